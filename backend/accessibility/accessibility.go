@@ -0,0 +1,223 @@
+// Package accessibility проверяет авторский контент уроков на типичные проблемы доступности:
+// картинки без альтернативного текста, текст с недостаточным контрастом и сплошные длинные абзацы.
+// Проверка эвристическая и работает по тексту урока (HTML или Markdown), без отрисовки страницы.
+package accessibility
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Виды проблем
+const (
+	MissingAlt    = "missing_alt"
+	LowContrast   = "low_contrast"
+	LongParagraph = "long_paragraph"
+)
+
+const (
+	// WCAG 2.1 AA for normal text
+	minContrast = 4.5
+	// Paragraphs longer than this are hard to follow with a screen reader or dyslexia
+	maxParagraphWords = 150
+
+	excerptLength = 80
+)
+
+// Штраф к оценке за каждую проблему
+var penalties = map[string]int{MissingAlt: 15, LowContrast: 10, LongParagraph: 5}
+
+// Issue — найденная проблема с фрагментом текста, где она встретилась
+type Issue struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Excerpt string `json:"excerpt"`
+}
+
+// Report — результат проверки: оценка от 0 до 100 и список проблем
+type Report struct {
+	Score  int     `json:"score"`
+	Issues []Issue `json:"issues"`
+}
+
+var (
+	imgTag        = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	altAttr       = regexp.MustCompile(`(?is)\balt\s*=`)
+	markdownImage = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]*)\)`)
+	styledTag     = regexp.MustCompile(`(?is)<[a-z][a-z0-9]*\b[^>]*\bstyle\s*=\s*("[^"]*"|'[^']*')[^>]*>`)
+	fontTag       = regexp.MustCompile(`(?is)<font\b[^>]*\bcolor\s*=\s*["']?([^"'\s>]+)[^>]*>`)
+	colorRule     = regexp.MustCompile(`(?i)(?:^|;)\s*(color|background-color|background)\s*:\s*([^;]+)`)
+	paragraphEnd  = regexp.MustCompile(`(?i)</p\s*>|<br\s*/?>|</li\s*>|</h[1-6]\s*>|</div\s*>|\n\s*\n`)
+	anyTag        = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// Check проверяет текст урока
+func Check(content string) Report {
+	issues := []Issue{}
+	issues = append(issues, missingAlt(content)...)
+	issues = append(issues, lowContrast(content)...)
+	issues = append(issues, longParagraphs(content)...)
+
+	score := 100
+	for _, issue := range issues {
+		score -= penalties[issue.Kind]
+	}
+	return Report{Score: max(score, 0), Issues: issues}
+}
+
+func missingAlt(content string) []Issue {
+	var issues []Issue
+	for _, tag := range imgTag.FindAllString(content, -1) {
+		// alt="" is the correct markup for decorative images
+		if !altAttr.MatchString(tag) {
+			issues = append(issues, Issue{Kind: MissingAlt, Message: "Image has no alt text", Excerpt: excerpt(tag)})
+		}
+	}
+	for _, match := range markdownImage.FindAllStringSubmatch(content, -1) {
+		if strings.TrimSpace(match[1]) == "" {
+			issues = append(issues, Issue{Kind: MissingAlt, Message: "Image has no alt text", Excerpt: excerpt(match[0])})
+		}
+	}
+	return issues
+}
+
+func lowContrast(content string) []Issue {
+	var issues []Issue
+	check := func(tag, foreground, background string) {
+		fg, ok := parseColor(foreground)
+		if !ok {
+			return
+		}
+		// Without an explicit background the text is assumed to be on the default white page
+		bg := [3]float64{255, 255, 255}
+		if background != "" {
+			if parsed, ok := parseColor(background); ok {
+				bg = parsed
+			}
+		}
+		if ratio := contrast(fg, bg); ratio < minContrast {
+			issues = append(issues, Issue{
+				Kind:    LowContrast,
+				Message: fmt.Sprintf("Text contrast is %.1f:1, at least %.1f:1 is needed", ratio, minContrast),
+				Excerpt: excerpt(tag),
+			})
+		}
+	}
+
+	for _, match := range styledTag.FindAllStringSubmatch(content, -1) {
+		style := strings.Trim(match[1], `"'`)
+		var foreground, background string
+		for _, rule := range colorRule.FindAllStringSubmatch(style, -1) {
+			value := strings.TrimSpace(rule[2])
+			if strings.EqualFold(rule[1], "color") {
+				foreground = value
+			} else if fields := strings.Fields(value); len(fields) > 0 {
+				// background: may carry more than a color; the first token usually is one
+				background = fields[0]
+			}
+		}
+		if foreground != "" {
+			check(match[0], foreground, background)
+		}
+	}
+	for _, match := range fontTag.FindAllStringSubmatch(content, -1) {
+		check(match[0], match[1], "")
+	}
+	return issues
+}
+
+func longParagraphs(content string) []Issue {
+	var issues []Issue
+	for _, paragraph := range paragraphEnd.Split(content, -1) {
+		text := anyTag.ReplaceAllString(markdownImage.ReplaceAllString(paragraph, " "), " ")
+		text = strings.Join(strings.Fields(text), " ")
+		if words := len(strings.Fields(text)); words > maxParagraphWords {
+			issues = append(issues, Issue{
+				Kind:    LongParagraph,
+				Message: fmt.Sprintf("Paragraph has %d words, consider splitting it (up to %d)", words, maxParagraphWords),
+				Excerpt: excerpt(text),
+			})
+		}
+	}
+	return issues
+}
+
+var namedColors = map[string][3]float64{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "red": {255, 0, 0}, "green": {0, 128, 0}, "blue": {0, 0, 255},
+	"yellow": {255, 255, 0}, "orange": {255, 165, 0}, "gray": {128, 128, 128}, "grey": {128, 128, 128},
+	"silver": {192, 192, 192}, "lightgray": {211, 211, 211}, "lightgrey": {211, 211, 211}, "gainsboro": {220, 220, 220},
+	"whitesmoke": {245, 245, 245}, "lightyellow": {255, 255, 224}, "pink": {255, 192, 203}, "cyan": {0, 255, 255},
+	"aqua": {0, 255, 255}, "lime": {0, 255, 0}, "navy": {0, 0, 128}, "maroon": {128, 0, 0}, "purple": {128, 0, 128},
+	"darkgray": {169, 169, 169}, "darkgrey": {169, 169, 169},
+}
+
+var rgbFunction = regexp.MustCompile(`(?i)^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)`)
+
+// parseColor понимает #rgb, #rrggbb, rgb()/rgba() и распространенные названия цветов
+func parseColor(value string) ([3]float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important")))
+	if color, ok := namedColors[value]; ok {
+		return color, true
+	}
+	if match := rgbFunction.FindStringSubmatch(value); match != nil {
+		var color [3]float64
+		for i := range color {
+			channel, _ := strconv.Atoi(match[i+1])
+			color[i] = float64(min(channel, 255))
+		}
+		return color, true
+	}
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return [3]float64{}, false
+		}
+		var color [3]float64
+		for i := range color {
+			channel, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+			if err != nil {
+				return [3]float64{}, false
+			}
+			color[i] = float64(channel)
+		}
+		return color, true
+	}
+	return [3]float64{}, false
+}
+
+// contrast — отношение контрастности по WCAG: от 1 (одинаковые цвета) до 21 (черный на белом)
+func contrast(a, b [3]float64) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+func luminance(color [3]float64) float64 {
+	var channels [3]float64
+	for i, value := range color {
+		value /= 255
+		if value <= 0.03928 {
+			channels[i] = value / 12.92
+		} else {
+			channels[i] = math.Pow((value+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*channels[0] + 0.7152*channels[1] + 0.0722*channels[2]
+}
+
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= excerptLength {
+		return text
+	}
+	return string([]rune(text)[:excerptLength]) + "…"
+}
@@ -0,0 +1,100 @@
+package accessibility
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The checker works on text only: these cases need neither the database nor the app
+func TestCheck(t *testing.T) {
+	longParagraph := "<p>" + strings.Repeat("word ", maxParagraphWords+1) + "</p>"
+
+	cases := []struct {
+		name    string
+		content string
+		kinds   []string
+	}{
+		{"clean lesson", `<p style="color: #000">Readable</p><img src="a.png" alt="Diagram">`, nil},
+		{"image without alt", `<img src="a.png">`, []string{MissingAlt}},
+		{"decorative image with empty alt", `<img src="a.png" alt="">`, nil},
+		{"markdown image without alt", `![](chart.png)`, []string{MissingAlt}},
+		{"markdown image with alt", `![Chart of results](chart.png)`, nil},
+		{"light gray on white", `<span style="color: #ccc">Faint</span>`, []string{LowContrast}},
+		{"white on a dark background", `<span style="color: white; background-color: #222">Fine</span>`, nil},
+		{"yellow on white via background shorthand", `<p style="color:yellow;background:#fff url(x.png)">Hi</p>`, []string{LowContrast}},
+		{"font tag color", `<font color="silver">Old markup</font>`, []string{LowContrast}},
+		{"unknown color is skipped", `<span style="color: var(--muted)">Themed</span>`, nil},
+		// Regression: an empty background declaration used to index an empty slice and panic
+		{"empty background declaration", `<span style="color: #ccc; background: ">Faint</span>`, []string{LowContrast}},
+		{"empty background without color", `<span style="background:;">Text</span>`, nil},
+		{"long paragraph", longParagraph, []string{LongParagraph}},
+		{"short paragraphs", "<p>One.</p><p>Two.</p>", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var report Report
+			assert.NotPanics(t, func() { report = Check(tc.content) })
+			kinds := []string{}
+			for _, issue := range report.Issues {
+				kinds = append(kinds, issue.Kind)
+			}
+			if tc.kinds == nil {
+				tc.kinds = []string{}
+			}
+			assert.Equal(t, tc.kinds, kinds)
+
+			expected := 100
+			for _, kind := range tc.kinds {
+				expected -= penalties[kind]
+			}
+			assert.Equal(t, expected, report.Score)
+		})
+	}
+}
+
+func TestScoreNeverNegative(t *testing.T) {
+	report := Check(strings.Repeat(`<img src="a.png">`, 10))
+	assert.Len(t, report.Issues, 10)
+	assert.Equal(t, 0, report.Score)
+}
+
+func TestParseColor(t *testing.T) {
+	cases := []struct {
+		value string
+		color [3]float64
+		ok    bool
+	}{
+		{"#fff", [3]float64{255, 255, 255}, true},
+		{"#1A2b3C", [3]float64{26, 43, 60}, true},
+		{"rgb(10, 20, 30)", [3]float64{10, 20, 30}, true},
+		{"rgba(300, 0, 0, 0.5)", [3]float64{255, 0, 0}, true},
+		{"Navy !important", [3]float64{0, 0, 128}, true},
+		{"#12345", [3]float64{}, false},
+		{"#ggg", [3]float64{}, false},
+		{"transparent", [3]float64{}, false},
+		{"", [3]float64{}, false},
+	}
+	for _, tc := range cases {
+		color, ok := parseColor(tc.value)
+		assert.Equal(t, tc.ok, ok, tc.value)
+		assert.Equal(t, tc.color, color, tc.value)
+	}
+}
+
+func TestContrast(t *testing.T) {
+	black, white := [3]float64{0, 0, 0}, [3]float64{255, 255, 255}
+	assert.InDelta(t, 21, contrast(black, white), 0.01)
+	assert.InDelta(t, 21, contrast(white, black), 0.01)
+	assert.InDelta(t, 1, contrast(white, white), 0.0001)
+	// #767676 is the lightest gray that passes AA on white
+	assert.GreaterOrEqual(t, contrast([3]float64{118, 118, 118}, white), minContrast)
+	assert.Less(t, contrast([3]float64{119, 119, 119}, white), minContrast)
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.Equal(t, "a b", excerpt("  a \n b "))
+	long := strings.Repeat("я", excerptLength+5)
+	assert.Equal(t, strings.Repeat("я", excerptLength)+"…", excerpt(long))
+}
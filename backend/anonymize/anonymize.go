@@ -0,0 +1,305 @@
+// Package anonymize превращает копию боевой базы в набор данных для staging:
+// email, имена пользователей, IP (в том числе внутри отчетов о честности сдачи) и строки браузера
+// заменяются детерминированно, поэтому одно и то же значение в разных таблицах остается одинаковым,
+// а связи между данными сохраняются.
+package anonymize
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"project/backend/integrity"
+	"project/backend/models"
+	"project/backend/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// emailDomain — зарезервированный домен (RFC 2606), письма на него никуда не уйдут
+const emailDomain = "example.invalid"
+
+var ErrSecretRequired = errors.New("a secret is required so the scrambled values can't be reversed by hashing known emails")
+
+// Options — параметры анонимизации
+type Options struct {
+	// Secret — ключ HMAC; с тем же ключом повторный запуск на новой копии дает те же значения
+	Secret string
+	// Password, если задан, становится паролем всех пользователей, чтобы на staging можно было войти под любым
+	Password string
+}
+
+// Report — сколько строк изменено в каждой колонке (table.column)
+type Report map[string]int64
+
+// Scrambler детерминированно заменяет персональные данные
+type Scrambler struct {
+	secret []byte
+}
+
+func NewScrambler(secret string) *Scrambler {
+	return &Scrambler{secret: []byte(secret)}
+}
+
+func (s *Scrambler) digest(kind, value string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(kind + ":" + value))
+	return mac.Sum(nil)
+}
+
+// Username заменяет имя пользователя
+func (s *Scrambler) Username(username string) string {
+	return "user_" + hex.EncodeToString(s.digest("username", username))[:12]
+}
+
+// Email заменяет адрес на адрес в зарезервированном домене; регистр адреса не учитывается
+func (s *Scrambler) Email(email string) string {
+	return "user_" + s.emailLocal(email) + "@" + emailDomain
+}
+
+func (s *Scrambler) emailLocal(email string) string {
+	return hex.EncodeToString(s.digest("email", strings.ToLower(strings.TrimSpace(email))))[:12]
+}
+
+// UserAgent заменяет строку браузера: одинаковые строки остаются одинаковыми, но версии и сборки
+// устройства по ней уже не узнать (разобранное устройство вроде "Chrome on Windows" остается как есть)
+func (s *Scrambler) UserAgent(userAgent string) string {
+	return "agent_" + hex.EncodeToString(s.digest("user_agent", userAgent))[:12]
+}
+
+// InstitutionalEmail заменяет только локальную часть: домен нужен, чтобы подтверждение принадлежности к университету работало
+func (s *Scrambler) InstitutionalEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return s.Email(email)
+	}
+	return "user_" + s.emailLocal(email) + email[at:]
+}
+
+// IP заменяет IPv4 адресом из 10.0.0.0/8, IPv6 — из fd00::/8, сохраняя тип адреса
+func (s *Scrambler) IP(ip string) string {
+	sum := s.digest("ip", ip)
+	parsed := net.ParseIP(ip)
+	if parsed != nil && parsed.To4() == nil {
+		scrambled := make(net.IP, net.IPv6len)
+		copy(scrambled, sum)
+		scrambled[0] = 0xfd
+		return scrambled.String()
+	}
+	return net.IPv4(10, sum[0], sum[1], sum[2]).String()
+}
+
+// column — колонка с персональными данными и способ ее замены
+type column struct {
+	model    interface{}
+	name     string
+	scramble func(string) string
+}
+
+// uniqueColumns — колонки с уникальным индексом: значения, давшие одну замену, в них различаются суффиксом
+var uniqueColumns = map[string]bool{"users.username": true, "users.email": true}
+
+// scrambleBatch — сколько значений заменяет один UPDATE
+const scrambleBatch = 500
+
+// Run анонимизирует базу в одной транзакции. Запускается только на копии, боевые данные он необратимо меняет.
+func Run(ctx context.Context, db *gorm.DB, opts Options) (Report, error) {
+	if opts.Secret == "" {
+		return nil, ErrSecretRequired
+	}
+	s := NewScrambler(opts.Secret)
+	blank := func(string) string { return "" }
+
+	columns := []column{
+		{&models.User{}, "username", s.Username},
+		{&models.User{}, "email", s.Email},
+		{&models.User{}, "university_email", s.InstitutionalEmail},
+		{&models.User{}, "avatar_url", blank},
+		{&models.User{}, "avatar_key", blank},
+		{&models.EmailChange{}, "new_email", s.Email},
+		{&models.Invitation{}, "email", s.Email},
+		{&models.AffiliationVerification{}, "email", s.InstitutionalEmail},
+		{&models.CourseAccessList{}, "email", s.Email},
+		{&models.LoginHistory{}, "ip", s.IP},
+		{&models.LoginHistory{}, "user_agent", s.UserAgent},
+		{&models.DeprecatedRouteCall{}, "user_agent", s.UserAgent},
+		{&models.ExamAttempt{}, "start_ip", s.IP},
+		{&models.ExamAttempt{}, "submit_ip", s.IP},
+		// Usernames copied next to comments and analytics rows
+		{&models.CourseComment{}, "user_name", s.Username},
+		{&models.CourseCommentReply{}, "user_name", s.Username},
+		{&models.TestComment{}, "user_name", s.Username},
+		{&models.TestCommentReply{}, "user_name", s.Username},
+		{&models.CourseAnalytics{}, "user_name", s.Username},
+		{&models.TestAnalytics{}, "user_name", s.Username},
+		{&models.CourseComment{}, "user_image", blank},
+		{&models.CourseCommentReply{}, "user_image", blank},
+		{&models.TestComment{}, "user_image", blank},
+		{&models.TestCommentReply{}, "user_image", blank},
+	}
+
+	report := Report{}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, col := range columns {
+			updated, err := scrambleColumn(tx, col)
+			if err != nil {
+				return err
+			}
+			report[tableName(tx, col.model)+"."+col.name] += updated
+		}
+
+		updated, err := scrambleIntegrityReports(tx, s)
+		if err != nil {
+			return err
+		}
+		report["exam_integrity_reports.report"] = updated
+
+		// Queued and sent messages carry real addresses in their payloads
+		result := tx.Unscoped().Where("1 = 1").Delete(&models.OutboxMessage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		report["outbox_messages.*"] = result.RowsAffected
+
+		if opts.Password != "" {
+			hash, err := utils.Passwords.Hash(opts.Password)
+			if err != nil {
+				return err
+			}
+			// Bumping the token version also logs out anything issued before the copy was made
+			result := tx.Unscoped().Model(&models.User{}).Where("1 = 1").Updates(map[string]interface{}{
+				"password_hash": hash,
+				"token_version": gorm.Expr("token_version + 1"),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			report["users.password_hash"] = result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// scrambleColumn заменяет каждое различное непустое значение колонки, включая мягко удаленные строки.
+// Замены уходят пачками по scrambleBatch значений в одном UPDATE ... FROM (VALUES ...).
+// В уникальной колонке значения, которые дают одну замену (адреса, различающиеся только регистром),
+// получают суффикс -2, -3...; значения читаются по порядку, поэтому суффиксы не меняются от запуска к запуску.
+func scrambleColumn(tx *gorm.DB, col column) (int64, error) {
+	table := tableName(tx, col.model)
+	var values []string
+	if err := tx.Unscoped().Model(col.model).
+		Where(fmt.Sprintf("%s IS NOT NULL AND %s <> ''", col.name, col.name)).
+		Distinct(col.name).Order(col.name).Pluck(col.name, &values).Error; err != nil {
+		return 0, fmt.Errorf("reading %s.%s: %w", table, col.name, err)
+	}
+
+	scrambled := make([]string, len(values))
+	taken := make(map[string]bool, len(values))
+	for i, value := range values {
+		scrambled[i] = col.scramble(value)
+		if uniqueColumns[table+"."+col.name] {
+			candidate := scrambled[i]
+			for n := 2; taken[candidate]; n++ {
+				candidate = withSuffix(scrambled[i], n)
+			}
+			scrambled[i] = candidate
+			taken[candidate] = true
+		}
+	}
+
+	var updated int64
+	for start := 0; start < len(values); start += scrambleBatch {
+		end := min(start+scrambleBatch, len(values))
+		rows := make([]string, 0, end-start)
+		args := make([]interface{}, 0, 2*(end-start))
+		for i := start; i < end; i++ {
+			rows = append(rows, "(?, ?)")
+			args = append(args, values[i], scrambled[i])
+		}
+		result := tx.Exec(fmt.Sprintf(
+			"UPDATE %[1]s SET %[2]s = scrambled.value FROM (VALUES %[3]s) AS scrambled(original, value) WHERE %[1]s.%[2]s = scrambled.original",
+			table, col.name, strings.Join(rows, ", ")), args...)
+		if result.Error != nil {
+			return updated, fmt.Errorf("updating %s.%s: %w", table, col.name, result.Error)
+		}
+		updated += result.RowsAffected
+	}
+	return updated, nil
+}
+
+// integrityBatch — сколько отчетов о честности сдачи читается за раз
+const integrityBatch = 100
+
+// scrambleIntegrityReports заменяет IP и имена пользователей внутри JSON отчетов о честности сдачи
+// теми же значениями, что и в колонках: студенты с общим IP и после замены остаются в одной группе
+func scrambleIntegrityReports(tx *gorm.DB, s *Scrambler) (int64, error) {
+	scrambleRef := func(ref *integrity.UserRef) {
+		if ref.Username != "" {
+			ref.Username = s.Username(ref.Username)
+		}
+	}
+
+	var updated int64
+	var batch []models.ExamIntegrityReport
+	result := tx.Unscoped().Where("report IS NOT NULL AND report <> ''").
+		FindInBatches(&batch, integrityBatch, func(_ *gorm.DB, _ int) error {
+			for _, stored := range batch {
+				var report integrity.Report
+				if err := json.Unmarshal([]byte(stored.Report), &report); err != nil {
+					return fmt.Errorf("reading integrity report %d: %w", stored.ID, err)
+				}
+				for i := range report.TimeAnomalies {
+					scrambleRef(&report.TimeAnomalies[i].UserRef)
+				}
+				for i := range report.IPClusters {
+					report.IPClusters[i].IP = s.IP(report.IPClusters[i].IP)
+					for j := range report.IPClusters[i].Users {
+						scrambleRef(&report.IPClusters[i].Users[j])
+					}
+				}
+				for i := range report.SimilarPairs {
+					scrambleRef(&report.SimilarPairs[i].A)
+					scrambleRef(&report.SimilarPairs[i].B)
+				}
+				for i := range report.Flagged {
+					scrambleRef(&report.Flagged[i])
+				}
+
+				data, err := json.Marshal(report)
+				if err != nil {
+					return err
+				}
+				if err := tx.Unscoped().Model(&stored).Update("report", string(data)).Error; err != nil {
+					return fmt.Errorf("updating integrity report %d: %w", stored.ID, err)
+				}
+				updated++
+			}
+			return nil
+		})
+	return updated, result.Error
+}
+
+// withSuffix добавляет к замене номер: к локальной части адреса или к концу имени
+func withSuffix(value string, n int) string {
+	if at := strings.LastIndex(value, "@"); at >= 0 {
+		return fmt.Sprintf("%s-%d%s", value[:at], n, value[at:])
+	}
+	return fmt.Sprintf("%s-%d", value, n)
+}
+
+func tableName(tx *gorm.DB, model interface{}) string {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Sprintf("%T", model)
+	}
+	return stmt.Schema.Table
+}
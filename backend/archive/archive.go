@@ -0,0 +1,227 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/backend/models"
+	"project/backend/storage"
+	"project/backend/utils"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// bundleVersion меняется при несовместимом изменении формата выгрузки
+const bundleVersion = 1
+
+var (
+	ErrAlreadyArchived = errors.New("User is already archived")
+	ErrNotArchived     = errors.New("User is not archived")
+	ErrLegalHold       = errors.New("User data is under a legal hold")
+)
+
+// Bundle — JSON-выгрузка данных пользователя, которые удаляются из основной БД.
+// Профиль, комментарии и авторский контент остаются на месте.
+type Bundle struct {
+	Version        int                         `json:"version"`
+	UserID         uint                        `json:"user_id"`
+	ArchivedAt     time.Time                   `json:"archived_at"`
+	Progress       []models.UserProgress       `json:"progress"`
+	CourseProgress []models.UserCourseProgress `json:"course_progress"`
+	TestProgress   []models.UserTestProgress   `json:"test_progress"`
+	LoginHistory   []models.LoginHistory       `json:"login_history"`
+	Settings       []models.UserSettings       `json:"settings"`
+}
+
+// Candidates возвращает ID пользователей без входов с момента cutoff, которые еще не архивированы.
+// Администраторы (по полю role и по RBAC) и пользователи, чьи API ключи использовались после cutoff,
+// не архивируются: интеграции работают без входов в интерфейс. Не архивируются и пользователи
+// под юридическим удержанием: их данные нельзя уносить из базы.
+func Candidates(db *gorm.DB, cutoff time.Time, limit int) ([]uint, error) {
+	recentLogins := db.Model(&models.LoginHistory{}).Select("1").
+		Where("user_id = users.id AND login_time >= ? AND success", cutoff)
+	rbacAdmins := db.Table("user_roles").Select("1").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = users.id AND user_roles.deleted_at IS NULL AND roles.name = ?", "admin")
+	recentKeys := db.Model(&models.ApiKey{}).Select("1").
+		Where("user_id = users.id AND last_used_at >= ?", cutoff)
+
+	var ids []uint
+	err := db.Model(&models.User{}).
+		Where("archived_at IS NULL AND role <> ? AND created_at < ?", "admin", cutoff).
+		Where("NOT EXISTS (?)", recentLogins).
+		Where("NOT EXISTS (?)", rbacAdmins).
+		Where("NOT EXISTS (?)", recentKeys).
+		Where("id NOT IN (?)", utils.HeldIDs(db, models.HoldSubjectUser)).
+		Order("id").Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ArchiveUser выгружает данные пользователя в store и удаляет их из БД.
+// Объект пишется до коммита транзакции и удаляется, если она не прошла.
+func ArchiveUser(ctx context.Context, db *gorm.DB, store storage.Storage, userID uint) (*models.UserArchive, error) {
+	var record models.UserArchive
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.ArchivedAt != nil {
+			return ErrAlreadyArchived
+		}
+		// A hold may have been placed after the candidates were picked
+		if utils.UnderLegalHold(tx, models.HoldSubjectUser, userID) {
+			return ErrLegalHold
+		}
+
+		now := time.Now()
+		bundle := Bundle{Version: bundleVersion, UserID: userID, ArchivedAt: now}
+		for _, load := range []interface{}{&bundle.Progress, &bundle.CourseProgress, &bundle.TestProgress,
+			&bundle.LoginHistory, &bundle.Settings} {
+			if err := tx.Where("user_id = ?", userID).Find(load).Error; err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(bundle)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("users/%d/%d.json", userID, now.UnixNano())
+		if _, err := store.Put(ctx, key, data, "application/json"); err != nil {
+			return fmt.Errorf("upload archive: %w", err)
+		}
+
+		err = purge(tx, userID)
+		if err == nil {
+			err = tx.Model(&user).Update("archived_at", now).Error
+		}
+		if err == nil {
+			record = models.UserArchive{UserID: userID, StorageKey: key, Size: len(data), ArchivedAt: now}
+			err = tx.Create(&record).Error
+		}
+		if err != nil {
+			store.Delete(ctx, key)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// RestoreUser возвращает данные пользователя из последней выгрузки в БД.
+// Объект в хранилище удаляется только после успешного коммита.
+func RestoreUser(ctx context.Context, db *gorm.DB, store storage.Storage, userID uint) error {
+	var record models.UserArchive
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.ArchivedAt == nil {
+			return ErrNotArchived
+		}
+
+		if err := tx.Where("user_id = ? AND restored_at IS NULL", userID).
+			Order("archived_at DESC").First(&record).Error; err != nil {
+			return err
+		}
+
+		data, err := store.Get(ctx, record.StorageKey)
+		if err != nil {
+			return fmt.Errorf("download archive: %w", err)
+		}
+		var bundle Bundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return fmt.Errorf("decode archive: %w", err)
+		}
+		if bundle.Version != bundleVersion || bundle.UserID != userID {
+			return fmt.Errorf("archive %s doesn't match user %d", record.StorageKey, userID)
+		}
+
+		// Rows that appeared after archiving (a fresh login, a course started again) win over the archived ones
+		if bundle.Progress, err = fresh(tx, &models.UserProgress{}, userID, "user_id", bundle.Progress,
+			func(p models.UserProgress) uint { return p.UserID }); err != nil {
+			return err
+		}
+		if bundle.CourseProgress, err = fresh(tx, &models.UserCourseProgress{}, userID, "course_id", bundle.CourseProgress,
+			func(p models.UserCourseProgress) uint { return p.CourseID }); err != nil {
+			return err
+		}
+		if bundle.TestProgress, err = fresh(tx, &models.UserTestProgress{}, userID, "test_id", bundle.TestProgress,
+			func(p models.UserTestProgress) uint { return p.TestID }); err != nil {
+			return err
+		}
+		if bundle.Settings, err = fresh(tx, &models.UserSettings{}, userID, "user_id", bundle.Settings,
+			func(s models.UserSettings) uint { return s.UserID }); err != nil {
+			return err
+		}
+		for _, rows := range []interface{}{&bundle.Progress, &bundle.CourseProgress, &bundle.TestProgress,
+			&bundle.LoginHistory, &bundle.Settings} {
+			if err := createAll(tx, rows); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(&user).Update("archived_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&record).Update("restored_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	store.Delete(ctx, record.StorageKey)
+	return nil
+}
+
+// purge удаляет из БД все данные, попадающие в выгрузку
+func purge(tx *gorm.DB, userID uint) error {
+	for _, model := range []interface{}{&models.UserProgress{}, &models.UserCourseProgress{},
+		&models.UserTestProgress{}, &models.LoginHistory{}, &models.UserSettings{}} {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fresh отбрасывает архивные строки, для ключа которых (column) после архивации появилась новая строка
+func fresh[T any](tx *gorm.DB, model interface{}, userID uint, column string, rows []T, key func(T) uint) ([]T, error) {
+	if len(rows) == 0 {
+		return rows, nil
+	}
+	var current []uint
+	if err := tx.Model(model).Where("user_id = ?", userID).Pluck(column, &current).Error; err != nil {
+		return nil, err
+	}
+	taken := make(map[uint]bool, len(current))
+	for _, value := range current {
+		taken[value] = true
+	}
+
+	kept := rows[:0]
+	for _, row := range rows {
+		if !taken[key(row)] {
+			kept = append(kept, row)
+		}
+	}
+	return kept, nil
+}
+
+// createAll вставляет строки из указателя на срез с их исходными ID (пустые срезы пропускаются)
+func createAll(tx *gorm.DB, rows interface{}) error {
+	if reflect.ValueOf(rows).Elem().Len() == 0 {
+		return nil
+	}
+	return tx.Omit(clause.Associations).CreateInBatches(rows, 500).Error
+}
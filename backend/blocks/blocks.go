@@ -0,0 +1,299 @@
+// Package blocks хранит содержимое урока как упорядоченные типизированные блоки (текст, изображение,
+// видео, встраивание, код, вопрос для самопроверки). Lesson.Content остается HTML-версией блоков:
+// ее читают поиск, эмбеддинги, проверка доступности, выгрузки и старые клиенты.
+package blocks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"project/backend/markup"
+	"project/backend/models"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownType = errors.New("unknown block type")
+
+	codeLanguage = regexp.MustCompile(`^[a-z0-9+#.-]{0,30}$`)
+)
+
+// Text — HTML-фрагмент (то же, что раньше было содержимым урока). Если задан Markdown, HTML отрисовывается из него.
+type Text struct {
+	HTML     string `json:"html"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type Image struct {
+	URL     string `json:"url"`
+	Alt     string `json:"alt"`
+	Caption string `json:"caption,omitempty"`
+}
+
+type Video struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// Embed — внешний плеер или интерактив во фрейме (только https-адреса markup.EmbedHosts)
+type Embed struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+type Code struct {
+	Language string `json:"language,omitempty"`
+	Code     string `json:"code"`
+}
+
+// Quiz — вопрос для самопроверки внутри урока; в оценку не входит, поэтому ответ отдается клиенту
+type Quiz struct {
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+	Correct     int      `json:"correct"`
+	Explanation string   `json:"explanation,omitempty"`
+}
+
+// Validate проверяет данные блока своего типа и возвращает их в нормализованном виде для LessonBlock.Data
+func Validate(blockType string, data json.RawMessage) (string, error) {
+	var value interface{}
+	switch blockType {
+	case models.BlockText:
+		var b Text
+		if err := json.Unmarshal(data, &b); err != nil {
+			return "", err
+		}
+		if b.Markdown != "" {
+			b.HTML = markup.Markdown(b.Markdown)
+		} else {
+			b.HTML = markup.Sanitize(b.HTML)
+		}
+		if strings.TrimSpace(b.HTML) == "" {
+			return "", errors.New("html or markdown is required")
+		}
+		value = b
+	case models.BlockImage:
+		var b Image
+		if err := json.Unmarshal(data, &b); err != nil {
+			return "", err
+		}
+		if err := checkURL(b.URL, false); err != nil {
+			return "", err
+		}
+		value = b
+	case models.BlockVideo:
+		var b Video
+		if err := json.Unmarshal(data, &b); err != nil {
+			return "", err
+		}
+		if err := checkURL(b.URL, false); err != nil {
+			return "", err
+		}
+		value = b
+	case models.BlockEmbed:
+		var b Embed
+		if err := json.Unmarshal(data, &b); err != nil {
+			return "", err
+		}
+		if err := checkURL(b.URL, true); err != nil {
+			return "", err
+		}
+		if !markup.EmbedAllowed(b.URL) {
+			return "", errors.New("url must be on one of the embed hosts: " + strings.Join(markup.EmbedHosts, ", "))
+		}
+		value = b
+	case models.BlockCode:
+		var b Code
+		if err := json.Unmarshal(data, &b); err != nil {
+			return "", err
+		}
+		b.Language = strings.ToLower(strings.TrimSpace(b.Language))
+		if b.Code == "" {
+			return "", errors.New("code is required")
+		}
+		if !codeLanguage.MatchString(b.Language) {
+			return "", errors.New("language must be a short identifier like go or python")
+		}
+		value = b
+	case models.BlockQuiz:
+		var b Quiz
+		if err := json.Unmarshal(data, &b); err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(b.Question) == "" {
+			return "", errors.New("question is required")
+		}
+		if len(b.Options) < 2 {
+			return "", errors.New("a quiz needs at least two options")
+		}
+		if b.Correct < 0 || b.Correct >= len(b.Options) {
+			return "", errors.New("correct must be the index of one of the options")
+		}
+		value = b
+	default:
+		return "", fmt.Errorf("%w %q, expected one of %s", ErrUnknownType, blockType, strings.Join(models.BlockTypes, ", "))
+	}
+
+	normalized, err := json.Marshal(value)
+	return string(normalized), err
+}
+
+// checkURL принимает адреса http(s) и пути загрузок платформы; фреймы — только https
+func checkURL(raw string, httpsOnly bool) error {
+	if raw == "" {
+		return errors.New("url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("url is invalid")
+	}
+	switch {
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && !httpsOnly:
+		return nil
+	case u.Scheme == "" && u.Host == "" && strings.HasPrefix(raw, "/") && !httpsOnly:
+		return nil
+	}
+	if httpsOnly {
+		return errors.New("url must be an https address")
+	}
+	return errors.New("url must be an http(s) address or an uploaded file path")
+}
+
+// Render собирает HTML урока из блоков по порядку
+func Render(list []models.LessonBlock) string {
+	parts := make([]string, 0, len(list))
+	for _, block := range list {
+		if part := renderBlock(block); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func renderBlock(block models.LessonBlock) string {
+	data := []byte(block.Data)
+	esc := html.EscapeString
+	switch block.Type {
+	case models.BlockText:
+		// Blocks saved before sanitizing was added are cleaned on the way out
+		var b Text
+		json.Unmarshal(data, &b)
+		return markup.Sanitize(b.HTML)
+	case models.BlockImage:
+		var b Image
+		json.Unmarshal(data, &b)
+		return fmt.Sprintf(`<figure><img src="%s" alt="%s">%s</figure>`, esc(b.URL), esc(b.Alt), caption(b.Caption))
+	case models.BlockVideo:
+		var b Video
+		json.Unmarshal(data, &b)
+		return fmt.Sprintf(`<figure><video controls src="%s"></video>%s</figure>`, esc(b.URL), caption(b.Caption))
+	case models.BlockEmbed:
+		var b Embed
+		json.Unmarshal(data, &b)
+		return fmt.Sprintf(`<iframe src="%s" title="%s" loading="lazy" allowfullscreen sandbox="%s"></iframe>`,
+			esc(b.URL), esc(b.Title), markup.IframeSandbox)
+	case models.BlockCode:
+		var b Code
+		json.Unmarshal(data, &b)
+		class := ""
+		if b.Language != "" {
+			class = fmt.Sprintf(` class="language-%s"`, esc(b.Language))
+		}
+		return fmt.Sprintf(`<pre><code%s>%s</code></pre>`, class, esc(b.Code))
+	case models.BlockQuiz:
+		// The answer stays out of the HTML, clients that know blocks check it themselves
+		var b Quiz
+		json.Unmarshal(data, &b)
+		var sb strings.Builder
+		sb.WriteString(`<div class="lesson-quiz"><p>` + esc(b.Question) + "</p><ol>")
+		for _, option := range b.Options {
+			sb.WriteString("<li>" + esc(option) + "</li>")
+		}
+		sb.WriteString("</ol></div>")
+		return sb.String()
+	}
+	return ""
+}
+
+func caption(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "<figcaption>" + html.EscapeString(text) + "</figcaption>"
+}
+
+// ForLesson возвращает блоки урока по порядку. У урока, содержимое которого записано одной строкой
+// (старые клиенты, импорт), блоков нет — тогда он отдается как один текстовый блок без ID.
+func ForLesson(db *gorm.DB, lesson *models.Lesson) ([]models.LessonBlock, error) {
+	var list []models.LessonBlock
+	if err := db.Where("lesson_id = ?", lesson.ID).Order("sequence_order, id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 && strings.TrimSpace(lesson.Content) != "" {
+		list = append(list, textBlock(lesson))
+	}
+	return list, nil
+}
+
+// Materialize сохраняет содержимое урока без блоков текстовым блоком, чтобы блоки можно было редактировать
+func Materialize(tx *gorm.DB, lesson *models.Lesson) ([]models.LessonBlock, error) {
+	list, err := ForLesson(tx, lesson)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 1 && list[0].ID == 0 {
+		if err := tx.Create(&list[0]).Error; err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// Sync переписывает Lesson.Content по текущим блокам урока. Markdown урока к этому времени
+// перенесен в текстовый блок (Materialize), поэтому у самого урока он сбрасывается.
+func Sync(tx *gorm.DB, lesson *models.Lesson) error {
+	var list []models.LessonBlock
+	if err := tx.Where("lesson_id = ?", lesson.ID).Order("sequence_order, id").Find(&list).Error; err != nil {
+		return err
+	}
+	lesson.Content, lesson.Markdown = Render(list), ""
+	return tx.Model(lesson).Updates(map[string]interface{}{"content": lesson.Content, "markdown": ""}).Error
+}
+
+// Has сообщает, что урок редактируется по блокам, а не одной строкой
+func Has(db *gorm.DB, lessonID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.LessonBlock{}).Where("lesson_id = ?", lessonID).Count(&count).Error
+	return count > 0, err
+}
+
+// Reset удаляет блоки урока, когда его содержимое записано одной строкой: источником снова становится Content
+func Reset(tx *gorm.DB, lessonID uint) error {
+	return tx.Where("lesson_id = ?", lessonID).Delete(&models.LessonBlock{}).Error
+}
+
+// Copy переносит блоки урока from в урок to (клонирование курса)
+func Copy(tx *gorm.DB, from, to uint) error {
+	var list []models.LessonBlock
+	if err := tx.Where("lesson_id = ?", from).Order("sequence_order, id").Find(&list).Error; err != nil {
+		return err
+	}
+	for _, block := range list {
+		if err := tx.Create(&models.LessonBlock{LessonID: to, Type: block.Type, Data: block.Data, SequenceOrder: block.SequenceOrder}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func textBlock(lesson *models.Lesson) models.LessonBlock {
+	data, _ := json.Marshal(Text{HTML: lesson.Content, Markdown: lesson.Markdown})
+	return models.LessonBlock{LessonID: lesson.ID, Type: models.BlockText, Data: string(data), SequenceOrder: 1}
+}
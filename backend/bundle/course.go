@@ -0,0 +1,298 @@
+// Package bundle описывает переносимый формат курса: содержимое без студентов, оценок и привязок
+// к конкретной организации. Из бандла курс можно создать в любом университете платформы.
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/backend/blocks"
+	"project/backend/markup"
+	"project/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// CourseVersion меняется при несовместимом изменении формата
+const CourseVersion = 1
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported course bundle version")
+	ErrInvalidBlock       = errors.New("invalid lesson block")
+	ErrInvalidAttachment  = errors.New("invalid lesson attachment")
+)
+
+// Course — курс в переносимом виде
+type Course struct {
+	Version           int              `json:"version"`
+	Title             string           `json:"title"`
+	ShortDesc         string           `json:"short_desc"`
+	Description       string           `json:"description"`
+	Difficulty        string           `json:"difficulty"`
+	Topic             string           `json:"topic"`
+	TopicID           *uint            `json:"topic_id"` // the topic tree is shared by the whole platform
+	License           string           `json:"license,omitempty"`
+	Attribution       string           `json:"attribution,omitempty"`
+	SourceLicense     string           `json:"source_license,omitempty"`
+	SourceAttribution string           `json:"source_attribution,omitempty"`
+	Modules           []Module         `json:"modules"`
+	Lessons           []Lesson         `json:"lessons"`
+	Survey            *Survey          `json:"survey,omitempty"`
+	GradeComponents   []GradeComponent `json:"grade_components"`
+}
+
+type Module struct {
+	Title         string `json:"title"`
+	SequenceOrder int    `json:"sequence_order"`
+}
+
+type Lesson struct {
+	Module        *int         `json:"module"` // index in Modules, nil for lessons outside modules
+	Title         string       `json:"title"`
+	Description   string       `json:"description"`
+	Content       string       `json:"content"`
+	Blocks        []Block      `json:"blocks,omitempty"` // Content is their HTML; without blocks Content alone is imported
+	Attachments   []Attachment `json:"attachments,omitempty"`
+	SequenceOrder int          `json:"sequence_order"`
+}
+
+// Attachment — файл урока. BuildCourse заполняет только описание и Key, содержимое в Data добавляет
+// выгрузка курса; в ZIP-архиве файл лежит отдельно, и File — его имя в архиве.
+type Attachment struct {
+	Title       string `json:"title"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data,omitempty"`
+	File        string `json:"file,omitempty"`
+	Key         string `json:"-"` // object key in storage.Default of the exported course
+}
+
+// StoreAttachment проверяет и сохраняет файл урока lessonID при импорте и возвращает его ключ в хранилище;
+// имя и тип файла он может уточнить
+type StoreAttachment func(lessonID uint, attachment *Attachment) (string, error)
+
+// Block — блок содержимого урока (см. пакет blocks)
+type Block struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type Survey struct {
+	Questions string `json:"questions"`
+	Required  bool   `json:"required"`
+}
+
+// GradeComponent — составляющая оценки; тесты принадлежат исходной организации, поэтому их ID не переносятся
+type GradeComponent struct {
+	Name          string  `json:"name"`
+	Kind          string  `json:"kind"`
+	Weight        float64 `json:"weight"`
+	Target        int     `json:"target"`
+	SequenceOrder int     `json:"sequence_order"`
+}
+
+// BuildCourse собирает бандл курса
+func BuildCourse(db *gorm.DB, courseID uint) (*Course, error) {
+	var course models.Course
+	if err := db.First(&course, courseID).Error; err != nil {
+		return nil, err
+	}
+
+	var modules []models.CourseModule
+	var lessons []models.Lesson
+	var components []models.CourseGradeComponent
+	var survey models.CourseSurvey
+	if err := db.Where("course_id = ?", courseID).Order("sequence_order, id").Find(&modules).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("course_id = ?", courseID).Order("sequence_order, id").Find(&lessons).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("course_id = ?", courseID).Order("sequence_order, id").Find(&components).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("course_id = ?", courseID).Limit(1).Find(&survey).Error; err != nil {
+		return nil, err
+	}
+
+	b := &Course{
+		Version:           CourseVersion,
+		Title:             course.Title,
+		ShortDesc:         course.ShortDesc,
+		Description:       course.Description,
+		Difficulty:        course.Difficulty,
+		Topic:             course.Topic,
+		TopicID:           course.TopicID,
+		License:           course.License,
+		Attribution:       course.Attribution,
+		SourceLicense:     course.SourceLicense,
+		SourceAttribution: course.SourceAttribution,
+		Modules:           make([]Module, 0, len(modules)),
+		Lessons:           make([]Lesson, 0, len(lessons)),
+		GradeComponents:   make([]GradeComponent, 0, len(components)),
+	}
+
+	index := make(map[uint]int, len(modules))
+	for i, module := range modules {
+		index[module.ID] = i
+		b.Modules = append(b.Modules, Module{Title: module.Title, SequenceOrder: module.SequenceOrder})
+	}
+	for _, lesson := range lessons {
+		item := Lesson{
+			Title:         lesson.Title,
+			Description:   lesson.Description,
+			Content:       lesson.Content,
+			SequenceOrder: lesson.SequenceOrder,
+		}
+		if lesson.ModuleID != nil {
+			if i, ok := index[*lesson.ModuleID]; ok {
+				item.Module = &i
+			}
+		}
+		var lessonBlocks []models.LessonBlock
+		if err := db.Where("lesson_id = ?", lesson.ID).Order("sequence_order, id").Find(&lessonBlocks).Error; err != nil {
+			return nil, err
+		}
+		for _, block := range lessonBlocks {
+			item.Blocks = append(item.Blocks, Block{Type: block.Type, Data: json.RawMessage(block.Data)})
+		}
+		var attachments []models.LessonAttachment
+		if err := db.Where("lesson_id = ?", lesson.ID).Order("id").Find(&attachments).Error; err != nil {
+			return nil, err
+		}
+		for _, attachment := range attachments {
+			item.Attachments = append(item.Attachments, Attachment{
+				Title:       attachment.Title,
+				FileName:    attachment.FileName,
+				ContentType: attachment.ContentType,
+				Key:         attachment.Key,
+			})
+		}
+		b.Lessons = append(b.Lessons, item)
+	}
+	for _, component := range components {
+		b.GradeComponents = append(b.GradeComponents, GradeComponent{
+			Name:          component.Name,
+			Kind:          component.Kind,
+			Weight:        component.Weight,
+			Target:        component.Target,
+			SequenceOrder: component.SequenceOrder,
+		})
+	}
+	if survey.ID != 0 {
+		b.Survey = &Survey{Questions: survey.Questions, Required: survey.Required}
+	}
+	return b, nil
+}
+
+// ImportCourse создает из бандла новый курс внутри транзакции tx. course задает автора и университет,
+// содержимое берется из бандла; курс создается черновиком с приватным доступом. Файлы уроков сохраняет store;
+// без него, как и без содержимого файла в бандле, они пропускаются.
+func ImportCourse(tx *gorm.DB, b *Course, course *models.Course, store StoreAttachment) error {
+	if b.Version != CourseVersion {
+		return fmt.Errorf("version %d: %w", b.Version, ErrUnsupportedVersion)
+	}
+
+	course.Title, course.ShortDesc, course.Description = b.Title, b.ShortDesc, b.Description
+	course.Difficulty, course.Topic, course.TopicID = b.Difficulty, b.Topic, b.TopicID
+	course.Status, course.PublishedAt = models.CourseDraft, nil
+	if err := tx.Create(course).Error; err != nil {
+		return err
+	}
+
+	moduleIDs := make([]uint, len(b.Modules))
+	for i, module := range b.Modules {
+		created := models.CourseModule{CourseID: course.ID, Title: module.Title, SequenceOrder: module.SequenceOrder}
+		if err := tx.Create(&created).Error; err != nil {
+			return err
+		}
+		moduleIDs[i] = created.ID
+	}
+
+	for _, lesson := range b.Lessons {
+		created := models.Lesson{
+			CourseID:      course.ID,
+			Title:         lesson.Title,
+			Description:   lesson.Description,
+			Content:       markup.Sanitize(lesson.Content),
+			SequenceOrder: lesson.SequenceOrder,
+		}
+		if lesson.Module != nil && *lesson.Module >= 0 && *lesson.Module < len(moduleIDs) {
+			created.ModuleID = &moduleIDs[*lesson.Module]
+		}
+		if err := tx.Create(&created).Error; err != nil {
+			return err
+		}
+		if err := importBlocks(tx, &created, lesson.Blocks); err != nil {
+			return err
+		}
+		if err := importAttachments(tx, &created, lesson.Attachments, store); err != nil {
+			return err
+		}
+	}
+
+	for _, component := range b.GradeComponents {
+		if err := tx.Create(&models.CourseGradeComponent{
+			CourseID:      course.ID,
+			Name:          component.Name,
+			Kind:          component.Kind,
+			Weight:        component.Weight,
+			Target:        component.Target,
+			SequenceOrder: component.SequenceOrder,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if b.Survey != nil {
+		if err := tx.Create(&models.CourseSurvey{CourseID: course.ID, Questions: b.Survey.Questions, Required: b.Survey.Required}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "private"}).Error
+}
+
+// importBlocks создает блоки урока и пересобирает по ним Content; блок, не прошедший проверку, — ошибка бандла
+func importBlocks(tx *gorm.DB, lesson *models.Lesson, list []Block) error {
+	if len(list) == 0 {
+		return nil
+	}
+	for i, block := range list {
+		data, err := blocks.Validate(block.Type, block.Data)
+		if err != nil {
+			return fmt.Errorf("%w: lesson %q, block %d: %v", ErrInvalidBlock, lesson.Title, i+1, err)
+		}
+		if err := tx.Create(&models.LessonBlock{LessonID: lesson.ID, Type: block.Type, Data: data, SequenceOrder: i + 1}).Error; err != nil {
+			return err
+		}
+	}
+	return blocks.Sync(tx, lesson)
+}
+
+// importAttachments сохраняет файлы урока через store и создает их строки
+func importAttachments(tx *gorm.DB, lesson *models.Lesson, list []Attachment, store StoreAttachment) error {
+	if store == nil {
+		return nil
+	}
+	for _, attachment := range list {
+		if len(attachment.Data) == 0 {
+			continue
+		}
+		key, err := store(lesson.ID, &attachment)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&models.LessonAttachment{
+			LessonID:    lesson.ID,
+			Title:       attachment.Title,
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			Size:        int64(len(attachment.Data)),
+			Key:         key,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"project/backend/markup"
+	"regexp"
+	"strings"
+)
+
+// Стандарты пакетов электронного обучения, из которых импортируется курс
+const (
+	StandardSCORM12   = "scorm_1.2"
+	StandardSCORM2004 = "scorm_2004"
+	StandardXAPI      = "xapi"
+)
+
+// Манифесты пакетов: SCORM описывает структуру в imsmanifest.xml, xAPI (Tin Can) — в tincan.xml
+const (
+	scormManifestFile = "imsmanifest.xml"
+	xapiManifestFile  = "tincan.xml"
+)
+
+// maxAssetSize ограничивает один медиафайл пакета, maxPackageFiles — число файлов в архиве
+const (
+	maxAssetSize    = 32 << 20
+	maxPackageFiles = 5000
+)
+
+var (
+	ErrNotPackage     = errors.New("not a SCORM or xAPI package: imsmanifest.xml or tincan.xml is missing")
+	ErrInvalidPackage = errors.New("invalid e-learning package")
+
+	bodyElement   = regexp.MustCompile(`(?is)<body\b[^>]*>(.*)</body\s*>`)
+	headElement   = regexp.MustCompile(`(?is)<head\b[^>]*>.*?</head\s*>|<!doctype[^>]*>|</?html\b[^>]*>`)
+	scriptElement = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script\s*>|<script\b[^>]*/>|<link\b[^>]*>`)
+	urlAttr       = regexp.MustCompile(`(?i)\b(src|href|poster|data)\s*=\s*("[^"]*"|'[^']*')`)
+	urlScheme     = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+// assetTypes — файлы, которые переносятся в хранилище. HTML, скрипты и SVG туда не попадают:
+// хранилище раздается с домена платформы, и активное содержимое пакета там исполнять нельзя.
+var assetTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".pdf":  "application/pdf",
+	".vtt":  "text/vtt",
+}
+
+// StoreFunc сохраняет файл пакета и возвращает его публичный URL; name — путь файла внутри пакета
+type StoreFunc func(name string, data []byte, contentType string) (string, error)
+
+// Package — курс, собранный из пакета SCORM или xAPI
+type Package struct {
+	Standard string
+	Course   *Course
+	// Assets — сколько медиафайлов сохранено, Skipped — файлы, на которые ссылались уроки, но которые не перенесены
+	Assets  int
+	Skipped []string
+}
+
+type scormManifest struct {
+	SchemaVersion string `xml:"metadata>schemaversion"`
+	Organizations struct {
+		Default string              `xml:"default,attr"`
+		List    []scormOrganization `xml:"organization"`
+	} `xml:"organizations"`
+	Resources struct {
+		Base string          `xml:"base,attr"`
+		List []scormResource `xml:"resource"`
+	} `xml:"resources"`
+}
+
+type scormOrganization struct {
+	Identifier string      `xml:"identifier,attr"`
+	Title      string      `xml:"title"`
+	Items      []scormItem `xml:"item"`
+}
+
+type scormItem struct {
+	IdentifierRef string      `xml:"identifierref,attr"`
+	IsVisible     string      `xml:"isvisible,attr"`
+	Title         string      `xml:"title"`
+	Items         []scormItem `xml:"item"`
+}
+
+type scormResource struct {
+	Identifier string `xml:"identifier,attr"`
+	Href       string `xml:"href,attr"`
+	Base       string `xml:"base,attr"`
+}
+
+type xapiManifest struct {
+	Activities []struct {
+		Type        string `xml:"type,attr"`
+		Name        string `xml:"name"`
+		Description string `xml:"description"`
+		Launch      string `xml:"launch"`
+	} `xml:"activities>activity"`
+}
+
+// ReadPackage разбирает ZIP-пакет SCORM 1.2, SCORM 2004 или xAPI в бандл курса. Разделы верхнего уровня
+// в структуре SCORM становятся модулями, страницы — уроками. Из HTML-страниц берется тело без скриптов
+// (среды выполнения SCORM на платформе нет), а изображения, аудио, видео и PDF сохраняются через store,
+// и ссылки на них в уроках заменяются на их новые адреса.
+func ReadPackage(data []byte, store StoreFunc) (*Package, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrNotPackage
+	}
+	if len(archive.File) > maxPackageFiles {
+		return nil, fmt.Errorf("%w: more than %d files", ErrInvalidPackage, maxPackageFiles)
+	}
+
+	p := &packageReader{files: make(map[string]*zip.File, len(archive.File)), stored: map[string]string{}, store: store}
+	for _, file := range archive.File {
+		p.files[path.Clean(file.Name)] = file
+	}
+
+	// Packages are often zipped together with their folder, so the manifest may sit one level down
+	manifestName := p.find(scormManifestFile)
+	if manifestName == "" {
+		manifestName = p.find(xapiManifestFile)
+	}
+	if manifestName == "" {
+		return nil, ErrNotPackage
+	}
+	p.root = path.Dir(manifestName)
+
+	manifestData, err := readFile(p.files[manifestName])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPackage, manifestName, err)
+	}
+
+	var pkg *Package
+	if path.Base(manifestName) == scormManifestFile {
+		pkg, err = p.readSCORM(manifestData)
+	} else {
+		pkg, err = p.readXAPI(manifestData)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pkg.Course.Lessons) == 0 {
+		return nil, fmt.Errorf("%w: the package has no launchable content", ErrInvalidPackage)
+	}
+
+	pkg.Assets = len(p.stored)
+	pkg.Skipped = p.skipped
+	return pkg, nil
+}
+
+type packageReader struct {
+	files   map[string]*zip.File
+	root    string
+	store   StoreFunc
+	stored  map[string]string // package path -> public URL
+	skipped []string
+}
+
+// find возвращает путь манифеста name в корне архива или в папке первого уровня
+func (p *packageReader) find(name string) string {
+	if _, ok := p.files[name]; ok {
+		return name
+	}
+	for file := range p.files {
+		if path.Base(file) == name && strings.Count(file, "/") == 1 {
+			return file
+		}
+	}
+	return ""
+}
+
+func (p *packageReader) readSCORM(data []byte) (*Package, error) {
+	var m scormManifest
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: imsmanifest.xml: %v", ErrInvalidPackage, err)
+	}
+	if len(m.Organizations.List) == 0 {
+		return nil, fmt.Errorf("%w: imsmanifest.xml has no organization", ErrInvalidPackage)
+	}
+
+	org := m.Organizations.List[0]
+	for _, candidate := range m.Organizations.List {
+		if candidate.Identifier == m.Organizations.Default {
+			org = candidate
+		}
+	}
+
+	resources := make(map[string]scormResource, len(m.Resources.List))
+	for _, resource := range m.Resources.List {
+		resource.Base = path.Join(m.Resources.Base, resource.Base)
+		resources[resource.Identifier] = resource
+	}
+
+	standard := StandardSCORM2004
+	if strings.TrimSpace(m.SchemaVersion) == "1.2" {
+		standard = StandardSCORM12
+	}
+
+	b := &Course{Version: CourseVersion, Title: strings.TrimSpace(org.Title), Modules: []Module{}, Lessons: []Lesson{}, GradeComponents: []GradeComponent{}}
+	var addItems func(items []scormItem, module *int) error
+	addItems = func(items []scormItem, module *int) error {
+		for _, item := range items {
+			if strings.EqualFold(item.IsVisible, "false") {
+				continue
+			}
+			if resource, ok := resources[item.IdentifierRef]; ok && resource.Href != "" {
+				content, err := p.lessonContent(path.Join(resource.Base, resource.Href), item.Title)
+				if err != nil {
+					return err
+				}
+				b.Lessons = append(b.Lessons, Lesson{
+					Module:        module,
+					Title:         strings.TrimSpace(item.Title),
+					Content:       content,
+					SequenceOrder: len(b.Lessons) + 1,
+				})
+			}
+			if len(item.Items) == 0 {
+				continue
+			}
+			// Top-level sections become modules, deeper nesting is flattened into them
+			if module == nil {
+				b.Modules = append(b.Modules, Module{Title: strings.TrimSpace(item.Title), SequenceOrder: len(b.Modules) + 1})
+				index := len(b.Modules) - 1
+				if err := addItems(item.Items, &index); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := addItems(item.Items, module); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := addItems(org.Items, nil); err != nil {
+		return nil, err
+	}
+	return &Package{Standard: standard, Course: b}, nil
+}
+
+func (p *packageReader) readXAPI(data []byte) (*Package, error) {
+	var m xapiManifest
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: tincan.xml: %v", ErrInvalidPackage, err)
+	}
+
+	b := &Course{Version: CourseVersion, Modules: []Module{}, Lessons: []Lesson{}, GradeComponents: []GradeComponent{}}
+	for _, activity := range m.Activities {
+		title := strings.TrimSpace(activity.Name)
+		if strings.HasSuffix(activity.Type, "/course") || b.Title == "" {
+			b.Title, b.ShortDesc = title, strings.TrimSpace(activity.Description)
+		}
+		if strings.TrimSpace(activity.Launch) == "" {
+			continue
+		}
+		content, err := p.lessonContent(strings.TrimSpace(activity.Launch), title)
+		if err != nil {
+			return nil, err
+		}
+		b.Lessons = append(b.Lessons, Lesson{
+			Title:         title,
+			Description:   strings.TrimSpace(activity.Description),
+			Content:       content,
+			SequenceOrder: len(b.Lessons) + 1,
+		})
+	}
+	return &Package{Standard: StandardXAPI, Course: b}, nil
+}
+
+// lessonContent превращает точку входа урока в содержимое: тело HTML-страницы или встроенный медиафайл
+func (p *packageReader) lessonContent(href, title string) (string, error) {
+	name, _ := splitURL(href)
+	name = path.Join(p.root, name)
+
+	ext := strings.ToLower(path.Ext(name))
+	if ext != ".html" && ext != ".htm" {
+		url, ok, err := p.asset(name)
+		if err != nil || !ok {
+			return "", err
+		}
+		return embed(url, ext, title), nil
+	}
+
+	page, err := readFile(p.files[name])
+	if err != nil {
+		p.skip(name)
+		return "", nil
+	}
+
+	html := string(page)
+	if match := bodyElement.FindStringSubmatch(html); match != nil {
+		html = match[1]
+	} else {
+		html = headElement.ReplaceAllString(html, "")
+	}
+	html = scriptElement.ReplaceAllString(html, "")
+
+	var storeErr error
+	html = urlAttr.ReplaceAllStringFunc(html, func(attr string) string {
+		match := urlAttr.FindStringSubmatch(attr)
+		value := match[2][1 : len(match[2])-1]
+		if value == "" || urlScheme.MatchString(value) || strings.HasPrefix(value, "/") || strings.HasPrefix(value, "#") {
+			return attr
+		}
+
+		target, fragment := splitURL(value)
+		url, ok, err := p.asset(path.Join(path.Dir(name), target))
+		if err != nil {
+			storeErr = err
+		}
+		if !ok {
+			return attr
+		}
+		return fmt.Sprintf(`%s="%s%s"`, match[1], url, fragment)
+	})
+	if storeErr != nil {
+		return "", storeErr
+	}
+	return markup.Sanitize(strings.TrimSpace(html)), nil
+}
+
+// asset сохраняет медиафайл пакета один раз и возвращает его URL; ok = false, если файл не переносится
+func (p *packageReader) asset(name string) (string, bool, error) {
+	if url, ok := p.stored[name]; ok {
+		return url, true, nil
+	}
+
+	contentType, ok := assetTypes[strings.ToLower(path.Ext(name))]
+	if !ok || strings.HasPrefix(name, "../") {
+		p.skip(name)
+		return "", false, nil
+	}
+	file := p.files[name]
+	if file == nil {
+		p.skip(name)
+		return "", false, nil
+	}
+	data, err := readFileLimit(file, maxAssetSize)
+	if err != nil {
+		p.skip(name)
+		return "", false, nil
+	}
+
+	relative := name
+	if p.root != "." {
+		relative = strings.TrimPrefix(name, p.root+"/")
+	}
+	url, err := p.store(relative, data, contentType)
+	if err != nil {
+		return "", false, err
+	}
+	p.stored[name] = url
+	return url, true, nil
+}
+
+func (p *packageReader) skip(name string) {
+	for _, skipped := range p.skipped {
+		if skipped == name {
+			return
+		}
+	}
+	p.skipped = append(p.skipped, name)
+}
+
+// splitURL отделяет путь файла от параметров запроса и якоря (якорь возвращается с #)
+func splitURL(value string) (string, string) {
+	fragment := ""
+	if i := strings.Index(value, "#"); i >= 0 {
+		value, fragment = value[:i], value[i:]
+	}
+	if i := strings.Index(value, "?"); i >= 0 {
+		value = value[:i]
+	}
+	return value, fragment
+}
+
+// embed — урок из одного медиафайла
+func embed(url, ext, title string) string {
+	contentType := assetTypes[ext]
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return fmt.Sprintf(`<p><img src="%s" alt="%s"></p>`, url, xmlEscape(title))
+	case strings.HasPrefix(contentType, "video/"):
+		return fmt.Sprintf(`<video controls src="%s"></video>`, url)
+	case strings.HasPrefix(contentType, "audio/"):
+		return fmt.Sprintf(`<audio controls src="%s"></audio>`, url)
+	default:
+		return fmt.Sprintf(`<p><a href="%s">%s</a></p>`, url, xmlEscape(title))
+	}
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
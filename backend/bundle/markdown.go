@@ -0,0 +1,226 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"project/backend/blocks"
+	"project/backend/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Файлы ZIP-архива курса: манифест со структурой курса, по Markdown-файлу на урок и файлы уроков
+const (
+	manifestFile   = "course.json"
+	readmeFile     = "README.md"
+	lessonsDir     = "lessons/"
+	attachmentsDir = "attachments/"
+)
+
+// maxLessonFileSize и maxAttachmentFileSize ограничивают распакованные файлы, чтобы архив-бомба не заняла всю память
+const (
+	maxLessonFileSize     = 8 << 20
+	maxAttachmentFileSize = 64 << 20
+)
+
+var (
+	ErrInvalidArchive = errors.New("the archive is not a course bundle: course.json is missing or invalid")
+
+	slugUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+// manifest — course.json в архиве: бандл без содержимого уроков, вместо него имя файла урока
+type manifest struct {
+	Course
+	LessonFiles []string `json:"lesson_files"`
+}
+
+// WriteMarkdown пишет курс в w как ZIP-архив: course.json со структурой курса, уроки в lessons/*.md
+// и их файлы в attachments/.
+// Содержимое уроков сохраняется без изменений (HTML внутри Markdown допустим), поэтому импорт архива
+// восстанавливает курс так же точно, как JSON-бандл.
+func WriteMarkdown(w io.Writer, b *Course) error {
+	archive := zip.NewWriter(w)
+	now := time.Now()
+
+	m := manifest{Course: *b, LessonFiles: make([]string, len(b.Lessons))}
+	m.Lessons = make([]Lesson, len(b.Lessons))
+	var attachmentFiles []archiveFile
+	for i, lesson := range b.Lessons {
+		m.LessonFiles[i] = fmt.Sprintf("%s%03d-%s.md", lessonsDir, i+1, slug(lesson.Title))
+		lesson.Content = ""
+		lesson.Attachments = make([]Attachment, len(b.Lessons[i].Attachments))
+		for j, attachment := range b.Lessons[i].Attachments {
+			if len(attachment.Data) > 0 {
+				attachment.File = fmt.Sprintf("%s%03d-%02d-%s", attachmentsDir, i+1, j+1, path.Base(attachment.FileName))
+				attachmentFiles = append(attachmentFiles, archiveFile{attachment.File, attachment.Data})
+				attachment.Data = nil
+			}
+			lesson.Attachments[j] = attachment
+		}
+		m.Lessons[i] = lesson
+	}
+
+	manifestData, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := []archiveFile{{manifestFile, manifestData}, {readmeFile, []byte(readme(b, m.LessonFiles))}}
+	for i, lesson := range b.Lessons {
+		files = append(files, archiveFile{m.LessonFiles[i], []byte(lessonMarkdown(lesson))})
+	}
+	files = append(files, attachmentFiles...)
+
+	for _, file := range files {
+		out, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ReadMarkdown разбирает архив, записанный WriteMarkdown. Текст урока берется из его .md файла без заголовка,
+// поэтому автор может поправить уроки в редакторе перед импортом.
+func ReadMarkdown(data []byte) (*Course, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[path.Clean(file.Name)] = file
+	}
+
+	manifestData, err := readFile(files[manifestFile])
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+	var m manifest
+	if err := json.Unmarshal(manifestData, &m); err != nil {
+		return nil, ErrInvalidArchive
+	}
+	if len(m.LessonFiles) != len(m.Lessons) {
+		return nil, fmt.Errorf("%w: %d lessons but %d lesson files", ErrInvalidArchive, len(m.Lessons), len(m.LessonFiles))
+	}
+
+	b := m.Course
+	for i, name := range m.LessonFiles {
+		content, err := readFile(files[path.Clean(name)])
+		if err != nil {
+			return nil, fmt.Errorf("%w: lesson file %s: %v", ErrInvalidArchive, name, err)
+		}
+		b.Lessons[i].Content = lessonBody(string(content))
+		// An edited lesson file wins over the blocks it was rendered from
+		if len(b.Lessons[i].Blocks) > 0 && b.Lessons[i].Content != renderBlocks(b.Lessons[i].Blocks) {
+			b.Lessons[i].Blocks = nil
+		}
+		for j, attachment := range b.Lessons[i].Attachments {
+			if attachment.File == "" {
+				continue
+			}
+			data, err := readFileLimit(files[path.Clean(attachment.File)], maxAttachmentFileSize)
+			if err != nil {
+				return nil, fmt.Errorf("%w: attachment file %s: %v", ErrInvalidArchive, attachment.File, err)
+			}
+			b.Lessons[i].Attachments[j].Data = data
+		}
+	}
+	return &b, nil
+}
+
+func readFile(file *zip.File) ([]byte, error) {
+	return readFileLimit(file, maxLessonFileSize)
+}
+
+func readFileLimit(file *zip.File, limit int64) ([]byte, error) {
+	if file == nil {
+		return nil, errors.New("file not found")
+	}
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errors.New("file is too large")
+	}
+	return data, nil
+}
+
+// lessonMarkdown — урок с заголовком; описание идет цитатой, граница с содержимым отмечена комментарием
+func lessonMarkdown(lesson Lesson) string {
+	var sb strings.Builder
+	sb.WriteString("# " + lesson.Title + "\n\n")
+	if lesson.Description != "" {
+		sb.WriteString("> " + strings.ReplaceAll(lesson.Description, "\n", "\n> ") + "\n\n")
+	}
+	sb.WriteString(contentMarker + "\n")
+	sb.WriteString(lesson.Content)
+	return sb.String()
+}
+
+// contentMarker отделяет заголовок урока от содержимого; все после него импортируется как есть
+const contentMarker = "<!-- lesson content -->"
+
+func lessonBody(markdown string) string {
+	if i := strings.Index(markdown, contentMarker+"\n"); i >= 0 {
+		return markdown[i+len(contentMarker)+1:]
+	}
+	return markdown
+}
+
+func readme(b *Course, lessonFiles []string) string {
+	var sb strings.Builder
+	sb.WriteString("# " + b.Title + "\n\n")
+	if b.ShortDesc != "" {
+		sb.WriteString(b.ShortDesc + "\n\n")
+	}
+	if b.Description != "" {
+		sb.WriteString(b.Description + "\n\n")
+	}
+	sb.WriteString("## Lessons\n\n")
+	for i, lesson := range b.Lessons {
+		fmt.Fprintf(&sb, "%d. [%s](%s)\n", i+1, lesson.Title, lessonFiles[i])
+	}
+	return sb.String()
+}
+
+func slug(title string) string {
+	s := strings.Trim(slugUnsafe.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(s) > 40 {
+		s = strings.TrimRight(s[:40], "-")
+	}
+	if s == "" {
+		s = "lesson"
+	}
+	return s
+}
+
+func renderBlocks(list []Block) string {
+	rendered := make([]models.LessonBlock, len(list))
+	for i, block := range list {
+		rendered[i] = models.LessonBlock{Type: block.Type, Data: string(block.Data)}
+	}
+	return blocks.Render(rendered)
+}
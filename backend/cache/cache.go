@@ -0,0 +1,118 @@
+// Package cache хранит готовые ответы в памяти процесса. Invalidate сбрасывает записи только
+// на своем экземпляре: остальные экземпляры API отдают старый ответ, пока не истечет его TTL,
+// поэтому TTL и есть граница согласованности кеша (для аналитики — ANALYTICS_CACHE_TTL_SECONDS).
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value     interface{}
+	expiresAt time.Time
+	tags      []string
+}
+
+// Cache — потокобезопасный in-memory кеш с TTL и инвалидацией по тегам
+type Cache struct {
+	mu    sync.RWMutex
+	items map[string]entry
+	tags  map[string]map[string]struct{}
+}
+
+func New() *Cache {
+	return &Cache{
+		items: make(map[string]entry),
+		tags:  make(map[string]map[string]struct{}),
+	}
+}
+
+// Analytics хранит ответы аналитических эндпоинтов; после изменения данных другие экземпляры
+// могут отдавать старую аналитику до ANALYTICS_CACHE_TTL_SECONDS
+var Analytics = New()
+
+// Status хранит ответ публичной страницы статуса, чтобы проверки не выполнялись на каждый запрос
+var Status = New()
+
+// Key строит ключ вида entity:role:filter1:filter2...
+func Key(entity, role string, filters ...interface{}) string {
+	parts := []string{entity, role}
+	for _, f := range filters {
+		parts = append(parts, fmt.Sprint(f))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Tag строит тег сущности для инвалидации, например course:42
+func Tag(entity string, id interface{}) string {
+	return fmt.Sprintf("%s:%v", entity, id)
+}
+
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	e, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(e.expiresAt) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set сохраняет значение; теги позволяют сбросить его вместе со связанными данными
+func (c *Cache) Set(key string, value interface{}, ttl time.Duration, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLocked(key)
+	c.items[key] = entry{value: value, expiresAt: time.Now().Add(ttl), tags: tags}
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+}
+
+// Invalidate удаляет все записи, помеченные любым из тегов (только в этом процессе)
+func (c *Cache) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.removeLocked(key)
+		}
+		delete(c.tags, tag)
+	}
+}
+
+// Purge удаляет просроченные записи
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, e := range c.items {
+		if now.After(e.expiresAt) {
+			c.removeLocked(key)
+		}
+	}
+}
+
+func (c *Cache) removeLocked(key string) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	for _, tag := range e.tags {
+		delete(c.tags[tag], key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	delete(c.items, key)
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticsCacheInvalidation(t *testing.T) {
+	c := New()
+
+	adminKey := Key("course", "admin", 1)
+	professorKey := Key("course", "professor", 1)
+	c.Set(adminKey, "admin view", time.Minute, Tag("course", 1))
+	c.Set(professorKey, "professor view", time.Minute, Tag("course", 1))
+	c.Set(Key("course", "admin", 2), "other course", time.Minute, Tag("course", 2))
+
+	value, ok := c.Get(professorKey)
+	assert.True(t, ok)
+	assert.Equal(t, "professor view", value)
+
+	c.Invalidate(Tag("course", 1))
+
+	_, ok = c.Get(adminKey)
+	assert.False(t, ok)
+	_, ok = c.Get(professorKey)
+	assert.False(t, ok)
+	_, ok = c.Get(Key("course", "admin", 2))
+	assert.True(t, ok)
+}
@@ -0,0 +1,161 @@
+// Package circles ведет испытания учебных кружков: считает прогресс участников по событиям прохождения
+// уроков и выдает значки за достигнутую цель. Значки выдаются только при записи — по событию (Track),
+// при создании испытания и планировщиком (AwardDue); запросы на чтение их лишь показывают.
+package circles
+
+import (
+	"errors"
+	"project/backend/models"
+	"project/backend/outbox"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AwardGrace — сколько после конца испытания планировщик еще проверяет его цель: события, записанные
+// в последние минуты срока, успевают дать значок
+const AwardGrace = 24 * time.Hour
+
+// Progress считает, сколько уроков для цели испытания участники прошли в его сроки.
+// userIDs = nil — все участники кружка. Прогресс не превышает цели, которая тоже возвращается.
+func Progress(db *gorm.DB, circle *models.StudyCircle, challenge *models.CircleChallenge, userIDs []uint) (map[uint]int, int, error) {
+	done, target, err := completions(db, circle, challenge, userIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	progress := make(map[uint]int, len(done))
+	for userID, times := range done {
+		progress[userID] = min(len(times), target)
+	}
+	return progress, target, nil
+}
+
+// AwardBadges выдает значки участникам, достигшим цели испытания, и пишет им об этом; возвращает новых
+// обладателей. Время значка — момент прохождения урока, которым цель была достигнута.
+func AwardBadges(tx *gorm.DB, circle *models.StudyCircle, challenge *models.CircleChallenge, userIDs []uint) ([]uint, error) {
+	done, target, err := completions(tx, circle, challenge, userIDs)
+	if err != nil || target == 0 {
+		return nil, err
+	}
+
+	var awarded []uint
+	for userID, times := range done {
+		if len(times) < target {
+			continue
+		}
+		badge := models.CircleBadge{ChallengeID: challenge.ID, UserID: userID, EarnedAt: times[target-1]}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&badge)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		awarded = append(awarded, userID)
+		if err := outbox.EnqueueNotification(tx, userID, outbox.NotifyBadges,
+			"Challenge completed: "+challenge.Title, "You earned a badge in "+circle.Name+"."); err != nil {
+			return nil, err
+		}
+	}
+	return awarded, nil
+}
+
+// Track засчитывает пройденный урок в идущие испытания кружка студента по этому курсу
+// и выдает значок, если цель достигнута. Вызывается в транзакции, которая записывает событие.
+func Track(tx *gorm.DB, activity models.UserActivity) error {
+	var circle models.StudyCircle
+	err := tx.Joins("JOIN study_circle_members ON study_circle_members.circle_id = study_circles.id").
+		Where("study_circle_members.user_id = ? AND study_circle_members.course_id = ?", activity.UserID, activity.TargetID).
+		First(&circle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var challenges []models.CircleChallenge
+	err = tx.Where("circle_id = ? AND starts_at <= ? AND ends_at > ?", circle.ID, activity.CreatedAt, activity.CreatedAt).
+		Where("NOT EXISTS (SELECT 1 FROM circle_badges WHERE circle_badges.challenge_id = circle_challenges.id AND circle_badges.user_id = ?)", activity.UserID).
+		Find(&challenges).Error
+	if err != nil {
+		return err
+	}
+	for i := range challenges {
+		if _, err := AwardBadges(tx, &circle, &challenges[i], []uint{activity.UserID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AwardDue проверяет цели идущих и недавно закончившихся испытаний. Ловит то, чего не дали события:
+// например, из модуля убрали урок, и участнику больше нечего проходить.
+func AwardDue(db *gorm.DB, now time.Time) error {
+	var challenges []models.CircleChallenge
+	if err := db.Where("starts_at <= ? AND ends_at > ?", now, now.Add(-AwardGrace)).Find(&challenges).Error; err != nil {
+		return err
+	}
+	for i := range challenges {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var circle models.StudyCircle
+			// A dissolved circle has nobody left to award
+			if err := tx.First(&circle, challenges[i].CircleID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+			_, err := AwardBadges(tx, &circle, &challenges[i], nil)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completions возвращает для каждого участника моменты первого прохождения уроков цели в сроки испытания,
+// по порядку, и саму цель. Цель модуля — его текущие уроки: модуль мог измениться после создания испытания.
+func completions(db *gorm.DB, circle *models.StudyCircle, challenge *models.CircleChallenge, userIDs []uint) (map[uint][]time.Time, int, error) {
+	if userIDs == nil {
+		if err := db.Model(&models.StudyCircleMember{}).Where("circle_id = ?", circle.ID).Pluck("user_id", &userIDs).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	target := challenge.Target
+	query := db.Model(&models.UserActivity{}).Select("user_id, subject_id, MIN(created_at) AS at").
+		Where("action_type = ? AND target_type = ? AND target_id = ? AND user_id IN ? AND created_at >= ? AND created_at < ?",
+			models.ActivityLessonComplete, "courses", circle.CourseID, userIDs, challenge.StartsAt, challenge.EndsAt)
+	if challenge.Goal == models.ChallengeModule && challenge.ModuleID != nil {
+		moduleLessons := db.Model(&models.Lesson{}).Select("id").Where("module_id = ?", *challenge.ModuleID)
+		var lessons int64
+		if err := db.Model(&models.Lesson{}).Where("module_id = ?", *challenge.ModuleID).Count(&lessons).Error; err != nil {
+			return nil, 0, err
+		}
+		target = int(lessons)
+		query = query.Where("subject_id IN (?)", moduleLessons)
+	}
+
+	var rows []struct {
+		UserID    uint
+		SubjectID uint
+		At        time.Time
+	}
+	if len(userIDs) > 0 {
+		if err := query.Group("user_id, subject_id").Order("user_id, at").Scan(&rows).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	done := make(map[uint][]time.Time, len(userIDs))
+	for _, id := range userIDs {
+		done[id] = nil
+	}
+	for _, row := range rows {
+		done[row.UserID] = append(done[row.UserID], row.At)
+	}
+	return done, target, nil
+}
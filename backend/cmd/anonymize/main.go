@@ -0,0 +1,57 @@
+// Команда anonymize готовит копию боевой базы для staging: заменяет email, имена пользователей, IP и строки браузера.
+//
+//	go run ./backend/cmd/anonymize -confirm <DB_NAME> [-password staging-pass]
+//
+// Подключение берется из тех же переменных окружения, что и у API. Секрет HMAC передается
+// через ANONYMIZE_SECRET, чтобы не попадать в историю shell. Архивы неактивных пользователей
+// в холодном хранилище не копируются и не затрагиваются: staging должен смотреть в свой ARCHIVE_DIR.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"project/backend/anonymize"
+	"project/backend/config"
+	"project/backend/utils"
+	"sort"
+)
+
+func main() {
+	confirm := flag.String("confirm", "", "name of the database to anonymize, must match DB_NAME")
+	password := flag.String("password", "", "set this password for every user (optional)")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	// The data is changed for good, so the target has to be named explicitly
+	if *confirm == "" || *confirm != cfg.DBName {
+		log.Fatalf("Refusing to run: pass -confirm %s to anonymize this database", cfg.DBName)
+	}
+
+	db, err := utils.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+
+	report, err := anonymize.Run(context.Background(), db, anonymize.Options{
+		Secret:   os.Getenv("ANONYMIZE_SECRET"),
+		Password: *password,
+	})
+	if err != nil {
+		log.Fatalf("Anonymization failed, nothing was changed: %v", err)
+	}
+
+	columns := make([]string, 0, len(report))
+	for column := range report {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		fmt.Printf("%-40s %d\n", column, report[column])
+	}
+}
@@ -0,0 +1,40 @@
+// Команда sandbox помечает базу как базу песочницы: без отметки sandbox.Reset ее не сбросит,
+// даже если экземпляр запущен с SANDBOX_MODE=true. Базам с именем *_sandbox отметка не нужна.
+//
+//	go run ./backend/cmd/sandbox -confirm <DB_NAME>
+//
+// Подключение берется из тех же переменных окружения, что и у API.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"project/backend/config"
+	"project/backend/sandbox"
+	"project/backend/utils"
+)
+
+func main() {
+	confirm := flag.String("confirm", "", "name of the database to mark as the sandbox, must match DB_NAME")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	// A marked database is wiped every night, so the target has to be named explicitly
+	if *confirm == "" || *confirm != cfg.DBName {
+		log.Fatalf("Refusing to run: pass -confirm %s to mark this database as the sandbox", cfg.DBName)
+	}
+
+	db, err := utils.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+
+	if err := sandbox.Mark(context.Background(), db); err != nil {
+		log.Fatalf("Marking the sandbox failed: %v", err)
+	}
+	log.Printf("Database %s is marked as the sandbox", cfg.DBName)
+}
@@ -0,0 +1,103 @@
+// Команда sanitize очищает HTML уроков, сохраненный до того, как содержимое стало проходить через
+// markup.Sanitize: текст уроков и текстовые блоки переписываются очищенными. Повторный запуск ничего не меняет.
+//
+//	go run ./backend/cmd/sanitize [-dry-run]
+//
+// Подключение берется из тех же переменных окружения, что и у API.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"project/backend/blocks"
+	"project/backend/config"
+	"project/backend/markup"
+	"project/backend/models"
+	"project/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only count what would change")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	db, err := utils.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+
+	var lessons, textBlocks int
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var batch []models.Lesson
+		return tx.Select("id", "content").FindInBatches(&batch, 500, func(batchTx *gorm.DB, _ int) error {
+			for _, lesson := range batch {
+				if clean := markup.Sanitize(lesson.Content); clean != lesson.Content {
+					lessons++
+					if !*dryRun {
+						if err := tx.Model(&lesson).Update("content", clean).Error; err != nil {
+							return err
+						}
+					}
+				}
+
+				changed, err := sanitizeBlocks(tx, lesson.ID, *dryRun)
+				if err != nil {
+					return err
+				}
+				textBlocks += changed
+			}
+			return nil
+		}).Error
+	})
+	if err != nil {
+		log.Fatalf("Sanitizing failed, nothing was changed: %v", err)
+	}
+
+	fmt.Printf("%-20s %d\n", "lessons", lessons)
+	fmt.Printf("%-20s %d\n", "text blocks", textBlocks)
+	if *dryRun {
+		fmt.Println("dry run, nothing was changed")
+	}
+}
+
+// sanitizeBlocks очищает текстовые блоки урока и пересобирает по ним его содержимое
+func sanitizeBlocks(tx *gorm.DB, lessonID uint, dryRun bool) (int, error) {
+	var list []models.LessonBlock
+	if err := tx.Where("lesson_id = ? AND type = ?", lessonID, models.BlockText).Find(&list).Error; err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, block := range list {
+		var text blocks.Text
+		if err := json.Unmarshal([]byte(block.Data), &text); err != nil {
+			continue
+		}
+		clean := markup.Sanitize(text.HTML)
+		if clean == text.HTML {
+			continue
+		}
+		changed++
+		if dryRun {
+			continue
+		}
+		text.HTML = clean
+		data, _ := json.Marshal(text)
+		if err := tx.Model(&block).Update("data", string(data)).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	if changed > 0 && !dryRun {
+		lesson := models.Lesson{Model: gorm.Model{ID: lessonID}}
+		return changed, blocks.Sync(tx, &lesson)
+	}
+	return changed, nil
+}
@@ -0,0 +1,125 @@
+// Package completion считает прохождение курса по его правилам (models.CompletionPolicy).
+// CompletionRate в прогрессе студента — доля выполненных требований: каждый требуемый урок и итоговый
+// тест весят одинаково, 100 означает, что курс пройден. Аналитика, сертификаты и лента опираются на это.
+package completion
+
+import (
+	"errors"
+	"math"
+	"project/backend/grading"
+	"project/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// RequiredLessons — сколько уроков из total нужно пройти по правилам курса
+func RequiredLessons(policy models.CompletionPolicy, total int) int {
+	percent := min(max(policy.LessonPercent, 0), 100)
+	return int(math.Ceil(float64(total) * float64(percent) / 100))
+}
+
+// Rate считает CompletionRate студента в курсе, где всего lessons уроков
+func Rate(db *gorm.DB, course models.Course, lessons int, progress models.UserCourseProgress) (float64, error) {
+	required := RequiredLessons(course.CompletionPolicy, lessons)
+	units, done := required, min(progress.LessonsCompleted, required)
+
+	if testID := course.CompletionPolicy.FinalTestID; testID != nil {
+		passed, exists, err := passedFinalTest(db, *testID, progress.UserID, course.CompletionPolicy.MinScore)
+		if err != nil {
+			return 0, err
+		}
+		// A deleted final test no longer blocks completion
+		if exists {
+			units++
+			if passed {
+				done++
+			}
+		}
+	}
+
+	if units == 0 {
+		return 0, nil
+	}
+	return float64(done) / float64(units) * 100, nil
+}
+
+// Refresh пересчитывает и сохраняет CompletionRate студента в курсе. Возвращает true, если курс
+// стал пройденным в результате пересчета.
+func Refresh(tx *gorm.DB, course models.Course, userID uint) (bool, error) {
+	var progress models.UserCourseProgress
+	if err := tx.Where("user_id = ? AND course_id = ?", userID, course.ID).First(&progress).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var lessons int64
+	if err := tx.Model(&models.Lesson{}).Where("course_id = ?", course.ID).Count(&lessons).Error; err != nil {
+		return false, err
+	}
+	rate, err := Rate(tx, course, int(lessons), progress)
+	if err != nil {
+		return false, err
+	}
+	if rate == progress.CompletionRate {
+		return false, nil
+	}
+
+	if err := tx.Model(&progress).Update("completion_rate", rate).Error; err != nil {
+		return false, err
+	}
+	if err := grading.RefreshUser(tx, course.ID, userID); err != nil {
+		return false, err
+	}
+	return rate >= 100 && progress.CompletionRate < 100, nil
+}
+
+// RefreshCourse пересчитывает прогресс всех студентов курса после смены правил
+func RefreshCourse(tx *gorm.DB, course models.Course) error {
+	var userIDs []uint
+	if err := tx.Model(&models.UserCourseProgress{}).
+		Where("course_id = ?", course.ID).Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if _, err := Refresh(tx, course, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshTest пересчитывает прохождение курсов, где тест итоговый, после попытки студента.
+// Возвращает курсы, которые студент этой попыткой прошел.
+func RefreshTest(tx *gorm.DB, testID, userID uint) ([]models.Course, error) {
+	var courses []models.Course
+	if err := tx.Where("completion_final_test_id = ?", testID).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+
+	var completed []models.Course
+	for _, course := range courses {
+		done, err := Refresh(tx, course, userID)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			completed = append(completed, course)
+		}
+	}
+	return completed, nil
+}
+
+func passedFinalTest(db *gorm.DB, testID, userID uint, minScore float64) (passed, exists bool, err error) {
+	var count int64
+	if err := db.Model(&models.Test{}).Where("id = ?", testID).Count(&count).Error; err != nil || count == 0 {
+		return false, false, err
+	}
+
+	var progress models.UserTestProgress
+	if err := db.Where("user_id = ? AND test_id = ?", userID, testID).Limit(1).Find(&progress).Error; err != nil {
+		return false, true, err
+	}
+	return progress.AttemptsUsed > 0 && progress.Score >= minScore, true, nil
+}
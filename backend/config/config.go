@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +17,142 @@ type Config struct {
 	DBName     string
 	JWTSecret  string
 	ServerPort string
+
+	// JWT signing: "HS256" uses JWTSecret, "RS256" uses the PEM key files
+	JWTAlgorithm      string
+	JWTExpiryHours    int
+	JWTPrivateKeyFile string
+	JWTPublicKeyFile  string
+	// Rotation: tokens are signed with the current key, labelled JWTKeyID in the kid header. JWTPreviousKeys
+	// lists retired keys still accepted until their tokens expire, as "kid:secret" (HS256) or "kid:/path/public.pem" (RS256);
+	// an entry without "kid:" is the key tokens issued before kid headers were signed with.
+	JWTKeyID        string
+	JWTPreviousKeys string
+	// Lifetime of access tokens issued to registered client apps (shorter than first-party sessions)
+	OAuthTokenTTLMinutes int
+
+	// Outbox / external deliveries
+	OutboxPollSeconds int
+	OutboxMaxAttempts int
+	SMTPHost          string
+	SMTPPort          string
+	SMTPUser          string
+	SMTPPassword      string
+	SMTPFrom          string
+	AppURL            string // web app address for links in emails, empty = links are paths; campaigns require it
+	WebhookURL        string
+	WebhookSecret     string
+	XAPIEndpoint      string
+	XAPIUsername      string
+	XAPIPassword      string
+	XAPIHomePage      string
+
+	// CAPTCHA on register/login: "recaptcha", "hcaptcha" or empty to disable
+	CaptchaProvider  string
+	CaptchaSecret    string
+	CaptchaVerifyURL string // overrides the provider's siteverify endpoint, e.g. for a proxy
+
+	// Language model for authoring helpers: "openai" (any OpenAI-compatible chat completions API) or empty to disable
+	LLMProvider       string
+	LLMBaseURL        string
+	LLMAPIKey         string
+	LLMModel          string
+	LLMTimeoutSeconds int
+	// Model for text embeddings (duplicate content report), empty to disable
+	LLMEmbeddingModel string
+
+	// Payments for paid courses: "stripe", "yookassa" or empty to disable
+	PaymentProvider      string
+	PaymentSecretKey     string // Stripe secret key or YooKassa secret key
+	PaymentShopID        string // YooKassa shop ID
+	PaymentWebhookSecret string // Stripe endpoint signing secret
+	PaymentReturnURL     string // where the student lands after checkout, {order} is replaced with the order ID
+	PaymentCurrency      string // currency of course prices set without one
+
+	// Timeouts
+	RequestTimeoutSeconds int
+	QueryTimeoutSeconds   int
+
+	// Cache invalidation is per instance, so this is also how long other instances may serve stale analytics
+	AnalyticsCacheTTLSeconds int
+	// Days a self-deleted account is kept (soft-deleted) before it is purged for good
+	AccountDeletionGraceDays int
+	// Days deleted courses and lessons stay restorable in the trash; then lessons and their files are purged (0 = never)
+	TrashRetentionDays int
+
+	// Monthly partitions of high-volume tables: how many are created ahead and how long they are kept (0 = forever)
+	PartitionMonthsAhead        int
+	LoginHistoryRetentionMonths int
+
+	// Dev-mode guardrail: EXPLAIN every SELECT and log sequential scans estimated above this many rows
+	QueryPlanGuard       bool
+	QueryPlanSeqScanRows int
+
+	// File storage for uploads: "disk" (served from StoragePublicURL) or "s3"
+	StorageDriver    string
+	StorageDir       string
+	StoragePublicURL string
+	S3Bucket         string
+	S3Region         string
+	S3Endpoint       string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3PublicURL      string
+	AvatarMaxBytes   int
+	CoverMaxBytes    int
+	// SCORM/xAPI packages imported as courses; the body limit of the import route is raised to fit them
+	PackageMaxBytes int
+	// Files attached to lessons (PDFs, slides, datasets); the body limit of the upload route is raised to fit them too
+	AttachmentMaxBytes int
+	// Comma-separated hosts whose players lessons may embed in frames (subdomains included); empty keeps the built-in list
+	EmbedHosts string
+
+	// Soft limits of an instructor's content (0 = unlimited): nothing is blocked, the author gets a warning
+	// when usage crosses each of QuotaWarnPercents (comma-separated percents of the limit)
+	StorageQuotaBytes     int
+	MaxCoursesPerAuthor   int
+	MaxQuestionsPerAuthor int
+	QuotaWarnPercents     string
+
+	// Cold storage for data of long-inactive users (never served publicly)
+	ArchiveDriver       string
+	ArchiveDir          string
+	ArchiveS3Bucket     string
+	ArchiveInactiveDays int
+	// Signed download links of organization exports: HMAC key (falls back to JWTSecret) and lifetime
+	ExportLinkSecret   string
+	ExportLinkTTLHours int
+	// How long hourly API usage buckets and daily deprecated route calls are kept
+	UsageRetentionDays int
+
+	// Reverse proxies in front of the app (comma-separated IPs or CIDRs). Only requests from them have their
+	// client IP taken from ProxyHeader; with none configured the IP is the connection's remote address.
+	TrustedProxies string
+	ProxyHeader    string
+
+	// Header with the client's country set by the proxy or CDN (e.g. Cloudflare's CF-IPCountry), empty to disable
+	GeoCountryHeader string
+
+	// Startup check of the migration version: "compat" (DB may be ahead), "strict" or "off".
+	// On mismatch the instance either refuses to start ("fail") or serves reads only ("readonly").
+	SchemaCheck          string
+	SchemaMismatchAction string
+
+	// Serve reads only, rejecting every change with 503 (admins can also toggle this at runtime)
+	ReadOnly        bool
+	ReadOnlyMessage string
+
+	// Developer sandbox: the instance runs against its own database with demo data for integrators,
+	// issues sandbox API keys only and wipes everything but accounts and keys every night at SandboxResetHour (UTC)
+	Sandbox             bool
+	SandboxResetHour    int
+	SandboxDemoPassword string
+
+	// Rate limiting (requests per window)
+	RateLimitWindowSeconds int
+	RateLimitMax           int
+	AuthRateLimitMax       int
+	WriteRateLimitMax      int
 }
 
 func LoadConfig() (*Config, error) {
@@ -23,7 +161,7 @@ func LoadConfig() (*Config, error) {
 		log.Println("Error loading .env file, using environment variables")
 	}
 
-	return &Config{
+	cfg := &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "postgres"),
@@ -31,7 +169,115 @@ func LoadConfig() (*Config, error) {
 		DBName:     getEnv("DB_NAME", "learning_platform"),
 		JWTSecret:  getEnv("JWT_SECRET", "secret"),
 		ServerPort: getEnv("SERVER_PORT", "6000"),
-	}, nil
+
+		JWTAlgorithm:      getEnv("JWT_ALGORITHM", "HS256"),
+		JWTExpiryHours:    getEnvInt("JWT_EXPIRY_HOURS", 72),
+		JWTPrivateKeyFile: getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTPublicKeyFile:  getEnv("JWT_PUBLIC_KEY_FILE", ""),
+		JWTKeyID:          getEnv("JWT_KEY_ID", ""),
+		JWTPreviousKeys:   getEnv("JWT_PREVIOUS_KEYS", ""),
+
+		OAuthTokenTTLMinutes: getEnvInt("OAUTH_TOKEN_TTL_MINUTES", 60),
+
+		// The relay ticker panics on a non-positive interval
+		OutboxPollSeconds: max(getEnvInt("OUTBOX_POLL_SECONDS", 5), 1),
+		OutboxMaxAttempts: getEnvInt("OUTBOX_MAX_ATTEMPTS", 8),
+		SMTPHost:          getEnv("SMTP_HOST", ""),
+		SMTPPort:          getEnv("SMTP_PORT", "587"),
+		SMTPUser:          getEnv("SMTP_USER", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:          getEnv("SMTP_FROM", "no-reply@philosofium.local"),
+		AppURL:            getEnv("APP_URL", ""),
+		WebhookURL:        getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:     getEnv("WEBHOOK_SECRET", ""),
+		XAPIEndpoint:      getEnv("XAPI_ENDPOINT", ""),
+		XAPIUsername:      getEnv("XAPI_USERNAME", ""),
+		XAPIPassword:      getEnv("XAPI_PASSWORD", ""),
+		XAPIHomePage:      getEnv("XAPI_HOME_PAGE", "https://philosofium.local"),
+
+		CaptchaProvider:  getEnv("CAPTCHA_PROVIDER", ""),
+		CaptchaSecret:    getEnv("CAPTCHA_SECRET", ""),
+		CaptchaVerifyURL: getEnv("CAPTCHA_VERIFY_URL", ""),
+
+		LLMProvider:       getEnv("LLM_PROVIDER", ""),
+		LLMBaseURL:        getEnv("LLM_BASE_URL", "https://api.openai.com/v1"),
+		LLMAPIKey:         getEnv("LLM_API_KEY", ""),
+		LLMModel:          getEnv("LLM_MODEL", "gpt-4o-mini"),
+		LLMTimeoutSeconds: getEnvInt("LLM_TIMEOUT_SECONDS", 30),
+		LLMEmbeddingModel: getEnv("LLM_EMBEDDING_MODEL", "text-embedding-3-small"),
+
+		PaymentProvider:      getEnv("PAYMENT_PROVIDER", ""),
+		PaymentSecretKey:     getEnv("PAYMENT_SECRET_KEY", ""),
+		PaymentShopID:        getEnv("PAYMENT_SHOP_ID", ""),
+		PaymentWebhookSecret: getEnv("PAYMENT_WEBHOOK_SECRET", ""),
+		PaymentReturnURL:     getEnv("PAYMENT_RETURN_URL", "http://localhost:3000/orders/{order}"),
+		PaymentCurrency:      getEnv("PAYMENT_CURRENCY", "usd"),
+
+		RequestTimeoutSeconds: getEnvInt("REQUEST_TIMEOUT_SECONDS", 30),
+		QueryTimeoutSeconds:   getEnvInt("QUERY_TIMEOUT_SECONDS", 10),
+
+		AnalyticsCacheTTLSeconds: getEnvInt("ANALYTICS_CACHE_TTL_SECONDS", 60),
+		AccountDeletionGraceDays: getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 30),
+		TrashRetentionDays:       getEnvInt("TRASH_RETENTION_DAYS", 30),
+
+		PartitionMonthsAhead:        getEnvInt("PARTITION_MONTHS_AHEAD", 3),
+		LoginHistoryRetentionMonths: getEnvInt("LOGIN_HISTORY_RETENTION_MONTHS", 0),
+
+		QueryPlanGuard:       getEnv("QUERY_PLAN_GUARD", "false") == "true",
+		QueryPlanSeqScanRows: getEnvInt("QUERY_PLAN_SEQ_SCAN_ROWS", 10000),
+
+		TrustedProxies:   getEnv("TRUSTED_PROXIES", ""),
+		ProxyHeader:      getEnv("PROXY_HEADER", "X-Forwarded-For"),
+		GeoCountryHeader: getEnv("GEO_COUNTRY_HEADER", "CF-IPCountry"),
+
+		StorageDriver:       getEnv("STORAGE_DRIVER", "disk"),
+		StorageDir:          getEnv("STORAGE_DIR", "./uploads"),
+		StoragePublicURL:    getEnv("STORAGE_PUBLIC_URL", "/uploads"),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		S3Region:            getEnv("S3_REGION", "us-east-1"),
+		S3Endpoint:          getEnv("S3_ENDPOINT", ""),
+		S3AccessKey:         getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:         getEnv("S3_SECRET_KEY", ""),
+		S3PublicURL:         getEnv("S3_PUBLIC_URL", ""),
+		AvatarMaxBytes:      getEnvInt("AVATAR_MAX_BYTES", 2<<20),
+		CoverMaxBytes:       getEnvInt("COVER_MAX_BYTES", 5<<20),
+		PackageMaxBytes:     getEnvInt("PACKAGE_MAX_BYTES", 64<<20),
+		AttachmentMaxBytes:  getEnvInt("ATTACHMENT_MAX_BYTES", 50<<20),
+		EmbedHosts:          getEnv("EMBED_HOSTS", ""),
+		ArchiveDriver:       getEnv("ARCHIVE_DRIVER", "disk"),
+		ArchiveDir:          getEnv("ARCHIVE_DIR", "./archive"),
+		ArchiveS3Bucket:     getEnv("ARCHIVE_S3_BUCKET", ""),
+		ArchiveInactiveDays: getEnvInt("ARCHIVE_INACTIVE_DAYS", 365),
+		ExportLinkSecret:    getEnv("EXPORT_LINK_SECRET", ""),
+		ExportLinkTTLHours:  getEnvInt("EXPORT_LINK_TTL_HOURS", 24),
+		UsageRetentionDays:  getEnvInt("USAGE_RETENTION_DAYS", 90),
+
+		StorageQuotaBytes:     getEnvInt("STORAGE_QUOTA_BYTES", 1<<30),
+		MaxCoursesPerAuthor:   getEnvInt("MAX_COURSES_PER_AUTHOR", 50),
+		MaxQuestionsPerAuthor: getEnvInt("MAX_QUESTIONS_PER_AUTHOR", 5000),
+		QuotaWarnPercents:     getEnv("QUOTA_WARN_PERCENTS", "80,95"),
+
+		SchemaCheck:          getEnv("SCHEMA_CHECK", "compat"),
+		SchemaMismatchAction: getEnv("SCHEMA_MISMATCH_ACTION", "fail"),
+
+		ReadOnly:        getEnv("READ_ONLY", "false") == "true",
+		ReadOnlyMessage: getEnv("READ_ONLY_MESSAGE", ""),
+
+		Sandbox:             getEnv("SANDBOX_MODE", "false") == "true",
+		SandboxResetHour:    getEnvInt("SANDBOX_RESET_HOUR", 3),
+		SandboxDemoPassword: getEnv("SANDBOX_DEMO_PASSWORD", "sandbox-demo"),
+
+		RateLimitWindowSeconds: getEnvInt("RATE_LIMIT_WINDOW_SECONDS", 60),
+		RateLimitMax:           getEnvInt("RATE_LIMIT_MAX", 120),
+		AuthRateLimitMax:       getEnvInt("AUTH_RATE_LIMIT_MAX", 10),
+		WriteRateLimitMax:      getEnvInt("WRITE_RATE_LIMIT_MAX", 60),
+	}
+
+	// Without the secret every verification fails and nobody can register or log in
+	if cfg.CaptchaProvider != "" && cfg.CaptchaSecret == "" {
+		return nil, fmt.Errorf("CAPTCHA_SECRET is required when CAPTCHA_PROVIDER is %q", cfg.CaptchaProvider)
+	}
+	return cfg, nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -40,3 +286,12 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
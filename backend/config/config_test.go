@@ -0,0 +1,19 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigRequiresCaptchaSecret(t *testing.T) {
+	t.Setenv("CAPTCHA_PROVIDER", "recaptcha")
+	t.Setenv("CAPTCHA_SECRET", "")
+	_, err := LoadConfig()
+	assert.Error(t, err)
+
+	t.Setenv("CAPTCHA_SECRET", "captcha-secret")
+	cfg, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "captcha-secret", cfg.CaptchaSecret)
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"errors"
+	"net/mail"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AccessListController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewAccessListController(db *gorm.DB, cfg *config.Config) *AccessListController {
+	return &AccessListController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *AccessListController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+type accessListEntry struct {
+	ID        uint      `json:"id"`
+	UserID    *uint     `json:"user_id,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	GroupID   *uint     `json:"group_id,omitempty"`
+	GroupName string    `json:"group_name,omitempty"`
+	AddedBy   uint      `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetAccessList возвращает список допуска курса
+func (ac *AccessListController) GetAccessList(c *fiber.Ctx) error {
+	course, done, err := ac.manageableCourse(c)
+	if done {
+		return err
+	}
+
+	var entries []accessListEntry
+	if err := ac.db(c).Table("course_access_lists").
+		Select("course_access_lists.id, course_access_lists.user_id, users.username, course_access_lists.email, "+
+			"course_access_lists.group_id, study_groups.name AS group_name, course_access_lists.added_by, course_access_lists.created_at").
+		Joins("LEFT JOIN users ON users.id = course_access_lists.user_id").
+		Joins("LEFT JOIN study_groups ON study_groups.id = course_access_lists.group_id").
+		Where("course_access_lists.course_id = ? AND course_access_lists.deleted_at IS NULL", course.ID).
+		Order("course_access_lists.created_at").
+		Scan(&entries).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch access list")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"access_level": course.AccessSettings.AccessLevel,
+		"entries":      entries,
+	})
+}
+
+// AddAccessListEntry допускает к курсу пользователя, email или учебную группу.
+// Список можно вести заранее: он действует, только пока у курса уровень доступа restricted.
+func (ac *AccessListController) AddAccessListEntry(c *fiber.Ctx) error {
+	course, done, err := ac.manageableCourse(c)
+	if done {
+		return err
+	}
+	actorID, _ := utils.ExtractUserIDFromToken(c, ac.Cfg)
+
+	var input struct {
+		UserID  uint   `json:"user_id"`
+		Email   string `json:"email"`
+		GroupID uint   `json:"group_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+
+	given := 0
+	for _, set := range []bool{input.UserID != 0, input.Email != "", input.GroupID != 0} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return utils.ValidationError(c, map[string]string{"entry": "Exactly one of user_id, email and group_id is required"})
+	}
+
+	entry := models.CourseAccessList{CourseID: course.ID, AddedBy: actorID}
+	duplicate := ac.db(c).Model(&models.CourseAccessList{}).Where("course_id = ?", course.ID)
+	response := accessListEntry{}
+	switch {
+	case input.UserID != 0:
+		var user models.User
+		if err := ac.db(c).Select("id", "username").First(&user, input.UserID).Error; err != nil {
+			return utils.NotFound(c, "User not found")
+		}
+		entry.UserID, response.Username = &user.ID, user.Username
+		duplicate = duplicate.Where("user_id = ?", user.ID)
+	case input.Email != "":
+		if _, err := mail.ParseAddress(input.Email); err != nil {
+			return utils.ValidationError(c, map[string]string{"email": "Invalid email"})
+		}
+		entry.Email = input.Email
+		duplicate = duplicate.Where("email = ?", input.Email)
+	default:
+		var group models.StudyGroup
+		if err := ac.db(c).First(&group, input.GroupID).Error; err != nil {
+			return utils.NotFound(c, "Group not found")
+		}
+		entry.GroupID, response.GroupName = &group.ID, group.Name
+		duplicate = duplicate.Where("group_id = ?", group.ID)
+	}
+
+	var exists int64
+	if err := duplicate.Count(&exists).Error; err != nil {
+		return utils.InternalServerError(c, "Could not add access list entry")
+	}
+	if exists > 0 {
+		return utils.Error(c, fiber.StatusConflict, errors.New("The entry is already on the access list"))
+	}
+	if err := ac.db(c).Create(&entry).Error; err != nil {
+		return utils.InternalServerError(c, "Could not add access list entry")
+	}
+
+	response.ID, response.UserID, response.Email, response.GroupID = entry.ID, entry.UserID, entry.Email, entry.GroupID
+	response.AddedBy, response.CreatedAt = entry.AddedBy, entry.CreatedAt
+	return utils.Created(c, response)
+}
+
+// RemoveAccessListEntry убирает запись из списка допуска. Уже записанные студенты продолжают проходить курс.
+func (ac *AccessListController) RemoveAccessListEntry(c *fiber.Ctx) error {
+	course, done, err := ac.manageableCourse(c)
+	if done {
+		return err
+	}
+
+	entryID, err := strconv.Atoi(c.Params("entryId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid entry ID")
+	}
+
+	result := ac.db(c).Unscoped().Where("id = ? AND course_id = ?", entryID, course.ID).Delete(&models.CourseAccessList{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not remove access list entry")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "Access list entry not found")
+	}
+	return utils.NoContent(c)
+}
+
+// manageableCourse загружает курс из :id и проверяет, что пользователь может решать, кого в него пускать
+func (ac *AccessListController) manageableCourse(c *fiber.Ctx) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, ac.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := ac.db(c).Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, policy.ActionInvite, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to manage access to this course"))
+	}
+	return &course, false, nil
+}
+
+// restrictedCourseAccess пускает в курс с уровнем доступа restricted только пользователей из списка допуска,
+// уже записанных студентов и сотрудников курса; для остальных уровней ничего не проверяет
+func restrictedCourseAccess(c *fiber.Ctx, course *models.Course) error {
+	if course.AccessSettings.AccessLevel != models.AccessRestricted {
+		return nil
+	}
+	return policy.Authorize(c, policy.ActionView, policy.Course(course))
+}
+
+// hiddenCourse сообщает, что курс вне каталога (черновик, на проверке, в архиве) закрыт для пользователя:
+// такие курсы видят только их студенты и редакторы, остальным они не показываются вовсе
+func hiddenCourse(c *fiber.Ctx, course *models.Course, enrolled bool) bool {
+	return course.Status != models.CoursePublished && !enrolled &&
+		policy.Authorize(c, policy.ActionEdit, policy.Course(course)) != nil
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"project/backend/accessibility"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AccessibilityController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewAccessibilityController(db *gorm.DB, cfg *config.Config) *AccessibilityController {
+	return &AccessibilityController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *AccessibilityController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+// GetCourseAccessibility проверяет уроки курса на проблемы доступности и возвращает оценку
+// и список проблем по каждому уроку, худшие уроки первыми; оценка курса — средняя по урокам
+func (ac *AccessibilityController) GetCourseAccessibility(c *fiber.Ctx) error {
+	course, done, err := ac.editableCourse(c)
+	if done {
+		return err
+	}
+
+	var lessons []models.Lesson
+	if err := ac.db(c).Where("course_id = ?", course.ID).Order("sequence_order, id").Find(&lessons).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch lessons")
+	}
+
+	results := make([]fiber.Map, 0, len(lessons))
+	total, issues := 0, 0
+	for _, lesson := range lessons {
+		report := accessibility.Check(lesson.Content)
+		total += report.Score
+		issues += len(report.Issues)
+		results = append(results, fiber.Map{
+			"lesson_id":      lesson.ID,
+			"title":          lesson.Title,
+			"sequence_order": lesson.SequenceOrder,
+			"score":          report.Score,
+			"issues":         report.Issues,
+		})
+	}
+	// Lessons with the same score keep the course order
+	sort.SliceStable(results, func(i, j int) bool { return results[i]["score"].(int) < results[j]["score"].(int) })
+
+	score := 100
+	if len(lessons) > 0 {
+		score = total / len(lessons)
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"course_id":    course.ID,
+		"score":        score,
+		"issues_count": issues,
+		"lessons":      results,
+	})
+}
+
+// CheckContent проверяет черновик урока до сохранения
+func (ac *AccessibilityController) CheckContent(c *fiber.Ctx) error {
+	if _, done, err := ac.editableCourse(c); done {
+		return err
+	}
+
+	var input struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	return utils.Success(c, fiber.StatusOK, accessibility.Check(input.Content))
+}
+
+func (ac *AccessibilityController) editableCourse(c *fiber.Ctx) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, ac.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := ac.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	// The report is for the people who can fix the content
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to check this course"))
+	}
+	return &course, false, nil
+}
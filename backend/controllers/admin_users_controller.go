@@ -0,0 +1,515 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/cache"
+	"project/backend/config"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/rankings"
+	"project/backend/storage"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AdminUsersController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewAdminUsersController(db *gorm.DB, cfg *config.Config) *AdminUsersController {
+	return &AdminUsersController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (uc *AdminUsersController) db(c *fiber.Ctx) *gorm.DB {
+	return uc.DB.WithContext(c.UserContext())
+}
+
+// adminUserSorts — допустимые значения ?sort= и соответствующие колонки
+var adminUserSorts = map[string]string{
+	"created_at":  "users.created_at",
+	"username":    "users.username",
+	"email":       "users.email",
+	"last_active": "user_progress.last_active",
+}
+
+// GetUsers возвращает каталог пользователей с фильтрами:
+// search (логин/email), role (users.role или назначенная роль), group, university_id / university,
+// registered_from / registered_to и active_from / active_to (YYYY-MM-DD, в часовом поясе админа),
+// status (active, deactivated, banned, archived), сортировкой sort + order и пагинацией page / page_size.
+func (uc *AdminUsersController) GetUsers(c *fiber.Ctx) error {
+	adminID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	sortColumn, ok := adminUserSorts[c.Query("sort", "created_at")]
+	if !ok {
+		return utils.ValidationError(c, map[string]string{"sort": "Sort must be one of created_at, username, email, last_active"})
+	}
+	order := c.Query("order", "desc")
+	if order != "asc" && order != "desc" {
+		return utils.ValidationError(c, map[string]string{"order": "Order must be asc or desc"})
+	}
+
+	query := uc.db(c).Model(&models.User{}).
+		Joins("LEFT JOIN user_progress ON user_progress.user_id = users.id AND user_progress.deleted_at IS NULL")
+
+	if search := c.Query("search"); search != "" {
+		query = query.Where("users.username ILIKE ? OR users.email ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where(`users.role = ? OR EXISTS (
+			SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = users.id AND user_roles.deleted_at IS NULL AND roles.name = ?)`, role, role)
+	}
+	if groupID := c.QueryInt("group_id"); groupID > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM study_group_members WHERE study_group_members.user_id = users.id AND study_group_members.group_id = ? AND study_group_members.deleted_at IS NULL)", groupID)
+	} else if group := c.Query("group"); group != "" {
+		query = query.Where("users.\"group\" = ?", group)
+	}
+	if universityID := c.QueryInt("university_id"); universityID > 0 {
+		query = query.Where("users.university_id = ?", universityID)
+	} else if university := c.Query("university"); university != "" {
+		query = query.Where("users.university = ?", university)
+	}
+
+	switch c.Query("status") {
+	case "":
+	case "active":
+		query = query.Where("users.active AND (users.banned_until IS NULL OR users.banned_until <= ?)", time.Now())
+	case "deactivated":
+		query = query.Where("NOT users.active")
+	case "banned":
+		query = query.Where("users.banned_until > ?", time.Now())
+	case "archived":
+		query = query.Where("users.archived_at IS NOT NULL")
+	default:
+		return utils.ValidationError(c, map[string]string{"status": "Status must be one of active, deactivated, banned, archived"})
+	}
+
+	// Date bounds are whole days in the admin's timezone; "to" is inclusive
+	loc := utils.UserLocation(uc.db(c), adminID)
+	for _, bound := range []struct {
+		param, condition string
+		inclusiveEnd     bool
+	}{
+		{"registered_from", "users.created_at >= ?", false},
+		{"registered_to", "users.created_at < ?", true},
+		{"active_from", "user_progress.last_active >= ?", false},
+		{"active_to", "user_progress.last_active < ?", true},
+	} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			return utils.ValidationError(c, map[string]string{bound.param: "Date must be in YYYY-MM-DD format"})
+		}
+		if bound.inclusiveEnd {
+			day = day.AddDate(0, 0, 1)
+		}
+		query = query.Where(bound.condition, day)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch users")
+	}
+
+	var rows []struct {
+		models.User
+		LastActive *time.Time
+	}
+	if err := query.Select("users.*, user_progress.last_active").
+		Order(fmt.Sprintf("%s %s NULLS LAST", sortColumn, order)).
+		Order("users.id " + order).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&rows).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch users")
+	}
+
+	// Assigned roles for the whole page in one query
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	var assignments []models.UserRole
+	if len(ids) > 0 {
+		if err := uc.db(c).Preload("Role").Where("user_id IN ?", ids).Find(&assignments).Error; err != nil {
+			return utils.InternalServerError(c, "Failed to fetch users")
+		}
+	}
+	roles := make(map[uint][]string)
+	for _, assignment := range assignments {
+		roles[assignment.UserID] = append(roles[assignment.UserID], assignment.Role.Name)
+	}
+
+	users := make([]fiber.Map, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, fiber.Map{
+			"id":                  row.ID,
+			"username":            row.Username,
+			"email":               row.Email,
+			"role":                row.Role,
+			"roles":               append([]string{}, roles[row.ID]...),
+			"group":               row.Group,
+			"university":          row.University,
+			"university_id":       row.UniversityID,
+			"university_verified": row.UniversityVerifiedAt != nil,
+			"active":              row.Active,
+			"banned_until":        row.BannedUntil,
+			"archived":            row.ArchivedAt != nil,
+			"created_at":          row.CreatedAt,
+			"last_active":         row.LastActive,
+		})
+	}
+
+	return utils.Paginate(c, users, total, page, pageSize)
+}
+
+// DeactivateUser отключает аккаунт пользователя
+func (uc *AdminUsersController) DeactivateUser(c *fiber.Ctx) error {
+	return uc.updateStatus(c, map[string]interface{}{"active": false})
+}
+
+// ActivateUser снова включает аккаунт пользователя
+func (uc *AdminUsersController) ActivateUser(c *fiber.Ctx) error {
+	return uc.updateStatus(c, map[string]interface{}{"active": true})
+}
+
+// BanUser временно (или бессрочно, если days = 0) закрывает пользователю доступ
+func (uc *AdminUsersController) BanUser(c *fiber.Ctx) error {
+	var input struct {
+		Reason string `json:"reason"`
+		Days   int    `json:"days"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	if input.Reason == "" {
+		return utils.ValidationError(c, map[string]string{"reason": "Reason is required"})
+	}
+	if input.Days < 0 {
+		return utils.ValidationError(c, map[string]string{"days": "Days must be positive"})
+	}
+
+	bannedUntil := utils.PermanentBan
+	if input.Days > 0 {
+		bannedUntil = time.Now().AddDate(0, 0, input.Days)
+	}
+
+	return uc.updateStatus(c, map[string]interface{}{
+		"banned_until": bannedUntil,
+		"ban_reason":   input.Reason,
+	})
+}
+
+// UnbanUser снимает бан с пользователя
+func (uc *AdminUsersController) UnbanUser(c *fiber.Ctx) error {
+	return uc.updateStatus(c, map[string]interface{}{
+		"banned_until": nil,
+		"ban_reason":   "",
+	})
+}
+
+// MergeUser переносит данные дублирующего аккаунта :id в аккаунт into_user_id одной транзакцией:
+// прогресс (при совпадении курса или теста остается лучший результат), комментарии, авторство,
+// историю входов и подписки. Роли, API-ключи и настройки дубликата не переносятся,
+// сам дубликат удаляется так же, как при удалении аккаунта пользователем.
+func (uc *AdminUsersController) MergeUser(c *fiber.Ctx) error {
+	adminID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	sourceID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	var input struct {
+		IntoUserID uint `json:"into_user_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.IntoUserID == 0 {
+		return utils.ValidationError(c, map[string]string{"into_user_id": "Target user is required"})
+	}
+	if input.IntoUserID == uint(sourceID) {
+		return utils.ValidationError(c, map[string]string{"into_user_id": "Can't merge an account into itself"})
+	}
+	if uint(sourceID) == adminID {
+		return utils.BadRequest(c, "You can't merge away your own account")
+	}
+
+	var source, target models.User
+	if err := uc.db(c).First(&source, sourceID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+	if err := uc.db(c).First(&target, input.IntoUserID).Error; err != nil {
+		return utils.NotFound(c, "Target user not found")
+	}
+	// The duplicate is deleted by the merge
+	if utils.UnderLegalHold(uc.db(c), models.HoldSubjectUser, source.ID) {
+		return utils.LegalHoldConflict(c)
+	}
+	// Archived activity lives in cold storage and would be left behind
+	if source.ArchivedAt != nil || target.ArchivedAt != nil {
+		return utils.ValidationError(c, map[string]string{"user": "Restore archived accounts before merging them"})
+	}
+
+	avatarKey := source.AvatarKey
+	var moved map[string]int64
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		moved, err = mergeAccounts(tx, &source, &target, uc.Cfg.AccountDeletionGraceDays)
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return utils.NotFound(c, "User not found")
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not merge accounts")
+	}
+
+	if avatarKey != "" {
+		storage.Default.Delete(c.UserContext(), avatarKey)
+	}
+	cache.Analytics.Invalidate("platform")
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"message":     "Accounts merged",
+		"merged_from": source.ID,
+		"user_id":     target.ID,
+		"moved":       moved,
+	})
+}
+
+// mergeAccounts выполняет слияние внутри транзакции tx и возвращает число перенесенных строк по разделам
+func mergeAccounts(tx *gorm.DB, source, target *models.User, graceDays int) (map[string]int64, error) {
+	// Lock both accounts in id order, so two opposite merges can't deadlock
+	first, second := source.ID, target.ID
+	if first > second {
+		first, second = second, first
+	}
+	for _, id := range []uint{first, second} {
+		if err := jobs.LockEntity(tx, "account", id); err != nil {
+			return nil, err
+		}
+	}
+	// Re-read under the lock: a concurrent merge may have deleted either account
+	if err := tx.First(source, source.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.First(target, target.ID).Error; err != nil {
+		return nil, err
+	}
+
+	var testIDs []uint
+	if err := tx.Model(&models.UserTestProgress{}).Where("user_id = ?", source.ID).
+		Distinct().Pluck("test_id", &testIDs).Error; err != nil {
+		return nil, err
+	}
+
+	moved := make(map[string]int64)
+	steps := []struct {
+		name string
+		sql  string
+		args []interface{}
+	}{
+		// Progress on the same course: keep the furthest one, add up the time spent
+		{"", `UPDATE user_course_progress t SET
+			lessons_completed = GREATEST(t.lessons_completed, s.lessons_completed),
+			completion_rate = GREATEST(t.completion_rate, s.completion_rate),
+			hours_spent = t.hours_spent + s.hours_spent,
+			last_accessed = GREATEST(t.last_accessed, s.last_accessed),
+			updated_at = NOW()
+			FROM user_course_progress s
+			WHERE t.user_id = ? AND s.user_id = ? AND s.course_id = t.course_id
+			AND t.deleted_at IS NULL AND s.deleted_at IS NULL`, []interface{}{target.ID, source.ID}},
+		{"", `DELETE FROM user_course_progress s WHERE s.user_id = ? AND EXISTS (
+			SELECT 1 FROM user_course_progress t
+			WHERE t.user_id = ? AND t.course_id = s.course_id AND t.deleted_at IS NULL)`, []interface{}{source.ID, target.ID}},
+		{"course_progress", `UPDATE user_course_progress SET user_id = ? WHERE user_id = ?`, []interface{}{target.ID, source.ID}},
+
+		// Results of the same test: keep the best attempt, add up the attempts used
+		{"", `UPDATE user_test_progress t SET
+			score = GREATEST(t.score, s.score),
+			correct_answers = CASE WHEN s.score > t.score THEN s.correct_answers ELSE t.correct_answers END,
+			questions_answered = CASE WHEN s.score > t.score THEN s.questions_answered ELSE t.questions_answered END,
+			attempts_used = t.attempts_used + s.attempts_used,
+			last_attempt = GREATEST(t.last_attempt, s.last_attempt),
+			updated_at = NOW()
+			FROM user_test_progress s
+			WHERE t.user_id = ? AND s.user_id = ? AND s.test_id = t.test_id
+			AND t.deleted_at IS NULL AND s.deleted_at IS NULL`, []interface{}{target.ID, source.ID}},
+		{"", `DELETE FROM user_test_progress s WHERE s.user_id = ? AND EXISTS (
+			SELECT 1 FROM user_test_progress t
+			WHERE t.user_id = ? AND t.test_id = s.test_id AND t.deleted_at IS NULL)`, []interface{}{source.ID, target.ID}},
+		{"test_results", `UPDATE user_test_progress SET user_id = ? WHERE user_id = ?`, []interface{}{target.ID, source.ID}},
+
+		// Overall progress is one row per user
+		{"", `UPDATE user_progress t SET
+			courses_completed = t.courses_completed + s.courses_completed,
+			tests_completed = t.tests_completed + s.tests_completed,
+			streak_days = GREATEST(t.streak_days, s.streak_days),
+			last_active = GREATEST(t.last_active, s.last_active),
+			updated_at = NOW()
+			FROM user_progress s
+			WHERE t.user_id = ? AND s.user_id = ? AND t.deleted_at IS NULL AND s.deleted_at IS NULL`, []interface{}{target.ID, source.ID}},
+		{"", `DELETE FROM user_progress WHERE user_id = ? AND EXISTS (
+			SELECT 1 FROM user_progress WHERE user_id = ? AND deleted_at IS NULL)`, []interface{}{source.ID, target.ID}},
+		{"progress", `UPDATE user_progress SET user_id = ? WHERE user_id = ?`, []interface{}{target.ID, source.ID}},
+
+		{"course_comments", `UPDATE course_comments SET user_id = ?, user_name = ? WHERE user_id = ?`, []interface{}{target.ID, target.Username, source.ID}},
+		{"course_comment_replies", `UPDATE course_comment_replies SET user_id = ?, user_name = ? WHERE user_id = ?`, []interface{}{target.ID, target.Username, source.ID}},
+		{"test_comments", `UPDATE test_comments SET user_id = ?, user_name = ? WHERE user_id = ?`, []interface{}{target.ID, target.Username, source.ID}},
+		{"test_comment_replies", `UPDATE test_comment_replies SET user_id = ?, user_name = ? WHERE user_id = ?`, []interface{}{target.ID, target.Username, source.ID}},
+		{"courses", `UPDATE courses SET author_id = ? WHERE author_id = ?`, []interface{}{target.ID, source.ID}},
+		{"tests", `UPDATE tests SET author_id = ? WHERE author_id = ?`, []interface{}{target.ID, source.ID}},
+		{"login_history", `UPDATE login_history SET user_id = ? WHERE user_id = ?`, []interface{}{target.ID, source.ID}},
+		{"activities", `UPDATE user_activities SET user_id = ? WHERE user_id = ?`, []interface{}{target.ID, source.ID}},
+		// Snapshots are derived; the target's current month is rebuilt by the progress-snapshots job
+		{"", `DELETE FROM user_progress_snapshots WHERE user_id = ?`, []interface{}{source.ID}},
+		{"", `UPDATE invitations SET invited_by = ? WHERE invited_by = ?`, []interface{}{target.ID, source.ID}},
+		{"", `UPDATE user_roles SET assigned_by = ? WHERE assigned_by = ?`, []interface{}{target.ID, source.ID}},
+		{"course_staff", `UPDATE course_staff SET user_id = ? WHERE user_id = ?
+			AND course_id NOT IN (SELECT course_id FROM course_staff WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"", `DELETE FROM course_staff WHERE user_id = ?`, []interface{}{source.ID}},
+		{"groups", `UPDATE study_group_members SET user_id = ? WHERE user_id = ?
+			AND group_id NOT IN (SELECT group_id FROM study_group_members WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"", `DELETE FROM study_group_members WHERE user_id = ?`, []interface{}{source.ID}},
+		{"exam_attempts", `UPDATE exam_attempts SET user_id = ? WHERE user_id = ?
+			AND session_id NOT IN (SELECT session_id FROM exam_attempts WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"", `UPDATE exam_session_proctors SET user_id = ? WHERE user_id = ?
+			AND session_id NOT IN (SELECT session_id FROM exam_session_proctors WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"", `DELETE FROM exam_session_proctors WHERE user_id = ?`, []interface{}{source.ID}},
+		{"certificates", `UPDATE course_certificates SET user_id = ? WHERE user_id = ?
+			AND course_id NOT IN (SELECT course_id FROM course_certificates WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"survey_responses", `UPDATE course_survey_responses SET user_id = ? WHERE user_id = ?
+			AND course_id NOT IN (SELECT course_id FROM course_survey_responses WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"grade_entries", `UPDATE course_grade_entries SET user_id = ? WHERE user_id = ?
+			AND component_id NOT IN (SELECT component_id FROM course_grade_entries WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"", `DELETE FROM course_grades WHERE user_id = ?`, []interface{}{source.ID}},
+		// One review per course: the target's own review wins
+		{"course_reviews", `UPDATE course_reviews SET user_id = ? WHERE user_id = ?
+			AND course_id NOT IN (SELECT course_id FROM course_reviews WHERE user_id = ?)`, []interface{}{target.ID, source.ID, target.ID}},
+		{"", `DELETE FROM course_reviews WHERE user_id = ?`, []interface{}{source.ID}},
+
+		// Follows: skip pairs the target already has and follows of oneself
+		{"following", `UPDATE user_follows SET follower_id = ? WHERE follower_id = ? AND author_id <> ?
+			AND author_id NOT IN (SELECT author_id FROM user_follows WHERE follower_id = ?)`,
+			[]interface{}{target.ID, source.ID, target.ID, target.ID}},
+		{"followers", `UPDATE user_follows SET author_id = ? WHERE author_id = ? AND follower_id <> ?
+			AND follower_id NOT IN (SELECT follower_id FROM user_follows WHERE author_id = ?)`,
+			[]interface{}{target.ID, source.ID, target.ID, target.ID}},
+		{"", `DELETE FROM user_follows WHERE follower_id = ? OR author_id = ?`, []interface{}{source.ID, source.ID}},
+
+		// Mentor links keep their consent status, same rules as follows
+		{"mentees", `UPDATE mentor_links SET mentor_id = ? WHERE mentor_id = ? AND student_id <> ?
+			AND student_id NOT IN (SELECT student_id FROM mentor_links WHERE mentor_id = ?)`,
+			[]interface{}{target.ID, source.ID, target.ID, target.ID}},
+		{"mentors", `UPDATE mentor_links SET student_id = ? WHERE student_id = ? AND mentor_id <> ?
+			AND mentor_id NOT IN (SELECT mentor_id FROM mentor_links WHERE student_id = ?)`,
+			[]interface{}{target.ID, source.ID, target.ID, target.ID}},
+		{"", `DELETE FROM mentor_links WHERE mentor_id = ? OR student_id = ?`, []interface{}{source.ID, source.ID}},
+
+		// Credentials and personal preferences of the duplicate are not inherited
+		{"", `DELETE FROM api_keys WHERE user_id = ?`, []interface{}{source.ID}},
+		{"", `DELETE FROM affiliation_verifications WHERE user_id = ?`, []interface{}{source.ID}},
+		{"", `DELETE FROM email_changes WHERE user_id = ?`, []interface{}{source.ID}},
+		{"", `DELETE FROM user_roles WHERE user_id = ?`, []interface{}{source.ID}},
+		{"", `DELETE FROM user_settings WHERE user_id = ?`, []interface{}{source.ID}},
+	}
+	for _, step := range steps {
+		result := tx.Exec(step.sql, step.args...)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if step.name != "" {
+			moved[step.name] = result.RowsAffected
+		}
+	}
+
+	for _, testID := range testIDs {
+		if err := rankings.RefreshTest(tx, testID); err != nil {
+			return nil, err
+		}
+	}
+
+	// The duplicate is removed like a self-deleted account and purged after the grace period
+	if err := tx.Model(source).Updates(map[string]interface{}{
+		"active":        false,
+		"purge_after":   time.Now().AddDate(0, 0, graceDays),
+		"avatar_url":    "",
+		"avatar_key":    "",
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return moved, tx.Delete(source).Error
+}
+
+// updateStatus меняет статус аккаунта. Свой статус и статус пользователя, у которого есть права
+// сверх прав администратора (см. utils.Outranks), менять нельзя.
+func (uc *AdminUsersController) updateStatus(c *fiber.Ctx, updates map[string]interface{}) error {
+	adminID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	if uint(userID) == adminID {
+		return utils.BadRequest(c, "You can't change the status of your own account")
+	}
+
+	var user models.User
+	if err := uc.db(c).First(&user, userID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	// A support agent must not lock out the admins above them
+	outranked, err := utils.Outranks(uc.db(c), user.ID, adminID)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not check permissions")
+	}
+	if outranked {
+		return utils.Forbidden(c, "You can't change the status of a user with more permissions than you")
+	}
+
+	if err := uc.db(c).Model(&user).Updates(updates).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update user status")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"id":           user.ID,
+		"active":       user.Active,
+		"banned_until": user.BannedUntil,
+		"ban_reason":   user.BanReason,
+	})
+}
@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"project/backend/cache"
 	"project/backend/config"
 	"project/backend/models"
+	"project/backend/policy"
 	"project/backend/utils"
 	"strconv"
 	"time"
@@ -20,6 +22,24 @@ func NewAnalyticsController(db *gorm.DB, cfg *config.Config) *AnalyticsControlle
 	return &AnalyticsController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *AnalyticsController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+// cacheKey строит ключ кеша с учетом роли пользователя, чтобы ответы разных ролей не смешивались
+func (ac *AnalyticsController) cacheKey(c *fiber.Ctx, entity string, filters ...interface{}) string {
+	role := ""
+	if claims, err := utils.ExtractClaims(c, ac.Cfg); err == nil {
+		role = claims.Role
+	}
+	return cache.Key(entity, role, filters...)
+}
+
+func (ac *AnalyticsController) cacheTTL() time.Duration {
+	return time.Duration(ac.Cfg.AnalyticsCacheTTLSeconds) * time.Second
+}
+
 // GetUserProgressAnalytics возвращает аналитику прогресса пользователя
 func (ac *AnalyticsController) GetUserProgressAnalytics(c *fiber.Ctx) error {
 	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
@@ -31,21 +51,22 @@ func (ac *AnalyticsController) GetUserProgressAnalytics(c *fiber.Ctx) error {
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
 
-	// Парсим даты или устанавливаем значения по умолчанию
+	// Парсим даты в часовом поясе пользователя или устанавливаем значения по умолчанию
+	loc := utils.UserLocation(ac.db(c), userID)
 	var start, end time.Time
 	if startDate == "" {
-		start = time.Now().AddDate(0, -1, 0) // Последний месяц по умолчанию
+		start = time.Now().In(loc).AddDate(0, -1, 0) // Последний месяц по умолчанию
 	} else {
-		start, err = time.Parse("2006-01-02", startDate)
+		start, err = time.ParseInLocation("2006-01-02", startDate, loc)
 		if err != nil {
 			return utils.BadRequest(c, "Invalid start_date format. Use YYYY-MM-DD")
 		}
 	}
 
 	if endDate == "" {
-		end = time.Now()
+		end = time.Now().In(loc)
 	} else {
-		end, err = time.Parse("2006-01-02", endDate)
+		end, err = time.ParseInLocation("2006-01-02", endDate, loc)
 		if err != nil {
 			return utils.BadRequest(c, "Invalid end_date format. Use YYYY-MM-DD")
 		}
@@ -53,21 +74,21 @@ func (ac *AnalyticsController) GetUserProgressAnalytics(c *fiber.Ctx) error {
 
 	// Получаем данные о прогрессе курсов
 	var courseProgress []models.UserCourseProgress
-	if err := ac.DB.Where("user_id = ? AND updated_at BETWEEN ? AND ?",
+	if err := ac.db(c).Where("user_id = ? AND updated_at BETWEEN ? AND ?",
 		userID, start, end).Find(&courseProgress).Error; err != nil {
 		return utils.InternalServerError(c, "Failed to fetch course progress")
 	}
 
 	// Получаем данные о прогрессе тестов
 	var testProgress []models.UserTestProgress
-	if err := ac.DB.Where("user_id = ? AND updated_at BETWEEN ? AND ?",
+	if err := ac.db(c).Where("user_id = ? AND updated_at BETWEEN ? AND ?",
 		userID, start, end).Find(&testProgress).Error; err != nil {
 		return utils.InternalServerError(c, "Failed to fetch test progress")
 	}
 
 	// Получаем данные о посещениях
 	var loginHistory []models.LoginHistory
-	if err := ac.DB.Where("user_id = ? AND login_time BETWEEN ? AND ?",
+	if err := ac.db(c).Where("user_id = ? AND login_time BETWEEN ? AND ? AND success",
 		userID, start, end).Find(&loginHistory).Error; err != nil {
 		return utils.InternalServerError(c, "Failed to fetch login history")
 	}
@@ -91,19 +112,23 @@ func (ac *AnalyticsController) GetCourseAnalytics(c *fiber.Ctx) error {
 		return utils.BadRequest(c, "Invalid course ID")
 	}
 
-	// Проверяем права доступа (только для автора/админа)
-	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
-	if err != nil {
+	// Проверяем права доступа (автор, со-администраторы и роли с доступом к аналитике)
+	if _, err := utils.ExtractUserIDFromToken(c, ac.Cfg); err != nil {
 		return utils.Unauthorized(c, "Unauthorized")
 	}
 
 	var course models.Course
-	if err := ac.DB.First(&course, courseID).Error; err != nil {
+	if err := ac.db(c).First(&course, courseID).Error; err != nil {
 		return utils.NotFound(c, "Course not found")
 	}
 
-	if course.AuthorID != userID {
-		return utils.Forbidden(c, "You don't have permission to view this analytics")
+	if err := policy.Authorize(c, policy.ActionViewAnalytics, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to view this analytics"))
+	}
+
+	key := ac.cacheKey(c, "course", courseID)
+	if data, ok := cache.Analytics.Get(key); ok {
+		return utils.Success(c, fiber.StatusOK, data)
 	}
 
 	// Получаем статистику по курсу
@@ -114,20 +139,20 @@ func (ac *AnalyticsController) GetCourseAnalytics(c *fiber.Ctx) error {
 		AvgTimeSpent      float64
 	}
 
-	ac.DB.Model(&models.UserCourseProgress{}).
+	ac.db(c).Model(&models.UserCourseProgress{}).
 		Where("course_id = ?", courseID).
 		Count(&stats.TotalEnrollments)
 
-	ac.DB.Model(&models.UserCourseProgress{}).
+	ac.db(c).Model(&models.UserCourseProgress{}).
 		Where("course_id = ? AND completion_rate >= 100", courseID).
 		Count(&stats.Completed)
 
-	ac.DB.Model(&models.UserCourseProgress{}).
+	ac.db(c).Model(&models.UserCourseProgress{}).
 		Select("AVG(completion_rate)").
 		Where("course_id = ?", courseID).
 		Scan(&stats.AvgCompletionRate)
 
-	ac.DB.Model(&models.UserCourseProgress{}).
+	ac.db(c).Model(&models.UserCourseProgress{}).
 		Select("AVG(hours_spent)").
 		Where("course_id = ?", courseID).
 		Scan(&stats.AvgTimeSpent)
@@ -140,7 +165,7 @@ func (ac *AnalyticsController) GetCourseAnalytics(c *fiber.Ctx) error {
 		Total       int64  `json:"total"`
 	}
 
-	ac.DB.Raw(`
+	ac.db(c).Raw(`
 		SELECT l.id as lesson_id, l.title as lesson_title, 
 		COUNT(ucp.id) as completed,
 		(SELECT COUNT(*) FROM user_course_progress WHERE course_id = ?) as total
@@ -150,13 +175,19 @@ func (ac *AnalyticsController) GetCourseAnalytics(c *fiber.Ctx) error {
 		GROUP BY l.id, l.title
 	`, courseID, courseID).Scan(&lessonCompletion)
 
-	return utils.Success(c, fiber.StatusOK, fiber.Map{
+	data := fiber.Map{
 		"course_id":    courseID,
 		"course_title": course.Title,
 		"stats":        stats,
 		"lesson_stats": lessonCompletion,
-		"enrollments":  getEnrollmentTrends(ac.DB, uint(courseID)),
-	})
+		"enrollments":  getEnrollmentTrends(ac.db(c), uint(courseID)),
+	}
+	if conduct, err := conductSummary(ac.db(c), uint(courseID)); err == nil && conduct != nil {
+		data["conduct"] = conduct
+	}
+	cache.Analytics.Set(key, data, ac.cacheTTL(), cache.Tag("course", courseID))
+
+	return utils.Success(c, fiber.StatusOK, data)
 }
 
 // getEnrollmentTrends возвращает динамику регистраций на курс
@@ -185,7 +216,7 @@ func (ac *AnalyticsController) GetTestAnalytics(c *fiber.Ctx) error {
 
 	// Проверяем существование теста
 	var test models.Test
-	if err := ac.DB.First(&test, testID).Error; err != nil {
+	if err := ac.db(c).First(&test, testID).Error; err != nil {
 		return utils.NotFound(c, "Test not found")
 	}
 
@@ -213,6 +244,11 @@ func (ac *AnalyticsController) GetTestAnalytics(c *fiber.Ctx) error {
 		}
 	}
 
+	key := ac.cacheKey(c, "test", testID, startDate, endDate)
+	if data, ok := cache.Analytics.Get(key); ok {
+		return utils.Success(c, fiber.StatusOK, data)
+	}
+
 	// Основные метрики
 	var metrics struct {
 		TotalAttempts     int64
@@ -223,31 +259,31 @@ func (ac *AnalyticsController) GetTestAnalytics(c *fiber.Ctx) error {
 		AvgWrongAnswers   float64
 	}
 
-	ac.DB.Model(&models.UserTestProgress{}).
+	ac.db(c).Model(&models.UserTestProgress{}).
 		Where("test_id = ? AND updated_at BETWEEN ? AND ?", testID, start, end).
 		Count(&metrics.TotalAttempts)
 
-	ac.DB.Model(&models.UserTestProgress{}).
+	ac.db(c).Model(&models.UserTestProgress{}).
 		Select("COUNT(DISTINCT user_id)").
 		Where("test_id = ? AND updated_at BETWEEN ? AND ?", testID, start, end).
 		Scan(&metrics.UniqueUsers)
 
-	ac.DB.Model(&models.UserTestProgress{}).
+	ac.db(c).Model(&models.UserTestProgress{}).
 		Select("AVG(score)").
 		Where("test_id = ? AND updated_at BETWEEN ? AND ?", testID, start, end).
 		Scan(&metrics.AvgScore)
 
-	ac.DB.Model(&models.UserTestProgress{}).
+	ac.db(c).Model(&models.UserTestProgress{}).
 		Select("AVG(time_spent)").
 		Where("test_id = ? AND updated_at BETWEEN ? AND ?", testID, start, end).
 		Scan(&metrics.AvgTimeSpent)
 
-	ac.DB.Model(&models.UserTestProgress{}).
+	ac.db(c).Model(&models.UserTestProgress{}).
 		Select("AVG(correct_answers)").
 		Where("test_id = ? AND updated_at BETWEEN ? AND ?", testID, start, end).
 		Scan(&metrics.AvgCorrectAnswers)
 
-	ac.DB.Model(&models.UserTestProgress{}).
+	ac.db(c).Model(&models.UserTestProgress{}).
 		Select("AVG(wrong_answers)").
 		Where("test_id = ? AND updated_at BETWEEN ? AND ?", testID, start, end).
 		Scan(&metrics.AvgWrongAnswers)
@@ -260,7 +296,7 @@ func (ac *AnalyticsController) GetTestAnalytics(c *fiber.Ctx) error {
 		AvgTimeSpent float64 `json:"avg_time_spent"`
 	}
 
-	ac.DB.Raw(`
+	ac.db(c).Raw(`
         SELECT 
             DATE(updated_at) as date,
             COUNT(*) as attempts,
@@ -279,7 +315,7 @@ func (ac *AnalyticsController) GetTestAnalytics(c *fiber.Ctx) error {
 		CorrectRate  float64 `json:"correct_rate"`
 	}
 
-	ac.DB.Raw(`
+	ac.db(c).Raw(`
         SELECT 
             q.id as question_id,
             q.question as question_text,
@@ -291,7 +327,27 @@ func (ac *AnalyticsController) GetTestAnalytics(c *fiber.Ctx) error {
         ORDER BY correct_rate ASC
     `, testID, start, end).Scan(&questionStats)
 
-	return utils.Success(c, fiber.StatusOK, fiber.Map{
+	// Localized variants are one test; the totals above include them all, this splits them by language
+	var localeStats []struct {
+		Locale      string  `json:"locale"`
+		Attempts    int     `json:"attempts"`
+		UniqueUsers int     `json:"unique_users"`
+		AvgScore    float64 `json:"avg_score"`
+	}
+
+	ac.db(c).Raw(`
+        SELECT
+            COALESCE(NULLIF(locale, ''), 'original') as locale,
+            COUNT(*) as attempts,
+            COUNT(DISTINCT user_id) as unique_users,
+            AVG(score) as avg_score
+        FROM user_test_progress
+        WHERE test_id = ? AND updated_at BETWEEN ? AND ? AND deleted_at IS NULL
+        GROUP BY 1
+        ORDER BY attempts DESC
+    `, testID, start, end).Scan(&localeStats)
+
+	data := fiber.Map{
 		"test_id":    testID,
 		"test_title": test.Title,
 		"period": fiber.Map{
@@ -301,7 +357,11 @@ func (ac *AnalyticsController) GetTestAnalytics(c *fiber.Ctx) error {
 		"metrics":        metrics,
 		"daily_stats":    dailyStats,
 		"question_stats": questionStats,
-	})
+		"locale_stats":   localeStats,
+	}
+	cache.Analytics.Set(key, data, ac.cacheTTL(), cache.Tag("test", testID))
+
+	return utils.Success(c, fiber.StatusOK, data)
 }
 
 // GetPlatformAnalytics возвращает аналитику по всей платформе (только для админов)
@@ -312,13 +372,13 @@ func (ac *AnalyticsController) GetPlatformAnalytics(c *fiber.Ctx) error {
 		return utils.Unauthorized(c, "Unauthorized")
 	}
 
-	var user models.User
-	if err := ac.DB.First(&user, userID).Error; err != nil {
-		return utils.NotFound(c, "User not found")
+	if !utils.HasPermission(ac.db(c), userID, models.PermPlatformView) {
+		return utils.Forbidden(c, "Admin access required")
 	}
 
-	if user.Role != "admin" {
-		return utils.Forbidden(c, "Admin access required")
+	key := ac.cacheKey(c, "platform")
+	if data, ok := cache.Analytics.Get(key); ok {
+		return utils.Success(c, fiber.StatusOK, data)
 	}
 
 	// Основные метрики платформы
@@ -333,21 +393,21 @@ func (ac *AnalyticsController) GetPlatformAnalytics(c *fiber.Ctx) error {
 	}
 
 	// Получаем данные
-	ac.DB.Model(&models.User{}).Count(&metrics.TotalUsers)
-	ac.DB.Model(&models.User{}).Where("last_login > ?",
+	ac.db(c).Model(&models.User{}).Count(&metrics.TotalUsers)
+	ac.db(c).Model(&models.User{}).Where("last_login > ?",
 		time.Now().AddDate(0, 0, -30)).Count(&metrics.ActiveUsers)
-	ac.DB.Model(&models.User{}).Where("created_at > ?",
+	ac.db(c).Model(&models.User{}).Where("created_at > ?",
 		time.Now().AddDate(0, 0, -7)).Count(&metrics.NewUsers)
-	ac.DB.Model(&models.Course{}).Count(&metrics.TotalCourses)
-	ac.DB.Model(&models.Course{}).Where("updated_at > ?",
+	ac.db(c).Model(&models.Course{}).Count(&metrics.TotalCourses)
+	ac.db(c).Model(&models.Course{}).Where("updated_at > ?",
 		time.Now().AddDate(0, -1, 0)).Count(&metrics.ActiveCourses)
-	ac.DB.Model(&models.Test{}).Count(&metrics.TotalTests)
-	ac.DB.Model(&models.UserCourseProgress{}).
+	ac.db(c).Model(&models.Test{}).Count(&metrics.TotalTests)
+	ac.db(c).Model(&models.UserCourseProgress{}).
 		Select("AVG(completion_rate)").Scan(&metrics.AvgCourseProgress)
 
 	// Динамика регистраций пользователей
 	var userGrowth []map[string]interface{}
-	ac.DB.Raw(`
+	ac.db(c).Raw(`
 		SELECT 
 			DATE(created_at) as date,
 			COUNT(*) as users
@@ -358,7 +418,7 @@ func (ac *AnalyticsController) GetPlatformAnalytics(c *fiber.Ctx) error {
 
 	// Самые популярные курсы
 	var popularCourses []map[string]interface{}
-	ac.DB.Raw(`
+	ac.db(c).Raw(`
 		SELECT 
 			c.id,
 			c.title,
@@ -371,10 +431,44 @@ func (ac *AnalyticsController) GetPlatformAnalytics(c *fiber.Ctx) error {
 		LIMIT 5
 	`).Scan(&popularCourses)
 
-	return utils.Success(c, fiber.StatusOK, fiber.Map{
+	data := fiber.Map{
 		"metrics":         metrics,
 		"user_growth":     userGrowth,
 		"popular_courses": popularCourses,
 		"timestamp":       time.Now().Format(time.RFC3339),
+	}
+	cache.Analytics.Set(key, data, ac.cacheTTL(), "platform")
+
+	return utils.Success(c, fiber.StatusOK, data)
+}
+
+// GetPlatformAlerts возвращает найденные аномалии метрик платформы, новые сначала
+func (ac *AnalyticsController) GetPlatformAlerts(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	if !utils.HasPermission(ac.db(c), userID, models.PermPlatformView) {
+		return utils.Forbidden(c, "Admin access required")
+	}
+
+	cursor, limit, err := utils.ParseCursorParams(c)
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cursor")
+	}
+
+	var alerts []models.PlatformAlert
+	query := ac.db(c).Model(&models.PlatformAlert{})
+	if metric := c.Query("metric"); metric != "" {
+		query = query.Where("metric = ?", metric)
+	}
+	if err := utils.ApplyCursor(query, "created_at", "id", cursor, limit).Find(&alerts).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch alerts")
+	}
+
+	alerts, next := utils.TrimPage(alerts, limit, func(alert models.PlatformAlert) (time.Time, uint) {
+		return alert.CreatedAt, alert.ID
 	})
+	return utils.PaginateCursor(c, alerts, next, limit)
 }
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errAnnouncementNotFound = errors.New("Announcement not found")
+
+type AnnouncementsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewAnnouncementsController(db *gorm.DB, cfg *config.Config) *AnnouncementsController {
+	return &AnnouncementsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *AnnouncementsController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+type announcementItem struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	RunID     *uint     `json:"run_id"`
+	AuthorID  uint      `json:"author_id"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetAnnouncements возвращает объявления курса, новые первыми. Студент видит объявления всего курса
+// и своего потока, преподаватели курса — все.
+func (ac *AnnouncementsController) GetAnnouncements(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	course, done, err := ac.course(c)
+	if done {
+		return err
+	}
+
+	query := ac.db(c).Model(&models.Announcement{}).Where("course_id = ?", course.ID)
+	if policy.Authorize(c, policy.ActionViewAnalytics, policy.Course(course)) != nil {
+		var progress models.UserCourseProgress
+		if err := ac.db(c).Where("user_id = ? AND course_id = ?", userID, course.ID).Limit(1).Find(&progress).Error; err != nil {
+			return utils.InternalServerError(c, "Failed to fetch announcements")
+		}
+		if progress.ID == 0 {
+			return utils.Forbidden(c, "Announcements are available to students of the course")
+		}
+		if progress.RunID != nil {
+			query = query.Where("run_id IS NULL OR run_id = ?", *progress.RunID)
+		} else {
+			query = query.Where("run_id IS NULL")
+		}
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// Counting on a session of its own leaves the filtered query untouched for the page itself
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch announcements")
+	}
+
+	var items []announcementItem
+	if err := query.Select("announcements.id, announcements.title, announcements.body, announcements.run_id, " +
+		"announcements.author_id, users.username AS author, announcements.created_at").
+		Joins("LEFT JOIN users ON users.id = announcements.author_id").
+		Order("announcements.created_at DESC, announcements.id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Scan(&items).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch announcements")
+	}
+	if items == nil {
+		items = []announcementItem{}
+	}
+	return utils.Paginate(c, items, total, page, pageSize)
+}
+
+// CreateAnnouncement публикует объявление и ставит в очередь письма записанным студентам
+// (всем или студентам одного потока), если они не отключили уведомления о курсах
+func (ac *AnnouncementsController) CreateAnnouncement(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	course, done, err := ac.editableCourse(c)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+		RunID *uint  `json:"run_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	errs := map[string]string{}
+	input.Title, input.Body = strings.TrimSpace(input.Title), strings.TrimSpace(input.Body)
+	if input.Title == "" {
+		errs["title"] = "Title is required"
+	}
+	if input.RunID != nil {
+		var runs int64
+		ac.db(c).Model(&models.CourseRun{}).Where("id = ? AND course_id = ?", *input.RunID, course.ID).Count(&runs)
+		if runs == 0 {
+			errs["run_id"] = errRunNotFound.Error()
+		}
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	announcement := models.Announcement{CourseID: course.ID, RunID: input.RunID, AuthorID: userID, Title: input.Title, Body: input.Body}
+	var notified int64
+	// The request queues one message, the relay writes an email to each learner who didn't opt out
+	err = ac.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&announcement).Error; err != nil {
+			return err
+		}
+
+		body := announcement.Body
+		if body == "" {
+			body = announcement.Title
+		}
+		fanout := outbox.Fanout{
+			CourseID:  course.ID,
+			RunID:     announcement.RunID,
+			ExcludeID: userID,
+			Category:  outbox.NotifyCourseUpdates,
+			Subject:   fmt.Sprintf("%s: %s", course.Title, announcement.Title),
+			Body:      body,
+		}
+		if err := tx.Table("(?) AS recipients", outbox.FanoutRecipients(tx, fanout)).Count(&notified).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueFanout(tx, fanout)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not publish announcement")
+	}
+
+	var author models.User
+	ac.db(c).Select("id", "username").Limit(1).Find(&author, userID)
+	return utils.Created(c, fiber.Map{
+		"announcement": announcementItem{
+			ID: announcement.ID, Title: announcement.Title, Body: announcement.Body, RunID: announcement.RunID,
+			AuthorID: userID, Author: author.Username, CreatedAt: announcement.CreatedAt,
+		},
+		"recipients": notified,
+	})
+}
+
+// DeleteAnnouncement снимает объявление; уже отправленные письма не отзываются
+func (ac *AnnouncementsController) DeleteAnnouncement(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, ac.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	course, done, err := ac.editableCourse(c)
+	if done {
+		return err
+	}
+
+	result := ac.db(c).Where("id = ? AND course_id = ?", c.Params("announcementId"), course.ID).Delete(&models.Announcement{})
+	switch {
+	case result.Error != nil:
+		return utils.InternalServerError(c, "Could not delete announcement")
+	case result.RowsAffected == 0:
+		return utils.NotFound(c, errAnnouncementNotFound.Error())
+	}
+	return utils.NoContent(c)
+}
+
+func (ac *AnnouncementsController) course(c *fiber.Ctx) (*models.Course, bool, error) {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := ac.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+	return &course, false, nil
+}
+
+func (ac *AnnouncementsController) editableCourse(c *fiber.Ctx) (*models.Course, bool, error) {
+	course, done, err := ac.course(c)
+	if done {
+		return nil, true, err
+	}
+
+	// Author, co-authors and university course editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to post announcements in this course"))
+	}
+	return course, false, nil
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ApiKeysController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewApiKeysController(db *gorm.DB, cfg *config.Config) *ApiKeysController {
+	return &ApiKeysController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (kc *ApiKeysController) db(c *fiber.Ctx) *gorm.DB {
+	return kc.DB.WithContext(c.UserContext())
+}
+
+// GetApiKeys возвращает API ключи пользователя (без самих ключей)
+func (kc *ApiKeysController) GetApiKeys(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, kc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var keys []models.ApiKey
+	if err := kc.db(c).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch API keys")
+	}
+
+	result := make([]fiber.Map, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, apiKeyResponse(key))
+	}
+
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// CreateApiKey создает новый API ключ. Ключ возвращается только один раз.
+func (kc *ApiKeysController) CreateApiKey(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, kc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Name          string `json:"name"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	if input.Name == "" {
+		return utils.ValidationError(c, map[string]string{"name": "Name is required"})
+	}
+
+	rawKey, prefix, hash, err := utils.GenerateAPIKey(kc.Cfg.Sandbox)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not generate API key")
+	}
+
+	key := models.ApiKey{
+		UserID:  userID,
+		Name:    input.Name,
+		Prefix:  prefix,
+		KeyHash: hash,
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := kc.db(c).Create(&key).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create API key")
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = rawKey
+	return utils.Created(c, response)
+}
+
+// UpdateApiKey переименовывает API ключ
+func (kc *ApiKeysController) UpdateApiKey(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, kc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	keyID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid API key ID")
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var key models.ApiKey
+	if err := kc.db(c).Where("id = ? AND user_id = ?", keyID, userID).First(&key).Error; err != nil {
+		return utils.NotFound(c, "API key not found")
+	}
+
+	if input.Name != "" {
+		key.Name = input.Name
+	}
+
+	if err := kc.db(c).Save(&key).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update API key")
+	}
+
+	return utils.Success(c, fiber.StatusOK, apiKeyResponse(key))
+}
+
+// DeleteApiKey отзывает API ключ
+func (kc *ApiKeysController) DeleteApiKey(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, kc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	keyID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid API key ID")
+	}
+
+	result := kc.db(c).Where("id = ? AND user_id = ?", keyID, userID).Delete(&models.ApiKey{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not delete API key")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "API key not found")
+	}
+
+	return utils.NoContent(c)
+}
+
+func apiKeyResponse(key models.ApiKey) fiber.Map {
+	return fiber.Map{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"created_at":   key.CreatedAt,
+		"last_used_at": key.LastUsedAt,
+		"expires_at":   key.ExpiresAt,
+	}
+}
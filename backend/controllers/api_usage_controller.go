@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/usage"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ApiUsageController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewApiUsageController(db *gorm.DB, cfg *config.Config) *ApiUsageController {
+	return &ApiUsageController{DB: db, Cfg: cfg}
+}
+
+// GetConsumers показывает самых активных потребителей API за последние ?days= дней (7 по умолчанию):
+// ?sort=requests|errors|rate_limited|peak, ?flagged=true — только с признаками злоупотребления, ?limit= (до 500).
+// В meta — действующие ограничения частоты, чтобы сравнить с пиковой нагрузкой клиентов.
+func (uc *ApiUsageController) GetConsumers(c *fiber.Ctx) error {
+	from, days, done, err := uc.period(c)
+	if done {
+		return err
+	}
+
+	sortBy := c.Query("sort", usage.SortRequests)
+	if !slices.Contains(usage.SortOrders, sortBy) {
+		return utils.ValidationError(c, map[string]string{"sort": "Sort must be one of " + strings.Join(usage.SortOrders, ", ")})
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	report, err := usage.Consumers(uc.DB.WithContext(c.UserContext()), from, sortBy, c.QueryBool("flagged"), limit)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to build API usage report")
+	}
+
+	return utils.Success(c, fiber.StatusOK, report, fiber.Map{
+		"days": days,
+		"sort": sortBy,
+		"rate_limits": fiber.Map{
+			"window_seconds": uc.Cfg.RateLimitWindowSeconds,
+			"max":            uc.Cfg.RateLimitMax,
+			"auth_max":       uc.Cfg.AuthRateLimitMax,
+			"write_max":      uc.Cfg.WriteRateLimitMax,
+		},
+	})
+}
+
+// GetEndpoints показывает маршруты по числу запросов за последние ?days= дней;
+// ?consumer= (например, api_key:12) — разбивка одного потребителя
+func (uc *ApiUsageController) GetEndpoints(c *fiber.Ctx) error {
+	from, days, done, err := uc.period(c)
+	if done {
+		return err
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "100"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	consumer := c.Query("consumer")
+	report, err := usage.Endpoints(uc.DB.WithContext(c.UserContext()), from, consumer, limit)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to build API usage report")
+	}
+
+	return utils.Success(c, fiber.StatusOK, report, fiber.Map{"days": days, "consumer": consumer})
+}
+
+// period разбирает ?days= и дописывает в базу запросы, накопленные этим экземпляром с последнего сброса
+func (uc *ApiUsageController) period(c *fiber.Ctx) (time.Time, int, bool, error) {
+	days, _ := strconv.Atoi(c.Query("days", "7"))
+	if days < 1 || days > 90 {
+		days = 7
+	}
+
+	if err := usage.Flush(c.UserContext(), uc.DB); err != nil {
+		return time.Time{}, 0, true, utils.InternalServerError(c, "Failed to record API usage")
+	}
+	return time.Now().AddDate(0, 0, -days), days, false, nil
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"project/backend/archive"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/storage"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxArchiveBatch ограничивает число пользователей, архивируемых одним запросом
+const maxArchiveBatch = 500
+
+type ArchivesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewArchivesController(db *gorm.DB, cfg *config.Config) *ArchivesController {
+	return &ArchivesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *ArchivesController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+// ArchiveInactiveUsers выгружает в холодное хранилище данные пользователей без входов дольше inactive_days.
+// С dry_run возвращает только список кандидатов.
+func (ac *ArchivesController) ArchiveInactiveUsers(c *fiber.Ctx) error {
+	var input struct {
+		InactiveDays int  `json:"inactive_days"`
+		Limit        int  `json:"limit"`
+		DryRun       bool `json:"dry_run"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	if input.InactiveDays <= 0 {
+		input.InactiveDays = ac.Cfg.ArchiveInactiveDays
+	}
+	if input.InactiveDays < 30 {
+		return utils.ValidationError(c, map[string]string{"inactive_days": "Must be at least 30 days"})
+	}
+	if input.Limit <= 0 || input.Limit > maxArchiveBatch {
+		input.Limit = maxArchiveBatch
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -input.InactiveDays)
+	candidates, err := archive.Candidates(ac.db(c), cutoff, input.Limit)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to find inactive users")
+	}
+	if input.DryRun {
+		return utils.Success(c, fiber.StatusOK, fiber.Map{"candidates": candidates, "archived": []uint{}})
+	}
+
+	archived := make([]uint, 0, len(candidates))
+	failed := fiber.Map{}
+	var archivedBytes int
+	for _, userID := range candidates {
+		record, err := archive.ArchiveUser(c.UserContext(), ac.DB, storage.Archive, userID)
+		if err != nil {
+			failed[strconv.Itoa(int(userID))] = err.Error()
+			continue
+		}
+		archived = append(archived, userID)
+		archivedBytes += record.Size
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"candidates": candidates,
+		"archived":   archived,
+		"bytes":      archivedBytes,
+	}, fiber.Map{"errors": failed})
+}
+
+// GetUserArchives возвращает историю выгрузок пользователя
+func (ac *ArchivesController) GetUserArchives(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	var archives []models.UserArchive
+	if err := ac.db(c).Where("user_id = ?", userID).Order("archived_at DESC").Find(&archives).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch archives")
+	}
+	return utils.Success(c, fiber.StatusOK, archives)
+}
+
+// RestoreUser возвращает данные пользователя из холодного хранилища
+func (ac *ArchivesController) RestoreUser(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	err = archive.RestoreUser(c.UserContext(), ac.DB, storage.Archive, uint(userID))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "User or archive not found")
+	case errors.Is(err, archive.ErrNotArchived):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		log.Printf("restore of archived user %d failed: %v", userID, err)
+		return utils.InternalServerError(c, "Could not restore user data")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"user_id": userID, "restored": true})
+}
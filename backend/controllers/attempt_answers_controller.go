@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errAttemptFinished = errors.New("The attempt is already finished")
+
+type AttemptAnswersController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewAttemptAnswersController(db *gorm.DB, cfg *config.Config) *AttemptAnswersController {
+	return &AttemptAnswersController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *AttemptAnswersController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+// SaveAnswer сохраняет ответ на вопрос в идущей попытке. Повторное сохранение заменяет ответ;
+// при сдаче попытки сохраненные ответы засчитываются, даже если клиент их не прислал.
+func (ac *AttemptAnswersController) SaveAnswer(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	attemptID, err := strconv.Atoi(c.Params("attemptId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid attempt ID")
+	}
+
+	var input struct {
+		Answer *int `json:"answer"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Answer == nil {
+		return utils.ValidationError(c, map[string]string{"answer": "Answer is required"})
+	}
+
+	question, attempt, err := attemptQuestion(c, ac.db(c), userID)
+	if err != nil {
+		return attemptError(c, err)
+	}
+	if attemptID != attempt {
+		return attemptError(c, errAttemptFinished)
+	}
+	// The clock of a timed question is checked by the answer endpoint, a draft would bypass it
+	if question.TimeLimitSeconds > 0 {
+		return utils.BadRequest(c, "Timed questions are answered through the answer endpoint")
+	}
+	if _, err := checkExamSubmission(ac.db(c), question.TestID, userID, c.IP()); err != nil {
+		return attemptError(c, err)
+	}
+
+	saved := models.AttemptAnswer{UserID: userID, TestID: question.TestID, QuestionID: question.ID, Attempt: attempt, Answer: *input.Answer}
+	if err := ac.db(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "question_id"}, {Name: "attempt"}},
+		DoUpdates: clause.AssignmentColumns([]string{"answer", "updated_at"}),
+	}).Create(&saved).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save answer")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"question_id": question.ID,
+		"attempt":     attempt,
+		"answer":      saved.Answer,
+		"saved_at":    saved.UpdatedAt,
+	})
+}
+
+// GetSavedAnswers возвращает ответы, сохраненные в попытке, чтобы продолжить ее после обрыва
+func (ac *AttemptAnswersController) GetSavedAnswers(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+	attemptID, err := strconv.Atoi(c.Params("attemptId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid attempt ID")
+	}
+
+	answers, err := savedAnswers(ac.db(c), uint(testID), userID, attemptID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch answers")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"attempt": attemptID,
+		"answers": answers,
+	})
+}
+
+// GetActiveAttempt возвращает незавершенную попытку пользователя: сохраненные ответы, выданные вопросы
+// с ограничением времени и, на экзамене, оставшееся время. По нему клиент восстанавливает попытку после
+// падения браузера; попытка на паузе возвращается вместе с токеном, чтобы ее можно было продолжить.
+func (ac *AttemptAnswersController) GetActiveAttempt(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var test models.Test
+	if err := ac.db(c).Preload("Questions").First(&test, testID).Error; err != nil {
+		return utils.NotFound(c, "Test not found")
+	}
+
+	var progress models.UserTestProgress
+	if err := ac.db(c).Where("user_id = ? AND test_id = ?", userID, test.ID).Limit(1).Find(&progress).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	attempt := progress.AttemptsUsed + 1
+
+	answers, err := savedAnswers(ac.db(c), test.ID, userID, attempt)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	var serves []models.QuestionServe
+	if err := ac.db(c).Where("user_id = ? AND test_id = ? AND attempt = ?", userID, test.ID, attempt).
+		Order("served_at").Find(&serves).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	exam, err := currentExamAttempt(ac.db(c), test.ID, userID)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	if len(answers) == 0 && len(serves) == 0 && exam == nil {
+		return utils.NotFound(c, "No unfinished attempt")
+	}
+
+	now := time.Now()
+	limits := make(map[uint]int, len(test.Questions))
+	for _, question := range test.Questions {
+		limits[question.ID] = question.TimeLimitSeconds
+	}
+	served := make([]fiber.Map, 0, len(serves))
+	for _, serve := range serves {
+		item := fiber.Map{
+			"question_id":        serve.QuestionID,
+			"served_at":          serve.ServedAt,
+			"answered":           serve.AnsweredAt != nil,
+			"time_limit_seconds": limits[serve.QuestionID],
+		}
+		if limit := limits[serve.QuestionID]; limit > 0 {
+			deadline := serve.Deadline(limit)
+			item["deadline"] = deadline
+			item["seconds_left"] = max(0, int(deadline.Sub(now).Seconds()))
+		}
+		served = append(served, item)
+	}
+
+	data := fiber.Map{
+		"attempt": attempt,
+		"answers": answers,
+		"served":  served,
+		"exam":    nil,
+	}
+	if exam != nil {
+		var session models.ExamSession
+		if err := ac.db(c).First(&session, exam.SessionID).Error; err != nil {
+			return utils.InternalServerError(c, "Could not query database")
+		}
+		deadline := exam.Deadline(session)
+		state := fiber.Map{
+			"session_id":     session.ID,
+			"status":         examAttemptStatus(*exam, session, now),
+			"started_at":     exam.StartedAt,
+			"deadline":       deadline,
+			"seconds_left":   max(0, int(deadline.Sub(now).Seconds())),
+			"paused_seconds": int(exam.PauseTime().Seconds()),
+		}
+		if exam.Paused(now) {
+			state["seconds_left"] = max(0, int(deadline.Sub(*exam.PauseEndsAt).Seconds()))
+			state["resume_by"] = exam.PauseEndsAt
+			state["resume_token"] = exam.ResumeToken
+		}
+		data["exam"] = state
+	}
+	return utils.Success(c, fiber.StatusOK, data)
+}
+
+type savedAnswer struct {
+	QuestionID uint      `json:"question_id"`
+	Answer     int       `json:"answer"`
+	SavedAt    time.Time `json:"saved_at"`
+}
+
+// savedAnswers возвращает ответы, сохраненные в попытке, по порядку вопросов
+func savedAnswers(db *gorm.DB, testID, userID uint, attempt int) ([]savedAnswer, error) {
+	var answers []savedAnswer
+	if err := db.Model(&models.AttemptAnswer{}).
+		Select("question_id, answer, updated_at AS saved_at").
+		Where("user_id = ? AND test_id = ? AND attempt = ?", userID, testID, attempt).
+		Order("question_id").
+		Scan(&answers).Error; err != nil {
+		return nil, err
+	}
+	if answers == nil {
+		answers = []savedAnswer{}
+	}
+	return answers, nil
+}
+
+// mergeSavedAnswers добавляет к присланным при сдаче ответам сохраненные по ходу попытки;
+// присланный ответ на тот же вопрос важнее сохраненного
+func mergeSavedAnswers(db *gorm.DB, testID, userID uint, attempt int, answers map[uint]int) error {
+	var saved []models.AttemptAnswer
+	if err := db.Where("user_id = ? AND test_id = ? AND attempt = ?", userID, testID, attempt).Find(&saved).Error; err != nil {
+		return err
+	}
+	for _, answer := range saved {
+		if _, ok := answers[answer.QuestionID]; !ok {
+			answers[answer.QuestionID] = answer.Answer
+		}
+	}
+	return nil
+}
@@ -2,12 +2,18 @@ package controllers
 
 import (
 	"errors"
+	"log"
+	"project/backend/archive"
 	"project/backend/config"
+	"project/backend/jobs"
+	"project/backend/metrics"
 	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/sandbox"
+	"project/backend/storage"
 	"project/backend/utils"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,6 +24,8 @@ import (
 type LoginRequest struct {
 	Username string `json:"username" example:"john_doe"`    // User's username
 	Password string `json:"password" example:"password123"` // User's password
+	// CAPTCHA token, required when CAPTCHA_PROVIDER is configured
+	CaptchaToken string `json:"captcha_token,omitempty" example:"03AGdBq24..."`
 }
 
 // LoginResponse represents successful login response
@@ -34,8 +42,9 @@ type LoginResponse struct {
 // ErrorResponse represents error response
 // @Description Standard error response format
 type ErrorResponse struct {
-	Error   string `json:"error" example:"Invalid credentials"`               // Error message
-	Message string `json:"message,omitempty" example:"Authentication failed"` // Additional message
+	Error   string `json:"error" example:"Unauthorized"`                    // HTTP status text
+	Code    string `json:"code,omitempty" example:"INVALID_CREDENTIALS"`    // Machine-readable error code
+	Message string `json:"message,omitempty" example:"Invalid credentials"` // Human-readable message
 }
 
 type AuthController struct {
@@ -47,6 +56,11 @@ func NewAuthController(db *gorm.DB, cfg *config.Config) *AuthController {
 	return &AuthController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *AuthController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
 func (ac *AuthController) Register(c *fiber.Ctx) error {
 	var user models.User
 	if err := c.BodyParser(&user); err != nil {
@@ -55,24 +69,84 @@ func (ac *AuthController) Register(c *fiber.Ctx) error {
 		})
 	}
 
+	// Optional invite code for restricted groups and the CAPTCHA token
+	var invite struct {
+		InviteCode   string `json:"invite_code"`
+		CaptchaToken string `json:"captcha_token"`
+	}
+	if err := c.BodyParser(&invite); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if ok, err := ac.verifyCaptcha(c, invite.CaptchaToken); !ok {
+		return err
+	}
+
+	// Affiliation is only verified through an institutional email, and the university must be in the directory
+	user.UniversityVerifiedAt, user.UniversityEmail = nil, ""
+	user.ArchivedAt = nil
+	universityName := utils.Optional[string]{Set: user.University != "", Value: user.University}
+	user.UniversityID, user.University = nil, ""
+	if _, err := applyUniversity(ac.db(c), utils.Optional[uint]{}, universityName, false, &user.UniversityID, &user.University); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.PasswordHash), bcrypt.DefaultCost)
+	hashedPassword, err := utils.Passwords.Hash(user.PasswordHash)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not hash password",
 		})
 	}
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
+
+	// Create user together with the welcome email (and accept the invitation, if any)
+	err = ac.db(c).Transaction(func(tx *gorm.DB) error {
+		var invitation *models.Invitation
+		if invite.InviteCode != "" {
+			var err error
+			if invitation, err = acceptInvitation(tx, invite.InviteCode, &user); err != nil {
+				return err
+			}
+		}
 
-	// Create user
-	if err := ac.DB.Create(&user).Error; err != nil {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		if invitation != nil {
+			if err := completeInvitation(tx, invitation, &user); err != nil {
+				return err
+			}
+		}
+
+		// Integrators try out the authoring endpoints too
+		if ac.Cfg.Sandbox {
+			if err := sandbox.GrantIntegratorRole(tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		return outbox.EnqueueEmail(tx, user.Email, "Welcome to Philosofium",
+			"Hello, "+user.Username+"! Your account has been created.")
+	})
+	if errors.Is(err, errInvitationInvalid) || errors.Is(err, errInvitationMismatch) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create user",
 		})
 	}
 
 	// Generate JWT token
-	token, err := utils.GenerateJWTToken(user.ID, ac.Cfg)
+	token, err := utils.GenerateJWTToken(&user, ac.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not generate token",
@@ -103,8 +177,9 @@ func (ac *AuthController) Register(c *fiber.Ctx) error {
 // @Router /auth/login [post]
 func (ac *AuthController) Login(c *fiber.Ctx) error {
 	type LoginInput struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username     string `json:"username"`
+		Password     string `json:"password"`
+		CaptchaToken string `json:"captcha_token"`
 	}
 
 	var input LoginInput
@@ -114,13 +189,16 @@ func (ac *AuthController) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := ac.verifyCaptcha(c, input.CaptchaToken); !ok {
+		return err
+	}
+
 	// Find user
 	var user models.User
-	if err := ac.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
+	if err := ac.db(c).Where("username = ?", input.Username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid credentials",
-			})
+			metrics.Inc(metrics.FailedLogins)
+			return utils.AuthFailure(c, utils.NewAuthError(utils.CodeInvalidCredentials, "Invalid credentials"))
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not query database",
@@ -128,51 +206,78 @@ func (ac *AuthController) Login(c *fiber.Ctx) error {
 	}
 
 	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid credentials",
-		})
+	if ok, _ := utils.Passwords.Verify(input.Password, user.PasswordHash); !ok {
+		metrics.Inc(metrics.FailedLogins)
+		if err := recordLogin(c, ac.db(c), ac.Cfg, user, false); err != nil {
+			log.Printf("recording failed login of user %d: %v", user.ID, err)
+		}
+		return utils.AuthFailure(c, utils.NewAuthError(utils.CodeInvalidCredentials, "Invalid credentials"))
+	}
+
+	if authErr := utils.AccountBlocked(&user); authErr != nil {
+		return utils.AuthFailure(c, authErr)
+	}
+
+	// A returning user gets their archived activity back before anything new is recorded
+	if user.ArchivedAt != nil {
+		if err := archive.RestoreUser(c.UserContext(), ac.DB, storage.Archive, user.ID); err != nil {
+			log.Printf("restore of archived user %d failed: %v", user.ID, err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Your account data is being restored, please try again later",
+			})
+		}
+	}
+
+	// Transparently upgrade legacy bcrypt hashes and outdated argon2 params
+	if utils.Passwords.NeedsRehash(user.PasswordHash) {
+		if hashed, err := utils.Passwords.Hash(input.Password); err == nil {
+			ac.db(c).Model(&user).UpdateColumn("password_hash", hashed)
+		}
 	}
 
 	// Generate JWT token
-	token, err := utils.GenerateJWTToken(user.ID, ac.Cfg)
+	token, err := utils.GenerateJWTToken(&user, ac.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not generate token",
 		})
 	}
 
-	// Update login history
-	loginHistory := models.LoginHistory{
-		UserID:    user.ID,
-		LoginTime: time.Now(),
+	// Update login history; a new device or country is reported to the owner by email
+	if err := recordLogin(c, ac.db(c), ac.Cfg, user, true); err != nil {
+		log.Printf("recording login of user %d: %v", user.ID, err)
 	}
-	ac.DB.Create(&loginHistory)
 
-	// Update user progress streak
-	var userProgress models.UserProgress
-	if err := ac.DB.Where("user_id = ?", user.ID).First(&userProgress).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			userProgress = models.UserProgress{
+	// Update user progress streak (locked per user so parallel logins on
+	// different instances don't double-count)
+	err = ac.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := jobs.LockEntity(tx, "streak", user.ID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var userProgress models.UserProgress
+		if err := tx.Where("user_id = ?", user.ID).First(&userProgress).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			return tx.Create(&models.UserProgress{
 				UserID:     user.ID,
-				LastActive: time.Now(),
+				LastActive: now,
 				StreakDays: 1,
-			}
-			ac.DB.Create(&userProgress)
-		} else {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Could not query database",
-			})
-		}
-	} else {
-		// Check if last active was yesterday to maintain streak
-		if time.Since(userProgress.LastActive) < 48*time.Hour {
-			userProgress.StreakDays++
-		} else {
-			userProgress.StreakDays = 1
+			}).Error
 		}
-		userProgress.LastActive = time.Now()
-		ac.DB.Save(&userProgress)
+
+		// Days are counted in the user's time zone: a login on the next
+		// local calendar day extends the streak, the same day keeps it
+		userProgress.StreakDays = utils.NextStreak(userProgress, now, utils.UserLocation(tx, user.ID))
+		userProgress.LastActive = now
+		return tx.Save(&userProgress).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
 	}
 
 	return c.JSON(fiber.Map{
@@ -184,3 +289,26 @@ func (ac *AuthController) Login(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// verifyCaptcha проверяет CAPTCHA из тела запроса или заголовка X-Captcha-Token.
+// Если проверка не пройдена, ответ с ошибкой уже отправлен и возвращается false.
+func (ac *AuthController) verifyCaptcha(c *fiber.Ctx, token string) (bool, error) {
+	if token == "" {
+		token = c.Get("X-Captcha-Token")
+	}
+
+	err := utils.VerifyCaptcha(c.UserContext(), ac.Cfg, token, c.IP())
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, utils.ErrCaptchaMissing) || errors.Is(err, utils.ErrCaptchaFailed) {
+		return false, utils.AuthFailure(c, &utils.AuthError{
+			Status:  fiber.StatusBadRequest,
+			Code:    utils.CodeCaptchaFailed,
+			Message: err.Error(),
+		})
+	}
+	return false, c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": "Could not verify CAPTCHA",
+	})
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AuthorsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewAuthorsController(db *gorm.DB, cfg *config.Config) *AuthorsController {
+	return &AuthorsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *AuthorsController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+// GetAuthorProfile возвращает публичную страницу автора: данные без email (с учетом настроек
+// приватности), опубликованные курсы и тесты, средний рейтинг и число подписчиков
+func (ac *AuthorsController) GetAuthorProfile(c *fiber.Ctx) error {
+	viewerID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	authorID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	db := ac.db(c)
+
+	var author models.User
+	if err := db.Select("id", "username", "group", "study_group_id", "university", "university_id",
+		"university_verified_at", "avatar_url", "created_at", "active").
+		First(&author, authorID).Error; err != nil || !author.Active {
+		return utils.NotFound(c, "User not found")
+	}
+
+	// The author themself and user admins see the page without privacy filtering
+	var settings models.UserSettings
+	if author.ID != viewerID && !utils.HasPermission(db, viewerID, models.PermUsersManage) {
+		if settings, err = utils.LoadUserSettings(db, author.ID); err != nil {
+			return utils.InternalServerError(c, "Failed to fetch settings")
+		}
+	}
+
+	type item struct {
+		ID          uint    `json:"id"`
+		Title       string  `json:"title"`
+		ShortDesc   string  `json:"short_desc"`
+		Difficulty  string  `json:"difficulty"`
+		Topic       string  `json:"topic"`
+		LogoURL     string  `json:"logo_url"`
+		Rating      float64 `json:"rating"`
+		RatingCount int64   `json:"rating_count"`
+	}
+
+	var courses []item
+	if err := db.Model(&models.Course{}).
+		Select(`courses.id, courses.title, courses.short_desc, courses.difficulty, courses.topic, courses.logo_url,
+			COALESCE((SELECT AVG(rating) FROM course_reviews WHERE course_id = courses.id AND deleted_at IS NULL), 0) AS rating,
+			(SELECT COUNT(*) FROM course_reviews WHERE course_id = courses.id AND deleted_at IS NULL) AS rating_count`).
+		Where("courses.author_id = ?", author.ID).
+		Where("courses.id IN (SELECT course_id FROM course_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Order("courses.created_at DESC").
+		Scan(&courses).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch author courses")
+	}
+
+	var tests []item
+	if err := db.Model(&models.Test{}).
+		Select(`tests.id, tests.title, tests.short_desc, tests.difficulty, tests.topic, tests.logo_url,
+			COALESCE((SELECT AVG(rating) FROM test_comments WHERE test_id = tests.id AND rating > 0 AND deleted_at IS NULL), 0) AS rating,
+			(SELECT COUNT(*) FROM test_comments WHERE test_id = tests.id AND rating > 0 AND deleted_at IS NULL) AS rating_count`).
+		Where("tests.author_id = ?", author.ID).
+		Where("tests.id IN (SELECT test_id FROM test_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Order("tests.created_at DESC").
+		Scan(&tests).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch author tests")
+	}
+
+	// Overall rating is weighted by the number of ratings, not averaged per course
+	var ratingSum float64
+	var ratingCount int64
+	for _, list := range [][]item{courses, tests} {
+		for _, it := range list {
+			ratingSum += it.Rating * float64(it.RatingCount)
+			ratingCount += it.RatingCount
+		}
+	}
+	var rating float64
+	if ratingCount > 0 {
+		rating = ratingSum / float64(ratingCount)
+	}
+
+	var followers int64
+	db.Model(&models.UserFollow{}).Where("author_id = ?", author.ID).Count(&followers)
+
+	var following int64
+	db.Model(&models.UserFollow{}).Where("author_id = ? AND follower_id = ?", author.ID, viewerID).Count(&following)
+
+	// Published courses and tests stay listed on a private profile, personal details don't
+	profile := applyPrivacy(fiber.Map{
+		"id":                  author.ID,
+		"username":            author.Username,
+		"group":               author.Group,
+		"group_id":            author.StudyGroupID,
+		"university":          author.University,
+		"university_id":       author.UniversityID,
+		"university_verified": author.UniversityVerifiedAt != nil,
+		"avatar_url":          author.AvatarURL,
+		"member_since":        author.CreatedAt,
+	}, settings)
+	profile["courses"] = courses
+	profile["tests"] = tests
+	profile["rating"] = rating
+	profile["rating_count"] = ratingCount
+	profile["followers"] = followers
+	profile["is_following"] = following > 0
+	return utils.Success(c, fiber.StatusOK, profile)
+}
+
+// FollowAuthor подписывает текущего пользователя на автора
+func (ac *AuthorsController) FollowAuthor(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	authorID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+	if uint(authorID) == userID {
+		return utils.BadRequest(c, "You can't follow yourself")
+	}
+
+	var author models.User
+	if err := ac.db(c).Select("id").First(&author, authorID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	follow := models.UserFollow{FollowerID: userID, AuthorID: author.ID}
+	if err := ac.db(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&follow).Error; err != nil {
+		return utils.InternalServerError(c, "Could not follow user")
+	}
+
+	return utils.NoContent(c)
+}
+
+// UnfollowAuthor отменяет подписку на автора
+func (ac *AuthorsController) UnfollowAuthor(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ac.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	authorID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	// Hard delete so the unique (follower, author) pair can be created again
+	if err := ac.db(c).Unscoped().
+		Where("follower_id = ? AND author_id = ?", userID, authorID).
+		Delete(&models.UserFollow{}).Error; err != nil {
+		return utils.InternalServerError(c, "Could not unfollow user")
+	}
+
+	return utils.NoContent(c)
+}
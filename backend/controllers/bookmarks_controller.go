@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Виды закладок, совпадают с TargetType в ленте активности
+const (
+	bookmarkCourses = "courses"
+	bookmarkTests   = "tests"
+)
+
+type BookmarksController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewBookmarksController(db *gorm.DB, cfg *config.Config) *BookmarksController {
+	return &BookmarksController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (bc *BookmarksController) db(c *fiber.Ctx) *gorm.DB {
+	return bc.DB.WithContext(c.UserContext())
+}
+
+type bookmarkItem struct {
+	Type         string    `json:"type"`
+	ID           uint      `json:"id"`
+	Title        string    `json:"title"`
+	ShortDesc    string    `json:"short_desc"`
+	Difficulty   string    `json:"difficulty"`
+	LogoURL      string    `json:"logo_url"`
+	Enrolled     bool      `json:"enrolled"` // course started or test attempted
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+// BookmarkCourse сохраняет курс в закладки; закрытый курс можно сохранить, только если он доступен пользователю
+func (bc *BookmarksController) BookmarkCourse(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, bc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := bc.db(c).Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := restrictedCourseAccess(c, &course); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "This course is open only to users on its access list"))
+	}
+
+	return bc.save(c, userID, bookmarkCourses, course.ID)
+}
+
+// UnbookmarkCourse убирает курс из закладок
+func (bc *BookmarksController) UnbookmarkCourse(c *fiber.Ctx) error {
+	return bc.remove(c, bookmarkCourses, "Invalid course ID")
+}
+
+// BookmarkTest сохраняет тест в закладки
+func (bc *BookmarksController) BookmarkTest(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, bc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var test models.Test
+	if err := bc.db(c).Select("id").First(&test, testID).Error; err != nil {
+		return utils.NotFound(c, "Test not found")
+	}
+
+	return bc.save(c, userID, bookmarkTests, test.ID)
+}
+
+// UnbookmarkTest убирает тест из закладок
+func (bc *BookmarksController) UnbookmarkTest(c *fiber.Ctx) error {
+	return bc.remove(c, bookmarkTests, "Invalid test ID")
+}
+
+// GetBookmarks возвращает закладки пользователя, новые первыми; ?type=courses|tests оставляет один вид.
+// Удаленные курсы и тесты пропускаются.
+func (bc *BookmarksController) GetBookmarks(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, bc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	kind := c.Query("type")
+	if kind != "" && kind != bookmarkCourses && kind != bookmarkTests {
+		return utils.BadRequest(c, "Type must be courses or tests")
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// Only bookmarks whose course or test still exists are listed and counted
+	query := bc.db(c).Table("bookmarks AS b").
+		Joins("LEFT JOIN courses ON b.target_type = ? AND courses.id = b.target_id AND courses.deleted_at IS NULL", bookmarkCourses).
+		Joins("LEFT JOIN tests ON b.target_type = ? AND tests.id = b.target_id AND tests.deleted_at IS NULL", bookmarkTests).
+		Where("b.user_id = ? AND b.deleted_at IS NULL", userID).
+		Where("courses.id IS NOT NULL OR tests.id IS NOT NULL")
+	if kind != "" {
+		query = query.Where("b.target_type = ?", kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch bookmarks")
+	}
+
+	var items []bookmarkItem
+	if err := query.Select(`b.target_type AS type, b.target_id AS id, b.created_at AS bookmarked_at,
+			COALESCE(courses.title, tests.title) AS title,
+			COALESCE(courses.short_desc, tests.short_desc) AS short_desc,
+			COALESCE(courses.difficulty, tests.difficulty) AS difficulty,
+			COALESCE(courses.logo_url, tests.logo_url) AS logo_url,
+			CASE WHEN b.target_type = ? THEN EXISTS (SELECT 1 FROM user_course_progress p
+				WHERE p.user_id = b.user_id AND p.course_id = b.target_id AND p.deleted_at IS NULL)
+			ELSE EXISTS (SELECT 1 FROM user_test_progress p
+				WHERE p.user_id = b.user_id AND p.test_id = b.target_id AND p.deleted_at IS NULL) END AS enrolled`, bookmarkCourses).
+		Order("b.created_at DESC, b.id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Scan(&items).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch bookmarks")
+	}
+	if items == nil {
+		items = []bookmarkItem{}
+	}
+	return utils.Paginate(c, items, total, page, pageSize)
+}
+
+func (bc *BookmarksController) save(c *fiber.Ctx, userID uint, kind string, targetID uint) error {
+	bookmark := models.Bookmark{UserID: userID, TargetType: kind, TargetID: targetID}
+	if err := bc.db(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&bookmark).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save bookmark")
+	}
+	return utils.NoContent(c)
+}
+
+func (bc *BookmarksController) remove(c *fiber.Ctx, kind, invalidID string) error {
+	userID, err := utils.ExtractUserIDFromToken(c, bc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	targetID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, invalidID)
+	}
+
+	// Hard delete so the unique (user, type, id) bookmark can be created again
+	if err := bc.db(c).Unscoped().
+		Where("user_id = ? AND target_type = ? AND target_id = ?", userID, kind, targetID).
+		Delete(&models.Bookmark{}).Error; err != nil {
+		return utils.InternalServerError(c, "Could not remove bookmark")
+	}
+	return utils.NoContent(c)
+}
+
+// isBookmarked сообщает, сохранен ли курс или тест в закладках пользователя
+func isBookmarked(db *gorm.DB, userID uint, kind string, targetID uint) bool {
+	var count int64
+	db.Model(&models.Bookmark{}).Where("user_id = ? AND target_type = ? AND target_id = ?", userID, kind, targetID).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"log"
+	"project/backend/config"
+	"project/backend/utils"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// bootstrapPageSize — сколько курсов и тестов отдается на стартовом экране
+const bootstrapPageSize = 10
+
+type BootstrapController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewBootstrapController(db *gorm.DB, cfg *config.Config) *BootstrapController {
+	return &BootstrapController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (bc *BootstrapController) db(c *fiber.Ctx) *gorm.DB {
+	return bc.DB.WithContext(c.UserContext())
+}
+
+// GetBootstrap возвращает все данные стартового экрана за один запрос.
+// Разделы собираются параллельно; ошибка одного раздела не ломает остальные:
+// она пишется в лог, а в meta.errors попадает только постоянное сообщение раздела.
+func (bc *BootstrapController) GetBootstrap(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, bc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	db := bc.db(c)
+	sections := map[string]func() (interface{}, error){
+		"profile": func() (interface{}, error) {
+			return loadProfile(db, userID)
+		},
+		"overview": func() (interface{}, error) {
+			return loadOverview(db, userID)
+		},
+		"settings": func() (interface{}, error) {
+			settings, err := utils.LoadUserSettings(db, userID)
+			return settingsPayload(settings), err
+		},
+		"progress": func() (interface{}, error) {
+			return loadProgressOverview(db, userID), nil
+		},
+		"courses": func() (interface{}, error) {
+			courses, total, err := listUserCourses(db, userID, "in_progress", "", 1, bootstrapPageSize)
+			return fiber.Map{"items": courses, "total": total}, err
+		},
+		"tests": func() (interface{}, error) {
+			tests, total, err := listUserTests(db, userID, "all", "", 1, bootstrapPageSize)
+			return fiber.Map{"items": tests, "total": total}, err
+		},
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		data   = fiber.Map{}
+		failed = fiber.Map{}
+	)
+	for name, load := range sections {
+		wg.Add(1)
+		go func(name string, load func() (interface{}, error)) {
+			defer wg.Done()
+			result, err := load()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// Driver errors may carry SQL and table names, the client only learns which section failed
+				log.Printf("bootstrap section %s of user %d failed: %v", name, userID, err)
+				failed[name] = "Could not load " + name
+				data[name] = nil
+				return
+			}
+			data[name] = result
+		}(name, load)
+	}
+	wg.Wait()
+
+	return utils.Success(c, fiber.StatusOK, data, fiber.Map{"errors": failed})
+}
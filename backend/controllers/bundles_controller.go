@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/backend/bundle"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/storage"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// MaxBundleBytes ограничивает JSON-бандл или архив курса при импорте
+const MaxBundleBytes = 64 << 20
+
+// Форматы выгрузки курса
+const (
+	bundleFormatJSON     = "json"
+	bundleFormatMarkdown = "markdown"
+)
+
+type BundlesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewBundlesController(db *gorm.DB, cfg *config.Config) *BundlesController {
+	return &BundlesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (bc *BundlesController) db(c *fiber.Ctx) *gorm.DB {
+	return bc.DB.WithContext(c.UserContext())
+}
+
+// ExportCourse отдает структуру, уроки и файлы уроков курса для резервной копии или переноса на другой экземпляр:
+// ?format=json (по умолчанию) — JSON-бандл, ?format=markdown — ZIP с course.json и уроками в Markdown.
+func (bc *BundlesController) ExportCourse(c *fiber.Ctx) error {
+	format := c.Query("format", bundleFormatJSON)
+	if format != bundleFormatJSON && format != bundleFormatMarkdown {
+		return utils.BadRequest(c, "Format must be json or markdown")
+	}
+
+	if _, err := utils.ExtractUserIDFromToken(c, bc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := bc.db(c).First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	// The bundle holds drafts and unpublished lessons, so it's for the people who edit the course
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to export this course"))
+	}
+
+	b, err := bundle.BuildCourse(bc.db(c), course.ID)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not build course bundle")
+	}
+	// Lesson files travel inside the bundle
+	for i := range b.Lessons {
+		for j := range b.Lessons[i].Attachments {
+			attachment := &b.Lessons[i].Attachments[j]
+			if attachment.Data, err = storage.Default.Get(c.UserContext(), attachment.Key); err != nil {
+				return utils.InternalServerError(c, "Could not read lesson attachments")
+			}
+		}
+	}
+
+	if format == bundleFormatJSON {
+		data, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return utils.InternalServerError(c, "Could not build course bundle")
+		}
+		c.Attachment(fmt.Sprintf("course_%d.json", course.ID))
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+		return c.Send(data)
+	}
+
+	var archive bytes.Buffer
+	if err := bundle.WriteMarkdown(&archive, b); err != nil {
+		return utils.InternalServerError(c, "Could not build course bundle")
+	}
+	c.Attachment(fmt.Sprintf("course_%d.zip", course.ID))
+	c.Set(fiber.HeaderContentType, "application/zip")
+	return c.Send(archive.Bytes())
+}
+
+// ImportCourse создает курс из выгрузки ExportCourse: JSON-бандл или ZIP-архив в теле запроса
+// или в поле file формы. Курс создается черновиком с приватным доступом в университете импортирующего.
+func (bc *BundlesController) ImportCourse(c *fiber.Ctx) error {
+	user, done, err := bc.importer(c)
+	if done {
+		return err
+	}
+
+	data, err := readUpload(c, MaxBundleBytes)
+	if err != nil {
+		return utils.BadRequest(c, err.Error())
+	}
+
+	var b *bundle.Course
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if b, err = bundle.ReadMarkdown(data); err != nil {
+			return utils.BadRequest(c, err.Error())
+		}
+	} else if err := json.Unmarshal(data, &b); err != nil || b == nil {
+		return utils.BadRequest(c, "Expected a course bundle as JSON or a ZIP archive")
+	}
+	if b.Title == "" {
+		return utils.ValidationError(c, map[string]string{"title": "The bundle has no course title"})
+	}
+
+	course, err := bc.createCourse(c, user, b)
+	if errors.Is(err, bundle.ErrUnsupportedVersion) {
+		return utils.Error(c, fiber.StatusConflict, err)
+	}
+	if errors.Is(err, bundle.ErrInvalidBlock) || errors.Is(err, bundle.ErrInvalidAttachment) {
+		return utils.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not import course")
+	}
+
+	return utils.Created(c, fiber.Map{
+		"id":      course.ID,
+		"title":   course.Title,
+		"status":  course.Status,
+		"modules": len(b.Modules),
+		"lessons": len(b.Lessons),
+	})
+}
+
+// ImportPackage создает курс из пакета SCORM 1.2/2004 или xAPI (ZIP в теле запроса или в поле file формы).
+// Медиафайлы пакета сохраняются в хранилище, скрипты и среда выполнения SCORM не переносятся:
+// skipped в ответе перечисляет файлы, на которые ссылались уроки, но которые остались в пакете.
+func (bc *BundlesController) ImportPackage(c *fiber.Ctx) error {
+	user, done, err := bc.importer(c)
+	if done {
+		return err
+	}
+
+	maxBytes := int64(bc.Cfg.PackageMaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+	data, err := readUpload(c, maxBytes)
+	if err != nil {
+		return utils.BadRequest(c, err.Error())
+	}
+
+	// Assets go under one prefix per import, so a failed import can be cleaned up
+	prefix := fmt.Sprintf("packages/%d-%d", user.ID, time.Now().UnixNano())
+	var keys []string
+	cleanup := func() {
+		for _, key := range keys {
+			storage.Default.Delete(c.UserContext(), key)
+		}
+	}
+	pkg, err := bundle.ReadPackage(data, func(name string, data []byte, contentType string) (string, error) {
+		key := prefix + "/" + name
+		url, err := storage.Default.Put(c.UserContext(), key, data, contentType)
+		if err == nil {
+			keys = append(keys, key)
+		}
+		return url, err
+	})
+	if errors.Is(err, bundle.ErrNotPackage) || errors.Is(err, bundle.ErrInvalidPackage) {
+		cleanup()
+		return utils.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		cleanup()
+		return utils.InternalServerError(c, "Could not store package files")
+	}
+	if pkg.Course.Title == "" {
+		pkg.Course.Title = "Imported course"
+	}
+
+	course, err := bc.createCourse(c, user, pkg.Course)
+	if err != nil {
+		cleanup()
+		return utils.InternalServerError(c, "Could not import course")
+	}
+
+	return utils.Created(c, fiber.Map{
+		"id":       course.ID,
+		"title":    course.Title,
+		"status":   course.Status,
+		"standard": pkg.Standard,
+		"modules":  len(pkg.Course.Modules),
+		"lessons":  len(pkg.Course.Lessons),
+		"assets":   pkg.Assets,
+		"skipped":  pkg.Skipped,
+	})
+}
+
+// importer загружает импортирующего и проверяет право создавать курсы в его университете
+func (bc *BundlesController) importer(c *fiber.Ctx) (*models.User, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, bc.Cfg)
+	if err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var user models.User
+	if err := bc.db(c).First(&user, userID).Error; err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+	if !utils.HasScopedPermission(bc.db(c), userID, models.PermCoursesCreate, user.University) {
+		return nil, true, utils.Forbidden(c, "You don't have permission to create courses")
+	}
+	return &user, false, nil
+}
+
+// createCourse создает из бандла черновик с приватным доступом в университете импортирующего
+func (bc *BundlesController) createCourse(c *fiber.Ctx, user *models.User, b *bundle.Course) (*models.Course, error) {
+	// Topic IDs differ between instances: keep the ID only if it names the same topic here, otherwise match by name
+	if b.TopicID != nil || b.Topic != "" {
+		var topic models.Topic
+		bc.db(c).Where("id = ? AND name = ?", b.TopicID, b.Topic).Limit(1).Find(&topic)
+		if topic.ID == 0 && b.Topic != "" {
+			bc.db(c).Where("name = ?", b.Topic).Order("id").Limit(1).Find(&topic)
+		}
+		b.TopicID = nil
+		if topic.ID != 0 {
+			b.TopicID = &topic.ID
+		}
+	}
+
+	course := models.Course{
+		AuthorID:          user.ID,
+		UniversityID:      user.UniversityID,
+		University:        user.University,
+		License:           b.License,
+		Attribution:       b.Attribution,
+		SourceLicense:     b.SourceLicense,
+		SourceAttribution: b.SourceAttribution,
+	}
+	if course.License == "" {
+		course.License = models.LicenseProprietary
+	}
+	// Lesson files are stored before the transaction commits and removed if it rolls back
+	var keys []string
+	maxBytes := bc.Cfg.AttachmentMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 50 << 20
+	}
+	store := func(lessonID uint, attachment *bundle.Attachment) (string, error) {
+		attachment.FileName = attachmentFileName(attachment.FileName)
+		if len(attachment.Data) > maxBytes {
+			return "", fmt.Errorf("%w: %s is too large", bundle.ErrInvalidAttachment, attachment.FileName)
+		}
+		contentType, err := utils.DetectAttachment(attachment.FileName, attachment.Data)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", bundle.ErrInvalidAttachment, attachment.FileName, err)
+		}
+		attachment.ContentType = contentType
+		key, err := storeAttachment(c.UserContext(), lessonID, attachment.FileName, attachment.Data, contentType)
+		if err == nil {
+			keys = append(keys, key)
+		}
+		return key, err
+	}
+
+	err := bc.db(c).Transaction(func(tx *gorm.DB) error {
+		return bundle.ImportCourse(tx, b, &course, store)
+	})
+	if err != nil {
+		for _, key := range keys {
+			storage.Default.Delete(c.UserContext(), key)
+		}
+	}
+	return &course, err
+}
+
+// readUpload читает тело запроса или файл из поля file формы, не больше maxBytes
+func readUpload(c *fiber.Ctx, maxBytes int64) ([]byte, error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		data := c.Body()
+		if int64(len(data)) > maxBytes {
+			return nil, errors.New("The upload is too large")
+		}
+		return data, nil
+	}
+	if file.Size > maxBytes {
+		return nil, errors.New("The upload is too large")
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, errors.New("Cannot read uploaded file")
+	}
+	defer f.Close()
+
+	data, tooLarge, err := utils.ReadLimited(f, maxBytes)
+	if err != nil {
+		return nil, errors.New("Cannot read uploaded file")
+	}
+	if tooLarge {
+		return nil, errors.New("The upload is too large")
+	}
+	return data, nil
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CampaignsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewCampaignsController(db *gorm.DB, cfg *config.Config) *CampaignsController {
+	return &CampaignsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *CampaignsController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
+type campaignInput struct {
+	Name         utils.Optional[string]  `json:"name"`
+	Trigger      utils.Optional[string]  `json:"trigger"`
+	InactiveDays utils.Optional[int]     `json:"inactive_days"`
+	ScoreBelow   utils.Optional[float64] `json:"score_below"`
+	Subject      utils.Optional[string]  `json:"subject"`
+	Body         utils.Optional[string]  `json:"body"`
+	Enabled      utils.Optional[bool]    `json:"enabled"`
+}
+
+// apply переносит поля в кампанию; merge = true для PATCH
+func (input campaignInput) apply(campaign *models.Campaign, merge bool) map[string]string {
+	errs := map[string]string{}
+	input.Name.Apply(&campaign.Name, merge)
+	campaign.Name = strings.TrimSpace(campaign.Name)
+	if campaign.Name == "" {
+		errs["name"] = "Name is required"
+	}
+
+	input.Trigger.Apply(&campaign.Trigger, merge)
+	if !slices.Contains(models.CampaignTriggers, campaign.Trigger) {
+		errs["trigger"] = "Trigger must be one of " + strings.Join(models.CampaignTriggers, ", ")
+	}
+	input.InactiveDays.Apply(&campaign.InactiveDays, merge)
+	if campaign.Trigger == models.CampaignInactive && (campaign.InactiveDays < 1 || campaign.InactiveDays > 365) {
+		errs["inactive_days"] = "Inactive days must be between 1 and 365"
+	}
+	input.ScoreBelow.Apply(&campaign.ScoreBelow, merge)
+	if campaign.Trigger == models.CampaignTestFailed && (campaign.ScoreBelow <= 0 || campaign.ScoreBelow > 100) {
+		errs["score_below"] = "Score below must be a percentage above 0"
+	}
+
+	input.Subject.Apply(&campaign.Subject, merge)
+	input.Body.Apply(&campaign.Body, merge)
+	if len(campaign.Subject) > 255 {
+		errs["subject"] = "Subject must be at most 255 characters"
+	}
+	// Campaigns are created enabled, only an explicit false pauses them
+	if input.Enabled.Set && !input.Enabled.Null {
+		campaign.Enabled = input.Enabled.Value
+	}
+	return errs
+}
+
+// campaignStats — отправки кампании: сколько ушло, сколько студентов вернулись или сдали тест,
+// сколько отправок еще в пределах срока, за который это засчитывается
+type campaignStats struct {
+	CampaignID uint       `json:"-"`
+	Sent       int64      `json:"sent"`
+	Converted  int64      `json:"converted"`
+	Pending    int64      `json:"pending"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+}
+
+// GetCampaigns возвращает кампании напоминаний с результатами отправок
+func (cc *CampaignsController) GetCampaigns(c *fiber.Ctx) error {
+	var campaigns []models.Campaign
+	if err := cc.db(c).Order("id").Find(&campaigns).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch campaigns")
+	}
+
+	var stats []campaignStats
+	if err := cc.statsQuery(c).Group("campaign_id").Scan(&stats).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch campaign stats")
+	}
+
+	result := make([]fiber.Map, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		var s campaignStats
+		if i := slices.IndexFunc(stats, func(s campaignStats) bool { return s.CampaignID == campaign.ID }); i >= 0 {
+			s = stats[i]
+		}
+		result = append(result, campaignPayload(campaign, s))
+	}
+	return utils.Success(c, fiber.StatusOK, result, fiber.Map{
+		"triggers":          models.CampaignTriggers,
+		"placeholders":      jobs.CampaignPlaceholders,
+		"conversion_window": jobs.CampaignConversionWindow.String(),
+	})
+}
+
+// CreateCampaign создает кампанию; пустые subject и body означают тексты по умолчанию для условия.
+// Без APP_URL кампании не создаются и не включаются: ссылкам в письмах нужен адрес сайта.
+func (cc *CampaignsController) CreateCampaign(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input campaignInput
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	if cc.Cfg.AppURL == "" {
+		return utils.Error(c, fiber.StatusServiceUnavailable, jobs.ErrCampaignsNeedAppURL)
+	}
+
+	campaign := models.Campaign{Enabled: true, CreatedByID: userID}
+	if errs := input.apply(&campaign, false); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+	if err := cc.db(c).Create(&campaign).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create campaign")
+	}
+	return utils.Created(c, campaignPayload(campaign, campaignStats{}))
+}
+
+// UpdateCampaign изменяет кампанию (PUT и PATCH); enabled = false приостанавливает отправку
+func (cc *CampaignsController) UpdateCampaign(c *fiber.Ctx) error {
+	campaign, done, err := cc.findCampaign(c)
+	if done {
+		return err
+	}
+
+	var input campaignInput
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if errs := input.apply(campaign, utils.IsMergePatch(c)); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+	if campaign.Enabled && cc.Cfg.AppURL == "" {
+		return utils.Error(c, fiber.StatusServiceUnavailable, jobs.ErrCampaignsNeedAppURL)
+	}
+	if err := cc.db(c).Save(campaign).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update campaign")
+	}
+	return utils.Success(c, fiber.StatusOK, campaignPayload(*campaign, campaignStats{}))
+}
+
+// DeleteCampaign удаляет кампанию; история отправок остается
+func (cc *CampaignsController) DeleteCampaign(c *fiber.Ctx) error {
+	campaign, done, err := cc.findCampaign(c)
+	if done {
+		return err
+	}
+	if err := cc.db(c).Delete(campaign).Error; err != nil {
+		return utils.InternalServerError(c, "Could not delete campaign")
+	}
+	return utils.NoContent(c)
+}
+
+// GetCampaignStats показывает отправки и возвраты кампании по дням за последние ?days= дней (30 по умолчанию)
+func (cc *CampaignsController) GetCampaignStats(c *fiber.Ctx) error {
+	campaign, done, err := cc.findCampaign(c)
+	if done {
+		return err
+	}
+
+	days, _ := strconv.Atoi(c.Query("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+
+	var totals campaignStats
+	if err := cc.statsQuery(c).Where("campaign_id = ?", campaign.ID).Group("campaign_id").Scan(&totals).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch campaign stats")
+	}
+
+	var daily []struct {
+		Day       time.Time `json:"day"`
+		Sent      int64     `json:"sent"`
+		Converted int64     `json:"converted"`
+	}
+	err = cc.db(c).Model(&models.CampaignDelivery{}).
+		Select("DATE(sent_at) AS day, COUNT(*) AS sent, COUNT(converted_at) AS converted").
+		Where("campaign_id = ? AND sent_at >= ?", campaign.ID, from).
+		Group("DATE(sent_at)").Order("day").
+		Scan(&daily).Error
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch campaign stats")
+	}
+
+	payload := campaignPayload(*campaign, totals)
+	payload["daily"] = daily
+	return utils.Success(c, fiber.StatusOK, payload, fiber.Map{"days": days})
+}
+
+// statsQuery собирает итоги отправок по кампаниям
+func (cc *CampaignsController) statsQuery(c *fiber.Ctx) *gorm.DB {
+	return cc.db(c).Model(&models.CampaignDelivery{}).
+		Select(`campaign_id, COUNT(*) AS sent, COUNT(converted_at) AS converted,
+			COUNT(*) FILTER (WHERE converted_at IS NULL AND sent_at > ?) AS pending, MAX(sent_at) AS last_sent_at`,
+			time.Now().Add(-jobs.CampaignConversionWindow))
+}
+
+func (cc *CampaignsController) findCampaign(c *fiber.Ctx) (*models.Campaign, bool, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid campaign ID")
+	}
+
+	var campaign models.Campaign
+	if err := cc.db(c).First(&campaign, id).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Campaign not found")
+	}
+	return &campaign, false, nil
+}
+
+func campaignPayload(campaign models.Campaign, stats campaignStats) fiber.Map {
+	// Only nudges whose window has closed (or that already converted) count towards the rate
+	var rate float64
+	if settled := stats.Sent - stats.Pending; settled > 0 {
+		rate = float64(stats.Converted) / float64(settled)
+	}
+	return fiber.Map{
+		"id":              campaign.ID,
+		"name":            campaign.Name,
+		"trigger":         campaign.Trigger,
+		"inactive_days":   campaign.InactiveDays,
+		"score_below":     campaign.ScoreBelow,
+		"subject":         campaign.Subject,
+		"body":            campaign.Body,
+		"enabled":         campaign.Enabled,
+		"created_at":      campaign.CreatedAt,
+		"sent":            stats.Sent,
+		"converted":       stats.Converted,
+		"pending":         stats.Pending,
+		"conversion_rate": rate,
+		"last_sent_at":    stats.LastSentAt,
+	}
+}
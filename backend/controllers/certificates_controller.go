@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/export"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errCourseNotCompleted = errors.New("Meet the course completion requirements to get the certificate")
+	errSurveyRequired     = errors.New("Answer the end-of-course survey to get the certificate")
+)
+
+type CertificatesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewCertificatesController(db *gorm.DB, cfg *config.Config) *CertificatesController {
+	return &CertificatesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *CertificatesController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
+// GetCourseSurvey возвращает анкету курса и отметку, ответил ли на нее пользователь
+func (cc *CertificatesController) GetCourseSurvey(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	survey, err := courseSurvey(cc.db(c), course.ID)
+	if err != nil {
+		return utils.NotFound(c, "Survey not found")
+	}
+
+	var answered int64
+	cc.db(c).Model(&models.CourseSurveyResponse{}).Where("course_id = ? AND user_id = ?", course.ID, userID).Count(&answered)
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"course_id": course.ID,
+		"questions": surveyQuestions(survey),
+		"required":  survey.Required,
+		"answered":  answered > 0,
+	})
+}
+
+// UpdateCourseSurvey задает вопросы анкеты и то, нужна ли она для получения сертификата
+func (cc *CertificatesController) UpdateCourseSurvey(c *fiber.Ctx) error {
+	course, _, done, err := cc.authorizedCourse(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Questions []string `json:"questions"`
+		Required  bool     `json:"required"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	questions := make([]string, 0, len(input.Questions))
+	for _, question := range input.Questions {
+		if question = strings.TrimSpace(question); question != "" {
+			questions = append(questions, question)
+		}
+	}
+	if input.Required && len(questions) == 0 {
+		return utils.ValidationError(c, map[string]string{"questions": "A required survey needs at least one question"})
+	}
+	encoded, _ := json.Marshal(questions)
+
+	survey := models.CourseSurvey{CourseID: course.ID, Questions: string(encoded), Required: input.Required}
+	if err := cc.db(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "course_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"questions", "required", "updated_at"}),
+	}).Create(&survey).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save survey")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"course_id": course.ID,
+		"questions": questions,
+		"required":  survey.Required,
+	})
+}
+
+// SubmitCourseSurvey сохраняет ответы на анкету и выдает сертификат, если курс уже пройден
+func (cc *CertificatesController) SubmitCourseSurvey(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	survey, err := courseSurvey(cc.db(c), course.ID)
+	if err != nil {
+		return utils.NotFound(c, "Survey not found")
+	}
+
+	var input struct {
+		Answers []string `json:"answers"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if len(input.Answers) != len(surveyQuestions(survey)) {
+		return utils.ValidationError(c, map[string]string{"answers": "Answer every question of the survey"})
+	}
+	encoded, _ := json.Marshal(input.Answers)
+
+	response := models.CourseSurveyResponse{CourseID: course.ID, UserID: userID, Answers: string(encoded)}
+	if err := cc.db(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "course_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"answers", "updated_at"}),
+	}).Create(&response).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save survey answers")
+	}
+
+	// The survey may have been the last thing standing between the student and the certificate
+	result := fiber.Map{"answered": true}
+	certificate, err := issueCertificate(cc.db(c), course.ID, userID)
+	switch {
+	case err == nil:
+		result["certificate"] = certificate
+	case !errors.Is(err, errCourseNotCompleted):
+		return utils.InternalServerError(c, "Could not issue certificate")
+	}
+
+	return utils.Created(c, result)
+}
+
+// GetCourseCertificate возвращает сертификат о прохождении курса в JSON (или PDF при Accept: application/pdf),
+// выдавая его при первом запросе
+func (cc *CertificatesController) GetCourseCertificate(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	certificate, err := issueCertificate(cc.db(c), course.ID, userID)
+	if errors.Is(err, errCourseNotCompleted) {
+		return utils.Error(c, fiber.StatusForbidden, err)
+	}
+	if errors.Is(err, errSurveyRequired) {
+		return utils.Error(c, fiber.StatusForbidden, err, fiber.Map{"survey_required": true})
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not issue certificate")
+	}
+
+	// Only clients that ask for a PDF get the printable file, the rest keep getting the record
+	if !strings.Contains(c.Get(fiber.HeaderAccept), "application/pdf") {
+		return utils.Success(c, fiber.StatusOK, certificate)
+	}
+
+	var holder models.User
+	if err := cc.db(c).First(&holder, userID).Error; err != nil {
+		return utils.InternalServerError(c, "Could not issue certificate")
+	}
+	pdf := export.CertificatePDF(export.CertificateData{
+		Holder:     holder.Username,
+		Course:     course.Title,
+		University: course.University,
+		Serial:     certificate.Serial,
+		IssuedAt:   certificate.IssuedAt,
+		// The Host header is client-controlled, the link is built from the configured address
+		VerifyURL: strings.TrimRight(cc.Cfg.AppURL, "/") + "/api/certificates/" + certificate.Serial,
+	})
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Attachment(fmt.Sprintf("certificate_%s.pdf", certificate.Serial))
+	return c.Send(pdf)
+}
+
+// VerifyCertificate — публичная проверка сертификата по номеру: кому и за какой курс он выдан
+func (cc *CertificatesController) VerifyCertificate(c *fiber.Ctx) error {
+	serial := strings.ToUpper(strings.TrimSpace(c.Params("code")))
+	if serial == "" {
+		return utils.BadRequest(c, "Invalid certificate number")
+	}
+
+	var certificate models.CourseCertificate
+	if err := cc.db(c).Where("serial = ?", serial).First(&certificate).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.Error(c, fiber.StatusNotFound, errors.New("Certificate not found"), fiber.Map{"valid": false})
+		}
+		return utils.InternalServerError(c, "Failed to verify certificate")
+	}
+
+	// The certificate stays valid after the course is retired
+	var course models.Course
+	if err := cc.db(c).Unscoped().First(&course, certificate.CourseID).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to verify certificate")
+	}
+	var holder models.User
+	if err := cc.db(c).Unscoped().First(&holder, certificate.UserID).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to verify certificate")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"valid":      true,
+		"serial":     certificate.Serial,
+		"issued_at":  certificate.IssuedAt,
+		"holder":     holder.Username,
+		"course":     fiber.Map{"id": course.ID, "title": course.Title},
+		"university": course.University,
+	})
+}
+
+// authorizedCourse загружает курс из :id и проверяет действие пользователя над ним
+func (cc *CertificatesController) authorizedCourse(c *fiber.Ctx, action policy.Action) (*models.Course, uint, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return nil, 0, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 0, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, 0, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, action, policy.Course(&course)); err != nil {
+		return nil, 0, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have access to this course"))
+	}
+	return &course, userID, false, nil
+}
+
+// issueCertificate выдает сертификат о прохождении курса. Курс должен быть пройден по его правилам,
+// а если автор сделал анкету обязательной — еще и анкета заполнена. Повторный вызов возвращает
+// уже выданный сертификат.
+func issueCertificate(db *gorm.DB, courseID, userID uint) (models.CourseCertificate, error) {
+	var certificate models.CourseCertificate
+	err := db.Where("course_id = ? AND user_id = ?", courseID, userID).First(&certificate).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return certificate, err
+	}
+
+	var progress models.UserCourseProgress
+	if err := db.Where("user_id = ? AND course_id = ?", userID, courseID).First(&progress).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return certificate, errCourseNotCompleted
+		}
+		return certificate, err
+	}
+	if progress.CompletionRate < 100 {
+		return certificate, errCourseNotCompleted
+	}
+
+	survey, err := courseSurvey(db, courseID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return certificate, err
+	}
+	if err == nil && survey.Required {
+		var answered int64
+		if err := db.Model(&models.CourseSurveyResponse{}).
+			Where("course_id = ? AND user_id = ?", courseID, userID).Count(&answered).Error; err != nil {
+			return certificate, err
+		}
+		if answered == 0 {
+			return certificate, errSurveyRequired
+		}
+	}
+
+	serial, err := generateCertificateSerial()
+	if err != nil {
+		return certificate, err
+	}
+	certificate = models.CourseCertificate{CourseID: courseID, UserID: userID, Serial: serial, IssuedAt: time.Now()}
+
+	// A parallel request may have issued it first; the unique pair keeps one certificate
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&certificate).Error; err != nil {
+		return certificate, err
+	}
+	err = db.Where("course_id = ? AND user_id = ?", courseID, userID).First(&certificate).Error
+	return certificate, err
+}
+
+func courseSurvey(db *gorm.DB, courseID uint) (models.CourseSurvey, error) {
+	var survey models.CourseSurvey
+	err := db.Where("course_id = ?", courseID).First(&survey).Error
+	return survey, err
+}
+
+func surveyQuestions(survey models.CourseSurvey) []string {
+	questions := []string{}
+	json.Unmarshal([]byte(survey.Questions), &questions)
+	return questions
+}
+
+func generateCertificateSerial() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(buf)), nil
+}
@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"fmt"
+	"project/backend/circles"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Ограничения испытаний кружка
+const (
+	challengeMaxDays    = 90  // longest window of a challenge
+	challengeMaxLessons = 500 // largest lessons target
+	circleStreakWindow  = 365 // days of history looked at for the circle streak
+)
+
+type CircleChallengesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewCircleChallengesController(db *gorm.DB, cfg *config.Config) *CircleChallengesController {
+	return &CircleChallengesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *CircleChallengesController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
+// GetChallenges возвращает испытания кружка — сначала идущие, потом будущие и завершенные — с прогрессом
+// текущего пользователя; в meta — общая серия кружка
+func (cc *CircleChallengesController) GetChallenges(c *fiber.Ctx) error {
+	circle, membership, done, err := circleMembership(c, cc.db(c), cc.Cfg, false)
+	if done {
+		return err
+	}
+
+	var challenges []models.CircleChallenge
+	if err := cc.db(c).Where("circle_id = ?", circle.ID).Order("starts_at DESC").Find(&challenges).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch challenges")
+	}
+
+	now := time.Now()
+	result := make([]fiber.Map, 0, len(challenges))
+	for _, challenge := range challenges {
+		progress, target, err := circles.Progress(cc.db(c), circle, &challenge, []uint{membership.UserID})
+		if err != nil {
+			return utils.InternalServerError(c, "Failed to fetch challenges")
+		}
+		var completed int64
+		if err := cc.db(c).Model(&models.CircleBadge{}).Where("challenge_id = ?", challenge.ID).Count(&completed).Error; err != nil {
+			return utils.InternalServerError(c, "Failed to fetch challenges")
+		}
+		payload := challengePayload(challenge, target, now)
+		payload["progress"] = progress[membership.UserID]
+		payload["completed_count"] = completed
+		result = append(result, payload)
+	}
+	order := map[string]int{"active": 0, "upcoming": 1, "finished": 2}
+	sort.SliceStable(result, func(i, j int) bool {
+		return order[result[i]["status"].(string)] < order[result[j]["status"].(string)]
+	})
+
+	streak, activeToday, err := circleStreak(cc.db(c), circle, now)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch challenges")
+	}
+	return utils.Success(c, fiber.StatusOK, result, fiber.Map{
+		"circle_id":    circle.ID,
+		"streak_days":  streak,
+		"active_today": activeToday,
+	})
+}
+
+// CreateChallenge создает испытание кружка (только владелец): пройти target уроков курса или весь модуль
+// к ends_at; вместо ends_at можно передать days. Прогресс засчитывается по урокам, пройденным в эти сроки.
+func (cc *CircleChallengesController) CreateChallenge(c *fiber.Ctx) error {
+	circle, membership, done, err := circleMembership(c, cc.db(c), cc.Cfg, true)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Title    string     `json:"title"`
+		Goal     string     `json:"goal"`
+		ModuleID *uint      `json:"module_id"`
+		Target   int        `json:"target"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+		Days     int        `json:"days"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	now := time.Now()
+	challenge := models.CircleChallenge{
+		CircleID:    circle.ID,
+		Title:       strings.TrimSpace(input.Title),
+		Goal:        input.Goal,
+		StartsAt:    now,
+		CreatedByID: membership.UserID,
+	}
+	if input.StartsAt != nil {
+		challenge.StartsAt = *input.StartsAt
+	}
+	switch {
+	case input.EndsAt != nil:
+		challenge.EndsAt = *input.EndsAt
+	case input.Days > 0:
+		challenge.EndsAt = challenge.StartsAt.AddDate(0, 0, input.Days)
+	}
+
+	errs := map[string]string{}
+	switch challenge.Goal {
+	case models.ChallengeLessons:
+		if input.Target < 1 || input.Target > challengeMaxLessons {
+			errs["target"] = "Target must be between 1 and " + strconv.Itoa(challengeMaxLessons)
+		}
+		challenge.Target = input.Target
+		if challenge.Title == "" {
+			challenge.Title = fmt.Sprintf("Complete %d lessons", input.Target)
+		}
+	case models.ChallengeModule:
+		var module models.CourseModule
+		if input.ModuleID == nil {
+			errs["module_id"] = "Module is required"
+		} else if err := cc.db(c).Where("id = ? AND course_id = ?", *input.ModuleID, circle.CourseID).First(&module).Error; err != nil {
+			errs["module_id"] = "Module not found in the circle's course"
+		} else {
+			challenge.ModuleID = &module.ID
+			var lessons int64
+			cc.db(c).Model(&models.Lesson{}).Where("module_id = ?", module.ID).Count(&lessons)
+			if lessons == 0 {
+				errs["module_id"] = "Module has no lessons"
+			}
+			challenge.Target = int(lessons)
+			if challenge.Title == "" {
+				challenge.Title = "Complete " + module.Title
+			}
+		}
+	default:
+		errs["goal"] = "Goal must be one of: " + models.ChallengeLessons + ", " + models.ChallengeModule
+	}
+	if len(challenge.Title) > 200 {
+		errs["title"] = "Title must be at most 200 characters"
+	}
+	switch {
+	case challenge.EndsAt.IsZero():
+		errs["ends_at"] = "End date or number of days is required"
+	case !challenge.EndsAt.After(challenge.StartsAt):
+		errs["ends_at"] = "Challenge must end after it starts"
+	case !challenge.EndsAt.After(now):
+		errs["ends_at"] = "Challenge must end in the future"
+	case challenge.EndsAt.Sub(challenge.StartsAt) > challengeMaxDays*24*time.Hour:
+		errs["ends_at"] = "Challenge can last at most " + strconv.Itoa(challengeMaxDays) + " days"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&challenge).Error; err != nil {
+			return err
+		}
+		// A challenge that started in the past may already be completed by someone
+		_, err := circles.AwardBadges(tx, circle, &challenge, nil)
+		return err
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not create challenge")
+	}
+	return utils.Created(c, challengePayload(challenge, challenge.Target, now))
+}
+
+// GetChallenge — испытание с таблицей лидеров кружка. Как и на доске прогресса, цифры и значки видны
+// только у участников с share_progress и у самого пользователя; остальные идут в конце без цифр.
+func (cc *CircleChallengesController) GetChallenge(c *fiber.Ctx) error {
+	circle, membership, done, err := circleMembership(c, cc.db(c), cc.Cfg, false)
+	if done {
+		return err
+	}
+	challenge, done, err := cc.findChallenge(c, circle)
+	if done {
+		return err
+	}
+
+	var members []models.StudyCircleMember
+	if err := cc.db(c).Where("circle_id = ?", circle.ID).Order("created_at").Find(&members).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch challenge")
+	}
+	users, err := circleUsers(cc.db(c), members)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch challenge")
+	}
+
+	progress, target, err := circles.Progress(cc.db(c), circle, challenge, nil)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch challenge")
+	}
+	var badges []models.CircleBadge
+	if err := cc.db(c).Where("challenge_id = ?", challenge.ID).Find(&badges).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch challenge")
+	}
+	earned := make(map[uint]time.Time, len(badges))
+	for _, badge := range badges {
+		earned[badge.UserID] = badge.EarnedAt
+	}
+
+	leaderboard := make([]fiber.Map, 0, len(members))
+	for _, member := range members {
+		row := circleMemberPayload(member, users[member.UserID])
+		if member.ShareProgress || member.UserID == membership.UserID {
+			row["progress"], row["completed"], row["earned_at"] = progress[member.UserID], false, nil
+			if at, ok := earned[member.UserID]; ok {
+				row["completed"], row["earned_at"] = true, at
+			}
+		}
+		leaderboard = append(leaderboard, row)
+	}
+	// Shown rows first, by progress, ties go to whoever finished earlier
+	sort.SliceStable(leaderboard, func(i, j int) bool {
+		pi, iShown := leaderboard[i]["progress"].(int)
+		pj, jShown := leaderboard[j]["progress"].(int)
+		if iShown != jShown {
+			return iShown
+		}
+		if pi != pj {
+			return pi > pj
+		}
+		ei, iDone := leaderboard[i]["earned_at"].(time.Time)
+		ej, jDone := leaderboard[j]["earned_at"].(time.Time)
+		return iDone && jDone && ei.Before(ej)
+	})
+	rank := 0
+	for _, row := range leaderboard {
+		if _, shown := row["progress"]; shown {
+			rank++
+			row["rank"] = rank
+		}
+	}
+
+	payload := challengePayload(*challenge, target, time.Now())
+	payload["completed_count"] = len(badges)
+	payload["leaderboard"] = leaderboard
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// DeleteChallenge удаляет испытание (только владелец); полученные значки остаются у участников
+func (cc *CircleChallengesController) DeleteChallenge(c *fiber.Ctx) error {
+	circle, _, done, err := circleMembership(c, cc.db(c), cc.Cfg, true)
+	if done {
+		return err
+	}
+	challenge, done, err := cc.findChallenge(c, circle)
+	if done {
+		return err
+	}
+	if err := cc.db(c).Delete(challenge).Error; err != nil {
+		return utils.InternalServerError(c, "Could not delete challenge")
+	}
+	return utils.NoContent(c)
+}
+
+// GetMyBadges возвращает значки текущего пользователя за испытания всех его кружков, новые первыми
+func (cc *CircleChallengesController) GetMyBadges(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var badges []struct {
+		ChallengeID uint      `json:"challenge_id"`
+		Title       string    `json:"title"`
+		Goal        string    `json:"goal"`
+		CircleID    uint      `json:"circle_id"`
+		CircleName  string    `json:"circle_name"`
+		CourseID    uint      `json:"course_id"`
+		EarnedAt    time.Time `json:"earned_at"`
+	}
+	// Badges outlive their challenges and circles, so deleted ones are joined as well
+	err = cc.db(c).Model(&models.CircleBadge{}).
+		Select("circle_badges.challenge_id, circle_challenges.title, circle_challenges.goal, "+
+			"study_circles.id AS circle_id, study_circles.name AS circle_name, study_circles.course_id, circle_badges.earned_at").
+		Joins("JOIN circle_challenges ON circle_challenges.id = circle_badges.challenge_id").
+		Joins("JOIN study_circles ON study_circles.id = circle_challenges.circle_id").
+		Where("circle_badges.user_id = ?", userID).
+		Order("circle_badges.earned_at DESC").
+		Scan(&badges).Error
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch badges")
+	}
+	return utils.Success(c, fiber.StatusOK, badges)
+}
+
+// findChallenge загружает испытание из :challengeId, принадлежащее кружку
+func (cc *CircleChallengesController) findChallenge(c *fiber.Ctx, circle *models.StudyCircle) (*models.CircleChallenge, bool, error) {
+	id, err := strconv.Atoi(c.Params("challengeId"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid challenge ID")
+	}
+	var challenge models.CircleChallenge
+	if err := cc.db(c).Where("id = ? AND circle_id = ?", id, circle.ID).First(&challenge).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Challenge not found")
+	}
+	return &challenge, false, nil
+}
+
+func challengePayload(challenge models.CircleChallenge, target int, now time.Time) fiber.Map {
+	status := "active"
+	if now.Before(challenge.StartsAt) {
+		status = "upcoming"
+	} else if !now.Before(challenge.EndsAt) {
+		status = "finished"
+	}
+	return fiber.Map{
+		"id":         challenge.ID,
+		"circle_id":  challenge.CircleID,
+		"title":      challenge.Title,
+		"goal":       challenge.Goal,
+		"module_id":  challenge.ModuleID,
+		"target":     target,
+		"starts_at":  challenge.StartsAt,
+		"ends_at":    challenge.EndsAt,
+		"status":     status,
+		"created_at": challenge.CreatedAt,
+	}
+}
+
+// circleStreak — сколько дней подряд (UTC) хотя бы половина участников кружка проходила уроки его курса.
+// Сегодняшний день еще может продлить серию, поэтому пока он не засчитан, серия считается со вчерашнего.
+func circleStreak(db *gorm.DB, circle *models.StudyCircle, now time.Time) (int, bool, error) {
+	var members []uint
+	if err := db.Model(&models.StudyCircleMember{}).Where("circle_id = ?", circle.ID).Pluck("user_id", &members).Error; err != nil {
+		return 0, false, err
+	}
+	if len(members) == 0 {
+		return 0, false, nil
+	}
+	needed := (len(members) + 1) / 2
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	var days []struct {
+		Day    time.Time
+		Active int
+	}
+	err := db.Model(&models.UserActivity{}).Select("DATE(created_at) AS day, COUNT(DISTINCT user_id) AS active").
+		Where("action_type IN ? AND target_type = ? AND target_id = ? AND user_id IN ? AND created_at >= ?",
+			[]string{models.ActivityLessonComplete, models.ActivityCourseComplete}, "courses", circle.CourseID,
+			members, today.AddDate(0, 0, -circleStreakWindow)).
+		Group("DATE(created_at)").Scan(&days).Error
+	if err != nil {
+		return 0, false, err
+	}
+
+	qualifying := make(map[string]bool, len(days))
+	for _, day := range days {
+		if day.Active >= needed {
+			qualifying[day.Day.Format("2006-01-02")] = true
+		}
+	}
+	activeToday := qualifying[today.Format("2006-01-02")]
+	day, streak := today, 0
+	if !activeToday {
+		day = today.AddDate(0, 0, -1)
+	}
+	for qualifying[day.Format("2006-01-02")] {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak, activeToday, nil
+}
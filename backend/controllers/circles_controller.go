@@ -0,0 +1,599 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Размер учебного кружка: по умолчанию и допустимые пределы
+const (
+	circleDefaultMembers = 8
+	circleMinMembers     = 2
+	circleMaxMembers     = 20
+)
+
+// circleCodeAlphabet — символы кода приглашения без похожих друг на друга (0/O, 1/I)
+const circleCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var (
+	errCircleFull      = errors.New("The circle is full")
+	errCircleShrink    = errors.New("The circle has more members than that")
+	errNotEnrolled     = errors.New("Enroll in the course to join its study circles")
+	errAlreadyInCircle = errors.New("You are already in a study circle of this course")
+)
+
+type StudyCirclesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewStudyCirclesController(db *gorm.DB, cfg *config.Config) *StudyCirclesController {
+	return &StudyCirclesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (sc *StudyCirclesController) db(c *fiber.Ctx) *gorm.DB {
+	return sc.DB.WithContext(c.UserContext())
+}
+
+type circleInput struct {
+	Name        utils.Optional[string] `json:"name"`
+	Description utils.Optional[string] `json:"description"`
+	MaxMembers  utils.Optional[int]    `json:"max_members"`
+}
+
+// apply переносит поля в кружок; merge = true для PATCH
+func (input circleInput) apply(circle *models.StudyCircle, merge bool) map[string]string {
+	errs := map[string]string{}
+	input.Name.Apply(&circle.Name, merge)
+	circle.Name = strings.TrimSpace(circle.Name)
+	if circle.Name == "" {
+		errs["name"] = "Name is required"
+	} else if len(circle.Name) > 100 {
+		errs["name"] = "Name must be at most 100 characters"
+	}
+	input.Description.Apply(&circle.Description, merge)
+	if len(circle.Description) > 1000 {
+		errs["description"] = "Description must be at most 1000 characters"
+	}
+	input.MaxMembers.Apply(&circle.MaxMembers, merge)
+	if circle.MaxMembers < circleMinMembers || circle.MaxMembers > circleMaxMembers {
+		errs["max_members"] = "Max members must be between " + strconv.Itoa(circleMinMembers) + " and " + strconv.Itoa(circleMaxMembers)
+	}
+	return errs
+}
+
+// GetMyCircles возвращает кружки текущего пользователя по всем курсам
+func (sc *StudyCirclesController) GetMyCircles(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var memberships []models.StudyCircleMember
+	if err := sc.db(c).Where("user_id = ?", userID).Order("created_at").Find(&memberships).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circles")
+	}
+
+	result := make([]fiber.Map, 0, len(memberships))
+	for _, membership := range memberships {
+		var circle models.StudyCircle
+		if err := sc.db(c).First(&circle, membership.CircleID).Error; err != nil {
+			continue
+		}
+		payload, err := sc.circlePayload(c, circle, membership)
+		if err != nil {
+			return utils.InternalServerError(c, "Failed to fetch circles")
+		}
+		result = append(result, payload)
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// CreateCircle создает кружок на курсе; создатель становится его владельцем.
+// Студент может состоять только в одном кружке на курсе.
+func (sc *StudyCirclesController) CreateCircle(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var input struct {
+		circleInput
+		ShareProgress bool `json:"share_progress"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var course models.Course
+	if err := sc.db(c).Select("id", "title").First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+
+	circle := models.StudyCircle{CourseID: course.ID, MaxMembers: circleDefaultMembers, CreatedByID: userID}
+	if errs := input.apply(&circle, false); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+	if circle.JoinCode, err = circleCode(); err != nil {
+		return utils.InternalServerError(c, "Could not create circle")
+	}
+
+	membership := models.StudyCircleMember{UserID: userID, CourseID: course.ID, Role: models.CircleOwner, ShareProgress: input.ShareProgress}
+	err = sc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&circle).Error; err != nil {
+			return err
+		}
+		membership.CircleID = circle.ID
+		return addCircleMember(tx, &membership)
+	})
+	if done, err := circleJoinError(c, err); done {
+		return err
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not create circle")
+	}
+
+	payload, err := sc.circlePayload(c, circle, membership)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not create circle")
+	}
+	return utils.Created(c, payload)
+}
+
+// JoinCircle добавляет студента в кружок по коду приглашения
+func (sc *StudyCirclesController) JoinCircle(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Code          string `json:"code"`
+		ShareProgress bool   `json:"share_progress"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	code := strings.ToUpper(strings.TrimSpace(input.Code))
+	if code == "" {
+		return utils.ValidationError(c, map[string]string{"code": "Code is required"})
+	}
+
+	var circle models.StudyCircle
+	if err := sc.db(c).Where("join_code = ?", code).First(&circle).Error; err != nil {
+		return utils.NotFound(c, "No circle with this code")
+	}
+
+	membership := models.StudyCircleMember{CircleID: circle.ID, UserID: userID, CourseID: circle.CourseID, Role: models.CircleMember, ShareProgress: input.ShareProgress}
+	err = sc.db(c).Transaction(func(tx *gorm.DB) error {
+		// The row lock keeps two students from taking the last seat at once
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&circle, circle.ID).Error; err != nil {
+			return err
+		}
+		var members int64
+		if err := tx.Model(&models.StudyCircleMember{}).Where("circle_id = ?", circle.ID).Count(&members).Error; err != nil {
+			return err
+		}
+		if int(members) >= circle.MaxMembers {
+			return errCircleFull
+		}
+		return addCircleMember(tx, &membership)
+	})
+	if done, err := circleJoinError(c, err); done {
+		return err
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not join circle")
+	}
+
+	payload, err := sc.circlePayload(c, circle, membership)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not join circle")
+	}
+	return utils.Created(c, payload)
+}
+
+// GetCircle возвращает кружок с участниками; доступен только участникам
+func (sc *StudyCirclesController) GetCircle(c *fiber.Ctx) error {
+	circle, membership, done, err := sc.findMembership(c, false)
+	if done {
+		return err
+	}
+
+	payload, err := sc.circlePayload(c, *circle, *membership)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circle")
+	}
+	var members []models.StudyCircleMember
+	if err := sc.db(c).Where("circle_id = ?", circle.ID).Order("created_at").Find(&members).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circle")
+	}
+	users, err := circleUsers(sc.db(c), members)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circle")
+	}
+
+	list := make([]fiber.Map, 0, len(members))
+	for _, member := range members {
+		list = append(list, circleMemberPayload(member, users[member.UserID]))
+	}
+	payload["members"] = list
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// UpdateCircle изменяет название, описание и размер кружка (PUT и PATCH); только владелец
+func (sc *StudyCirclesController) UpdateCircle(c *fiber.Ctx) error {
+	circle, membership, done, err := sc.findMembership(c, true)
+	if done {
+		return err
+	}
+
+	var input circleInput
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if errs := input.apply(circle, utils.IsMergePatch(c)); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+	var members int64
+	err = sc.db(c).Transaction(func(tx *gorm.DB) error {
+		// Joins take the same row lock, so nobody gets in between the count and the new size
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.StudyCircle{}, circle.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.StudyCircleMember{}).Where("circle_id = ?", circle.ID).Count(&members).Error; err != nil {
+			return err
+		}
+		// Shrinking below the current size would leave members over the limit
+		if int64(circle.MaxMembers) < members {
+			return errCircleShrink
+		}
+		return tx.Model(circle).Select("name", "description", "max_members").Updates(circle).Error
+	})
+	if errors.Is(err, errCircleShrink) {
+		return utils.ValidationError(c, map[string]string{"max_members": "The circle already has " + strconv.FormatInt(members, 10) + " members"})
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not update circle")
+	}
+	payload, err := sc.circlePayload(c, *circle, *membership)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not update circle")
+	}
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// RotateCircleCode заменяет код приглашения: старый перестает работать, участники остаются
+func (sc *StudyCirclesController) RotateCircleCode(c *fiber.Ctx) error {
+	circle, membership, done, err := sc.findMembership(c, true)
+	if done {
+		return err
+	}
+
+	if circle.JoinCode, err = circleCode(); err != nil {
+		return utils.InternalServerError(c, "Could not change the code")
+	}
+	if err := sc.db(c).Model(circle).Update("join_code", circle.JoinCode).Error; err != nil {
+		return utils.InternalServerError(c, "Could not change the code")
+	}
+	payload, err := sc.circlePayload(c, *circle, *membership)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not change the code")
+	}
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// DeleteCircle распускает кружок; только владелец
+func (sc *StudyCirclesController) DeleteCircle(c *fiber.Ctx) error {
+	circle, _, done, err := sc.findMembership(c, true)
+	if done {
+		return err
+	}
+
+	err = sc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("circle_id = ?", circle.ID).Delete(&models.StudyCircleMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(circle).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not delete circle")
+	}
+	return utils.NoContent(c)
+}
+
+// UpdateMembership меняет настройки приватности участника: share_progress открывает его прогресс кружку
+func (sc *StudyCirclesController) UpdateMembership(c *fiber.Ctx) error {
+	circle, membership, done, err := sc.findMembership(c, false)
+	if done {
+		return err
+	}
+
+	var input struct {
+		ShareProgress utils.Optional[bool] `json:"share_progress"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	// false is a meaningful value here, so PUT applies it as well
+	if input.ShareProgress.Set {
+		membership.ShareProgress = input.ShareProgress.Value
+	}
+	if err := sc.db(c).Model(membership).Update("share_progress", membership.ShareProgress).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update membership")
+	}
+
+	payload, err := sc.circlePayload(c, *circle, *membership)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not update membership")
+	}
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// RemoveCircleMember исключает участника (владелец) или выводит из кружка самого пользователя.
+// Ушедшего владельца сменяет участник с наибольшим стажем; опустевший кружок удаляется.
+func (sc *StudyCirclesController) RemoveCircleMember(c *fiber.Ctx) error {
+	circle, membership, done, err := sc.findMembership(c, false)
+	if done {
+		return err
+	}
+
+	targetID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+	if uint(targetID) != membership.UserID && membership.Role != models.CircleOwner {
+		return utils.Forbidden(c, "Only the circle owner can remove members")
+	}
+
+	var target models.StudyCircleMember
+	if err := sc.db(c).Where("circle_id = ? AND user_id = ?", circle.ID, targetID).First(&target).Error; err != nil {
+		return utils.NotFound(c, "Member not found")
+	}
+	if err := sc.db(c).Transaction(func(tx *gorm.DB) error { return leaveCircle(tx, circle, &target) }); err != nil {
+		return utils.InternalServerError(c, "Could not remove member")
+	}
+	return utils.NoContent(c)
+}
+
+// GetCircleBoard — доска прогресса кружка по его курсу. Цифры видны только у тех, кто включил
+// share_progress; свои цифры пользователь видит всегда. Участники с открытым прогрессом идут первыми.
+func (sc *StudyCirclesController) GetCircleBoard(c *fiber.Ctx) error {
+	circle, _, done, err := sc.findMembership(c, false)
+	if done {
+		return err
+	}
+	userID, _ := utils.ExtractUserIDFromToken(c, sc.Cfg)
+
+	var members []models.StudyCircleMember
+	if err := sc.db(c).Where("circle_id = ?", circle.ID).Order("created_at").Find(&members).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circle board")
+	}
+	users, err := circleUsers(sc.db(c), members)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circle board")
+	}
+
+	visible := make([]uint, 0, len(members))
+	for _, member := range members {
+		if member.ShareProgress || member.UserID == userID {
+			visible = append(visible, member.UserID)
+		}
+	}
+	var progress []models.UserCourseProgress
+	if err := sc.db(c).Where("course_id = ? AND user_id IN ?", circle.CourseID, visible).Find(&progress).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circle board")
+	}
+	var lastActive []struct {
+		UserID uint
+		At     time.Time
+	}
+	err = sc.db(c).Model(&models.UserActivity{}).Select("user_id, MAX(created_at) AS at").
+		Where("target_type = ? AND target_id = ? AND user_id IN ?", "courses", circle.CourseID, visible).
+		Group("user_id").Scan(&lastActive).Error
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch circle board")
+	}
+
+	var lessons int64
+	sc.db(c).Model(&models.Lesson{}).Where("course_id = ?", circle.CourseID).Count(&lessons)
+
+	board := make([]fiber.Map, 0, len(members))
+	for _, member := range members {
+		row := circleMemberPayload(member, users[member.UserID])
+		if member.ShareProgress || member.UserID == userID {
+			row["lessons_completed"], row["completion_rate"], row["hours_spent"], row["last_active_at"] = 0, 0.0, 0.0, nil
+			for _, p := range progress {
+				if p.UserID == member.UserID {
+					row["lessons_completed"], row["completion_rate"], row["hours_spent"] = p.LessonsCompleted, p.CompletionRate, p.HoursSpent
+				}
+			}
+			for _, a := range lastActive {
+				if a.UserID == member.UserID {
+					row["last_active_at"] = a.At
+				}
+			}
+		}
+		board = append(board, row)
+	}
+	sort.SliceStable(board, func(i, j int) bool {
+		ri, iShown := board[i]["completion_rate"].(float64)
+		rj, jShown := board[j]["completion_rate"].(float64)
+		if iShown != jShown {
+			return iShown
+		}
+		return ri > rj
+	})
+
+	return utils.Success(c, fiber.StatusOK, board, fiber.Map{
+		"circle_id":     circle.ID,
+		"course_id":     circle.CourseID,
+		"lessons_total": lessons,
+	})
+}
+
+// addCircleMember записывает участие, если студент записан на курс и еще не состоит в кружке этого курса.
+// Вызывается в транзакции вступления: второй кружок того же курса не пускает уникальный индекс (user_id, course_id).
+func addCircleMember(tx *gorm.DB, membership *models.StudyCircleMember) error {
+	var enrolled int64
+	if err := tx.Model(&models.UserCourseProgress{}).
+		Where("user_id = ? AND course_id = ?", membership.UserID, membership.CourseID).Count(&enrolled).Error; err != nil {
+		return err
+	}
+	if enrolled == 0 {
+		return errNotEnrolled
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(membership)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errAlreadyInCircle
+	}
+	return nil
+}
+
+// circleJoinError отвечает на отказ во вступлении; прочие ошибки оставляет вызывающему
+func circleJoinError(c *fiber.Ctx, err error) (bool, error) {
+	switch {
+	case errors.Is(err, errNotEnrolled):
+		return true, utils.Forbidden(c, err.Error())
+	case errors.Is(err, errAlreadyInCircle), errors.Is(err, errCircleFull):
+		return true, utils.Error(c, fiber.StatusConflict, err)
+	}
+	return false, nil
+}
+
+// findMembership загружает кружок из :id и участие в нем текущего пользователя; ownerOnly — только для владельца
+func (sc *StudyCirclesController) findMembership(c *fiber.Ctx, ownerOnly bool) (*models.StudyCircle, *models.StudyCircleMember, bool, error) {
+	return circleMembership(c, sc.db(c), sc.Cfg, ownerOnly)
+}
+
+func circleMembership(c *fiber.Ctx, db *gorm.DB, cfg *config.Config, ownerOnly bool) (*models.StudyCircle, *models.StudyCircleMember, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, cfg)
+	if err != nil {
+		return nil, nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, nil, true, utils.BadRequest(c, "Invalid circle ID")
+	}
+
+	var circle models.StudyCircle
+	if err := db.First(&circle, id).Error; err != nil {
+		return nil, nil, true, utils.NotFound(c, "Circle not found")
+	}
+	// Circles are private to their members, others get the same answer as for a missing one
+	var membership models.StudyCircleMember
+	if err := db.Where("circle_id = ? AND user_id = ?", circle.ID, userID).First(&membership).Error; err != nil {
+		return nil, nil, true, utils.NotFound(c, "Circle not found")
+	}
+	if ownerOnly && membership.Role != models.CircleOwner {
+		return nil, nil, true, utils.Forbidden(c, "Only the circle owner can do this")
+	}
+	return &circle, &membership, false, nil
+}
+
+func (sc *StudyCirclesController) circlePayload(c *fiber.Ctx, circle models.StudyCircle, membership models.StudyCircleMember) (fiber.Map, error) {
+	var course models.Course
+	if err := sc.db(c).Select("id", "title").First(&course, circle.CourseID).Error; err != nil {
+		return nil, err
+	}
+	var members int64
+	if err := sc.db(c).Model(&models.StudyCircleMember{}).Where("circle_id = ?", circle.ID).Count(&members).Error; err != nil {
+		return nil, err
+	}
+	return fiber.Map{
+		"id":             circle.ID,
+		"course_id":      circle.CourseID,
+		"course_title":   course.Title,
+		"name":           circle.Name,
+		"description":    circle.Description,
+		"join_code":      circle.JoinCode,
+		"max_members":    circle.MaxMembers,
+		"member_count":   members,
+		"role":           membership.Role,
+		"share_progress": membership.ShareProgress,
+		"created_at":     circle.CreatedAt,
+	}, nil
+}
+
+// leaveCircle удаляет участие; если уходит владелец, права переходят к участнику с наибольшим стажем,
+// а кружок без участников удаляется
+func leaveCircle(tx *gorm.DB, circle *models.StudyCircle, member *models.StudyCircleMember) error {
+	// Hard delete: the (circle, user) pair is unique, so a soft-deleted row would block joining again
+	if err := tx.Unscoped().Delete(member).Error; err != nil {
+		return err
+	}
+	if member.Role != models.CircleOwner {
+		return nil
+	}
+
+	var next models.StudyCircleMember
+	err := tx.Where("circle_id = ?", circle.ID).Order("created_at, id").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Delete(circle).Error
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&next).Update("role", models.CircleOwner).Error
+}
+
+// circleUsers загружает имена и аватары участников
+func circleUsers(db *gorm.DB, members []models.StudyCircleMember) (map[uint]models.User, error) {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserID)
+	}
+	var users []models.User
+	if err := db.Select("id", "username", "avatar_url").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	return byID, nil
+}
+
+func circleMemberPayload(member models.StudyCircleMember, user models.User) fiber.Map {
+	return fiber.Map{
+		"user_id":        member.UserID,
+		"username":       user.Username,
+		"avatar_url":     user.AvatarURL,
+		"role":           member.Role,
+		"share_progress": member.ShareProgress,
+		"joined_at":      member.CreatedAt,
+	}
+}
+
+// circleCode генерирует код приглашения из 8 символов
+func circleCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = circleCodeAlphabet[int(b)%len(circleCodeAlphabet)]
+	}
+	return string(buf), nil
+}
@@ -2,9 +2,14 @@ package controllers
 
 import (
 	"project/backend/config"
+	"project/backend/grading"
+	"project/backend/markup"
 	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/policy"
 	"project/backend/utils"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -19,6 +24,12 @@ func NewCommentsController(db *gorm.DB, cfg *config.Config) *CommentsController
 	return &CommentsController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *CommentsController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
+// AddCourseComment добавляет комментарий к курсу; текст пишется в Markdown и отдается также готовым HTML (TextHTML)
 func (cc *CommentsController) AddCourseComment(c *fiber.Ctx) error {
 	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
 	if err != nil {
@@ -54,7 +65,7 @@ func (cc *CommentsController) AddCourseComment(c *fiber.Ctx) error {
 
 	// Get user info
 	var user models.User
-	if err := cc.DB.First(&user, userID).Error; err != nil {
+	if err := cc.db(c).First(&user, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -64,12 +75,55 @@ func (cc *CommentsController) AddCourseComment(c *fiber.Ctx) error {
 		CourseID:  uint(courseID),
 		UserID:    userID,
 		UserName:  user.Username,
-		UserImage: "", // You can add user image URL here
+		UserImage: user.AvatarURL,
 		Text:      input.Text,
-		Rating:    input.Rating,
+		TextHTML:  markup.Markdown(input.Text),
 	}
 
-	if err := cc.DB.Create(&comment).Error; err != nil {
+	// The course author hears about new comments (unless they opted out)
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+
+		var course models.Course
+		if err := tx.Select("id", "title", "author_id").First(&course, courseID).Error; err != nil {
+			return nil
+		}
+		if err := recordActivity(tx, models.UserActivity{
+			UserID:       userID,
+			ActionType:   models.ActivityComment,
+			TargetType:   "courses",
+			TargetID:     course.ID,
+			TargetTitle:  course.Title,
+			SubjectID:    comment.ID,
+			SubjectTitle: excerpt(comment.Text),
+		}); err != nil {
+			return err
+		}
+		if err := grading.RefreshUser(tx, course.ID, userID); err != nil {
+			return err
+		}
+		// Older clients still rate through comments: the rating becomes the student's review
+		if input.Rating > 0 {
+			var enrolled int64
+			if err := tx.Model(&models.UserCourseProgress{}).
+				Where("user_id = ? AND course_id = ?", userID, course.ID).Count(&enrolled).Error; err != nil {
+				return err
+			}
+			if enrolled > 0 {
+				if _, err := upsertReview(tx, models.Review{CourseID: course.ID, UserID: userID, Rating: input.Rating, Text: input.Text}); err != nil {
+					return err
+				}
+			}
+		}
+		if course.AuthorID == userID {
+			return nil
+		}
+		return outbox.EnqueueNotification(tx, course.AuthorID, outbox.NotifyComments,
+			"New comment on "+course.Title, user.Username+" commented on "+course.Title+": "+input.Text)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create comment",
 		})
@@ -78,6 +132,93 @@ func (cc *CommentsController) AddCourseComment(c *fiber.Ctx) error {
 	return c.JSON(comment)
 }
 
+// ReplyToCourseComment отвечает на комментарий от лица автора курса или его сотрудников
+func (cc *CommentsController) ReplyToCourseComment(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+	commentID, err := strconv.Atoi(c.Params("commentId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid comment ID")
+	}
+
+	var input struct {
+		Text string `json:"text"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Text == "" {
+		return utils.ValidationError(c, map[string]string{"text": "Text is required"})
+	}
+
+	var course models.Course
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionModerate, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You can't reply to comments on this course"))
+	}
+
+	var comment models.CourseComment
+	if err := cc.db(c).Where("id = ? AND course_id = ?", commentID, course.ID).First(&comment).Error; err != nil {
+		return utils.NotFound(c, "Comment not found")
+	}
+
+	var user models.User
+	if err := cc.db(c).First(&user, userID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	reply := models.CourseCommentReply{
+		CommentID: comment.ID,
+		UserID:    userID,
+		UserName:  user.Username,
+		UserImage: user.AvatarURL,
+		Text:      input.Text,
+		TextHTML:  markup.Markdown(input.Text),
+	}
+
+	// The commenter hears about the answer (unless they opted out)
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&reply).Error; err != nil {
+			return err
+		}
+		if err := recordActivity(tx, models.UserActivity{
+			UserID:       userID,
+			ActionType:   models.ActivityCommentReply,
+			TargetType:   "courses",
+			TargetID:     course.ID,
+			TargetTitle:  course.Title,
+			SubjectID:    reply.ID,
+			SubjectTitle: excerpt(reply.Text),
+		}); err != nil {
+			return err
+		}
+		if err := grading.RefreshUser(tx, course.ID, userID); err != nil {
+			return err
+		}
+		if comment.UserID == userID {
+			return nil
+		}
+		return outbox.EnqueueNotification(tx, comment.UserID, outbox.NotifyComments,
+			"Reply to your comment on "+course.Title, user.Username+" replied on "+course.Title+": "+input.Text)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not create reply")
+	}
+
+	return utils.Created(c, reply)
+}
+
+// GetCourseComments отдает все комментарии курса массивом, как до пагинации. Маршрут устарел,
+// постраничный вариант — GetCourseCommentsPage.
 func (cc *CommentsController) GetCourseComments(c *fiber.Ctx) error {
 	courseID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -87,9 +228,7 @@ func (cc *CommentsController) GetCourseComments(c *fiber.Ctx) error {
 	}
 
 	var comments []models.CourseComment
-	result := cc.DB.Preload("Replies").Where("course_id = ?", courseID).Find(&comments)
-
-	if result.Error != nil {
+	if err := cc.db(c).Preload("Replies").Where("course_id = ?", courseID).Find(&comments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not fetch comments",
 		})
@@ -97,3 +236,28 @@ func (cc *CommentsController) GetCourseComments(c *fiber.Ctx) error {
 
 	return c.JSON(comments)
 }
+
+// GetCourseCommentsPage отдает комментарии курса от новых к старым с keyset-пагинацией
+func (cc *CommentsController) GetCourseCommentsPage(c *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	cursor, limit, err := utils.ParseCursorParams(c)
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cursor")
+	}
+
+	var comments []models.CourseComment
+	query := cc.db(c).Preload("Replies").Where("course_id = ?", courseID)
+	if err := utils.ApplyCursor(query, "created_at", "id", cursor, limit).Find(&comments).Error; err != nil {
+		return utils.InternalServerError(c, "Could not fetch comments")
+	}
+
+	comments, next := utils.TrimPage(comments, limit, func(comment models.CourseComment) (time.Time, uint) {
+		return comment.CreatedAt, comment.ID
+	})
+
+	return utils.PaginateCursor(c, comments, next, limit)
+}
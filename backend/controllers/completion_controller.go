@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"project/backend/cache"
+	"project/backend/completion"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CompletionController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewCompletionController(db *gorm.DB, cfg *config.Config) *CompletionController {
+	return &CompletionController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *CompletionController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
+// GetCompletionPolicy возвращает правила прохождения курса и то, сколько из них выполнил пользователь
+func (cc *CompletionController) GetCompletionPolicy(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	var lessons int64
+	if err := cc.db(c).Model(&models.Lesson{}).Where("course_id = ?", course.ID).Count(&lessons).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	var progress models.UserCourseProgress
+	if err := cc.db(c).Where("user_id = ? AND course_id = ?", userID, course.ID).Limit(1).Find(&progress).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+
+	data := completionPolicyPayload(course.CompletionPolicy)
+	data["lessons_total"] = lessons
+	data["lessons_required"] = completion.RequiredLessons(course.CompletionPolicy, int(lessons))
+	data["lessons_completed"] = progress.LessonsCompleted
+	data["completion_rate"] = progress.CompletionRate
+	data["completed"] = progress.CompletionRate >= 100
+	if testID := course.CompletionPolicy.FinalTestID; testID != nil {
+		var result models.UserTestProgress
+		if err := cc.db(c).Where("user_id = ? AND test_id = ?", userID, *testID).Limit(1).Find(&result).Error; err != nil {
+			return utils.InternalServerError(c, "Could not query database")
+		}
+		data["final_test_score"] = nil
+		if result.AttemptsUsed > 0 {
+			data["final_test_score"] = result.Score
+		}
+		data["final_test_passed"] = result.AttemptsUsed > 0 && result.Score >= course.CompletionPolicy.MinScore
+	}
+	return utils.Success(c, fiber.StatusOK, data)
+}
+
+// UpdateCompletionPolicy заменяет правила прохождения курса и пересчитывает прогресс всех студентов.
+// Уже выданные сертификаты остаются в силе.
+func (cc *CompletionController) UpdateCompletionPolicy(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	var input struct {
+		LessonPercent *int    `json:"lesson_percent"`
+		FinalTestID   *uint   `json:"final_test_id"`
+		MinScore      float64 `json:"min_score"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	rules := models.CompletionPolicy{LessonPercent: 100, FinalTestID: input.FinalTestID, MinScore: input.MinScore}
+	if input.LessonPercent != nil {
+		rules.LessonPercent = *input.LessonPercent
+	}
+
+	errs := map[string]string{}
+	if rules.LessonPercent < 0 || rules.LessonPercent > 100 {
+		errs["lesson_percent"] = "lesson_percent must be between 0 and 100"
+	}
+	if rules.MinScore < 0 || rules.MinScore > 100 {
+		errs["min_score"] = "min_score must be between 0 and 100"
+	}
+	if rules.FinalTestID != nil {
+		// Same rule as for the grade composition: only tests of the course author or of this editor
+		found, err := countCourseTests(cc.db(c), course, userID, []uint{*rules.FinalTestID})
+		if err != nil {
+			return utils.InternalServerError(c, "Could not query database")
+		}
+		if found == 0 {
+			errs["final_test_id"] = "Unknown test or a test of another author"
+		}
+	} else if rules.MinScore != 0 {
+		errs["min_score"] = "min_score needs a final test"
+	}
+	if rules.LessonPercent == 0 && rules.FinalTestID == nil {
+		errs["lesson_percent"] = "The course must require lessons, a final test or both"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	course.CompletionPolicy = rules
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(course).Updates(map[string]interface{}{
+			"completion_lesson_percent": rules.LessonPercent,
+			"completion_final_test_id":  rules.FinalTestID,
+			"completion_min_score":      rules.MinScore,
+		}).Error; err != nil {
+			return err
+		}
+		return completion.RefreshCourse(tx, *course)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not save completion policy")
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("course", course.ID), "platform")
+	return utils.Success(c, fiber.StatusOK, completionPolicyPayload(rules))
+}
+
+// authorizedCourse загружает курс из :id и проверяет право на action
+func (cc *CompletionController) authorizedCourse(c *fiber.Ctx, action policy.Action) (*models.Course, uint, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return nil, 0, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 0, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, 0, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, action, policy.Course(&course)); err != nil {
+		return nil, 0, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have access to this course"))
+	}
+	return &course, userID, false, nil
+}
+
+func completionPolicyPayload(rules models.CompletionPolicy) fiber.Map {
+	return fiber.Map{
+		"lesson_percent": rules.LessonPercent,
+		"final_test_id":  rules.FinalTestID,
+		"min_score":      rules.MinScore,
+	}
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/cache"
+	"project/backend/config"
+	"project/backend/grading"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errConductRequired = errors.New("Acknowledge the course rules before starting")
+
+type ConductController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewConductController(db *gorm.DB, cfg *config.Config) *ConductController {
+	return &ConductController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *ConductController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
+// GetCourseConduct возвращает правила курса и отметку, подтвердил ли пользователь их текущую версию
+func (cc *ConductController) GetCourseConduct(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	var conduct models.CourseConduct
+	if err := cc.db(c).Where("course_id = ?", course.ID).First(&conduct).Error; err != nil {
+		return utils.NotFound(c, "The course has no rules to acknowledge")
+	}
+
+	var ack models.CourseConductAcknowledgement
+	cc.db(c).Where("course_id = ? AND user_id = ? AND version = ?", course.ID, userID, conduct.Version).Limit(1).Find(&ack)
+
+	result := conductPayload(conduct)
+	result["acknowledged"] = ack.ID != 0
+	if ack.ID != 0 {
+		result["acknowledged_at"] = ack.AcknowledgedAt
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// UpdateCourseConduct задает правила курса и то, обязательно ли их подтверждать.
+// Новый текст начинает новую версию: прежние подтверждения остаются в журнале, но больше не засчитываются.
+func (cc *ConductController) UpdateCourseConduct(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Statement string `json:"statement"`
+		Required  bool   `json:"required"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	input.Statement = strings.TrimSpace(input.Statement)
+	if input.Statement == "" && input.Required {
+		return utils.ValidationError(c, map[string]string{"statement": "Required rules need a statement"})
+	}
+
+	var conduct models.CourseConduct
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("course_id = ?", course.ID).Limit(1).Find(&conduct).Error; err != nil {
+			return err
+		}
+		if conduct.ID == 0 {
+			conduct = models.CourseConduct{CourseID: course.ID, Version: 1}
+		} else if conduct.Statement != input.Statement {
+			conduct.Version++
+		}
+		conduct.Statement, conduct.Required, conduct.UpdatedBy = input.Statement, input.Required, userID
+		return tx.Save(&conduct).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not save course rules")
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("course", course.ID))
+	return utils.Success(c, fiber.StatusOK, conductPayload(conduct))
+}
+
+// AcknowledgeCourseConduct записывает, что пользователь прочитал и принял текущую версию правил курса
+func (cc *ConductController) AcknowledgeCourseConduct(c *fiber.Ctx) error {
+	course, userID, done, err := cc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	var conduct models.CourseConduct
+	if err := cc.db(c).Where("course_id = ?", course.ID).First(&conduct).Error; err != nil {
+		return utils.NotFound(c, "The course has no rules to acknowledge")
+	}
+
+	// The version the student saw may be outdated by the time they submit
+	var input struct {
+		Version int `json:"version"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.BadRequest(c, "Cannot parse JSON")
+		}
+	}
+	if input.Version != 0 && input.Version != conduct.Version {
+		return utils.Error(c, fiber.StatusConflict, errors.New("The course rules have changed, read them again"), fiber.Map{"version": conduct.Version})
+	}
+
+	ack := models.CourseConductAcknowledgement{
+		CourseID:       course.ID,
+		UserID:         userID,
+		Version:        conduct.Version,
+		Statement:      conduct.Statement,
+		AcknowledgedAt: time.Now(),
+	}
+	result := cc.db(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&ack)
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not save acknowledgement")
+	}
+	if result.RowsAffected == 0 {
+		cc.db(c).Where("course_id = ? AND user_id = ? AND version = ?", course.ID, userID, conduct.Version).First(&ack)
+		return utils.Success(c, fiber.StatusOK, acknowledgementPayload(ack))
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("course", course.ID))
+	return utils.Created(c, acknowledgementPayload(ack))
+}
+
+// GetConductAcknowledgements возвращает журнал подтверждений правил курса (?user_id=, ?version=) для разбора споров об экзаменах
+func (cc *ConductController) GetConductAcknowledgements(c *fiber.Ctx) error {
+	course, _, done, err := cc.authorizedCourse(c, policy.ActionViewAnalytics)
+	if done {
+		return err
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := cc.db(c).Table("course_conduct_acknowledgements").
+		Joins("JOIN users ON users.id = course_conduct_acknowledgements.user_id").
+		Where("course_conduct_acknowledgements.course_id = ? AND course_conduct_acknowledgements.deleted_at IS NULL", course.ID)
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("course_conduct_acknowledgements.user_id = ?", userID)
+	}
+	if version := c.QueryInt("version"); version > 0 {
+		query = query.Where("course_conduct_acknowledgements.version = ?", version)
+	}
+
+	// Counting on a session of its own leaves the filtered query untouched for the page itself
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch acknowledgements")
+	}
+
+	var rows []struct {
+		UserID         uint      `json:"user_id"`
+		Username       string    `json:"username"`
+		Version        int       `json:"version"`
+		Statement      string    `json:"statement"`
+		AcknowledgedAt time.Time `json:"acknowledged_at"`
+	}
+	if err := query.Select("course_conduct_acknowledgements.user_id, users.username, course_conduct_acknowledgements.version, " +
+		"course_conduct_acknowledgements.statement, course_conduct_acknowledgements.acknowledged_at").
+		Order("course_conduct_acknowledgements.acknowledged_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Scan(&rows).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch acknowledgements")
+	}
+
+	return utils.Paginate(c, rows, total, page, pageSize)
+}
+
+// authorizedCourse загружает курс из :id и проверяет действие пользователя над ним
+func (cc *ConductController) authorizedCourse(c *fiber.Ctx, action policy.Action) (*models.Course, uint, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return nil, 0, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 0, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, 0, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, action, policy.Course(&course)); err != nil {
+		return nil, 0, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have access to this course"))
+	}
+	return &course, userID, false, nil
+}
+
+// pendingConduct возвращает правила курса, если они обязательны, а пользователь не подтвердил их текущую версию
+func pendingConduct(db *gorm.DB, courseID, userID uint) (*models.CourseConduct, error) {
+	var conduct models.CourseConduct
+	if err := db.Where("course_id = ? AND required = ?", courseID, true).Limit(1).Find(&conduct).Error; err != nil || conduct.ID == 0 {
+		return nil, err
+	}
+
+	var acknowledged int64
+	if err := db.Model(&models.CourseConductAcknowledgement{}).
+		Where("course_id = ? AND user_id = ? AND version = ?", courseID, userID, conduct.Version).
+		Count(&acknowledged).Error; err != nil {
+		return nil, err
+	}
+	if acknowledged > 0 {
+		return nil, nil
+	}
+	return &conduct, nil
+}
+
+// pendingTestConduct ищет неподтвержденные правила курсов, которые проходит пользователь и в оценку которых входит тест
+func pendingTestConduct(db *gorm.DB, testID, userID uint) (*models.CourseConduct, error) {
+	var components []models.CourseGradeComponent
+	if err := db.Where("kind = ? AND course_id IN (?)", models.GradeKindTests,
+		db.Model(&models.UserCourseProgress{}).Select("course_id").Where("user_id = ?", userID)).
+		Find(&components).Error; err != nil {
+		return nil, err
+	}
+
+	for _, component := range components {
+		if !slices.Contains(grading.ParseTestIDs(component.TestIDs), testID) {
+			continue
+		}
+		conduct, err := pendingConduct(db, component.CourseID, userID)
+		if err != nil || conduct != nil {
+			return conduct, err
+		}
+	}
+	return nil, nil
+}
+
+// conductSummary — сколько студентов курса подтвердили текущую версию правил; nil, если правил нет
+func conductSummary(db *gorm.DB, courseID uint) (fiber.Map, error) {
+	var conduct models.CourseConduct
+	if err := db.Where("course_id = ?", courseID).Limit(1).Find(&conduct).Error; err != nil || conduct.ID == 0 {
+		return nil, err
+	}
+
+	var enrolled, acknowledged int64
+	if err := db.Model(&models.UserCourseProgress{}).Where("course_id = ?", courseID).Count(&enrolled).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.CourseConductAcknowledgement{}).
+		Where("course_id = ? AND version = ?", courseID, conduct.Version).
+		Where("user_id IN (?)", db.Model(&models.UserCourseProgress{}).Select("user_id").Where("course_id = ?", courseID)).
+		Count(&acknowledged).Error; err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"version":      conduct.Version,
+		"required":     conduct.Required,
+		"enrolled":     enrolled,
+		"acknowledged": acknowledged,
+		"pending":      enrolled - acknowledged,
+	}, nil
+}
+
+func conductPayload(conduct models.CourseConduct) fiber.Map {
+	return fiber.Map{
+		"course_id":  conduct.CourseID,
+		"statement":  conduct.Statement,
+		"version":    conduct.Version,
+		"required":   conduct.Required,
+		"updated_at": conduct.UpdatedAt,
+	}
+}
+
+func acknowledgementPayload(ack models.CourseConductAcknowledgement) fiber.Map {
+	return fiber.Map{
+		"course_id":       ack.CourseID,
+		"version":         ack.Version,
+		"acknowledged":    true,
+		"acknowledged_at": ack.AcknowledgedAt,
+	}
+}
@@ -2,15 +2,29 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"project/backend/blocks"
+	"project/backend/cache"
+	"project/backend/completion"
 	"project/backend/config"
+	"project/backend/grading"
+	"project/backend/markup"
 	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/policy"
+	"project/backend/storage"
 	"project/backend/utils"
+	"project/backend/waitlist"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CoursesController struct {
@@ -22,6 +36,11 @@ func NewCoursesController(db *gorm.DB, cfg *config.Config) *CoursesController {
 	return &CoursesController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *CoursesController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
 func (cc *CoursesController) GetUserCourses(c *fiber.Ctx) error {
 	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
 	if err != nil {
@@ -31,14 +50,14 @@ func (cc *CoursesController) GetUserCourses(c *fiber.Ctx) error {
 	}
 
 	var courses []models.Course
-	cc.DB.Joins("JOIN user_course_progress ON user_course_progress.course_id = courses.id").
+	cc.db(c).Preload("AccessSettings").Joins("JOIN user_course_progress ON user_course_progress.course_id = courses.id").
 		Where("user_course_progress.user_id = ?", userID).
 		Find(&courses)
 
 	var result []fiber.Map
 	for _, course := range courses {
 		var progress models.UserCourseProgress
-		cc.DB.Where("user_id = ? AND course_id = ?", userID, course.ID).First(&progress)
+		cc.db(c).Where("user_id = ? AND course_id = ?", userID, course.ID).First(&progress)
 
 		result = append(result, fiber.Map{
 			"id":            course.ID,
@@ -49,6 +68,8 @@ func (cc *CoursesController) GetUserCourses(c *fiber.Ctx) error {
 			"completed":     progress.LessonsCompleted,
 			"hours_spent":   progress.HoursSpent,
 			"last_accessed": progress.LastAccessed,
+			"schedule":      schedulePayload(course.AccessSettings),
+			"price":         pricePayload(course),
 		})
 	}
 
@@ -67,38 +88,78 @@ func (cc *CoursesController) GetAvailableCourses(c *fiber.Ctx) error {
 	topic := c.Query("topic")
 	university := c.Query("university")
 
-	query := cc.DB.Model(&models.Course{}).Where("access_level = 'public'")
+	// Published courses with public access; access_level lives in course_access_settings, not on courses
+	query := cc.db(c).Model(&models.Course{}).
+		Where("status = ?", models.CoursePublished).
+		Where("id IN (SELECT course_id FROM course_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)")
 
 	if topic != "" {
 		query = query.Where("topic LIKE ?", "%"+topic+"%")
 	}
 
+	// Browsing by taxonomy node includes the whole subtree
+	if topicID := c.Query("topic_id"); topicID != "" {
+		query = query.Where("topic_id IN (?)", cc.db(c).Model(&models.Topic{}).Select("id").
+			Where("path LIKE (SELECT path FROM topics WHERE id = ?) || '%'", topicID))
+	}
+
 	if university != "" {
 		query = query.Where("university LIKE ?", "%"+university+"%")
 	}
 
+	query = filterCoursesByTags(query, parseTagFilter(c.Query("tags")))
+
 	var courses []models.Course
-	query.Find(&courses)
+	query.Preload("AccessSettings").Find(&courses)
+
+	courseIDs := make([]uint, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.ID)
+	}
+	tags, err := courseTags(cc.db(c), courseIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
 
 	var result []fiber.Map
 	for _, course := range courses {
 		var progress models.UserCourseProgress
-		cc.DB.Where("user_id = ? AND course_id = ?", userID, course.ID).First(&progress)
+		cc.db(c).Where("user_id = ? AND course_id = ?", userID, course.ID).First(&progress)
 
 		result = append(result, fiber.Map{
-			"id":          course.ID,
-			"title":       course.Title,
-			"progress":    progress.CompletionRate,
-			"group":       course.RecommendedFor,
-			"description": course.ShortDesc,
-			"difficulty":  course.Difficulty,
-			"university":  course.University,
-			"topic":       course.Topic,
-			"author":      course.AuthorID,
-			"logo_url":    course.LogoURL,
+			"id":                 course.ID,
+			"title":              course.Title,
+			"progress":           progress.CompletionRate,
+			"group":              course.RecommendedFor,
+			"description":        course.ShortDesc,
+			"difficulty":         course.Difficulty,
+			"university":         course.University,
+			"topic":              course.Topic,
+			"topic_id":           course.TopicID,
+			"author":             course.AuthorID,
+			"logo_url":           course.LogoURL,
+			"license":            course.License,
+			"attribution":        course.Attribution,
+			"source_attribution": course.SourceAttribution,
+			"tags":               tags[course.ID],
+			"schedule":           schedulePayload(course.AccessSettings),
+			"price":              pricePayload(course),
 		})
 	}
 
+	// Counts next to the catalog filters; the plain list stays the default for older clients
+	if wantFacets(c) {
+		facets, err := catalogFacets(cc.db(c), courses, tags)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not query database",
+			})
+		}
+		return c.JSON(fiber.Map{"courses": result, "facets": facets})
+	}
+
 	return c.JSON(result)
 }
 
@@ -118,7 +179,7 @@ func (cc *CoursesController) GetCourseDetails(c *fiber.Ctx) error {
 	}
 
 	var course models.Course
-	if err := cc.DB.Preload("Lessons").Preload("Comments").First(&course, courseID).Error; err != nil {
+	if err := cc.db(c).Preload("Modules").Preload("Lessons").Preload("Comments").Preload("AccessSettings").First(&course, courseID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Course not found",
@@ -130,25 +191,124 @@ func (cc *CoursesController) GetCourseDetails(c *fiber.Ctx) error {
 	}
 
 	var progress models.UserCourseProgress
-	cc.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&progress)
+	cc.db(c).Where("user_id = ? AND course_id = ?", userID, courseID).First(&progress)
+
+	// Drafts and archived courses stay with their students and editors
+	if hiddenCourse(c, &course, progress.ID != 0) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Course not found",
+		})
+	}
+
+	if err := restrictedCourseAccess(c, &course); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
+			"error": "This course is open only to users on its access list",
+		})
+	}
+
+	run, err := studentRun(cc.db(c), course.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	var runInfo fiber.Map
+	if run != nil {
+		runInfo = fiber.Map{"id": run.ID, "title": run.Title}
+	}
+
+	breadcrumbs, err := topicBreadcrumbs(cc.db(c), course.TopicID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	// Final grade by the author's composition, null until one is defined
+	grade, err := grading.Compute(cc.db(c), course, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	// Until a paid course is bought only the outline is shown, lesson content stays behind the payment
+	locked := paymentRequired(c, &course, progress.ID != 0)
+	if locked {
+		for i := range course.Lessons {
+			course.Lessons[i].Content, course.Lessons[i].Markdown = "", ""
+		}
+	}
+
+	// Lessons grouped into sections; the flat list stays for older clients
+	modules, unassigned := moduleTree(course.Modules, course.Lessons)
+
+	tags, err := courseTags(cc.db(c), []uint{course.ID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	capacity, err := capacityPayload(cc.db(c), course, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	rating, err := courseRating(cc.db(c), course.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	review, err := findReview(cc.db(c), course.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"course": fiber.Map{
-			"id":              course.ID,
-			"title":           course.Title,
-			"description":     course.Description,
-			"short_desc":      course.ShortDesc,
-			"difficulty":      course.Difficulty,
-			"recommended":     course.RecommendedFor,
-			"university":      course.University,
-			"topic":           course.Topic,
-			"logo_url":        course.LogoURL,
-			"author":          course.AuthorID,
-			"lessons":         course.Lessons,
-			"comments":        course.Comments,
-			"completion_rate": course.CompletionRate,
+			"id":                   course.ID,
+			"title":                course.Title,
+			"description":          course.Description,
+			"short_desc":           course.ShortDesc,
+			"difficulty":           course.Difficulty,
+			"recommended":          course.RecommendedFor,
+			"recommended_group_id": course.RecommendedGroupID,
+			"university":           course.University,
+			"university_id":        course.UniversityID,
+			"topic":                course.Topic,
+			"topic_id":             course.TopicID,
+			"breadcrumbs":          breadcrumbs,
+			"tags":                 tags[course.ID],
+			"logo_url":             course.LogoURL,
+			"license":              course.License,
+			"attribution":          course.Attribution,
+			"source_attribution":   course.SourceAttribution,
+			"author":               course.AuthorID,
+			"lessons":              course.Lessons,
+			"modules":              modules,
+			"unassigned_lessons":   unassigned,
+			"comments":             course.Comments,
+			"completion_rate":      course.CompletionRate,
+			"status":               course.Status,
+			"published_at":         course.PublishedAt,
+			"schedule":             schedulePayload(runSchedule(course, run)),
+			"run":                  runInfo,
+			"bookmarked":           isBookmarked(cc.db(c), userID, bookmarkCourses, course.ID),
+			"completion_policy":    completionPolicyPayload(course.CompletionPolicy),
+			"price":                pricePayload(course),
+			"payment_required":     locked,
+			"capacity":             capacity,
+			"rating":               rating,
 		},
-		"progress": progress,
+		"progress":  progress,
+		"grade":     grade,
+		"my_review": review,
 	})
 }
 
@@ -181,7 +341,7 @@ func (cc *CoursesController) UpdateCourseProgress(c *fiber.Ctx) error {
 	}
 
 	var course models.Course
-	if err := cc.DB.Preload("Lessons").First(&course, courseID).Error; err != nil {
+	if err := cc.db(c).Preload("Lessons").Preload("AccessSettings").First(&course, courseID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Course not found",
@@ -193,7 +353,7 @@ func (cc *CoursesController) UpdateCourseProgress(c *fiber.Ctx) error {
 	}
 
 	var progress models.UserCourseProgress
-	if err := cc.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&progress).Error; err != nil {
+	if err := cc.db(c).Where("user_id = ? AND course_id = ?", userID, courseID).First(&progress).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			progress = models.UserCourseProgress{
 				UserID:           userID,
@@ -209,26 +369,218 @@ func (cc *CoursesController) UpdateCourseProgress(c *fiber.Ctx) error {
 		}
 	}
 
+	// Only published courses take new students; their students keep going after it's unpublished
+	if hiddenCourse(c, &course, progress.ID != 0) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Course not found",
+		})
+	}
+	// An archived course is read-only for everyone
+	if course.Status == models.CourseArchived {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "The course is archived",
+			"status": course.Status,
+		})
+	}
+
+	// Enrolling in a restricted course takes a place on its access list
+	if err := restrictedCourseAccess(c, &course); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
+			"error": "This course is open only to users on its access list",
+		})
+	}
+
+	// Students of a run follow its dates instead of the course schedule
+	run, err := studentRun(cc.db(c), course.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	schedule := runSchedule(course, run)
+
+	// Progress counts only while the course runs
+	switch schedule.ScheduleStatus(time.Now()) {
+	case models.ScheduleUpcoming:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":    "The course hasn't started yet",
+			"opens_at": schedule.StartsAt,
+		})
+	case models.ScheduleClosed:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":     "The course has ended",
+			"closed_at": schedule.EndsAt,
+		})
+	}
+
+	// The course rules come before the first lesson
+	conduct, err := pendingConduct(cc.db(c), course.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	if conduct != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":            errConductRequired.Error(),
+			"conduct_required": true,
+			"conduct_version":  conduct.Version,
+		})
+	}
+
+	// Paid courses start only after the payment is confirmed by the provider
+	if paymentRequired(c, &course, progress.ID != 0) {
+		return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{
+			"error":            "Buy the course to start it",
+			"payment_required": true,
+			"price":            pricePayload(course),
+		})
+	}
+
+	// A full course takes new students through its waitlist; staff don't need a seat
+	needsSeat := progress.ID == 0 && course.AccessSettings.MaxEnrollments > 0 &&
+		policy.Authorize(c, policy.ActionEdit, policy.Course(&course)) != nil
+
+	// A paid enrollment creates the row before the first lesson is opened
+	started := progress.ID == 0 || progress.LastAccessed == ""
+	wasCompleted := progress.CompletionRate >= 100
+	if progress.RunID == nil && run != nil {
+		progress.RunID = &run.ID
+	}
+
 	if input.MarkCompleted {
 		progress.LessonsCompleted++
 	}
 
 	progress.HoursSpent += input.HoursSpent
-	progress.CompletionRate = float64(progress.LessonsCompleted) / float64(len(course.Lessons)) * 100
+	// What "complete" means is up to the course's completion policy
+	progress.CompletionRate, err = completion.Rate(cc.db(c), course, len(course.Lessons), progress)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
 	progress.LastAccessed = time.Now().Format(time.RFC3339)
 
-	if err := cc.DB.Save(&progress).Error; err != nil {
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		// The seat is checked under the course lock, otherwise concurrent enrollments take the same last seat
+		if needsSeat {
+			if err := waitlist.Lock(tx, course.ID); err != nil {
+				return err
+			}
+			seat, err := waitlist.HasSeat(tx, course.AccessSettings, userID, time.Now())
+			if err != nil {
+				return err
+			}
+			if !seat {
+				return errCourseFull
+			}
+		}
+		if err := tx.Save(&progress).Error; err != nil {
+			return err
+		}
+
+		// Dashboard feed: "started Ethics 101", "completed Lesson 3 of Ethics 101"
+		activity := models.UserActivity{UserID: userID, TargetType: "courses", TargetID: course.ID, TargetTitle: course.Title}
+		var feed []models.UserActivity
+		if started {
+			feed = append(feed, withAction(activity, models.ActivityCourseStart))
+		}
+		if input.MarkCompleted {
+			if lesson := completedLesson(course.Lessons, input.LessonID, progress.LessonsCompleted); lesson != nil {
+				event := withAction(activity, models.ActivityLessonComplete)
+				event.SubjectID, event.SubjectTitle = lesson.ID, lesson.Title
+				feed = append(feed, event)
+			}
+		}
+		if progress.CompletionRate >= 100 && !wasCompleted {
+			event := withAction(activity, models.ActivityCourseComplete)
+			event.Duration = progress.HoursSpent
+			feed = append(feed, event)
+		}
+		for _, event := range feed {
+			if err := recordActivity(tx, event); err != nil {
+				return err
+			}
+		}
+		if err := grading.RefreshUser(tx, course.ID, userID); err != nil {
+			return err
+		}
+
+		verb := "progressed"
+		if progress.CompletionRate >= 100 {
+			verb = "completed"
+		}
+		statement := outbox.NewStatement(cc.Cfg, userID, "", verb, "courses", course.ID, course.Title)
+		statement.Result = &outbox.StatementResult{
+			Score:      &outbox.StatementScore{Scaled: progress.CompletionRate / 100, Raw: progress.CompletionRate, Min: 0, Max: 100},
+			Completion: progress.CompletionRate >= 100,
+		}
+		return outbox.EnqueueStatement(tx, cc.Cfg, statement)
+	})
+	if errors.Is(err, errCourseFull) {
+		position, _ := waitlist.Position(cc.db(c), course.ID, userID)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":             errCourseFull.Error(),
+			"course_full":       true,
+			"waitlist_position": position,
+		})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not save progress",
 		})
 	}
 
-	return c.JSON(fiber.Map{
+	// Drop cached dashboards that include this progress
+	cache.Analytics.Invalidate(cache.Tag("course", courseID), "platform")
+
+	response := fiber.Map{
 		"message":  "Progress updated",
 		"progress": progress,
-	})
+	}
+
+	// Completing the course issues the certificate unless the author requires the survey first;
+	// a failure here isn't fatal, the certificate is issued again on request
+	if progress.CompletionRate >= 100 {
+		certificate, err := issueCertificate(cc.db(c), course.ID, userID)
+		switch {
+		case err == nil:
+			response["certificate"] = certificate
+		case errors.Is(err, errSurveyRequired):
+			response["survey_required"] = true
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// courseAnalyticsRow — прогресс одного студента курса
+type courseAnalyticsRow struct {
+	ID               uint      `json:"-"`
+	CreatedAt        time.Time `json:"-"`
+	UserID           uint      `json:"user_id"`
+	Username         string    `json:"username"`
+	LessonsCompleted int       `json:"lessons_completed"`
+	HoursSpent       float64   `json:"hours_spent"`
+	CompletionRate   float64   `json:"completion_rate"`
+	RunID            *uint     `json:"run_id"`
+}
+
+// courseAnalyticsQuery выбирает прогресс студентов курса, ?run_id= сужает выборку до одного потока
+func (cc *CoursesController) courseAnalyticsQuery(c *fiber.Ctx, courseID int) *gorm.DB {
+	query := cc.db(c).Table("user_course_progress AS p").
+		Select("p.id, p.created_at, p.user_id, users.username, p.lessons_completed, p.hours_spent, p.completion_rate, p.run_id").
+		Joins("JOIN users ON users.id = p.user_id").
+		Where("p.course_id = ? AND p.deleted_at IS NULL", courseID)
+	if runID := c.QueryInt("run_id"); runID > 0 {
+		query = query.Where("p.run_id = ?", runID)
+	}
+	return query
 }
 
+// GetCourseAnalytics отдает прогресс всех студентов курса в прежнем виде {"analytics": [...]}. Маршрут устарел,
+// постраничный вариант — GetCourseAnalyticsPage.
 func (cc *CoursesController) GetCourseAnalytics(c *fiber.Ctx) error {
 	courseID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -237,32 +589,41 @@ func (cc *CoursesController) GetCourseAnalytics(c *fiber.Ctx) error {
 		})
 	}
 
-	var progresses []models.UserCourseProgress
-	if err := cc.DB.Where("course_id = ?", courseID).Find(&progresses).Error; err != nil {
+	var rows []courseAnalyticsRow
+	if err := cc.courseAnalyticsQuery(c, courseID).Order("p.id").Scan(&rows).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not query database",
 		})
 	}
 
-	var users []fiber.Map
-	for _, progress := range progresses {
-		var user models.User
-		if err := cc.DB.First(&user, progress.UserID).Error; err != nil {
-			continue
-		}
+	return c.JSON(fiber.Map{
+		"analytics": rows,
+	})
+}
 
-		users = append(users, fiber.Map{
-			"user_id":           user.ID,
-			"username":          user.Username,
-			"lessons_completed": progress.LessonsCompleted,
-			"hours_spent":       progress.HoursSpent,
-			"completion_rate":   progress.CompletionRate,
-		})
+// GetCourseAnalyticsPage отдает прогресс студентов курса с keyset-пагинацией
+func (cc *CoursesController) GetCourseAnalyticsPage(c *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
 	}
 
-	return c.JSON(fiber.Map{
-		"analytics": users,
+	cursor, limit, err := utils.ParseCursorParams(c)
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cursor")
+	}
+
+	var rows []courseAnalyticsRow
+	if err := utils.ApplyCursor(cc.courseAnalyticsQuery(c, courseID), "p.created_at", "p.id", cursor, limit).
+		Scan(&rows).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+
+	rows, next := utils.TrimPage(rows, limit, func(r courseAnalyticsRow) (time.Time, uint) {
+		return r.CreatedAt, r.ID
 	})
+
+	return utils.PaginateCursor(c, rows, next, limit)
 }
 
 func (cc *CoursesController) CreateCourse(c *fiber.Ctx) error {
@@ -280,10 +641,59 @@ func (cc *CoursesController) CreateCourse(c *fiber.Ctx) error {
 		})
 	}
 
+	// The topic comes from the taxonomy, free text in "topic" is ignored
+	var topicInput struct {
+		TopicID            utils.Optional[uint] `json:"topic_id"`
+		UniversityID       utils.Optional[uint] `json:"university_id"`
+		RecommendedGroupID utils.Optional[uint] `json:"recommended_group_id"`
+	}
+	c.BodyParser(&topicInput)
+	course.TopicID, course.Topic = nil, ""
+	if err := assignTopic(cc.db(c), topicInput.TopicID, &course.TopicID, &course.Topic); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// The university must come from the directory, either by id or by its exact name
+	universityName := utils.Optional[string]{Set: course.University != "", Value: course.University}
+	course.UniversityID, course.University = nil, ""
+	if _, err := applyUniversity(cc.db(c), topicInput.UniversityID, universityName, false, &course.UniversityID, &course.University); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Same for the recommended group
+	groupName := utils.Optional[string]{Set: course.RecommendedFor != "", Value: course.RecommendedFor}
+	course.RecommendedGroupID, course.RecommendedFor = nil, ""
+	if _, err := applyGroup(cc.db(c), topicInput.RecommendedGroupID, groupName, false, course.UniversityID, &course.RecommendedGroupID, &course.RecommendedFor); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// University-scoped authors may only create courses for their university
+	if !utils.HasScopedPermission(cc.db(c), userID, models.PermCoursesCreate, course.University) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You don't have permission to create courses for this university",
+		})
+	}
+
+	// Credit owed to copied content is only set by cloning and marketplace imports
+	course.SourceLicense, course.SourceAttribution = "", ""
+	if errs := applyLicense(utils.Optional[string]{}, utils.Optional[string]{}, false, "", &course.License, &course.Attribution); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
 	course.AuthorID = userID
 	course.CompletionRate = 0
+	// Every course starts as a draft and reaches the catalog through the publish endpoint
+	course.Status, course.PublishedAt = models.CourseDraft, nil
+	// Covers are only set through the cover endpoints
+	course.LogoURL, course.CoverKey, course.StockCoverID = "", "", nil
 
-	if err := cc.DB.Create(&course).Error; err != nil {
+	if err := cc.db(c).Create(&course).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create course",
 		})
@@ -293,10 +703,9 @@ func (cc *CoursesController) CreateCourse(c *fiber.Ctx) error {
 	accessSettings := models.CourseAccessSettings{
 		CourseID:    course.ID,
 		AccessLevel: "private",
-		Admins:      strconv.Itoa(int(userID)),
 	}
 
-	if err := cc.DB.Create(&accessSettings).Error; err != nil {
+	if err := cc.db(c).Create(&accessSettings).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create access settings",
 		})
@@ -309,7 +718,7 @@ func (cc *CoursesController) CreateCourse(c *fiber.Ctx) error {
 }
 
 func (cc *CoursesController) UpdateCourseDescription(c *fiber.Ctx) error {
-	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	_, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Unauthorized",
@@ -324,14 +733,17 @@ func (cc *CoursesController) UpdateCourseDescription(c *fiber.Ctx) error {
 	}
 
 	var input struct {
-		Title          string `json:"title"`
-		ShortDesc      string `json:"short_desc"`
-		Description    string `json:"description"`
-		Difficulty     string `json:"difficulty"`
-		RecommendedFor string `json:"recommended_for"`
-		University     string `json:"university"`
-		Topic          string `json:"topic"`
-		LogoURL        string `json:"logo_url"`
+		Title              utils.Optional[string] `json:"title"`
+		ShortDesc          utils.Optional[string] `json:"short_desc"`
+		Description        utils.Optional[string] `json:"description"`
+		Difficulty         utils.Optional[string] `json:"difficulty"`
+		RecommendedFor     utils.Optional[string] `json:"recommended_for"`
+		RecommendedGroupID utils.Optional[uint]   `json:"recommended_group_id"`
+		University         utils.Optional[string] `json:"university"`
+		UniversityID       utils.Optional[uint]   `json:"university_id"`
+		TopicID            utils.Optional[uint]   `json:"topic_id"`
+		License            utils.Optional[string] `json:"license"`
+		Attribution        utils.Optional[string] `json:"attribution"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -341,7 +753,7 @@ func (cc *CoursesController) UpdateCourseDescription(c *fiber.Ctx) error {
 	}
 
 	var course models.Course
-	if err := cc.DB.First(&course, courseID).Error; err != nil {
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Course not found",
@@ -352,40 +764,39 @@ func (cc *CoursesController) UpdateCourseDescription(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if course.AuthorID != userID && !strings.Contains(course.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university course editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to edit this course",
 		})
 	}
 
-	// Update fields
-	if input.Title != "" {
-		course.Title = input.Title
-	}
-	if input.ShortDesc != "" {
-		course.ShortDesc = input.ShortDesc
-	}
-	if input.Description != "" {
-		course.Description = input.Description
-	}
-	if input.Difficulty != "" {
-		course.Difficulty = input.Difficulty
-	}
-	if input.RecommendedFor != "" {
-		course.RecommendedFor = input.RecommendedFor
+	// Update fields (PATCH follows JSON Merge Patch, PUT ignores empty values)
+	merge := utils.IsMergePatch(c)
+	input.Title.Apply(&course.Title, merge)
+	input.ShortDesc.Apply(&course.ShortDesc, merge)
+	input.Description.Apply(&course.Description, merge)
+	input.Difficulty.Apply(&course.Difficulty, merge)
+	if _, err := applyUniversity(cc.db(c), input.UniversityID, input.University, merge, &course.UniversityID, &course.University); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	if input.University != "" {
-		course.University = input.University
+	if _, err := applyGroup(cc.db(c), input.RecommendedGroupID, input.RecommendedFor, merge, course.UniversityID, &course.RecommendedGroupID, &course.RecommendedFor); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	if input.Topic != "" {
-		course.Topic = input.Topic
+	if err := assignTopic(cc.db(c), input.TopicID, &course.TopicID, &course.Topic); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	if input.LogoURL != "" {
-		course.LogoURL = input.LogoURL
+	if errs := applyLicense(input.License, input.Attribution, merge, course.SourceLicense, &course.License, &course.Attribution); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
 	}
 
-	if err := cc.DB.Save(&course).Error; err != nil {
+	if err := cc.db(c).Save(&course).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not update course",
 		})
@@ -415,7 +826,9 @@ func (cc *CoursesController) AddLesson(c *fiber.Ctx) error {
 	var input struct {
 		Title       string `json:"title"`
 		Description string `json:"description"`
-		Content     string `json:"content"`
+		Content     string `json:"content"`  // HTML, sanitized before saving
+		Markdown    string `json:"markdown"` // takes the place of content, which is then rendered from it
+		ModuleID    *uint  `json:"module_id"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -425,7 +838,7 @@ func (cc *CoursesController) AddLesson(c *fiber.Ctx) error {
 	}
 
 	var course models.Course
-	if err := cc.DB.First(&course, courseID).Error; err != nil {
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Course not found",
@@ -436,26 +849,53 @@ func (cc *CoursesController) AddLesson(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if course.AuthorID != userID && !strings.Contains(course.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university course editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to add lessons to this course",
 		})
 	}
 
+	if input.ModuleID != nil {
+		if err := lessonModule(cc.db(c), course.ID, *input.ModuleID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Module not found in this course",
+			})
+		}
+	}
+
 	// Get current lesson count to set sequence order
 	var lessonCount int64
-	cc.DB.Model(&models.Lesson{}).Where("course_id = ?", courseID).Count(&lessonCount)
+	cc.db(c).Model(&models.Lesson{}).Where("course_id = ?", courseID).Count(&lessonCount)
 
 	lesson := models.Lesson{
 		CourseID:      uint(courseID),
+		ModuleID:      input.ModuleID,
 		Title:         input.Title,
 		Description:   input.Description,
-		Content:       input.Content,
+		Content:       markup.Sanitize(input.Content),
 		SequenceOrder: int(lessonCount) + 1,
 	}
+	if input.Markdown != "" {
+		lesson.Markdown = input.Markdown
+		lesson.Content = markup.Markdown(input.Markdown)
+	}
 
-	if err := cc.DB.Create(&lesson).Error; err != nil {
+	// Enrolled learners are notified together with the new lesson (unless they opted out); the relay
+	// writes the emails, the request only queues one message
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&lesson).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueFanout(tx, outbox.Fanout{
+			CourseID:  course.ID,
+			ExcludeID: userID,
+			Category:  outbox.NotifyCourseUpdates,
+			Subject:   "New lesson in " + course.Title,
+			Body:      "A new lesson \"" + lesson.Title + "\" is available in " + course.Title + ".",
+		})
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create lesson",
 		})
@@ -468,7 +908,7 @@ func (cc *CoursesController) AddLesson(c *fiber.Ctx) error {
 }
 
 func (cc *CoursesController) UpdateLesson(c *fiber.Ctx) error {
-	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	_, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Unauthorized",
@@ -490,10 +930,12 @@ func (cc *CoursesController) UpdateLesson(c *fiber.Ctx) error {
 	}
 
 	var input struct {
-		Title         string `json:"title"`
-		Description   string `json:"description"`
-		Content       string `json:"content"`
-		SequenceOrder int    `json:"sequence_order"`
+		Title         utils.Optional[string] `json:"title"`
+		Description   utils.Optional[string] `json:"description"`
+		Content       utils.Optional[string] `json:"content"`
+		Markdown      utils.Optional[string] `json:"markdown"`
+		SequenceOrder utils.Optional[int]    `json:"sequence_order"`
+		ModuleID      utils.Optional[uint]   `json:"module_id"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -503,7 +945,7 @@ func (cc *CoursesController) UpdateLesson(c *fiber.Ctx) error {
 	}
 
 	var course models.Course
-	if err := cc.DB.First(&course, courseID).Error; err != nil {
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Course not found",
@@ -514,15 +956,15 @@ func (cc *CoursesController) UpdateLesson(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if course.AuthorID != userID && !strings.Contains(course.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university course editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to edit lessons in this course",
 		})
 	}
 
 	var lesson models.Lesson
-	if err := cc.DB.Where("id = ? AND course_id = ?", lessonID, courseID).First(&lesson).Error; err != nil {
+	if err := cc.db(c).Where("id = ? AND course_id = ?", lessonID, courseID).First(&lesson).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Lesson not found",
@@ -533,21 +975,45 @@ func (cc *CoursesController) UpdateLesson(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update fields
-	if input.Title != "" {
-		lesson.Title = input.Title
-	}
-	if input.Description != "" {
-		lesson.Description = input.Description
-	}
-	if input.Content != "" {
-		lesson.Content = input.Content
-	}
-	if input.SequenceOrder != 0 {
-		lesson.SequenceOrder = input.SequenceOrder
+	// Update fields (PATCH follows JSON Merge Patch, PUT ignores empty values)
+	merge := utils.IsMergePatch(c)
+	input.Title.Apply(&lesson.Title, merge)
+	input.Description.Apply(&lesson.Description, merge)
+	content := lesson.Content
+	// Markdown wins over HTML sent in the same request; HTML alone drops the Markdown source,
+	// and so does markdown: null, keeping the HTML rendered from it
+	switch {
+	case input.Markdown.Apply(&lesson.Markdown, merge) && lesson.Markdown != "":
+		lesson.Content = markup.Markdown(lesson.Markdown)
+	case input.Content.Apply(&lesson.Content, merge):
+		lesson.Content = markup.Sanitize(lesson.Content)
+		lesson.Markdown = ""
+	}
+	input.SequenceOrder.Apply(&lesson.SequenceOrder, merge)
+	// null takes the lesson out of its module
+	switch {
+	case input.ModuleID.Set && (input.ModuleID.Null || input.ModuleID.Value == 0):
+		lesson.ModuleID = nil
+	case input.ModuleID.Set:
+		if err := lessonModule(cc.db(c), course.ID, input.ModuleID.Value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Module not found in this course",
+			})
+		}
+		lesson.ModuleID = &input.ModuleID.Value
 	}
 
-	if err := cc.DB.Save(&lesson).Error; err != nil {
+	// Content written as one string replaces the lesson's blocks
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&lesson).Error; err != nil {
+			return err
+		}
+		if lesson.Content != content {
+			return blocks.Reset(tx, lesson.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not update lesson",
 		})
@@ -568,7 +1034,7 @@ func (cc *CoursesController) GetCourseComments(c *fiber.Ctx) error {
 	}
 
 	var comments []models.CourseComment
-	if err := cc.DB.Where("course_id = ?", courseID).Find(&comments).Error; err != nil {
+	if err := cc.db(c).Where("course_id = ?", courseID).Find(&comments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not query database",
 		})
@@ -578,7 +1044,7 @@ func (cc *CoursesController) GetCourseComments(c *fiber.Ctx) error {
 }
 
 func (cc *CoursesController) UpdateCourseSettings(c *fiber.Ctx) error {
-	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	_, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Unauthorized",
@@ -593,10 +1059,14 @@ func (cc *CoursesController) UpdateCourseSettings(c *fiber.Ctx) error {
 	}
 
 	var input struct {
-		AccessLevel string `json:"access_level"`
-		StartDate   string `json:"start_date"`
-		EndDate     string `json:"end_date"`
-		Admins      string `json:"admins"`
+		AccessLevel string                 `json:"access_level"`
+		StartDate   utils.Optional[string] `json:"start_date"` // null removes the date
+		EndDate     utils.Optional[string] `json:"end_date"`
+		Admins      *string                `json:"admins"`
+		Price       *int64                 `json:"price"`    // minor units, 0 makes the course free
+		Currency    string                 `json:"currency"` // ISO 4217, the platform currency by default
+		// Seats for students, 0 lifts the limit; extra seats go to the waitlist right away
+		MaxEnrollments *int `json:"max_enrollments"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -606,7 +1076,7 @@ func (cc *CoursesController) UpdateCourseSettings(c *fiber.Ctx) error {
 	}
 
 	var course models.Course
-	if err := cc.DB.Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+	if err := cc.db(c).Preload("AccessSettings").First(&course, courseID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Course not found",
@@ -617,28 +1087,53 @@ func (cc *CoursesController) UpdateCourseSettings(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if course.AuthorID != userID && !strings.Contains(course.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university course editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to edit settings for this course",
 		})
 	}
 
+	switch input.AccessLevel {
+	case "", models.AccessPublic, models.AccessPrivate, models.AccessRestricted:
+	default:
+		return utils.ValidationError(c, map[string]string{"access_level": "Access level must be public, private or restricted"})
+	}
+	if input.Admins != nil {
+		return utils.ValidationError(c, map[string]string{"admins": "Co-authors are managed through /api/courses/:id/staff"})
+	}
+
 	// Update settings
 	if input.AccessLevel != "" {
 		course.AccessSettings.AccessLevel = input.AccessLevel
 	}
-	if input.StartDate != "" {
-		course.AccessSettings.StartDate = input.StartDate
+	if errs := applySchedule(&course.AccessSettings, input.StartDate, input.EndDate); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
 	}
-	if input.EndDate != "" {
-		course.AccessSettings.EndDate = input.EndDate
+	if errs := applyPrice(&course, input.Price, input.Currency, cc.Cfg.PaymentCurrency); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
 	}
-	if input.Admins != "" {
-		course.AccessSettings.Admins = input.Admins
+	if input.MaxEnrollments != nil {
+		if *input.MaxEnrollments < 0 {
+			return utils.ValidationError(c, map[string]string{"max_enrollments": "Max enrollments can't be negative"})
+		}
+		course.AccessSettings.MaxEnrollments = *input.MaxEnrollments
 	}
 
-	if err := cc.DB.Save(&course.AccessSettings).Error; err != nil {
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := waitlist.Lock(tx, course.ID); err != nil {
+			return err
+		}
+		if err := tx.Save(&course.AccessSettings).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&course).Select("price", "currency").Updates(&course).Error; err != nil {
+			return err
+		}
+		_, err := waitlist.Promote(tx, course, time.Now())
+		return err
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not update course settings",
 		})
@@ -647,5 +1142,508 @@ func (cc *CoursesController) UpdateCourseSettings(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"message":  "Course settings updated",
 		"settings": course.AccessSettings,
+		"price":    pricePayload(course),
+	})
+}
+
+// applySchedule задает даты начала и окончания курса; отсутствующая дата или пустая строка оставляет ее
+// как есть, null снимает ее. Текст сохраняется как введен, а разобранное время — в StartsAt и EndsAt.
+func applySchedule(settings *models.CourseAccessSettings, startDate, endDate utils.Optional[string]) map[string]string {
+	errs := map[string]string{}
+	dates := []struct {
+		field    string
+		value    utils.Optional[string]
+		endOfDay bool
+		text     *string
+		dst      **time.Time
+	}{
+		{"start_date", startDate, false, &settings.StartDate, &settings.StartsAt},
+		{"end_date", endDate, true, &settings.EndDate, &settings.EndsAt},
+	}
+	for _, date := range dates {
+		switch {
+		case date.value.Value != "":
+			parsed, err := utils.ParseScheduleDate(date.value.Value, date.endOfDay)
+			if err != nil {
+				errs[date.field] = err.Error()
+			}
+			*date.text, *date.dst = date.value.Value, parsed
+		case date.value.Null:
+			*date.text, *date.dst = "", nil
+		}
+	}
+	if len(errs) == 0 && settings.StartsAt != nil && settings.EndsAt != nil && !settings.EndsAt.After(*settings.StartsAt) {
+		errs["end_date"] = "The course must end after it starts"
+	}
+	return errs
+}
+
+// schedulePayload — расписание курса для каталога: upcoming с датой открытия, open или closed
+func schedulePayload(settings models.CourseAccessSettings) fiber.Map {
+	return fiber.Map{
+		"status":    settings.ScheduleStatus(time.Now()),
+		"opens_at":  settings.StartsAt,
+		"closes_at": settings.EndsAt,
+	}
+}
+
+// completedLesson находит отмеченный урок: по lesson_id из запроса или, если он не передан,
+// по порядковому номеру среди уроков курса
+func completedLesson(lessons []models.Lesson, lessonID uint, number int) *models.Lesson {
+	ordered := make([]models.Lesson, len(lessons))
+	copy(ordered, lessons)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].SequenceOrder < ordered[j].SequenceOrder })
+
+	for i := range ordered {
+		if lessonID != 0 && ordered[i].ID == lessonID {
+			return &ordered[i]
+		}
+	}
+	if lessonID == 0 && number >= 1 && number <= len(ordered) {
+		return &ordered[number-1]
+	}
+	return nil
+}
+
+// courseTransition — переход жизненного цикла: из каких статусов он возможен и куда ведет
+type courseTransition struct {
+	from []string
+	to   string
+}
+
+var courseTransitions = map[string]courseTransition{
+	"submit":    {from: []string{models.CourseDraft}, to: models.CourseReview},
+	"publish":   {from: []string{models.CourseDraft, models.CourseReview}, to: models.CoursePublished},
+	"reject":    {from: []string{models.CourseReview}, to: models.CourseDraft},
+	"unpublish": {from: []string{models.CoursePublished}, to: models.CourseDraft},
+	"archive":   {from: []string{models.CourseDraft, models.CourseReview, models.CoursePublished}, to: models.CourseArchived},
+	"restore":   {from: []string{models.CourseArchived}, to: models.CourseDraft},
+}
+
+var (
+	errCourseTransition = errors.New("The course can't make this transition from its current status")
+	errCourseSelfReview = errors.New("A course is published by a reviewer other than its author")
+)
+
+// SubmitCourse отправляет черновик на проверку
+func (cc *CoursesController) SubmitCourse(c *fiber.Ctx) error {
+	return cc.transitionCourse(c, "submit")
+}
+
+// PublishCourse публикует курс, после чего он появляется в каталоге. Публикация — это проверка курса,
+// поэтому автор не может опубликовать свой курс сам
+func (cc *CoursesController) PublishCourse(c *fiber.Ctx) error {
+	return cc.transitionCourse(c, "publish")
+}
+
+// RejectCourse возвращает курс с проверки в черновики
+func (cc *CoursesController) RejectCourse(c *fiber.Ctx) error {
+	return cc.transitionCourse(c, "reject")
+}
+
+// UnpublishCourse снимает курс с публикации; записанные студенты сохраняют доступ и прогресс
+func (cc *CoursesController) UnpublishCourse(c *fiber.Ctx) error {
+	return cc.transitionCourse(c, "unpublish")
+}
+
+// ArchiveCourse переносит курс в архив
+func (cc *CoursesController) ArchiveCourse(c *fiber.Ctx) error {
+	return cc.transitionCourse(c, "archive")
+}
+
+// RestoreCourse возвращает курс из архива в черновики
+func (cc *CoursesController) RestoreCourse(c *fiber.Ctx) error {
+	return cc.transitionCourse(c, "restore")
+}
+
+func (cc *CoursesController) transitionCourse(c *fiber.Ctx, action string) error {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	// Publishing takes an optional note for the changelog
+	var input struct {
+		Changelog string `json:"changelog"`
+	}
+	if action == "publish" && len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.BadRequest(c, "Cannot parse JSON")
+		}
+	}
+
+	transition := courseTransitions[action]
+	var course models.Course
+	var version *models.CourseVersion
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&course, courseID).Error; err != nil {
+			return err
+		}
+
+		// Author, co-admins and university course editors
+		if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+			return err
+		}
+
+		if !slices.Contains(transition.from, course.Status) {
+			return errCourseTransition
+		}
+		if action == "publish" && course.AuthorID == userID {
+			return errCourseSelfReview
+		}
+
+		course.Status = transition.to
+		if transition.to == models.CoursePublished {
+			now := time.Now()
+			course.PublishedAt = &now
+			// Every publication is kept as a version the author can compare with and roll back to
+			created, err := createCourseVersion(tx, &course, userID, strings.TrimSpace(input.Changelog))
+			if err != nil {
+				return err
+			}
+			version = created
+		}
+		return tx.Model(&course).Select("status", "published_at").Updates(&course).Error
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "Course not found")
+	case errors.Is(err, errCourseTransition):
+		return utils.Error(c, fiber.StatusConflict, err, fiber.Map{"status": course.Status, "allowed_from": transition.from})
+	case errors.Is(err, errCourseSelfReview):
+		return utils.Forbidden(c, err.Error())
+	case err != nil && policy.Status(err) != fiber.StatusInternalServerError:
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to change the status of this course"))
+	case err != nil:
+		return utils.InternalServerError(c, "Could not update course status")
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("course", course.ID), "platform")
+	payload := fiber.Map{
+		"id":           course.ID,
+		"status":       course.Status,
+		"published_at": course.PublishedAt,
+	}
+	if version != nil {
+		payload["version"] = version.Number
+	}
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// CloneCourse создает копию курса как шаблон для нового семестра: разделы, уроки с файлами, настройки доступа,
+// анкета, состав оценки и соответствие колонок SIS. Прогресс, оценки, комментарии и сотрудники не копируются.
+func (cc *CoursesController) CloneCourse(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, cc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var input struct {
+		Title     string                 `json:"title"`
+		StartDate utils.Optional[string] `json:"start_date"`
+		EndDate   utils.Optional[string] `json:"end_date"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.BadRequest(c, "Cannot parse JSON")
+		}
+	}
+	var schedule models.CourseAccessSettings
+	if errs := applySchedule(&schedule, input.StartDate, input.EndDate); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	var source models.Course
+	if err := cc.db(c).Preload("Modules").Preload("Lessons").Preload("AccessSettings").First(&source, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+
+	// Only people who can edit the course may copy its content, and the copy is a new course in the same university
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&source)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to clone this course"))
+	}
+	if !utils.HasScopedPermission(cc.db(c), userID, models.PermCoursesCreate, source.University) {
+		return utils.Forbidden(c, "You don't have permission to create courses")
+	}
+
+	clone := source
+	clone.Model = gorm.Model{}
+	clone.Title = strings.TrimSpace(input.Title)
+	if clone.Title == "" {
+		clone.Title = source.Title + " (copy)"
+	}
+	clone.AuthorID = userID
+	clone.CompletionRate = 0
+	clone.Status, clone.PublishedAt = models.CourseDraft, nil
+	clone.Modules, clone.Lessons, clone.Comments = nil, nil, nil
+	clone.AccessSettings = models.CourseAccessSettings{}
+	// The copy keeps the license and owes the same credit as the source
+	clone.SourceLicense, clone.SourceAttribution = source.Upstream()
+	// An uploaded cover belongs to the source course and is deleted with it, so the copy gets its own file below
+	if source.CoverKey != "" {
+		clone.LogoURL, clone.CoverKey = "", ""
+	}
+
+	lessonIDs := make(map[uint]uint, len(source.Lessons))
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&clone).Error; err != nil {
+			return err
+		}
+
+		moduleIDs := make(map[uint]uint, len(source.Modules))
+		for _, module := range source.Modules {
+			copied := models.CourseModule{CourseID: clone.ID, Title: module.Title, SequenceOrder: module.SequenceOrder}
+			if err := tx.Create(&copied).Error; err != nil {
+				return err
+			}
+			moduleIDs[module.ID] = copied.ID
+		}
+
+		for _, lesson := range source.Lessons {
+			copied := models.Lesson{
+				CourseID:      clone.ID,
+				Title:         lesson.Title,
+				Description:   lesson.Description,
+				Content:       lesson.Content,
+				Markdown:      lesson.Markdown,
+				SequenceOrder: lesson.SequenceOrder,
+			}
+			if lesson.ModuleID != nil {
+				if moduleID, ok := moduleIDs[*lesson.ModuleID]; ok {
+					copied.ModuleID = &moduleID
+				}
+			}
+			if err := tx.Create(&copied).Error; err != nil {
+				return err
+			}
+			lessonIDs[lesson.ID] = copied.ID
+			if err := blocks.Copy(tx, lesson.ID, copied.ID); err != nil {
+				return err
+			}
+		}
+
+		var tags []models.CourseTag
+		if err := tx.Where("course_id = ?", source.ID).Find(&tags).Error; err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if err := tx.Create(&models.CourseTag{CourseID: clone.ID, TagID: tag.TagID}).Error; err != nil {
+				return err
+			}
+		}
+
+		// The dates belong to the old semester, so they are taken from the request
+		accessLevel := source.AccessSettings.AccessLevel
+		if accessLevel == "" {
+			accessLevel = "private"
+		}
+		if err := tx.Create(&models.CourseAccessSettings{
+			CourseID:    clone.ID,
+			AccessLevel: accessLevel,
+			StartDate:   schedule.StartDate,
+			EndDate:     schedule.EndDate,
+			StartsAt:    schedule.StartsAt,
+			EndsAt:      schedule.EndsAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		var survey models.CourseSurvey
+		if tx.Where("course_id = ?", source.ID).Limit(1).Find(&survey); survey.ID != 0 {
+			if err := tx.Create(&models.CourseSurvey{CourseID: clone.ID, Questions: survey.Questions, Required: survey.Required}).Error; err != nil {
+				return err
+			}
+		}
+
+		var components []models.CourseGradeComponent
+		if err := tx.Where("course_id = ?", source.ID).Find(&components).Error; err != nil {
+			return err
+		}
+		for _, component := range components {
+			component.Model = gorm.Model{}
+			component.CourseID = clone.ID
+			if err := tx.Create(&component).Error; err != nil {
+				return err
+			}
+		}
+
+		var mapping models.CourseSISMapping
+		if tx.Where("course_id = ?", source.ID).Limit(1).Find(&mapping); mapping.ID != 0 {
+			if err := tx.Create(&models.CourseSISMapping{CourseID: clone.ID, Columns: mapping.Columns}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not clone course")
+	}
+
+	if source.CoverKey != "" {
+		cc.copyCover(c, &source, &clone)
+	}
+	cc.copyAttachments(c, lessonIDs)
+
+	return utils.Created(c, fiber.Map{
+		"id":        clone.ID,
+		"title":     clone.Title,
+		"status":    clone.Status,
+		"logo_url":  clone.LogoURL,
+		"license":   clone.License,
+		"source_id": source.ID,
+		"modules":   len(source.Modules),
+		"lessons":   len(source.Lessons),
+	})
+}
+
+// copyCover копирует загруженную обложку курса под новым ключом; при ошибке копия остается без обложки
+func (cc *CoursesController) copyCover(c *fiber.Ctx, source, clone *models.Course) {
+	data, err := storage.Default.Get(c.UserContext(), source.CoverKey)
+	if err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("covers/courses/%d-%d%s", clone.ID, time.Now().UnixNano(), path.Ext(source.CoverKey))
+	contentType := "image/jpeg"
+	if path.Ext(key) == ".png" {
+		contentType = "image/png"
+	}
+	url, err := storage.Default.Put(c.UserContext(), key, data, contentType)
+	if err != nil {
+		return
+	}
+
+	if err := cc.db(c).Model(clone).Updates(map[string]interface{}{"logo_url": url, "cover_key": key}).Error; err != nil {
+		storage.Default.Delete(c.UserContext(), key)
+	}
+}
+
+// copyAttachments копирует файлы уроков курса в скопированные уроки (lessonIDs: урок источника -> копия)
+// под новыми ключами: файл удаляется вместе со своим уроком, поэтому копии нужны свои.
+// Файл, который не удалось скопировать, в копии пропускается.
+func (cc *CoursesController) copyAttachments(c *fiber.Ctx, lessonIDs map[uint]uint) {
+	if len(lessonIDs) == 0 {
+		return
+	}
+	sourceIDs := make([]uint, 0, len(lessonIDs))
+	for id := range lessonIDs {
+		sourceIDs = append(sourceIDs, id)
+	}
+	var attachments []models.LessonAttachment
+	if err := cc.db(c).Where("lesson_id IN ?", sourceIDs).Order("id").Find(&attachments).Error; err != nil {
+		return
+	}
+
+	for _, attachment := range attachments {
+		data, err := storage.Default.Get(c.UserContext(), attachment.Key)
+		if err != nil {
+			continue
+		}
+		lessonID := lessonIDs[attachment.LessonID]
+		key, err := storeAttachment(c.UserContext(), lessonID, attachment.FileName, data, attachment.ContentType)
+		if err != nil {
+			continue
+		}
+		attachment.Model = gorm.Model{}
+		attachment.LessonID, attachment.Key = lessonID, key
+		if err := cc.db(c).Create(&attachment).Error; err != nil {
+			storage.Default.Delete(c.UserContext(), key)
+		}
+	}
+}
+
+// DeleteCourse удаляет курс вместе с разделами, уроками, настройками и прогрессом студентов.
+// Все удаляется мягко и возвращается из корзины (см. TrashController).
+func (cc *CoursesController) DeleteCourse(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, cc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to delete this course"))
+	}
+	if utils.UnderLegalHold(cc.db(c), models.HoldSubjectCourse, course.ID) {
+		return utils.LegalHoldConflict(c)
+	}
+
+	// One timestamp for the whole cascade, the restore matches on it
+	at := time.Now().Truncate(time.Microsecond)
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := deleteCascade(tx, courseCascade, "course_id", course.ID, at); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.MarketplaceListing{}).
+			Where("course_id = ? AND status IN ?", course.ID, []string{models.ListingPending, models.ListingApproved}).
+			Update("status", models.ListingWithdrawn).Error; err != nil {
+			return err
+		}
+		return tx.Model(&course).Update("deleted_at", at).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not delete course")
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("course", course.ID), "platform")
+	return utils.NoContent(c)
+}
+
+// DeleteLesson удаляет урок; прогресс студентов пересчитывается по оставшимся урокам
+func (cc *CoursesController) DeleteLesson(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, cc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to delete lessons of this course"))
+	}
+	if utils.UnderLegalHold(cc.db(c), models.HoldSubjectCourse, course.ID) {
+		return utils.LegalHoldConflict(c)
+	}
+
+	var lesson models.Lesson
+	if err := cc.db(c).Where("id = ? AND course_id = ?", c.Params("lessonId"), course.ID).First(&lesson).Error; err != nil {
+		return utils.NotFound(c, "Lesson not found")
+	}
+
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&lesson).Error; err != nil {
+			return err
+		}
+		return refreshLessonProgress(tx, &course)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not delete lesson")
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("course", course.ID))
+	return utils.NoContent(c)
 }
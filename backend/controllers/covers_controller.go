@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/storage"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CoversController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewCoversController(db *gorm.DB, cfg *config.Config) *CoversController {
+	return &CoversController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (cc *CoversController) db(c *fiber.Ctx) *gorm.DB {
+	return cc.DB.WithContext(c.UserContext())
+}
+
+// GetStockCovers возвращает галерею стандартных обложек
+func (cc *CoversController) GetStockCovers(c *fiber.Ctx) error {
+	var covers []models.StockCover
+	if err := cc.db(c).Order("sort_order, id").Find(&covers).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch covers")
+	}
+
+	result := make([]fiber.Map, 0, len(covers))
+	for _, cover := range covers {
+		result = append(result, fiber.Map{
+			"id":    cover.ID,
+			"title": cover.Title,
+			"url":   cover.URL,
+		})
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// CreateStockCover загружает новую обложку в галерею (multipart: cover, title, sort_order и параметры обрезки)
+func (cc *CoversController) CreateStockCover(c *fiber.Ctx) error {
+	title := c.FormValue("title")
+	if title == "" {
+		return utils.ValidationError(c, map[string]string{"title": "Title is required"})
+	}
+	sortOrder, _ := strconv.Atoi(c.FormValue("sort_order"))
+
+	key := fmt.Sprintf("covers/stock/%d", time.Now().UnixNano())
+	url, key, sent, err := cc.storeCover(c, key)
+	if sent {
+		return err
+	}
+
+	cover := models.StockCover{Title: title, URL: url, Key: key, SortOrder: sortOrder}
+	if err := cc.db(c).Create(&cover).Error; err != nil {
+		storage.Default.Delete(c.UserContext(), key)
+		return utils.InternalServerError(c, "Could not create cover")
+	}
+
+	return utils.Created(c, fiber.Map{"id": cover.ID, "title": cover.Title, "url": cover.URL})
+}
+
+// DeleteStockCover убирает обложку из галереи; курсы с ней остаются без обложки
+func (cc *CoversController) DeleteStockCover(c *fiber.Ctx) error {
+	coverID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cover ID")
+	}
+
+	var cover models.StockCover
+	err = cc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&cover, coverID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Course{}).Where("stock_cover_id = ?", cover.ID).
+			Updates(map[string]interface{}{"stock_cover_id": nil, "logo_url": ""}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&cover).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return utils.NotFound(c, "Cover not found")
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not delete cover")
+	}
+
+	if cover.Key != "" {
+		storage.Default.Delete(c.UserContext(), cover.Key)
+	}
+	return utils.NoContent(c)
+}
+
+// UploadCourseCover загружает обложку курса (multipart: cover и необязательные x, y, width, height)
+func (cc *CoversController) UploadCourseCover(c *fiber.Ctx) error {
+	course, sent, err := cc.editableCourse(c)
+	if sent {
+		return err
+	}
+
+	key := fmt.Sprintf("covers/courses/%d-%d", course.ID, time.Now().UnixNano())
+	url, key, sent, err := cc.storeCover(c, key)
+	if sent {
+		return err
+	}
+
+	if err := cc.setCourseCover(c, course, url, key, nil); err != nil {
+		storage.Default.Delete(c.UserContext(), key)
+		return utils.InternalServerError(c, "Could not update course cover")
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"logo_url": url})
+}
+
+// SetCourseCover выбирает для курса обложку из галереи
+func (cc *CoversController) SetCourseCover(c *fiber.Ctx) error {
+	var input struct {
+		StockCoverID uint `json:"stock_cover_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	course, sent, err := cc.editableCourse(c)
+	if sent {
+		return err
+	}
+
+	var cover models.StockCover
+	if err := cc.db(c).First(&cover, input.StockCoverID).Error; err != nil {
+		return utils.NotFound(c, "Cover not found")
+	}
+
+	if err := cc.setCourseCover(c, course, cover.URL, "", &cover.ID); err != nil {
+		return utils.InternalServerError(c, "Could not update course cover")
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"logo_url": cover.URL, "stock_cover_id": cover.ID})
+}
+
+// DeleteCourseCover убирает обложку курса
+func (cc *CoversController) DeleteCourseCover(c *fiber.Ctx) error {
+	course, sent, err := cc.editableCourse(c)
+	if sent {
+		return err
+	}
+
+	if err := cc.setCourseCover(c, course, "", "", nil); err != nil {
+		return utils.InternalServerError(c, "Could not update course cover")
+	}
+	return utils.NoContent(c)
+}
+
+// editableCourse загружает курс из :id и проверяет, что пользователь может его редактировать
+func (cc *CoversController) editableCourse(c *fiber.Ctx) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, cc.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := cc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit this course"))
+	}
+	return &course, false, nil
+}
+
+// storeCover читает файл из поля "cover", обрезает его и сохраняет под key (расширение добавляется по формату)
+func (cc *CoversController) storeCover(c *fiber.Ctx, key string) (string, string, bool, error) {
+	header, err := c.FormFile("cover")
+	if err != nil {
+		return "", "", true, utils.BadRequest(c, "Multipart field \"cover\" is required")
+	}
+
+	maxBytes := int64(cc.Cfg.CoverMaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = 5 << 20
+	}
+	if header.Size > maxBytes {
+		return "", "", true, utils.BadRequest(c, "Cover is too large")
+	}
+
+	crop, err := cropFromForm(c)
+	if err != nil {
+		return "", "", true, utils.ValidationError(c, map[string]string{"crop": err.Error()})
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return "", "", true, utils.BadRequest(c, "Could not read upload")
+	}
+	defer file.Close()
+
+	data, tooLarge, err := utils.ReadLimited(file, maxBytes)
+	if err != nil {
+		return "", "", true, utils.BadRequest(c, "Could not read upload")
+	}
+	if tooLarge {
+		return "", "", true, utils.BadRequest(c, "Cover is too large")
+	}
+
+	cover, contentType, err := utils.ProcessCover(data, crop)
+	if err != nil {
+		return "", "", true, utils.ValidationError(c, map[string]string{"cover": err.Error()})
+	}
+
+	if contentType == "image/png" {
+		key += ".png"
+	} else {
+		key += ".jpg"
+	}
+	url, err := storage.Default.Put(c.UserContext(), key, cover, contentType)
+	if err != nil {
+		return "", "", true, utils.InternalServerError(c, "Could not store cover")
+	}
+	return url, key, false, nil
+}
+
+// setCourseCover записывает новую обложку и удаляет предыдущий загруженный файл
+func (cc *CoversController) setCourseCover(c *fiber.Ctx, course *models.Course, url, key string, stockCoverID *uint) error {
+	previousKey := course.CoverKey
+	if err := cc.db(c).Model(course).Updates(map[string]interface{}{
+		"logo_url":       url,
+		"cover_key":      key,
+		"stock_cover_id": stockCoverID,
+	}).Error; err != nil {
+		return err
+	}
+
+	if previousKey != "" && previousKey != key {
+		storage.Default.Delete(c.UserContext(), previousKey)
+	}
+	return nil
+}
+
+// cropFromForm читает область обрезки из полей x, y, width, height; без них обрезка делается по центру
+func cropFromForm(c *fiber.Ctx) (image.Rectangle, error) {
+	if c.FormValue("width") == "" && c.FormValue("height") == "" {
+		return image.Rectangle{}, nil
+	}
+
+	var values [4]int
+	for i, field := range []string{"x", "y", "width", "height"} {
+		value, err := strconv.Atoi(c.FormValue(field, "0"))
+		if err != nil || value < 0 {
+			return image.Rectangle{}, fmt.Errorf("%s must be a non-negative integer", field)
+		}
+		values[i] = value
+	}
+	if values[2] == 0 || values[3] == 0 {
+		return image.Rectangle{}, utils.ErrInvalidCrop
+	}
+	return image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3]), nil
+}
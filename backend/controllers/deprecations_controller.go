@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/deprecation"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type DeprecationsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewDeprecationsController(db *gorm.DB, cfg *config.Config) *DeprecationsController {
+	return &DeprecationsController{DB: db, Cfg: cfg}
+}
+
+// GetDeprecationReport показывает устаревшие маршруты и клиентов, которые вызывали их за последние ?days= дней (30 по умолчанию)
+func (dc *DeprecationsController) GetDeprecationReport(c *fiber.Ctx) error {
+	days, _ := strconv.Atoi(c.Query("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+
+	from := time.Now().AddDate(0, 0, -(days - 1))
+	// Calls counted on this instance since the last flush belong in the report too
+	if err := deprecation.Flush(c.UserContext(), dc.DB); err != nil {
+		return utils.InternalServerError(c, "Failed to record deprecated route calls")
+	}
+	report, err := deprecation.Report(dc.DB.WithContext(c.UserContext()), from)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to build deprecation report")
+	}
+
+	return utils.Success(c, fiber.StatusOK, report, fiber.Map{"days": days})
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/llm"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDistractors = 3
+	maxDistractors     = 8
+)
+
+const distractorsSystemPrompt = "You help teachers write multiple-choice quizzes. " +
+	"Given a question and its correct answer, suggest plausible but clearly wrong answer options. " +
+	"Match the language, length and style of the correct answer. Never repeat the correct answer or the options already given. " +
+	"Reply with a JSON array of strings and nothing else."
+
+type DistractorsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewDistractorsController(db *gorm.DB, cfg *config.Config) *DistractorsController {
+	return &DistractorsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (dc *DistractorsController) db(c *fiber.Ctx) *gorm.DB {
+	return dc.DB.WithContext(c.UserContext())
+}
+
+// SuggestDistractors предлагает неправильные варианты ответа для вопроса теста.
+// Для сохраненного вопроса (:questionId) текст и правильный ответ берутся из него, поля запроса их переопределяют;
+// без :questionId — для черновика, который автор еще не сохранил. Предложения только возвращаются, в вопрос они не записываются.
+func (dc *DistractorsController) SuggestDistractors(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, dc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var input struct {
+		Question      string   `json:"question"`
+		CorrectAnswer string   `json:"correct_answer"`
+		Options       []string `json:"options"` // options already written, so suggestions don't repeat them
+		Count         int      `json:"count"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.BadRequest(c, "Cannot parse JSON")
+		}
+	}
+
+	var test models.Test
+	if err := dc.db(c).First(&test, testID).Error; err != nil {
+		return utils.NotFound(c, "Test not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit this test"))
+	}
+
+	if c.Params("questionId") != "" {
+		var question models.TestQuestion
+		if err := dc.db(c).Where("id = ? AND test_id = ?", c.Params("questionId"), test.ID).First(&question).Error; err != nil {
+			return utils.NotFound(c, "Question not found")
+		}
+
+		var options []string
+		json.Unmarshal([]byte(question.Options), &options)
+		if input.Question == "" {
+			input.Question = question.Question
+		}
+		if input.CorrectAnswer == "" && question.CorrectAnswer >= 0 && question.CorrectAnswer < len(options) {
+			input.CorrectAnswer = options[question.CorrectAnswer]
+		}
+		if input.Options == nil {
+			input.Options = options
+		}
+	}
+
+	input.Question = strings.TrimSpace(input.Question)
+	input.CorrectAnswer = strings.TrimSpace(input.CorrectAnswer)
+	errs := map[string]string{}
+	if input.Question == "" {
+		errs["question"] = "Question is required"
+	}
+	if input.CorrectAnswer == "" {
+		errs["correct_answer"] = "Correct answer is required"
+	}
+	if input.Count == 0 {
+		input.Count = defaultDistractors
+	}
+	if input.Count < 1 || input.Count > maxDistractors {
+		errs["count"] = fmt.Sprintf("Count must be between 1 and %d", maxDistractors)
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	reply, err := llm.Default.Complete(c.UserContext(), distractorsSystemPrompt, distractorsPrompt(input.Question, input.CorrectAnswer, input.Options, input.Count))
+	switch {
+	case errors.Is(err, llm.ErrDisabled):
+		return utils.Error(c, fiber.StatusServiceUnavailable, errors.New("Suggestions are not available on this server"))
+	case err != nil:
+		return utils.Error(c, fiber.StatusBadGateway, errors.New("Could not get suggestions, please try again"))
+	}
+
+	suggestions, err := parseDistractors(reply, append([]string{input.CorrectAnswer}, input.Options...), input.Count)
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadGateway, errors.New("Could not get suggestions, please try again"))
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"question":       input.Question,
+		"correct_answer": input.CorrectAnswer,
+		"suggestions":    suggestions,
+	})
+}
+
+func distractorsPrompt(question, correct string, existing []string, count int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Question: %s\nCorrect answer: %s\n", question, correct)
+	if len(existing) > 0 {
+		fmt.Fprintf(&b, "Options already written: %s\n", strings.Join(existing, "; "))
+	}
+	fmt.Fprintf(&b, "Suggest %d wrong answer options.", count)
+	return b.String()
+}
+
+// parseDistractors достает JSON-массив из ответа модели и отбрасывает пустые варианты, повторы
+// и совпадения с exclude (правильным ответом и уже написанными вариантами) без учета регистра
+func parseDistractors(reply string, exclude []string, count int) ([]string, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON array in the reply")
+	}
+	var raw []string
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &raw); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(exclude)+len(raw))
+	for _, option := range exclude {
+		seen[strings.ToLower(strings.TrimSpace(option))] = true
+	}
+	suggestions := []string{}
+	for _, option := range raw {
+		option = strings.TrimSpace(option)
+		key := strings.ToLower(option)
+		if option == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		suggestions = append(suggestions, option)
+		if len(suggestions) == count {
+			break
+		}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/jobs"
+	"project/backend/llm"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const defaultDuplicateThreshold = 0.9
+
+// duplicatePairsQuery — пары курсов из content_similarities выше порога: сходство описаний и сколько уроков
+// каждого курса нашлось в другом; удаленные курсы и уроки не учитываются. lesson_share — доля уроков
+// меньшего курса, найденных в другом, score — большее из нее и сходства описаний.
+const duplicatePairsQuery = `
+WITH matches AS (
+	SELECT s.course_a, s.course_b,
+		MAX(s.similarity) FILTER (WHERE s.subject_type = @course) AS description,
+		COUNT(DISTINCT s.subject_a) FILTER (WHERE s.subject_type = @lesson) AS lessons_a,
+		COUNT(DISTINCT s.subject_b) FILTER (WHERE s.subject_type = @lesson) AS lessons_b
+	FROM content_similarities s
+	JOIN courses ca ON ca.id = s.course_a AND ca.deleted_at IS NULL
+	JOIN courses cb ON cb.id = s.course_b AND cb.deleted_at IS NULL
+	LEFT JOIN lessons la ON s.subject_type = @lesson AND la.id = s.subject_a AND la.deleted_at IS NULL
+	LEFT JOIN lessons lb ON s.subject_type = @lesson AND lb.id = s.subject_b AND lb.deleted_at IS NULL
+	WHERE s.similarity >= @threshold
+		AND (s.subject_type = @course OR (la.id IS NOT NULL AND lb.id IS NOT NULL))
+	GROUP BY s.course_a, s.course_b
+), totals AS (
+	SELECT course_id, COUNT(*) AS total FROM lessons WHERE deleted_at IS NULL GROUP BY course_id
+), scored AS (
+	SELECT m.course_a, m.course_b, m.description,
+		CASE
+			WHEN LEAST(COALESCE(ta.total, 0), COALESCE(tb.total, 0)) = 0 THEN 0
+			WHEN COALESCE(ta.total, 0) <= COALESCE(tb.total, 0) THEN LEAST(m.lessons_a::float / ta.total, 1)
+			ELSE LEAST(m.lessons_b::float / tb.total, 1)
+		END AS share
+	FROM matches m
+	LEFT JOIN totals ta ON ta.course_id = m.course_a
+	LEFT JOIN totals tb ON tb.course_id = m.course_b
+)
+SELECT course_a, course_b, description, share, GREATEST(share, COALESCE(description, 0)) AS score FROM scored`
+
+type DuplicatesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewDuplicatesController(db *gorm.DB, cfg *config.Config) *DuplicatesController {
+	return &DuplicatesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (dc *DuplicatesController) db(c *fiber.Ctx) *gorm.DB {
+	return dc.DB.WithContext(c.UserContext())
+}
+
+// duplicatePair — два курса с похожим описанием или общими уроками
+type duplicatePair struct {
+	CourseA     uint
+	CourseB     uint
+	Description *float64
+	Share       float64
+	Score       float64
+}
+
+// GetDuplicateReport находит курсы с по существу одинаковым контентом: похожие описания (?threshold=, по умолчанию 0.9)
+// и уроки, повторяющиеся в разных курсах, постранично (page, page_size). Похожие пары заранее находит фоновая
+// задача; новые тексты попадают в отчет после ее запуска или POST /refresh.
+func (dc *DuplicatesController) GetDuplicateReport(c *fiber.Ctx) error {
+	threshold := defaultDuplicateThreshold
+	if value := c.Query("threshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < jobs.SimilarityFloor || parsed > 1 {
+			return utils.ValidationError(c, map[string]string{
+				"threshold": fmt.Sprintf("Threshold must be between %g and 1", jobs.SimilarityFloor),
+			})
+		}
+		threshold = parsed
+	}
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	embedder, ok := llm.Default.(llm.Embedder)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return utils.Error(c, fiber.StatusServiceUnavailable, errors.New("Embeddings are not configured on this server"))
+	}
+	model := embedder.EmbeddingModel()
+
+	params := map[string]interface{}{"threshold": threshold, "course": models.EmbeddingCourse, "lesson": models.EmbeddingLesson}
+	var total int64
+	if err := dc.db(c).Raw("SELECT COUNT(*) FROM ("+duplicatePairsQuery+") AS pairs", params).Scan(&total).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to load duplicates")
+	}
+	params["limit"], params["offset"] = pageSize, (page-1)*pageSize
+	var pairs []duplicatePair
+	if err := dc.db(c).Raw(duplicatePairsQuery+" ORDER BY score DESC, course_a, course_b LIMIT @limit OFFSET @offset", params).
+		Scan(&pairs).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to load duplicates")
+	}
+
+	lessons, err := dc.duplicatedLessons(c, pairs, threshold)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to load duplicates")
+	}
+	summaries, err := dc.courseSummaries(c, pairs)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to load courses")
+	}
+
+	report := make([]fiber.Map, 0, len(pairs))
+	for _, pair := range pairs {
+		key := [2]uint{pair.CourseA, pair.CourseB}
+		if lessons[key] == nil {
+			lessons[key] = []fiber.Map{}
+		}
+		report = append(report, fiber.Map{
+			"courses":                []fiber.Map{summaries[pair.CourseA], summaries[pair.CourseB]},
+			"description_similarity": pair.Description,
+			"duplicated_lessons":     lessons[key],
+			"lesson_share":           pair.Share,
+			"score":                  pair.Score,
+		})
+	}
+
+	var compared []struct {
+		SubjectType string
+		Total       int64
+	}
+	if err := dc.db(c).Model(&models.ContentEmbedding{}).Select("subject_type, COUNT(*) AS total").
+		Where("embed_model = ? AND compared_at IS NOT NULL", model).Group("subject_type").Scan(&compared).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to load embeddings")
+	}
+	counts := fiber.Map{"courses": 0, "lessons": 0}
+	for _, row := range compared {
+		counts[row.SubjectType+"s"] = row.Total
+	}
+	pending, err := jobs.PendingEmbeddings(dc.db(c), model)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to load embeddings")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"threshold": threshold,
+		"model":     model,
+		"compared":  counts,
+		"pending":   pending,
+		"pairs":     report,
+	}, fiber.Map{"total": total, "page": page, "page_size": pageSize})
+}
+
+// RefreshDuplicateReport пересчитывает и сравнивает векторы новых и измененных курсов и уроков, не дожидаясь фоновой задачи
+func (dc *DuplicatesController) RefreshDuplicateReport(c *fiber.Ctx) error {
+	embedder, ok := llm.Default.(llm.Embedder)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return utils.Error(c, fiber.StatusServiceUnavailable, errors.New("Embeddings are not configured on this server"))
+	}
+
+	refreshed, err := jobs.RefreshEmbeddingsNow(c.UserContext(), dc.DB)
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadGateway, errors.New("Could not compute embeddings, please try again"))
+	}
+	pending, err := jobs.PendingEmbeddings(dc.db(c), embedder.EmbeddingModel())
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to load embeddings")
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"refreshed": refreshed, "pending": pending})
+}
+
+// duplicatedLessons — совпавшие уроки пар страницы выше порога, по паре курсов
+func (dc *DuplicatesController) duplicatedLessons(c *fiber.Ctx, pairs []duplicatePair, threshold float64) (map[[2]uint][]fiber.Map, error) {
+	result := make(map[[2]uint][]fiber.Map, len(pairs))
+	if len(pairs) == 0 {
+		return result, nil
+	}
+	keys := make([][]interface{}, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = []interface{}{pair.CourseA, pair.CourseB}
+	}
+
+	var rows []struct {
+		CourseA, CourseB uint
+		LessonA, LessonB uint
+		TitleA, TitleB   string
+		Similarity       float64
+	}
+	if err := dc.db(c).Table("content_similarities s").
+		Select("s.course_a, s.course_b, la.id AS lesson_a, lb.id AS lesson_b, la.title AS title_a, lb.title AS title_b, s.similarity").
+		Joins("JOIN lessons la ON la.id = s.subject_a AND la.deleted_at IS NULL").
+		Joins("JOIN lessons lb ON lb.id = s.subject_b AND lb.deleted_at IS NULL").
+		Where("s.subject_type = ? AND s.similarity >= ? AND (s.course_a, s.course_b) IN ?", models.EmbeddingLesson, threshold, keys).
+		Order("s.similarity DESC, la.id, lb.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		key := [2]uint{row.CourseA, row.CourseB}
+		result[key] = append(result[key], fiber.Map{
+			"lesson_a":   fiber.Map{"id": row.LessonA, "title": row.TitleA},
+			"lesson_b":   fiber.Map{"id": row.LessonB, "title": row.TitleB},
+			"similarity": row.Similarity,
+		})
+	}
+	return result, nil
+}
+
+// courseSummaries — что нужно знать о курсах пары, чтобы решить, какой объединить или снять: статус, автор, число студентов
+func (dc *DuplicatesController) courseSummaries(c *fiber.Ctx, pairs []duplicatePair) (map[uint]fiber.Map, error) {
+	ids := make([]uint, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		ids = append(ids, pair.CourseA, pair.CourseB)
+	}
+	ids = uniqueIDs(ids)
+	summaries := make(map[uint]fiber.Map, len(ids))
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+
+	var courses []models.Course
+	if err := dc.db(c).Where("id IN ?", ids).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	type count struct {
+		CourseID uint
+		Total    int
+	}
+	var lessonCounts, enrollmentCounts []count
+	if err := dc.db(c).Model(&models.Lesson{}).Select("course_id, COUNT(*) AS total").
+		Where("course_id IN ?", ids).Group("course_id").Scan(&lessonCounts).Error; err != nil {
+		return nil, err
+	}
+	if err := dc.db(c).Model(&models.UserCourseProgress{}).Select("course_id, COUNT(*) AS total").
+		Where("course_id IN ?", ids).Group("course_id").Scan(&enrollmentCounts).Error; err != nil {
+		return nil, err
+	}
+	lessons := map[uint]int{}
+	for _, cnt := range lessonCounts {
+		lessons[cnt.CourseID] = cnt.Total
+	}
+	enrollments := map[uint]int{}
+	for _, cnt := range enrollmentCounts {
+		enrollments[cnt.CourseID] = cnt.Total
+	}
+
+	for _, course := range courses {
+		summaries[course.ID] = fiber.Map{
+			"id":          course.ID,
+			"title":       course.Title,
+			"status":      course.Status,
+			"author":      course.AuthorID,
+			"university":  course.University,
+			"lessons":     lessons[course.ID],
+			"enrollments": enrollments[course.ID],
+			"updated_at":  course.UpdatedAt,
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,751 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"project/backend/cache"
+	"project/backend/completion"
+	"project/backend/config"
+	"project/backend/events"
+	"project/backend/grading"
+	"project/backend/integrity"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/rankings"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errExamTimeUp      = errors.New("Time for this exam is up")
+	errExamInvalidated = errors.New("Your attempt was invalidated by a proctor")
+	errExamPaused      = errors.New("The attempt is paused, resume it to continue")
+)
+
+type ExamSessionsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewExamSessionsController(db *gorm.DB, cfg *config.Config) *ExamSessionsController {
+	return &ExamSessionsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ec *ExamSessionsController) db(c *fiber.Ctx) *gorm.DB {
+	return ec.DB.WithContext(c.UserContext())
+}
+
+// CreateExamSession назначает экзаменационную сессию по тесту
+func (ec *ExamSessionsController) CreateExamSession(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var test models.Test
+	if err := ec.db(c).First(&test, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Test not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit this test"))
+	}
+
+	var input struct {
+		Title           string    `json:"title"`
+		StartsAt        time.Time `json:"starts_at"`
+		EndsAt          time.Time `json:"ends_at"`
+		DurationMinutes int       `json:"duration_minutes"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	errs := map[string]string{}
+	if input.StartsAt.IsZero() || input.EndsAt.IsZero() {
+		errs["starts_at"] = "starts_at and ends_at are required"
+	} else if !input.EndsAt.After(input.StartsAt) {
+		errs["ends_at"] = "ends_at must be after starts_at"
+	}
+	if input.DurationMinutes < 0 {
+		errs["duration_minutes"] = "duration_minutes can't be negative"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	session := models.ExamSession{
+		TestID:          test.ID,
+		Title:           strings.TrimSpace(input.Title),
+		StartsAt:        input.StartsAt,
+		EndsAt:          input.EndsAt,
+		DurationMinutes: input.DurationMinutes,
+		CreatedBy:       userID,
+	}
+	if session.Title == "" {
+		session.Title = test.Title
+	}
+	if err := ec.db(c).Create(&session).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create exam session")
+	}
+
+	return utils.Created(c, examSessionCard(session))
+}
+
+// GetTestExamSessions возвращает расписание сессий теста
+func (ec *ExamSessionsController) GetTestExamSessions(c *fiber.Ctx) error {
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var sessions []models.ExamSession
+	if err := ec.db(c).Where("test_id = ?", testID).Order("starts_at").Find(&sessions).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch exam sessions")
+	}
+
+	result := make([]fiber.Map, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, examSessionCard(session))
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// GetExamSessionAttempts — ход сессии для прокторов: кто начал, сколько осталось времени, кто сдал
+func (ec *ExamSessionsController) GetExamSessionAttempts(c *fiber.Ctx) error {
+	session, _, done, err := ec.authorizedSession(c, policy.ActionViewAnalytics)
+	if done {
+		return err
+	}
+
+	type row struct {
+		models.ExamAttempt
+		Username string
+		Score    *float64
+	}
+	var rows []row
+	if err := ec.db(c).Table("exam_attempts").
+		Select("exam_attempts.*, users.username, user_test_progress.score").
+		Joins("JOIN users ON users.id = exam_attempts.user_id").
+		Joins("LEFT JOIN user_test_progress ON user_test_progress.user_id = exam_attempts.user_id AND user_test_progress.test_id = ? AND user_test_progress.deleted_at IS NULL", session.TestID).
+		Where("exam_attempts.session_id = ? AND exam_attempts.deleted_at IS NULL", session.ID).
+		Order("exam_attempts.started_at").
+		Scan(&rows).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch attempts")
+	}
+
+	now := time.Now()
+	attempts := make([]fiber.Map, 0, len(rows))
+	for _, r := range rows {
+		deadline := r.Deadline(*session)
+		item := fiber.Map{
+			"user_id":       r.UserID,
+			"username":      r.Username,
+			"status":        examAttemptStatus(r.ExamAttempt, *session, now),
+			"started_at":    r.StartedAt,
+			"deadline":      deadline,
+			"seconds_left":  0,
+			"extra_minutes": r.ExtraMinutes,
+			"submitted_at":  r.SubmittedAt,
+			"score":         nil,
+		}
+		if r.SubmittedAt != nil && r.InvalidatedAt == nil {
+			item["score"] = r.Score
+		}
+		if r.SubmittedAt == nil && r.InvalidatedAt == nil && deadline.After(now) {
+			item["seconds_left"] = int(deadline.Sub(now).Seconds())
+		}
+		if r.Paused(now) {
+			// The clock is stopped: the time left is what remains once the pause ends
+			item["seconds_left"] = int(deadline.Sub(*r.PauseEndsAt).Seconds())
+			item["resume_by"] = r.PauseEndsAt
+		}
+		item["pauses"] = r.Pauses
+		item["paused_seconds"] = int(r.PauseTime().Seconds())
+		if r.InvalidatedAt != nil {
+			item["invalidated_at"] = r.InvalidatedAt
+			item["invalidation_reason"] = r.InvalidationReason
+		}
+		attempts = append(attempts, item)
+	}
+
+	data := examSessionCard(*session)
+	takingNow, err := events.TestTakers.Count(ec.db(c), events.Topic("test", session.TestID))
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch presence")
+	}
+	data["taking_now"] = takingNow
+	data["attempts"] = attempts
+	return utils.Success(c, fiber.StatusOK, data)
+}
+
+// ExtendExamTime добавляет студенту минуты сверх ограничений сессии
+func (ec *ExamSessionsController) ExtendExamTime(c *fiber.Ctx) error {
+	session, actorID, done, err := ec.authorizedSession(c, policy.ActionExtendTime)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Minutes int `json:"minutes"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Minutes <= 0 {
+		return utils.ValidationError(c, map[string]string{"minutes": "minutes must be positive"})
+	}
+
+	attempt, done, err := ec.findAttempt(c, session)
+	if done {
+		return err
+	}
+	if attempt.SubmittedAt != nil || attempt.InvalidatedAt != nil {
+		return utils.BadRequest(c, "Attempt is already finished")
+	}
+
+	attempt.ExtraMinutes += input.Minutes
+	attempt.ExtendedBy = &actorID
+	if err := ec.db(c).Model(attempt).Updates(map[string]interface{}{
+		"extra_minutes": attempt.ExtraMinutes,
+		"extended_by":   actorID,
+	}).Error; err != nil {
+		return utils.InternalServerError(c, "Could not extend time")
+	}
+
+	deadline := attempt.Deadline(*session)
+	events.Publish(ec.db(c), events.Topic("test", session.TestID), "extension", fiber.Map{
+		"session_id": session.ID,
+		"user_id":    attempt.UserID,
+		"deadline":   deadline,
+	})
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"user_id":       attempt.UserID,
+		"extra_minutes": attempt.ExtraMinutes,
+		"deadline":      deadline,
+	})
+}
+
+// InvalidateExamAttempt аннулирует попытку студента: результат обнуляется и убирается из рейтинга
+func (ec *ExamSessionsController) InvalidateExamAttempt(c *fiber.Ctx) error {
+	session, actorID, done, err := ec.authorizedSession(c, policy.ActionInvalidate)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		return utils.ValidationError(c, map[string]string{"reason": "Reason is required"})
+	}
+
+	attempt, done, err := ec.findAttempt(c, session)
+	if done {
+		return err
+	}
+	if attempt.InvalidatedAt != nil {
+		return utils.BadRequest(c, "Attempt is already invalidated")
+	}
+
+	now := time.Now()
+	err = ec.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(attempt).Updates(map[string]interface{}{
+			"invalidated_at":      now,
+			"invalidated_by":      actorID,
+			"invalidation_reason": input.Reason,
+		}).Error; err != nil {
+			return err
+		}
+		if attempt.SubmittedAt == nil {
+			return nil
+		}
+
+		// The submitted result doesn't count; the attempt itself stays used
+		if err := tx.Model(&models.UserTestProgress{}).
+			Where("user_id = ? AND test_id = ?", attempt.UserID, session.TestID).
+			Updates(map[string]interface{}{"score": 0, "correct_answers": 0, "questions_answered": 0}).Error; err != nil {
+			return err
+		}
+		if err := rankings.RecordScore(tx, session.TestID, attempt.UserID); err != nil {
+			return err
+		}
+		if err := grading.RefreshTest(tx, session.TestID, attempt.UserID); err != nil {
+			return err
+		}
+		// A voided final test no longer completes its courses
+		_, err := completion.RefreshTest(tx, session.TestID, attempt.UserID)
+		return err
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not invalidate attempt")
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("test", session.TestID), "platform")
+	events.Publish(ec.db(c), events.Topic("test", session.TestID), "invalidation", fiber.Map{
+		"session_id": session.ID,
+		"user_id":    attempt.UserID,
+		"reason":     input.Reason,
+	})
+
+	return utils.NoContent(c)
+}
+
+// PauseExamAttempt ставит идущую попытку экзамена на паузу, если настройки теста это разрешают. Время на
+// паузе не входит в личный лимит сессии; пауза длится, пока студент не продолжит попытку по токену,
+// но не дольше остатка бюджета пауз, после чего время снова идет.
+func (ec *ExamSessionsController) PauseExamAttempt(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var settings models.TestAccessSettings
+	if err := ec.db(c).Where("test_id = ?", testID).Limit(1).Find(&settings).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	if settings.MaxPauseMinutes <= 0 {
+		return utils.Forbidden(c, "Pausing is not allowed for this test")
+	}
+
+	token, err := generateResumeToken()
+	if err != nil {
+		return utils.InternalServerError(c, "Could not pause attempt")
+	}
+
+	var session models.ExamSession
+	var attempt models.ExamAttempt
+	err = ec.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockExamAttempt(tx, uint(testID), userID, &session, &attempt); err != nil {
+			return err
+		}
+		now := time.Now()
+		if attempt.Paused(now) {
+			return fiber.NewError(fiber.StatusConflict, "The attempt is already paused")
+		}
+		if session.DurationMinutes == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "This exam has no per-student time limit to pause")
+		}
+
+		settlePause(&attempt, now)
+		budget := time.Duration(settings.MaxPauseMinutes)*time.Minute - time.Duration(attempt.PausedSeconds)*time.Second
+		if budget <= 0 {
+			return fiber.NewError(fiber.StatusConflict, "The pause budget for this attempt is used up")
+		}
+
+		pauseEndsAt := now.Add(budget)
+		attempt.PausedAt = &now
+		attempt.PauseEndsAt = &pauseEndsAt
+		attempt.Pauses++
+		attempt.ResumeToken = token
+		return saveExamPause(tx, &attempt)
+	})
+	if err != nil {
+		return ec.pauseError(c, err)
+	}
+
+	events.Publish(ec.db(c), events.Topic("test", session.TestID), "pause", fiber.Map{
+		"session_id": session.ID,
+		"user_id":    userID,
+		"resume_by":  attempt.PauseEndsAt,
+	})
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"resume_token": token,
+		"paused_at":    attempt.PausedAt,
+		"resume_by":    attempt.PauseEndsAt,
+		"seconds_left": int(attempt.Deadline(session).Sub(*attempt.PauseEndsAt).Seconds()),
+	})
+}
+
+// ResumeExamAttempt снимает попытку с паузы по токену, выданному при постановке на паузу.
+// В паузу засчитывается только фактически прошедшее время.
+func (ec *ExamSessionsController) ResumeExamAttempt(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var input struct {
+		ResumeToken string `json:"resume_token"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.ResumeToken == "" {
+		return utils.ValidationError(c, map[string]string{"resume_token": "Resume token is required"})
+	}
+
+	var session models.ExamSession
+	var attempt models.ExamAttempt
+	err = ec.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockExamAttempt(tx, uint(testID), userID, &session, &attempt); err != nil {
+			return err
+		}
+		if attempt.PausedAt == nil {
+			return fiber.NewError(fiber.StatusConflict, "The attempt is not paused")
+		}
+		if subtle.ConstantTimeCompare([]byte(input.ResumeToken), []byte(attempt.ResumeToken)) != 1 {
+			return fiber.NewError(fiber.StatusForbidden, "Invalid resume token")
+		}
+
+		settlePause(&attempt, time.Now())
+		return saveExamPause(tx, &attempt)
+	})
+	if err != nil {
+		return ec.pauseError(c, err)
+	}
+
+	deadline := attempt.Deadline(session)
+	events.Publish(ec.db(c), events.Topic("test", session.TestID), "resume", fiber.Map{
+		"session_id": session.ID,
+		"user_id":    userID,
+		"deadline":   deadline,
+	})
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"status":         examAttemptStatus(attempt, session, time.Now()),
+		"deadline":       deadline,
+		"seconds_left":   max(0, int(time.Until(deadline).Seconds())),
+		"paused_seconds": attempt.PausedSeconds,
+	})
+}
+
+func (ec *ExamSessionsController) pauseError(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &fiberErr):
+		return utils.Error(c, fiberErr.Code, fiberErr)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "No exam attempt in progress")
+	case errors.Is(err, errExamTimeUp), errors.Is(err, errExamInvalidated):
+		return utils.Forbidden(c, err.Error())
+	}
+	return utils.InternalServerError(c, "Could not query database")
+}
+
+// GetExamSessionProctors возвращает прокторов сессии
+func (ec *ExamSessionsController) GetExamSessionProctors(c *fiber.Ctx) error {
+	session, _, done, err := ec.authorizedSession(c, policy.ActionManageStaff)
+	if done {
+		return err
+	}
+
+	type proctor struct {
+		UserID    uint      `json:"user_id"`
+		Username  string    `json:"username"`
+		AddedBy   uint      `json:"added_by"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	var proctors []proctor
+	if err := ec.db(c).Table("exam_session_proctors").
+		Select("exam_session_proctors.user_id, users.username, exam_session_proctors.added_by, exam_session_proctors.created_at").
+		Joins("JOIN users ON users.id = exam_session_proctors.user_id").
+		Where("exam_session_proctors.session_id = ? AND exam_session_proctors.deleted_at IS NULL", session.ID).
+		Order("exam_session_proctors.created_at").
+		Scan(&proctors).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch proctors")
+	}
+
+	return utils.Success(c, fiber.StatusOK, proctors)
+}
+
+// AddExamSessionProctor назначает проктора на сессию
+func (ec *ExamSessionsController) AddExamSessionProctor(c *fiber.Ctx) error {
+	session, actorID, done, err := ec.authorizedSession(c, policy.ActionManageStaff)
+	if done {
+		return err
+	}
+
+	var input struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var user models.User
+	if err := ec.db(c).Select("id", "username").First(&user, input.UserID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	proctor := models.ExamSessionProctor{SessionID: session.ID, UserID: user.ID, AddedBy: actorID}
+	if err := ec.db(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&proctor).Error; err != nil {
+		return utils.InternalServerError(c, "Could not add proctor")
+	}
+
+	return utils.Created(c, fiber.Map{"session_id": session.ID, "user_id": user.ID, "username": user.Username})
+}
+
+// RemoveExamSessionProctor снимает проктора с сессии
+func (ec *ExamSessionsController) RemoveExamSessionProctor(c *fiber.Ctx) error {
+	session, _, done, err := ec.authorizedSession(c, policy.ActionManageStaff)
+	if done {
+		return err
+	}
+
+	userID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	// Hard delete: the (session, user) pair is unique, so a soft-deleted row would block re-adding
+	result := ec.db(c).Unscoped().Where("session_id = ? AND user_id = ?", session.ID, userID).Delete(&models.ExamSessionProctor{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not remove proctor")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "Proctor not found")
+	}
+
+	return utils.NoContent(c)
+}
+
+// GetIntegrityReport отдает файлом отчет о честности сдачи. Отчет строится после закрытия сессии
+// (с учетом продлений); если фоновая задача еще не успела, он строится по запросу.
+func (ec *ExamSessionsController) GetIntegrityReport(c *fiber.Ctx) error {
+	session, _, done, err := ec.authorizedSession(c, policy.ActionViewAnalytics)
+	if done {
+		return err
+	}
+
+	stored, err := integrity.Find(ec.db(c), session.ID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch integrity report")
+	}
+	if stored == nil {
+		now := time.Now()
+		closesAt, err := integrity.ClosesAt(ec.db(c), *session)
+		if err != nil {
+			return utils.InternalServerError(c, "Failed to fetch integrity report")
+		}
+		if closesAt.After(now) {
+			return utils.Error(c, fiber.StatusConflict, errors.New("The integrity report is available once the session closes"),
+				fiber.Map{"closes_at": closesAt})
+		}
+		if stored, err = integrity.Generate(ec.db(c), *session, now); err != nil {
+			return utils.InternalServerError(c, "Could not generate integrity report")
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	c.Attachment(fmt.Sprintf("exam_session_%d_integrity.json", session.ID))
+	return c.SendString(stored.Report)
+}
+
+// authorizedSession загружает сессию из :id вместе с тестом и проверяет право на action
+func (ec *ExamSessionsController) authorizedSession(c *fiber.Ctx, action policy.Action) (*models.ExamSession, uint, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return nil, 0, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	sessionID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 0, true, utils.BadRequest(c, "Invalid session ID")
+	}
+
+	var session models.ExamSession
+	if err := ec.db(c).First(&session, sessionID).Error; err != nil {
+		return nil, 0, true, utils.NotFound(c, "Exam session not found")
+	}
+	var test models.Test
+	if err := ec.db(c).Preload("AccessSettings").First(&test, session.TestID).Error; err != nil {
+		return nil, 0, true, utils.NotFound(c, "Test not found")
+	}
+
+	if err := policy.Authorize(c, action, policy.ExamSession(&session, &test)); err != nil {
+		return nil, 0, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to administer this exam session"))
+	}
+	return &session, userID, false, nil
+}
+
+// findAttempt загружает попытку студента :userId в сессии
+func (ec *ExamSessionsController) findAttempt(c *fiber.Ctx, session *models.ExamSession) (*models.ExamAttempt, bool, error) {
+	studentID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid user ID")
+	}
+
+	var attempt models.ExamAttempt
+	if err := ec.db(c).Where("session_id = ? AND user_id = ?", session.ID, studentID).First(&attempt).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Attempt not found")
+	}
+	return &attempt, false, nil
+}
+
+// startExamAttempt начинает попытку студента, если по тесту сейчас идет сессия.
+// Повторное открытие теста возвращает уже начатую попытку.
+func startExamAttempt(db *gorm.DB, testID, userID uint, ip string) (*models.ExamSession, *models.ExamAttempt, error) {
+	now := time.Now()
+	var session models.ExamSession
+	err := db.Where("test_id = ? AND starts_at <= ? AND ends_at > ?", testID, now, now).
+		Order("starts_at DESC").First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attempt := models.ExamAttempt{SessionID: session.ID, UserID: userID, StartedAt: now, StartIP: ip}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&attempt).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := db.Where("session_id = ? AND user_id = ?", session.ID, userID).First(&attempt).Error; err != nil {
+		return nil, nil, err
+	}
+	return &session, &attempt, nil
+}
+
+// currentExamAttempt возвращает несданную попытку студента в сессии по тесту или nil, если ее нет
+func currentExamAttempt(db *gorm.DB, testID, userID uint) (*models.ExamAttempt, error) {
+	var attempt models.ExamAttempt
+	err := db.Joins("JOIN exam_sessions ON exam_sessions.id = exam_attempts.session_id AND exam_sessions.deleted_at IS NULL").
+		Where("exam_sessions.test_id = ? AND exam_attempts.user_id = ? AND exam_attempts.submitted_at IS NULL", testID, userID).
+		Order("exam_attempts.started_at DESC").First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// checkExamSubmission проверяет попытку студента перед приемом ответов. Если тест идет в сессии, а студент
+// его не открывал, попытка начинается сейчас, чтобы ответы все равно прошли проверку срока.
+// Возвращает nil, если студент сдает тест вне сессии.
+func checkExamSubmission(db *gorm.DB, testID, userID uint, ip string) (*models.ExamAttempt, error) {
+	attempt, err := currentExamAttempt(db, testID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == nil {
+		// Submitting without opening the test must not skip the session's clock
+		if _, attempt, err = startExamAttempt(db, testID, userID, ip); attempt == nil || err != nil {
+			return nil, err
+		}
+		if attempt.SubmittedAt != nil {
+			return nil, errAttemptFinished
+		}
+	}
+
+	if attempt.InvalidatedAt != nil {
+		return nil, errExamInvalidated
+	}
+	var session models.ExamSession
+	if err := db.First(&session, attempt.SessionID).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if attempt.Paused(now) {
+		return nil, errExamPaused
+	}
+	if now.After(attempt.Deadline(session)) {
+		return nil, errExamTimeUp
+	}
+	return attempt, nil
+}
+
+// lockExamAttempt загружает и блокирует идущую попытку студента вместе с ее сессией.
+// Сданная или несуществующая попытка дает gorm.ErrRecordNotFound.
+func lockExamAttempt(tx *gorm.DB, testID, userID uint, session *models.ExamSession, attempt *models.ExamAttempt) error {
+	current, err := currentExamAttempt(tx, testID, userID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return gorm.ErrRecordNotFound
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(attempt, current.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.First(session, attempt.SessionID).Error; err != nil {
+		return err
+	}
+	if attempt.InvalidatedAt != nil {
+		return errExamInvalidated
+	}
+	if !attempt.Paused(time.Now()) && time.Now().After(attempt.Deadline(*session)) {
+		return errExamTimeUp
+	}
+	return nil
+}
+
+// settlePause закрывает паузу попытки: в бюджет идет время до now, но не дольше PauseEndsAt
+func settlePause(attempt *models.ExamAttempt, now time.Time) {
+	if attempt.PausedAt == nil {
+		return
+	}
+	end := now
+	if attempt.PauseEndsAt != nil && attempt.PauseEndsAt.Before(end) {
+		end = *attempt.PauseEndsAt
+	}
+	attempt.PausedSeconds += int(end.Sub(*attempt.PausedAt).Seconds())
+	attempt.PausedAt = nil
+	attempt.PauseEndsAt = nil
+	attempt.ResumeToken = ""
+}
+
+func saveExamPause(tx *gorm.DB, attempt *models.ExamAttempt) error {
+	return tx.Model(attempt).Select("paused_at", "pause_ends_at", "paused_seconds", "pauses", "resume_token").Updates(attempt).Error
+}
+
+func generateResumeToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func examAttemptStatus(attempt models.ExamAttempt, session models.ExamSession, now time.Time) string {
+	switch {
+	case attempt.InvalidatedAt != nil:
+		return "invalidated"
+	case attempt.SubmittedAt != nil:
+		return "submitted"
+	case attempt.Paused(now):
+		return "paused"
+	case now.After(attempt.Deadline(session)):
+		return "expired"
+	default:
+		return "in_progress"
+	}
+}
+
+func examSessionCard(session models.ExamSession) fiber.Map {
+	return fiber.Map{
+		"id":               session.ID,
+		"test_id":          session.TestID,
+		"title":            session.Title,
+		"starts_at":        session.StartsAt,
+		"ends_at":          session.EndsAt,
+		"duration_minutes": session.DurationMinutes,
+	}
+}
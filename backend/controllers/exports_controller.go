@@ -0,0 +1,512 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project/backend/config"
+	"project/backend/export"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/storage"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExportsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewExportsController(db *gorm.DB, cfg *config.Config) *ExportsController {
+	return &ExportsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ec *ExportsController) db(c *fiber.Ctx) *gorm.DB {
+	return ec.DB.WithContext(c.UserContext())
+}
+
+// ExportCourseGradebook отдает CSV с прогрессом всех студентов курса потоком;
+// ?format=canvas|moodle|custom отдает ту же ведомость в колонках SIS (json и markdown перехватывает BundlesController.ExportCourse)
+func (ec *ExportsController) ExportCourseGradebook(c *fiber.Ctx) error {
+	return ec.streamGradebook(c, models.ExportKindCourse)
+}
+
+// ExportTestGradebook отдает CSV с результатами всех студентов теста потоком
+func (ec *ExportsController) ExportTestGradebook(c *fiber.Ctx) error {
+	return ec.streamGradebook(c, models.ExportKindTest)
+}
+
+func (ec *ExportsController) streamGradebook(c *fiber.Ctx, kind string) error {
+	entityID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid ID")
+	}
+
+	gradebook, done, err := ec.resolve(c, kind, uint(entityID))
+	if done {
+		return err
+	}
+
+	filename := exportFilename(kind, uint(entityID))
+	if format := c.Query("format"); format != "" && kind == models.ExportKindCourse {
+		if gradebook, done, err = ec.sisGradebook(c, uint(entityID), format, nil); done {
+			return err
+		}
+		filename = sisFilename(format, uint(entityID))
+	}
+
+	ec.stream(c, gradebook, filename, fmt.Sprintf("%s/%d", kind, entityID))
+	return nil
+}
+
+// stream пишет выгрузку в ответ по мере чтения из базы
+func (ec *ExportsController) stream(c *fiber.Ctx, gradebook export.Gradebook, filename, name string) {
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Attachment(filename)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), export.JobTimeout)
+		defer cancel()
+
+		// Push every chunk to the client as soon as it's written
+		err := gradebook.Stream(ctx, ec.DB, w, func(int64) { w.Flush() })
+		if err != nil {
+			log.Printf("gradebook export %s failed: %v", name, err)
+		}
+		w.Flush()
+	})
+}
+
+// PullCourseGrades отдает оценки курса в формате SIS для ночной синхронизации.
+// Доступен только по ключу API интеграции; ?since= (RFC 3339) оставляет только изменившиеся строки.
+func (ec *ExportsController) PullCourseGrades(c *fiber.Ctx) error {
+	if c.Locals("api_key_id") == nil {
+		return utils.Forbidden(c, "This endpoint requires an integration API key")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+	if _, done, err := ec.resolve(c, models.ExportKindCourse, uint(courseID)); done {
+		return err
+	}
+
+	var since *time.Time
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return utils.ValidationError(c, map[string]string{"since": "since must be an RFC 3339 timestamp"})
+		}
+		since = &parsed
+	}
+
+	format := c.Query("format", export.SISFormatCanvas)
+	gradebook, done, err := ec.sisGradebook(c, uint(courseID), format, since)
+	if done {
+		return err
+	}
+
+	ec.stream(c, gradebook, sisFilename(format, uint(courseID)), fmt.Sprintf("sis/%d", courseID))
+	return nil
+}
+
+// GetSISMapping возвращает сохраненные колонки формата custom и доступные поля ведомости
+func (ec *ExportsController) GetSISMapping(c *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+	if _, done, err := ec.resolve(c, models.ExportKindCourse, uint(courseID)); done {
+		return err
+	}
+
+	columns, err := export.SavedSISColumns(ec.db(c), uint(courseID))
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch SIS mapping")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"columns": columns,
+		"fields":  export.SISFields(),
+		"presets": export.SISPresets,
+	})
+}
+
+// UpdateSISMapping сохраняет колонки формата custom
+func (ec *ExportsController) UpdateSISMapping(c *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+	if _, done, err := ec.resolve(c, models.ExportKindCourse, uint(courseID)); done {
+		return err
+	}
+
+	var input struct {
+		Columns []export.Column `json:"columns"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	fields := map[string]bool{"": true}
+	for _, field := range export.SISFields() {
+		fields[field] = true
+	}
+	errs := map[string]string{}
+	if len(input.Columns) == 0 {
+		errs["columns"] = "At least one column is required"
+	}
+	for i, column := range input.Columns {
+		if strings.TrimSpace(column.Header) == "" {
+			errs[fmt.Sprintf("columns.%d.header", i)] = "Header is required"
+		}
+		if !fields[column.Field] {
+			errs[fmt.Sprintf("columns.%d.field", i)] = "Unknown gradebook field"
+		}
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	encoded, _ := json.Marshal(input.Columns)
+	mapping := models.CourseSISMapping{CourseID: uint(courseID), Columns: string(encoded)}
+	if err := ec.db(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "course_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"columns", "updated_at"}),
+	}).Create(&mapping).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save SIS mapping")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"columns": input.Columns})
+}
+
+// sisGradebook выбирает колонки формата SIS; custom берет сохраненное соответствие курса
+func (ec *ExportsController) sisGradebook(c *fiber.Ctx, courseID uint, format string, since *time.Time) (export.Gradebook, bool, error) {
+	columns, ok := export.SISPresets[format]
+	if format == export.SISFormatCustom {
+		saved, err := export.SavedSISColumns(ec.db(c), courseID)
+		if err != nil {
+			return export.Gradebook{}, true, utils.InternalServerError(c, "Failed to fetch SIS mapping")
+		}
+		if len(saved) == 0 {
+			return export.Gradebook{}, true, utils.ValidationError(c, map[string]string{"format": "The course has no custom SIS mapping"})
+		}
+		columns, ok = saved, true
+	}
+	if !ok {
+		return export.Gradebook{}, true, utils.ValidationError(c, map[string]string{"format": "Format must be one of canvas, moodle, custom"})
+	}
+	return export.SISGradebook(courseID, columns, since), false, nil
+}
+
+// CreateExportJob ставит выгрузку в фон; прогресс можно отслеживать через GetExportJob
+func (ec *ExportsController) CreateExportJob(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Kind     string `json:"kind"`
+		EntityID uint   `json:"entity_id"`
+		Format   string `json:"format"` // SIS format, course exports only
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	if input.Kind != models.ExportKindCourse && input.Kind != models.ExportKindTest {
+		return utils.ValidationError(c, map[string]string{"kind": "Kind must be course or test"})
+	}
+
+	if _, done, err := ec.resolve(c, input.Kind, input.EntityID); done {
+		return err
+	}
+	if input.Kind != models.ExportKindCourse {
+		input.Format = ""
+	}
+	if input.Format != "" {
+		if _, done, err := ec.sisGradebook(c, input.EntityID, input.Format, nil); done {
+			return err
+		}
+	}
+
+	// The export-jobs worker picks the job up on whichever instance runs it next
+	job := models.ExportJob{
+		UserID:   userID,
+		Kind:     input.Kind,
+		EntityID: input.EntityID,
+		Format:   input.Format,
+		Status:   models.ExportStatusPending,
+	}
+	if err := ec.db(c).Create(&job).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create export job")
+	}
+
+	return utils.Success(c, fiber.StatusAccepted, job)
+}
+
+// GetExportJob возвращает состояние и прогресс фоновой выгрузки
+func (ec *ExportsController) GetExportJob(c *fiber.Ctx) error {
+	job, ok, err := ec.findJob(c)
+	if !ok {
+		return err
+	}
+
+	var percent float64
+	if job.Total > 0 {
+		percent = float64(job.Processed) / float64(job.Total) * 100
+	} else if job.Status == models.ExportStatusDone {
+		percent = 100
+	}
+
+	payload := fiber.Map{
+		"id":          job.ID,
+		"kind":        job.Kind,
+		"entity_id":   job.EntityID,
+		"status":      job.Status,
+		"processed":   job.Processed,
+		"total":       job.Total,
+		"percent":     percent,
+		"error":       job.Error,
+		"finished_at": job.FinishedAt,
+		"expires_at":  job.ExpiresAt,
+	}
+	// Organization archives are handed over to people without an account, so they get a signed link
+	if job.Kind == models.ExportKindUniversity && job.Status == models.ExportStatusDone {
+		url, expires := ec.downloadLink(job)
+		payload["download_url"] = url
+		payload["download_expires_at"] = expires
+	}
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// DownloadExportJob отдает файл завершенной фоновой выгрузки
+func (ec *ExportsController) DownloadExportJob(c *fiber.Ctx) error {
+	job, ok, err := ec.findJob(c)
+	if !ok {
+		return err
+	}
+
+	if job.Status != models.ExportStatusDone {
+		return utils.BadRequest(c, "Export is not finished yet")
+	}
+	return sendExport(c, job)
+}
+
+// sendExport отдает файл выгрузки из хранилища с типом содержимого по ее виду
+func sendExport(c *fiber.Ctx, job *models.ExportJob) error {
+	data, err := storage.Archive.Get(c.UserContext(), job.FileKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return utils.NotFound(c, "Export file is no longer available")
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not read export file")
+	}
+
+	switch job.Kind {
+	case models.ExportKindUniversity:
+		c.Attachment(fmt.Sprintf("university-%d-export.zip", job.EntityID))
+		c.Set(fiber.HeaderContentType, "application/zip")
+	case models.ExportKindUserData:
+		c.Attachment(fmt.Sprintf("user-%d-data.zip", job.EntityID))
+		c.Set(fiber.HeaderContentType, "application/zip")
+	default:
+		c.Attachment(exportFilename(job.Kind, job.EntityID))
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+	return c.Send(data)
+}
+
+// findJob загружает выгрузку текущего пользователя по :id
+func (ec *ExportsController) findJob(c *fiber.Ctx) (*models.ExportJob, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return nil, false, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	jobID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, false, utils.BadRequest(c, "Invalid export ID")
+	}
+
+	var job models.ExportJob
+	if err := ec.db(c).Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+		return nil, false, utils.NotFound(c, "Export not found")
+	}
+	return &job, true, nil
+}
+
+// resolve проверяет, что курс или тест существует и пользователь может выгружать его ведомость,
+// и возвращает описание выгрузки
+func (ec *ExportsController) resolve(c *fiber.Ctx, kind string, entityID uint) (export.Gradebook, bool, error) {
+	if kind == models.ExportKindTest {
+		var test models.Test
+		if err := ec.db(c).Select("id", "author_id", "university").First(&test, entityID).Error; err != nil {
+			return export.Gradebook{}, true, utils.NotFound(c, "Test not found")
+		}
+		if err := policy.Authorize(c, policy.ActionGrade, policy.Test(&test)); err != nil {
+			return export.Gradebook{}, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to export this gradebook"))
+		}
+		return export.TestGradebook(entityID), false, nil
+	}
+
+	var course models.Course
+	if err := ec.db(c).Select("id", "author_id", "university").First(&course, entityID).Error; err != nil {
+		return export.Gradebook{}, true, utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionGrade, policy.Course(&course)); err != nil {
+		return export.Gradebook{}, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to export this gradebook"))
+	}
+	return export.CourseGradebook(entityID), false, nil
+}
+
+// ExportUserData отдает ZIP-архив всех данных текущего пользователя.
+// Архив собирается в фоне: пока он не готов, возвращается 202 с прогрессом,
+// готовый архив отдается, пока хранится его файл (?refresh=true собирает новый).
+func (ec *ExportsController) ExportUserData(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var job models.ExportJob
+	err = ec.db(c).Where("user_id = ? AND kind = ?", userID, models.ExportKindUserData).
+		Order("id DESC").Limit(1).Find(&job).Error
+	if err != nil {
+		return utils.InternalServerError(c, "Could not query exports")
+	}
+
+	switch {
+	case job.ID != 0 && (job.Status == models.ExportStatusPending || job.Status == models.ExportStatusRunning):
+		return utils.Success(c, fiber.StatusAccepted, userDataJobPayload(job))
+	case job.ID != 0 && job.Status == models.ExportStatusDone && !c.QueryBool("refresh") &&
+		job.ExpiresAt != nil && time.Now().Before(*job.ExpiresAt):
+		return sendExport(c, &job)
+	}
+
+	job = models.ExportJob{
+		UserID:   userID,
+		Kind:     models.ExportKindUserData,
+		EntityID: userID,
+		Status:   models.ExportStatusPending,
+	}
+	if err := ec.db(c).Create(&job).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create export job")
+	}
+
+	return utils.Success(c, fiber.StatusAccepted, userDataJobPayload(job))
+}
+
+// ExportUniversity ставит в фон полную выгрузку университета для передачи данных при уходе с платформы.
+// Доступна администраторам платформы и ролям с разрешением university.export в этом университете.
+func (ec *ExportsController) ExportUniversity(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ec.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var university models.University
+	if err := ec.db(c).First(&university, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "University not found")
+	}
+	if !utils.HasScopedPermission(ec.db(c), userID, models.PermUniversityExport, university.Name) {
+		return utils.Forbidden(c, "You don't have permission to export this university")
+	}
+
+	// One archive at a time per university, it reads every course and test
+	var running int64
+	ec.db(c).Model(&models.ExportJob{}).
+		Where("kind = ? AND entity_id = ? AND status IN ?", models.ExportKindUniversity, university.ID,
+			[]string{models.ExportStatusPending, models.ExportStatusRunning}).
+		Count(&running)
+	if running > 0 {
+		return utils.Error(c, fiber.StatusConflict, errors.New("An export of this university is already running"), nil)
+	}
+
+	job := models.ExportJob{
+		UserID:   userID,
+		Kind:     models.ExportKindUniversity,
+		EntityID: university.ID,
+		Status:   models.ExportStatusPending,
+	}
+	if err := ec.db(c).Create(&job).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create export job")
+	}
+
+	return utils.Success(c, fiber.StatusAccepted, fiber.Map{
+		"id":         job.ID,
+		"status":     job.Status,
+		"status_url": fmt.Sprintf("/api/admin/exports/%d", job.ID),
+	})
+}
+
+// DownloadSignedExport отдает выгрузку по подписанной ссылке без авторизации
+func (ec *ExportsController) DownloadSignedExport(c *fiber.Ctx) error {
+	jobID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid export ID")
+	}
+
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if !export.VerifyDownload(ec.linkSecret(), uint(jobID), expires, c.Query("signature")) {
+		return utils.Forbidden(c, "The download link is invalid or has expired")
+	}
+
+	var job models.ExportJob
+	if err := ec.db(c).First(&job, jobID).Error; err != nil || job.Status != models.ExportStatusDone {
+		return utils.NotFound(c, "Export not found")
+	}
+	return sendExport(c, &job)
+}
+
+// downloadLink строит подписанную ссылку на файл выгрузки; ссылка не переживает сам файл
+func (ec *ExportsController) downloadLink(job *models.ExportJob) (string, time.Time) {
+	ttl := ec.Cfg.ExportLinkTTLHours
+	if ttl <= 0 {
+		ttl = 24
+	}
+	expires := time.Now().Add(time.Duration(ttl) * time.Hour)
+	if job.ExpiresAt != nil && job.ExpiresAt.Before(expires) {
+		expires = *job.ExpiresAt
+	}
+	expires = expires.Truncate(time.Second)
+	signature := export.SignDownload(ec.linkSecret(), job.ID, expires)
+	return fmt.Sprintf("/api/exports/%d/download?expires=%d&signature=%s", job.ID, expires.Unix(), signature), expires
+}
+
+func (ec *ExportsController) linkSecret() string {
+	if ec.Cfg.ExportLinkSecret != "" {
+		return ec.Cfg.ExportLinkSecret
+	}
+	return ec.Cfg.JWTSecret
+}
+
+func userDataJobPayload(job models.ExportJob) fiber.Map {
+	return fiber.Map{
+		"id":        job.ID,
+		"status":    job.Status,
+		"processed": job.Processed,
+		"total":     job.Total,
+	}
+}
+
+func exportFilename(kind string, entityID uint) string {
+	return fmt.Sprintf("%s-%d-gradebook.csv", kind, entityID)
+}
+
+func sisFilename(format string, courseID uint) string {
+	return fmt.Sprintf("course-%d-%s-grades.csv", courseID, format)
+}
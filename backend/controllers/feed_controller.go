@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"fmt"
+	"project/backend/circles"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// feedExcerptLength — сколько символов комментария попадает в ленту
+const feedExcerptLength = 80
+
+type FeedController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewFeedController(db *gorm.DB, cfg *config.Config) *FeedController {
+	return &FeedController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (fc *FeedController) db(c *fiber.Ctx) *gorm.DB {
+	return fc.DB.WithContext(c.UserContext())
+}
+
+type feedItem struct {
+	ID           uint      `json:"id"`
+	Type         string    `json:"type"`
+	TargetType   string    `json:"target_type"`
+	TargetID     uint      `json:"target_id"`
+	TargetTitle  string    `json:"target_title"`
+	SubjectID    uint      `json:"subject_id,omitempty"`
+	SubjectTitle string    `json:"subject_title,omitempty"`
+	Score        float64   `json:"score,omitempty"`
+	Duration     float64   `json:"duration,omitempty"`
+	Message      string    `json:"message"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GetUserFeed возвращает ленту активности текущего пользователя с keyset-пагинацией
+func (fc *FeedController) GetUserFeed(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, fc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	cursor, limit, err := utils.ParseCursorParams(c)
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cursor")
+	}
+
+	var activities []models.UserActivity
+	query := fc.db(c).Where("user_id = ?", userID)
+	if t := c.Query("type"); t != "" {
+		query = query.Where("action_type = ?", t)
+	}
+	if err := utils.ApplyCursor(query, "created_at", "id", cursor, limit).Find(&activities).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch activity feed")
+	}
+
+	activities, next := utils.TrimPage(activities, limit, func(activity models.UserActivity) (time.Time, uint) {
+		return activity.CreatedAt, activity.ID
+	})
+
+	items := make([]feedItem, 0, len(activities))
+	for _, activity := range activities {
+		items = append(items, feedItem{
+			ID:           activity.ID,
+			Type:         activity.ActionType,
+			TargetType:   activity.TargetType,
+			TargetID:     activity.TargetID,
+			TargetTitle:  activity.TargetTitle,
+			SubjectID:    activity.SubjectID,
+			SubjectTitle: activity.SubjectTitle,
+			Score:        activity.Score,
+			Duration:     activity.Duration,
+			Message:      feedMessage(activity),
+			CreatedAt:    activity.CreatedAt,
+		})
+	}
+
+	return utils.PaginateCursor(c, items, next, limit)
+}
+
+// feedMessage составляет строку для дашборда, например "completed Lesson 3 of Ethics 101"
+func feedMessage(activity models.UserActivity) string {
+	switch activity.ActionType {
+	case models.ActivityCourseStart, models.ActivityTestStart:
+		return "started " + activity.TargetTitle
+	case models.ActivityLessonComplete:
+		return "completed " + activity.SubjectTitle + " of " + activity.TargetTitle
+	case models.ActivityCourseComplete:
+		return "completed " + activity.TargetTitle
+	case models.ActivityTestComplete:
+		return fmt.Sprintf("scored %.0f%% on %s", activity.Score, activity.TargetTitle)
+	case models.ActivityComment:
+		return "commented on " + activity.TargetTitle
+	case models.ActivityCommentReply:
+		return "replied to a comment on " + activity.TargetTitle
+	default:
+		return activity.ActionType + " " + activity.TargetTitle
+	}
+}
+
+// withAction возвращает копию события с заданным типом
+func withAction(activity models.UserActivity, action string) models.UserActivity {
+	activity.ActionType = action
+	return activity
+}
+
+// recordActivity добавляет событие в ленту пользователя; вызывается в транзакции, которая меняет прогресс
+func recordActivity(tx *gorm.DB, activity models.UserActivity) error {
+	activity.Timestamp = time.Now().Format(time.RFC3339)
+	if err := tx.Create(&activity).Error; err != nil {
+		return err
+	}
+	// Completed lessons move the challenges of the student's study circle
+	if activity.ActionType == models.ActivityLessonComplete && activity.TargetType == "courses" {
+		return circles.Track(tx, activity)
+	}
+	return nil
+}
+
+// recordActivityOnce добавляет событие, если такого же события по этой цели в ленте еще нет
+func recordActivityOnce(tx *gorm.DB, activity models.UserActivity) error {
+	var exists int64
+	if err := tx.Model(&models.UserActivity{}).
+		Where("user_id = ? AND action_type = ? AND target_type = ? AND target_id = ?",
+			activity.UserID, activity.ActionType, activity.TargetType, activity.TargetID).
+		Count(&exists).Error; err != nil || exists > 0 {
+		return err
+	}
+	return recordActivity(tx, activity)
+}
+
+// excerpt обрезает текст комментария для ленты
+func excerpt(text string) string {
+	runes := []rune(text)
+	if len(runes) <= feedExcerptLength {
+		return text
+	}
+	return string(runes[:feedExcerptLength]) + "…"
+}
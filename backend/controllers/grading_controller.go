@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"encoding/json"
+	"project/backend/config"
+	"project/backend/grading"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GradingController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewGradingController(db *gorm.DB, cfg *config.Config) *GradingController {
+	return &GradingController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (gc *GradingController) db(c *fiber.Ctx) *gorm.DB {
+	return gc.DB.WithContext(c.UserContext())
+}
+
+type gradeComponentInput struct {
+	Name    string  `json:"name"`
+	Kind    string  `json:"kind"`
+	Weight  float64 `json:"weight"`
+	TestIDs []uint  `json:"test_ids"`
+	Target  int     `json:"target"`
+}
+
+// GetCourseGrading возвращает состав итоговой оценки курса и оценку текущего пользователя
+func (gc *GradingController) GetCourseGrading(c *fiber.Ctx) error {
+	course, userID, done, err := gc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	components, err := grading.Components(gc.db(c), course.ID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch grade composition")
+	}
+	grade, err := grading.Compute(gc.db(c), *course, userID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to compute grade")
+	}
+	scale, err := grading.ScaleFor(gc.db(c), course.UniversityID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch grade scale")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"components": gradeComponentsPayload(components),
+		"scale":      scale,
+		"grade":      grade,
+	})
+}
+
+// UpdateCourseGrading заменяет состав итоговой оценки курса; веса задаются в процентах и в сумме дают 100
+func (gc *GradingController) UpdateCourseGrading(c *fiber.Ctx) error {
+	course, userID, done, err := gc.authorizedCourse(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Components []gradeComponentInput `json:"components"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	errs := map[string]string{}
+	var total float64
+	components := make([]models.CourseGradeComponent, 0, len(input.Components))
+	for i, item := range input.Components {
+		field := "components." + strconv.Itoa(i)
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			name = item.Kind
+		}
+		component := models.CourseGradeComponent{
+			CourseID:      course.ID,
+			Name:          name,
+			Kind:          item.Kind,
+			Weight:        item.Weight,
+			SequenceOrder: i,
+		}
+
+		switch item.Kind {
+		case models.GradeKindTests:
+			if len(item.TestIDs) == 0 {
+				errs[field+".test_ids"] = "A tests component needs at least one test"
+				break
+			}
+			found, err := countCourseTests(gc.db(c), course, userID, item.TestIDs)
+			if err != nil {
+				return utils.InternalServerError(c, "Could not query database")
+			}
+			if int(found) != len(uniqueIDs(item.TestIDs)) {
+				errs[field+".test_ids"] = "Unknown test"
+				break
+			}
+			ids := make([]string, 0, len(item.TestIDs))
+			for _, id := range uniqueIDs(item.TestIDs) {
+				ids = append(ids, strconv.FormatUint(uint64(id), 10))
+			}
+			component.TestIDs = strings.Join(ids, ",")
+		case models.GradeKindParticipation:
+			if item.Target < 1 {
+				errs[field+".target"] = "Target must be at least 1 comment"
+			}
+			component.Target = item.Target
+		case models.GradeKindLessons, models.GradeKindManual:
+		default:
+			errs[field+".kind"] = "Kind must be one of tests, lessons, participation, manual"
+		}
+		if item.Weight <= 0 {
+			errs[field+".weight"] = "Weight must be positive"
+		}
+
+		total += item.Weight
+		components = append(components, component)
+	}
+	if len(components) > 0 && (total < 99.99 || total > 100.01) {
+		errs["components"] = "Weights must add up to 100"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	err = gc.db(c).Transaction(func(tx *gorm.DB) error {
+		// Manual scores belong to components that are replaced, so they go too
+		if err := tx.Unscoped().Where("component_id IN (?)",
+			tx.Model(&models.CourseGradeComponent{}).Select("id").Where("course_id = ?", course.ID)).
+			Delete(&models.CourseGradeEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("course_id = ?", course.ID).Delete(&models.CourseGradeComponent{}).Error; err != nil {
+			return err
+		}
+		if len(components) > 0 {
+			if err := tx.Create(&components).Error; err != nil {
+				return err
+			}
+		}
+		return grading.RefreshCourse(tx, course.ID)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not save grade composition")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"components": gradeComponentsPayload(components),
+	})
+}
+
+// SetGradeEntry выставляет студенту балл по составляющей, оцениваемой вручную
+func (gc *GradingController) SetGradeEntry(c *fiber.Ctx) error {
+	course, graderID, done, err := gc.authorizedCourse(c, policy.ActionGrade)
+	if done {
+		return err
+	}
+
+	var component models.CourseGradeComponent
+	if err := gc.db(c).Where("id = ? AND course_id = ?", c.Params("componentId"), course.ID).First(&component).Error; err != nil {
+		return utils.NotFound(c, "Grade component not found")
+	}
+	if component.Kind != models.GradeKindManual {
+		return utils.ValidationError(c, map[string]string{"component": "Only manual components are graded by hand"})
+	}
+
+	userID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+	var student models.User
+	if err := gc.db(c).Select("id").First(&student, userID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	var input struct {
+		Score float64 `json:"score"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Score < 0 || input.Score > 100 {
+		return utils.ValidationError(c, map[string]string{"score": "Score must be between 0 and 100"})
+	}
+
+	entry := models.CourseGradeEntry{ComponentID: component.ID, UserID: student.ID, Score: input.Score, GradedBy: graderID}
+	var grade models.CourseGrade
+	err = gc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "component_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "graded_by", "updated_at"}),
+		}).Create(&entry).Error; err != nil {
+			return err
+		}
+		if err := grading.RefreshUser(tx, course.ID, student.ID); err != nil {
+			return err
+		}
+		return tx.Where("course_id = ? AND user_id = ?", course.ID, student.ID).First(&grade).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not save grade")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"component_id": component.ID,
+		"user_id":      student.ID,
+		"score":        entry.Score,
+		"percent":      grade.Percent,
+		"letter":       grade.Letter,
+	})
+}
+
+// GetGradeScale возвращает шкалу буквенных оценок университета
+func (gc *GradingController) GetGradeScale(c *fiber.Ctx) error {
+	university, done, err := gc.university(c)
+	if done {
+		return err
+	}
+
+	scale, err := grading.ScaleFor(gc.db(c), &university.ID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch grade scale")
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"university_id": university.ID, "bands": scale})
+}
+
+// UpdateGradeScale задает шкалу буквенных оценок университета и пересчитывает оценки его курсов
+func (gc *GradingController) UpdateGradeScale(c *fiber.Ctx) error {
+	university, done, err := gc.university(c)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Bands []grading.Band `json:"bands"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	errs := map[string]string{}
+	letters := map[string]bool{}
+	hasFloor := false
+	for i, band := range input.Bands {
+		field := "bands." + strconv.Itoa(i)
+		band.Letter = strings.TrimSpace(band.Letter)
+		input.Bands[i] = band
+		if band.Letter == "" || letters[band.Letter] {
+			errs[field+".letter"] = "Letters must be non-empty and unique"
+		}
+		if band.Min < 0 || band.Min > 100 {
+			errs[field+".min"] = "Min must be between 0 and 100"
+		}
+		letters[band.Letter] = true
+		hasFloor = hasFloor || band.Min == 0
+	}
+	if len(input.Bands) == 0 || !hasFloor {
+		errs["bands"] = "The scale needs a band starting at 0"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	encoded, _ := json.Marshal(input.Bands)
+	scale := models.GradeScale{UniversityID: university.ID, Bands: string(encoded)}
+	err = gc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "university_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"bands", "updated_at"}),
+		}).Create(&scale).Error; err != nil {
+			return err
+		}
+
+		var courseIDs []uint
+		if err := tx.Model(&models.CourseGradeComponent{}).
+			Joins("JOIN courses ON courses.id = course_grade_components.course_id").
+			Where("courses.university_id = ?", university.ID).
+			Distinct().Pluck("course_grade_components.course_id", &courseIDs).Error; err != nil {
+			return err
+		}
+		for _, courseID := range courseIDs {
+			if err := grading.RefreshCourse(tx, courseID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not save grade scale")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"university_id": university.ID, "bands": input.Bands})
+}
+
+// authorizedCourse загружает курс из :id и проверяет действие пользователя над ним
+func (gc *GradingController) authorizedCourse(c *fiber.Ctx, action policy.Action) (*models.Course, uint, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, gc.Cfg)
+	if err != nil {
+		return nil, 0, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 0, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := gc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, 0, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, action, policy.Course(&course)); err != nil {
+		return nil, 0, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to manage grades of this course"))
+	}
+	return &course, userID, false, nil
+}
+
+// university загружает университет из :id
+func (gc *GradingController) university(c *fiber.Ctx) (*models.University, bool, error) {
+	var university models.University
+	if err := gc.db(c).First(&university, c.Params("id")).Error; err != nil {
+		return nil, true, utils.NotFound(c, "University not found")
+	}
+	return &university, false, nil
+}
+
+func gradeComponentsPayload(components []models.CourseGradeComponent) []fiber.Map {
+	payload := make([]fiber.Map, 0, len(components))
+	for _, component := range components {
+		item := fiber.Map{
+			"id":     component.ID,
+			"name":   component.Name,
+			"kind":   component.Kind,
+			"weight": component.Weight,
+		}
+		switch component.Kind {
+		case models.GradeKindTests:
+			item["test_ids"] = grading.ParseTestIDs(component.TestIDs)
+		case models.GradeKindParticipation:
+			item["target"] = component.Target
+		}
+		payload = append(payload, item)
+	}
+	return payload
+}
+
+// countCourseTests считает, сколько из указанных тестов можно подключить к курсу: тесты не привязаны
+// к курсам, поэтому годятся только тесты автора курса или редактора, который меняет настройки
+func countCourseTests(db *gorm.DB, course *models.Course, editorID uint, ids []uint) (int64, error) {
+	var found int64
+	err := db.Model(&models.Test{}).Where("id IN ? AND author_id IN ?", uniqueIDs(ids), []uint{course.AuthorID, editorID}).
+		Count(&found).Error
+	return found, err
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := map[uint]bool{}
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
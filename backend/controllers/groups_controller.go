@@ -0,0 +1,388 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errUnknownGroup   = errors.New("Group is not in the directory")
+	errGroupInUse     = errors.New("Group is referenced by courses, tests or course runs")
+	errGroupSlugTaken = errors.New("Slug is already taken")
+)
+
+type GroupsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewGroupsController(db *gorm.DB, cfg *config.Config) *GroupsController {
+	return &GroupsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (gc *GroupsController) db(c *fiber.Ctx) *gorm.DB {
+	return gc.DB.WithContext(c.UserContext())
+}
+
+// GetGroups возвращает справочник групп (фильтры ?university_id= и ?search=)
+func (gc *GroupsController) GetGroups(c *fiber.Ctx) error {
+	query := gc.db(c).Model(&models.StudyGroup{})
+	if universityID := c.QueryInt("university_id"); universityID > 0 {
+		query = query.Where("university_id = ?", universityID)
+	}
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("name ILIKE ?", "%"+search+"%")
+	}
+
+	var groups []models.StudyGroup
+	if err := query.Order("name").Find(&groups).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch groups")
+	}
+
+	result := make([]fiber.Map, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, groupCard(group))
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// GetGroup возвращает группу с числом участников
+func (gc *GroupsController) GetGroup(c *fiber.Ctx) error {
+	var group models.StudyGroup
+	if err := gc.db(c).First(&group, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Group not found")
+	}
+
+	var members int64
+	gc.db(c).Model(&models.StudyGroupMember{}).Where("group_id = ?", group.ID).Count(&members)
+
+	data := groupCard(group)
+	data["members"] = members
+	return utils.Success(c, fiber.StatusOK, data)
+}
+
+// CreateGroup добавляет группу в справочник
+func (gc *GroupsController) CreateGroup(c *fiber.Ctx) error {
+	var input struct {
+		Name         string               `json:"name"`
+		Slug         string               `json:"slug"`
+		UniversityID utils.Optional[uint] `json:"university_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return utils.ValidationError(c, map[string]string{"name": "Name is required"})
+	}
+
+	group := models.StudyGroup{Name: input.Name, Slug: input.Slug}
+	if _, err := applyUniversity(gc.db(c), input.UniversityID, utils.Optional[string]{}, false, &group.UniversityID, &group.University); err != nil {
+		return utils.ValidationError(c, map[string]string{"university_id": err.Error()})
+	}
+	// Groups of different universities often share a name, so the default slug includes the university
+	if group.Slug == "" {
+		group.Slug = slugify(strings.TrimSpace(group.University + " " + group.Name))
+	}
+
+	if err := gc.db(c).Create(&group).Error; err != nil {
+		return utils.BadRequest(c, errGroupSlugTaken.Error())
+	}
+
+	return utils.Created(c, groupCard(group))
+}
+
+// UpdateGroup изменяет группу; новое название переносится в пользователей и контент
+func (gc *GroupsController) UpdateGroup(c *fiber.Ctx) error {
+	groupID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid group ID")
+	}
+
+	var input struct {
+		Name         utils.Optional[string] `json:"name"`
+		Slug         utils.Optional[string] `json:"slug"`
+		UniversityID utils.Optional[uint]   `json:"university_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var group models.StudyGroup
+	err = gc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&group, groupID).Error; err != nil {
+			return err
+		}
+
+		oldName := group.Name
+		input.Name.Apply(&group.Name, false)
+		input.Slug.Apply(&group.Slug, false)
+		if _, err := applyUniversity(tx, input.UniversityID, utils.Optional[string]{}, false, &group.UniversityID, &group.University); err != nil {
+			return err
+		}
+
+		if err := tx.Save(&group).Error; err != nil {
+			return errGroupSlugTaken
+		}
+
+		// Keep the denormalized names in sync
+		if group.Name != oldName {
+			if err := tx.Model(&models.User{}).Where("study_group_id = ?", group.ID).
+				Update("group", group.Name).Error; err != nil {
+				return err
+			}
+			for _, model := range []interface{}{&models.Course{}, &models.Test{}} {
+				if err := tx.Model(model).Where("recommended_group_id = ?", group.ID).
+					Update("recommended_for", group.Name).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "Group not found")
+	case errors.Is(err, errUnknownUniversity):
+		return utils.ValidationError(c, map[string]string{"university_id": err.Error()})
+	case errors.Is(err, errGroupSlugTaken):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not update group")
+	}
+
+	return utils.Success(c, fiber.StatusOK, groupCard(group))
+}
+
+// DeleteGroup удаляет группу, которую не рекомендуют курсы и тесты и не проходит ни один поток; участники из нее выходят
+func (gc *GroupsController) DeleteGroup(c *fiber.Ctx) error {
+	groupID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid group ID")
+	}
+
+	err = gc.db(c).Transaction(func(tx *gorm.DB) error {
+		var group models.StudyGroup
+		if err := tx.First(&group, groupID).Error; err != nil {
+			return err
+		}
+
+		var courses, tests, runs int64
+		tx.Model(&models.Course{}).Where("recommended_group_id = ?", group.ID).Count(&courses)
+		tx.Model(&models.Test{}).Where("recommended_group_id = ?", group.ID).Count(&tests)
+		tx.Model(&models.CourseRun{}).Where("group_id = ?", group.ID).Count(&runs)
+		if courses+tests+runs > 0 {
+			return errGroupInUse
+		}
+
+		if err := tx.Model(&models.User{}).Where("study_group_id = ?", group.ID).
+			Updates(map[string]interface{}{"study_group_id": nil, "group": ""}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("group_id = ?", group.ID).Delete(&models.StudyGroupMember{}).Error; err != nil {
+			return err
+		}
+		// Hard delete so the slug can be reused
+		return tx.Unscoped().Delete(&group).Error
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "Group not found")
+	case errors.Is(err, errGroupInUse):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not delete group")
+	}
+
+	return utils.NoContent(c)
+}
+
+// GetGroupMembers возвращает участников группы
+func (gc *GroupsController) GetGroupMembers(c *fiber.Ctx) error {
+	var group models.StudyGroup
+	if err := gc.db(c).First(&group, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Group not found")
+	}
+
+	type member struct {
+		UserID   uint      `json:"user_id"`
+		Username string    `json:"username"`
+		Email    string    `json:"email"`
+		Primary  bool      `json:"primary"`
+		JoinedAt time.Time `json:"joined_at"`
+	}
+	var members []member
+	if err := gc.db(c).Table("study_group_members AS m").
+		Select("m.user_id, users.username, users.email, COALESCE(users.study_group_id = m.group_id, false) AS \"primary\", m.created_at AS joined_at").
+		Joins("JOIN users ON users.id = m.user_id AND users.deleted_at IS NULL").
+		Where("m.group_id = ? AND m.deleted_at IS NULL", group.ID).
+		Order("users.username").
+		Scan(&members).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch group members")
+	}
+
+	return utils.Success(c, fiber.StatusOK, members)
+}
+
+// AddGroupMember добавляет пользователя в группу; с "primary": true она становится основной группой пользователя
+func (gc *GroupsController) AddGroupMember(c *fiber.Ctx) error {
+	actorID, err := utils.ExtractUserIDFromToken(c, gc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var group models.StudyGroup
+	if err := gc.db(c).First(&group, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Group not found")
+	}
+
+	var input struct {
+		UserID  uint `json:"user_id"`
+		Primary bool `json:"primary"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var user models.User
+	if err := gc.db(c).Select("id", "study_group_id").First(&user, input.UserID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	err = gc.db(c).Transaction(func(tx *gorm.DB) error {
+		if input.Primary {
+			return setPrimaryGroup(tx, user.ID, user.StudyGroupID, &group.ID, group.Name, actorID)
+		}
+		return joinGroup(tx, group.ID, user.ID, actorID)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not add group member")
+	}
+
+	return utils.Created(c, fiber.Map{"group_id": group.ID, "user_id": user.ID, "primary": input.Primary})
+}
+
+// RemoveGroupMember исключает пользователя из группы (и снимает ее как основную)
+func (gc *GroupsController) RemoveGroupMember(c *fiber.Ctx) error {
+	groupID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid group ID")
+	}
+	userID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	var removed int64
+	err = gc.db(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&models.StudyGroupMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		return tx.Model(&models.User{}).Where("id = ? AND study_group_id = ?", userID, groupID).
+			Updates(map[string]interface{}{"study_group_id": nil, "group": ""}).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not remove group member")
+	}
+	if removed == 0 {
+		return utils.NotFound(c, "Member not found")
+	}
+
+	return utils.NoContent(c)
+}
+
+// applyGroup привязывает запись к группе из справочника.
+// group_id имеет приоритет; название сравнивается без учета регистра и пунктуации,
+// а при совпадении в нескольких университетах выбирается группа университета universityID.
+// Возвращает true, если группа изменилась.
+func applyGroup(db *gorm.DB, id utils.Optional[uint], name utils.Optional[string], merge bool,
+	universityID *uint, groupID **uint, groupName *string) (bool, error) {
+	var group *models.StudyGroup
+	switch {
+	case id.Set:
+		if id.Null || id.Value == 0 {
+			break
+		}
+		group = &models.StudyGroup{}
+		if err := db.Select("id", "name").First(group, id.Value).Error; err != nil {
+			return false, errUnknownGroup
+		}
+	case name.Set:
+		value := *groupName
+		if !name.Apply(&value, merge) {
+			return false, nil
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			break
+		}
+		group = &models.StudyGroup{}
+		err := db.Select("id", "name").
+			Where("trim(both '-' from lower(regexp_replace(name, '[^[:alnum:]]+', '-', 'g'))) = ?", slugify(value)).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL: "university_id IS NOT DISTINCT FROM ? DESC, id", Vars: []interface{}{universityID}, WithoutParentheses: true,
+			}}).
+			First(group).Error
+		if err != nil {
+			return false, errUnknownGroup
+		}
+	default:
+		return false, nil
+	}
+
+	previous := derefUint(*groupID)
+	if group == nil {
+		*groupID, *groupName = nil, ""
+	} else {
+		*groupID, *groupName = &group.ID, group.Name
+	}
+	return previous != derefUint(*groupID), nil
+}
+
+// joinGroup добавляет пользователя в группу, если он еще не участник
+func joinGroup(tx *gorm.DB, groupID, userID, addedBy uint) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.StudyGroupMember{GroupID: groupID, UserID: userID, AddedBy: addedBy}).Error
+}
+
+// setPrimaryGroup меняет основную группу пользователя: из прежней он выходит, в новую вступает
+func setPrimaryGroup(tx *gorm.DB, userID uint, previous, next *uint, name string, addedBy uint) error {
+	if previous != nil && derefUint(previous) != derefUint(next) {
+		if err := tx.Unscoped().Where("group_id = ? AND user_id = ?", *previous, userID).
+			Delete(&models.StudyGroupMember{}).Error; err != nil {
+			return err
+		}
+	}
+	if next != nil {
+		if err := joinGroup(tx, *next, userID, addedBy); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"study_group_id": next, "group": name}).Error
+}
+
+func groupCard(group models.StudyGroup) fiber.Map {
+	return fiber.Map{
+		"id":            group.ID,
+		"name":          group.Name,
+		"slug":          group.Slug,
+		"university":    group.University,
+		"university_id": group.UniversityID,
+	}
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/policy"
+	"project/backend/utils"
+	"project/backend/waitlist"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const invitationTTL = 14 * 24 * time.Hour
+
+var (
+	errInvitationInvalid  = errors.New("Invalid or expired invite code")
+	errInvitationMismatch = errors.New("Invite code was issued for a different email")
+)
+
+type InvitationsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewInvitationsController(db *gorm.DB, cfg *config.Config) *InvitationsController {
+	return &InvitationsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ic *InvitationsController) db(c *fiber.Ctx) *gorm.DB {
+	return ic.DB.WithContext(c.UserContext())
+}
+
+// GetInvitations возвращает приглашения, отправленные текущим пользователем
+func (ic *InvitationsController) GetInvitations(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ic.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var invitations []models.Invitation
+	if err := ic.db(c).Where("invited_by = ?", userID).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch invitations")
+	}
+
+	return utils.Success(c, fiber.StatusOK, invitations)
+}
+
+// CreateInvitation приглашает студента по email с заранее заданной группой, университетом и курсом
+func (ic *InvitationsController) CreateInvitation(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, ic.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Email      string `json:"email"`
+		Group      string `json:"group"`
+		University string `json:"university"`
+		CourseID   *uint  `json:"course_id"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	input.Email = strings.TrimSpace(strings.ToLower(input.Email))
+	if input.Email == "" || !strings.Contains(input.Email, "@") {
+		return utils.ValidationError(c, map[string]string{"email": "Valid email is required"})
+	}
+
+	var universityID *uint
+	if input.University != "" {
+		if _, err := applyUniversity(ic.db(c), utils.Optional[uint]{}, utils.Optional[string]{Set: true, Value: input.University},
+			false, &universityID, &input.University); err != nil {
+			return utils.ValidationError(c, map[string]string{"university": err.Error()})
+		}
+	}
+
+	// The group is stored under its directory spelling
+	if input.Group != "" {
+		var groupID *uint
+		if _, err := applyGroup(ic.db(c), utils.Optional[uint]{}, utils.Optional[string]{Set: true, Value: input.Group},
+			false, universityID, &groupID, &input.Group); err != nil {
+			return utils.ValidationError(c, map[string]string{"group": err.Error()})
+		}
+	}
+
+	if !utils.HasScopedPermission(ic.db(c), userID, models.PermUsersInvite, input.University) {
+		return utils.Forbidden(c, "You can't invite users to this university")
+	}
+
+	if input.CourseID != nil {
+		var course models.Course
+		if err := ic.db(c).First(&course, *input.CourseID).Error; err != nil {
+			return utils.NotFound(c, "Course not found")
+		}
+		if err := policy.Authorize(c, policy.ActionInvite, policy.Course(&course)); err != nil {
+			return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You can't invite users to this course"))
+		}
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		return utils.InternalServerError(c, "Could not generate invite code")
+	}
+
+	invitation := models.Invitation{
+		Code:       code,
+		Email:      input.Email,
+		Group:      input.Group,
+		University: input.University,
+		CourseID:   input.CourseID,
+		InvitedBy:  userID,
+		ExpiresAt:  time.Now().Add(invitationTTL),
+	}
+
+	err = ic.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invitation).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueEmail(tx, invitation.Email, "Invitation to Philosofium",
+			"You have been invited to join Philosofium. Use this invite code when registering: "+invitation.Code)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not create invitation")
+	}
+
+	return utils.Created(c, invitation)
+}
+
+// acceptInvitation проверяет код приглашения и применяет его к новому пользователю внутри транзакции регистрации
+func acceptInvitation(tx *gorm.DB, code string, user *models.User) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := tx.Where("code = ? AND accepted_at IS NULL AND expires_at > ?", code, time.Now()).
+		First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvitationInvalid
+		}
+		return nil, err
+	}
+
+	if !strings.EqualFold(invitation.Email, strings.TrimSpace(user.Email)) {
+		return nil, errInvitationMismatch
+	}
+
+	if invitation.University != "" {
+		_, err := applyUniversity(tx, utils.Optional[uint]{}, utils.Optional[string]{Set: true, Value: invitation.University},
+			false, &user.UniversityID, &user.University)
+		if err != nil {
+			// The university was removed from the directory after the invitation was sent
+			user.UniversityID, user.University = nil, ""
+		}
+	}
+	if invitation.Group != "" {
+		_, err := applyGroup(tx, utils.Optional[uint]{}, utils.Optional[string]{Set: true, Value: invitation.Group},
+			false, user.UniversityID, &user.StudyGroupID, &user.Group)
+		if err != nil {
+			// Same for a group deleted in the meantime
+			user.StudyGroupID, user.Group = nil, ""
+		}
+	}
+	return &invitation, nil
+}
+
+// completeInvitation помечает приглашение использованным и записывает пользователя на курс,
+// а если свободных мест на курсе нет — ставит его в очередь.
+// Приглашение помечается условным UPDATE: из двух одновременных регистраций по одному коду проходит одна.
+func completeInvitation(tx *gorm.DB, invitation *models.Invitation, user *models.User) error {
+	now := time.Now()
+	result := tx.Model(invitation).Where("accepted_at IS NULL").Updates(map[string]interface{}{
+		"accepted_at": now,
+		"accepted_by": user.ID,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvitationInvalid
+	}
+
+	if user.StudyGroupID != nil {
+		if err := joinGroup(tx, *user.StudyGroupID, user.ID, invitation.InvitedBy); err != nil {
+			return err
+		}
+	}
+
+	if invitation.CourseID == nil {
+		return nil
+	}
+
+	// An invitation doesn't add seats: with the course full the invitee queues up like everyone else
+	if err := waitlist.Lock(tx, *invitation.CourseID); err != nil {
+		return err
+	}
+	var settings models.CourseAccessSettings
+	if err := tx.Where("course_id = ?", *invitation.CourseID).Limit(1).Find(&settings).Error; err != nil {
+		return err
+	}
+	seat, err := waitlist.HasSeat(tx, settings, user.ID, now)
+	if err != nil {
+		return err
+	}
+	if !seat {
+		_, err := waitlist.Join(tx, *invitation.CourseID, user.ID)
+		return err
+	}
+	return tx.Create(&models.UserCourseProgress{
+		UserID:       user.ID,
+		CourseID:     *invitation.CourseID,
+		LastAccessed: now.Format(time.RFC3339),
+	}).Error
+}
+
+func generateInviteCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// legalHoldSubjects — модели, на которые можно поставить удержание
+var legalHoldSubjects = map[string]interface{}{
+	models.HoldSubjectUser:   &models.User{},
+	models.HoldSubjectCourse: &models.Course{},
+	models.HoldSubjectTest:   &models.Test{},
+}
+
+type LegalHoldsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewLegalHoldsController(db *gorm.DB, cfg *config.Config) *LegalHoldsController {
+	return &LegalHoldsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (lc *LegalHoldsController) db(c *fiber.Ctx) *gorm.DB {
+	return lc.DB.WithContext(c.UserContext())
+}
+
+// GetLegalHolds возвращает удержания, включая снятые (?active=true — только действующие)
+func (lc *LegalHoldsController) GetLegalHolds(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := lc.db(c).Model(&models.LegalHold{})
+	if subjectType := c.Query("subject_type"); subjectType != "" {
+		query = query.Where("subject_type = ?", subjectType)
+	}
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("subject_id = ?", subjectID)
+	}
+	if c.Query("active") == "true" {
+		query = query.Where("released_at IS NULL")
+	}
+	var total int64
+	query.Count(&total)
+
+	var holds []models.LegalHold
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&holds).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch legal holds")
+	}
+	return utils.Paginate(c, holds, total, page, pageSize)
+}
+
+// CreateLegalHold ставит удержание на пользователя, курс или тест; причина обязательна
+func (lc *LegalHoldsController) CreateLegalHold(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, lc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		SubjectType string `json:"subject_type"`
+		SubjectID   uint   `json:"subject_id"`
+		Reason      string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+
+	errs := map[string]string{}
+	model, ok := legalHoldSubjects[input.SubjectType]
+	if !ok {
+		errs["subject_type"] = "Subject type must be user, course or test"
+	}
+	if input.Reason == "" {
+		errs["reason"] = "Reason is required"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	// Soft-deleted accounts waiting for the purge can be held too
+	var count int64
+	lc.db(c).Unscoped().Model(model).Where("id = ?", input.SubjectID).Count(&count)
+	if count == 0 {
+		return utils.NotFound(c, "Subject not found")
+	}
+	if utils.UnderLegalHold(lc.db(c), input.SubjectType, input.SubjectID) {
+		return utils.Error(c, fiber.StatusConflict, fiber.NewError(fiber.StatusConflict, "The subject is already under legal hold"))
+	}
+
+	hold := models.LegalHold{
+		SubjectType: input.SubjectType,
+		SubjectID:   input.SubjectID,
+		Reason:      input.Reason,
+		AppliedBy:   userID,
+	}
+	if err := lc.db(c).Create(&hold).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create legal hold")
+	}
+	return utils.Created(c, hold)
+}
+
+// ReleaseLegalHold снимает удержание; запись остается с указанием, кто и почему его снял
+func (lc *LegalHoldsController) ReleaseLegalHold(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, lc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		return utils.ValidationError(c, map[string]string{"reason": "Reason is required"})
+	}
+
+	var hold models.LegalHold
+	if err := lc.db(c).First(&hold, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Legal hold not found")
+	}
+	if hold.ReleasedAt != nil {
+		return utils.Error(c, fiber.StatusConflict, fiber.NewError(fiber.StatusConflict, "The legal hold is already released"))
+	}
+
+	now := time.Now()
+	hold.ReleasedAt, hold.ReleasedBy, hold.ReleaseReason = &now, &userID, input.Reason
+	if err := lc.db(c).Model(&hold).Select("released_at", "released_by", "release_reason").Updates(&hold).Error; err != nil {
+		return utils.InternalServerError(c, "Could not release legal hold")
+	}
+	return utils.Success(c, fiber.StatusOK, hold)
+}
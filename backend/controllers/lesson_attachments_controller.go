@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/storage"
+	"project/backend/utils"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type LessonAttachmentsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewLessonAttachmentsController(db *gorm.DB, cfg *config.Config) *LessonAttachmentsController {
+	return &LessonAttachmentsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (ac *LessonAttachmentsController) db(c *fiber.Ctx) *gorm.DB {
+	return ac.DB.WithContext(c.UserContext())
+}
+
+// GetAttachments отдает список файлов урока
+func (ac *LessonAttachmentsController) GetAttachments(c *fiber.Ctx) error {
+	lesson, done, err := viewableLesson(c, ac.db(c), ac.Cfg)
+	if done {
+		return err
+	}
+
+	var attachments []models.LessonAttachment
+	if err := ac.db(c).Where("lesson_id = ?", lesson.ID).Order("id").Find(&attachments).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch attachments")
+	}
+
+	result := make([]fiber.Map, 0, len(attachments))
+	for _, attachment := range attachments {
+		result = append(result, attachmentPayload(attachment, lesson.CourseID))
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// DownloadAttachment отдает файл урока с заголовком Content-Disposition: attachment
+func (ac *LessonAttachmentsController) DownloadAttachment(c *fiber.Ctx) error {
+	lesson, done, err := viewableLesson(c, ac.db(c), ac.Cfg)
+	if done {
+		return err
+	}
+
+	var attachment models.LessonAttachment
+	if err := ac.db(c).Where("id = ? AND lesson_id = ?", c.Params("attachmentId"), lesson.ID).First(&attachment).Error; err != nil {
+		return utils.NotFound(c, "Attachment not found")
+	}
+
+	data, err := storage.Default.Get(c.UserContext(), attachment.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return utils.NotFound(c, "Attachment file is missing")
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not read attachment")
+	}
+
+	c.Attachment(attachment.FileName)
+	c.Set(fiber.HeaderContentType, attachment.ContentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.Send(data)
+}
+
+// UploadAttachment добавляет файл к уроку (multipart: file и необязательный title).
+// Принимаются PDF, слайды, документы и наборы данных не больше ATTACHMENT_MAX_BYTES.
+func (ac *LessonAttachmentsController) UploadAttachment(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, ac.db(c), ac.Cfg)
+	if done {
+		return err
+	}
+	userID, _ := utils.ExtractUserIDFromToken(c, ac.Cfg)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return utils.BadRequest(c, "Multipart field \"file\" is required")
+	}
+	maxBytes := int64(ac.Cfg.AttachmentMaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = 50 << 20
+	}
+	if header.Size > maxBytes {
+		return utils.BadRequest(c, "Attachment is too large")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return utils.BadRequest(c, "Could not read upload")
+	}
+	defer file.Close()
+
+	data, tooLarge, err := utils.ReadLimited(file, maxBytes)
+	if err != nil {
+		return utils.BadRequest(c, "Could not read upload")
+	}
+	if tooLarge {
+		return utils.BadRequest(c, "Attachment is too large")
+	}
+
+	fileName := attachmentFileName(header.Filename)
+	contentType, err := utils.DetectAttachment(fileName, data)
+	if err != nil {
+		return utils.ValidationError(c, map[string]string{"file": err.Error()})
+	}
+
+	title := strings.TrimSpace(c.FormValue("title"))
+	if title == "" {
+		title = strings.TrimSuffix(fileName, path.Ext(fileName))
+	}
+	if len(title) > 255 {
+		return utils.ValidationError(c, map[string]string{"title": "Title must be at most 255 characters"})
+	}
+
+	key, err := storeAttachment(c.UserContext(), lesson.ID, fileName, data, contentType)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not store attachment")
+	}
+
+	attachment := models.LessonAttachment{
+		LessonID:     lesson.ID,
+		Title:        title,
+		FileName:     fileName,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Key:          key,
+		UploadedByID: userID,
+	}
+	if err := ac.db(c).Create(&attachment).Error; err != nil {
+		storage.Default.Delete(c.UserContext(), key)
+		return utils.InternalServerError(c, "Could not create attachment")
+	}
+	return utils.Created(c, attachmentPayload(attachment, lesson.CourseID))
+}
+
+// DeleteAttachment удаляет файл урока вместе с объектом в хранилище
+func (ac *LessonAttachmentsController) DeleteAttachment(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, ac.db(c), ac.Cfg)
+	if done {
+		return err
+	}
+
+	var attachment models.LessonAttachment
+	if err := ac.db(c).Where("id = ? AND lesson_id = ?", c.Params("attachmentId"), lesson.ID).First(&attachment).Error; err != nil {
+		return utils.NotFound(c, "Attachment not found")
+	}
+	if err := ac.db(c).Unscoped().Delete(&attachment).Error; err != nil {
+		return utils.InternalServerError(c, "Could not delete attachment")
+	}
+
+	storage.Default.Delete(c.UserContext(), attachment.Key)
+	return utils.NoContent(c)
+}
+
+// storeAttachment сохраняет файл урока в хранилище и возвращает его ключ
+func storeAttachment(ctx context.Context, lessonID uint, fileName string, data []byte, contentType string) (string, error) {
+	// The disk driver serves uploads publicly, so the key is unguessable and downloads go through the API
+	token, err := randomToken("", 16)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("attachments/lessons/%d/%s%s", lessonID, token, strings.ToLower(path.Ext(fileName)))
+	if _, err := storage.Default.Put(ctx, key, data, contentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// attachmentFileName оставляет от имени загруженного файла только базовое имя без управляющих символов и кавычек
+func attachmentFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if ext := path.Ext(name); len(name) > 255 && len(ext) < 20 {
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	return name
+}
+
+func attachmentPayload(attachment models.LessonAttachment, courseID uint) fiber.Map {
+	return fiber.Map{
+		"id":           attachment.ID,
+		"title":        attachment.Title,
+		"file_name":    attachment.FileName,
+		"content_type": attachment.ContentType,
+		"size":         attachment.Size,
+		"download_url": fmt.Sprintf("/api/courses/%d/lessons/%d/attachments/%d", courseID, attachment.LessonID, attachment.ID),
+		"created_at":   attachment.CreatedAt,
+	}
+}
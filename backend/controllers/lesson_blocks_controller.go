@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"project/backend/blocks"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	errBlockNotFound = errors.New("Block not found")
+	errBlockOrder    = errors.New("List every block of the lesson exactly once")
+)
+
+type LessonBlocksController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewLessonBlocksController(db *gorm.DB, cfg *config.Config) *LessonBlocksController {
+	return &LessonBlocksController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (bc *LessonBlocksController) db(c *fiber.Ctx) *gorm.DB {
+	return bc.DB.WithContext(c.UserContext())
+}
+
+// GetLessonBlocks отдает студенту блоки урока по порядку
+func (bc *LessonBlocksController) GetLessonBlocks(c *fiber.Ctx) error {
+	lesson, done, err := viewableLesson(c, bc.db(c), bc.Cfg)
+	if done {
+		return err
+	}
+
+	list, err := blocks.ForLesson(bc.db(c), lesson)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch lesson blocks")
+	}
+	return utils.Success(c, fiber.StatusOK, blocksPayload(list))
+}
+
+// GetBlocks отдает автору блоки урока
+func (bc *LessonBlocksController) GetBlocks(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, bc.db(c), bc.Cfg)
+	if done {
+		return err
+	}
+
+	list, err := blocks.ForLesson(bc.db(c), lesson)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch lesson blocks")
+	}
+	return utils.Success(c, fiber.StatusOK, blocksPayload(list))
+}
+
+// CreateBlock добавляет блок в конец урока или на позицию position (с 1), сдвигая следующие
+func (bc *LessonBlocksController) CreateBlock(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, bc.db(c), bc.Cfg)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Type     string          `json:"type"`
+		Data     json.RawMessage `json:"data"`
+		Position int             `json:"position"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	data, err := blocks.Validate(input.Type, input.Data)
+	if err != nil {
+		return utils.ValidationError(c, map[string]string{"data": err.Error()})
+	}
+
+	block := models.LessonBlock{LessonID: lesson.ID, Type: input.Type, Data: data}
+	err = bc.db(c).Transaction(func(tx *gorm.DB) error {
+		current, err := blocks.Materialize(tx, lesson)
+		if err != nil {
+			return err
+		}
+
+		position := input.Position
+		if position < 1 || position > len(current) {
+			position = len(current) + 1
+		}
+		block.SequenceOrder = position
+		if err := tx.Create(&block).Error; err != nil {
+			return err
+		}
+		return bc.saveOrder(tx, lesson, slices.Insert(current, position-1, block))
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not create block")
+	}
+	return utils.Created(c, blockPayload(block))
+}
+
+// UpdateBlock меняет данные блока (PUT и PATCH); тип меняется только вместе с данными
+func (bc *LessonBlocksController) UpdateBlock(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, bc.db(c), bc.Cfg)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var block models.LessonBlock
+	if err := bc.db(c).Where("id = ? AND lesson_id = ?", c.Params("blockId"), lesson.ID).First(&block).Error; err != nil {
+		return utils.NotFound(c, errBlockNotFound.Error())
+	}
+
+	if input.Type == "" {
+		input.Type = block.Type
+	}
+	if len(input.Data) == 0 {
+		if input.Type != block.Type {
+			return utils.ValidationError(c, map[string]string{"data": "Data is required when the type changes"})
+		}
+		input.Data = json.RawMessage(block.Data)
+	}
+	data, err := blocks.Validate(input.Type, input.Data)
+	if err != nil {
+		return utils.ValidationError(c, map[string]string{"data": err.Error()})
+	}
+	block.Type, block.Data = input.Type, data
+
+	err = bc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&block).Error; err != nil {
+			return err
+		}
+		return blocks.Sync(tx, lesson)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not update block")
+	}
+	return utils.Success(c, fiber.StatusOK, blockPayload(block))
+}
+
+// DeleteBlock удаляет блок; следующие сдвигаются на его место
+func (bc *LessonBlocksController) DeleteBlock(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, bc.db(c), bc.Cfg)
+	if done {
+		return err
+	}
+
+	err = bc.db(c).Transaction(func(tx *gorm.DB) error {
+		current, err := blocks.Materialize(tx, lesson)
+		if err != nil {
+			return err
+		}
+		blockID, _ := strconv.Atoi(c.Params("blockId"))
+		i := slices.IndexFunc(current, func(b models.LessonBlock) bool { return b.ID == uint(blockID) })
+		if i < 0 {
+			return errBlockNotFound
+		}
+		if err := tx.Delete(&current[i]).Error; err != nil {
+			return err
+		}
+		return bc.saveOrder(tx, lesson, slices.Delete(current, i, i+1))
+	})
+	switch {
+	case errors.Is(err, errBlockNotFound):
+		return utils.NotFound(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not delete block")
+	}
+	return utils.NoContent(c)
+}
+
+// ReorderBlocks расставляет блоки урока в порядке block_ids; перечислены должны быть все блоки урока
+func (bc *LessonBlocksController) ReorderBlocks(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, bc.db(c), bc.Cfg)
+	if done {
+		return err
+	}
+
+	var input struct {
+		BlockIDs []uint `json:"block_ids"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var list []models.LessonBlock
+	err = bc.db(c).Transaction(func(tx *gorm.DB) error {
+		current, err := blocks.Materialize(tx, lesson)
+		if err != nil {
+			return err
+		}
+		if len(input.BlockIDs) != len(current) {
+			return errBlockOrder
+		}
+		for _, id := range input.BlockIDs {
+			i := slices.IndexFunc(current, func(b models.LessonBlock) bool { return b.ID == id })
+			if i < 0 || slices.ContainsFunc(list, func(b models.LessonBlock) bool { return b.ID == id }) {
+				return errBlockOrder
+			}
+			list = append(list, current[i])
+		}
+		return bc.saveOrder(tx, lesson, list)
+	})
+	switch {
+	case errors.Is(err, errBlockOrder):
+		return utils.ValidationError(c, map[string]string{"block_ids": err.Error()})
+	case err != nil:
+		return utils.InternalServerError(c, "Could not reorder blocks")
+	}
+	return utils.Success(c, fiber.StatusOK, blocksPayload(list))
+}
+
+// saveOrder нумерует блоки по порядку list и пересобирает HTML урока
+func (bc *LessonBlocksController) saveOrder(tx *gorm.DB, lesson *models.Lesson, list []models.LessonBlock) error {
+	for i := range list {
+		if list[i].SequenceOrder == i+1 {
+			continue
+		}
+		list[i].SequenceOrder = i + 1
+		if err := tx.Model(&list[i]).Update("sequence_order", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return blocks.Sync(tx, lesson)
+}
+
+// viewableLesson загружает урок из :lessonId курса :id для студента. Курс вне каталога (черновик, на проверке,
+// в архиве) открыт только его студентам и редакторам, остальные проверяются по уровню доступа курса:
+// приватный — только записанным и сотрудникам, ограниченный — еще и списку допуска; платный — только после покупки
+func viewableLesson(c *fiber.Ctx, db *gorm.DB, cfg *config.Config) (*models.Lesson, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, cfg)
+	if err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var course models.Course
+	if err := db.Preload("AccessSettings").First(&course, c.Params("id")).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+	var enrolled int64
+	if err := db.Model(&models.UserCourseProgress{}).Where("user_id = ? AND course_id = ?", userID, course.ID).
+		Count(&enrolled).Error; err != nil {
+		return nil, true, utils.InternalServerError(c, "Could not query database")
+	}
+	if course.Status != models.CoursePublished && enrolled == 0 {
+		// Drafts aren't announced to anyone outside the course
+		if policy.Authorize(c, policy.ActionEdit, policy.Course(&course)) != nil {
+			return nil, true, utils.NotFound(c, "Course not found")
+		}
+	} else if err := policy.Authorize(c, policy.ActionView, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "This course is open only to its students"))
+	}
+	if paymentRequired(c, &course, enrolled > 0) {
+		return nil, true, utils.Error(c, fiber.StatusPaymentRequired, errors.New("Buy the course to open its lessons"),
+			fiber.Map{"payment_required": true, "price": pricePayload(course)})
+	}
+
+	var lesson models.Lesson
+	if err := db.Where("id = ? AND course_id = ?", c.Params("lessonId"), course.ID).First(&lesson).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Lesson not found")
+	}
+	return &lesson, false, nil
+}
+
+// editableLesson загружает урок из :lessonId курса :id и проверяет право на редактирование курса
+func editableLesson(c *fiber.Ctx, db *gorm.DB, cfg *config.Config) (*models.Lesson, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := db.First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+	// Author, co-admins and university course editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit lessons in this course"))
+	}
+
+	var lesson models.Lesson
+	if err := db.Where("id = ? AND course_id = ?", c.Params("lessonId"), course.ID).First(&lesson).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Lesson not found")
+	}
+	return &lesson, false, nil
+}
+
+func blockPayload(block models.LessonBlock) fiber.Map {
+	return fiber.Map{
+		"id":             block.ID,
+		"type":           block.Type,
+		"data":           json.RawMessage(block.Data),
+		"sequence_order": block.SequenceOrder,
+	}
+}
+
+func blocksPayload(list []models.LessonBlock) []fiber.Map {
+	result := make([]fiber.Map, 0, len(list))
+	for _, block := range list {
+		result = append(result, blockPayload(block))
+	}
+	return result
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/blocks"
+	"project/backend/config"
+	"project/backend/markup"
+	"project/backend/models"
+	"project/backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errDraftNotFound  = errors.New("This lesson has no draft")
+	errDraftRevision  = errors.New("The draft was saved by someone else, reload it before saving")
+	errDraftOutOfDate = errors.New("The lesson was changed after this draft was started, publish with force to overwrite it")
+	errDraftBlocks    = errors.New("This lesson is edited block by block and has no drafts, change its blocks instead")
+)
+
+type LessonDraftsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewLessonDraftsController(db *gorm.DB, cfg *config.Config) *LessonDraftsController {
+	return &LessonDraftsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (dc *LessonDraftsController) db(c *fiber.Ctx) *gorm.DB {
+	return dc.DB.WithContext(c.UserContext())
+}
+
+// GetDraft отдает черновик урока; 404, если черновика нет и редактор показывает сам урок
+func (dc *LessonDraftsController) GetDraft(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, dc.db(c), dc.Cfg)
+	if done {
+		return err
+	}
+
+	var draft models.LessonDraft
+	if err := dc.db(c).Where("lesson_id = ?", lesson.ID).First(&draft).Error; err != nil {
+		return utils.NotFound(c, errDraftNotFound.Error())
+	}
+	return utils.Success(c, fiber.StatusOK, draftPayload(draft, lesson))
+}
+
+// SaveDraft автосохраняет правки урока в черновик, не трогая опубликованный урок. Первый вызов начинает
+// черновик с текущего содержимого урока, следующие обязаны передать revision — номер, полученный при прошлом
+// сохранении: если черновик с тех пор сохранил кто-то другой, возвращается 409, чтобы редакторы не затирали
+// правки друг друга. Черновик хранит урок одной строкой, поэтому у уроков с блоками его нет.
+func (dc *LessonDraftsController) SaveDraft(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, dc.db(c), dc.Cfg)
+	if done {
+		return err
+	}
+	userID, _ := utils.ExtractUserIDFromToken(c, dc.Cfg)
+
+	var input struct {
+		Title       utils.Optional[string] `json:"title"`
+		Description utils.Optional[string] `json:"description"`
+		Content     utils.Optional[string] `json:"content"`
+		Markdown    utils.Optional[string] `json:"markdown"`
+		Revision    int                    `json:"revision"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var draft models.LessonDraft
+	err = dc.db(c).Transaction(func(tx *gorm.DB) error {
+		structured, err := blocks.Has(tx, lesson.ID)
+		if err != nil {
+			return err
+		}
+		if structured {
+			return errDraftBlocks
+		}
+		lockDraft := func() error {
+			return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("lesson_id = ?", lesson.ID).First(&draft).Error
+		}
+		err = lockDraft()
+		if errors.Is(err, gorm.ErrRecordNotFound) && input.Revision == 0 {
+			// Concurrent first saves insert one draft: the others wait for it here and then get a revision conflict
+			seed := models.LessonDraft{
+				LessonID:      lesson.ID,
+				Title:         lesson.Title,
+				Description:   lesson.Description,
+				Content:       lesson.Content,
+				Markdown:      lesson.Markdown,
+				BaseUpdatedAt: lesson.UpdatedAt,
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+				return err
+			}
+			err = lockDraft()
+		}
+		switch {
+		// A revision without a draft means it was published or discarded since that save
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return errDraftRevision
+		case err != nil:
+			return err
+		case input.Revision != draft.Revision:
+			return errDraftRevision
+		}
+
+		// Same rules as for the lesson itself: Markdown wins over HTML, HTML alone drops the Markdown source
+		merge := utils.IsMergePatch(c)
+		input.Title.Apply(&draft.Title, merge)
+		input.Description.Apply(&draft.Description, merge)
+		switch {
+		case input.Markdown.Apply(&draft.Markdown, merge) && draft.Markdown != "":
+			draft.Content = markup.Markdown(draft.Markdown)
+		case input.Content.Apply(&draft.Content, merge):
+			draft.Content = markup.Sanitize(draft.Content)
+			draft.Markdown = ""
+		}
+		draft.Revision++
+		draft.UpdatedByID = userID
+		return tx.Save(&draft).Error
+	})
+	if errors.Is(err, errDraftBlocks) {
+		return utils.Error(c, fiber.StatusConflict, err)
+	}
+	if errors.Is(err, errDraftRevision) {
+		return utils.Error(c, fiber.StatusConflict, err, fiber.Map{
+			"revision":      draft.Revision,
+			"updated_by_id": draft.UpdatedByID,
+			"updated_at":    draft.UpdatedAt,
+		})
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not save draft")
+	}
+	return utils.Success(c, fiber.StatusOK, draftPayload(draft, lesson))
+}
+
+// PublishDraft переносит черновик в урок и удаляет его. Если урок изменили в обход черновика после того,
+// как черновик был начат, публикация требует force: true, иначе эти изменения были бы молча затерты.
+// Урок, который с тех пор перешел на блоки, черновик не перезаписывает: такой черновик можно только удалить.
+func (dc *LessonDraftsController) PublishDraft(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, dc.db(c), dc.Cfg)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Force bool `json:"force"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.BadRequest(c, "Cannot parse JSON")
+		}
+	}
+
+	err = dc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(lesson, lesson.ID).Error; err != nil {
+			return err
+		}
+		var draft models.LessonDraft
+		if err := tx.Where("lesson_id = ?", lesson.ID).First(&draft).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errDraftNotFound
+			}
+			return err
+		}
+		// Force doesn't apply here: publishing would replace every quiz, video and embed with one string
+		structured, err := blocks.Has(tx, lesson.ID)
+		if err != nil {
+			return err
+		}
+		if structured {
+			return errDraftBlocks
+		}
+		if !input.Force && !lesson.UpdatedAt.Equal(draft.BaseUpdatedAt) {
+			return errDraftOutOfDate
+		}
+
+		lesson.Title, lesson.Description = draft.Title, draft.Description
+		lesson.Content, lesson.Markdown = draft.Content, draft.Markdown
+		if err := tx.Save(lesson).Error; err != nil {
+			return err
+		}
+		// Hard delete: a lesson has one draft, a soft-deleted row would keep the unique index taken
+		return tx.Unscoped().Delete(&draft).Error
+	})
+	switch {
+	case errors.Is(err, errDraftNotFound):
+		return utils.NotFound(c, err.Error())
+	case errors.Is(err, errDraftOutOfDate):
+		return utils.Error(c, fiber.StatusConflict, err, fiber.Map{"lesson_updated_at": lesson.UpdatedAt})
+	case errors.Is(err, errDraftBlocks):
+		return utils.Error(c, fiber.StatusConflict, err)
+	case err != nil:
+		return utils.InternalServerError(c, "Could not publish draft")
+	}
+	return utils.Success(c, fiber.StatusOK, lesson)
+}
+
+// DiscardDraft удаляет черновик урока; опубликованный урок не меняется
+func (dc *LessonDraftsController) DiscardDraft(c *fiber.Ctx) error {
+	lesson, done, err := editableLesson(c, dc.db(c), dc.Cfg)
+	if done {
+		return err
+	}
+
+	result := dc.db(c).Unscoped().Where("lesson_id = ?", lesson.ID).Delete(&models.LessonDraft{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not discard draft")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, errDraftNotFound.Error())
+	}
+	return utils.NoContent(c)
+}
+
+// draftPayload описывает черновик; out_of_date — урок изменили в обход черновика после его начала
+func draftPayload(draft models.LessonDraft, lesson *models.Lesson) fiber.Map {
+	return fiber.Map{
+		"lesson_id":     draft.LessonID,
+		"title":         draft.Title,
+		"description":   draft.Description,
+		"content":       draft.Content,
+		"markdown":      draft.Markdown,
+		"revision":      draft.Revision,
+		"out_of_date":   !lesson.UpdatedAt.Equal(draft.BaseUpdatedAt),
+		"updated_by_id": draft.UpdatedByID,
+		"updated_at":    draft.UpdatedAt,
+	}
+}
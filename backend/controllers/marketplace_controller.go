@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"project/backend/bundle"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errListingReviewed = errors.New("The listing has already been reviewed")
+
+type MarketplaceController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewMarketplaceController(db *gorm.DB, cfg *config.Config) *MarketplaceController {
+	return &MarketplaceController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (mc *MarketplaceController) db(c *fiber.Ctx) *gorm.DB {
+	return mc.DB.WithContext(c.UserContext())
+}
+
+// SubmitListing отправляет опубликованный курс в общий каталог; заявку проверяет администратор платформы
+func (mc *MarketplaceController) SubmitListing(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	// The license defaults to the course's own; a license given here relicenses the course
+	var input struct {
+		Summary     string                 `json:"summary"`
+		License     utils.Optional[string] `json:"license"`
+		Attribution utils.Optional[string] `json:"attribution"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	course, done, err := mc.editableCourse(c)
+	if done {
+		return err
+	}
+
+	license, attribution := course.License, course.Attribution
+	errs := applyLicense(input.License, input.Attribution, false, course.SourceLicense, &license, &attribution)
+	if _, ok := errs["license"]; !ok && !models.OpenLicense(license) {
+		errs["license"] = "Only courses under cc-by or cc-by-sa can be shared"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	if course.Status != models.CoursePublished {
+		return utils.Error(c, fiber.StatusConflict, errors.New("Only published courses can be submitted"), fiber.Map{"status": course.Status})
+	}
+
+	var active int64
+	mc.db(c).Model(&models.MarketplaceListing{}).
+		Where("course_id = ? AND status IN ?", course.ID, []string{models.ListingPending, models.ListingApproved}).
+		Count(&active)
+	if active > 0 {
+		return utils.Error(c, fiber.StatusConflict, errors.New("The course is already in the marketplace or waiting for review"))
+	}
+
+	b, err := bundle.BuildCourse(mc.db(c), course.ID)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not build course bundle")
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not build course bundle")
+	}
+
+	listing := models.MarketplaceListing{
+		CourseID:    course.ID,
+		SubmittedBy: userID,
+		University:  course.University,
+		Title:       course.Title,
+		Summary:     strings.TrimSpace(input.Summary),
+		License:     license,
+		Attribution: withSourceCredit(attribution, course.SourceAttribution),
+		Bundle:      string(data),
+		Status:      models.ListingPending,
+	}
+	err = mc.db(c).Transaction(func(tx *gorm.DB) error {
+		if license != course.License || attribution != course.Attribution {
+			if err := tx.Model(course).Updates(map[string]interface{}{"license": license, "attribution": attribution}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&listing).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not submit course")
+	}
+	return utils.Created(c, listingPayload(listing))
+}
+
+// WithdrawListing убирает курс из каталога; уже импортированные копии остаются у организаций
+func (mc *MarketplaceController) WithdrawListing(c *fiber.Ctx) error {
+	course, done, err := mc.editableCourse(c)
+	if done {
+		return err
+	}
+
+	result := mc.db(c).Model(&models.MarketplaceListing{}).
+		Where("course_id = ? AND status IN ?", course.ID, []string{models.ListingPending, models.ListingApproved}).
+		Update("status", models.ListingWithdrawn)
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not withdraw course")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "The course is not in the marketplace")
+	}
+	return utils.NoContent(c)
+}
+
+// GetListings возвращает одобренные курсы каталога (?search= по названию и описанию)
+func (mc *MarketplaceController) GetListings(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, mc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+	return mc.listings(c, models.ListingApproved)
+}
+
+// GetReviewQueue возвращает заявки для проверки (?status=, по умолчанию pending)
+func (mc *MarketplaceController) GetReviewQueue(c *fiber.Ctx) error {
+	return mc.listings(c, c.Query("status", models.ListingPending))
+}
+
+func (mc *MarketplaceController) listings(c *fiber.Ctx, status string) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := mc.db(c).Model(&models.MarketplaceListing{}).Where("status = ?", status)
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("title ILIKE ? OR summary ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+	var total int64
+	query.Count(&total)
+
+	var listings []models.MarketplaceListing
+	if err := query.Omit("bundle").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&listings).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch marketplace")
+	}
+
+	items := make([]fiber.Map, 0, len(listings))
+	for _, listing := range listings {
+		items = append(items, listingPayload(listing))
+	}
+	return utils.Paginate(c, items, total, page, pageSize)
+}
+
+// GetListing возвращает курс каталога с оглавлением. Неодобренные заявки видят только
+// подавший их пользователь и проверяющие.
+func (mc *MarketplaceController) GetListing(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var listing models.MarketplaceListing
+	if err := mc.db(c).First(&listing, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Listing not found")
+	}
+	if listing.Status != models.ListingApproved && listing.SubmittedBy != userID &&
+		!utils.HasPermission(mc.db(c), userID, models.PermMarketplaceReview) {
+		return utils.NotFound(c, "Listing not found")
+	}
+
+	var b bundle.Course
+	json.Unmarshal([]byte(listing.Bundle), &b)
+	outline := make([]fiber.Map, 0, len(b.Modules)+1)
+	lessonTitles := func(module *int) []string {
+		titles := []string{}
+		for _, lesson := range b.Lessons {
+			if (module == nil && lesson.Module == nil) || (module != nil && lesson.Module != nil && *module == *lesson.Module) {
+				titles = append(titles, lesson.Title)
+			}
+		}
+		return titles
+	}
+	for i, module := range b.Modules {
+		outline = append(outline, fiber.Map{"title": module.Title, "lessons": lessonTitles(&i)})
+	}
+	if rest := lessonTitles(nil); len(rest) > 0 {
+		outline = append(outline, fiber.Map{"title": "", "lessons": rest})
+	}
+
+	payload := listingPayload(listing)
+	payload["description"] = b.Description
+	payload["difficulty"] = b.Difficulty
+	payload["topic"] = b.Topic
+	payload["outline"] = outline
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// ApproveListing публикует заявку в каталоге
+func (mc *MarketplaceController) ApproveListing(c *fiber.Ctx) error {
+	return mc.review(c, models.ListingApproved)
+}
+
+// RejectListing отклоняет заявку; note объясняет автору причину
+func (mc *MarketplaceController) RejectListing(c *fiber.Ctx) error {
+	return mc.review(c, models.ListingRejected)
+}
+
+func (mc *MarketplaceController) review(c *fiber.Ctx, status string) error {
+	userID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.BadRequest(c, "Cannot parse JSON")
+		}
+	}
+	input.Note = strings.TrimSpace(input.Note)
+	if status == models.ListingRejected && input.Note == "" {
+		return utils.ValidationError(c, map[string]string{"note": "A note for the author is required"})
+	}
+
+	var listing models.MarketplaceListing
+	err = mc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&listing, c.Params("id")).Error; err != nil {
+			return err
+		}
+		if listing.Status != models.ListingPending {
+			return errListingReviewed
+		}
+
+		now := time.Now()
+		listing.Status, listing.ReviewedBy, listing.ReviewedAt, listing.ReviewNote = status, &userID, &now, input.Note
+		return tx.Model(&listing).Select("status", "reviewed_by", "reviewed_at", "review_note").Updates(&listing).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "Listing not found")
+	case errors.Is(err, errListingReviewed):
+		return utils.Error(c, fiber.StatusConflict, err, fiber.Map{"status": listing.Status})
+	case err != nil:
+		return utils.InternalServerError(c, "Could not review listing")
+	}
+	return utils.Success(c, fiber.StatusOK, listingPayload(listing))
+}
+
+// ImportListing создает из курса каталога черновик в университете пользователя
+func (mc *MarketplaceController) ImportListing(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var listing models.MarketplaceListing
+	if err := mc.db(c).Where("id = ? AND status = ?", c.Params("id"), models.ListingApproved).First(&listing).Error; err != nil {
+		return utils.NotFound(c, "Listing not found")
+	}
+
+	var user models.User
+	if err := mc.db(c).First(&user, userID).Error; err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+	if !utils.HasScopedPermission(mc.db(c), userID, models.PermCoursesCreate, user.University) {
+		return utils.Forbidden(c, "You don't have permission to create courses")
+	}
+
+	var b bundle.Course
+	if err := json.Unmarshal([]byte(listing.Bundle), &b); err != nil {
+		return utils.InternalServerError(c, "Listing bundle is corrupted")
+	}
+
+	// The copy stays under the shared license and keeps crediting the original authors
+	course := models.Course{
+		AuthorID:          userID,
+		UniversityID:      user.UniversityID,
+		University:        user.University,
+		License:           listing.License,
+		Attribution:       listing.Attribution,
+		SourceLicense:     listing.License,
+		SourceAttribution: listing.Attribution,
+	}
+	// The listing holds no files, lesson attachments stay with the source course
+	err = mc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := bundle.ImportCourse(tx, &b, &course, nil); err != nil {
+			return err
+		}
+		if err := tx.Create(&models.MarketplaceImport{ListingID: listing.ID, CourseID: course.ID, ImportedBy: userID}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&listing).UpdateColumn("imports", gorm.Expr("imports + 1")).Error
+	})
+	if errors.Is(err, bundle.ErrUnsupportedVersion) {
+		return utils.Error(c, fiber.StatusConflict, err)
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not import course")
+	}
+
+	return utils.Created(c, fiber.Map{
+		"id":          course.ID,
+		"title":       course.Title,
+		"status":      course.Status,
+		"listing_id":  listing.ID,
+		"license":     listing.License,
+		"attribution": listing.Attribution,
+	})
+}
+
+// editableCourse загружает курс из :id и проверяет право на его редактирование
+func (mc *MarketplaceController) editableCourse(c *fiber.Ctx) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, mc.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := mc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to share this course"))
+	}
+	return &course, false, nil
+}
+
+func listingPayload(listing models.MarketplaceListing) fiber.Map {
+	return fiber.Map{
+		"id":           listing.ID,
+		"course_id":    listing.CourseID,
+		"title":        listing.Title,
+		"summary":      listing.Summary,
+		"university":   listing.University,
+		"license":      listing.License,
+		"attribution":  listing.Attribution,
+		"status":       listing.Status,
+		"review_note":  listing.ReviewNote,
+		"reviewed_at":  listing.ReviewedAt,
+		"imports":      listing.Imports,
+		"submitted_by": listing.SubmittedBy,
+		"submitted_at": listing.CreatedAt,
+	}
+}
+
+// applyLicense меняет лицензию и указание авторства контента и проверяет результат.
+// sourceLicense — лицензия контента, из которого сделана копия: производное от cc-by-sa остается под cc-by-sa.
+func applyLicense(license, attribution utils.Optional[string], merge bool, sourceLicense string, dstLicense, dstAttribution *string) map[string]string {
+	license.Apply(dstLicense, merge)
+	attribution.Apply(dstAttribution, merge)
+	*dstAttribution = strings.TrimSpace(*dstAttribution)
+	if *dstLicense == "" {
+		*dstLicense = models.LicenseProprietary
+	}
+
+	errs := map[string]string{}
+	switch {
+	case !slices.Contains(models.Licenses, *dstLicense):
+		errs["license"] = "License must be proprietary, cc-by or cc-by-sa"
+	case sourceLicense == models.LicenseCCBYSA && *dstLicense != models.LicenseCCBYSA:
+		errs["license"] = "Content adapted from cc-by-sa material must stay under cc-by-sa"
+	}
+	if models.OpenLicense(*dstLicense) && *dstAttribution == "" {
+		errs["attribution"] = "Attribution is required for cc-by and cc-by-sa"
+	}
+	return errs
+}
+
+// withSourceCredit дополняет указание авторства курса обязательствами перед контентом, из которого он скопирован
+func withSourceCredit(attribution, source string) string {
+	if source == "" || strings.Contains(attribution, source) {
+		return attribution
+	}
+	return attribution + "; adapted from " + source
+}
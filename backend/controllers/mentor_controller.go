@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// mentorPageSize — сколько курсов и тестов студента видит наставник
+const mentorPageSize = 50
+
+type MentorController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewMentorController(db *gorm.DB, cfg *config.Config) *MentorController {
+	return &MentorController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (mc *MentorController) db(c *fiber.Ctx) *gorm.DB {
+	return mc.DB.WithContext(c.UserContext())
+}
+
+type mentorLinkItem struct {
+	UserID     uint       `json:"user_id"`
+	Username   string     `json:"username"`
+	AvatarURL  string     `json:"avatar_url"`
+	Status     string     `json:"status"`
+	Note       string     `json:"note"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+}
+
+// GetStudents возвращает студентов наставника вместе с неподтвержденными запросами
+func (mc *MentorController) GetStudents(c *fiber.Ctx) error {
+	mentorID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	students, err := mentorLinks(mc.db(c), "mentor_id", "student_id", mentorID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch students")
+	}
+	return utils.Success(c, fiber.StatusOK, students)
+}
+
+// RequestStudent отправляет студенту запрос на просмотр его прогресса
+func (mc *MentorController) RequestStudent(c *fiber.Ctx) error {
+	mentorID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		StudentID uint   `json:"student_id"`
+		Note      string `json:"note"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.StudentID == mentorID {
+		return utils.ValidationError(c, map[string]string{"student_id": "You can't mentor yourself"})
+	}
+
+	var student models.User
+	if err := mc.db(c).Select("id", "email", "active").First(&student, input.StudentID).Error; err != nil || !student.Active {
+		return utils.NotFound(c, "User not found")
+	}
+	var mentor models.User
+	if err := mc.db(c).Select("id", "username").First(&mentor, mentorID).Error; err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	// Repeating the request keeps an already given consent
+	link := models.MentorLink{MentorID: mentorID, StudentID: student.ID, Status: models.MentorLinkPending, Note: input.Note}
+	err = mc.db(c).Transaction(func(tx *gorm.DB) error {
+		var existing models.MentorLink
+		err := tx.Where("mentor_id = ? AND student_id = ?", mentorID, student.ID).First(&existing).Error
+		if err == nil {
+			link = existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueEmail(tx, student.Email, mentor.Username+" asks to follow your progress",
+			fmt.Sprintf("%s would like read-only access to your course and test progress. "+
+				"Accept or decline the request in your account settings.", mentor.Username))
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not send mentor request")
+	}
+
+	return utils.Created(c, link)
+}
+
+// RemoveStudent удаляет связь со студентом или отзывает неподтвержденный запрос
+func (mc *MentorController) RemoveStudent(c *fiber.Ctx) error {
+	mentorID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	studentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	return deleteMentorLink(c, mc.db(c), mentorID, uint(studentID))
+}
+
+// GetStudentProgress показывает наставнику прогресс студента, давшего согласие. Только чтение.
+func (mc *MentorController) GetStudentProgress(c *fiber.Ctx) error {
+	mentorID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	studentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	db := mc.db(c)
+	var link models.MentorLink
+	if err := db.Where("mentor_id = ? AND student_id = ? AND status = ?", mentorID, studentID, models.MentorLinkAccepted).
+		First(&link).Error; err != nil {
+		return utils.Forbidden(c, "The student hasn't accepted your mentor request")
+	}
+
+	var student models.User
+	if err := db.Select("id", "username", "avatar_url").First(&student, studentID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	courses, _, err := listUserCourses(db, student.ID, "all", "", 1, mentorPageSize)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch progress data")
+	}
+	tests, _, err := listUserTests(db, student.ID, "all", "", 1, mentorPageSize)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch progress data")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"student": fiber.Map{
+			"id":         student.ID,
+			"username":   student.Username,
+			"avatar_url": student.AvatarURL,
+		},
+		"overview": loadProgressOverview(db, student.ID),
+		"courses":  courses,
+		"tests":    tests,
+	})
+}
+
+// GetMentors возвращает наставников студента и их запросы
+func (mc *MentorController) GetMentors(c *fiber.Ctx) error {
+	studentID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	mentors, err := mentorLinks(mc.db(c), "student_id", "mentor_id", studentID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch mentors")
+	}
+	return utils.Success(c, fiber.StatusOK, mentors)
+}
+
+// AcceptMentor дает наставнику согласие на просмотр прогресса
+func (mc *MentorController) AcceptMentor(c *fiber.Ctx) error {
+	studentID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	mentorID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	var link models.MentorLink
+	if err := mc.db(c).Where("mentor_id = ? AND student_id = ?", mentorID, studentID).First(&link).Error; err != nil {
+		return utils.NotFound(c, "Mentor request not found")
+	}
+	if link.Status != models.MentorLinkAccepted {
+		now := time.Now()
+		link.Status, link.AcceptedAt = models.MentorLinkAccepted, &now
+		if err := mc.db(c).Model(&link).Select("status", "accepted_at").Updates(&link).Error; err != nil {
+			return utils.InternalServerError(c, "Could not accept mentor request")
+		}
+	}
+
+	return utils.Success(c, fiber.StatusOK, link)
+}
+
+// RemoveMentor отклоняет запрос наставника или отзывает ранее данное согласие
+func (mc *MentorController) RemoveMentor(c *fiber.Ctx) error {
+	studentID, err := utils.ExtractUserIDFromToken(c, mc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	mentorID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	return deleteMentorLink(c, mc.db(c), uint(mentorID), studentID)
+}
+
+// mentorLinks возвращает связи пользователя (ownColumn) с данными второй стороны (otherColumn)
+func mentorLinks(db *gorm.DB, ownColumn, otherColumn string, userID uint) ([]mentorLinkItem, error) {
+	items := []mentorLinkItem{}
+	err := db.Table("mentor_links").
+		Select("users.id AS user_id, users.username, users.avatar_url, mentor_links.status, mentor_links.note, "+
+			"mentor_links.created_at, mentor_links.accepted_at").
+		Joins("JOIN users ON users.id = mentor_links."+otherColumn).
+		Where("mentor_links."+ownColumn+" = ? AND mentor_links.deleted_at IS NULL", userID).
+		Order("mentor_links.created_at DESC").
+		Scan(&items).Error
+	return items, err
+}
+
+func deleteMentorLink(c *fiber.Ctx, db *gorm.DB, mentorID, studentID uint) error {
+	// Hard delete: the (mentor, student) pair is unique, so a soft-deleted row would block a new request
+	result := db.Unscoped().Where("mentor_id = ? AND student_id = ?", mentorID, studentID).Delete(&models.MentorLink{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not remove mentor link")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "Mentor link not found")
+	}
+	return utils.NoContent(c)
+}
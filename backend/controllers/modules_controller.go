@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errModuleNotFound = errors.New("Module not found")
+
+type ModulesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewModulesController(db *gorm.DB, cfg *config.Config) *ModulesController {
+	return &ModulesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (mc *ModulesController) db(c *fiber.Ctx) *gorm.DB {
+	return mc.DB.WithContext(c.UserContext())
+}
+
+// GetModules возвращает разделы курса с их уроками
+func (mc *ModulesController) GetModules(c *fiber.Ctx) error {
+	course, done, err := mc.editableCourse(c)
+	if done {
+		return err
+	}
+
+	var modules []models.CourseModule
+	var lessons []models.Lesson
+	if err := mc.db(c).Where("course_id = ?", course.ID).Find(&modules).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch modules")
+	}
+	if err := mc.db(c).Where("course_id = ?", course.ID).Find(&lessons).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch lessons")
+	}
+
+	tree, unassigned := moduleTree(modules, lessons)
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"modules":            tree,
+		"unassigned_lessons": unassigned,
+	})
+}
+
+// CreateModule добавляет раздел в конец курса
+func (mc *ModulesController) CreateModule(c *fiber.Ctx) error {
+	course, done, err := mc.editableCourse(c)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Title         string `json:"title"`
+		SequenceOrder *int   `json:"sequence_order"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	input.Title = strings.TrimSpace(input.Title)
+	if input.Title == "" {
+		return utils.ValidationError(c, map[string]string{"title": "Title is required"})
+	}
+
+	module := models.CourseModule{CourseID: course.ID, Title: input.Title}
+	if input.SequenceOrder != nil {
+		module.SequenceOrder = *input.SequenceOrder
+	} else {
+		var count int64
+		mc.db(c).Model(&models.CourseModule{}).Where("course_id = ?", course.ID).Count(&count)
+		module.SequenceOrder = int(count) + 1
+	}
+	if err := mc.db(c).Create(&module).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create module")
+	}
+
+	return utils.Created(c, modulePayload(module, []models.Lesson{}))
+}
+
+// UpdateModule переименовывает или переставляет раздел (PUT и PATCH)
+func (mc *ModulesController) UpdateModule(c *fiber.Ctx) error {
+	course, done, err := mc.editableCourse(c)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Title         utils.Optional[string] `json:"title"`
+		SequenceOrder utils.Optional[int]    `json:"sequence_order"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var module models.CourseModule
+	if err := mc.db(c).Where("id = ? AND course_id = ?", c.Params("moduleId"), course.ID).First(&module).Error; err != nil {
+		return utils.NotFound(c, errModuleNotFound.Error())
+	}
+
+	// A module always keeps a title, so null and "" leave it as is
+	input.Title.Apply(&module.Title, false)
+	input.SequenceOrder.Apply(&module.SequenceOrder, utils.IsMergePatch(c))
+	module.Title = strings.TrimSpace(module.Title)
+	if module.Title == "" {
+		return utils.ValidationError(c, map[string]string{"title": "Title is required"})
+	}
+	if err := mc.db(c).Save(&module).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update module")
+	}
+
+	var lessons []models.Lesson
+	mc.db(c).Where("module_id = ?", module.ID).Find(&lessons)
+	return utils.Success(c, fiber.StatusOK, modulePayload(module, lessons))
+}
+
+// DeleteModule удаляет раздел; его уроки остаются в курсе без раздела
+func (mc *ModulesController) DeleteModule(c *fiber.Ctx) error {
+	course, done, err := mc.editableCourse(c)
+	if done {
+		return err
+	}
+	if utils.UnderLegalHold(mc.db(c), models.HoldSubjectCourse, course.ID) {
+		return utils.LegalHoldConflict(c)
+	}
+
+	err = mc.db(c).Transaction(func(tx *gorm.DB) error {
+		var module models.CourseModule
+		if err := tx.Where("id = ? AND course_id = ?", c.Params("moduleId"), course.ID).First(&module).Error; err != nil {
+			return errModuleNotFound
+		}
+		if err := tx.Model(&models.Lesson{}).Where("module_id = ?", module.ID).Update("module_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&module).Error
+	})
+	switch {
+	case errors.Is(err, errModuleNotFound):
+		return utils.NotFound(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not delete module")
+	}
+	return utils.NoContent(c)
+}
+
+// editableCourse загружает курс из :id и проверяет право на его редактирование
+func (mc *ModulesController) editableCourse(c *fiber.Ctx) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, mc.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := mc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	// Author, co-admins and university course editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit modules of this course"))
+	}
+	return &course, false, nil
+}
+
+// moduleTree раскладывает уроки по разделам; оба уровня упорядочены по sequence_order
+func moduleTree(modules []models.CourseModule, lessons []models.Lesson) ([]fiber.Map, []models.Lesson) {
+	sort.SliceStable(modules, func(i, j int) bool { return modules[i].SequenceOrder < modules[j].SequenceOrder })
+	ordered := make([]models.Lesson, len(lessons))
+	copy(ordered, lessons)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].SequenceOrder < ordered[j].SequenceOrder })
+
+	byModule := make(map[uint][]models.Lesson, len(modules))
+	for _, module := range modules {
+		byModule[module.ID] = []models.Lesson{}
+	}
+	unassigned := []models.Lesson{}
+	for _, lesson := range ordered {
+		if lesson.ModuleID != nil {
+			if _, ok := byModule[*lesson.ModuleID]; ok {
+				byModule[*lesson.ModuleID] = append(byModule[*lesson.ModuleID], lesson)
+				continue
+			}
+		}
+		unassigned = append(unassigned, lesson)
+	}
+
+	tree := make([]fiber.Map, 0, len(modules))
+	for _, module := range modules {
+		tree = append(tree, modulePayload(module, byModule[module.ID]))
+	}
+	return tree, unassigned
+}
+
+func modulePayload(module models.CourseModule, lessons []models.Lesson) fiber.Map {
+	return fiber.Map{
+		"id":             module.ID,
+		"title":          module.Title,
+		"sequence_order": module.SequenceOrder,
+		"lessons":        lessons,
+	}
+}
+
+// lessonModule проверяет, что раздел принадлежит курсу урока
+func lessonModule(db *gorm.DB, courseID uint, moduleID uint) error {
+	var count int64
+	if err := db.Model(&models.CourseModule{}).Where("id = ? AND course_id = ?", moduleID, courseID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errModuleNotFound
+	}
+	return nil
+}
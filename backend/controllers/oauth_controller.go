@@ -0,0 +1,403 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// oauthCodeTTL — сколько живет код авторизации до обмена на токен
+const oauthCodeTTL = 5 * time.Minute
+
+type OAuthController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewOAuthController(db *gorm.DB, cfg *config.Config) *OAuthController {
+	return &OAuthController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (oc *OAuthController) db(c *fiber.Ctx) *gorm.DB {
+	return oc.DB.WithContext(c.UserContext())
+}
+
+type oauthClientInput struct {
+	Name         utils.Optional[string]   `json:"name"`
+	Kind         utils.Optional[string]   `json:"kind"`
+	Scopes       utils.Optional[[]string] `json:"scopes"`
+	RedirectURIs utils.Optional[[]string] `json:"redirect_uris"`
+}
+
+// apply переносит поля в клиента; merge = true для PATCH
+func (input oauthClientInput) apply(client *models.OAuthClient, merge bool) map[string]string {
+	errs := map[string]string{}
+	input.Name.Apply(&client.Name, merge)
+	client.Name = strings.TrimSpace(client.Name)
+	if client.Name == "" {
+		errs["name"] = "Name is required"
+	}
+
+	if input.Scopes.Set || !merge {
+		for _, scope := range input.Scopes.Value {
+			if !slices.Contains(models.Scopes, scope) {
+				errs["scopes"] = "Unknown scope " + scope + ", expected one of " + strings.Join(models.Scopes, ", ")
+			}
+		}
+		client.Scopes = strings.Join(input.Scopes.Value, " ")
+	}
+	if client.Scopes == "" {
+		errs["scopes"] = "At least one scope is required"
+	}
+
+	if input.RedirectURIs.Set || !merge {
+		client.RedirectURIs = strings.Join(input.RedirectURIs.Value, " ")
+	}
+	if client.RedirectURIs == "" {
+		errs["redirect_uris"] = "At least one redirect URI is required"
+	}
+	return errs
+}
+
+// GetClients возвращает зарегистрированные клиентские приложения (без секретов)
+func (oc *OAuthController) GetClients(c *fiber.Ctx) error {
+	var clients []models.OAuthClient
+	if err := oc.db(c).Order("created_at DESC").Find(&clients).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch clients")
+	}
+
+	result := make([]fiber.Map, 0, len(clients))
+	for _, client := range clients {
+		result = append(result, oauthClientResponse(client))
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// CreateClient регистрирует приложение. Секрет веб- и сторонних приложений возвращается только один раз.
+func (oc *OAuthController) CreateClient(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, oc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input oauthClientInput
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	client := models.OAuthClient{Kind: input.Kind.Value, CreatedBy: userID}
+	errs := input.apply(&client, false)
+	if !slices.Contains(models.ClientKinds, client.Kind) {
+		errs["kind"] = "Kind must be one of " + strings.Join(models.ClientKinds, ", ")
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	clientID, err := randomToken("cl_", 12)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not generate client ID")
+	}
+	client.ClientID = clientID
+
+	var secret string
+	if client.Kind != models.ClientMobile {
+		if secret, err = randomToken("cs_", 32); err != nil {
+			return utils.InternalServerError(c, "Could not generate client secret")
+		}
+		client.SecretHash = utils.HashAPIKey(secret)
+	}
+
+	if err := oc.db(c).Create(&client).Error; err != nil {
+		return utils.InternalServerError(c, "Could not register client")
+	}
+
+	response := oauthClientResponse(client)
+	if secret != "" {
+		response["client_secret"] = secret
+	}
+	return utils.Created(c, response)
+}
+
+// UpdateClient меняет название, области и адреса возврата; сужение областей действует и на выданные токены
+func (oc *OAuthController) UpdateClient(c *fiber.Ctx) error {
+	client, done, err := oc.findClient(c)
+	if done {
+		return err
+	}
+
+	var input oauthClientInput
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Kind.Set && input.Kind.Value != client.Kind {
+		return utils.ValidationError(c, map[string]string{"kind": "The kind of a client can't be changed, register a new one"})
+	}
+	if errs := input.apply(client, c.Method() == fiber.MethodPatch); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	if err := oc.db(c).Save(client).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update client")
+	}
+	return utils.Success(c, fiber.StatusOK, oauthClientResponse(*client))
+}
+
+// RotateClientSecret выдает новый секрет; старый перестает работать сразу
+func (oc *OAuthController) RotateClientSecret(c *fiber.Ctx) error {
+	client, done, err := oc.findClient(c)
+	if done {
+		return err
+	}
+	if client.Kind == models.ClientMobile {
+		return utils.BadRequest(c, "Mobile clients have no secret, they use PKCE")
+	}
+
+	secret, err := randomToken("cs_", 32)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not generate client secret")
+	}
+	if err := oc.db(c).Model(client).Update("secret_hash", utils.HashAPIKey(secret)).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update client")
+	}
+
+	response := oauthClientResponse(*client)
+	response["client_secret"] = secret
+	return utils.Success(c, fiber.StatusOK, response)
+}
+
+// RevokeClient отзывает приложение: новые токены не выдаются, выданные перестают приниматься
+func (oc *OAuthController) RevokeClient(c *fiber.Ctx) error {
+	client, done, err := oc.findClient(c)
+	if done {
+		return err
+	}
+
+	now := time.Now()
+	if err := oc.db(c).Model(client).Update("revoked_at", now).Error; err != nil {
+		return utils.InternalServerError(c, "Could not revoke client")
+	}
+	client.RevokedAt = &now
+	return utils.Success(c, fiber.StatusOK, oauthClientResponse(*client))
+}
+
+// Authorize выдает приложению код авторизации от имени вошедшего пользователя.
+// scope — области через пробел (по умолчанию все разрешенные клиенту), мобильные приложения передают code_challenge (S256).
+func (oc *OAuthController) Authorize(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, oc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		ClientID            string `json:"client_id"`
+		Scope               string `json:"scope"`
+		RedirectURI         string `json:"redirect_uri"`
+		CodeChallenge       string `json:"code_challenge"`
+		CodeChallengeMethod string `json:"code_challenge_method"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var client models.OAuthClient
+	if err := oc.db(c).Where("client_id = ? AND revoked_at IS NULL", input.ClientID).First(&client).Error; err != nil {
+		return utils.NotFound(c, "Client not found")
+	}
+
+	errs := map[string]string{}
+	redirectURIs := strings.Fields(client.RedirectURIs)
+	if input.RedirectURI == "" && len(redirectURIs) == 1 {
+		input.RedirectURI = redirectURIs[0]
+	}
+	if !slices.Contains(redirectURIs, input.RedirectURI) {
+		errs["redirect_uri"] = "The redirect URI isn't registered for this client"
+	}
+
+	scope := strings.Join(strings.Fields(input.Scope), " ")
+	if scope == "" {
+		scope = client.Scopes
+	}
+	for _, requested := range strings.Fields(scope) {
+		if !utils.ScopeIncludes(client.Scopes, requested) {
+			errs["scope"] = "The client may not ask for " + requested
+		}
+	}
+
+	if input.CodeChallengeMethod != "" && input.CodeChallengeMethod != "S256" {
+		errs["code_challenge_method"] = "Only S256 is supported"
+	}
+	if client.Kind == models.ClientMobile && input.CodeChallenge == "" {
+		errs["code_challenge"] = "Mobile clients must use PKCE"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	code, err := randomToken("", 32)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not generate code")
+	}
+	if err := oc.db(c).Create(&models.OAuthCode{
+		CodeHash:      utils.HashAPIKey(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		Scope:         scope,
+		RedirectURI:   input.RedirectURI,
+		CodeChallenge: input.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	}).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create code")
+	}
+
+	return utils.Created(c, fiber.Map{
+		"code":         code,
+		"redirect_uri": input.RedirectURI,
+		"scope":        scope,
+		"expires_in":   int(oauthCodeTTL.Seconds()),
+	})
+}
+
+// Token обменивает код авторизации на токен доступа (grant_type=authorization_code).
+// Ответ и ошибки — в формате RFC 6749, чтобы подходили стандартные клиентские библиотеки.
+func (oc *OAuthController) Token(c *fiber.Ctx) error {
+	var input struct {
+		GrantType    string `json:"grant_type" form:"grant_type"`
+		Code         string `json:"code" form:"code"`
+		RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`
+		ClientID     string `json:"client_id" form:"client_id"`
+		ClientSecret string `json:"client_secret" form:"client_secret"`
+		CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "Cannot parse the request body")
+	}
+	// Confidential clients may authenticate with HTTP Basic instead of the body
+	if id, secret, ok := basicAuth(c); ok {
+		input.ClientID, input.ClientSecret = id, secret
+	}
+	if input.GrantType != "authorization_code" {
+		return oauthError(c, fiber.StatusBadRequest, "unsupported_grant_type", "Only authorization_code is supported")
+	}
+
+	var client models.OAuthClient
+	if err := oc.db(c).Where("client_id = ? AND revoked_at IS NULL", input.ClientID).First(&client).Error; err != nil {
+		return oauthError(c, fiber.StatusUnauthorized, "invalid_client", "Unknown client")
+	}
+	if client.SecretHash != "" &&
+		subtle.ConstantTimeCompare([]byte(utils.HashAPIKey(input.ClientSecret)), []byte(client.SecretHash)) != 1 {
+		return oauthError(c, fiber.StatusUnauthorized, "invalid_client", "Invalid client secret")
+	}
+
+	var code models.OAuthCode
+	err := oc.db(c).Where("code_hash = ? AND client_id = ?", utils.HashAPIKey(input.Code), client.ID).First(&code).Error
+	if err != nil || code.UsedAt != nil || code.ExpiresAt.Before(time.Now()) || code.RedirectURI != input.RedirectURI {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "The code is invalid, expired or already used")
+	}
+	if code.CodeChallenge != "" && !verifyPKCE(code.CodeChallenge, input.CodeVerifier) {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "The code verifier doesn't match")
+	}
+
+	// A code is good for exactly one token, even when two exchanges race
+	result := oc.db(c).Model(&code).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "Could not redeem the code")
+	}
+	if result.RowsAffected == 0 {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "The code is invalid, expired or already used")
+	}
+
+	var user models.User
+	if err := oc.db(c).First(&user, code.UserID).Error; err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "The user no longer exists")
+	}
+	if authErr := utils.AccountBlocked(&user); authErr != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", authErr.Message)
+	}
+
+	token, ttl, err := utils.GenerateClientToken(&user, client.ClientID, code.Scope, oc.Cfg)
+	if err != nil {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "Could not issue the token")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(ttl.Seconds()),
+		"scope":        code.Scope,
+	})
+}
+
+// findClient загружает клиента из :id
+func (oc *OAuthController) findClient(c *fiber.Ctx) (*models.OAuthClient, bool, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid client ID")
+	}
+
+	var client models.OAuthClient
+	if err := oc.db(c).First(&client, id).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Client not found")
+	}
+	return &client, false, nil
+}
+
+func oauthClientResponse(client models.OAuthClient) fiber.Map {
+	return fiber.Map{
+		"id":            client.ID,
+		"client_id":     client.ClientID,
+		"name":          client.Name,
+		"kind":          client.Kind,
+		"confidential":  client.SecretHash != "",
+		"scopes":        strings.Fields(client.Scopes),
+		"redirect_uris": strings.Fields(client.RedirectURIs),
+		"created_at":    client.CreatedAt,
+		"revoked_at":    client.RevokedAt,
+	}
+}
+
+func oauthError(c *fiber.Ctx, status int, code, description string) error {
+	return c.Status(status).JSON(fiber.Map{"error": code, "error_description": description})
+}
+
+// verifyPKCE проверяет code_verifier против сохраненного S256 challenge
+func verifyPKCE(challenge, verifier string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return verifier != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	header := c.Get(fiber.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(string(decoded), ":")
+	return id, secret, ok
+}
+
+func randomToken(prefix string, size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
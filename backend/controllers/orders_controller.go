@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/payments"
+	"project/backend/policy"
+	"project/backend/utils"
+	"project/backend/waitlist"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var currencyPattern = regexp.MustCompile(`^[a-z]{3}$`)
+
+type OrdersController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewOrdersController(db *gorm.DB, cfg *config.Config) *OrdersController {
+	return &OrdersController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (oc *OrdersController) db(c *fiber.Ctx) *gorm.DB {
+	return oc.DB.WithContext(c.UserContext())
+}
+
+// CreateCheckout создает заказ платного курса и страницу оплаты у провайдера.
+// Неоплаченный заказ на ту же цену переиспользуется, чтобы повторное нажатие не плодило заказы.
+func (oc *OrdersController) CreateCheckout(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, oc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := oc.db(c).Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionView, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have access to this course"))
+	}
+	if err := restrictedCourseAccess(c, &course); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "This course is open only to users on its access list"))
+	}
+	if course.Price <= 0 {
+		return utils.Error(c, fiber.StatusUnprocessableEntity, errors.New("This course is free, open it to enroll"))
+	}
+	if course.AccessSettings.ScheduleStatus(time.Now()) == models.ScheduleClosed {
+		return utils.Error(c, fiber.StatusForbidden, errors.New("The course has ended"))
+	}
+	if _, disabled := payments.Default.(payments.Disabled); disabled {
+		return utils.Error(c, fiber.StatusServiceUnavailable, errors.New("Payments are not available on this server"))
+	}
+
+	var enrolled int64
+	if err := oc.db(c).Model(&models.UserCourseProgress{}).
+		Where("user_id = ? AND course_id = ?", userID, course.ID).Count(&enrolled).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	if enrolled > 0 {
+		return utils.Error(c, fiber.StatusConflict, errors.New("You are already enrolled in this course"))
+	}
+	seat, err := waitlist.HasSeat(oc.db(c), course.AccessSettings, userID, time.Now())
+	if err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	if !seat {
+		position, _ := waitlist.Position(oc.db(c), course.ID, userID)
+		return utils.Error(c, fiber.StatusConflict, errors.New("The course is full, join its waitlist to take the next free seat"),
+			fiber.Map{"course_full": true, "waitlist_position": position})
+	}
+
+	// One pending order per user and course, enforced by a unique index
+	var order models.Order
+	err = oc.db(c).Where("user_id = ? AND course_id = ? AND status = ?", userID, course.ID, models.OrderPending).First(&order).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	// The price or the provider changed since the order was made: it is canceled, a paid session still enrolls
+	if order.ID != 0 && (order.Amount != course.Price || order.Currency != course.Currency || order.Provider != payments.Default.Name()) {
+		if err := oc.db(c).Model(&order).Update("status", models.OrderCanceled).Error; err != nil {
+			return utils.InternalServerError(c, "Could not query database")
+		}
+		order = models.Order{}
+	}
+	if order.ID != 0 && order.CheckoutURL != "" {
+		return utils.Success(c, fiber.StatusOK, orderPayload(order, course.Title))
+	}
+
+	if order.ID == 0 {
+		order = models.Order{
+			UserID:   userID,
+			CourseID: course.ID,
+			Amount:   course.Price,
+			Currency: course.Currency,
+			Status:   models.OrderPending,
+			Provider: payments.Default.Name(),
+		}
+		result := oc.db(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&order)
+		if result.Error != nil {
+			return utils.InternalServerError(c, "Could not create order")
+		}
+		if result.RowsAffected == 0 {
+			// A concurrent checkout created the order and is starting its payment
+			return utils.Error(c, fiber.StatusConflict, errors.New("The payment for this course is already being started, try again in a moment"))
+		}
+	}
+
+	var user models.User
+	oc.db(c).Select("id", "email").First(&user, userID)
+	session, err := payments.Default.CreateCheckout(c.UserContext(), payments.Checkout{
+		OrderID:     order.ID,
+		Amount:      order.Amount,
+		Currency:    order.Currency,
+		Description: course.Title,
+		Email:       user.Email,
+		ReturnURL:   strings.ReplaceAll(oc.Cfg.PaymentReturnURL, "{order}", strconv.FormatUint(uint64(order.ID), 10)),
+	})
+	if err != nil {
+		log.Printf("checkout for order %d failed: %v", order.ID, err)
+		oc.db(c).Model(&order).Update("status", models.OrderFailed)
+		return utils.Error(c, fiber.StatusBadGateway, errors.New("Could not start the payment, please try again"))
+	}
+
+	order.ProviderSessionID = session.ID
+	order.CheckoutURL = session.URL
+	if err := oc.db(c).Model(&order).Updates(map[string]interface{}{
+		"provider_session_id": session.ID,
+		"checkout_url":        session.URL,
+	}).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save order")
+	}
+	return utils.Created(c, orderPayload(order, course.Title))
+}
+
+// GetOrders возвращает заказы пользователя, начиная с последних
+func (oc *OrdersController) GetOrders(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, oc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := oc.db(c).Model(&models.Order{}).Where("user_id = ?", userID)
+	var total int64
+	query.Count(&total)
+
+	var orders []models.Order
+	if err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&orders).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch orders")
+	}
+
+	courseIDs := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		courseIDs = append(courseIDs, order.CourseID)
+	}
+	titles, err := courseTitles(oc.db(c), courseIDs)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch orders")
+	}
+
+	items := make([]fiber.Map, 0, len(orders))
+	for _, order := range orders {
+		items = append(items, orderPayload(order, titles[order.CourseID]))
+	}
+	return utils.Paginate(c, items, total, page, pageSize)
+}
+
+// GetOrder возвращает заказ пользователя; страница возврата с оплаты опрашивает его, пока не придет вебхук
+func (oc *OrdersController) GetOrder(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, oc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var order models.Order
+	if err := oc.db(c).Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&order).Error; err != nil {
+		return utils.NotFound(c, "Order not found")
+	}
+	titles, err := courseTitles(oc.db(c), []uint{order.CourseID})
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch order")
+	}
+	return utils.Success(c, fiber.StatusOK, orderPayload(order, titles[order.CourseID]))
+}
+
+// PaymentWebhook принимает уведомления провайдера об оплате. Оплаченный заказ зачисляет студента на курс;
+// повторные уведомления ничего не меняют.
+func (oc *OrdersController) PaymentWebhook(c *fiber.Ctx) error {
+	event, err := payments.Default.ParseWebhook(c.UserContext(), c.Body(), func(key string) string { return c.Get(key) })
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		return utils.Error(c, fiber.StatusServiceUnavailable, err)
+	case errors.Is(err, payments.ErrSignature):
+		return utils.BadRequest(c, "Invalid webhook")
+	case err != nil:
+		// The provider retries failed deliveries
+		log.Printf("payment webhook failed: %v", err)
+		return utils.Error(c, fiber.StatusBadGateway, errors.New("Could not process the webhook"))
+	}
+	if event.Type == "" || event.OrderID == 0 {
+		return utils.Success(c, fiber.StatusOK, fiber.Map{"processed": false})
+	}
+
+	processed := false
+	err = oc.db(c).Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND provider = ?", event.OrderID, payments.Default.Name()).First(&order).Error; err != nil {
+			return err
+		}
+		if event.SessionID != "" && order.ProviderSessionID != "" && event.SessionID != order.ProviderSessionID {
+			return gorm.ErrRecordNotFound
+		}
+
+		switch {
+		case order.Status == models.OrderPaid:
+			return nil
+		case event.Type == payments.EventPaid:
+			now := time.Now()
+			if err := tx.Model(&order).Updates(map[string]interface{}{
+				"status":              models.OrderPaid,
+				"provider_payment_id": event.PaymentID,
+				"paid_at":             &now,
+			}).Error; err != nil {
+				return err
+			}
+			processed = true
+			return enrollPaid(tx, order)
+		case order.Status == models.OrderPending:
+			status := models.OrderCanceled
+			if event.Type == payments.EventFailed {
+				status = models.OrderFailed
+			}
+			processed = true
+			return tx.Model(&order).Update("status", status).Error
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Not one of ours (another environment sharing the account); don't make the provider retry
+		return utils.Success(c, fiber.StatusOK, fiber.Map{"processed": false})
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not process the webhook")
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"processed": processed})
+}
+
+// enrollPaid зачисляет покупателя на курс. Запись прогресса пустая: первый урок откроет курс как обычно.
+func enrollPaid(tx *gorm.DB, order models.Order) error {
+	var progress models.UserCourseProgress
+	if err := tx.Where(models.UserCourseProgress{UserID: order.UserID, CourseID: order.CourseID}).FirstOrCreate(&progress).Error; err != nil {
+		return err
+	}
+	// The seat held for the buyer becomes their enrollment
+	return waitlist.Leave(tx, order.CourseID, order.UserID)
+}
+
+// paymentRequired сообщает, что курс нужно купить: курс платный, пользователь еще не зачислен
+// и не редактирует курс сам
+func paymentRequired(c *fiber.Ctx, course *models.Course, enrolled bool) bool {
+	if course.Price <= 0 || enrolled {
+		return false
+	}
+	return policy.Authorize(c, policy.ActionEdit, policy.Course(course)) != nil
+}
+
+// applyPrice задает цену курса; без валюты берется уже заданная или валюта платформы
+func applyPrice(course *models.Course, price *int64, currency, fallback string) map[string]string {
+	errs := map[string]string{}
+	if price == nil && currency == "" {
+		return errs
+	}
+	if price != nil {
+		if *price < 0 {
+			errs["price"] = "Price can't be negative"
+		}
+		course.Price = *price
+	}
+	currency = strings.ToLower(strings.TrimSpace(currency))
+	switch {
+	case currency != "":
+		if !currencyPattern.MatchString(currency) {
+			errs["currency"] = "Currency must be a three-letter ISO 4217 code"
+		}
+		course.Currency = currency
+	case course.Currency == "":
+		course.Currency = strings.ToLower(fallback)
+	}
+	return errs
+}
+
+// pricePayload — цена курса для каталога, nil для бесплатных
+func pricePayload(course models.Course) fiber.Map {
+	if course.Price <= 0 {
+		return nil
+	}
+	return fiber.Map{"amount": course.Price, "currency": course.Currency}
+}
+
+func orderPayload(order models.Order, courseTitle string) fiber.Map {
+	return fiber.Map{
+		"id":           order.ID,
+		"course_id":    order.CourseID,
+		"course_title": courseTitle,
+		"amount":       order.Amount,
+		"currency":     order.Currency,
+		"status":       order.Status,
+		"checkout_url": order.CheckoutURL,
+		"paid_at":      order.PaidAt,
+		"created_at":   order.CreatedAt,
+	}
+}
+
+// courseTitles возвращает названия курсов по ID, включая удаленные
+func courseTitles(db *gorm.DB, ids []uint) (map[uint]string, error) {
+	titles := map[uint]string{}
+	if len(ids) == 0 {
+		return titles, nil
+	}
+	var courses []models.Course
+	if err := db.Unscoped().Select("id", "title").Where("id IN ?", uniqueIDs(ids)).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	for _, course := range courses {
+		titles[course.ID] = course.Title
+	}
+	return titles, nil
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"project/backend/config"
 	"project/backend/models"
 	"project/backend/utils"
@@ -18,13 +19,21 @@ func NewOverviewController(db *gorm.DB, cfg *config.Config) *OverviewController
 	return &OverviewController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (oc *OverviewController) db(c *fiber.Ctx) *gorm.DB {
+	return oc.DB.WithContext(c.UserContext())
+}
+
 // SearchCourses возвращает курсы по критериям поиска
 func (oc *OverviewController) SearchCourses(c *fiber.Ctx) error {
 	search := c.Query("search")
 	group := c.Query("group")
 	sort := c.Query("sort", "popularity") // popularity, newest, rating
 
-	query := oc.DB.Model(&models.Course{}).Where("access_level = 'public'")
+	// Published courses with public access; access_level lives in course_access_settings, not on courses
+	query := oc.db(c).Model(&models.Course{}).
+		Where("status = ?", models.CoursePublished).
+		Where("id IN (SELECT course_id FROM course_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)")
 
 	// Поиск по названию/описанию
 	if search != "" {
@@ -33,56 +42,84 @@ func (oc *OverviewController) SearchCourses(c *fiber.Ctx) error {
 	}
 
 	// Фильтр по группе
-	if group != "" {
+	if groupID := c.QueryInt("group_id"); groupID > 0 {
+		query = query.Where("recommended_group_id = ?", groupID)
+	} else if group != "" {
 		query = query.Where("recommended_for = ?", group)
 	}
 
+	query = filterCoursesByTags(query, parseTagFilter(c.Query("tags")))
+
 	// Сортировка
 	switch sort {
 	case "newest":
 		query = query.Order("created_at DESC")
 	case "rating":
-		query = query.Order("(SELECT AVG(rating) FROM course_comments WHERE course_id = courses.id) DESC")
+		query = query.Order("(SELECT AVG(rating) FROM course_reviews WHERE course_id = courses.id AND deleted_at IS NULL) DESC NULLS LAST")
 	default: // popularity
 		query = query.Order("(SELECT COUNT(*) FROM user_course_progress WHERE course_id = courses.id) DESC")
 	}
 
 	var courses []models.Course
-	if err := query.Find(&courses).Error; err != nil {
+	if err := query.Preload("AccessSettings").Find(&courses).Error; err != nil {
 		return utils.InternalServerError(c, "Failed to fetch courses")
 	}
 
+	courseIDs := make([]uint, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.ID)
+	}
+	tags, err := courseTags(oc.db(c), courseIDs)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch tags")
+	}
+
 	// Формируем упрощенный ответ
 	var result []map[string]interface{}
 	for _, course := range courses {
 		// Получаем средний рейтинг
 		var avgRating float64
-		oc.DB.Model(&models.CourseComment{}).
+		oc.db(c).Model(&models.Review{}).
 			Select("COALESCE(AVG(rating), 0)").
 			Where("course_id = ?", course.ID).
 			Scan(&avgRating)
 
 		// Получаем количество участников
 		var enrollments int64
-		oc.DB.Model(&models.UserCourseProgress{}).
+		oc.db(c).Model(&models.UserCourseProgress{}).
 			Where("course_id = ?", course.ID).
 			Count(&enrollments)
 
 		result = append(result, map[string]interface{}{
-			"id":          course.ID,
-			"title":       course.Title,
-			"short_desc":  course.ShortDesc,
-			"difficulty":  course.Difficulty,
-			"recommended": course.RecommendedFor,
-			"university":  course.University,
-			"topic":       course.Topic,
-			"logo_url":    course.LogoURL,
-			"rating":      avgRating,
-			"enrollments": enrollments,
-			"created_at":  course.CreatedAt,
+			"id":                 course.ID,
+			"title":              course.Title,
+			"short_desc":         course.ShortDesc,
+			"difficulty":         course.Difficulty,
+			"recommended":        course.RecommendedFor,
+			"university":         course.University,
+			"topic":              course.Topic,
+			"logo_url":           course.LogoURL,
+			"license":            course.License,
+			"attribution":        course.Attribution,
+			"source_attribution": course.SourceAttribution,
+			"tags":               tags[course.ID],
+			"schedule":           schedulePayload(course.AccessSettings),
+			"price":              pricePayload(course),
+			"rating":             avgRating,
+			"enrollments":        enrollments,
+			"created_at":         course.CreatedAt,
 		})
 	}
 
+	// Counts next to the catalog filters; the plain list stays the default for older clients
+	if wantFacets(c) {
+		facets, err := catalogFacets(oc.db(c), courses, tags)
+		if err != nil {
+			return utils.InternalServerError(c, "Failed to fetch facets")
+		}
+		return utils.Success(c, fiber.StatusOK, fiber.Map{"courses": result, "facets": facets})
+	}
+
 	return utils.Success(c, fiber.StatusOK, result)
 }
 
@@ -93,51 +130,61 @@ func (oc *OverviewController) GetUserOverview(c *fiber.Ctx) error {
 		return utils.Unauthorized(c, "Unauthorized")
 	}
 
+	overview, err := loadOverview(oc.db(c), userID)
+	if err != nil {
+		return utils.InternalServerError(c, err.Error())
+	}
+
+	return utils.Success(c, fiber.StatusOK, overview)
+}
+
+// loadOverview собирает обзор: стрик, активные курсы и рекомендации
+func loadOverview(db *gorm.DB, userID uint) (fiber.Map, error) {
 	// Получаем прогресс пользователя
 	var progress models.UserProgress
-	if err := oc.DB.Where("user_id = ?", userID).First(&progress).Error; err != nil {
-		return utils.InternalServerError(c, "Failed to fetch user progress")
+	if err := db.Where("user_id = ?", userID).First(&progress).Error; err != nil {
+		return nil, errors.New("Failed to fetch user progress")
 	}
 
 	// Получаем активные курсы
 	var activeCourses []models.UserCourseProgress
-	if err := oc.DB.Preload("Course").
+	if err := db.Preload("Course").
 		Where("user_id = ? AND completion_rate < 100", userID).
 		Order("updated_at DESC").
 		Limit(3).
 		Find(&activeCourses).Error; err != nil {
-		return utils.InternalServerError(c, "Failed to fetch active courses")
+		return nil, errors.New("Failed to fetch active courses")
 	}
 
 	// Получаем рекомендации курсов
-	recommendedCourses, err := oc.getRecommendedCourses(userID)
+	recommendedCourses, err := getRecommendedCourses(db, userID)
 	if err != nil {
-		return utils.InternalServerError(c, "Failed to get recommendations")
+		return nil, errors.New("Failed to get recommendations")
 	}
 
-	// Формируем ответ
-	return utils.Success(c, fiber.StatusOK, fiber.Map{
+	return fiber.Map{
 		"streak_days":       progress.StreakDays,
 		"courses_completed": progress.CoursesCompleted,
 		"tests_completed":   progress.TestsCompleted,
 		"active_courses":    activeCourses,
 		"recommendations":   recommendedCourses,
-	})
+	}, nil
 }
 
 // getRecommendedCourses возвращает рекомендованные курсы для пользователя
-func (oc *OverviewController) getRecommendedCourses(userID uint) ([]map[string]interface{}, error) {
+func getRecommendedCourses(db *gorm.DB, userID uint) ([]map[string]interface{}, error) {
 	var recommendations []map[string]interface{}
 
 	// Простая реализация рекомендаций (можно улучшить)
-	// 1. По группе пользователя
+	// 1. По группам пользователя
 	var user models.User
-	if err := oc.DB.First(&user, userID).Error; err != nil {
+	if err := db.First(&user, userID).Error; err != nil {
 		return nil, err
 	}
 
-	query := oc.DB.Model(&models.Course{}).
-		Where("access_level = 'public' AND recommended_for = ?", user.Group).
+	query := db.Model(&models.Course{}).
+		Where("access_level = 'public'").
+		Where("recommended_group_id IN (SELECT group_id FROM study_group_members WHERE user_id = ? AND deleted_at IS NULL)", user.ID).
 		Order("(SELECT COUNT(*) FROM user_course_progress WHERE course_id = courses.id) DESC").
 		Limit(3)
 
@@ -149,7 +196,7 @@ func (oc *OverviewController) getRecommendedCourses(userID uint) ([]map[string]i
 
 	for rows.Next() {
 		var course models.Course
-		oc.DB.ScanRows(rows, &course)
+		db.ScanRows(rows, &course)
 
 		recommendations = append(recommendations, map[string]interface{}{
 			"id":         course.ID,
@@ -161,7 +208,7 @@ func (oc *OverviewController) getRecommendedCourses(userID uint) ([]map[string]i
 
 	// 2. По университету, если не хватило рекомендаций
 	if len(recommendations) < 3 && user.University != "" {
-		query = oc.DB.Model(&models.Course{}).
+		query = db.Model(&models.Course{}).
 			Where("access_level = 'public' AND university = ?", user.University).
 			Order("created_at DESC").
 			Limit(3 - len(recommendations))
@@ -174,7 +221,7 @@ func (oc *OverviewController) getRecommendedCourses(userID uint) ([]map[string]i
 
 		for rows.Next() {
 			var course models.Course
-			oc.DB.ScanRows(rows, &course)
+			db.ScanRows(rows, &course)
 
 			recommendations = append(recommendations, map[string]interface{}{
 				"id":         course.ID,
@@ -194,7 +241,7 @@ func (oc *OverviewController) SearchTests(c *fiber.Ctx) error {
 	group := c.Query("group")
 	sort := c.Query("sort", "popularity") // popularity, newest, rating
 
-	query := oc.DB.Model(&models.Test{}).Where("access_level = 'public'")
+	query := oc.db(c).Model(&models.Test{}).Where("access_level = 'public'")
 
 	// Поиск по названию/описанию
 	if search != "" {
@@ -203,7 +250,9 @@ func (oc *OverviewController) SearchTests(c *fiber.Ctx) error {
 	}
 
 	// Фильтр по группе
-	if group != "" {
+	if groupID := c.QueryInt("group_id"); groupID > 0 {
+		query = query.Where("recommended_group_id = ?", groupID)
+	} else if group != "" {
 		query = query.Where("recommended_for = ?", group)
 	}
 
@@ -227,14 +276,14 @@ func (oc *OverviewController) SearchTests(c *fiber.Ctx) error {
 	for _, test := range tests {
 		// Получаем средний рейтинг
 		var avgRating float64
-		oc.DB.Model(&models.TestComment{}).
+		oc.db(c).Model(&models.TestComment{}).
 			Select("COALESCE(AVG(rating), 0)").
 			Where("test_id = ?", test.ID).
 			Scan(&avgRating)
 
 		// Получаем количество участников
 		var attempts int64
-		oc.DB.Model(&models.UserTestProgress{}).
+		oc.db(c).Model(&models.UserTestProgress{}).
 			Where("test_id = ?", test.ID).
 			Count(&attempts)
 
@@ -247,6 +296,8 @@ func (oc *OverviewController) SearchTests(c *fiber.Ctx) error {
 			"university":  test.University,
 			"topic":       test.Topic,
 			"logo_url":    test.LogoURL,
+			"license":     test.License,
+			"attribution": test.Attribution,
 			"rating":      avgRating,
 			"attempts":    attempts,
 			"created_at":  test.CreatedAt,
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"math"
 	"project/backend/config"
 	"project/backend/models"
 	"project/backend/utils"
@@ -19,6 +20,11 @@ func NewProgressController(db *gorm.DB, cfg *config.Config) *ProgressController
 	return &ProgressController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (pc *ProgressController) db(c *fiber.Ctx) *gorm.DB {
+	return pc.DB.WithContext(c.UserContext())
+}
+
 func (pc *ProgressController) GetProgress(c *fiber.Ctx) error {
 	userID, err := utils.ExtractUserIDFromToken(c, pc.Cfg)
 	if err != nil {
@@ -27,13 +33,14 @@ func (pc *ProgressController) GetProgress(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get last 4 months progress
-	now := time.Now()
+	// Get last 4 months progress, months and days follow the user's time zone
+	loc := utils.UserLocation(pc.db(c), userID)
+	now := time.Now().In(loc)
 	months := make([]models.MonthlyProgress, 4)
 
 	for i := 0; i < 4; i++ {
 		month := now.AddDate(0, -i, 0)
-		startOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+		startOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
 		endOfMonth := startOfMonth.AddDate(0, 1, -1)
 
 		var streakDays int
@@ -41,23 +48,23 @@ func (pc *ProgressController) GetProgress(c *fiber.Ctx) error {
 		loginFrequency := make(map[string]int)
 
 		// Get streak days for the month
-		pc.DB.Model(&models.UserProgress{}).
+		pc.db(c).Model(&models.UserProgress{}).
 			Where("user_id = ? AND last_active BETWEEN ? AND ?", userID, startOfMonth, endOfMonth).
 			Select("MAX(streak_days)").
 			Scan(&streakDays)
 
 		// Get courses completed in the month
-		pc.DB.Model(&models.UserCourseProgress{}).
+		pc.db(c).Model(&models.UserCourseProgress{}).
 			Where("user_id = ? AND updated_at BETWEEN ? AND ? AND completion_rate = 100", userID, startOfMonth, endOfMonth).
 			Count(&coursesCompleted)
 
 		// Get login frequency (simplified - count logins per day)
 		var logins []models.LoginHistory
-		pc.DB.Where("user_id = ? AND login_time BETWEEN ? AND ?", userID, startOfMonth, endOfMonth).
+		pc.db(c).Where("user_id = ? AND login_time BETWEEN ? AND ? AND success", userID, startOfMonth, endOfMonth).
 			Find(&logins)
 
 		for _, login := range logins {
-			day := login.LoginTime.Format("2006-01-02")
+			day := login.LoginTime.In(loc).Format("2006-01-02")
 			loginFrequency[day]++
 		}
 
@@ -83,22 +90,60 @@ func (pc *ProgressController) GetProgressOverview(c *fiber.Ctx) error {
 		})
 	}
 
+	return c.JSON(loadProgressOverview(pc.db(c), userID))
+}
+
+// loadProgressOverview считает итоговые показатели прогресса пользователя
+func loadProgressOverview(db *gorm.DB, userID uint) models.ProgressOverview {
 	var userProgress models.UserProgress
-	pc.DB.Where("user_id = ?", userID).First(&userProgress)
+	db.Where("user_id = ?", userID).First(&userProgress)
 
 	var totalCoursesCompleted int64
-	pc.DB.Model(&models.UserCourseProgress{}).
+	db.Model(&models.UserCourseProgress{}).
 		Where("user_id = ? AND completion_rate = 100", userID).
 		Count(&totalCoursesCompleted)
 
 	var totalTestsCompleted int64
-	pc.DB.Model(&models.UserTestProgress{}).
+	db.Model(&models.UserTestProgress{}).
 		Where("user_id = ? AND attempts_used > 0", userID).
 		Count(&totalTestsCompleted)
 
-	return c.JSON(models.ProgressOverview{
+	return models.ProgressOverview{
 		TotalStreakDays:       userProgress.StreakDays,
 		TotalCoursesCompleted: int(totalCoursesCompleted),
 		TotalTestsCompleted:   int(totalTestsCompleted),
-	})
+		Trends:                loadProgressTrends(db, userID, time.Now()),
+	}
+}
+
+// loadProgressTrends сравнивает срезы текущего и предыдущего месяца; месяц без среза считается нулевым
+func loadProgressTrends(db *gorm.DB, userID uint, now time.Time) models.ProgressTrends {
+	start := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	trends := models.ProgressTrends{
+		Period:         start.Format(models.SnapshotPeriodLayout),
+		PreviousPeriod: start.AddDate(0, -1, 0).Format(models.SnapshotPeriodLayout),
+	}
+
+	var snapshots []models.UserProgressSnapshot
+	db.Where("user_id = ? AND period IN ?", userID, []string{trends.Period, trends.PreviousPeriod}).Find(&snapshots)
+
+	var current, previous models.UserProgressSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.Period == trends.Period {
+			current = snapshot
+		} else {
+			previous = snapshot
+		}
+	}
+
+	trends.Hours = trendDelta(current.Hours, previous.Hours)
+	trends.Lessons = trendDelta(float64(current.Lessons), float64(previous.Lessons))
+	trends.AvgScore = trendDelta(current.AvgScore, previous.AvgScore)
+	trends.BestStreak = trendDelta(float64(current.BestStreak), float64(previous.BestStreak))
+	return trends
+}
+
+func trendDelta(current, previous float64) models.TrendDelta {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return models.TrendDelta{Current: round(current), Previous: round(previous), Delta: round(current - previous)}
 }
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Network delay allowed on top of a question's time limit
+const questionTimeGrace = 2 * time.Second
+
+var (
+	errQuestionNotServed   = errors.New("Open the question before answering it")
+	errQuestionAnswered    = errors.New("The question has already been answered")
+	errQuestionTimeUp      = errors.New("Time is up for this question")
+	errQuestionNoAttempts  = errors.New("No attempts left")
+	errQuestionNotFound    = errors.New("Question not found")
+	errQuestionInvalidTest = errors.New("Invalid test ID")
+)
+
+type QuestionTimingController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewQuestionTimingController(db *gorm.DB, cfg *config.Config) *QuestionTimingController {
+	return &QuestionTimingController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (qc *QuestionTimingController) db(c *fiber.Ctx) *gorm.DB {
+	return qc.DB.WithContext(c.UserContext())
+}
+
+// ServeQuestion выдает вопрос текущей попытки и запоминает момент выдачи. Вопросы с ограничением
+// времени приходят только отсюда; повторный запрос возвращает тот же вопрос, а время не сбрасывается.
+func (qc *QuestionTimingController) ServeQuestion(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, qc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	question, attempt, err := attemptQuestion(c, qc.db(c), userID)
+	if err != nil {
+		return attemptError(c, err)
+	}
+	// Serving a question starts its clock, which waits for the course rules like the attempt itself
+	conduct, err := pendingTestConduct(qc.db(c), question.TestID, userID)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not serve question")
+	}
+	if conduct != nil {
+		return utils.Error(c, fiber.StatusForbidden, errConductRequired, fiber.Map{
+			"conduct_required": true,
+			"conduct_version":  conduct.Version,
+			"course_id":        conduct.CourseID,
+		})
+	}
+
+	serve := models.QuestionServe{UserID: userID, TestID: question.TestID, QuestionID: question.ID, Attempt: attempt, ServedAt: time.Now()}
+	if err := qc.db(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&serve).Error; err != nil {
+		return utils.InternalServerError(c, "Could not serve question")
+	}
+	// Already served in this attempt: the clock keeps running from the first time
+	if err := qc.db(c).Where("user_id = ? AND question_id = ? AND attempt = ?", userID, question.ID, attempt).First(&serve).Error; err != nil {
+		return utils.InternalServerError(c, "Could not serve question")
+	}
+
+	translations, err := questionTranslations(qc.db(c), []models.TestQuestion{*question}, requestLocale(c, qc.db(c), userID))
+	if err != nil {
+		return utils.InternalServerError(c, "Could not serve question")
+	}
+	localized := localizeQuestion(*question, translations)
+	var options []string
+	json.Unmarshal([]byte(localized.Options), &options)
+
+	result := fiber.Map{
+		"id":                 question.ID,
+		"title":              localized.Title,
+		"description":        localized.Description,
+		"question":           localized.Question,
+		"options":            options,
+		"order":              question.SequenceOrder,
+		"attempt":            attempt,
+		"served_at":          serve.ServedAt,
+		"answered":           serve.AnsweredAt != nil,
+		"time_limit_seconds": question.TimeLimitSeconds,
+	}
+	if question.TimeLimitSeconds > 0 {
+		deadline := serve.Deadline(question.TimeLimitSeconds)
+		result["deadline"] = deadline
+		result["seconds_left"] = max(0, int(time.Until(deadline).Seconds()))
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// AnswerQuestion принимает ответ на выданный вопрос. Ответ после истечения времени не засчитывается:
+// вопрос закрывается без ответа и при проверке попытки считается неотвеченным.
+func (qc *QuestionTimingController) AnswerQuestion(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, qc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Answer *int `json:"answer"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Answer == nil {
+		return utils.ValidationError(c, map[string]string{"answer": "Answer is required"})
+	}
+
+	question, attempt, err := attemptQuestion(c, qc.db(c), userID)
+	if err != nil {
+		return attemptError(c, err)
+	}
+
+	now := time.Now()
+	var serve models.QuestionServe
+	err = qc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND question_id = ? AND attempt = ?", userID, question.ID, attempt).
+			First(&serve).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errQuestionNotServed
+			}
+			return err
+		}
+		if serve.AnsweredAt != nil {
+			return errQuestionAnswered
+		}
+
+		serve.AnsweredAt = &now
+		serve.Answer = input.Answer
+		if !answeredInTime(serve, question.TimeLimitSeconds, now) {
+			serve.Answer = nil
+		}
+		return tx.Save(&serve).Error
+	})
+	if err != nil {
+		return attemptError(c, err)
+	}
+
+	if serve.Answer == nil {
+		return utils.Error(c, fiber.StatusConflict, fiber.NewError(fiber.StatusConflict, errQuestionTimeUp.Error()), fiber.Map{
+			"timed_out": true,
+			"deadline":  serve.Deadline(question.TimeLimitSeconds),
+		})
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"question_id": question.ID,
+		"attempt":     attempt,
+		"answered_at": serve.AnsweredAt,
+		"timed_out":   false,
+	})
+}
+
+// attemptQuestion загружает вопрос из :id и :questionId и номер попытки, которая сейчас идет
+func attemptQuestion(c *fiber.Ctx, db *gorm.DB, userID uint) (*models.TestQuestion, int, error) {
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 0, errQuestionInvalidTest
+	}
+
+	var question models.TestQuestion
+	if err := db.Where("id = ? AND test_id = ?", c.Params("questionId"), testID).First(&question).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, errQuestionNotFound
+		}
+		return nil, 0, err
+	}
+
+	var progress models.UserTestProgress
+	if err := db.Where("user_id = ? AND test_id = ?", userID, testID).Limit(1).Find(&progress).Error; err != nil {
+		return nil, 0, err
+	}
+	var accessSettings models.TestAccessSettings
+	db.Where("test_id = ?", testID).Limit(1).Find(&accessSettings)
+	if accessSettings.AttemptsAllowed > 0 && progress.AttemptsUsed >= accessSettings.AttemptsAllowed {
+		return nil, 0, errQuestionNoAttempts
+	}
+
+	// Questions of a paused exam attempt stay closed until it is resumed
+	exam, err := currentExamAttempt(db, uint(testID), userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if exam != nil && exam.Paused(time.Now()) {
+		return nil, 0, errExamPaused
+	}
+	return &question, progress.AttemptsUsed + 1, nil
+}
+
+// attemptError переводит ошибки attemptQuestion и приема ответов в ответ API
+func attemptError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errQuestionInvalidTest):
+		return utils.BadRequest(c, err.Error())
+	case errors.Is(err, errQuestionNotFound):
+		return utils.NotFound(c, err.Error())
+	case errors.Is(err, errQuestionNoAttempts), errors.Is(err, errExamTimeUp), errors.Is(err, errExamInvalidated):
+		return utils.Forbidden(c, err.Error())
+	case errors.Is(err, errQuestionNotServed), errors.Is(err, errQuestionAnswered), errors.Is(err, errExamPaused), errors.Is(err, errAttemptFinished):
+		return utils.Error(c, fiber.StatusConflict, fiber.NewError(fiber.StatusConflict, err.Error()))
+	}
+	return utils.InternalServerError(c, "Could not query database")
+}
+
+// answeredInTime сообщает, уложился ли ответ в ограничение времени вопроса
+func answeredInTime(serve models.QuestionServe, limitSeconds int, at time.Time) bool {
+	return limitSeconds <= 0 || !at.After(serve.Deadline(limitSeconds).Add(questionTimeGrace))
+}
+
+// applyQuestionTimeLimits оставляет в ответах попытки только те ответы на вопросы с ограничением времени,
+// что даны вовремя после выдачи вопроса: принятый через AnswerQuestion ответ заменяет присланный при
+// сдаче, а без него присланный засчитывается, только если время вопроса еще не вышло.
+// Возвращает вопросы, время на которые истекло.
+func applyQuestionTimeLimits(db *gorm.DB, questions []models.TestQuestion, userID uint, attempt int, answers map[uint]int, now time.Time) ([]uint, error) {
+	limits := map[uint]int{}
+	for _, question := range questions {
+		if question.TimeLimitSeconds > 0 {
+			limits[question.ID] = question.TimeLimitSeconds
+		}
+	}
+	timedOut := []uint{}
+	if len(limits) == 0 {
+		return timedOut, nil
+	}
+
+	var serves []models.QuestionServe
+	if err := db.Where("user_id = ? AND attempt = ? AND question_id IN ?", userID, attempt, slices.Collect(maps.Keys(limits))).Find(&serves).Error; err != nil {
+		return nil, err
+	}
+	served := make(map[uint]models.QuestionServe, len(serves))
+	for _, serve := range serves {
+		served[serve.QuestionID] = serve
+	}
+
+	for _, question := range questions {
+		limit, timed := limits[question.ID]
+		if !timed {
+			continue
+		}
+		submitted, hasAnswer := answers[question.ID]
+		delete(answers, question.ID)
+
+		serve, ok := served[question.ID]
+		switch {
+		case !ok:
+			// Answering a timed question without opening it through the serve endpoint doesn't count
+			if hasAnswer {
+				timedOut = append(timedOut, question.ID)
+			}
+		case serve.AnsweredAt != nil && serve.Answer != nil:
+			answers[question.ID] = *serve.Answer
+		case serve.AnsweredAt != nil:
+			timedOut = append(timedOut, question.ID)
+		case hasAnswer && answeredInTime(serve, limit, now):
+			answers[question.ID] = submitted
+		default:
+			timedOut = append(timedOut, question.ID)
+		}
+	}
+	return timedOut, nil
+}
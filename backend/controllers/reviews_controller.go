@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReviewsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewReviewsController(db *gorm.DB, cfg *config.Config) *ReviewsController {
+	return &ReviewsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (rc *ReviewsController) db(c *fiber.Ctx) *gorm.DB {
+	return rc.DB.WithContext(c.UserContext())
+}
+
+type reviewItem struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	UserImage string    `json:"user_image"`
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Reviews outlive their authors, like comments
+const reviewColumns = `r.id, r.user_id, r.rating, r.text, r.created_at, r.updated_at,
+	COALESCE(users.username, ?) AS user_name, COALESCE(users.avatar_url, '') AS user_image`
+
+// findReview возвращает отзыв пользователя о курсе в виде ответа API или nil, если отзыва нет
+func findReview(db *gorm.DB, courseID, userID uint) (*reviewItem, error) {
+	var items []reviewItem
+	if err := db.Table("course_reviews AS r").
+		Joins("LEFT JOIN users ON users.id = r.user_id AND users.deleted_at IS NULL").
+		Where("r.course_id = ? AND r.user_id = ? AND r.deleted_at IS NULL", courseID, userID).
+		Select(reviewColumns, deletedUserName).Limit(1).Scan(&items).Error; err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// upsertReview сохраняет отзыв пользователя о курсе и сообщает, был ли он создан заново
+func upsertReview(tx *gorm.DB, review models.Review) (bool, error) {
+	var existing int64
+	if err := tx.Model(&models.Review{}).
+		Where("course_id = ? AND user_id = ?", review.CourseID, review.UserID).Count(&existing).Error; err != nil {
+		return false, err
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "course_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "text", "updated_at", "deleted_at"}),
+	}).Create(&review).Error
+	return existing == 0, err
+}
+
+// GetCourseReviews возвращает отзывы о курсе, новые первыми; ?rating=1..5 оставляет отзывы с одной оценкой
+func (rc *ReviewsController) GetCourseReviews(c *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := rc.db(c).Table("course_reviews AS r").
+		Joins("LEFT JOIN users ON users.id = r.user_id AND users.deleted_at IS NULL").
+		Where("r.course_id = ? AND r.deleted_at IS NULL", courseID)
+	if rating := c.QueryInt("rating"); rating != 0 {
+		if rating < 1 || rating > 5 {
+			return utils.BadRequest(c, "Rating must be between 1 and 5")
+		}
+		query = query.Where("r.rating = ?", rating)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch reviews")
+	}
+
+	var items []reviewItem
+	if err := query.Select(reviewColumns, deletedUserName).
+		Order("r.created_at DESC, r.id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Scan(&items).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch reviews")
+	}
+	if items == nil {
+		items = []reviewItem{}
+	}
+	return utils.Paginate(c, items, total, page, pageSize)
+}
+
+// SaveCourseReview создает или изменяет отзыв пользователя о курсе. Оставить отзыв могут только
+// записанные на курс; автор курса узнает о новом отзыве.
+func (rc *ReviewsController) SaveCourseReview(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, rc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var input struct {
+		Rating int    `json:"rating"`
+		Text   string `json:"text"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Rating < 1 || input.Rating > 5 {
+		return utils.ValidationError(c, map[string]string{"rating": "Rating must be between 1 and 5"})
+	}
+	input.Text = strings.TrimSpace(input.Text)
+
+	var course models.Course
+	if err := rc.db(c).Select("id", "title", "author_id").First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+
+	var enrolled int64
+	if err := rc.db(c).Model(&models.UserCourseProgress{}).
+		Where("user_id = ? AND course_id = ?", userID, course.ID).Count(&enrolled).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+	if enrolled == 0 {
+		return utils.Forbidden(c, "Enroll in the course to review it")
+	}
+
+	created := false
+	err = rc.db(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = upsertReview(tx, models.Review{CourseID: course.ID, UserID: userID, Rating: input.Rating, Text: input.Text})
+		if err != nil || !created || course.AuthorID == userID {
+			return err
+		}
+
+		var user models.User
+		tx.Select("id", "username").First(&user, userID)
+		return outbox.EnqueueNotification(tx, course.AuthorID, outbox.NotifyComments,
+			"New review of "+course.Title, user.Username+" rated "+course.Title+" "+strconv.Itoa(input.Rating)+"/5: "+input.Text)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not save review")
+	}
+
+	review, err := findReview(rc.db(c), course.ID, userID)
+	if err != nil || review == nil {
+		return utils.InternalServerError(c, "Could not save review")
+	}
+	if created {
+		return utils.Created(c, review)
+	}
+	return utils.Success(c, fiber.StatusOK, review)
+}
+
+// DeleteCourseReview удаляет отзыв пользователя о курсе
+func (rc *ReviewsController) DeleteCourseReview(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, rc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	// Hard delete so the unique (course, user) review can be left again
+	if err := rc.db(c).Unscoped().Where("course_id = ? AND user_id = ?", courseID, userID).
+		Delete(&models.Review{}).Error; err != nil {
+		return utils.InternalServerError(c, "Could not delete review")
+	}
+	return utils.NoContent(c)
+}
+
+// courseRating — средняя оценка курса, число отзывов и распределение по звездам (ключи 1–5)
+func courseRating(db *gorm.DB, courseID uint) (fiber.Map, error) {
+	var rows []struct {
+		Rating int
+		Count  int64
+	}
+	if err := db.Model(&models.Review{}).Select("rating, COUNT(*) AS count").
+		Where("course_id = ?", courseID).Group("rating").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	histogram := map[string]int64{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}
+	var count, sum int64
+	for _, row := range rows {
+		histogram[strconv.Itoa(row.Rating)] = row.Count
+		count += row.Count
+		sum += int64(row.Rating) * row.Count
+	}
+	var average float64
+	if count > 0 {
+		average = float64(sum) / float64(count)
+	}
+	return fiber.Map{"average": average, "count": count, "histogram": histogram}, nil
+}
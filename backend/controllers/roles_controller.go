@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RolesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewRolesController(db *gorm.DB, cfg *config.Config) *RolesController {
+	return &RolesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (rc *RolesController) db(c *fiber.Ctx) *gorm.DB {
+	return rc.DB.WithContext(c.UserContext())
+}
+
+// GetRoles возвращает список ролей с их разрешениями
+func (rc *RolesController) GetRoles(c *fiber.Ctx) error {
+	var roles []models.Role
+	if err := rc.db(c).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch roles")
+	}
+
+	return utils.Success(c, fiber.StatusOK, roles)
+}
+
+// GetPermissions возвращает список всех разрешений
+func (rc *RolesController) GetPermissions(c *fiber.Ctx) error {
+	var permissions []models.Permission
+	if err := rc.db(c).Order("code").Find(&permissions).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch permissions")
+	}
+
+	return utils.Success(c, fiber.StatusOK, permissions)
+}
+
+// CreateRole создает новую роль с набором разрешений
+func (rc *RolesController) CreateRole(c *fiber.Ctx) error {
+	var input struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	if input.Name == "" {
+		return utils.ValidationError(c, map[string]string{"name": "Name is required"})
+	}
+
+	permissions, err := rc.findPermissions(c, input.Permissions)
+	if err != nil {
+		return utils.BadRequest(c, err.Error())
+	}
+
+	role := models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: permissions,
+	}
+
+	if err := rc.db(c).Create(&role).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create role")
+	}
+
+	return utils.Created(c, role)
+}
+
+// UpdateRolePermissions заменяет набор разрешений роли
+func (rc *RolesController) UpdateRolePermissions(c *fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid role ID")
+	}
+
+	var input struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var role models.Role
+	if err := rc.db(c).First(&role, roleID).Error; err != nil {
+		return utils.NotFound(c, "Role not found")
+	}
+
+	permissions, err := rc.findPermissions(c, input.Permissions)
+	if err != nil {
+		return utils.BadRequest(c, err.Error())
+	}
+
+	if err := rc.db(c).Model(&role).Association("Permissions").Replace(permissions); err != nil {
+		return utils.InternalServerError(c, "Could not update role permissions")
+	}
+
+	role.Permissions = permissions
+	return utils.Success(c, fiber.StatusOK, role)
+}
+
+// GetUserRoles возвращает роли, назначенные пользователю
+func (rc *RolesController) GetUserRoles(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	var userRoles []models.UserRole
+	if err := rc.db(c).Preload("Role.Permissions").Where("user_id = ?", userID).Find(&userRoles).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch user roles")
+	}
+
+	return utils.Success(c, fiber.StatusOK, userRoles)
+}
+
+// AssignRole назначает роль пользователю (опционально в рамках университета)
+func (rc *RolesController) AssignRole(c *fiber.Ctx) error {
+	adminID, err := utils.ExtractUserIDFromToken(c, rc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	var input struct {
+		Role       string `json:"role"`
+		University string `json:"university"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var user models.User
+	if err := rc.db(c).First(&user, userID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	var role models.Role
+	if err := rc.db(c).Where("name = ?", input.Role).First(&role).Error; err != nil {
+		return utils.NotFound(c, "Role not found")
+	}
+
+	userRole := models.UserRole{
+		UserID:     uint(userID),
+		RoleID:     role.ID,
+		University: input.University,
+		AssignedBy: adminID,
+	}
+
+	// The unique index on the assignment scope settles concurrent requests
+	result := rc.db(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&userRole)
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not assign role")
+	}
+	if result.RowsAffected == 0 {
+		return utils.BadRequest(c, "Role already assigned")
+	}
+
+	userRole.Role = role
+	return utils.Created(c, userRole)
+}
+
+// RevokeRole отзывает роль у пользователя в одной области: ?university= для роли в рамках университета,
+// без параметра — глобальную. Назначения той же роли в других областях остаются.
+func (rc *RolesController) RevokeRole(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid role ID")
+	}
+
+	result := rc.db(c).Where("user_id = ? AND role_id = ? AND university = ?", userID, roleID, c.Query("university")).
+		Delete(&models.UserRole{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not revoke role")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "Role assignment not found")
+	}
+
+	return utils.NoContent(c)
+}
+
+func (rc *RolesController) findPermissions(c *fiber.Ctx, codes []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(codes) == 0 {
+		return permissions, nil
+	}
+
+	if err := rc.db(c).Where("code IN ?", codes).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	if len(permissions) != len(codes) {
+		return nil, errors.New("Unknown permission code")
+	}
+
+	return permissions, nil
+}
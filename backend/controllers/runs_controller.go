@@ -0,0 +1,398 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errRunNotFound = errors.New("Run not found")
+
+type RunsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewRunsController(db *gorm.DB, cfg *config.Config) *RunsController {
+	return &RunsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (rc *RunsController) db(c *fiber.Ctx) *gorm.DB {
+	return rc.DB.WithContext(c.UserContext())
+}
+
+// runInput — поля потока; даты в тех же форматах, что и расписание курса, null в PATCH очищает дату
+type runInput struct {
+	Title     utils.Optional[string] `json:"title"`
+	GroupID   utils.Optional[uint]   `json:"group_id"`
+	StartDate utils.Optional[string] `json:"start_date"`
+	EndDate   utils.Optional[string] `json:"end_date"`
+}
+
+// GetRuns возвращает потоки курса с размером состава и числом начавших
+func (rc *RunsController) GetRuns(c *fiber.Ctx) error {
+	course, done, err := rc.course(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	var runs []models.CourseRun
+	if err := rc.db(c).Where("course_id = ?", course.ID).Order("starts_at DESC NULLS LAST, id DESC").Find(&runs).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch runs")
+	}
+
+	result := make([]fiber.Map, 0, len(runs))
+	for _, run := range runs {
+		item := runPayload(run)
+		var members, started int64
+		if run.GroupID != nil {
+			rc.db(c).Model(&models.StudyGroupMember{}).Where("group_id = ?", *run.GroupID).Count(&members)
+		}
+		rc.db(c).Model(&models.UserCourseProgress{}).Where("run_id = ?", run.ID).Count(&started)
+		item["roster_size"], item["started"] = members, started
+		result = append(result, item)
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// CreateRun открывает новый поток курса для учебной группы
+func (rc *RunsController) CreateRun(c *fiber.Ctx) error {
+	course, done, err := rc.course(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+	userID, _ := utils.ExtractUserIDFromToken(c, rc.Cfg)
+
+	var input runInput
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	run := models.CourseRun{CourseID: course.ID, CreatedBy: userID}
+	if errs := rc.applyRun(c, &run, input, true); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+	if err := rc.db(c).Create(&run).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create run")
+	}
+	return utils.Created(c, runPayload(run))
+}
+
+// UpdateRun меняет название, группу или даты потока (PUT и PATCH)
+func (rc *RunsController) UpdateRun(c *fiber.Ctx) error {
+	course, done, err := rc.course(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	var input runInput
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var run models.CourseRun
+	if err := rc.db(c).Where("id = ? AND course_id = ?", c.Params("runId"), course.ID).First(&run).Error; err != nil {
+		return utils.NotFound(c, errRunNotFound.Error())
+	}
+	if errs := rc.applyRun(c, &run, input, utils.IsMergePatch(c)); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+	if err := rc.db(c).Save(&run).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update run")
+	}
+	return utils.Success(c, fiber.StatusOK, runPayload(run))
+}
+
+// DeleteRun удаляет поток; прогресс его студентов остается в курсе без потока
+func (rc *RunsController) DeleteRun(c *fiber.Ctx) error {
+	course, done, err := rc.course(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	err = rc.db(c).Transaction(func(tx *gorm.DB) error {
+		var run models.CourseRun
+		if err := tx.Where("id = ? AND course_id = ?", c.Params("runId"), course.ID).First(&run).Error; err != nil {
+			return errRunNotFound
+		}
+		if err := tx.Model(&models.UserCourseProgress{}).Where("run_id = ?", run.ID).Update("run_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&run).Error
+	})
+	switch {
+	case errors.Is(err, errRunNotFound):
+		return utils.NotFound(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not delete run")
+	}
+	return utils.NoContent(c)
+}
+
+// GetRunRoster возвращает состав потока: участников группы и студентов, начавших курс в этом потоке,
+// с их прогрессом; кто еще не начал, показан с нулевым прогрессом
+func (rc *RunsController) GetRunRoster(c *fiber.Ctx) error {
+	course, done, err := rc.course(c, policy.ActionViewAnalytics)
+	if done {
+		return err
+	}
+	run, err := rc.run(c, course.ID)
+	if err != nil {
+		return utils.NotFound(c, err.Error())
+	}
+
+	var progress []models.UserCourseProgress
+	if err := rc.db(c).Where("course_id = ? AND run_id = ?", course.ID, run.ID).Find(&progress).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch roster")
+	}
+	byUser := make(map[uint]models.UserCourseProgress, len(progress))
+	userIDs := make([]uint, 0, len(progress))
+	for _, p := range progress {
+		byUser[p.UserID] = p
+		userIDs = append(userIDs, p.UserID)
+	}
+	if run.GroupID != nil {
+		var members []uint
+		if err := rc.db(c).Model(&models.StudyGroupMember{}).Where("group_id = ?", *run.GroupID).Pluck("user_id", &members).Error; err != nil {
+			return utils.InternalServerError(c, "Failed to fetch roster")
+		}
+		userIDs = append(userIDs, members...)
+	}
+
+	var users []models.User
+	if err := rc.db(c).Where("id IN ?", uniqueIDs(userIDs)).Find(&users).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch roster")
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	roster := make([]fiber.Map, 0, len(users))
+	for _, user := range users {
+		p, started := byUser[user.ID]
+		roster = append(roster, fiber.Map{
+			"user_id":           user.ID,
+			"username":          user.Username,
+			"started":           started,
+			"lessons_completed": p.LessonsCompleted,
+			"hours_spent":       p.HoursSpent,
+			"completion_rate":   p.CompletionRate,
+			"last_accessed":     p.LastAccessed,
+		})
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"run": runPayload(*run), "roster": roster})
+}
+
+// GetRunAnalytics возвращает статистику курса только по студентам потока
+func (rc *RunsController) GetRunAnalytics(c *fiber.Ctx) error {
+	course, done, err := rc.course(c, policy.ActionViewAnalytics)
+	if done {
+		return err
+	}
+	run, err := rc.run(c, course.ID)
+	if err != nil {
+		return utils.NotFound(c, err.Error())
+	}
+
+	var stats struct {
+		RosterSize        int64   `json:"roster_size"`
+		TotalEnrollments  int64   `json:"total_enrollments"`
+		Completed         int64   `json:"completed"`
+		AvgCompletionRate float64 `json:"avg_completion_rate"`
+		AvgTimeSpent      float64 `json:"avg_time_spent"`
+	}
+	if err := rc.db(c).Model(&models.UserCourseProgress{}).
+		Select("COUNT(*) AS total_enrollments, COUNT(*) FILTER (WHERE completion_rate >= 100) AS completed, "+
+			"COALESCE(AVG(completion_rate), 0) AS avg_completion_rate, COALESCE(AVG(hours_spent), 0) AS avg_time_spent").
+		Where("course_id = ? AND run_id = ?", course.ID, run.ID).
+		Scan(&stats).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to compute analytics")
+	}
+	if run.GroupID != nil {
+		rc.db(c).Model(&models.StudyGroupMember{}).Where("group_id = ?", *run.GroupID).Count(&stats.RosterSize)
+	}
+
+	var lessonCompletion []struct {
+		LessonID    uint   `json:"lesson_id"`
+		LessonTitle string `json:"lesson_title"`
+		Completed   int64  `json:"completed"`
+	}
+	rc.db(c).Raw(`
+		SELECT l.id AS lesson_id, l.title AS lesson_title, COUNT(ucp.id) AS completed
+		FROM lessons l
+		LEFT JOIN user_course_progress ucp ON ucp.lessons_completed >= l.sequence_order
+			AND ucp.course_id = l.course_id AND ucp.run_id = ? AND ucp.deleted_at IS NULL
+		WHERE l.course_id = ? AND l.deleted_at IS NULL
+		GROUP BY l.id, l.title, l.sequence_order
+		ORDER BY l.sequence_order
+	`, run.ID, course.ID).Scan(&lessonCompletion)
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"run":          runPayload(*run),
+		"stats":        stats,
+		"lesson_stats": lessonCompletion,
+	})
+}
+
+// applyRun переносит поля запроса в поток и проверяет их; при создании название обязательно
+func (rc *RunsController) applyRun(c *fiber.Ctx, run *models.CourseRun, input runInput, merge bool) map[string]string {
+	errs := map[string]string{}
+
+	// A run always keeps a title, so null and "" leave it as is
+	input.Title.Apply(&run.Title, false)
+	run.Title = strings.TrimSpace(run.Title)
+	if run.Title == "" {
+		errs["title"] = "Title is required"
+	}
+
+	if input.GroupID.Set {
+		switch {
+		case input.GroupID.Null || input.GroupID.Value == 0:
+			if merge {
+				run.GroupID = nil
+			}
+		default:
+			var group models.StudyGroup
+			if err := rc.db(c).First(&group, input.GroupID.Value).Error; err != nil {
+				errs["group_id"] = errUnknownGroup.Error()
+			} else {
+				run.GroupID = &group.ID
+			}
+		}
+	}
+
+	// Same formats as the course schedule; the end date covers the whole day
+	dates := []struct {
+		field    string
+		value    utils.Optional[string]
+		endOfDay bool
+		dst      **time.Time
+	}{
+		{"start_date", input.StartDate, false, &run.StartsAt},
+		{"end_date", input.EndDate, true, &run.EndsAt},
+	}
+	for _, date := range dates {
+		switch {
+		case date.value.Value != "":
+			parsed, err := utils.ParseScheduleDate(date.value.Value, date.endOfDay)
+			if err != nil {
+				errs[date.field] = err.Error()
+				continue
+			}
+			*date.dst = parsed
+		case merge && date.value.Set:
+			*date.dst = nil
+		}
+	}
+	if len(errs) == 0 && run.StartsAt != nil && run.EndsAt != nil && !run.EndsAt.After(*run.StartsAt) {
+		errs["end_date"] = "The run must end after it starts"
+	}
+	return errs
+}
+
+// course загружает курс из :id и проверяет право на действие с его потоками
+func (rc *RunsController) course(c *fiber.Ctx, action policy.Action) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, rc.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := rc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, action, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to manage runs of this course"))
+	}
+	return &course, false, nil
+}
+
+func (rc *RunsController) run(c *fiber.Ctx, courseID uint) (*models.CourseRun, error) {
+	var run models.CourseRun
+	if err := rc.db(c).Where("id = ? AND course_id = ?", c.Params("runId"), courseID).First(&run).Error; err != nil {
+		return nil, errRunNotFound
+	}
+	return &run, nil
+}
+
+// studentRun находит поток, в котором студент проходит курс: записанный в его прогрессе,
+// а если его нет — поток одной из групп студента, который еще идет или скоро начнется
+// (из нескольких — начинающийся позже всех). nil — курс проходится вне потоков.
+func studentRun(db *gorm.DB, courseID, userID uint) (*models.CourseRun, error) {
+	var progress models.UserCourseProgress
+	err := db.Where("user_id = ? AND course_id = ? AND run_id IS NOT NULL", userID, courseID).First(&progress).Error
+	switch {
+	case err == nil:
+		var run models.CourseRun
+		if err := db.First(&run, *progress.RunID).Error; err == nil {
+			return &run, nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	var runs []models.CourseRun
+	if err := db.Where("course_id = ?", courseID).
+		Where("group_id IN (?)", db.Model(&models.StudyGroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var current *models.CourseRun
+	for i, run := range runs {
+		if run.ScheduleStatus(now) == models.ScheduleClosed {
+			continue
+		}
+		if current == nil || startsLater(run, *current) {
+			current = &runs[i]
+		}
+	}
+	return current, nil
+}
+
+func startsLater(a, b models.CourseRun) bool {
+	switch {
+	case a.StartsAt == nil:
+		return false
+	case b.StartsAt == nil:
+		return true
+	}
+	return a.StartsAt.After(*b.StartsAt)
+}
+
+// runSchedule — расписание, по которому учится студент: даты потока или, вне потоков, курса
+func runSchedule(course models.Course, run *models.CourseRun) models.CourseAccessSettings {
+	if run == nil {
+		return course.AccessSettings
+	}
+	return models.CourseAccessSettings{StartsAt: run.StartsAt, EndsAt: run.EndsAt}
+}
+
+func runPayload(run models.CourseRun) fiber.Map {
+	return fiber.Map{
+		"id":         run.ID,
+		"course_id":  run.CourseID,
+		"title":      run.Title,
+		"group_id":   run.GroupID,
+		"starts_at":  run.StartsAt,
+		"ends_at":    run.EndsAt,
+		"status":     run.ScheduleStatus(time.Now()),
+		"created_at": run.CreatedAt,
+	}
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/sandbox"
+	"project/backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SandboxController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewSandboxController(db *gorm.DB, cfg *config.Config) *SandboxController {
+	return &SandboxController{DB: db, Cfg: cfg}
+}
+
+// GetSandbox рассказывает интеграторам о песочнице: демо-аккаунты, последний и следующий сброс
+func (sc *SandboxController) GetSandbox(c *fiber.Ctx) error {
+	state, err := sandbox.Load(c.UserContext(), sc.DB)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch sandbox state")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"demo_accounts": []fiber.Map{
+			{"username": sandbox.DemoInstructor, "role": "instructor"},
+			{"username": sandbox.DemoStudent, "role": "student"},
+		},
+		"demo_password":  sc.Cfg.SandboxDemoPassword,
+		"api_key_prefix": "pk_sandbox_",
+		"last_reset_at":  state.LastResetAt,
+		"next_reset_at":  sandbox.NextReset(time.Now(), sc.Cfg.SandboxResetHour),
+		// Accounts and API keys survive the reset, everything created with them doesn't
+		"kept_on_reset": []string{"accounts", "roles", "settings", "api_keys"},
+	})
+}
+
+// ResetSandbox сбрасывает песочницу немедленно, не дожидаясь ночного сброса
+func (sc *SandboxController) ResetSandbox(c *fiber.Ctx) error {
+	report, err := sandbox.Reset(c.UserContext(), sc.DB, sc.Cfg.Sandbox, sc.Cfg.SandboxDemoPassword, time.Now())
+	if errors.Is(err, sandbox.ErrNotSandbox) || errors.Is(err, sandbox.ErrUnmarkedDatabase) {
+		return utils.Error(c, fiber.StatusConflict, err)
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not reset the sandbox")
+	}
+
+	return utils.Success(c, fiber.StatusOK, report)
+}
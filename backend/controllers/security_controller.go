@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"fmt"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// loginLookback — за какой срок входы сравниваются при поиске новых устройств и стран
+const loginLookback = 180 * 24 * time.Hour
+
+type SecurityController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewSecurityController(db *gorm.DB, cfg *config.Config) *SecurityController {
+	return &SecurityController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (sc *SecurityController) db(c *fiber.Ctx) *gorm.DB {
+	return sc.DB.WithContext(c.UserContext())
+}
+
+type loginItem struct {
+	ID         uint      `json:"id"`
+	LoginTime  time.Time `json:"login_time"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Device     string    `json:"device"`
+	Country    string    `json:"country"`
+	Success    bool      `json:"success"`
+	NewDevice  bool      `json:"new_device"`
+	NewCountry bool      `json:"new_country"`
+}
+
+// GetLogins возвращает входы и неудачные попытки входа в аккаунт с keyset-пагинацией
+func (sc *SecurityController) GetLogins(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	cursor, limit, err := utils.ParseCursorParams(c)
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cursor")
+	}
+
+	var logins []models.LoginHistory
+	query := sc.db(c).Where("user_id = ?", userID)
+	switch c.Query("filter") {
+	case "failed":
+		query = query.Where("NOT success")
+	case "flagged":
+		query = query.Where("new_device OR new_country")
+	}
+	if err := utils.ApplyCursor(query, "login_time", "id", cursor, limit).Find(&logins).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch login history")
+	}
+
+	logins, next := utils.TrimPage(logins, limit, func(login models.LoginHistory) (time.Time, uint) {
+		return login.LoginTime, login.ID
+	})
+
+	items := make([]loginItem, 0, len(logins))
+	for _, login := range logins {
+		items = append(items, loginItem{
+			ID:         login.ID,
+			LoginTime:  login.LoginTime,
+			IP:         login.IP,
+			UserAgent:  login.UserAgent,
+			Device:     login.Device,
+			Country:    login.Country,
+			Success:    login.Success,
+			NewDevice:  login.NewDevice,
+			NewCountry: login.NewCountry,
+		})
+	}
+
+	return utils.PaginateCursor(c, items, next, limit)
+}
+
+// recordLogin записывает вход или неудачную попытку с IP, устройством и страной.
+// Успешный вход с нового устройства или из новой страны отмечается, и владельцу уходит письмо;
+// самый первый вход аккаунта не отмечается.
+func recordLogin(c *fiber.Ctx, db *gorm.DB, cfg *config.Config, user models.User, success bool) error {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	login := models.LoginHistory{
+		UserID:    user.ID,
+		LoginTime: time.Now(),
+		IP:        c.IP(),
+		UserAgent: userAgent,
+		Device:    utils.DeviceName(userAgent),
+		Country:   utils.RequestCountry(c, cfg.GeoCountryHeader),
+		Success:   success,
+	}
+	if !success {
+		return db.Create(&login).Error
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		recent := func() *gorm.DB {
+			return tx.Model(&models.LoginHistory{}).
+				Where("user_id = ? AND success AND login_time >= ?", user.ID, login.LoginTime.Add(-loginLookback))
+		}
+		var previous, sameDevice, sameCountry int64
+		if err := recent().Count(&previous).Error; err != nil {
+			return err
+		}
+		if previous > 0 {
+			if err := recent().Where("device = ?", login.Device).Count(&sameDevice).Error; err != nil {
+				return err
+			}
+			login.NewDevice = sameDevice == 0
+			if login.Country != "" {
+				if err := recent().Where("country = ?", login.Country).Count(&sameCountry).Error; err != nil {
+					return err
+				}
+				login.NewCountry = sameCountry == 0
+			}
+		}
+
+		if err := tx.Create(&login).Error; err != nil {
+			return err
+		}
+		if !login.NewDevice && !login.NewCountry {
+			return nil
+		}
+
+		location := login.Country
+		if location == "" {
+			location = "unknown location"
+		}
+		return outbox.EnqueueEmail(tx, user.Email, "New sign-in to your Philosofium account",
+			fmt.Sprintf("Your account was signed in from %s (%s, IP %s) at %s UTC. "+
+				"If this wasn't you, change your password right away.",
+				login.Device, location, login.IP, login.LoginTime.UTC().Format("2006-01-02 15:04")))
+	})
+}
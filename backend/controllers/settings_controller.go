@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SettingsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewSettingsController(db *gorm.DB, cfg *config.Config) *SettingsController {
+	return &SettingsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (sc *SettingsController) db(c *fiber.Ctx) *gorm.DB {
+	return sc.DB.WithContext(c.UserContext())
+}
+
+// GetSettings возвращает настройки текущего пользователя
+func (sc *SettingsController) GetSettings(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	settings, err := utils.LoadUserSettings(sc.db(c), userID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch settings")
+	}
+	return utils.Success(c, fiber.StatusOK, settingsPayload(settings))
+}
+
+// UpdateSettings изменяет настройки текущего пользователя (PUT и PATCH)
+func (sc *SettingsController) UpdateSettings(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Locale             utils.Optional[string] `json:"locale"`
+		Timezone           utils.Optional[string] `json:"timezone"`
+		Theme              utils.Optional[string] `json:"theme"`
+		EmailNotifications utils.Optional[bool]   `json:"email_notifications"`
+		EmailComments      utils.Optional[bool]   `json:"email_comments"`
+		EmailCourseUpdates utils.Optional[bool]   `json:"email_course_updates"`
+		EmailNudges        utils.Optional[bool]   `json:"email_nudges"`
+		EmailBadges        utils.Optional[bool]   `json:"email_badges"`
+		HideEmail          utils.Optional[bool]   `json:"hide_email"`
+		HideUniversity     utils.Optional[bool]   `json:"hide_university"`
+		PrivateProfile     utils.Optional[bool]   `json:"private_profile"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	errs := map[string]string{}
+	if input.Locale.Set && !slices.Contains(utils.SupportedLocales, input.Locale.Value) {
+		errs["locale"] = "Unsupported locale"
+	}
+	if input.Timezone.Set {
+		if _, err := time.LoadLocation(input.Timezone.Value); err != nil || input.Timezone.Value == "" {
+			errs["timezone"] = "Unknown time zone"
+		}
+	}
+	if input.Theme.Set && !slices.Contains(utils.SupportedThemes, input.Theme.Value) {
+		errs["theme"] = "Theme must be light, dark or system"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	var settings models.UserSettings
+	err = sc.db(c).Transaction(func(tx *gorm.DB) error {
+		settings = utils.DefaultUserSettings(userID)
+		if err := tx.Where("user_id = ?", userID).FirstOrCreate(&settings).Error; err != nil {
+			return err
+		}
+
+		input.Locale.Apply(&settings.Locale, false)
+		input.Timezone.Apply(&settings.Timezone, false)
+		input.Theme.Apply(&settings.Theme, false)
+		// false is a meaningful value for switches, so they are applied as-is
+		for _, toggle := range []struct {
+			value utils.Optional[bool]
+			dst   *bool
+		}{
+			{input.EmailNotifications, &settings.EmailNotifications},
+			{input.EmailComments, &settings.EmailComments},
+			{input.EmailCourseUpdates, &settings.EmailCourseUpdates},
+			{input.EmailNudges, &settings.EmailNudges},
+			{input.EmailBadges, &settings.EmailBadges},
+			{input.HideEmail, &settings.HideEmail},
+			{input.HideUniversity, &settings.HideUniversity},
+			{input.PrivateProfile, &settings.PrivateProfile},
+		} {
+			if toggle.value.Set && !toggle.value.Null {
+				*toggle.dst = toggle.value.Value
+			}
+		}
+
+		return tx.Save(&settings).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not update settings")
+	}
+
+	return utils.Success(c, fiber.StatusOK, settingsPayload(settings))
+}
+
+func settingsPayload(settings models.UserSettings) fiber.Map {
+	return fiber.Map{
+		"locale":               settings.Locale,
+		"timezone":             settings.Timezone,
+		"theme":                settings.Theme,
+		"email_notifications":  settings.EmailNotifications,
+		"email_comments":       settings.EmailComments,
+		"email_course_updates": settings.EmailCourseUpdates,
+		"email_nudges":         settings.EmailNudges,
+		"email_badges":         settings.EmailBadges,
+		"hide_email":           settings.HideEmail,
+		"hide_university":      settings.HideUniversity,
+		"private_profile":      settings.PrivateProfile,
+	}
+}
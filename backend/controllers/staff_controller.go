@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/policy"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StaffController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewStaffController(db *gorm.DB, cfg *config.Config) *StaffController {
+	return &StaffController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (sc *StaffController) db(c *fiber.Ctx) *gorm.DB {
+	return sc.DB.WithContext(c.UserContext())
+}
+
+type staffMember struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	AddedBy   uint      `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// courseRoleTitles — как роль называется в уведомлении о назначении
+var courseRoleTitles = map[string]string{
+	models.CourseRoleEditor: "a co-author",
+	models.CourseRoleTA:     "a teaching assistant",
+	models.CourseRoleViewer: "a viewer",
+}
+
+// GetCourseStaff возвращает соавторов и сотрудников курса
+func (sc *StaffController) GetCourseStaff(c *fiber.Ctx) error {
+	course, done, err := sc.manageableCourse(c)
+	if done {
+		return err
+	}
+
+	var staff []staffMember
+	if err := sc.db(c).Table("course_staff").
+		Select("course_staff.user_id, users.username, users.email, course_staff.role, course_staff.added_by, course_staff.created_at").
+		Joins("JOIN users ON users.id = course_staff.user_id").
+		Where("course_staff.course_id = ? AND course_staff.deleted_at IS NULL", course.ID).
+		Order("course_staff.created_at").
+		Scan(&staff).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch course staff")
+	}
+
+	return utils.Success(c, fiber.StatusOK, staff)
+}
+
+// AddCourseStaff назначает пользователю роль в курсе (editor, ta, viewer); повторное назначение обновляет роль
+func (sc *StaffController) AddCourseStaff(c *fiber.Ctx) error {
+	course, done, err := sc.manageableCourse(c)
+	if done {
+		return err
+	}
+	actorID, _ := utils.ExtractUserIDFromToken(c, sc.Cfg)
+
+	var input struct {
+		UserID uint   `json:"user_id"`
+		Role   string `json:"role"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Role == "" {
+		input.Role = models.CourseRoleTA
+	}
+	if !slices.Contains(models.CourseRoles, input.Role) {
+		return utils.ValidationError(c, map[string]string{"role": "Role must be editor, ta or viewer"})
+	}
+	if input.UserID == course.AuthorID {
+		return utils.ValidationError(c, map[string]string{"user_id": "The author already manages this course"})
+	}
+
+	var user models.User
+	if err := sc.db(c).Select("id", "username").First(&user, input.UserID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	member := models.CourseStaff{CourseID: course.ID, UserID: user.ID, Role: input.Role, AddedBy: actorID}
+	err = sc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "course_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role", "added_by", "updated_at"}),
+		}).Create(&member).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueNotification(tx, user.ID, outbox.NotifyCourseUpdates,
+			"You are now staff of "+course.Title, "You were added as "+courseRoleTitles[input.Role]+" to "+course.Title)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not add course staff")
+	}
+
+	return utils.Created(c, staffMember{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      member.Role,
+		AddedBy:   member.AddedBy,
+		CreatedAt: member.CreatedAt,
+	})
+}
+
+// RemoveCourseStaff снимает с пользователя роль в курсе
+func (sc *StaffController) RemoveCourseStaff(c *fiber.Ctx) error {
+	course, done, err := sc.manageableCourse(c)
+	if done {
+		return err
+	}
+
+	userID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	// Hard delete: the (course, user) pair is unique, so a soft-deleted row would block re-adding
+	result := sc.db(c).Unscoped().Where("course_id = ? AND user_id = ?", course.ID, userID).Delete(&models.CourseStaff{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not remove course staff")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "Staff member not found")
+	}
+
+	return utils.NoContent(c)
+}
+
+// manageableCourse загружает курс из :id и проверяет, что пользователь может управлять его сотрудниками
+func (sc *StaffController) manageableCourse(c *fiber.Ctx) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, sc.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := sc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, policy.ActionManageStaff, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to manage staff of this course"))
+	}
+	return &course, false, nil
+}
@@ -0,0 +1,443 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/backend/cache"
+	"project/backend/config"
+	"project/backend/metrics"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/readonly"
+	"project/backend/storage"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	statusCacheTTL     = 15 * time.Second
+	statusCheckTimeout = 2 * time.Second
+	// statusProbeKey — ключ, который никогда не создается: ответ «не найдено» означает, что хранилище доступно
+	statusProbeKey = "status/probe"
+	// Возраст самого старого неотправленного письма, после которого очередь считается отстающей
+	emailQueueDegradedAfter = 15 * time.Minute
+	emailQueueOutageAfter   = time.Hour
+	// resolvedIncidentsShown — сколько еще показываются решенные инциденты
+	resolvedIncidentsShown = 24 * time.Hour
+)
+
+// Состояния компонентов и платформы, от лучшего к худшему
+const (
+	statusOperational = "operational"
+	statusMaintenance = "maintenance"
+	statusDegraded    = "degraded"
+	statusOutage      = "outage"
+)
+
+var (
+	statusRank = map[string]int{statusOperational: 0, statusMaintenance: 1, statusDegraded: 2, statusOutage: 3}
+
+	statusComponents = []string{models.ComponentAPI, models.ComponentDatabase, models.ComponentStorage, models.ComponentEmailQueue}
+	incidentSeverity = []string{models.IncidentMinor, models.IncidentMajor, models.IncidentMaintenance}
+
+	errIncidentNotFound = errors.New("Incident not found")
+)
+
+// processStartedAt — момент запуска экземпляра, от которого считается его аптайм
+var processStartedAt = time.Now()
+
+type StatusController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewStatusController(db *gorm.DB, cfg *config.Config) *StatusController {
+	return &StatusController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (sc *StatusController) db(c *fiber.Ctx) *gorm.DB {
+	return sc.DB.WithContext(c.UserContext())
+}
+
+type componentStatus struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type incidentItem struct {
+	ID         uint       `json:"id"`
+	Title      string     `json:"title"`
+	Message    string     `json:"message"`
+	Severity   string     `json:"severity"`
+	Components []string   `json:"components"`
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+// GetStatus возвращает состояние API, базы данных, хранилища и очереди писем вместе с заметками об инцидентах.
+// Эндпоинт публичный, поэтому ответ кешируется на несколько секунд.
+func (sc *StatusController) GetStatus(c *fiber.Ctx) error {
+	if cached, ok := cache.Status.Get("status"); ok {
+		return utils.Success(c, fiber.StatusOK, cached)
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), statusCheckTimeout)
+	defer cancel()
+	db := sc.DB.WithContext(ctx)
+
+	components := map[string]*componentStatus{
+		models.ComponentAPI:        {Name: models.ComponentAPI, Status: statusOperational},
+		models.ComponentDatabase:   checkDatabase(ctx, db),
+		models.ComponentStorage:    checkStorage(ctx),
+		models.ComponentEmailQueue: checkEmailQueue(db),
+	}
+
+	// With the database down the status is still reported, just without incident notes
+	var incidents []models.StatusIncident
+	if err := db.Where("resolved_at IS NULL OR resolved_at >= ?", time.Now().Add(-resolvedIncidentsShown)).
+		Order("started_at DESC").Find(&incidents).Error; err != nil && components[models.ComponentDatabase].Status == statusOperational {
+		return utils.InternalServerError(c, "Failed to fetch incidents")
+	}
+
+	// An open incident is the admins' word on a component even when its checks pass
+	items := make([]incidentItem, 0, len(incidents))
+	for _, incident := range incidents {
+		item := incidentPayload(incident)
+		items = append(items, item)
+		if incident.ResolvedAt != nil {
+			continue
+		}
+		for _, name := range item.Components {
+			if component, ok := components[name]; ok {
+				component.Status = worseStatus(component.Status, incidentStatus(incident.Severity))
+			}
+		}
+	}
+
+	overall := statusOperational
+	list := make([]componentStatus, 0, len(statusComponents))
+	for _, name := range statusComponents {
+		overall = worseStatus(overall, components[name].Status)
+		list = append(list, *components[name])
+	}
+
+	payload := fiber.Map{
+		"status":     overall,
+		"components": list,
+		"incidents":  items,
+		"read_only":  readonly.Current(),
+		"uptime":     uptime(db),
+		"checked_at": time.Now().UTC(),
+	}
+	cache.Status.Set("status", payload, statusCacheTTL, "status")
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+func checkDatabase(ctx context.Context, db *gorm.DB) *componentStatus {
+	component := &componentStatus{Name: models.ComponentDatabase, Status: statusOperational}
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		component.Status, component.Message = statusOutage, "Database is unreachable"
+	}
+	return component
+}
+
+func checkStorage(ctx context.Context) *componentStatus {
+	component := &componentStatus{Name: models.ComponentStorage, Status: statusOperational}
+	if _, err := storage.Default.Get(ctx, statusProbeKey); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		component.Status, component.Message = statusOutage, "File storage is unreachable"
+	}
+	return component
+}
+
+// checkEmailQueue судит об очереди писем по возрасту самого старого неотправленного письма
+func checkEmailQueue(db *gorm.DB) *componentStatus {
+	component := &componentStatus{Name: models.ComponentEmailQueue, Status: statusOperational}
+
+	var oldest models.OutboxMessage
+	err := db.Where("kind = ? AND status = ?", outbox.KindEmail, outbox.StatusPending).
+		Order("created_at").Limit(1).Find(&oldest).Error
+	switch {
+	case err != nil:
+		component.Status, component.Message = statusOutage, "Email queue can't be checked"
+	case oldest.ID == 0:
+	case time.Since(oldest.CreatedAt) > emailQueueOutageAfter:
+		component.Status, component.Message = statusOutage, "Emails are not being delivered"
+	case time.Since(oldest.CreatedAt) > emailQueueDegradedAfter:
+		component.Status, component.Message = statusDegraded, "Emails are delayed"
+	}
+	return component
+}
+
+// uptime сообщает время работы экземпляра и долю запросов без ошибок сервера за сутки
+func uptime(db *gorm.DB) fiber.Map {
+	result := fiber.Map{
+		"since":   processStartedAt.UTC(),
+		"seconds": int64(time.Since(processStartedAt).Seconds()),
+	}
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	requests, err := metrics.Sum(db, metrics.Requests, from, to)
+	if err != nil || requests == 0 {
+		return result
+	}
+	errs, err := metrics.Sum(db, metrics.ServerErrors, from, to)
+	if err != nil {
+		return result
+	}
+	result["availability_24h"] = 100 * float64(requests-errs) / float64(requests)
+	return result
+}
+
+func incidentStatus(severity string) string {
+	switch severity {
+	case models.IncidentMajor:
+		return statusOutage
+	case models.IncidentMaintenance:
+		return statusMaintenance
+	default:
+		return statusDegraded
+	}
+}
+
+func worseStatus(a, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+func incidentPayload(incident models.StatusIncident) incidentItem {
+	components := []string{}
+	if incident.Components != "" {
+		components = strings.Split(incident.Components, ",")
+	}
+	return incidentItem{
+		ID:         incident.ID,
+		Title:      incident.Title,
+		Message:    incident.Message,
+		Severity:   incident.Severity,
+		Components: components,
+		StartedAt:  incident.StartedAt,
+		ResolvedAt: incident.ResolvedAt,
+	}
+}
+
+// validateIncident проверяет важность и список компонентов инцидента
+func validateIncident(severity string, components []string) map[string]string {
+	errs := map[string]string{}
+	if !slices.Contains(incidentSeverity, severity) {
+		errs["severity"] = "Severity must be minor, major or maintenance"
+	}
+	for _, component := range components {
+		if !slices.Contains(statusComponents, component) {
+			errs["components"] = fmt.Sprintf("Unknown component %q", component)
+			break
+		}
+	}
+	return errs
+}
+
+// GetIncidents возвращает все заметки об инцидентах, начиная с последних
+func (sc *StatusController) GetIncidents(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := sc.db(c).Model(&models.StatusIncident{})
+	if c.Query("active") == "true" {
+		query = query.Where("resolved_at IS NULL")
+	}
+	var total int64
+	query.Count(&total)
+
+	var incidents []models.StatusIncident
+	if err := query.Order("started_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&incidents).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch incidents")
+	}
+
+	items := make([]incidentItem, 0, len(incidents))
+	for _, incident := range incidents {
+		items = append(items, incidentPayload(incident))
+	}
+	return utils.Paginate(c, items, total, page, pageSize)
+}
+
+// CreateIncident публикует заметку об инциденте или плановых работах
+func (sc *StatusController) CreateIncident(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Title      string     `json:"title"`
+		Message    string     `json:"message"`
+		Severity   string     `json:"severity"`
+		Components []string   `json:"components"`
+		StartedAt  *time.Time `json:"started_at"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	input.Title = strings.TrimSpace(input.Title)
+	if input.Severity == "" {
+		input.Severity = models.IncidentMinor
+	}
+	errs := validateIncident(input.Severity, input.Components)
+	if input.Title == "" {
+		errs["title"] = "Title is required"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	incident := models.StatusIncident{
+		Title:      input.Title,
+		Message:    input.Message,
+		Severity:   input.Severity,
+		Components: strings.Join(input.Components, ","),
+		StartedAt:  time.Now(),
+		CreatedBy:  userID,
+	}
+	if input.StartedAt != nil {
+		incident.StartedAt = *input.StartedAt
+	}
+	if err := sc.db(c).Create(&incident).Error; err != nil {
+		return utils.InternalServerError(c, "Could not create incident")
+	}
+
+	cache.Status.Invalidate("status")
+	return utils.Created(c, incidentPayload(incident))
+}
+
+// UpdateIncident изменяет заметку об инциденте (PUT и PATCH); resolved: true закрывает инцидент, false — открывает снова
+func (sc *StatusController) UpdateIncident(c *fiber.Ctx) error {
+	incidentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid incident ID")
+	}
+
+	var input struct {
+		Title      utils.Optional[string]   `json:"title"`
+		Message    utils.Optional[string]   `json:"message"`
+		Severity   utils.Optional[string]   `json:"severity"`
+		Components utils.Optional[[]string] `json:"components"`
+		Resolved   utils.Optional[bool]     `json:"resolved"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var incident models.StatusIncident
+	if err := sc.db(c).First(&incident, incidentID).Error; err != nil {
+		return utils.NotFound(c, errIncidentNotFound.Error())
+	}
+
+	merge := utils.IsMergePatch(c)
+	input.Title.Apply(&incident.Title, false)
+	input.Message.Apply(&incident.Message, merge)
+	input.Severity.Apply(&incident.Severity, false)
+	var components []string
+	if input.Components.Apply(&components, true) {
+		incident.Components = strings.Join(components, ",")
+	}
+	if input.Resolved.Set && !input.Resolved.Null {
+		switch {
+		case input.Resolved.Value && incident.ResolvedAt == nil:
+			now := time.Now()
+			incident.ResolvedAt = &now
+		case !input.Resolved.Value:
+			incident.ResolvedAt = nil
+		}
+	}
+
+	errs := validateIncident(incident.Severity, components)
+	if strings.TrimSpace(incident.Title) == "" {
+		errs["title"] = "Title is required"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
+	if err := sc.db(c).Save(&incident).Error; err != nil {
+		return utils.InternalServerError(c, "Could not update incident")
+	}
+
+	cache.Status.Invalidate("status")
+	return utils.Success(c, fiber.StatusOK, incidentPayload(incident))
+}
+
+// DeleteIncident удаляет заметку, опубликованную по ошибке
+func (sc *StatusController) DeleteIncident(c *fiber.Ctx) error {
+	incidentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid incident ID")
+	}
+
+	result := sc.db(c).Delete(&models.StatusIncident{}, incidentID)
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not delete incident")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, errIncidentNotFound.Error())
+	}
+
+	cache.Status.Invalidate("status")
+	return utils.NoContent(c)
+}
+
+// GetReadOnly возвращает состояние режима только для чтения
+func (sc *StatusController) GetReadOnly(c *fiber.Ctx) error {
+	return utils.Success(c, fiber.StatusOK, readonly.Current())
+}
+
+// UpdateReadOnly включает или выключает режим только для чтения для всей платформы.
+// Остальные экземпляры подхватывают изменение в течение нескольких секунд.
+func (sc *StatusController) UpdateReadOnly(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, sc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Enabled *bool  `json:"enabled"`
+		Message string `json:"message"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if input.Enabled == nil {
+		return utils.ValidationError(c, map[string]string{"enabled": "Enabled is required"})
+	}
+
+	state, err := readonly.Set(c.UserContext(), sc.DB, *input.Enabled, strings.TrimSpace(input.Message), userID)
+	switch {
+	case errors.Is(err, readonly.ErrForced):
+		return utils.Error(c, fiber.StatusConflict, err, state)
+	case err != nil:
+		return utils.InternalServerError(c, "Could not update read-only mode")
+	}
+
+	cache.Status.Invalidate("status")
+	return utils.Success(c, fiber.StatusOK, state)
+}
@@ -0,0 +1,398 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	errTagNotFound = errors.New("Tag not found")
+	errUnknownTags = errors.New("Some tags don't exist")
+
+	// Same alphabet as slugify produces: lowercase letters and digits in words joined by single dashes
+	tagSlugPattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}]+(-[\p{Ll}\p{Lo}\p{N}]+)*$`)
+)
+
+// maxTagSlugLength — предел длины slug в символах; slug попадает в адреса каталога
+const maxTagSlugLength = 64
+
+type TagsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewTagsController(db *gorm.DB, cfg *config.Config) *TagsController {
+	return &TagsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (tc *TagsController) db(c *fiber.Ctx) *gorm.DB {
+	return tc.DB.WithContext(c.UserContext())
+}
+
+// tagSlugProblem объясняет, чем slug не подходит; пустая строка — slug в порядке
+func tagSlugProblem(slug string) string {
+	switch {
+	case utf8.RuneCountInString(slug) > maxTagSlugLength:
+		return fmt.Sprintf("Slug must be at most %d characters", maxTagSlugLength)
+	case !tagSlugPattern.MatchString(slug):
+		return "Slug may only contain lowercase letters and digits separated by single dashes"
+	}
+	return ""
+}
+
+// TagRef — метка в карточках курсов и тестов
+type TagRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// GetTags возвращает все метки с количеством опубликованных публичных курсов и публичных тестов
+func (tc *TagsController) GetTags(c *fiber.Ctx) error {
+	var tags []models.Tag
+	if err := tc.db(c).Order("name").Find(&tags).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch tags")
+	}
+
+	type count struct {
+		TagID uint
+		Total int64
+	}
+	var courseCounts, testCounts []count
+	tc.db(c).Model(&models.CourseTag{}).
+		Select("tag_id, COUNT(*) AS total").
+		Where("course_id IN (SELECT id FROM courses WHERE status = ? AND deleted_at IS NULL)", models.CoursePublished).
+		Where("course_id IN (SELECT course_id FROM course_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Group("tag_id").Scan(&courseCounts)
+	tc.db(c).Model(&models.TestTag{}).
+		Select("tag_id, COUNT(*) AS total").
+		Where("test_id IN (SELECT test_id FROM test_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Group("tag_id").Scan(&testCounts)
+
+	courses := make(map[uint]int64, len(courseCounts))
+	for _, cnt := range courseCounts {
+		courses[cnt.TagID] = cnt.Total
+	}
+	tests := make(map[uint]int64, len(testCounts))
+	for _, cnt := range testCounts {
+		tests[cnt.TagID] = cnt.Total
+	}
+
+	result := make([]fiber.Map, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, fiber.Map{
+			"id":           tag.ID,
+			"name":         tag.Name,
+			"slug":         tag.Slug,
+			"course_count": courses[tag.ID],
+			"test_count":   tests[tag.ID],
+		})
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// CreateTag добавляет метку; slug по умолчанию строится из названия
+func (tc *TagsController) CreateTag(c *fiber.Ctx) error {
+	var input struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return utils.ValidationError(c, map[string]string{"name": "Name is required"})
+	}
+	if input.Slug == "" {
+		input.Slug = slugify(input.Name)
+	}
+	if problem := tagSlugProblem(input.Slug); problem != "" {
+		return utils.ValidationError(c, map[string]string{"slug": problem})
+	}
+
+	tag := models.Tag{Name: input.Name, Slug: input.Slug}
+	if err := tc.db(c).Create(&tag).Error; err != nil {
+		return utils.Error(c, fiber.StatusConflict, errors.New("Could not create tag, slug may already be taken"))
+	}
+	return utils.Created(c, tagRef(tag))
+}
+
+// UpdateTag переименовывает метку или меняет ее slug (PUT и PATCH)
+func (tc *TagsController) UpdateTag(c *fiber.Ctx) error {
+	var input struct {
+		Name utils.Optional[string] `json:"name"`
+		Slug utils.Optional[string] `json:"slug"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var tag models.Tag
+	if err := tc.db(c).First(&tag, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, errTagNotFound.Error())
+	}
+
+	// A tag always keeps a name and a slug, so null and "" leave them as is
+	input.Name.Apply(&tag.Name, false)
+	input.Slug.Apply(&tag.Slug, false)
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" {
+		return utils.ValidationError(c, map[string]string{"name": "Name is required"})
+	}
+	if problem := tagSlugProblem(tag.Slug); problem != "" {
+		return utils.ValidationError(c, map[string]string{"slug": problem})
+	}
+	if err := tc.db(c).Save(&tag).Error; err != nil {
+		return utils.Error(c, fiber.StatusConflict, errors.New("Could not update tag, slug may already be taken"))
+	}
+	return utils.Success(c, fiber.StatusOK, tagRef(tag))
+}
+
+// DeleteTag удаляет метку и снимает ее со всех курсов и тестов
+func (tc *TagsController) DeleteTag(c *fiber.Ctx) error {
+	err := tc.db(c).Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.First(&tag, c.Params("id")).Error; err != nil {
+			return errTagNotFound
+		}
+		if err := tx.Unscoped().Where("tag_id = ?", tag.ID).Delete(&models.CourseTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("tag_id = ?", tag.ID).Delete(&models.TestTag{}).Error; err != nil {
+			return err
+		}
+		// Hard delete so the slug can be reused
+		return tx.Unscoped().Delete(&tag).Error
+	})
+	switch {
+	case errors.Is(err, errTagNotFound):
+		return utils.NotFound(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not delete tag")
+	}
+	return utils.NoContent(c)
+}
+
+// SetCourseTags заменяет метки курса списком tag_ids
+func (tc *TagsController) SetCourseTags(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, tc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var course models.Course
+	if err := tc.db(c).First(&course, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit this course"))
+	}
+
+	return tc.setTags(c, func(tx *gorm.DB, tagIDs []uint) error {
+		if err := tx.Unscoped().Where("course_id = ?", course.ID).Delete(&models.CourseTag{}).Error; err != nil {
+			return err
+		}
+		for _, tagID := range tagIDs {
+			if err := tx.Create(&models.CourseTag{CourseID: course.ID, TagID: tagID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetTestTags заменяет метки теста списком tag_ids
+func (tc *TagsController) SetTestTags(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, tc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var test models.Test
+	if err := tc.db(c).First(&test, c.Params("id")).Error; err != nil {
+		return utils.NotFound(c, "Test not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit this test"))
+	}
+
+	return tc.setTags(c, func(tx *gorm.DB, tagIDs []uint) error {
+		if err := tx.Unscoped().Where("test_id = ?", test.ID).Delete(&models.TestTag{}).Error; err != nil {
+			return err
+		}
+		for _, tagID := range tagIDs {
+			if err := tx.Create(&models.TestTag{TestID: test.ID, TagID: tagID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// setTags разбирает tag_ids, проверяет, что все метки существуют, и в транзакции вызывает replace
+func (tc *TagsController) setTags(c *fiber.Ctx, replace func(tx *gorm.DB, tagIDs []uint) error) error {
+	var input struct {
+		TagIDs []uint `json:"tag_ids"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	ids := uniqueIDs(input.TagIDs)
+	var tags []models.Tag
+	err := tc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ?", ids).Order("name").Find(&tags).Error; err != nil {
+			return err
+		}
+		if len(tags) != len(ids) {
+			return errUnknownTags
+		}
+		return replace(tx, ids)
+	})
+	switch {
+	case errors.Is(err, errUnknownTags):
+		return utils.ValidationError(c, map[string]string{"tag_ids": err.Error()})
+	case err != nil:
+		return utils.InternalServerError(c, "Could not save tags")
+	}
+
+	refs := make([]TagRef, 0, len(tags))
+	for _, tag := range tags {
+		refs = append(refs, tagRef(tag))
+	}
+	return utils.Success(c, fiber.StatusOK, refs)
+}
+
+func tagRef(tag models.Tag) TagRef {
+	return TagRef{ID: tag.ID, Name: tag.Name, Slug: tag.Slug}
+}
+
+// parseTagFilter разбирает ?tags=ethics,exam-prep: курс должен иметь все перечисленные метки
+func parseTagFilter(value string) []string {
+	var slugs []string
+	for _, slug := range strings.Split(value, ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+// filterCoursesByTags оставляет курсы, у которых есть каждая из меток slugs
+func filterCoursesByTags(query *gorm.DB, slugs []string) *gorm.DB {
+	for _, slug := range slugs {
+		query = query.Where("courses.id IN (SELECT course_tags.course_id FROM course_tags JOIN tags ON tags.id = course_tags.tag_id "+
+			"WHERE tags.slug = ? AND course_tags.deleted_at IS NULL AND tags.deleted_at IS NULL)", slug)
+	}
+	return query
+}
+
+// courseTags загружает метки курсов одним запросом
+func courseTags(db *gorm.DB, courseIDs []uint) (map[uint][]TagRef, error) {
+	var rows []struct {
+		CourseID uint
+		TagRef
+	}
+	if len(courseIDs) > 0 {
+		if err := db.Model(&models.CourseTag{}).
+			Select("course_tags.course_id, tags.id, tags.name, tags.slug").
+			Joins("JOIN tags ON tags.id = course_tags.tag_id AND tags.deleted_at IS NULL").
+			Where("course_tags.course_id IN ?", courseIDs).
+			Order("tags.name").Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	byCourse := make(map[uint][]TagRef, len(courseIDs))
+	for _, id := range courseIDs {
+		byCourse[id] = []TagRef{}
+	}
+	for _, row := range rows {
+		byCourse[row.CourseID] = append(byCourse[row.CourseID], row.TagRef)
+	}
+	return byCourse, nil
+}
+
+// testTags возвращает метки теста
+func testTags(db *gorm.DB, testID uint) ([]TagRef, error) {
+	refs := []TagRef{}
+	err := db.Model(&models.TestTag{}).
+		Select("tags.id, tags.name, tags.slug").
+		Joins("JOIN tags ON tags.id = test_tags.tag_id AND tags.deleted_at IS NULL").
+		Where("test_tags.test_id = ?", testID).
+		Order("tags.name").Scan(&refs).Error
+	return refs, err
+}
+
+// catalogFacets считает найденные курсы по меткам и темам, чтобы каталог мог показать количество рядом с фильтрами
+func catalogFacets(db *gorm.DB, courses []models.Course, tags map[uint][]TagRef) (fiber.Map, error) {
+	type facet struct {
+		ID    uint   `json:"id"`
+		Name  string `json:"name"`
+		Slug  string `json:"slug"`
+		Count int64  `json:"count"`
+	}
+
+	tagFacets := map[uint]*facet{}
+	topicCounts := map[uint]int64{}
+	for _, course := range courses {
+		for _, tag := range tags[course.ID] {
+			if tagFacets[tag.ID] == nil {
+				tagFacets[tag.ID] = &facet{ID: tag.ID, Name: tag.Name, Slug: tag.Slug}
+			}
+			tagFacets[tag.ID].Count++
+		}
+		if course.TopicID != nil {
+			topicCounts[*course.TopicID]++
+		}
+	}
+
+	topicIDs := make([]uint, 0, len(topicCounts))
+	for id := range topicCounts {
+		topicIDs = append(topicIDs, id)
+	}
+	var topics []models.Topic
+	if len(topicIDs) > 0 {
+		if err := db.Where("id IN ?", topicIDs).Find(&topics).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	byTag := make([]facet, 0, len(tagFacets))
+	for _, f := range tagFacets {
+		byTag = append(byTag, *f)
+	}
+	byTopic := make([]facet, 0, len(topics))
+	for _, topic := range topics {
+		byTopic = append(byTopic, facet{ID: topic.ID, Name: topic.Name, Slug: topic.Slug, Count: topicCounts[topic.ID]})
+	}
+	for _, list := range [][]facet{byTag, byTopic} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			return list[i].Name < list[j].Name
+		})
+	}
+
+	return fiber.Map{"tags": byTag, "topics": byTopic, "total": len(courses)}, nil
+}
+
+// wantFacets сообщает, запросил ли клиент счетчики фильтров (?facets=true)
+func wantFacets(c *fiber.Ctx) bool {
+	facets, _ := strconv.ParseBool(c.Query("facets"))
+	return facets
+}
@@ -1,11 +1,21 @@
 package controllers
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"project/backend/cache"
+	"project/backend/completion"
 	"project/backend/config"
+	"project/backend/events"
+	"project/backend/grading"
 	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/policy"
+	"project/backend/rankings"
 	"project/backend/utils"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -23,6 +33,11 @@ func NewTestsController(db *gorm.DB, cfg *config.Config) *TestsController {
 	return &TestsController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (tc *TestsController) db(c *fiber.Ctx) *gorm.DB {
+	return tc.DB.WithContext(c.UserContext())
+}
+
 func (tc *TestsController) GetUserTests(c *fiber.Ctx) error {
 	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
 	if err != nil {
@@ -32,14 +47,14 @@ func (tc *TestsController) GetUserTests(c *fiber.Ctx) error {
 	}
 
 	var tests []models.Test
-	tc.DB.Joins("JOIN user_test_progress ON user_test_progress.test_id = tests.id").
+	tc.db(c).Joins("JOIN user_test_progress ON user_test_progress.test_id = tests.id").
 		Where("user_test_progress.user_id = ?", userID).
 		Find(&tests)
 
 	var result []fiber.Map
 	for _, test := range tests {
 		var progress models.UserTestProgress
-		tc.DB.Where("user_id = ? AND test_id = ?", userID, test.ID).First(&progress)
+		tc.db(c).Where("user_id = ? AND test_id = ?", userID, test.ID).First(&progress)
 
 		result = append(result, fiber.Map{
 			"id":            test.ID,
@@ -70,12 +85,18 @@ func (tc *TestsController) GetAvailableTests(c *fiber.Ctx) error {
 	topic := c.Query("topic")
 	university := c.Query("university")
 
-	query := tc.DB.Model(&models.Test{}).Where("access_level = 'public'")
+	query := tc.db(c).Model(&models.Test{}).Where("access_level = 'public'")
 
 	if topic != "" {
 		query = query.Where("topic LIKE ?", "%"+topic+"%")
 	}
 
+	// Browsing by taxonomy node includes the whole subtree
+	if topicID := c.Query("topic_id"); topicID != "" {
+		query = query.Where("topic_id IN (?)", tc.db(c).Model(&models.Topic{}).Select("id").
+			Where("path LIKE (SELECT path FROM topics WHERE id = ?) || '%'", topicID))
+	}
+
 	if university != "" {
 		query = query.Where("university LIKE ?", "%"+university+"%")
 	}
@@ -86,7 +107,7 @@ func (tc *TestsController) GetAvailableTests(c *fiber.Ctx) error {
 	var result []fiber.Map
 	for _, test := range tests {
 		var progress models.UserTestProgress
-		tc.DB.Where("user_id = ? AND test_id = ?", userID, test.ID).First(&progress)
+		tc.db(c).Where("user_id = ? AND test_id = ?", userID, test.ID).First(&progress)
 
 		result = append(result, fiber.Map{
 			"id":          test.ID,
@@ -97,8 +118,11 @@ func (tc *TestsController) GetAvailableTests(c *fiber.Ctx) error {
 			"difficulty":  test.Difficulty,
 			"university":  test.University,
 			"topic":       test.Topic,
+			"topic_id":    test.TopicID,
 			"author":      test.AuthorID,
 			"logo_url":    test.LogoURL,
+			"license":     test.License,
+			"attribution": test.Attribution,
 		})
 	}
 
@@ -121,7 +145,7 @@ func (tc *TestsController) GetTestDetails(c *fiber.Ctx) error {
 	}
 
 	var test models.Test
-	if err := tc.DB.Preload("Questions").Preload("Comments").First(&test, testID).Error; err != nil {
+	if err := tc.db(c).Preload("Questions").Preload("Comments").Preload("AccessSettings").First(&test, testID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Test not found",
@@ -133,41 +157,157 @@ func (tc *TestsController) GetTestDetails(c *fiber.Ctx) error {
 	}
 
 	var progress models.UserTestProgress
-	tc.DB.Where("user_id = ? AND test_id = ?", userID, testID).First(&progress)
+	tc.db(c).Where("user_id = ? AND test_id = ?", userID, testID).First(&progress)
+
+	// Author, co-authors and editors see the test as written; everyone else opening it is taking it
+	editor := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)) == nil
+
+	// Opening the test counts as taking it until answers are submitted
+	var exam fiber.Map
+	paused := false
+	if !editor {
+		// Opening the test starts the attempt, so the course rules are acknowledged first
+		conduct, err := pendingTestConduct(tc.db(c), test.ID, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not query database",
+			})
+		}
+		if conduct != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":            errConductRequired.Error(),
+				"conduct_required": true,
+				"conduct_version":  conduct.Version,
+				"course_id":        conduct.CourseID,
+			})
+		}
+
+		// Live updates are best effort, opening the test doesn't depend on them
+		topic := events.Topic("test", test.ID)
+		if takingNow, err := events.TestTakers.Touch(tc.db(c), topic, userID); err == nil {
+			events.Publish(tc.db(c), topic, "presence", fiber.Map{"taking_now": takingNow})
+		}
+
+		// The first opening before any answers goes to the activity feed
+		if progress.ID == 0 {
+			if err := recordActivityOnce(tc.db(c), models.UserActivity{
+				UserID:      userID,
+				ActionType:  models.ActivityTestStart,
+				TargetType:  "tests",
+				TargetID:    test.ID,
+				TargetTitle: test.Title,
+			}); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Could not query database",
+				})
+			}
+		}
+
+		// During an exam session the clock starts on the first opening
+		session, attempt, err := startExamAttempt(tc.db(c), test.ID, userID, c.IP())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not query database",
+			})
+		}
+		if session != nil {
+			now := time.Now()
+			exam = fiber.Map{
+				"session_id":     session.ID,
+				"started_at":     attempt.StartedAt,
+				"deadline":       attempt.Deadline(*session),
+				"status":         examAttemptStatus(*attempt, *session, now),
+				"paused_seconds": int(attempt.PauseTime().Seconds()),
+			}
+			if paused = attempt.Paused(now); paused {
+				exam["resume_by"] = attempt.PauseEndsAt
+			}
+		}
+	}
+
+	// Approved translations replace the original text for students who read another language
+	locale := requestLocale(c, tc.db(c), userID)
+	translations, err := questionTranslations(tc.db(c), test.Questions, locale)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	locales, err := testLocales(tc.db(c), test.Questions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
 
 	// Parse question options from JSON string to array
 	var questions []map[string]interface{}
 	for _, q := range test.Questions {
+		q = localizeQuestion(q, translations)
 		var options []string
 		json.Unmarshal([]byte(q.Options), &options)
 
-		questions = append(questions, map[string]interface{}{
-			"id":          q.ID,
-			"title":       q.Title,
-			"description": q.Description,
-			"question":    q.Question,
-			"options":     options,
-			"order":       q.SequenceOrder,
+		item := map[string]interface{}{
+			"id":                 q.ID,
+			"title":              q.Title,
+			"description":        q.Description,
+			"question":           q.Question,
+			"options":            options,
+			"order":              q.SequenceOrder,
+			"time_limit_seconds": q.TimeLimitSeconds,
+		}
+		// A timed question is only shown when served, so its clock starts when it is read;
+		// a paused exam attempt shows no questions until it is resumed
+		if (q.TimeLimitSeconds > 0 && !editor) || paused {
+			delete(item, "description")
+			delete(item, "question")
+			delete(item, "options")
+		}
+		questions = append(questions, item)
+	}
+
+	breadcrumbs, err := topicBreadcrumbs(tc.db(c), test.TopicID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	tags, err := testTags(tc.db(c), test.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
 		})
 	}
 
 	return c.JSON(fiber.Map{
 		"test": fiber.Map{
-			"id":              test.ID,
-			"title":           test.Title,
-			"description":     test.Description,
-			"short_desc":      test.ShortDesc,
-			"difficulty":      test.Difficulty,
-			"recommended":     test.RecommendedFor,
-			"university":      test.University,
-			"topic":           test.Topic,
-			"logo_url":        test.LogoURL,
-			"author":          test.AuthorID,
-			"questions":       questions,
-			"comments":        test.Comments,
-			"completion_rate": test.CompletionRate,
+			"id":                   test.ID,
+			"title":                test.Title,
+			"description":          test.Description,
+			"short_desc":           test.ShortDesc,
+			"difficulty":           test.Difficulty,
+			"recommended":          test.RecommendedFor,
+			"recommended_group_id": test.RecommendedGroupID,
+			"university":           test.University,
+			"university_id":        test.UniversityID,
+			"topic":                test.Topic,
+			"topic_id":             test.TopicID,
+			"breadcrumbs":          breadcrumbs,
+			"tags":                 tags,
+			"logo_url":             test.LogoURL,
+			"license":              test.License,
+			"attribution":          test.Attribution,
+			"author":               test.AuthorID,
+			"questions":            questions,
+			"locale":               variantLocale(translations, locale),
+			"locales":              locales,
+			"bookmarked":           isBookmarked(tc.db(c), userID, bookmarkTests, test.ID),
+			"comments":             test.Comments,
+			"completion_rate":      test.CompletionRate,
 		},
 		"progress": progress,
+		"exam":     exam,
 	})
 }
 
@@ -193,6 +333,7 @@ func (tc *TestsController) UpdateTestProgress(c *fiber.Ctx) error {
 
 	type ProgressInput struct {
 		Answers []AnswerInput `json:"answers"`
+		Locale  string        `json:"locale"` // variant the answers were given in, the user's language by default
 	}
 
 	var input ProgressInput
@@ -203,7 +344,7 @@ func (tc *TestsController) UpdateTestProgress(c *fiber.Ctx) error {
 	}
 
 	var test models.Test
-	if err := tc.DB.Preload("Questions").First(&test, testID).Error; err != nil {
+	if err := tc.db(c).Preload("Questions").First(&test, testID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Test not found",
@@ -215,7 +356,7 @@ func (tc *TestsController) UpdateTestProgress(c *fiber.Ctx) error {
 	}
 
 	var progress models.UserTestProgress
-	if err := tc.DB.Where("user_id = ? AND test_id = ?", userID, testID).First(&progress).Error; err != nil {
+	if err := tc.db(c).Where("user_id = ? AND test_id = ?", userID, testID).First(&progress).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			progress = models.UserTestProgress{
 				UserID:            userID,
@@ -232,40 +373,190 @@ func (tc *TestsController) UpdateTestProgress(c *fiber.Ctx) error {
 		}
 	}
 
+	// A test graded in a course the user takes waits for the course rules as well
+	conduct, err := pendingTestConduct(tc.db(c), test.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	if conduct != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":            errConductRequired.Error(),
+			"conduct_required": true,
+			"conduct_version":  conduct.Version,
+			"course_id":        conduct.CourseID,
+		})
+	}
+
 	// Check attempts
 	var accessSettings models.TestAccessSettings
-	tc.DB.Where("test_id = ?", testID).First(&accessSettings)
+	tc.db(c).Where("test_id = ?", testID).First(&accessSettings)
 	if progress.AttemptsUsed >= accessSettings.AttemptsAllowed && accessSettings.AttemptsAllowed > 0 {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": "No attempts left",
 		})
 	}
 
+	// Answers of a proctored attempt are only accepted before its deadline
+	attempt, err := checkExamSubmission(tc.db(c), test.ID, userID, c.IP())
+	if errors.Is(err, errExamTimeUp) || errors.Is(err, errExamInvalidated) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, errExamPaused) || errors.Is(err, errAttemptFinished) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	answers := make(map[uint]int, len(input.Answers))
+	for _, answer := range input.Answers {
+		answers[answer.QuestionID] = answer.Answer
+	}
+	// Answers saved during the attempt count unless the submit overrides them
+	if err := mergeSavedAnswers(tc.db(c), test.ID, userID, progress.AttemptsUsed+1, answers); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	// Timed questions count only when answered within their limit after being served
+	timedOut, err := applyQuestionTimeLimits(tc.db(c), test.Questions, userID, progress.AttemptsUsed+1, answers, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
 	// Process answers
 	correctAnswers := 0
-	for _, answer := range input.Answers {
+	for questionID, answer := range answers {
 		var question models.TestQuestion
-		if err := tc.DB.Where("id = ? AND test_id = ?", answer.QuestionID, testID).First(&question).Error; err != nil {
+		if err := tc.db(c).Where("id = ? AND test_id = ?", questionID, testID).First(&question).Error; err != nil {
 			continue
 		}
 
-		if answer.Answer == question.CorrectAnswer {
+		if answer == question.CorrectAnswer {
 			correctAnswers++
 		}
 	}
 
-	progress.QuestionsAnswered = len(input.Answers)
+	progress.QuestionsAnswered = len(answers)
 	progress.CorrectAnswers = correctAnswers
 	progress.Score = float64(correctAnswers) / float64(len(test.Questions)) * 100
 	progress.AttemptsUsed++
 	progress.LastAttempt = time.Now().Format(time.RFC3339)
 
-	if err := tc.DB.Save(&progress).Error; err != nil {
+	// The attempt is counted for the variant the student actually saw
+	locale := input.Locale
+	if !slices.Contains(utils.SupportedLocales, locale) {
+		locale = requestLocale(c, tc.db(c), userID)
+	}
+	translations, err := questionTranslations(tc.db(c), test.Questions, locale)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	progress.Locale = variantLocale(translations, locale)
+
+	var completedCourses []models.Course
+	err = tc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&progress).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ? AND test_id = ? AND attempt = ?", userID, test.ID, progress.AttemptsUsed).
+			Delete(&models.AttemptAnswer{}).Error; err != nil {
+			return err
+		}
+		if attempt != nil {
+			// What was submitted and from where feeds the session's integrity report
+			submitted, _ := json.Marshal(answers)
+			if err := tx.Model(attempt).Updates(map[string]interface{}{
+				"submitted_at": time.Now(),
+				"score":        progress.Score,
+				"answers":      string(submitted),
+				"submit_ip":    c.IP(),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := rankings.RecordScore(tx, test.ID, userID); err != nil {
+			return err
+		}
+		if err := grading.RefreshTest(tx, test.ID, userID); err != nil {
+			return err
+		}
+		// Passing a final test may complete the courses it closes
+		if completedCourses, err = completion.RefreshTest(tx, test.ID, userID); err != nil {
+			return err
+		}
+		for _, course := range completedCourses {
+			if err := recordActivity(tx, models.UserActivity{
+				UserID:      userID,
+				ActionType:  models.ActivityCourseComplete,
+				TargetType:  "courses",
+				TargetID:    course.ID,
+				TargetTitle: course.Title,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := recordActivity(tx, models.UserActivity{
+			UserID:      userID,
+			ActionType:  models.ActivityTestComplete,
+			TargetType:  "tests",
+			TargetID:    test.ID,
+			TargetTitle: test.Title,
+			Score:       progress.Score,
+		}); err != nil {
+			return err
+		}
+
+		statement := outbox.NewStatement(tc.Cfg, userID, "", "completed", "tests", test.ID, test.Title)
+		statement.Result = &outbox.StatementResult{
+			Score:      &outbox.StatementScore{Scaled: progress.Score / 100, Raw: progress.Score, Min: 0, Max: 100},
+			Completion: true,
+		}
+		if err := outbox.EnqueueStatement(tx, tc.Cfg, statement); err != nil {
+			return err
+		}
+
+		return outbox.EnqueueWebhook(tx, tc.Cfg, "test.completed", fiber.Map{
+			"user_id":       userID,
+			"test_id":       test.ID,
+			"score":         progress.Score,
+			"attempts_used": progress.AttemptsUsed,
+		})
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not save progress",
 		})
 	}
 
+	// Drop cached dashboards that include this progress
+	cache.Analytics.Invalidate(cache.Tag("test", testID), "platform")
+
+	// Completed courses issue their certificates; a failure isn't fatal, the certificate is issued again on request
+	certificates := []models.CourseCertificate{}
+	for _, course := range completedCourses {
+		cache.Analytics.Invalidate(cache.Tag("course", course.ID))
+		if certificate, err := issueCertificate(tc.db(c), course.ID, userID); err == nil {
+			certificates = append(certificates, certificate)
+		}
+	}
+
+	position, _ := rankings.ForUser(tc.db(c), test.ID, userID)
+	tc.publishAttempt(c, &test, userID, &progress, position)
+
 	return c.JSON(fiber.Map{
 		"message": "Progress updated",
 		"progress": fiber.Map{
@@ -275,9 +566,35 @@ func (tc *TestsController) UpdateTestProgress(c *fiber.Ctx) error {
 			"attempts_used":      progress.AttemptsUsed,
 			"attempts_left":      accessSettings.AttemptsAllowed - progress.AttemptsUsed,
 		},
+		"timed_out":    timedOut,
+		"ranking":      rankingPayload(position),
+		"certificates": certificates,
 	})
 }
 
+// testAnalyticsRow — прогресс одного студента по тесту
+type testAnalyticsRow struct {
+	ID                uint      `json:"-"`
+	CreatedAt         time.Time `json:"-"`
+	UserID            uint      `json:"user_id"`
+	Username          string    `json:"username"`
+	QuestionsAnswered int       `json:"questions_answered"`
+	CorrectAnswers    int       `json:"correct_answers"`
+	Score             float64   `json:"score"`
+	AttemptsUsed      int       `json:"attempts_used"`
+	Locale            string    `json:"locale"`
+}
+
+// testAnalyticsQuery выбирает прогресс студентов по тесту
+func (tc *TestsController) testAnalyticsQuery(c *fiber.Ctx, testID int) *gorm.DB {
+	return tc.db(c).Table("user_test_progress AS p").
+		Select("p.id, p.created_at, p.user_id, users.username, p.questions_answered, p.correct_answers, p.score, p.attempts_used, p.locale").
+		Joins("JOIN users ON users.id = p.user_id").
+		Where("p.test_id = ? AND p.deleted_at IS NULL", testID)
+}
+
+// GetTestAnalytics отдает прогресс всех студентов по тесту в прежнем виде {"analytics": [...]}. Маршрут устарел,
+// постраничный вариант — GetTestAnalyticsPage.
 func (tc *TestsController) GetTestAnalytics(c *fiber.Ctx) error {
 	testID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -286,33 +603,41 @@ func (tc *TestsController) GetTestAnalytics(c *fiber.Ctx) error {
 		})
 	}
 
-	var progresses []models.UserTestProgress
-	if err := tc.DB.Where("test_id = ?", testID).Find(&progresses).Error; err != nil {
+	var rows []testAnalyticsRow
+	if err := tc.testAnalyticsQuery(c, testID).Order("p.id").Scan(&rows).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not query database",
 		})
 	}
 
-	var users []fiber.Map
-	for _, progress := range progresses {
-		var user models.User
-		if err := tc.DB.First(&user, progress.UserID).Error; err != nil {
-			continue
-		}
+	return c.JSON(fiber.Map{
+		"analytics": rows,
+	})
+}
 
-		users = append(users, fiber.Map{
-			"user_id":            user.ID,
-			"username":           user.Username,
-			"questions_answered": progress.QuestionsAnswered,
-			"correct_answers":    progress.CorrectAnswers,
-			"score":              progress.Score,
-			"attempts_used":      progress.AttemptsUsed,
-		})
+// GetTestAnalyticsPage отдает прогресс студентов по тесту с keyset-пагинацией
+func (tc *TestsController) GetTestAnalyticsPage(c *fiber.Ctx) error {
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
 	}
 
-	return c.JSON(fiber.Map{
-		"analytics": users,
+	cursor, limit, err := utils.ParseCursorParams(c)
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cursor")
+	}
+
+	var rows []testAnalyticsRow
+	if err := utils.ApplyCursor(tc.testAnalyticsQuery(c, testID), "p.created_at", "p.id", cursor, limit).
+		Scan(&rows).Error; err != nil {
+		return utils.InternalServerError(c, "Could not query database")
+	}
+
+	rows, next := utils.TrimPage(rows, limit, func(r testAnalyticsRow) (time.Time, uint) {
+		return r.CreatedAt, r.ID
 	})
+
+	return utils.PaginateCursor(c, rows, next, limit)
 }
 
 func (tc *TestsController) CreateTest(c *fiber.Ctx) error {
@@ -330,10 +655,53 @@ func (tc *TestsController) CreateTest(c *fiber.Ctx) error {
 		})
 	}
 
+	// The topic comes from the taxonomy, free text in "topic" is ignored
+	var topicInput struct {
+		TopicID            utils.Optional[uint] `json:"topic_id"`
+		UniversityID       utils.Optional[uint] `json:"university_id"`
+		RecommendedGroupID utils.Optional[uint] `json:"recommended_group_id"`
+	}
+	c.BodyParser(&topicInput)
+	test.TopicID, test.Topic = nil, ""
+	if err := assignTopic(tc.db(c), topicInput.TopicID, &test.TopicID, &test.Topic); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// The university must come from the directory, either by id or by its exact name
+	universityName := utils.Optional[string]{Set: test.University != "", Value: test.University}
+	test.UniversityID, test.University = nil, ""
+	if _, err := applyUniversity(tc.db(c), topicInput.UniversityID, universityName, false, &test.UniversityID, &test.University); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Same for the recommended group
+	groupName := utils.Optional[string]{Set: test.RecommendedFor != "", Value: test.RecommendedFor}
+	test.RecommendedGroupID, test.RecommendedFor = nil, ""
+	if _, err := applyGroup(tc.db(c), topicInput.RecommendedGroupID, groupName, false, test.UniversityID, &test.RecommendedGroupID, &test.RecommendedFor); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// University-scoped authors may only create tests for their university
+	if !utils.HasScopedPermission(tc.db(c), userID, models.PermTestsCreate, test.University) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You don't have permission to create tests for this university",
+		})
+	}
+
+	if errs := applyLicense(utils.Optional[string]{}, utils.Optional[string]{}, false, "", &test.License, &test.Attribution); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
+	}
+
 	test.AuthorID = userID
 	test.CompletionRate = 0
 
-	if err := tc.DB.Create(&test).Error; err != nil {
+	if err := tc.db(c).Create(&test).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create test",
 		})
@@ -347,7 +715,7 @@ func (tc *TestsController) CreateTest(c *fiber.Ctx) error {
 		AttemptsAllowed: 1,
 	}
 
-	if err := tc.DB.Create(&accessSettings).Error; err != nil {
+	if err := tc.db(c).Create(&accessSettings).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create access settings",
 		})
@@ -360,7 +728,7 @@ func (tc *TestsController) CreateTest(c *fiber.Ctx) error {
 }
 
 func (tc *TestsController) UpdateTestDescription(c *fiber.Ctx) error {
-	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	_, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Unauthorized",
@@ -375,14 +743,18 @@ func (tc *TestsController) UpdateTestDescription(c *fiber.Ctx) error {
 	}
 
 	var input struct {
-		Title          string `json:"title"`
-		ShortDesc      string `json:"short_desc"`
-		Description    string `json:"description"`
-		Difficulty     string `json:"difficulty"`
-		RecommendedFor string `json:"recommended_for"`
-		University     string `json:"university"`
-		Topic          string `json:"topic"`
-		LogoURL        string `json:"logo_url"`
+		Title              utils.Optional[string] `json:"title"`
+		ShortDesc          utils.Optional[string] `json:"short_desc"`
+		Description        utils.Optional[string] `json:"description"`
+		Difficulty         utils.Optional[string] `json:"difficulty"`
+		RecommendedFor     utils.Optional[string] `json:"recommended_for"`
+		RecommendedGroupID utils.Optional[uint]   `json:"recommended_group_id"`
+		University         utils.Optional[string] `json:"university"`
+		UniversityID       utils.Optional[uint]   `json:"university_id"`
+		TopicID            utils.Optional[uint]   `json:"topic_id"`
+		LogoURL            utils.Optional[string] `json:"logo_url"`
+		License            utils.Optional[string] `json:"license"`
+		Attribution        utils.Optional[string] `json:"attribution"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -392,7 +764,7 @@ func (tc *TestsController) UpdateTestDescription(c *fiber.Ctx) error {
 	}
 
 	var test models.Test
-	if err := tc.DB.First(&test, testID).Error; err != nil {
+	if err := tc.db(c).First(&test, testID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Test not found",
@@ -403,40 +775,40 @@ func (tc *TestsController) UpdateTestDescription(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if test.AuthorID != userID && !strings.Contains(test.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university test editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to edit this test",
 		})
 	}
 
-	// Update fields
-	if input.Title != "" {
-		test.Title = input.Title
-	}
-	if input.ShortDesc != "" {
-		test.ShortDesc = input.ShortDesc
-	}
-	if input.Description != "" {
-		test.Description = input.Description
-	}
-	if input.Difficulty != "" {
-		test.Difficulty = input.Difficulty
-	}
-	if input.RecommendedFor != "" {
-		test.RecommendedFor = input.RecommendedFor
+	// Update fields (PATCH follows JSON Merge Patch, PUT ignores empty values)
+	merge := utils.IsMergePatch(c)
+	input.Title.Apply(&test.Title, merge)
+	input.ShortDesc.Apply(&test.ShortDesc, merge)
+	input.Description.Apply(&test.Description, merge)
+	input.Difficulty.Apply(&test.Difficulty, merge)
+	if _, err := applyUniversity(tc.db(c), input.UniversityID, input.University, merge, &test.UniversityID, &test.University); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	if input.University != "" {
-		test.University = input.University
+	if _, err := applyGroup(tc.db(c), input.RecommendedGroupID, input.RecommendedFor, merge, test.UniversityID, &test.RecommendedGroupID, &test.RecommendedFor); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	if input.Topic != "" {
-		test.Topic = input.Topic
+	if err := assignTopic(tc.db(c), input.TopicID, &test.TopicID, &test.Topic); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	if input.LogoURL != "" {
-		test.LogoURL = input.LogoURL
+	input.LogoURL.Apply(&test.LogoURL, merge)
+	if errs := applyLicense(input.License, input.Attribution, merge, "", &test.License, &test.Attribution); len(errs) > 0 {
+		return utils.ValidationError(c, errs)
 	}
 
-	if err := tc.DB.Save(&test).Error; err != nil {
+	if err := tc.db(c).Save(&test).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not update test",
 		})
@@ -449,7 +821,7 @@ func (tc *TestsController) UpdateTestDescription(c *fiber.Ctx) error {
 }
 
 func (tc *TestsController) AddQuestion(c *fiber.Ctx) error {
-	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	_, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Unauthorized",
@@ -469,6 +841,7 @@ func (tc *TestsController) AddQuestion(c *fiber.Ctx) error {
 		Question      string   `json:"question"`
 		Options       []string `json:"options"`
 		CorrectAnswer int      `json:"correct_answer"`
+		TimeLimit     int      `json:"time_limit_seconds"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -478,7 +851,7 @@ func (tc *TestsController) AddQuestion(c *fiber.Ctx) error {
 	}
 
 	var test models.Test
-	if err := tc.DB.First(&test, testID).Error; err != nil {
+	if err := tc.db(c).First(&test, testID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Test not found",
@@ -489,9 +862,9 @@ func (tc *TestsController) AddQuestion(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if test.AuthorID != userID && !strings.Contains(test.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university test editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to add questions to this test",
 		})
 	}
@@ -502,6 +875,11 @@ func (tc *TestsController) AddQuestion(c *fiber.Ctx) error {
 			"error": "Invalid correct answer index",
 		})
 	}
+	if input.TimeLimit < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Time limit can't be negative",
+		})
+	}
 
 	// Convert options to JSON
 	optionsJson, err := json.Marshal(input.Options)
@@ -513,19 +891,20 @@ func (tc *TestsController) AddQuestion(c *fiber.Ctx) error {
 
 	// Get current question count to set sequence order
 	var questionCount int64
-	tc.DB.Model(&models.TestQuestion{}).Where("test_id = ?", testID).Count(&questionCount)
+	tc.db(c).Model(&models.TestQuestion{}).Where("test_id = ?", testID).Count(&questionCount)
 
 	question := models.TestQuestion{
-		TestID:        uint(testID),
-		Title:         input.Title,
-		Description:   input.Description,
-		Question:      input.Question,
-		Options:       string(optionsJson),
-		CorrectAnswer: input.CorrectAnswer,
-		SequenceOrder: int(questionCount) + 1,
+		TestID:           uint(testID),
+		Title:            input.Title,
+		Description:      input.Description,
+		Question:         input.Question,
+		Options:          string(optionsJson),
+		CorrectAnswer:    input.CorrectAnswer,
+		SequenceOrder:    int(questionCount) + 1,
+		TimeLimitSeconds: input.TimeLimit,
 	}
 
-	if err := tc.DB.Create(&question).Error; err != nil {
+	if err := tc.db(c).Create(&question).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create question",
 		})
@@ -538,7 +917,7 @@ func (tc *TestsController) AddQuestion(c *fiber.Ctx) error {
 }
 
 func (tc *TestsController) UpdateQuestion(c *fiber.Ctx) error {
-	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	_, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Unauthorized",
@@ -560,12 +939,13 @@ func (tc *TestsController) UpdateQuestion(c *fiber.Ctx) error {
 	}
 
 	var input struct {
-		Title         string   `json:"title"`
-		Description   string   `json:"description"`
-		Question      string   `json:"question"`
-		Options       []string `json:"options"`
-		CorrectAnswer int      `json:"correct_answer"`
-		SequenceOrder int      `json:"sequence_order"`
+		Title         utils.Optional[string]   `json:"title"`
+		Description   utils.Optional[string]   `json:"description"`
+		Question      utils.Optional[string]   `json:"question"`
+		Options       utils.Optional[[]string] `json:"options"`
+		CorrectAnswer utils.Optional[int]      `json:"correct_answer"`
+		SequenceOrder utils.Optional[int]      `json:"sequence_order"`
+		TimeLimit     utils.Optional[int]      `json:"time_limit_seconds"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -575,7 +955,7 @@ func (tc *TestsController) UpdateQuestion(c *fiber.Ctx) error {
 	}
 
 	var test models.Test
-	if err := tc.DB.First(&test, testID).Error; err != nil {
+	if err := tc.db(c).First(&test, testID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Test not found",
@@ -586,15 +966,15 @@ func (tc *TestsController) UpdateQuestion(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if test.AuthorID != userID && !strings.Contains(test.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university test editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to edit questions in this test",
 		})
 	}
 
 	var question models.TestQuestion
-	if err := tc.DB.Where("id = ? AND test_id = ?", questionID, testID).First(&question).Error; err != nil {
+	if err := tc.db(c).Where("id = ? AND test_id = ?", questionID, testID).First(&question).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Question not found",
@@ -605,33 +985,50 @@ func (tc *TestsController) UpdateQuestion(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update fields
-	if input.Title != "" {
-		question.Title = input.Title
-	}
-	if input.Description != "" {
-		question.Description = input.Description
-	}
-	if input.Question != "" {
-		question.Question = input.Question
+	// Every field sent is applied as is, zeros included (correct_answer 0 is the first option);
+	// null clears it, and the merged question is validated as a whole
+	input.Title.Apply(&question.Title, true)
+	input.Description.Apply(&question.Description, true)
+	input.Question.Apply(&question.Question, true)
+	input.CorrectAnswer.Apply(&question.CorrectAnswer, true)
+	input.SequenceOrder.Apply(&question.SequenceOrder, true)
+	input.TimeLimit.Apply(&question.TimeLimitSeconds, true)
+	if question.TimeLimitSeconds < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Time limit can't be negative",
+		})
 	}
-	if input.Options != nil {
-		optionsJson, err := json.Marshal(input.Options)
+
+	var options []string
+	if input.Options.Apply(&options, true) {
+		optionsJson, err := json.Marshal(options)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Could not encode options",
 			})
 		}
 		question.Options = string(optionsJson)
+	} else if err := json.Unmarshal([]byte(question.Options), &options); err != nil && question.Options != "" {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not decode options",
+		})
+	}
+
+	errs := map[string]string{}
+	if input.Title.Set && strings.TrimSpace(question.Title) == "" {
+		errs["title"] = "Title is required"
 	}
-	if input.CorrectAnswer >= 0 {
-		question.CorrectAnswer = input.CorrectAnswer
+	if input.Question.Set && strings.TrimSpace(question.Question) == "" {
+		errs["question"] = "Question text is required"
 	}
-	if input.SequenceOrder != 0 {
-		question.SequenceOrder = input.SequenceOrder
+	if (input.CorrectAnswer.Set || input.Options.Set) && (question.CorrectAnswer < 0 || question.CorrectAnswer >= len(options)) {
+		errs["correct_answer"] = "Invalid correct answer index"
+	}
+	if len(errs) > 0 {
+		return utils.ValidationError(c, errs)
 	}
 
-	if err := tc.DB.Save(&question).Error; err != nil {
+	if err := tc.db(c).Save(&question).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not update question",
 		})
@@ -652,7 +1049,7 @@ func (tc *TestsController) GetTestComments(c *fiber.Ctx) error {
 	}
 
 	var comments []models.TestComment
-	if err := tc.DB.Where("test_id = ?", testID).Find(&comments).Error; err != nil {
+	if err := tc.db(c).Where("test_id = ?", testID).Find(&comments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not query database",
 		})
@@ -662,7 +1059,7 @@ func (tc *TestsController) GetTestComments(c *fiber.Ctx) error {
 }
 
 func (tc *TestsController) UpdateTestSettings(c *fiber.Ctx) error {
-	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	_, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Unauthorized",
@@ -682,6 +1079,7 @@ func (tc *TestsController) UpdateTestSettings(c *fiber.Ctx) error {
 		EndDate         string `json:"end_date"`
 		Admins          string `json:"admins"`
 		AttemptsAllowed int    `json:"attempts_allowed"`
+		MaxPauseMinutes *int   `json:"max_pause_minutes"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -691,7 +1089,7 @@ func (tc *TestsController) UpdateTestSettings(c *fiber.Ctx) error {
 	}
 
 	var test models.Test
-	if err := tc.DB.Preload("AccessSettings").First(&test, testID).Error; err != nil {
+	if err := tc.db(c).Preload("AccessSettings").First(&test, testID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Test not found",
@@ -702,9 +1100,9 @@ func (tc *TestsController) UpdateTestSettings(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user is author or admin
-	if test.AuthorID != userID && !strings.Contains(test.AccessSettings.Admins, strconv.Itoa(int(userID))) {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	// Author, co-admins and university test editors
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
 			"error": "You don't have permission to edit settings for this test",
 		})
 	}
@@ -725,8 +1123,16 @@ func (tc *TestsController) UpdateTestSettings(c *fiber.Ctx) error {
 	if input.AttemptsAllowed >= 0 {
 		test.AccessSettings.AttemptsAllowed = input.AttemptsAllowed
 	}
+	if input.MaxPauseMinutes != nil {
+		if *input.MaxPauseMinutes < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "max_pause_minutes can't be negative",
+			})
+		}
+		test.AccessSettings.MaxPauseMinutes = *input.MaxPauseMinutes
+	}
 
-	if err := tc.DB.Save(&test.AccessSettings).Error; err != nil {
+	if err := tc.db(c).Save(&test.AccessSettings).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not update test settings",
 		})
@@ -754,7 +1160,7 @@ func (tc *TestsController) GetTestResult(c *fiber.Ctx) error {
 	}
 
 	var test models.Test
-	if err := tc.DB.Preload("Questions").First(&test, testID).Error; err != nil {
+	if err := tc.db(c).Preload("Questions").Preload("AccessSettings").First(&test, testID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Test not found",
@@ -766,29 +1172,53 @@ func (tc *TestsController) GetTestResult(c *fiber.Ctx) error {
 	}
 
 	var progress models.UserTestProgress
-	if err := tc.DB.Where("user_id = ? AND test_id = ?", userID, testID).First(&progress).Error; err != nil {
+	if err := tc.db(c).Where("user_id = ? AND test_id = ?", userID, testID).First(&progress).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Test not completed",
 		})
 	}
 
+	translations, err := questionTranslations(tc.db(c), test.Questions, requestLocale(c, tc.db(c), userID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	// While another attempt is possible, timed questions stay hidden: read here, they would be answered
+	// off the clock on the next attempt
+	allowed := test.AccessSettings.AttemptsAllowed
+	retakable := (allowed == 0 || progress.AttemptsUsed < allowed) &&
+		policy.Authorize(c, policy.ActionEdit, policy.Test(&test)) != nil
+
 	// Prepare questions with correct answers
 	var questions []map[string]interface{}
 	for _, q := range test.Questions {
+		q = localizeQuestion(q, translations)
 		var options []string
 		json.Unmarshal([]byte(q.Options), &options)
 
-		questions = append(questions, map[string]interface{}{
-			"id":             q.ID,
-			"title":          q.Title,
-			"description":    q.Description,
-			"question":       q.Question,
-			"options":        options,
-			"correct_answer": q.CorrectAnswer,
-			"order":          q.SequenceOrder,
-		})
+		item := map[string]interface{}{
+			"id":                 q.ID,
+			"title":              q.Title,
+			"description":        q.Description,
+			"question":           q.Question,
+			"options":            options,
+			"correct_answer":     q.CorrectAnswer,
+			"order":              q.SequenceOrder,
+			"time_limit_seconds": q.TimeLimitSeconds,
+		}
+		if q.TimeLimitSeconds > 0 && retakable {
+			delete(item, "description")
+			delete(item, "question")
+			delete(item, "options")
+			delete(item, "correct_answer")
+		}
+		questions = append(questions, item)
 	}
 
+	position, _ := rankings.ForUser(tc.db(c), test.ID, userID)
+
 	return c.JSON(fiber.Map{
 		"test": fiber.Map{
 			"id":        test.ID,
@@ -801,5 +1231,286 @@ func (tc *TestsController) GetTestResult(c *fiber.Ctx) error {
 			"score":              progress.Score,
 			"attempts_used":      progress.AttemptsUsed,
 		},
+		"ranking": rankingPayload(position),
+	})
+}
+
+// GetTestLeaderboard возвращает таблицу лидеров теста и место текущего пользователя
+func (tc *TestsController) GetTestLeaderboard(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unauthorized",
+		})
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid test ID",
+		})
+	}
+
+	var test models.Test
+	if err := tc.db(c).Preload("AccessSettings").First(&test, testID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Test not found",
+		})
+	}
+
+	// Names and scores are only shown to those who may open the test
+	if err := policy.Authorize(c, policy.ActionView, policy.Test(&test)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
+			"error": "You don't have access to this test",
+		})
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	entries, err := rankings.Top(tc.db(c), test.ID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	position, err := rankings.ForUser(tc.db(c), test.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+
+	leaders := make([]fiber.Map, 0, len(entries))
+	for _, entry := range entries {
+		leaders = append(leaders, fiber.Map{
+			"rank":     entry.Rank,
+			"delta":    rankings.Position{Rank: entry.Rank, PreviousRank: entry.PreviousRank}.Delta(),
+			"user_id":  entry.UserID,
+			"username": entry.Username,
+			"score":    entry.Score,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"leaderboard": leaders,
+		"me":          rankingPayload(position),
+	})
+}
+
+// rankingPayload описывает место пользователя; delta > 0 — поднялся на столько мест
+func rankingPayload(position *rankings.Position) fiber.Map {
+	if position == nil {
+		return nil
+	}
+	return fiber.Map{
+		"rank":          position.Rank,
+		"previous_rank": position.PreviousRank,
+		"delta":         position.Delta(),
+		"total":         position.Total,
+	}
+}
+
+// publishAttempt сообщает подписчикам живой ленты теста о сданной попытке
+func (tc *TestsController) publishAttempt(c *fiber.Ctx, test *models.Test, userID uint, progress *models.UserTestProgress, position *rankings.Position) {
+	topic := events.Topic("test", test.ID)
+	takingNow, err := events.TestTakers.Leave(tc.db(c), topic, userID)
+	if err != nil {
+		return
+	}
+
+	var user models.User
+	tc.db(c).Select("id", "username").First(&user, userID)
+
+	attempt := fiber.Map{
+		"user_id":            userID,
+		"username":           user.Username,
+		"score":              progress.Score,
+		"correct_answers":    progress.CorrectAnswers,
+		"questions_answered": progress.QuestionsAnswered,
+		"attempts_used":      progress.AttemptsUsed,
+		"rank":               nil,
+	}
+	if position != nil {
+		attempt["rank"] = position.Rank
+	}
+	events.Publish(tc.db(c), topic, "attempt", attempt)
+	events.Publish(tc.db(c), topic, "presence", fiber.Map{"taking_now": takingNow})
+}
+
+// liveHeartbeat — как часто в живую ленту пишется комментарий, чтобы прокси не закрывали соединение
+const liveHeartbeat = 15 * time.Second
+
+// StreamTestActivity — живая лента теста для автора (Server-Sent Events): сданные попытки
+// и число студентов, проходящих тест прямо сейчас. Первым приходит событие snapshot.
+func (tc *TestsController) StreamTestActivity(c *fiber.Ctx) error {
+	_, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unauthorized",
+		})
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid test ID",
+		})
+	}
+
+	var test models.Test
+	if err := tc.db(c).Preload("AccessSettings").First(&test, testID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Test not found",
+		})
+	}
+
+	if err := policy.Authorize(c, policy.ActionViewAnalytics, policy.Test(&test)); err != nil {
+		return c.Status(policy.Status(err)).JSON(fiber.Map{
+			"error": "You don't have permission to monitor this test",
+		})
+	}
+
+	var stats struct {
+		Attempts     int64
+		AverageScore float64
+	}
+	tc.db(c).Model(&models.UserTestProgress{}).
+		Select("COUNT(*) AS attempts, COALESCE(AVG(score), 0) AS average_score").
+		Where("test_id = ?", test.ID).
+		Scan(&stats)
+
+	topic := events.Topic("test", test.ID)
+	takingNow, err := events.TestTakers.Count(tc.db(c), topic)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not query database",
+		})
+	}
+	snapshot := events.Event{Type: "snapshot", At: time.Now(), Data: fiber.Map{
+		"test_id":       test.ID,
+		"taking_now":    takingNow,
+		"attempts":      stats.Attempts,
+		"average_score": stats.AverageScore,
+		"window": fiber.Map{
+			"start_date": test.AccessSettings.StartDate,
+			"end_date":   test.AccessSettings.EndDate,
+		},
+	}}
+
+	stream, unsubscribe := events.Default.Subscribe(topic)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The writer runs after the handler returns, so it must not use the request context
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		heartbeat := time.NewTicker(liveHeartbeat)
+		defer heartbeat.Stop()
+
+		if writeEvent(w, snapshot) != nil {
+			return
+		}
+		for {
+			select {
+			case event, ok := <-stream:
+				if !ok || writeEvent(w, event) != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := w.WriteString(": ping\n\n"); err != nil || w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// writeEvent пишет событие в формате SSE и сразу отправляет его клиенту
+func writeEvent(w *bufio.Writer, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// DeleteTest удаляет тест вместе с вопросами, настройками, результатами и сессиями экзамена.
+// Все удаляется мягко и возвращается из корзины (см. TrashController).
+func (tc *TestsController) DeleteTest(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, tc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var test models.Test
+	if err := tc.db(c).First(&test, testID).Error; err != nil {
+		return utils.NotFound(c, "Test not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to delete this test"))
+	}
+	if utils.UnderLegalHold(tc.db(c), models.HoldSubjectTest, test.ID) {
+		return utils.LegalHoldConflict(c)
+	}
+
+	// One timestamp for the whole cascade, the restore matches on it
+	at := time.Now().Truncate(time.Microsecond)
+	err = tc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := deleteCascade(tx, testCascade, "test_id", test.ID, at); err != nil {
+			return err
+		}
+		return tx.Model(&test).Update("deleted_at", at).Error
 	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not delete test")
+	}
+
+	cache.Analytics.Invalidate(cache.Tag("test", test.ID), "platform")
+	return utils.NoContent(c)
+}
+
+// DeleteQuestion удаляет вопрос; уже выставленные результаты не пересчитываются
+func (tc *TestsController) DeleteQuestion(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, tc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var test models.Test
+	if err := tc.db(c).First(&test, testID).Error; err != nil {
+		return utils.NotFound(c, "Test not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to delete questions in this test"))
+	}
+	if utils.UnderLegalHold(tc.db(c), models.HoldSubjectTest, test.ID) {
+		return utils.LegalHoldConflict(c)
+	}
+
+	result := tc.db(c).Where("id = ? AND test_id = ?", c.Params("questionId"), test.ID).Delete(&models.TestQuestion{})
+	if result.Error != nil {
+		return utils.InternalServerError(c, "Could not delete question")
+	}
+	if result.RowsAffected == 0 {
+		return utils.NotFound(c, "Question not found")
+	}
+	return utils.NoContent(c)
 }
@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/utils"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	errTopicNotFound = errors.New("Topic not found")
+	errTopicCycle    = errors.New("A topic can't be moved under itself or its descendants")
+	errTopicInUse    = errors.New("Topic has subtopics or content attached")
+
+	slugUnsafe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+type TopicsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewTopicsController(db *gorm.DB, cfg *config.Config) *TopicsController {
+	return &TopicsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (tc *TopicsController) db(c *fiber.Ctx) *gorm.DB {
+	return tc.DB.WithContext(c.UserContext())
+}
+
+// TopicNode — узел дерева тем в ответах API
+type TopicNode struct {
+	ID          uint         `json:"id"`
+	Name        string       `json:"name"`
+	Slug        string       `json:"slug"`
+	ParentID    *uint        `json:"parent_id"`
+	CourseCount int64        `json:"course_count"`
+	TestCount   int64        `json:"test_count"`
+	Children    []*TopicNode `json:"children"`
+}
+
+// Breadcrumb — элемент пути от корня каталога до темы
+type Breadcrumb struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// GetTopicTree возвращает весь каталог тем деревом с количеством опубликованных курсов и тестов в каждом узле
+func (tc *TopicsController) GetTopicTree(c *fiber.Ctx) error {
+	var topics []models.Topic
+	if err := tc.db(c).Order("sort_order, name").Find(&topics).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch topics")
+	}
+
+	type count struct {
+		TopicID uint
+		Total   int64
+	}
+	var courseCounts, testCounts []count
+	tc.db(c).Model(&models.Course{}).
+		Select("topic_id, COUNT(*) AS total").
+		Where("topic_id IS NOT NULL").
+		Where("id IN (SELECT course_id FROM course_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Group("topic_id").Scan(&courseCounts)
+	tc.db(c).Model(&models.Test{}).
+		Select("topic_id, COUNT(*) AS total").
+		Where("topic_id IS NOT NULL").
+		Where("id IN (SELECT test_id FROM test_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Group("topic_id").Scan(&testCounts)
+
+	nodes := make(map[uint]*TopicNode, len(topics))
+	for _, topic := range topics {
+		nodes[topic.ID] = &TopicNode{
+			ID:       topic.ID,
+			Name:     topic.Name,
+			Slug:     topic.Slug,
+			ParentID: topic.ParentID,
+			Children: []*TopicNode{},
+		}
+	}
+	for _, cnt := range courseCounts {
+		if node, ok := nodes[cnt.TopicID]; ok {
+			node.CourseCount = cnt.Total
+		}
+	}
+	for _, cnt := range testCounts {
+		if node, ok := nodes[cnt.TopicID]; ok {
+			node.TestCount = cnt.Total
+		}
+	}
+
+	roots := []*TopicNode{}
+	for _, topic := range topics {
+		node := nodes[topic.ID]
+		if parent, ok := nodes[derefUint(topic.ParentID)]; ok {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		roots = append(roots, node)
+	}
+
+	return utils.Success(c, fiber.StatusOK, roots)
+}
+
+// GetTopic возвращает тему с хлебными крошками, подтемами и опубликованными курсами и тестами всего поддерева
+func (tc *TopicsController) GetTopic(c *fiber.Ctx) error {
+	topicID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid topic ID")
+	}
+
+	var topic models.Topic
+	if err := tc.db(c).First(&topic, topicID).Error; err != nil {
+		return utils.NotFound(c, errTopicNotFound.Error())
+	}
+
+	breadcrumbs, err := topicBreadcrumbs(tc.db(c), &topic.ID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch breadcrumbs")
+	}
+
+	var children []models.Topic
+	tc.db(c).Where("parent_id = ?", topic.ID).Order("sort_order, name").Find(&children)
+
+	subtree := tc.db(c).Model(&models.Topic{}).Select("id").Where("path LIKE ?", topic.Path+"%")
+
+	var courses []models.Course
+	if err := tc.db(c).
+		Where("topic_id IN (?)", subtree).
+		Where("id IN (SELECT course_id FROM course_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Order("title").Find(&courses).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch courses")
+	}
+
+	var tests []models.Test
+	if err := tc.db(c).
+		Where("topic_id IN (?)", subtree).
+		Where("id IN (SELECT test_id FROM test_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Order("title").Find(&tests).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch tests")
+	}
+
+	courseCards := make([]fiber.Map, 0, len(courses))
+	for _, course := range courses {
+		courseCards = append(courseCards, fiber.Map{
+			"id":                 course.ID,
+			"title":              course.Title,
+			"short_desc":         course.ShortDesc,
+			"difficulty":         course.Difficulty,
+			"topic_id":           course.TopicID,
+			"author":             course.AuthorID,
+			"logo_url":           course.LogoURL,
+			"license":            course.License,
+			"attribution":        course.Attribution,
+			"source_attribution": course.SourceAttribution,
+		})
+	}
+	testCards := make([]fiber.Map, 0, len(tests))
+	for _, test := range tests {
+		testCards = append(testCards, fiber.Map{
+			"id":          test.ID,
+			"title":       test.Title,
+			"short_desc":  test.ShortDesc,
+			"difficulty":  test.Difficulty,
+			"topic_id":    test.TopicID,
+			"author":      test.AuthorID,
+			"logo_url":    test.LogoURL,
+			"license":     test.License,
+			"attribution": test.Attribution,
+		})
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"id":          topic.ID,
+		"name":        topic.Name,
+		"slug":        topic.Slug,
+		"parent_id":   topic.ParentID,
+		"breadcrumbs": breadcrumbs,
+		"children":    children,
+		"courses":     courseCards,
+		"tests":       testCards,
+	})
+}
+
+// CreateTopic добавляет тему в каталог (корневую, если parent_id не указан)
+func (tc *TopicsController) CreateTopic(c *fiber.Ctx) error {
+	var input struct {
+		Name      string `json:"name"`
+		Slug      string `json:"slug"`
+		ParentID  *uint  `json:"parent_id"`
+		SortOrder int    `json:"sort_order"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return utils.ValidationError(c, map[string]string{"name": "Name is required"})
+	}
+	if input.Slug == "" {
+		input.Slug = slugify(input.Name)
+	}
+
+	topic := models.Topic{
+		Name:      input.Name,
+		Slug:      input.Slug,
+		ParentID:  input.ParentID,
+		SortOrder: input.SortOrder,
+	}
+
+	err := tc.db(c).Transaction(func(tx *gorm.DB) error {
+		parentPath := "/"
+		if topic.ParentID != nil {
+			var parent models.Topic
+			if err := tx.First(&parent, *topic.ParentID).Error; err != nil {
+				return errTopicNotFound
+			}
+			parentPath = parent.Path
+		}
+
+		if err := tx.Create(&topic).Error; err != nil {
+			return err
+		}
+		topic.Path = fmt.Sprintf("%s%d/", parentPath, topic.ID)
+		return tx.Model(&topic).Update("path", topic.Path).Error
+	})
+	if errors.Is(err, errTopicNotFound) {
+		return utils.NotFound(c, "Parent topic not found")
+	}
+	if err != nil {
+		return utils.BadRequest(c, "Could not create topic, slug may already be taken")
+	}
+
+	return utils.Created(c, topic)
+}
+
+// UpdateTopic переименовывает тему или переносит ее вместе с поддеревом под другого родителя
+func (tc *TopicsController) UpdateTopic(c *fiber.Ctx) error {
+	topicID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid topic ID")
+	}
+
+	var input struct {
+		Name      utils.Optional[string] `json:"name"`
+		Slug      utils.Optional[string] `json:"slug"`
+		ParentID  utils.Optional[uint]   `json:"parent_id"`
+		SortOrder utils.Optional[int]    `json:"sort_order"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var topic models.Topic
+	err = tc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&topic, topicID).Error; err != nil {
+			return errTopicNotFound
+		}
+
+		oldName := topic.Name
+		input.Name.Apply(&topic.Name, false)
+		input.Slug.Apply(&topic.Slug, false)
+		input.SortOrder.Apply(&topic.SortOrder, true)
+
+		if input.ParentID.Set {
+			if err := moveTopic(tx, &topic, input.ParentID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Save(&topic).Error; err != nil {
+			return err
+		}
+
+		// Keep the denormalized topic name on courses and tests in sync
+		if topic.Name != oldName {
+			if err := tx.Model(&models.Course{}).Where("topic_id = ?", topic.ID).Update("topic", topic.Name).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Test{}).Where("topic_id = ?", topic.ID).Update("topic", topic.Name).Error
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errTopicNotFound):
+		return utils.NotFound(c, err.Error())
+	case errors.Is(err, errTopicCycle):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not update topic")
+	}
+
+	return utils.Success(c, fiber.StatusOK, topic)
+}
+
+// DeleteTopic удаляет пустую тему без подтем
+func (tc *TopicsController) DeleteTopic(c *fiber.Ctx) error {
+	topicID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid topic ID")
+	}
+
+	err = tc.db(c).Transaction(func(tx *gorm.DB) error {
+		var topic models.Topic
+		if err := tx.First(&topic, topicID).Error; err != nil {
+			return errTopicNotFound
+		}
+
+		var children, courses, tests int64
+		tx.Model(&models.Topic{}).Where("parent_id = ?", topic.ID).Count(&children)
+		tx.Model(&models.Course{}).Where("topic_id = ?", topic.ID).Count(&courses)
+		tx.Model(&models.Test{}).Where("topic_id = ?", topic.ID).Count(&tests)
+		if children+courses+tests > 0 {
+			return errTopicInUse
+		}
+
+		// Hard delete so the slug can be reused
+		return tx.Unscoped().Delete(&topic).Error
+	})
+
+	switch {
+	case errors.Is(err, errTopicNotFound):
+		return utils.NotFound(c, err.Error())
+	case errors.Is(err, errTopicInUse):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not delete topic")
+	}
+
+	return utils.NoContent(c)
+}
+
+// moveTopic меняет родителя темы и переписывает materialized path всего поддерева
+func moveTopic(tx *gorm.DB, topic *models.Topic, parentID utils.Optional[uint]) error {
+	newParentPath := "/"
+	var newParent *uint
+	if !parentID.Null && parentID.Value != 0 {
+		var parent models.Topic
+		if err := tx.First(&parent, parentID.Value).Error; err != nil {
+			return errTopicNotFound
+		}
+		if strings.HasPrefix(parent.Path, topic.Path) {
+			return errTopicCycle
+		}
+		newParentPath = parent.Path
+		newParent = &parent.ID
+	}
+
+	oldPath := topic.Path
+	newPath := fmt.Sprintf("%s%d/", newParentPath, topic.ID)
+	if oldPath == newPath {
+		return nil
+	}
+
+	if err := tx.Model(&models.Topic{}).
+		Where("path LIKE ?", oldPath+"%").
+		Update("path", gorm.Expr("? || substr(path, ?)", newPath, len(oldPath)+1)).Error; err != nil {
+		return err
+	}
+
+	topic.ParentID = newParent
+	topic.Path = newPath
+	return nil
+}
+
+// assignTopic проверяет topic_id из запроса и записывает его вместе с названием темы.
+// null (или 0) отвязывает тему.
+func assignTopic(db *gorm.DB, input utils.Optional[uint], topicID **uint, topicName *string) error {
+	if !input.Set {
+		return nil
+	}
+	if input.Null || input.Value == 0 {
+		*topicID = nil
+		*topicName = ""
+		return nil
+	}
+
+	var topic models.Topic
+	if err := db.Select("id", "name").First(&topic, input.Value).Error; err != nil {
+		return errTopicNotFound
+	}
+	*topicID = &topic.ID
+	*topicName = topic.Name
+	return nil
+}
+
+// topicBreadcrumbs возвращает путь от корня каталога до темы; для nil — пустой список
+func topicBreadcrumbs(db *gorm.DB, topicID *uint) ([]Breadcrumb, error) {
+	breadcrumbs := []Breadcrumb{}
+	if topicID == nil {
+		return breadcrumbs, nil
+	}
+
+	var topic models.Topic
+	if err := db.Select("id", "path").First(&topic, *topicID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return breadcrumbs, nil
+		}
+		return nil, err
+	}
+
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(topic.Path, "/"), "/") {
+		if id, err := strconv.Atoi(part); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+
+	var ancestors []models.Topic
+	if err := db.Where("id IN ?", ids).Find(&ancestors).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Topic, len(ancestors))
+	for _, ancestor := range ancestors {
+		byID[ancestor.ID] = ancestor
+	}
+	for _, id := range ids {
+		if ancestor, ok := byID[id]; ok {
+			breadcrumbs = append(breadcrumbs, Breadcrumb{ID: ancestor.ID, Name: ancestor.Name, Slug: ancestor.Slug})
+		}
+	}
+	return breadcrumbs, nil
+}
+
+func slugify(name string) string {
+	return strings.Trim(slugUnsafe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func derefUint(v *uint) uint {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,484 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/llm"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const translationSystemPrompt = "You translate multiple-choice quiz questions for teachers. " +
+	"Translate every field of every question in the given JSON object into the target language, keeping terminology precise and the tone neutral. " +
+	"Keep the number and the order of the questions and of their options exactly as given and do not add hints to the correct answer. " +
+	"Reply with a JSON object with the same keys and nothing else."
+
+// translationBatch — сколько вопросов переводится одним запросом к модели
+const translationBatch = 10
+
+// localeNames — названия языков для подсказки модели
+var localeNames = map[string]string{"en": "English", "ru": "Russian"}
+
+// questionText — переводимые поля вопроса
+type questionText struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+}
+
+type TranslationsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewTranslationsController(db *gorm.DB, cfg *config.Config) *TranslationsController {
+	return &TranslationsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (tc *TranslationsController) db(c *fiber.Ctx) *gorm.DB {
+	return tc.DB.WithContext(c.UserContext())
+}
+
+// GetTranslations возвращает переводы вопросов теста (?locale= — только на один язык) с отметкой,
+// какие из них устарели после правки исходного вопроса
+func (tc *TranslationsController) GetTranslations(c *fiber.Ctx) error {
+	test, _, done, err := tc.authorizedTest(c)
+	if done {
+		return err
+	}
+
+	query := tc.db(c).Where("test_id = ?", test.ID)
+	if locale := c.Query("locale"); locale != "" {
+		query = query.Where("locale = ?", locale)
+	}
+	var translations []models.TestQuestionTranslation
+	if err := query.Order("question_id, locale").Find(&translations).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch translations")
+	}
+	var questions []models.TestQuestion
+	if err := tc.db(c).Where("test_id = ?", test.ID).Find(&questions).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch questions")
+	}
+	checksums := make(map[uint]string, len(questions))
+	for _, question := range questions {
+		checksums[question.ID] = questionChecksum(question)
+	}
+
+	result := make([]fiber.Map, 0, len(translations))
+	approved := map[string]int{}
+	for _, translation := range translations {
+		current, ok := checksums[translation.QuestionID]
+		if !ok {
+			continue // the question was deleted
+		}
+		stale := translation.SourceChecksum != current
+		if translation.Status == models.TranslationApproved && !stale {
+			approved[translation.Locale]++
+		}
+		result = append(result, translationPayload(translation, stale))
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"questions":    len(questions),
+		"approved":     approved,
+		"translations": result,
+	})
+}
+
+// TranslateQuestions готовит черновики машинного перевода вопросов теста на язык locale.
+// Переводятся вопросы без перевода и те, чей перевод устарел; overwrite=true заменяет и непроверенные черновики.
+// Одобренные актуальные переводы не трогаются. Черновики показываются студентам только после одобрения.
+func (tc *TranslationsController) TranslateQuestions(c *fiber.Ctx) error {
+	test, _, done, err := tc.authorizedTest(c)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Locale      string `json:"locale"`
+		QuestionIDs []uint `json:"question_ids"` // empty = every question of the test
+		Overwrite   bool   `json:"overwrite"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+	if !slices.Contains(utils.SupportedLocales, input.Locale) {
+		return utils.ValidationError(c, map[string]string{"locale": "Unsupported locale"})
+	}
+
+	query := tc.db(c).Where("test_id = ?", test.ID)
+	if len(input.QuestionIDs) > 0 {
+		query = query.Where("id IN ?", input.QuestionIDs)
+	}
+	var questions []models.TestQuestion
+	if err := query.Order("sequence_order, id").Find(&questions).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch questions")
+	}
+	if len(input.QuestionIDs) > 0 && len(questions) != len(uniqueIDs(input.QuestionIDs)) {
+		return utils.ValidationError(c, map[string]string{"question_ids": "Some questions don't belong to this test"})
+	}
+
+	existing := map[uint]models.TestQuestionTranslation{}
+	var translations []models.TestQuestionTranslation
+	if err := tc.db(c).Where("test_id = ? AND locale = ?", test.ID, input.Locale).Find(&translations).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch translations")
+	}
+	for _, translation := range translations {
+		existing[translation.QuestionID] = translation
+	}
+
+	drafts := []fiber.Map{}
+	skipped := 0
+	pending := make([]models.TestQuestion, 0, len(questions))
+	for _, question := range questions {
+		translation, found := existing[question.ID]
+		if found && translation.SourceChecksum == questionChecksum(question) &&
+			(translation.Status == models.TranslationApproved || !input.Overwrite) {
+			skipped++
+			continue
+		}
+		pending = append(pending, question)
+	}
+
+	// Questions go to the model in batches, a long test doesn't cost one call per question
+	for start := 0; start < len(pending); start += translationBatch {
+		batch := pending[start:min(start+translationBatch, len(pending))]
+		texts, err := translateQuestions(c, batch, input.Locale)
+		switch {
+		case errors.Is(err, llm.ErrDisabled):
+			return utils.Error(c, fiber.StatusServiceUnavailable, errors.New("Machine translation is not available on this server"))
+		case err != nil:
+			// Drafts made so far are kept, the author can run the rest again
+			return utils.Error(c, fiber.StatusBadGateway, errors.New("Could not translate the questions, please try again"),
+				fiber.Map{"translated": drafts})
+		}
+
+		saved := make([]models.TestQuestionTranslation, len(batch))
+		err = tc.db(c).Transaction(func(tx *gorm.DB) error {
+			for i, question := range batch {
+				options, _ := json.Marshal(texts[i].Options)
+				translation := existing[question.ID]
+				translation.TestID = test.ID
+				translation.QuestionID = question.ID
+				translation.Locale = input.Locale
+				translation.Title = texts[i].Title
+				translation.Description = texts[i].Description
+				translation.Question = texts[i].Question
+				translation.Options = string(options)
+				translation.Status = models.TranslationNeedsReview
+				translation.Source = models.TranslationMachine
+				translation.SourceChecksum = questionChecksum(question)
+				translation.ReviewedBy = nil
+				translation.ReviewedAt = nil
+				if err := tx.Save(&translation).Error; err != nil {
+					return err
+				}
+				saved[i] = translation
+			}
+			return nil
+		})
+		if err != nil {
+			return utils.InternalServerError(c, "Could not save translation")
+		}
+		for _, translation := range saved {
+			drafts = append(drafts, translationPayload(translation, false))
+		}
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"locale":     input.Locale,
+		"translated": drafts,
+		"skipped":    skipped,
+	})
+}
+
+// UpdateTranslation — правка перевода автором; правленый перевод снова ждет одобрения
+func (tc *TranslationsController) UpdateTranslation(c *fiber.Ctx) error {
+	test, _, done, err := tc.authorizedTest(c)
+	if done {
+		return err
+	}
+
+	var input struct {
+		Title       utils.Optional[string]   `json:"title"`
+		Description utils.Optional[string]   `json:"description"`
+		Question    utils.Optional[string]   `json:"question"`
+		Options     utils.Optional[[]string] `json:"options"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	translation, question, done, err := tc.translation(c, test.ID)
+	if done {
+		return err
+	}
+
+	merge := utils.IsMergePatch(c)
+	input.Title.Apply(&translation.Title, merge)
+	input.Description.Apply(&translation.Description, merge)
+	input.Question.Apply(&translation.Question, merge)
+	var options []string
+	if input.Options.Apply(&options, merge) {
+		if len(options) != len(questionOptions(question)) {
+			return utils.ValidationError(c, map[string]string{"options": "The translation must have as many options as the question"})
+		}
+		encoded, _ := json.Marshal(options)
+		translation.Options = string(encoded)
+	}
+	if strings.TrimSpace(translation.Question) == "" {
+		return utils.ValidationError(c, map[string]string{"question": "Question is required"})
+	}
+
+	translation.Status = models.TranslationNeedsReview
+	translation.Source = models.TranslationAuthor
+	translation.ReviewedBy = nil
+	translation.ReviewedAt = nil
+	if err := tc.db(c).Save(&translation).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save translation")
+	}
+	return utils.Success(c, fiber.StatusOK, translationPayload(translation, translation.SourceChecksum != questionChecksum(question)))
+}
+
+// ApproveTranslation одобряет перевод, после чего он показывается студентам с этим языком.
+// Одобрение подтверждает перевод для текущей версии вопроса, даже если тот менялся после перевода.
+func (tc *TranslationsController) ApproveTranslation(c *fiber.Ctx) error {
+	test, userID, done, err := tc.authorizedTest(c)
+	if done {
+		return err
+	}
+
+	translation, question, done, err := tc.translation(c, test.ID)
+	if done {
+		return err
+	}
+	var options []string
+	if err := json.Unmarshal([]byte(translation.Options), &options); err != nil || len(options) != len(questionOptions(question)) {
+		return utils.Error(c, fiber.StatusConflict, errors.New("The translation doesn't match the options of the question, edit it first"))
+	}
+
+	now := time.Now()
+	translation.Status = models.TranslationApproved
+	translation.SourceChecksum = questionChecksum(question)
+	translation.ReviewedBy = &userID
+	translation.ReviewedAt = &now
+	if err := tc.db(c).Save(&translation).Error; err != nil {
+		return utils.InternalServerError(c, "Could not save translation")
+	}
+	return utils.Success(c, fiber.StatusOK, translationPayload(translation, false))
+}
+
+// DeleteTranslation удаляет перевод; вопрос снова показывается на исходном языке
+func (tc *TranslationsController) DeleteTranslation(c *fiber.Ctx) error {
+	test, _, done, err := tc.authorizedTest(c)
+	if done {
+		return err
+	}
+
+	translation, _, done, err := tc.translation(c, test.ID)
+	if done {
+		return err
+	}
+	// Hard delete, so the same locale can be translated again
+	if err := tc.db(c).Unscoped().Delete(&translation).Error; err != nil {
+		return utils.InternalServerError(c, "Could not delete translation")
+	}
+	return utils.NoContent(c)
+}
+
+// authorizedTest загружает тест из :id и проверяет, что пользователь может его редактировать
+func (tc *TranslationsController) authorizedTest(c *fiber.Ctx) (*models.Test, uint, bool, error) {
+	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	if err != nil {
+		return nil, 0, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	testID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 0, true, utils.BadRequest(c, "Invalid test ID")
+	}
+
+	var test models.Test
+	if err := tc.db(c).First(&test, testID).Error; err != nil {
+		return nil, 0, true, utils.NotFound(c, "Test not found")
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return nil, 0, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to edit this test"))
+	}
+	return &test, userID, false, nil
+}
+
+// translation загружает перевод из :translationId вместе с исходным вопросом
+func (tc *TranslationsController) translation(c *fiber.Ctx, testID uint) (models.TestQuestionTranslation, models.TestQuestion, bool, error) {
+	var translation models.TestQuestionTranslation
+	var question models.TestQuestion
+	if err := tc.db(c).Where("id = ? AND test_id = ?", c.Params("translationId"), testID).First(&translation).Error; err != nil {
+		return translation, question, true, utils.NotFound(c, "Translation not found")
+	}
+	if err := tc.db(c).First(&question, translation.QuestionID).Error; err != nil {
+		return translation, question, true, utils.NotFound(c, "Question not found")
+	}
+	return translation, question, false, nil
+}
+
+// translateQuestions просит модель перевести пачку вопросов одним запросом и проверяет, что ни вопросы,
+// ни варианты ответа не потерялись; переводы возвращаются в порядке вопросов
+func translateQuestions(c *fiber.Ctx, questions []models.TestQuestion, locale string) ([]questionText, error) {
+	source := struct {
+		Questions []questionText `json:"questions"`
+	}{Questions: make([]questionText, len(questions))}
+	for i, question := range questions {
+		source.Questions[i] = questionText{
+			Title:       question.Title,
+			Description: question.Description,
+			Question:    question.Question,
+			Options:     questionOptions(question),
+		}
+	}
+	encoded, _ := json.Marshal(source)
+	prompt := fmt.Sprintf("Target language: %s\n%s", localeNames[locale], encoded)
+
+	reply, err := llm.Default.Complete(c.UserContext(), translationSystemPrompt, prompt)
+	if err != nil {
+		return nil, err
+	}
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON object in the reply")
+	}
+	var translated struct {
+		Questions []questionText `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &translated); err != nil {
+		return nil, err
+	}
+	if len(translated.Questions) != len(questions) {
+		return nil, errors.New("the reply doesn't match the questions")
+	}
+	for i, text := range translated.Questions {
+		if strings.TrimSpace(text.Question) == "" || len(text.Options) != len(source.Questions[i].Options) {
+			return nil, errors.New("the reply doesn't match the questions")
+		}
+	}
+	return translated.Questions, nil
+}
+
+func questionOptions(question models.TestQuestion) []string {
+	options := []string{}
+	json.Unmarshal([]byte(question.Options), &options)
+	return options
+}
+
+// questionChecksum — отпечаток переводимых полей вопроса, по нему видно, что перевод устарел
+func questionChecksum(question models.TestQuestion) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{question.Title, question.Description, question.Question, question.Options}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func translationPayload(translation models.TestQuestionTranslation, stale bool) fiber.Map {
+	var options []string
+	json.Unmarshal([]byte(translation.Options), &options)
+	return fiber.Map{
+		"id":          translation.ID,
+		"question_id": translation.QuestionID,
+		"locale":      translation.Locale,
+		"title":       translation.Title,
+		"description": translation.Description,
+		"question":    translation.Question,
+		"options":     options,
+		"status":      translation.Status,
+		"source":      translation.Source,
+		"stale":       stale,
+		"reviewed_by": translation.ReviewedBy,
+		"reviewed_at": translation.ReviewedAt,
+		"updated_at":  translation.UpdatedAt,
+	}
+}
+
+// questionTranslations возвращает одобренные и актуальные переводы вопросов на язык locale по ID вопроса
+func questionTranslations(db *gorm.DB, questions []models.TestQuestion, locale string) (map[uint]models.TestQuestionTranslation, error) {
+	result := map[uint]models.TestQuestionTranslation{}
+	if locale == "" || len(questions) == 0 {
+		return result, nil
+	}
+	ids := make([]uint, len(questions))
+	for i, question := range questions {
+		ids[i] = question.ID
+	}
+	var translations []models.TestQuestionTranslation
+	if err := db.Where("question_id IN ? AND locale = ? AND status = ?", ids, locale, models.TranslationApproved).
+		Find(&translations).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.TestQuestionTranslation, len(translations))
+	for _, translation := range translations {
+		byID[translation.QuestionID] = translation
+	}
+	for _, question := range questions {
+		if translation, ok := byID[question.ID]; ok && translation.SourceChecksum == questionChecksum(question) {
+			result[question.ID] = translation
+		}
+	}
+	return result, nil
+}
+
+// variantLocale — вариант теста, который видит пользователь с языком locale: сам locale, если на него
+// одобрен хотя бы один вопрос, иначе пустая строка (исходный текст)
+func variantLocale(translations map[uint]models.TestQuestionTranslation, locale string) string {
+	if len(translations) == 0 {
+		return ""
+	}
+	return locale
+}
+
+// testLocales возвращает языки, на которые у теста есть одобренные актуальные переводы
+func testLocales(db *gorm.DB, questions []models.TestQuestion) ([]string, error) {
+	locales := []string{}
+	for _, locale := range utils.SupportedLocales {
+		translations, err := questionTranslations(db, questions, locale)
+		if err != nil {
+			return nil, err
+		}
+		if len(translations) > 0 {
+			locales = append(locales, locale)
+		}
+	}
+	return locales, nil
+}
+
+// localizeQuestion подставляет одобренный перевод в текст вопроса, если он есть
+func localizeQuestion(question models.TestQuestion, translations map[uint]models.TestQuestionTranslation) models.TestQuestion {
+	if translation, ok := translations[question.ID]; ok {
+		question.Title = translation.Title
+		question.Description = translation.Description
+		question.Question = translation.Question
+		question.Options = translation.Options
+	}
+	return question
+}
+
+// requestLocale — язык, на котором показывать тест: ?locale= или язык из настроек пользователя
+func requestLocale(c *fiber.Ctx, db *gorm.DB, userID uint) string {
+	if locale := c.Query("locale"); slices.Contains(utils.SupportedLocales, locale) {
+		return locale
+	}
+	settings, err := utils.LoadUserSettings(db, userID)
+	if err != nil {
+		return ""
+	}
+	return settings.Locale
+}
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/cache"
+	"project/backend/completion"
+	"project/backend/config"
+	"project/backend/grading"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Удаленный контент скрывается мягким удалением. Связанные строки удаляются с тем же deleted_at,
+// по нему восстановление находит ровно то, что было удалено вместе с курсом или тестом.
+// Курсы и уроки восстанавливаются в течение TrashRetentionDays: потом уроки с их файлами удаляются насовсем
+// (см. jobs.PurgeTrashedLessons).
+
+// courseCascade — строки, которые удаляются и восстанавливаются вместе с курсом (колонка course_id).
+// Сертификаты остаются: студенты получили их и могут предъявлять.
+var courseCascade = []interface{}{
+	&models.CourseAccessSettings{}, &models.CourseModule{}, &models.Lesson{},
+	&models.UserCourseProgress{}, &models.CourseAnalytics{}, &models.CourseStaff{},
+	&models.CourseGradeComponent{}, &models.CourseGrade{}, &models.CourseSurvey{},
+	&models.CourseSISMapping{}, &models.CourseAccessList{}, &models.CourseConduct{},
+	&models.CourseConductAcknowledgement{}, &models.CourseTag{}, &models.CourseRun{},
+	&models.Announcement{}, &models.CourseWaitlist{}, &models.Review{},
+}
+
+// testCascade — строки, которые удаляются и восстанавливаются вместе с тестом (колонка test_id)
+var testCascade = []interface{}{
+	&models.TestAccessSettings{}, &models.TestQuestion{}, &models.UserTestProgress{},
+	&models.TestAnalytics{}, &models.TestRanking{}, &models.ExamSession{}, &models.TestTag{},
+	&models.TestQuestionTranslation{}, &models.QuestionServe{},
+	&models.AttemptAnswer{},
+}
+
+// Виды удаленного контента в корзине
+const (
+	trashCourse   = "courses"
+	trashLesson   = "lessons"
+	trashTest     = "tests"
+	trashQuestion = "questions"
+)
+
+var errTrashNotFound = errors.New("Deleted item not found")
+
+type TrashController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewTrashController(db *gorm.DB, cfg *config.Config) *TrashController {
+	return &TrashController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (tc *TrashController) db(c *fiber.Ctx) *gorm.DB {
+	return tc.DB.WithContext(c.UserContext())
+}
+
+// GetTrash возвращает удаленный контент вида :kind (courses, lessons, tests, questions), новые сначала.
+// Авторы видят свой контент, редакторы с глобальной ролью — весь.
+func (tc *TrashController) GetTrash(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, tc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// Outside a global editor role only the user's own content is listed
+	courses := tc.db(c).Unscoped().Model(&models.Course{})
+	if !utils.HasPermission(tc.db(c), userID, models.PermCoursesEdit) {
+		courses = courses.Where("author_id = ?", userID)
+	}
+	tests := tc.db(c).Unscoped().Model(&models.Test{})
+	if !utils.HasPermission(tc.db(c), userID, models.PermTestsEdit) {
+		tests = tests.Where("author_id = ?", userID)
+	}
+
+	var query *gorm.DB
+	switch c.Params("kind") {
+	case trashCourse:
+		query = courses.Where("deleted_at > ?", tc.purgeCutoff())
+	case trashTest:
+		query = tests.Where("deleted_at IS NOT NULL")
+	case trashLesson:
+		// Only lessons deleted one by one: those removed with their course come back with it
+		query = tc.db(c).Unscoped().Model(&models.Lesson{}).
+			Where("deleted_at > ? AND course_id IN (?)", tc.purgeCutoff(), courses.Select("id").Where("deleted_at IS NULL"))
+	case trashQuestion:
+		query = tc.db(c).Unscoped().Model(&models.TestQuestion{}).
+			Where("deleted_at IS NOT NULL AND test_id IN (?)", tests.Select("id").Where("deleted_at IS NULL"))
+	default:
+		return utils.NotFound(c, "Unknown content kind")
+	}
+
+	var total int64
+	query.Count(&total)
+	var rows []map[string]interface{}
+	if err := query.Select("id", "title", "deleted_at").Order("deleted_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&rows).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch deleted content")
+	}
+	items := make([]fiber.Map, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, fiber.Map(row))
+	}
+	return utils.Paginate(c, items, total, page, pageSize)
+}
+
+// RestoreTrash восстанавливает удаленный курс, урок, тест или вопрос вместе с удаленными с ним данными
+func (tc *TrashController) RestoreTrash(c *fiber.Ctx) error {
+	if _, err := utils.ExtractUserIDFromToken(c, tc.Cfg); err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid ID")
+	}
+
+	switch c.Params("kind") {
+	case trashCourse:
+		err = tc.restoreCourse(c, uint(id))
+	case trashLesson:
+		err = tc.restoreLesson(c, uint(id))
+	case trashTest:
+		err = tc.restoreTest(c, uint(id))
+	case trashQuestion:
+		err = tc.restoreQuestion(c, uint(id))
+	default:
+		return utils.NotFound(c, "Unknown content kind")
+	}
+
+	switch {
+	case errors.Is(err, errTrashNotFound):
+		return utils.NotFound(c, err.Error())
+	case err != nil && policy.Status(err) != fiber.StatusInternalServerError:
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have permission to restore this content"))
+	case err != nil:
+		return utils.InternalServerError(c, "Could not restore content")
+	}
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"kind": c.Params("kind"), "id": id, "restored": true})
+}
+
+func (tc *TrashController) restoreCourse(c *fiber.Ctx, id uint) error {
+	var course models.Course
+	if err := tc.db(c).Unscoped().Where("id = ? AND deleted_at > ?", id, tc.purgeCutoff()).First(&course).Error; err != nil {
+		return errTrashNotFound
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return err
+	}
+
+	err := tc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := restoreCascade(tx, courseCascade, "course_id", id, course.DeletedAt.Time); err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&course).Update("deleted_at", nil).Error
+	})
+	if err == nil {
+		cache.Analytics.Invalidate(cache.Tag("course", id), "platform")
+	}
+	return err
+}
+
+func (tc *TrashController) restoreTest(c *fiber.Ctx, id uint) error {
+	var test models.Test
+	if err := tc.db(c).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&test).Error; err != nil {
+		return errTrashNotFound
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return err
+	}
+
+	err := tc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := restoreCascade(tx, testCascade, "test_id", id, test.DeletedAt.Time); err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&test).Update("deleted_at", nil).Error
+	})
+	if err == nil {
+		cache.Analytics.Invalidate(cache.Tag("test", id), "platform")
+	}
+	return err
+}
+
+// restoreLesson возвращает урок в курс; урок, удаленный вместе с курсом, восстанавливается только с ним
+func (tc *TrashController) restoreLesson(c *fiber.Ctx, id uint) error {
+	var lesson models.Lesson
+	if err := tc.db(c).Unscoped().Where("id = ? AND deleted_at > ?", id, tc.purgeCutoff()).First(&lesson).Error; err != nil {
+		return errTrashNotFound
+	}
+	var course models.Course
+	if err := tc.db(c).First(&course, lesson.CourseID).Error; err != nil {
+		return errTrashNotFound
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Course(&course)); err != nil {
+		return err
+	}
+
+	return tc.db(c).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"deleted_at": nil}
+		// The module may have been deleted in the meantime
+		if lesson.ModuleID != nil && lessonModule(tx, course.ID, *lesson.ModuleID) != nil {
+			updates["module_id"] = nil
+		}
+		if err := tx.Unscoped().Model(&lesson).Updates(updates).Error; err != nil {
+			return err
+		}
+		return refreshLessonProgress(tx, &course)
+	})
+}
+
+func (tc *TrashController) restoreQuestion(c *fiber.Ctx, id uint) error {
+	var question models.TestQuestion
+	if err := tc.db(c).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&question).Error; err != nil {
+		return errTrashNotFound
+	}
+	var test models.Test
+	if err := tc.db(c).First(&test, question.TestID).Error; err != nil {
+		return errTrashNotFound
+	}
+	if err := policy.Authorize(c, policy.ActionEdit, policy.Test(&test)); err != nil {
+		return err
+	}
+	return tc.db(c).Unscoped().Model(&question).Update("deleted_at", nil).Error
+}
+
+// purgeCutoff — удаленные раньше курсы и уроки уже не восстановить: их уроки удаляет PurgeTrashedLessons
+func (tc *TrashController) purgeCutoff() time.Time {
+	if tc.Cfg.TrashRetentionDays <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -tc.Cfg.TrashRetentionDays)
+}
+
+// deleteCascade мягко удаляет строки моделей, принадлежащие объекту, с отметкой at
+func deleteCascade(tx *gorm.DB, cascade []interface{}, column string, id uint, at time.Time) error {
+	for _, model := range cascade {
+		if err := tx.Model(model).Where(column+" = ?", id).Update("deleted_at", at).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreCascade восстанавливает строки, удаленные вместе с объектом в момент at
+func restoreCascade(tx *gorm.DB, cascade []interface{}, column string, id uint, at time.Time) error {
+	for _, model := range cascade {
+		if err := tx.Unscoped().Model(model).Where(column+" = ? AND deleted_at = ?", id, at).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshLessonProgress пересчитывает прогресс студентов после изменения числа уроков курса по его
+// правилам прохождения: итоговый тест и доля уроков учитываются так же, как при смене правил
+func refreshLessonProgress(tx *gorm.DB, course *models.Course) error {
+	if err := completion.RefreshCourse(tx, *course); err != nil {
+		return err
+	}
+	return grading.RefreshCourse(tx, course.ID)
+}
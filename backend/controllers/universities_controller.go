@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const affiliationTTL = 24 * time.Hour
+
+var (
+	errUnknownUniversity   = errors.New("University is not in the directory")
+	errAffiliationDomain   = errors.New("Email domain doesn't belong to this university")
+	errAffiliationInvalid  = errors.New("Invalid or expired verification token")
+	errUniversityInUse     = errors.New("University is referenced by users, groups or content")
+	errUniversityNameTaken = errors.New("Name or slug is already taken")
+)
+
+type UniversitiesController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewUniversitiesController(db *gorm.DB, cfg *config.Config) *UniversitiesController {
+	return &UniversitiesController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (uc *UniversitiesController) db(c *fiber.Ctx) *gorm.DB {
+	return uc.DB.WithContext(c.UserContext())
+}
+
+// GetUniversities возвращает справочник университетов (с поиском по ?search=)
+func (uc *UniversitiesController) GetUniversities(c *fiber.Ctx) error {
+	query := uc.db(c).Model(&models.University{})
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		query = query.Where("name ILIKE ? OR short_name ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	var universities []models.University
+	if err := query.Order("name").Find(&universities).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch universities")
+	}
+
+	result := make([]fiber.Map, 0, len(universities))
+	for _, university := range universities {
+		result = append(result, universityCard(university))
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// GetUniversity — страница университета: данные, число подтвержденных участников и опубликованный контент
+func (uc *UniversitiesController) GetUniversity(c *fiber.Ctx) error {
+	var university models.University
+	query := uc.db(c).Where("slug = ?", c.Params("slug"))
+	if id, err := strconv.Atoi(c.Params("slug")); err == nil {
+		query = uc.db(c).Where("id = ?", id)
+	}
+	if err := query.First(&university).Error; err != nil {
+		return utils.NotFound(c, "University not found")
+	}
+
+	var members, verified int64
+	uc.db(c).Model(&models.User{}).Where("university_id = ?", university.ID).Count(&members)
+	uc.db(c).Model(&models.User{}).
+		Where("university_id = ? AND university_verified_at IS NOT NULL", university.ID).Count(&verified)
+
+	var courses []models.Course
+	if err := uc.db(c).
+		Where("university_id = ?", university.ID).
+		Where("id IN (SELECT course_id FROM course_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Order("created_at DESC").Find(&courses).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch courses")
+	}
+
+	var tests []models.Test
+	if err := uc.db(c).
+		Where("university_id = ?", university.ID).
+		Where("id IN (SELECT test_id FROM test_access_settings WHERE access_level = 'public' AND deleted_at IS NULL)").
+		Order("created_at DESC").Find(&tests).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch tests")
+	}
+
+	courseCards := make([]fiber.Map, 0, len(courses))
+	for _, course := range courses {
+		courseCards = append(courseCards, fiber.Map{
+			"id":                 course.ID,
+			"title":              course.Title,
+			"short_desc":         course.ShortDesc,
+			"difficulty":         course.Difficulty,
+			"topic":              course.Topic,
+			"author":             course.AuthorID,
+			"logo_url":           course.LogoURL,
+			"license":            course.License,
+			"attribution":        course.Attribution,
+			"source_attribution": course.SourceAttribution,
+		})
+	}
+	testCards := make([]fiber.Map, 0, len(tests))
+	for _, test := range tests {
+		testCards = append(testCards, fiber.Map{
+			"id":          test.ID,
+			"title":       test.Title,
+			"short_desc":  test.ShortDesc,
+			"difficulty":  test.Difficulty,
+			"topic":       test.Topic,
+			"author":      test.AuthorID,
+			"logo_url":    test.LogoURL,
+			"license":     test.License,
+			"attribution": test.Attribution,
+		})
+	}
+
+	data := universityCard(university)
+	data["members"] = members
+	data["verified_members"] = verified
+	data["courses"] = courseCards
+	data["tests"] = testCards
+	return utils.Success(c, fiber.StatusOK, data)
+}
+
+// CreateUniversity добавляет университет в справочник
+func (uc *UniversitiesController) CreateUniversity(c *fiber.Ctx) error {
+	var input struct {
+		Name         string   `json:"name"`
+		Slug         string   `json:"slug"`
+		ShortName    string   `json:"short_name"`
+		Country      string   `json:"country"`
+		Website      string   `json:"website"`
+		LogoURL      string   `json:"logo_url"`
+		EmailDomains []string `json:"email_domains"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return utils.ValidationError(c, map[string]string{"name": "Name is required"})
+	}
+	if input.Slug == "" {
+		input.Slug = slugify(input.Name)
+	}
+
+	university := models.University{
+		Name:         input.Name,
+		Slug:         input.Slug,
+		ShortName:    input.ShortName,
+		Country:      input.Country,
+		Website:      input.Website,
+		LogoURL:      input.LogoURL,
+		EmailDomains: joinDomains(input.EmailDomains),
+	}
+	if err := uc.db(c).Create(&university).Error; err != nil {
+		return utils.BadRequest(c, errUniversityNameTaken.Error())
+	}
+
+	return utils.Created(c, universityCard(university))
+}
+
+// UpdateUniversity изменяет запись справочника; новое название переносится в пользователей и контент
+func (uc *UniversitiesController) UpdateUniversity(c *fiber.Ctx) error {
+	universityID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid university ID")
+	}
+
+	var input struct {
+		Name         utils.Optional[string]   `json:"name"`
+		Slug         utils.Optional[string]   `json:"slug"`
+		ShortName    utils.Optional[string]   `json:"short_name"`
+		Country      utils.Optional[string]   `json:"country"`
+		Website      utils.Optional[string]   `json:"website"`
+		LogoURL      utils.Optional[string]   `json:"logo_url"`
+		EmailDomains utils.Optional[[]string] `json:"email_domains"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var university models.University
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&university, universityID).Error; err != nil {
+			return err
+		}
+
+		oldName := university.Name
+		merge := utils.IsMergePatch(c)
+		input.Name.Apply(&university.Name, false)
+		input.Slug.Apply(&university.Slug, false)
+		input.ShortName.Apply(&university.ShortName, merge)
+		input.Country.Apply(&university.Country, merge)
+		input.Website.Apply(&university.Website, merge)
+		input.LogoURL.Apply(&university.LogoURL, merge)
+		var domains []string
+		if input.EmailDomains.Apply(&domains, true) {
+			university.EmailDomains = joinDomains(domains)
+		}
+
+		if err := tx.Save(&university).Error; err != nil {
+			return errUniversityNameTaken
+		}
+
+		// Keep the denormalized names in sync
+		if university.Name != oldName {
+			for _, model := range []interface{}{&models.User{}, &models.Course{}, &models.Test{}, &models.StudyGroup{}} {
+				if err := tx.Model(model).Where("university_id = ?", university.ID).
+					Update("university", university.Name).Error; err != nil {
+					return err
+				}
+			}
+			// Scoped roles are granted by name only, a stale name would leave them matching nothing
+			if err := tx.Model(&models.UserRole{}).Where("university = ?", oldName).
+				Update("university", university.Name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "University not found")
+	case errors.Is(err, errUniversityNameTaken):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not update university")
+	}
+
+	return utils.Success(c, fiber.StatusOK, universityCard(university))
+}
+
+// DeleteUniversity удаляет университет, на который никто не ссылается
+func (uc *UniversitiesController) DeleteUniversity(c *fiber.Ctx) error {
+	universityID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid university ID")
+	}
+
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		var university models.University
+		if err := tx.First(&university, universityID).Error; err != nil {
+			return err
+		}
+
+		var users, courses, tests, groups int64
+		tx.Model(&models.User{}).Where("university_id = ?", university.ID).Count(&users)
+		tx.Model(&models.Course{}).Where("university_id = ?", university.ID).Count(&courses)
+		tx.Model(&models.Test{}).Where("university_id = ?", university.ID).Count(&tests)
+		tx.Model(&models.StudyGroup{}).Where("university_id = ?", university.ID).Count(&groups)
+		if users+courses+tests+groups > 0 {
+			return errUniversityInUse
+		}
+
+		// Hard delete so the name and slug can be reused
+		return tx.Unscoped().Delete(&university).Error
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.NotFound(c, "University not found")
+	case errors.Is(err, errUniversityInUse):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not delete university")
+	}
+
+	return utils.NoContent(c)
+}
+
+// RequestAffiliation отправляет код подтверждения на институциональный email пользователя
+func (uc *UniversitiesController) RequestAffiliation(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		UniversityID uint   `json:"university_id"`
+		Email        string `json:"email"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	if !strings.Contains(input.Email, "@") {
+		return utils.ValidationError(c, map[string]string{"email": "Valid email is required"})
+	}
+
+	var university models.University
+	if err := uc.db(c).First(&university, input.UniversityID).Error; err != nil {
+		return utils.NotFound(c, "University not found")
+	}
+	if !emailDomainMatches(university, input.Email) {
+		return utils.ValidationError(c, map[string]string{"email": errAffiliationDomain.Error()})
+	}
+
+	token, err := generateInviteCode()
+	if err != nil {
+		return utils.InternalServerError(c, "Could not generate verification token")
+	}
+
+	verification := models.AffiliationVerification{
+		UserID:       userID,
+		UniversityID: university.ID,
+		Email:        input.Email,
+		TokenHash:    utils.HashAPIKey(token),
+		ExpiresAt:    time.Now().Add(affiliationTTL),
+	}
+
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&verification).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueEmail(tx, input.Email, "Confirm your affiliation with "+university.Name,
+			"Use this code to confirm your affiliation on Philosofium: "+token)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not start verification")
+	}
+
+	return utils.Success(c, fiber.StatusAccepted, fiber.Map{
+		"university_id": university.ID,
+		"email":         verification.Email,
+		"expires_at":    verification.ExpiresAt,
+	})
+}
+
+// VerifyAffiliation подтверждает принадлежность к университету по коду из письма
+func (uc *UniversitiesController) VerifyAffiliation(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var university models.University
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		var verification models.AffiliationVerification
+		if err := tx.Where("token_hash = ? AND user_id = ? AND verified_at IS NULL AND expires_at > ?",
+			utils.HashAPIKey(strings.TrimSpace(input.Token)), userID, time.Now()).
+			First(&verification).Error; err != nil {
+			return errAffiliationInvalid
+		}
+		if err := tx.First(&university, verification.UniversityID).Error; err != nil {
+			return errAffiliationInvalid
+		}
+
+		now := time.Now()
+		if err := tx.Model(&verification).Update("verified_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"university_id":          university.ID,
+			"university":             university.Name,
+			"university_email":       verification.Email,
+			"university_verified_at": now,
+		}).Error
+	})
+	if errors.Is(err, errAffiliationInvalid) {
+		return utils.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		return utils.InternalServerError(c, "Could not verify affiliation")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"university": universityCard(university),
+		"verified":   true,
+	})
+}
+
+// applyUniversity привязывает запись к университету из справочника.
+// university_id имеет приоритет; название (старый формат) должно совпадать с записью справочника.
+// Возвращает true, если университет изменился.
+func applyUniversity(db *gorm.DB, id utils.Optional[uint], name utils.Optional[string], merge bool,
+	universityID **uint, universityName *string) (bool, error) {
+	var university *models.University
+	switch {
+	case id.Set:
+		if id.Null || id.Value == 0 {
+			break
+		}
+		university = &models.University{}
+		if err := db.Select("id", "name").First(university, id.Value).Error; err != nil {
+			return false, errUnknownUniversity
+		}
+	case name.Set:
+		value := *universityName
+		if !name.Apply(&value, merge) {
+			return false, nil
+		}
+		if value == "" {
+			break
+		}
+		university = &models.University{}
+		if err := db.Select("id", "name").Where("LOWER(name) = LOWER(?)", strings.TrimSpace(value)).
+			First(university).Error; err != nil {
+			return false, errUnknownUniversity
+		}
+	default:
+		return false, nil
+	}
+
+	previous := derefUint(*universityID)
+	if university == nil {
+		*universityID, *universityName = nil, ""
+	} else {
+		*universityID, *universityName = &university.ID, university.Name
+	}
+	return previous != derefUint(*universityID), nil
+}
+
+// emailDomainMatches проверяет, что email принадлежит одному из доменов университета (или их поддоменам)
+func emailDomainMatches(university models.University, email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	for _, allowed := range strings.Split(university.EmailDomains, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed != "" && (domain == allowed || strings.HasSuffix(domain, "."+allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinDomains(domains []string) string {
+	cleaned := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			cleaned = append(cleaned, domain)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
+
+func universityCard(university models.University) fiber.Map {
+	domains := []string{}
+	if university.EmailDomains != "" {
+		domains = strings.Split(university.EmailDomains, ",")
+	}
+	return fiber.Map{
+		"id":            university.ID,
+		"name":          university.Name,
+		"slug":          university.Slug,
+		"short_name":    university.ShortName,
+		"country":       university.Country,
+		"website":       university.Website,
+		"logo_url":      university.LogoURL,
+		"email_domains": domains,
+	}
+}
@@ -1,17 +1,30 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"project/backend/config"
 	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/quota"
+	"project/backend/storage"
 	"project/backend/utils"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// emailChangeTTL — сколько действует код подтверждения нового email
+const emailChangeTTL = 24 * time.Hour
+
+var (
+	errEmailTaken         = errors.New("Email already taken")
+	errEmailChangeInvalid = errors.New("Invalid or expired confirmation code")
+)
+
 type UserController struct {
 	DB  *gorm.DB
 	Cfg *config.Config
@@ -21,6 +34,11 @@ func NewUserController(db *gorm.DB, cfg *config.Config) *UserController {
 	return &UserController{DB: db, Cfg: cfg}
 }
 
+// db возвращает подключение, привязанное к контексту запроса
+func (uc *UserController) db(c *fiber.Ctx) *gorm.DB {
+	return uc.DB.WithContext(c.UserContext())
+}
+
 // GetProfile возвращает профиль пользователя
 func (uc *UserController) GetProfile(c *fiber.Ctx) error {
 	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
@@ -28,35 +46,167 @@ func (uc *UserController) GetProfile(c *fiber.Ctx) error {
 		return utils.Unauthorized(c, "Unauthorized")
 	}
 
-	var user models.User
-	if err := uc.DB.First(&user, userID).Error; err != nil {
+	profile, err := loadProfile(uc.db(c), userID)
+	if err != nil {
 		return utils.NotFound(c, "User not found")
 	}
 
+	settings, err := utils.LoadUserSettings(uc.db(c), userID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch settings")
+	}
+
+	// ?view=public shows the profile the way other users and authors see it
+	if c.Query("view") == "public" {
+		public, err := publicProfile(uc.db(c), userID, false)
+		if err != nil {
+			return utils.NotFound(c, "User not found")
+		}
+		return utils.Success(c, fiber.StatusOK, applyPrivacy(public, settings))
+	}
+
+	profile["privacy"] = fiber.Map{
+		"hide_email":      settings.HideEmail,
+		"hide_university": settings.HideUniversity,
+		"private_profile": settings.PrivateProfile,
+	}
+	return utils.Success(c, fiber.StatusOK, profile)
+}
+
+// GetPublicProfile возвращает профиль пользователя для других пользователей с учетом его настроек приватности.
+// Сам пользователь и администраторы пользователей видят профиль целиком.
+func (uc *UserController) GetPublicProfile(c *fiber.Ctx) error {
+	viewerID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid user ID")
+	}
+
+	full := uint(userID) == viewerID || utils.HasPermission(uc.db(c), viewerID, models.PermUsersManage)
+	profile, err := publicProfile(uc.db(c), uint(userID), full)
+	if err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	if !full {
+		settings, err := utils.LoadUserSettings(uc.db(c), uint(userID))
+		if err != nil {
+			return utils.InternalServerError(c, "Failed to fetch settings")
+		}
+		profile = applyPrivacy(profile, settings)
+	}
+
+	return utils.Success(c, fiber.StatusOK, profile)
+}
+
+// publicProfile собирает то, что вообще может быть показано другим пользователям. Почта в него попадает
+// только с withEmail — для самого пользователя и администраторов, — даже если пользователь ее не скрывал.
+func publicProfile(db *gorm.DB, userID uint, withEmail bool) (fiber.Map, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var progress models.UserProgress
+	db.Where("user_id = ?", userID).First(&progress)
+
+	profile := fiber.Map{
+		"id":                  user.ID,
+		"username":            user.Username,
+		"group":               user.Group,
+		"group_id":            user.StudyGroupID,
+		"university":          user.University,
+		"university_id":       user.UniversityID,
+		"university_verified": user.UniversityVerifiedAt != nil,
+		"avatar_url":          user.AvatarURL,
+		"member_since":        user.CreatedAt,
+		"stats": fiber.Map{
+			"courses_completed": progress.CoursesCompleted,
+			"tests_completed":   progress.TestsCompleted,
+			"streak_days":       utils.CurrentStreak(progress, time.Now(), utils.UserLocation(db, userID)),
+		},
+	}
+	if withEmail {
+		profile["email"] = user.Email
+	}
+	return profile, nil
+}
+
+// profilePersonalFields — поля профиля, которые скрывает каждая из настроек приватности.
+// Учебная группа уходит вместе с университетом: по ней университет легко восстановить.
+var profilePersonalFields = map[string][]string{
+	"email":      {"email"},
+	"university": {"university", "university_id", "university_verified", "group", "group_id"},
+}
+
+// profilePrivateFields — что остается в закрытом профиле
+var profilePrivateFields = []string{"id", "username", "avatar_url"}
+
+// applyPrivacy убирает из профиля то, что пользователь скрыл от других
+func applyPrivacy(profile fiber.Map, settings models.UserSettings) fiber.Map {
+	if settings.PrivateProfile {
+		private := fiber.Map{"private": true}
+		for _, field := range profilePrivateFields {
+			if value, ok := profile[field]; ok {
+				private[field] = value
+			}
+		}
+		return private
+	}
+
+	hidden := map[string]bool{"email": settings.HideEmail, "university": settings.HideUniversity}
+	for setting, fields := range profilePersonalFields {
+		if !hidden[setting] {
+			continue
+		}
+		for _, field := range fields {
+			delete(profile, field)
+		}
+	}
+	return profile
+}
+
+// loadProfile собирает профиль пользователя без чувствительных данных
+func loadProfile(db *gorm.DB, userID uint) (fiber.Map, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
 	// Получаем прогресс пользователя
 	var progress models.UserProgress
-	uc.DB.Where("user_id = ?", userID).First(&progress)
+	db.Where("user_id = ?", userID).First(&progress)
 
 	// Получаем активные курсы
 	var activeCourses []models.UserCourseProgress
-	uc.DB.Preload("Course").
+	db.Preload("Course").
 		Where("user_id = ? AND completion_rate < 100", userID).
 		Order("updated_at DESC").
 		Limit(3).
 		Find(&activeCourses)
 
-	// Формируем ответ без чувствительных данных
-	return utils.Success(c, fiber.StatusOK, fiber.Map{
-		"id":             user.ID,
-		"username":       user.Username,
-		"email":          user.Email,
-		"role":           user.Role,
-		"group":          user.Group,
-		"university":     user.University,
-		"created_at":     user.CreatedAt,
-		"progress":       progress,
-		"active_courses": activeCourses,
-	})
+	return fiber.Map{
+		"id":                  user.ID,
+		"username":            user.Username,
+		"email":               user.Email,
+		"role":                user.Role,
+		"group":               user.Group,
+		"group_id":            user.StudyGroupID,
+		"university":          user.University,
+		"university_id":       user.UniversityID,
+		"university_verified": user.UniversityVerifiedAt != nil,
+		"avatar_url":          user.AvatarURL,
+		"created_at":          user.CreatedAt,
+		"progress":            progress,
+		"active_courses":      activeCourses,
+	}, nil
 }
 
 // UpdateProfile обновляет профиль пользователя
@@ -67,12 +217,14 @@ func (uc *UserController) UpdateProfile(c *fiber.Ctx) error {
 	}
 
 	var input struct {
-		Username    string `json:"username"`
-		Email       string `json:"email"`
-		OldPassword string `json:"old_password"`
-		NewPassword string `json:"new_password"`
-		Group       string `json:"group"`
-		University  string `json:"university"`
+		Username     string                 `json:"username"`
+		Email        string                 `json:"email"`
+		OldPassword  string                 `json:"old_password"`
+		NewPassword  string                 `json:"new_password"`
+		Group        utils.Optional[string] `json:"group"`
+		GroupID      utils.Optional[uint]   `json:"group_id"`
+		University   utils.Optional[string] `json:"university"`
+		UniversityID utils.Optional[uint]   `json:"university_id"`
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -80,7 +232,7 @@ func (uc *UserController) UpdateProfile(c *fiber.Ctx) error {
 	}
 
 	var user models.User
-	if err := uc.DB.First(&user, userID).Error; err != nil {
+	if err := uc.db(c).First(&user, userID).Error; err != nil {
 		return utils.NotFound(c, "User not found")
 	}
 
@@ -88,7 +240,7 @@ func (uc *UserController) UpdateProfile(c *fiber.Ctx) error {
 	if input.Username != "" && input.Username != user.Username {
 		// Проверяем, не занято ли имя
 		var existingUser models.User
-		if err := uc.DB.Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
+		if err := uc.db(c).Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
 			if existingUser.ID != user.ID {
 				return utils.BadRequest(c, "Username already taken")
 			}
@@ -96,16 +248,26 @@ func (uc *UserController) UpdateProfile(c *fiber.Ctx) error {
 		user.Username = input.Username
 	}
 
-	// Обновление email
-	if input.Email != "" && input.Email != user.Email {
-		// Проверяем, не занят ли email
-		var existingUser models.User
-		if err := uc.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
-			if existingUser.ID != user.ID {
-				return utils.BadRequest(c, "Email already taken")
-			}
+	// Новый email не применяется сразу: на него уходит код подтверждения (см. ConfirmEmailChange)
+	var emailChange *models.EmailChange
+	var emailToken string
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	if input.Email != "" && input.Email != strings.ToLower(user.Email) {
+		if !strings.Contains(input.Email, "@") {
+			return utils.ValidationError(c, map[string]string{"email": "Valid email is required"})
+		}
+		if emailTaken(uc.db(c), input.Email, user.ID) {
+			return utils.BadRequest(c, errEmailTaken.Error())
+		}
+		if emailToken, err = generateInviteCode(); err != nil {
+			return utils.InternalServerError(c, "Could not generate confirmation code")
+		}
+		emailChange = &models.EmailChange{
+			UserID:    user.ID,
+			NewEmail:  input.Email,
+			TokenHash: utils.HashAPIKey(emailToken),
+			ExpiresAt: time.Now().Add(emailChangeTTL),
 		}
-		user.Email = input.Email
 	}
 
 	// Обновление пароля
@@ -115,34 +277,138 @@ func (uc *UserController) UpdateProfile(c *fiber.Ctx) error {
 		}
 
 		// Проверяем старый пароль
-		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.OldPassword)); err != nil {
+		if ok, _ := utils.Passwords.Verify(input.OldPassword, user.PasswordHash); !ok {
 			return utils.Unauthorized(c, "Invalid old password")
 		}
 
 		// Хешируем новый пароль
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+		hashedPassword, err := utils.Passwords.Hash(input.NewPassword)
 		if err != nil {
 			return utils.InternalServerError(c, "Could not hash password")
 		}
-		user.PasswordHash = string(hashedPassword)
+		user.PasswordHash = hashedPassword
+		// Старые токены перестают действовать после смены пароля
+		user.TokenVersion++
 	}
 
-	// Обновление группы и университета
-	if input.Group != "" {
-		user.Group = input.Group
+	// Обновление группы и университета (PATCH позволяет их очистить)
+	merge := utils.IsMergePatch(c)
+	changed, err := applyUniversity(uc.db(c), input.UniversityID, input.University, merge, &user.UniversityID, &user.University)
+	if err != nil {
+		return utils.ValidationError(c, map[string]string{"university": err.Error()})
+	}
+	// A new university has to be verified again
+	if changed {
+		user.UniversityVerifiedAt, user.UniversityEmail = nil, ""
 	}
-	if input.University != "" {
-		user.University = input.University
+	previousGroup := user.StudyGroupID
+	groupChanged, err := applyGroup(uc.db(c), input.GroupID, input.Group, merge, user.UniversityID, &user.StudyGroupID, &user.Group)
+	if err != nil {
+		return utils.ValidationError(c, map[string]string{"group": err.Error()})
 	}
 
 	// Сохраняем изменения
-	if err := uc.DB.Save(&user).Error; err != nil {
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if emailChange != nil {
+			// Only the latest requested address can be confirmed
+			if err := tx.Unscoped().Where("user_id = ? AND confirmed_at IS NULL", user.ID).
+				Delete(&models.EmailChange{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(emailChange).Error; err != nil {
+				return err
+			}
+			if err := outbox.EnqueueEmail(tx, emailChange.NewEmail, "Confirm your new email on Philosofium",
+				"Use this code to confirm your new email address: "+emailToken); err != nil {
+				return err
+			}
+		}
+		if !groupChanged {
+			return nil
+		}
+		return setPrimaryGroup(tx, user.ID, previousGroup, user.StudyGroupID, user.Group, user.ID)
+	})
+	if err != nil {
 		return utils.InternalServerError(c, "Could not update user")
 	}
 
-	return utils.Success(c, fiber.StatusOK, fiber.Map{
+	response := fiber.Map{
 		"message": "Profile updated successfully",
+	}
+	if emailChange != nil {
+		response["pending_email"] = emailChange.NewEmail
+		response["pending_email_expires_at"] = emailChange.ExpiresAt
+	}
+	if input.NewPassword != "" {
+		token, err := utils.GenerateJWTToken(&user, uc.Cfg)
+		if err != nil {
+			return utils.InternalServerError(c, "Could not generate token")
+		}
+		response["token"] = token
+	}
+
+	return utils.Success(c, fiber.StatusOK, response)
+}
+
+// ConfirmEmailChange меняет email по коду, отправленному на новый адрес, и уведомляет старый адрес
+func (uc *UserController) ConfirmEmailChange(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var change models.EmailChange
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ? AND user_id = ? AND confirmed_at IS NULL AND expires_at > ?",
+			utils.HashAPIKey(strings.TrimSpace(input.Token)), userID, time.Now()).
+			First(&change).Error; err != nil {
+			return errEmailChangeInvalid
+		}
+		// The address may have been registered by someone else in the meantime
+		if emailTaken(tx, change.NewEmail, userID) {
+			return errEmailTaken
+		}
+
+		var user models.User
+		if err := tx.Select("id", "email").First(&user, userID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&change).Update("confirmed_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("email", change.NewEmail).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueEmail(tx, user.Email, "Your Philosofium email was changed",
+			"The email of your account was changed to "+change.NewEmail+
+				". If you didn't do this, contact support right away.")
 	})
+	switch {
+	case errors.Is(err, errEmailChangeInvalid), errors.Is(err, errEmailTaken):
+		return utils.BadRequest(c, err.Error())
+	case err != nil:
+		return utils.InternalServerError(c, "Could not change email")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"email": change.NewEmail})
+}
+
+// emailTaken проверяет, занят ли адрес другим пользователем
+func emailTaken(db *gorm.DB, email string, userID uint) bool {
+	var count int64
+	db.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", email, userID).Count(&count)
+	return count > 0
 }
 
 func (uc *UserController) GetUserCourses(c *fiber.Ctx) error {
@@ -161,9 +427,20 @@ func (uc *UserController) GetUserCourses(c *fiber.Ctx) error {
 	if pageSize < 1 {
 		pageSize = 10
 	}
+
+	courses, total, err := listUserCourses(uc.db(c), userID, status, search, page, pageSize)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch progress data")
+	}
+
+	return utils.Paginate(c, courses, total, page, pageSize)
+}
+
+// listUserCourses возвращает страницу курсов пользователя с прогрессом
+func listUserCourses(db *gorm.DB, userID uint, status, search string, page, pageSize int) ([]map[string]interface{}, int64, error) {
 	offset := (page - 1) * pageSize
 
-	query := uc.DB.Model(&models.UserCourseProgress{}).Where("user_id = ?", userID)
+	query := db.Model(&models.UserCourseProgress{}).Where("user_id = ?", userID)
 
 	switch status {
 	case "in_progress":
@@ -182,18 +459,18 @@ func (uc *UserController) GetUserCourses(c *fiber.Ctx) error {
 
 	var progresses []models.UserCourseProgress
 	if err := query.Offset(offset).Limit(pageSize).Find(&progresses).Error; err != nil {
-		return utils.InternalServerError(c, "Failed to fetch progress data")
+		return nil, 0, err
 	}
 
 	var courses []map[string]interface{}
 	for _, progress := range progresses {
 		var course models.Course
-		if err := uc.DB.Where("id = ?", progress.CourseID).First(&course).Error; err != nil {
+		if err := db.Where("id = ?", progress.CourseID).First(&course).Error; err != nil {
 			continue // если курс не найден — пропускаем
 		}
 
 		var lessonCount int64
-		uc.DB.Model(&models.Lesson{}).Where("course_id = ?", course.ID).Count(&lessonCount)
+		db.Model(&models.Lesson{}).Where("course_id = ?", course.ID).Count(&lessonCount)
 
 		courses = append(courses, map[string]interface{}{
 			"id":            course.ID,
@@ -207,7 +484,7 @@ func (uc *UserController) GetUserCourses(c *fiber.Ctx) error {
 		})
 	}
 
-	return utils.Paginate(c, courses, total, page, pageSize)
+	return courses, total, nil
 }
 
 func (uc *UserController) GetUserTests(c *fiber.Ctx) error {
@@ -226,9 +503,20 @@ func (uc *UserController) GetUserTests(c *fiber.Ctx) error {
 	if pageSize < 1 {
 		pageSize = 10
 	}
+
+	tests, total, err := listUserTests(uc.db(c), userID, status, search, page, pageSize)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch tests")
+	}
+
+	return utils.Paginate(c, tests, total, page, pageSize)
+}
+
+// listUserTests возвращает страницу тестов пользователя с результатами
+func listUserTests(db *gorm.DB, userID uint, status, search string, page, pageSize int) ([]map[string]interface{}, int64, error) {
 	offset := (page - 1) * pageSize
 
-	query := uc.DB.Model(&models.UserTestProgress{}).Where("user_id = ?", userID)
+	query := db.Model(&models.UserTestProgress{}).Where("user_id = ?", userID)
 
 	switch status {
 	case "in_progress":
@@ -247,13 +535,13 @@ func (uc *UserController) GetUserTests(c *fiber.Ctx) error {
 
 	var progresses []models.UserTestProgress
 	if err := query.Offset(offset).Limit(pageSize).Find(&progresses).Error; err != nil {
-		return utils.InternalServerError(c, "Failed to fetch tests")
+		return nil, 0, err
 	}
 
 	var tests []map[string]interface{}
 	for _, progress := range progresses {
 		var test models.Test
-		if err := uc.DB.Where("id = ?", progress.TestID).First(&test).Error; err != nil {
+		if err := db.Where("id = ?", progress.TestID).First(&test).Error; err != nil {
 			continue // если тест не найден — пропускаем
 		}
 
@@ -268,7 +556,7 @@ func (uc *UserController) GetUserTests(c *fiber.Ctx) error {
 		})
 	}
 
-	return utils.Paginate(c, tests, total, page, pageSize)
+	return tests, total, nil
 }
 
 // GetUserActivity возвращает активность пользователя
@@ -280,17 +568,31 @@ func (uc *UserController) GetUserActivity(c *fiber.Ctx) error {
 
 	// Параметры периода
 	days, _ := strconv.Atoi(c.Query("days", "7")) // По умолчанию за последние 7 дней
+	if days < 1 {
+		days = 7
+	}
+
+	// Дни считаются в часовом поясе пользователя: период начинается с его
+	// локальной полуночи и включает сегодняшний день
+	loc := utils.UserLocation(uc.db(c), userID)
+	now := time.Now()
+	since := utils.LocalDayStart(now, loc).AddDate(0, 0, -(days - 1))
 
 	// Получаем историю входов
 	var logins []models.LoginHistory
-	if err := uc.DB.Where("user_id = ? AND login_time >= ?",
-		userID, time.Now().AddDate(0, 0, -days)).
+	if err := uc.db(c).Where("user_id = ? AND login_time >= ? AND success", userID, since).
 		Order("login_time DESC").
 		Find(&logins).Error; err != nil {
 		return utils.InternalServerError(c, "Failed to fetch login history")
 	}
 
-	// Получаем активность по курсам
+	// Серия пересчитывается на лету: фоновый сброс мог еще не дойти до пользователя
+	var progress models.UserProgress
+	uc.db(c).Where("user_id = ?", userID).First(&progress)
+	streak := utils.CurrentStreak(progress, now, loc)
+
+	// Получаем активность по курсам. updated_at хранится в UTC без пояса: сначала помечаем его как UTC,
+	// затем переводим в пояс пользователя
 	var courseActivity []struct {
 		Date    string  `json:"date"`
 		Courses int     `json:"courses"`
@@ -298,17 +600,17 @@ func (uc *UserController) GetUserActivity(c *fiber.Ctx) error {
 		Hours   float64 `json:"hours"`
 	}
 
-	uc.DB.Raw(`
+	uc.db(c).Raw(`
 		SELECT 
-			DATE(updated_at) as date,
+			DATE(timezone(?, timezone('UTC', updated_at))) as date,
 			COUNT(DISTINCT course_id) as courses,
 			SUM(lessons_completed) as lessons,
 			SUM(hours_spent) as hours
 		FROM user_course_progress
 		WHERE user_id = ? AND updated_at >= ?
-		GROUP BY DATE(updated_at)
+		GROUP BY 1
 		ORDER BY date DESC
-	`, userID, time.Now().AddDate(0, 0, -days)).Scan(&courseActivity)
+	`, loc.String(), userID, since).Scan(&courseActivity)
 
 	// Получаем активность по тестам
 	var testActivity []struct {
@@ -318,22 +620,365 @@ func (uc *UserController) GetUserActivity(c *fiber.Ctx) error {
 		AvgScore float64 `json:"avg_score"`
 	}
 
-	uc.DB.Raw(`
+	uc.db(c).Raw(`
 		SELECT 
-			DATE(updated_at) as date,
+			DATE(timezone(?, timezone('UTC', updated_at))) as date,
 			COUNT(DISTINCT test_id) as tests,
 			SUM(attempts_used) as attempts,
 			AVG(score) as avg_score
 		FROM user_test_progress
 		WHERE user_id = ? AND updated_at >= ?
-		GROUP BY DATE(updated_at)
+		GROUP BY 1
 		ORDER BY date DESC
-	`, userID, time.Now().AddDate(0, 0, -days)).Scan(&testActivity)
+	`, loc.String(), userID, since).Scan(&testActivity)
 
 	return utils.Success(c, fiber.StatusOK, fiber.Map{
 		"logins":          logins,
 		"course_activity": courseActivity,
 		"test_activity":   testActivity,
 		"period_days":     days,
+		"streak_days":     streak,
+		"active_today":    streak > 0 && utils.CalendarDaysBetween(progress.LastActive, now, loc) == 0,
+		"timezone":        loc.String(),
+	})
+}
+
+// GetActivityHistory возвращает историю входов пользователя с keyset-пагинацией
+func (uc *UserController) GetActivityHistory(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	cursor, limit, err := utils.ParseCursorParams(c)
+	if err != nil {
+		return utils.BadRequest(c, "Invalid cursor")
+	}
+
+	var logins []models.LoginHistory
+	query := uc.db(c).Where("user_id = ? AND success", userID)
+	if err := utils.ApplyCursor(query, "login_time", "id", cursor, limit).Find(&logins).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch login history")
+	}
+
+	logins, next := utils.TrimPage(logins, limit, func(login models.LoginHistory) (time.Time, uint) {
+		return login.LoginTime, login.ID
+	})
+
+	return utils.PaginateCursor(c, logins, next, limit)
+}
+
+// GetUsage возвращает использование мягких лимитов автора (место под файлы уроков, курсы, вопросы)
+// и пороги, на которых приходят предупреждения
+func (uc *UserController) GetUsage(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	usage, err := quota.Current(uc.db(c), uc.Cfg, userID)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch usage")
+	}
+	return utils.Success(c, fiber.StatusOK, usage, fiber.Map{
+		"warn_percents": quota.Thresholds(uc.Cfg),
+	})
+}
+
+// searchGroupLimit — сколько совпадений отдается в каждой группе результатов поиска
+const searchGroupLimit = 20
+
+// SearchEnrolledContent ищет только по курсам, на которые записан пользователь, и тестам,
+// которые он проходил: названия, содержимое уроков, вопросы и собственные комментарии.
+// В отличие от /api/overview/courses, публичный каталог не затрагивается.
+func (uc *UserController) SearchEnrolledContent(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	if len([]rune(q)) < 2 {
+		return utils.ValidationError(c, map[string]string{"q": "Search query must be at least 2 characters"})
+	}
+	// % and _ typed by the user are matched literally
+	pattern := utils.ContainsPattern(q)
+
+	db := uc.db(c)
+	enrolledCourses := db.Model(&models.UserCourseProgress{}).Select("course_id").Where("user_id = ?", userID)
+	attemptedTests := db.Model(&models.UserTestProgress{}).Select("test_id").Where("user_id = ?", userID)
+
+	var courses []struct {
+		ID        uint   `json:"id"`
+		Title     string `json:"title"`
+		ShortDesc string `json:"short_desc"`
+	}
+	if err := db.Model(&models.Course{}).
+		Select("id, title, short_desc").
+		Where("id IN (?)", enrolledCourses).
+		Where(`title ILIKE ? ESCAPE '\' OR short_desc ILIKE ? ESCAPE '\' OR description ILIKE ? ESCAPE '\'`, pattern, pattern, pattern).
+		Order("title").Limit(searchGroupLimit).
+		Scan(&courses).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to search courses")
+	}
+
+	var lessons []struct {
+		ID          uint   `json:"id"`
+		CourseID    uint   `json:"course_id"`
+		CourseTitle string `json:"course_title"`
+		Title       string `json:"title"`
+	}
+	if err := db.Table("lessons AS l").
+		Select("l.id, l.course_id, c.title AS course_title, l.title").
+		Joins("JOIN courses c ON c.id = l.course_id").
+		Where("l.deleted_at IS NULL AND l.course_id IN (?)", enrolledCourses).
+		Where(`l.title ILIKE ? ESCAPE '\' OR l.description ILIKE ? ESCAPE '\' OR l.content ILIKE ? ESCAPE '\'`, pattern, pattern, pattern).
+		Order("l.course_id, l.sequence_order").Limit(searchGroupLimit).
+		Scan(&lessons).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to search lessons")
+	}
+
+	var tests []struct {
+		ID        uint   `json:"id"`
+		Title     string `json:"title"`
+		ShortDesc string `json:"short_desc"`
+	}
+	if err := db.Model(&models.Test{}).
+		Select("id, title, short_desc").
+		Where("id IN (?)", attemptedTests).
+		Where(`title ILIKE ? ESCAPE '\' OR short_desc ILIKE ? ESCAPE '\' OR description ILIKE ? ESCAPE '\' OR id IN (?)`, pattern, pattern, pattern,
+			db.Model(&models.TestQuestion{}).Select("test_id").Where(`title ILIKE ? ESCAPE '\' OR question ILIKE ? ESCAPE '\'`, pattern, pattern)).
+		Order("title").Limit(searchGroupLimit).
+		Scan(&tests).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to search tests")
+	}
+
+	var comments []struct {
+		ID        uint      `json:"id"`
+		CourseID  uint      `json:"course_id"`
+		Text      string    `json:"text"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	if err := db.Model(&models.CourseComment{}).
+		Select("id, course_id, text, created_at").
+		Where(`user_id = ? AND course_id IN (?) AND text ILIKE ? ESCAPE '\'`, userID, enrolledCourses, pattern).
+		Order("created_at DESC").Limit(searchGroupLimit).
+		Scan(&comments).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to search comments")
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"courses":  courses,
+		"lessons":  lessons,
+		"tests":    tests,
+		"comments": comments,
+	}, fiber.Map{"q": q, "limit": searchGroupLimit})
+}
+
+// UploadAvatar принимает изображение (multipart, поле "avatar"), обрезает его до квадрата,
+// уменьшает и сохраняет в хранилище. Старый аватар удаляется.
+func (uc *UserController) UploadAvatar(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		return utils.BadRequest(c, "Multipart field \"avatar\" is required")
+	}
+
+	maxBytes := int64(uc.Cfg.AvatarMaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = 2 << 20
+	}
+	if header.Size > maxBytes {
+		return utils.BadRequest(c, "Avatar is too large")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return utils.BadRequest(c, "Could not read upload")
+	}
+	defer file.Close()
+
+	data, tooLarge, err := utils.ReadLimited(file, maxBytes)
+	if err != nil {
+		return utils.BadRequest(c, "Could not read upload")
+	}
+	if tooLarge {
+		return utils.BadRequest(c, "Avatar is too large")
+	}
+
+	avatar, contentType, err := utils.ProcessAvatar(data)
+	if err != nil {
+		return utils.ValidationError(c, map[string]string{"avatar": err.Error()})
+	}
+
+	var user models.User
+	if err := uc.db(c).Select("id", "avatar_key").First(&user, userID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	ext := ".jpg"
+	if contentType == "image/png" {
+		ext = ".png"
+	}
+	// A fresh key per upload busts CDN and browser caches
+	key := fmt.Sprintf("avatars/%d-%d%s", userID, time.Now().UnixNano(), ext)
+
+	url, err := storage.Default.Put(c.UserContext(), key, avatar, contentType)
+	if err != nil {
+		return utils.InternalServerError(c, "Could not store avatar")
+	}
+
+	if err := setAvatar(uc.db(c), userID, url, key); err != nil {
+		storage.Default.Delete(c.UserContext(), key)
+		return utils.InternalServerError(c, "Could not update avatar")
+	}
+	if user.AvatarKey != "" {
+		storage.Default.Delete(c.UserContext(), user.AvatarKey)
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{"avatar_url": url})
+}
+
+// DeleteAvatar удаляет аватар пользователя
+func (uc *UserController) DeleteAvatar(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var user models.User
+	if err := uc.db(c).Select("id", "avatar_key").First(&user, userID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	if err := setAvatar(uc.db(c), userID, "", ""); err != nil {
+		return utils.InternalServerError(c, "Could not update avatar")
+	}
+	if user.AvatarKey != "" {
+		storage.Default.Delete(c.UserContext(), user.AvatarKey)
+	}
+
+	return utils.NoContent(c)
+}
+
+// setAvatar сохраняет аватар у пользователя и обновляет картинку во всех его комментариях
+func setAvatar(db *gorm.DB, userID uint, url, key string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"avatar_url": url, "avatar_key": key}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.CourseComment{}, &models.CourseCommentReply{},
+			&models.TestComment{}, &models.TestCommentReply{},
+		} {
+			if err := tx.Model(model).Where("user_id = ?", userID).Update("user_image", url).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deletedUserName подставляется вместо имени автора в комментариях удаленных аккаунтов
+const deletedUserName = "Deleted user"
+
+// DeleteAccount удаляет аккаунт текущего пользователя: комментарии анонимизируются, история входов
+// и ключи API удаляются, а сама запись окончательно стирается после AccountDeletionGraceDays
+func (uc *UserController) DeleteAccount(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, uc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.BadRequest(c, "Cannot parse JSON")
+	}
+
+	var user models.User
+	if err := uc.db(c).First(&user, userID).Error; err != nil {
+		return utils.NotFound(c, "User not found")
+	}
+
+	// Deleting the account is irreversible for the user, so the password is asked again
+	if ok, _ := utils.Passwords.Verify(input.Password, user.PasswordHash); !ok {
+		return utils.Unauthorized(c, "Invalid password")
+	}
+	if utils.UnderLegalHold(uc.db(c), models.HoldSubjectUser, user.ID) {
+		return utils.LegalHoldConflict(c)
+	}
+
+	purgeAfter := time.Now().AddDate(0, 0, uc.Cfg.AccountDeletionGraceDays)
+	err = uc.db(c).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&models.CourseComment{}, &models.CourseCommentReply{},
+			&models.TestComment{}, &models.TestCommentReply{},
+		} {
+			if err := tx.Model(model).Where("user_id = ?", userID).
+				Updates(map[string]interface{}{"user_name": deletedUserName, "user_image": ""}).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, model := range []interface{}{
+			&models.LoginHistory{}, &models.ApiKey{}, &models.AffiliationVerification{},
+			&models.UserActivity{}, &models.UserProgressSnapshot{}, &models.EmailChange{},
+			&models.Bookmark{}, &models.AttemptAnswer{}, &models.CourseWaitlist{},
+			&models.QuotaWarning{},
+			&models.CircleBadge{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("follower_id = ? OR author_id = ?", userID, userID).
+			Delete(&models.UserFollow{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("mentor_id = ? OR student_id = ?", userID, userID).
+			Delete(&models.MentorLink{}).Error; err != nil {
+			return err
+		}
+		// Circles the user owned get a new owner
+		var memberships []models.StudyCircleMember
+		if err := tx.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+			return err
+		}
+		for _, membership := range memberships {
+			circle := models.StudyCircle{Model: gorm.Model{ID: membership.CircleID}}
+			if err := leaveCircle(tx, &circle, &membership); err != nil {
+				return err
+			}
+		}
+
+		// Bumping the token version signs the user out everywhere
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"purge_after":   purgeAfter,
+			"avatar_url":    "",
+			"avatar_key":    "",
+			"token_version": gorm.Expr("token_version + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not delete account")
+	}
+
+	if user.AvatarKey != "" {
+		storage.Default.Delete(c.UserContext(), user.AvatarKey)
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"message":     "Account deleted",
+		"purge_after": purgeAfter,
 	})
 }
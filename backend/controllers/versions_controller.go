@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"project/backend/blocks"
+	"project/backend/config"
+	"project/backend/markup"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"slices"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errVersionNotFound = errors.New("Version not found")
+
+type VersionsController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewVersionsController(db *gorm.DB, cfg *config.Config) *VersionsController {
+	return &VersionsController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (vc *VersionsController) db(c *fiber.Ctx) *gorm.DB {
+	return vc.DB.WithContext(c.UserContext())
+}
+
+// courseSnapshot — содержимое курса на момент публикации
+type courseSnapshot struct {
+	Title       string           `json:"title"`
+	ShortDesc   string           `json:"short_desc"`
+	Description string           `json:"description"`
+	Modules     []snapshotModule `json:"modules"`
+	Lessons     []snapshotLesson `json:"lessons"`
+}
+
+type snapshotModule struct {
+	ID            uint   `json:"id"`
+	Title         string `json:"title"`
+	SequenceOrder int    `json:"sequence_order"`
+}
+
+type snapshotLesson struct {
+	ID            uint   `json:"id"`
+	ModuleID      *uint  `json:"module_id"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	Content       string `json:"content"`
+	SequenceOrder int    `json:"sequence_order"`
+}
+
+// versionChanges — что изменилось по сравнению с предыдущей версией
+type versionChanges struct {
+	CourseChanged  bool     `json:"course_changed"` // title or descriptions
+	LessonsAdded   []string `json:"lessons_added"`
+	LessonsRemoved []string `json:"lessons_removed"`
+	LessonsEdited  []string `json:"lessons_edited"`
+}
+
+// GetCourseVersions возвращает историю публикаций курса с описанием изменений
+func (vc *VersionsController) GetCourseVersions(c *fiber.Ctx) error {
+	course, done, err := vc.authorizedCourse(c, policy.ActionView)
+	if done {
+		return err
+	}
+
+	var versions []models.CourseVersion
+	if err := vc.db(c).Omit("snapshot").Where("course_id = ?", course.ID).Order("number DESC").Find(&versions).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch versions")
+	}
+
+	result := make([]fiber.Map, 0, len(versions))
+	for _, version := range versions {
+		result = append(result, versionPayload(version))
+	}
+	return utils.Success(c, fiber.StatusOK, result)
+}
+
+// GetCourseVersion возвращает версию вместе со снимком содержимого
+func (vc *VersionsController) GetCourseVersion(c *fiber.Ctx) error {
+	course, done, err := vc.authorizedCourse(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	version, err := findVersion(vc.db(c), course.ID, c.Params("number"))
+	if err != nil {
+		return utils.NotFound(c, err.Error())
+	}
+
+	var snapshot courseSnapshot
+	json.Unmarshal([]byte(version.Snapshot), &snapshot)
+	payload := versionPayload(*version)
+	payload["snapshot"] = snapshot
+	return utils.Success(c, fiber.StatusOK, payload)
+}
+
+// RollbackLessons возвращает урокам название, описание и текст из выбранной версии.
+// Без lesson_ids откатываются все уроки версии; уроки, которых в курсе уже нет, пропускаются.
+// Статус курса не меняется: чтобы студенты увидели откат, курс публикуется заново.
+func (vc *VersionsController) RollbackLessons(c *fiber.Ctx) error {
+	course, done, err := vc.authorizedCourse(c, policy.ActionEdit)
+	if done {
+		return err
+	}
+
+	var input struct {
+		LessonIDs []uint `json:"lesson_ids"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.BadRequest(c, "Cannot parse JSON")
+		}
+	}
+
+	version, err := findVersion(vc.db(c), course.ID, c.Params("number"))
+	if err != nil {
+		return utils.NotFound(c, err.Error())
+	}
+	var snapshot courseSnapshot
+	if err := json.Unmarshal([]byte(version.Snapshot), &snapshot); err != nil {
+		return utils.InternalServerError(c, "Version snapshot is corrupted")
+	}
+
+	restored, skipped := []uint{}, []uint{}
+	err = vc.db(c).Transaction(func(tx *gorm.DB) error {
+		for _, saved := range snapshot.Lessons {
+			if len(input.LessonIDs) > 0 && !slices.Contains(input.LessonIDs, saved.ID) {
+				continue
+			}
+			result := tx.Model(&models.Lesson{}).Where("id = ? AND course_id = ?", saved.ID, course.ID).Updates(map[string]interface{}{
+				"title":       saved.Title,
+				"description": saved.Description,
+				"content":     markup.Sanitize(saved.Content),
+				"markdown":    "",
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				skipped = append(skipped, saved.ID)
+				continue
+			}
+			// Versions keep the lesson as HTML, so the restored text replaces the blocks
+			if err := blocks.Reset(tx, saved.ID); err != nil {
+				return err
+			}
+			restored = append(restored, saved.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not roll back lessons")
+	}
+
+	// Requested lessons that the version doesn't contain
+	for _, id := range input.LessonIDs {
+		if !slices.ContainsFunc(snapshot.Lessons, func(l snapshotLesson) bool { return l.ID == id }) {
+			skipped = append(skipped, id)
+		}
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"version":  version.Number,
+		"restored": restored,
+		"skipped":  skipped,
+	})
+}
+
+// authorizedCourse загружает курс из :id и проверяет действие над ним
+func (vc *VersionsController) authorizedCourse(c *fiber.Ctx, action policy.Action) (*models.Course, bool, error) {
+	if _, err := utils.ExtractUserIDFromToken(c, vc.Cfg); err != nil {
+		return nil, true, utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, true, utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := vc.db(c).First(&course, courseID).Error; err != nil {
+		return nil, true, utils.NotFound(c, "Course not found")
+	}
+
+	if err := policy.Authorize(c, action, policy.Course(&course)); err != nil {
+		return nil, true, utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have access to the versions of this course"))
+	}
+	return &course, false, nil
+}
+
+func findVersion(db *gorm.DB, courseID uint, number string) (*models.CourseVersion, error) {
+	var version models.CourseVersion
+	if err := db.Where("course_id = ? AND number = ?", courseID, number).First(&version).Error; err != nil {
+		return nil, errVersionNotFound
+	}
+	return &version, nil
+}
+
+func versionPayload(version models.CourseVersion) fiber.Map {
+	var changes versionChanges
+	json.Unmarshal([]byte(version.Changes), &changes)
+	return fiber.Map{
+		"number":       version.Number,
+		"changelog":    version.Changelog,
+		"changes":      changes,
+		"published_by": version.PublishedBy,
+		"published_at": version.CreatedAt,
+	}
+}
+
+// createCourseVersion сохраняет снимок курса при публикации и сравнивает его с предыдущей версией
+func createCourseVersion(tx *gorm.DB, course *models.Course, userID uint, changelog string) (*models.CourseVersion, error) {
+	var modules []models.CourseModule
+	var lessons []models.Lesson
+	if err := tx.Where("course_id = ?", course.ID).Order("sequence_order, id").Find(&modules).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("course_id = ?", course.ID).Order("sequence_order, id").Find(&lessons).Error; err != nil {
+		return nil, err
+	}
+
+	snapshot := courseSnapshot{
+		Title:       course.Title,
+		ShortDesc:   course.ShortDesc,
+		Description: course.Description,
+		Modules:     make([]snapshotModule, 0, len(modules)),
+		Lessons:     make([]snapshotLesson, 0, len(lessons)),
+	}
+	for _, module := range modules {
+		snapshot.Modules = append(snapshot.Modules, snapshotModule{ID: module.ID, Title: module.Title, SequenceOrder: module.SequenceOrder})
+	}
+	for _, lesson := range lessons {
+		snapshot.Lessons = append(snapshot.Lessons, snapshotLesson{
+			ID:            lesson.ID,
+			ModuleID:      lesson.ModuleID,
+			Title:         lesson.Title,
+			Description:   lesson.Description,
+			Content:       lesson.Content,
+			SequenceOrder: lesson.SequenceOrder,
+		})
+	}
+
+	var previous models.CourseVersion
+	if err := tx.Where("course_id = ?", course.ID).Order("number DESC").Limit(1).Find(&previous).Error; err != nil {
+		return nil, err
+	}
+	var before courseSnapshot
+	if previous.ID != 0 {
+		json.Unmarshal([]byte(previous.Snapshot), &before)
+	}
+
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	changesJSON, err := json.Marshal(diffSnapshots(before, snapshot))
+	if err != nil {
+		return nil, err
+	}
+
+	version := models.CourseVersion{
+		CourseID:    course.ID,
+		Number:      previous.Number + 1,
+		Changelog:   changelog,
+		Changes:     string(changesJSON),
+		Snapshot:    string(snapshotJSON),
+		PublishedBy: userID,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// diffSnapshots сравнивает уроки двух снимков по ID
+func diffSnapshots(before, after courseSnapshot) versionChanges {
+	changes := versionChanges{
+		CourseChanged:  before.Title != after.Title || before.ShortDesc != after.ShortDesc || before.Description != after.Description,
+		LessonsAdded:   []string{},
+		LessonsRemoved: []string{},
+		LessonsEdited:  []string{},
+	}
+
+	old := make(map[uint]snapshotLesson, len(before.Lessons))
+	for _, lesson := range before.Lessons {
+		old[lesson.ID] = lesson
+	}
+	for _, lesson := range after.Lessons {
+		previous, ok := old[lesson.ID]
+		switch {
+		case !ok:
+			changes.LessonsAdded = append(changes.LessonsAdded, lesson.Title)
+		case previous.Title != lesson.Title || previous.Description != lesson.Description || previous.Content != lesson.Content:
+			changes.LessonsEdited = append(changes.LessonsEdited, lesson.Title)
+		}
+		delete(old, lesson.ID)
+	}
+	for _, lesson := range before.Lessons {
+		if _, ok := old[lesson.ID]; ok {
+			changes.LessonsRemoved = append(changes.LessonsRemoved, lesson.Title)
+		}
+	}
+	return changes
+}
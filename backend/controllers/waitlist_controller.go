@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
+	"project/backend/utils"
+	"project/backend/waitlist"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errCourseFull = errors.New("The course is full, join its waitlist to take the next free seat")
+
+type WaitlistController struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewWaitlistController(db *gorm.DB, cfg *config.Config) *WaitlistController {
+	return &WaitlistController{DB: db, Cfg: cfg}
+}
+
+// db возвращает подключение, привязанное к контексту запроса
+func (wc *WaitlistController) db(c *fiber.Ctx) *gorm.DB {
+	return wc.DB.WithContext(c.UserContext())
+}
+
+// Enroll записывает пользователя на курс. Если все места заняты, пользователь встает в очередь
+// и будет записан автоматически, когда место освободится. Платный курс при свободном месте
+// покупается через checkout.
+func (wc *WaitlistController) Enroll(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, wc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := wc.db(c).Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionView, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have access to this course"))
+	}
+	if err := restrictedCourseAccess(c, &course); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "This course is open only to users on its access list"))
+	}
+	if course.AccessSettings.ScheduleStatus(time.Now()) == models.ScheduleClosed {
+		return utils.Error(c, fiber.StatusForbidden, errors.New("The course has ended"))
+	}
+
+	var enrolled, position int64
+	seat := false
+	err = wc.db(c).Transaction(func(tx *gorm.DB) error {
+		// Concurrent requests for the last seat queue up on the course row
+		if err := waitlist.Lock(tx, course.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserCourseProgress{}).
+			Where("user_id = ? AND course_id = ?", userID, course.ID).Count(&enrolled).Error; err != nil || enrolled > 0 {
+			return err
+		}
+
+		var err error
+		if seat, err = waitlist.HasSeat(tx, course.AccessSettings, userID, time.Now()); err != nil {
+			return err
+		}
+		switch {
+		case !seat:
+			position, err = waitlist.Join(tx, course.ID, userID)
+			return err
+		case paymentRequired(c, &course, false):
+			return nil
+		}
+
+		// The progress row without LastAccessed enrolls; the first lesson starts the course
+		if err := tx.Create(&models.UserCourseProgress{UserID: userID, CourseID: course.ID}).Error; err != nil {
+			return err
+		}
+		return waitlist.Leave(tx, course.ID, userID)
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not enroll")
+	}
+
+	switch {
+	case enrolled > 0:
+		return utils.Success(c, fiber.StatusOK, fiber.Map{"enrolled": true, "waitlisted": false})
+	case !seat:
+		return utils.Success(c, fiber.StatusAccepted, fiber.Map{"enrolled": false, "waitlisted": true, "position": position})
+	case paymentRequired(c, &course, false):
+		return utils.Error(c, fiber.StatusPaymentRequired, errors.New("Buy the course to enroll"), fiber.Map{"price": pricePayload(course)})
+	}
+	return utils.Created(c, fiber.Map{"enrolled": true, "waitlisted": false})
+}
+
+// LeaveWaitlist убирает пользователя из очереди на курс; придержанное место отдается следующему
+func (wc *WaitlistController) LeaveWaitlist(c *fiber.Ctx) error {
+	userID, err := utils.ExtractUserIDFromToken(c, wc.Cfg)
+	if err != nil {
+		return utils.Unauthorized(c, "Unauthorized")
+	}
+
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := wc.db(c).Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+
+	err = wc.db(c).Transaction(func(tx *gorm.DB) error {
+		if err := waitlist.Lock(tx, course.ID); err != nil {
+			return err
+		}
+		if err := waitlist.Leave(tx, course.ID, userID); err != nil {
+			return err
+		}
+		_, err := waitlist.Promote(tx, course, time.Now())
+		return err
+	})
+	if err != nil {
+		return utils.InternalServerError(c, "Could not leave the waitlist")
+	}
+	return utils.NoContent(c)
+}
+
+// GetWaitlist — очередь на курс для его сотрудников: места, занятые места и ожидающие по порядку
+func (wc *WaitlistController) GetWaitlist(c *fiber.Ctx) error {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequest(c, "Invalid course ID")
+	}
+
+	var course models.Course
+	if err := wc.db(c).Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+		return utils.NotFound(c, "Course not found")
+	}
+	if err := policy.Authorize(c, policy.ActionViewAnalytics, policy.Course(&course)); err != nil {
+		return utils.Error(c, policy.Status(err), fiber.NewError(policy.Status(err), "You don't have access to this course's waitlist"))
+	}
+
+	now := time.Now()
+	taken, err := waitlist.Taken(wc.db(c), course.ID, now)
+	if err != nil {
+		return utils.InternalServerError(c, "Failed to fetch waitlist")
+	}
+
+	type entry struct {
+		UserID    uint       `json:"user_id"`
+		Username  string     `json:"username"`
+		JoinedAt  time.Time  `json:"joined_at"`
+		OfferedAt *time.Time `json:"offered_at"`
+	}
+	var entries []entry
+	if err := wc.db(c).Table("course_waitlists").
+		Select("course_waitlists.user_id, users.username, course_waitlists.created_at AS joined_at, course_waitlists.offered_at").
+		Joins("JOIN users ON users.id = course_waitlists.user_id").
+		Where("course_waitlists.course_id = ? AND course_waitlists.deleted_at IS NULL", course.ID).
+		Where("course_waitlists.offered_at IS NULL OR course_waitlists.offered_at > ?", now.Add(-waitlist.HoldPeriod)).
+		// Held seats first, then the queue in order
+		Order("course_waitlists.offered_at IS NULL, course_waitlists.created_at, course_waitlists.id").
+		Scan(&entries).Error; err != nil {
+		return utils.InternalServerError(c, "Failed to fetch waitlist")
+	}
+	if entries == nil {
+		entries = []entry{}
+	}
+
+	return utils.Success(c, fiber.StatusOK, fiber.Map{
+		"max_enrollments": course.AccessSettings.MaxEnrollments,
+		"taken":           taken,
+		"waiting":         entries,
+	})
+}
+
+// capacityPayload — места курса и место пользователя в очереди для карточки курса
+func capacityPayload(db *gorm.DB, course models.Course, userID uint) (fiber.Map, error) {
+	if course.AccessSettings.MaxEnrollments <= 0 {
+		return nil, nil
+	}
+	taken, err := waitlist.Taken(db, course.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	position, err := waitlist.Position(db, course.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{
+		"max_enrollments":   course.AccessSettings.MaxEnrollments,
+		"seats_left":        max(0, int64(course.AccessSettings.MaxEnrollments)-taken),
+		"waitlist_position": position,
+	}, nil
+}
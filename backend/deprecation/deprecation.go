@@ -0,0 +1,181 @@
+// Package deprecation ведет устаревшие маршруты API: те, что заменены новыми (конверт ответа, пагинация и т.п.)
+// и будут отключены. Вызовы таких маршрутов считаются по клиентам, чтобы до даты отключения было видно,
+// кто еще на них сидит. Счетчики, как и в пакете metrics, копятся в памяти экземпляра и пишутся в базу Flush.
+package deprecation
+
+import (
+	"context"
+	"project/backend/models"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notice — объявление об устаревании маршрута
+type Notice struct {
+	Since     time.Time `json:"since"`            // sent as the Deprecation header
+	Sunset    time.Time `json:"sunset,omitempty"` // after it the route answers 410 Gone; zero until a date is announced
+	Successor string    `json:"successor,omitempty"`
+}
+
+// Sunsetted сообщает, отключен ли маршрут к моменту now
+func (n Notice) Sunsetted(now time.Time) bool {
+	return !n.Sunset.IsZero() && !now.Before(n.Sunset)
+}
+
+type callKey struct {
+	route  string
+	client string
+	day    time.Time
+}
+
+type calls struct {
+	count     int64
+	userAgent string
+	last      time.Time
+}
+
+var (
+	mu      sync.Mutex
+	notices = map[string]Notice{}
+	pending = map[callKey]*calls{}
+)
+
+// Register запоминает объявление маршрута для отчета; route — "METHOD /path" в виде шаблона маршрута
+func Register(route string, notice Notice) {
+	mu.Lock()
+	notices[route] = notice
+	mu.Unlock()
+}
+
+// Record учитывает вызов маршрута клиентом; в базу вызовы попадают при Flush
+func Record(route, client, userAgent string, at time.Time) {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	key := callKey{route: route, client: client, day: at.UTC().Truncate(24 * time.Hour)}
+
+	mu.Lock()
+	defer mu.Unlock()
+	entry := pending[key]
+	if entry == nil {
+		entry = &calls{}
+		pending[key] = entry
+	}
+	entry.count++
+	entry.userAgent = userAgent
+	entry.last = at
+}
+
+// Flush добавляет накопленные вызовы к дневным счетчикам deprecated_route_calls
+func Flush(ctx context.Context, db *gorm.DB) error {
+	mu.Lock()
+	batch := pending
+	pending = map[callKey]*calls{}
+	mu.Unlock()
+
+	for key, entry := range batch {
+		err := db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "route"}, {Name: "client"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"calls":          gorm.Expr("deprecated_route_calls.calls + ?", entry.count),
+				"user_agent":     entry.userAgent,
+				"last_called_at": gorm.Expr("GREATEST(deprecated_route_calls.last_called_at, ?)", entry.last),
+				"updated_at":     time.Now(),
+			}),
+		}).Create(&models.DeprecatedRouteCall{
+			Route:        key.route,
+			Client:       key.client,
+			Day:          key.day,
+			Calls:        entry.count,
+			UserAgent:    entry.userAgent,
+			LastCalledAt: entry.last,
+		}).Error
+		if err != nil {
+			// Keep what wasn't written for the next flush
+			mu.Lock()
+			for key, entry := range batch {
+				if current := pending[key]; current != nil {
+					current.count += entry.count
+				} else {
+					pending[key] = entry
+				}
+			}
+			mu.Unlock()
+			return err
+		}
+		delete(batch, key)
+	}
+	return nil
+}
+
+// ClientUsage — вызовы маршрута одним клиентом за период отчета
+type ClientUsage struct {
+	Client       string    `json:"client"`
+	Calls        int64     `json:"calls"`
+	UserAgent    string    `json:"user_agent"`
+	LastCalledAt time.Time `json:"last_called_at"`
+}
+
+// RouteReport — устаревший маршрут и клиенты, которые вызывали его с начала периода
+type RouteReport struct {
+	Route string `json:"route"`
+	Notice
+	Calls   int64         `json:"calls"`
+	Clients []ClientUsage `json:"clients"`
+}
+
+// Report собирает вызовы устаревших маршрутов начиная с дня from; клиенты отсортированы по числу вызовов.
+// Объявленные маршруты попадают в отчет и без вызовов.
+func Report(db *gorm.DB, from time.Time) ([]RouteReport, error) {
+	var rows []struct {
+		Route        string
+		Client       string
+		Calls        int64
+		UserAgent    string
+		LastCalledAt time.Time
+	}
+	err := db.Model(&models.DeprecatedRouteCall{}).
+		Select(`route, client, SUM(calls) AS calls, MAX(last_called_at) AS last_called_at,
+			(ARRAY_AGG(user_agent ORDER BY last_called_at DESC))[1] AS user_agent`).
+		Where("day >= ?", from.UTC().Truncate(24*time.Hour)).
+		Group("route, client").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byRoute := map[string]*RouteReport{}
+	mu.Lock()
+	for route, notice := range notices {
+		byRoute[route] = &RouteReport{Route: route, Notice: notice, Clients: []ClientUsage{}}
+	}
+	mu.Unlock()
+
+	for _, row := range rows {
+		report := byRoute[row.Route]
+		if report == nil {
+			// Only called on other instances or before a restart so far
+			report = &RouteReport{Route: row.Route, Clients: []ClientUsage{}}
+			byRoute[row.Route] = report
+		}
+		report.Calls += row.Calls
+		report.Clients = append(report.Clients, ClientUsage{
+			Client:       row.Client,
+			Calls:        row.Calls,
+			UserAgent:    row.UserAgent,
+			LastCalledAt: row.LastCalledAt,
+		})
+	}
+
+	result := make([]RouteReport, 0, len(byRoute))
+	for _, report := range byRoute {
+		sort.Slice(report.Clients, func(i, j int) bool { return report.Clients[i].Calls > report.Clients[j].Calls })
+		result = append(result, *report)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Route < result[j].Route })
+	return result, nil
+}
@@ -77,15 +77,20 @@ const docTemplate = `{
             "description": "Standard error response format",
             "type": "object",
             "properties": {
+                "code": {
+                    "description": "Machine-readable error code",
+                    "type": "string",
+                    "example": "INVALID_CREDENTIALS"
+                },
                 "error": {
-                    "description": "Error message",
+                    "description": "HTTP status text",
                     "type": "string",
-                    "example": "Invalid credentials"
+                    "example": "Unauthorized"
                 },
                 "message": {
-                    "description": "Additional message",
+                    "description": "Human-readable message",
                     "type": "string",
-                    "example": "Authentication failed"
+                    "example": "Invalid credentials"
                 }
             }
         },
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer — сколько событий может накопиться у медленного подписчика;
+// дальше новые события для него отбрасываются, чтобы публикация не блокировалась
+const subscriberBuffer = 64
+
+// Event — сообщение, доставляемое подписчикам топика
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	At   time.Time   `json:"at"`
+}
+
+// Bus раздает события подписчикам своего экземпляра: доставка не блокируется и не ждет подписчиков.
+// События между экземплярами ходят через Postgres (Publish и Listen), Bus только получает их от Listen.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[string]map[chan Event]struct{})}
+}
+
+// Default — общая шина приложения
+var Default = NewBus()
+
+// Topic строит имя топика сущности, например test:42
+func Topic(entity string, id interface{}) string {
+	return fmt.Sprintf("%s:%v", entity, id)
+}
+
+// Subscribe подписывает на топик; вызовите возвращенную функцию, чтобы отписаться
+func (b *Bus) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[chan Event]struct{})
+	}
+	b.subs[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[topic], ch)
+			if len(b.subs[topic]) == 0 {
+				delete(b.subs, topic)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// deliver рассылает событие всем текущим подписчикам топика на этом экземпляре
+func (b *Bus) deliver(topic string, event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// channel — канал Postgres, через который экземпляры API обмениваются событиями
+const channel = "live_events"
+
+// listenRetry — пауза перед переподключением слушателя после обрыва соединения
+const listenRetry = 5 * time.Second
+
+// notification — событие вместе с топиком в полезной нагрузке NOTIFY
+type notification struct {
+	Topic string `json:"topic"`
+	Event Event  `json:"event"`
+}
+
+// Publish отправляет событие подписчикам топика на всех экземплярах через NOTIFY.
+// Внутри транзакции событие уходит только после ее фиксации и пропадает при откате.
+func Publish(db *gorm.DB, topic, eventType string, data interface{}) error {
+	payload, err := json.Marshal(notification{Topic: topic, Event: Event{Type: eventType, Data: data, At: time.Now()}})
+	if err != nil {
+		return err
+	}
+	return db.Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error
+}
+
+// Listen слушает канал событий на отдельном соединении и раздает события подписчикам bus,
+// пока не отменен ctx. После обрыва соединения переподключается; события, пришедшие в это время, теряются.
+func Listen(ctx context.Context, db *gorm.DB, bus *Bus, logger *log.Logger) {
+	for ctx.Err() == nil {
+		err := listen(ctx, db, bus)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Printf("event listener stopped, reconnecting: %v", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(listenRetry):
+		}
+	}
+}
+
+func listen(ctx context.Context, db *gorm.DB, bus *Bus) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+		// The connection goes back to the pool, it must not keep receiving events there
+		defer pgConn.Exec(context.Background(), "UNLISTEN "+channel)
+
+		for {
+			received, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			var msg notification
+			if err := json.Unmarshal([]byte(received.Payload), &msg); err != nil {
+				continue
+			}
+			bus.deliver(msg.Topic, msg.Event)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Presence отслеживает, кто сейчас работает с сущностью (например, проходит тест).
+// Отметки хранятся в базе, поэтому их видят все экземпляры API; отметка истекает через ttl,
+// если пользователь не завершил работу явно, а просроченные строки удаляет PurgePresence.
+type Presence struct {
+	ttl time.Duration
+}
+
+func NewPresence(ttl time.Duration) *Presence {
+	return &Presence{ttl: ttl}
+}
+
+// TestTakers — пользователи, открывшие тест и еще не отправившие ответы
+var TestTakers = NewPresence(time.Hour)
+
+// Touch отмечает пользователя в топике и возвращает текущее число присутствующих
+func (p *Presence) Touch(db *gorm.DB, topic string, userID uint) (int, error) {
+	mark := models.PresenceMark{Topic: topic, UserID: userID, ExpiresAt: time.Now().Add(p.ttl)}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "topic"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&mark).Error; err != nil {
+		return 0, err
+	}
+	return p.Count(db, topic)
+}
+
+// Leave снимает отметку и возвращает текущее число присутствующих
+func (p *Presence) Leave(db *gorm.DB, topic string, userID uint) (int, error) {
+	if err := db.Where("topic = ? AND user_id = ?", topic, userID).Delete(&models.PresenceMark{}).Error; err != nil {
+		return 0, err
+	}
+	return p.Count(db, topic)
+}
+
+// Count возвращает число присутствующих с непросроченной отметкой
+func (p *Presence) Count(db *gorm.DB, topic string) (int, error) {
+	var count int64
+	err := db.Model(&models.PresenceMark{}).Where("topic = ? AND expires_at > ?", topic, time.Now()).Count(&count).Error
+	return int(count), err
+}
+
+// PurgePresence удаляет просроченные отметки всех топиков
+func PurgePresence(db *gorm.DB, now time.Time) error {
+	return db.Where("expires_at <= ?", now).Delete(&models.PresenceMark{}).Error
+}
@@ -0,0 +1,182 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A4 landscape, in points
+const (
+	certificateWidth  = 842
+	certificateHeight = 595
+	certificateMargin = 70
+)
+
+// CertificateData — то, что печатается на сертификате о прохождении курса
+type CertificateData struct {
+	Holder     string
+	Course     string
+	University string
+	Serial     string
+	IssuedAt   time.Time
+	VerifyURL  string // where anyone can check the serial
+}
+
+// CertificatePDF рисует сертификат одной страницей PDF. Используются стандартные шрифты PDF,
+// поэтому файл не тянет за собой шрифтов; кириллица в них не входит и печатается латиницей
+// (на странице проверки имя и курс показаны как есть).
+func CertificatePDF(data CertificateData) []byte {
+	page := &pdfPage{}
+	page.rect(30, 30, certificateWidth-60, certificateHeight-60, 2)
+	page.rect(38, 38, certificateWidth-76, certificateHeight-76, 0.5)
+
+	y := 470.0
+	page.center("F2", 34, y, "Certificate of Completion")
+	y -= 55
+	page.center("F1", 14, y, "This certifies that")
+	y -= 45
+	page.center("F2", 28, y, data.Holder)
+	y -= 40
+	page.center("F1", 14, y, "has successfully completed the course")
+	y -= 38
+	for _, line := range wrapText(data.Course, 22, certificateWidth-2*certificateMargin) {
+		page.center("F2", 22, y, line)
+		y -= 28
+	}
+	if data.University != "" {
+		page.center("F1", 14, y, data.University)
+	}
+
+	page.text("F1", 11, certificateMargin, 95, "Issued on "+data.IssuedAt.Format("January 2, 2006"))
+	page.text("F1", 11, certificateMargin, 78, "Certificate No. "+data.Serial)
+	if data.VerifyURL != "" {
+		page.text("F1", 9, certificateMargin, 61, "Verify at "+data.VerifyURL)
+	}
+
+	return pdfDocument(page.content.String())
+}
+
+// pdfPage накапливает поток команд рисования одной страницы
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+func (p *pdfPage) rect(x, y, width, height, lineWidth float64) {
+	fmt.Fprintf(&p.content, "%.2f w %.2f %.2f %.2f %.2f re S\n", lineWidth, x, y, width, height)
+}
+
+func (p *pdfPage) text(font string, size, x, y float64, value string) {
+	fmt.Fprintf(&p.content, "BT /%s %.0f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(value))
+}
+
+func (p *pdfPage) center(font string, size, y float64, value string) {
+	p.text(font, size, (certificateWidth-textWidth(value, size))/2, y, value)
+}
+
+// pdfDocument собирает файл из одной страницы: каталог, страницы, шрифты, поток и таблицу смещений
+func pdfDocument(content string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>",
+			certificateWidth, certificateHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfString приводит текст к печатному ASCII и экранирует спецсимволы строки PDF
+func pdfString(value string) string {
+	var b strings.Builder
+	for _, r := range transliterate(value) {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('?')
+		}
+	}
+	return b.String()
+}
+
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+func transliterate(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		lower := []rune(strings.ToLower(string(r)))[0]
+		latin, ok := cyrillic[lower]
+		switch {
+		case !ok:
+			b.WriteRune(r)
+		case lower != r && latin != "":
+			b.WriteString(strings.ToUpper(latin[:1]) + latin[1:])
+		default:
+			b.WriteString(latin)
+		}
+	}
+	return b.String()
+}
+
+// helveticaWidths — ширины символов ' '..'~' шрифта Helvetica в тысячных долях кегля
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// textWidth оценивает ширину строки; жирное начертание чуть шире, это учтено запасом
+func textWidth(value string, size float64) float64 {
+	total := 0
+	for _, r := range transliterate(value) {
+		if r >= ' ' && r <= '~' {
+			total += helveticaWidths[r-' ']
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size * 1.05 / 1000
+}
+
+// wrapText разбивает строку по словам так, чтобы каждая строка помещалась в width
+func wrapText(value string, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(value) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if line != "" && textWidth(candidate, size) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	return append(lines, line)
+}
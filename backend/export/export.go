@@ -0,0 +1,178 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// chunkSize — сколько строк забирается из серверного курсора за один FETCH
+const chunkSize = 500
+
+// Progress вызывается после каждого чанка с количеством уже выгруженных строк
+type Progress func(processed int64)
+
+// Gradebook описывает выгрузку: заголовок CSV, запрос и разбор строки
+type Gradebook struct {
+	Header []string
+	Query  string
+	Args   []interface{}
+	Scan   func(rows *sql.Rows) ([]string, error)
+}
+
+// CourseGradebook — прогресс всех студентов курса
+func CourseGradebook(courseID uint) Gradebook {
+	return courseGradebook(courseID, nil)
+}
+
+// courseGradebook строит выгрузку курса; since оставляет только строки, изменившиеся после него
+func courseGradebook(courseID uint, since *time.Time) Gradebook {
+	query := `SELECT u.id, u.username, u.email, COALESCE(u."group", ''), COALESCE(u.university, ''),
+				p.lessons_completed, p.hours_spent, p.completion_rate, COALESCE(p.last_accessed, ''),
+				g.percent, COALESCE(g.letter, '')
+			FROM user_course_progress p
+			JOIN users u ON u.id = p.user_id
+			LEFT JOIN course_grades g ON g.course_id = p.course_id AND g.user_id = p.user_id AND g.deleted_at IS NULL
+			WHERE p.course_id = ? AND p.deleted_at IS NULL`
+	args := []interface{}{courseID}
+	if since != nil {
+		query += ` AND (p.updated_at > ? OR g.updated_at > ?)`
+		args = append(args, *since, *since)
+	}
+
+	return Gradebook{
+		Header: []string{"user_id", "username", "email", "group", "university",
+			"lessons_completed", "hours_spent", "completion_rate", "last_accessed", "final_grade", "letter_grade"},
+		Query: query + `
+			ORDER BY p.id`,
+		Args: args,
+		Scan: func(rows *sql.Rows) ([]string, error) {
+			var (
+				userID                       uint
+				username, email, group, univ string
+				lessons                      int
+				hours, completion            float64
+				lastAccessed, letter         string
+				grade                        sql.NullFloat64
+			)
+			if err := rows.Scan(&userID, &username, &email, &group, &univ,
+				&lessons, &hours, &completion, &lastAccessed, &grade, &letter); err != nil {
+				return nil, err
+			}
+			finalGrade := ""
+			if grade.Valid {
+				finalGrade = formatFloat(grade.Float64)
+			}
+			return []string{
+				strconv.FormatUint(uint64(userID), 10), username, email, group, univ,
+				strconv.Itoa(lessons), formatFloat(hours), formatFloat(completion), lastAccessed, finalGrade, letter,
+			}, nil
+		},
+	}
+}
+
+// TestGradebook — результаты всех студентов по тесту
+func TestGradebook(testID uint) Gradebook {
+	return Gradebook{
+		Header: []string{"user_id", "username", "email", "group", "university",
+			"questions_answered", "correct_answers", "score", "attempts_used", "last_attempt"},
+		Query: `SELECT u.id, u.username, u.email, COALESCE(u."group", ''), COALESCE(u.university, ''),
+				p.questions_answered, p.correct_answers, p.score, p.attempts_used, COALESCE(p.last_attempt, '')
+			FROM user_test_progress p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.test_id = ? AND p.deleted_at IS NULL
+			ORDER BY p.id`,
+		Args: []interface{}{testID},
+		Scan: func(rows *sql.Rows) ([]string, error) {
+			var (
+				userID                       uint
+				username, email, group, univ string
+				answered, correct, attempts  int
+				score                        float64
+				lastAttempt                  string
+			)
+			if err := rows.Scan(&userID, &username, &email, &group, &univ,
+				&answered, &correct, &score, &attempts, &lastAttempt); err != nil {
+				return nil, err
+			}
+			return []string{
+				strconv.FormatUint(uint64(userID), 10), username, email, group, univ,
+				strconv.Itoa(answered), strconv.Itoa(correct), formatFloat(score), strconv.Itoa(attempts), lastAttempt,
+			}, nil
+		},
+	}
+}
+
+// Count возвращает общее число строк выгрузки (для отчета о прогрессе)
+func (g Gradebook) Count(ctx context.Context, db *gorm.DB) (int64, error) {
+	var total int64
+	err := db.WithContext(ctx).Raw("SELECT COUNT(*) FROM ("+g.Query+") AS export", g.Args...).Scan(&total).Error
+	return total, err
+}
+
+// Stream пишет CSV в w, читая данные серверным курсором чанками по chunkSize строк,
+// поэтому в памяти никогда не держится весь результат.
+func (g Gradebook) Stream(ctx context.Context, db *gorm.DB, w io.Writer, progress Progress) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(g.Header); err != nil {
+		return err
+	}
+
+	// Cursors only live inside a transaction
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DECLARE gradebook_export NO SCROLL CURSOR FOR "+g.Query, g.Args...).Error; err != nil {
+			return err
+		}
+
+		var processed int64
+		for {
+			rows, err := tx.Raw(fmt.Sprintf("FETCH %d FROM gradebook_export", chunkSize)).Rows()
+			if err != nil {
+				return err
+			}
+
+			fetched := 0
+			for rows.Next() {
+				record, err := g.Scan(rows)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				if err := out.Write(record); err != nil {
+					rows.Close()
+					return err
+				}
+				fetched++
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			out.Flush()
+			if err := out.Error(); err != nil {
+				return err
+			}
+
+			processed += int64(fetched)
+			if progress != nil {
+				progress(processed)
+			}
+			if fetched < chunkSize {
+				break
+			}
+		}
+
+		return tx.Exec("CLOSE gradebook_export").Error
+	})
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
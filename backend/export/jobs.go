@@ -0,0 +1,176 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"project/backend/models"
+	"project/backend/storage"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobTimeout ограничивает одну выгрузку; выгрузка, которая выполняется дольше, считается прерванной
+const JobTimeout = 10 * time.Minute
+
+// FileTTL — сколько хранится файл готовой выгрузки; потом его удаляет ReapJobs
+const FileTTL = 24 * time.Hour
+
+// ClaimJob забирает самую старую ожидающую выгрузку и помечает ее выполняемой.
+// SKIP LOCKED не дает двум экземплярам взять одну выгрузку; nil — ждущих выгрузок нет.
+func ClaimJob(db *gorm.DB) (*models.ExportJob, error) {
+	var job models.ExportJob
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", models.ExportStatusPending).Order("id").First(&job).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		job.Status, job.StartedAt = models.ExportStatusRunning, &now
+		return tx.Model(&job).Select("status", "started_at").Updates(&job).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RunJob собирает выгрузку, забранную ClaimJob, и кладет файл в store. Ошибка сборки записывается
+// в саму выгрузку; возвращаются только ошибки записи ее состояния.
+func RunJob(ctx context.Context, db *gorm.DB, store storage.Storage, job *models.ExportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, JobTimeout)
+	defer cancel()
+	db = db.WithContext(ctx)
+
+	key, err := buildJob(ctx, db, store, job)
+	now := time.Now()
+	if err != nil {
+		return db.Model(job).Updates(map[string]interface{}{
+			"status":      models.ExportStatusFailed,
+			"error":       err.Error(),
+			"finished_at": now,
+		}).Error
+	}
+	expires := now.Add(FileTTL)
+	return db.Model(job).Updates(map[string]interface{}{
+		"status":      models.ExportStatusDone,
+		"file_key":    key,
+		"finished_at": now,
+		"expires_at":  expires,
+	}).Error
+}
+
+// buildJob пишет выгрузку в память, сохраняя прогресс после каждого чанка, и отдает ее в store
+func buildJob(ctx context.Context, db *gorm.DB, store storage.Storage, job *models.ExportJob) (string, error) {
+	ext, contentType := "zip", "application/zip"
+	var total int64
+	var write func(buf *bytes.Buffer, progress Progress) error
+	switch job.Kind {
+	case models.ExportKindUserData:
+		total = UserDataSections()
+		write = func(buf *bytes.Buffer, progress Progress) error {
+			return UserData(ctx, db, job.EntityID, buf, progress)
+		}
+	case models.ExportKindUniversity:
+		total = UniversitySections()
+		write = func(buf *bytes.Buffer, progress Progress) error {
+			return University(ctx, db, job.EntityID, buf, progress)
+		}
+	default:
+		gradebook, err := jobGradebook(db, job)
+		if err != nil {
+			return "", err
+		}
+		if total, err = gradebook.Count(ctx, db); err != nil {
+			return "", err
+		}
+		ext, contentType = "csv", "text/csv; charset=utf-8"
+		write = func(buf *bytes.Buffer, progress Progress) error {
+			return gradebook.Stream(ctx, db, buf, progress)
+		}
+	}
+	if err := db.Model(job).Update("total", total).Error; err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err := write(&buf, func(processed int64) {
+		db.Model(job).Update("processed", processed)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("exports/%d.%s", job.ID, ext)
+	if _, err := store.Put(ctx, key, buf.Bytes(), contentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// jobGradebook восстанавливает ведомость выгрузки: курс или тест, для курса — в колонках формата SIS
+func jobGradebook(db *gorm.DB, job *models.ExportJob) (Gradebook, error) {
+	switch {
+	case job.Kind == models.ExportKindTest:
+		return TestGradebook(job.EntityID), nil
+	case job.Kind != models.ExportKindCourse:
+		return Gradebook{}, fmt.Errorf("unknown export kind %q", job.Kind)
+	case job.Format == "":
+		return CourseGradebook(job.EntityID), nil
+	}
+
+	columns, ok := SISPresets[job.Format]
+	if job.Format == SISFormatCustom {
+		saved, err := SavedSISColumns(db, job.EntityID)
+		if err != nil {
+			return Gradebook{}, err
+		}
+		columns, ok = saved, len(saved) > 0
+	}
+	if !ok {
+		return Gradebook{}, fmt.Errorf("the course has no %s SIS columns", job.Format)
+	}
+	return SISGradebook(job.EntityID, columns, nil), nil
+}
+
+// ReapJobs завершает ошибкой выгрузки, прерванные падением экземпляра (дольше JobTimeout в работе),
+// и удаляет файлы выгрузок, срок хранения которых истек
+func ReapJobs(ctx context.Context, db *gorm.DB, store storage.Storage, now time.Time) error {
+	db = db.WithContext(ctx)
+
+	// A live job can't outrun its own timeout, so anything older was abandoned
+	err := db.Model(&models.ExportJob{}).
+		Where("status = ? AND started_at < ?", models.ExportStatusRunning, now.Add(-JobTimeout-time.Minute)).
+		Updates(map[string]interface{}{
+			"status":      models.ExportStatusFailed,
+			"error":       "The export was interrupted, start it again",
+			"finished_at": now,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	var expired []models.ExportJob
+	if err := db.Where("status = ? AND expires_at < ?", models.ExportStatusDone, now).Find(&expired).Error; err != nil {
+		return err
+	}
+	for i := range expired {
+		if err := store.Delete(ctx, expired[i].FileKey); err != nil {
+			return err
+		}
+		if err := db.Model(&expired[i]).Updates(map[string]interface{}{
+			"status":   models.ExportStatusExpired,
+			"file_key": "",
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Форматы выгрузки оценок для систем учета студентов (SIS)
+const (
+	SISFormatCanvas = "canvas"
+	SISFormatMoodle = "moodle"
+	SISFormatCustom = "custom" // columns come from the course's saved mapping
+)
+
+// Column — колонка выгрузки для SIS: заголовок и поле ведомости курса.
+// Пустое поле дает пустую колонку (например, ID, который SIS заполняет сама).
+type Column struct {
+	Header string `json:"header"`
+	Field  string `json:"field"`
+}
+
+// SISFields — поля ведомости курса, которые можно отобразить на колонки
+func SISFields() []string {
+	return CourseGradebook(0).Header
+}
+
+// SISPresets — колонки стандартных форматов импорта оценок
+var SISPresets = map[string][]Column{
+	// Canvas gradebook import: students are matched by SIS Login ID
+	SISFormatCanvas: {
+		{Header: "Student", Field: "username"},
+		{Header: "ID", Field: ""},
+		{Header: "SIS User ID", Field: "user_id"},
+		{Header: "SIS Login ID", Field: "email"},
+		{Header: "Section", Field: "group"},
+		{Header: "Final Score", Field: "final_grade"},
+		{Header: "Final Grade", Field: "letter_grade"},
+	},
+	// Moodle grade import: students are matched by email address
+	SISFormatMoodle: {
+		{Header: "Email address", Field: "email"},
+		{Header: "Username", Field: "username"},
+		{Header: "ID number", Field: "user_id"},
+		{Header: "Course total", Field: "final_grade"},
+		{Header: "Letter grade", Field: "letter_grade"},
+	},
+}
+
+// SISGradebook — ведомость курса в колонках SIS; since оставляет только изменившиеся
+// после него строки, чтобы ночная синхронизация не гоняла весь курс
+func SISGradebook(courseID uint, columns []Column, since *time.Time) Gradebook {
+	base := courseGradebook(courseID, since)
+	index := make(map[string]int, len(base.Header))
+	for i, field := range base.Header {
+		index[field] = i
+	}
+
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.Header)
+	}
+
+	return Gradebook{
+		Header: header,
+		Query:  base.Query,
+		Args:   base.Args,
+		Scan: func(rows *sql.Rows) ([]string, error) {
+			record, err := base.Scan(rows)
+			if err != nil {
+				return nil, err
+			}
+			mapped := make([]string, 0, len(columns))
+			for _, column := range columns {
+				value := ""
+				if i, ok := index[column.Field]; ok {
+					value = record[i]
+				}
+				mapped = append(mapped, value)
+			}
+			return mapped, nil
+		},
+	}
+}
+
+// SavedSISColumns возвращает колонки формата custom; пустой список, если курс их не задал
+func SavedSISColumns(db *gorm.DB, courseID uint) ([]Column, error) {
+	var mapping models.CourseSISMapping
+	err := db.Where("course_id = ?", courseID).First(&mapping).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return []Column{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := []Column{}
+	err = json.Unmarshal([]byte(mapping.Columns), &columns)
+	return columns, err
+}
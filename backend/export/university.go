@@ -0,0 +1,124 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// universitySections перечисляет данные организации для передачи при уходе с платформы.
+// Пользователи выгружаются только как участники: без email, истории входов и прочих персональных данных.
+var universitySections = []archiveSection{
+	{"university.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		var university models.University
+		err := db.First(&university, universityID).Error
+		return university, err
+	}},
+	{"courses.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		courses := []models.Course{}
+		err := db.Where("university_id = ?", universityID).
+			Preload("Modules").Preload("Lessons").Preload("AccessSettings").
+			Order("id").Find(&courses).Error
+		return courses, err
+	}},
+	{"course_surveys.json", byCourse[models.CourseSurvey]()},
+	{"course_grading.json", byCourse[models.CourseGradeComponent]()},
+	{"tests.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		tests := []models.Test{}
+		err := db.Where("university_id = ?", universityID).
+			Preload("Questions").Preload("AccessSettings").
+			Order("id").Find(&tests).Error
+		return tests, err
+	}},
+	{"groups.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		groups := []models.StudyGroup{}
+		err := db.Where("university_id = ?", universityID).Order("id").Find(&groups).Error
+		return groups, err
+	}},
+	{"group_members.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		rows := []models.StudyGroupMember{}
+		err := db.Where("group_id IN (SELECT id FROM study_groups WHERE university_id = ? AND deleted_at IS NULL)", universityID).
+			Order("id").Find(&rows).Error
+		return rows, err
+	}},
+	{"members.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		var users []models.User
+		if err := db.Where("university_id = ?", universityID).Order("id").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		members := make([]map[string]interface{}, 0, len(users))
+		for _, user := range users {
+			members = append(members, map[string]interface{}{
+				"id":                     user.ID,
+				"username":               user.Username,
+				"group":                  user.Group,
+				"university_verified_at": user.UniversityVerifiedAt,
+				"joined_at":              user.CreatedAt,
+			})
+		}
+		return members, nil
+	}},
+	{"roles.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		var roles []struct {
+			UserID uint   `json:"user_id"`
+			Role   string `json:"role"`
+		}
+		err := db.Table("user_roles").
+			Select("user_roles.user_id, roles.name AS role").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("user_roles.deleted_at IS NULL AND user_roles.university = (SELECT name FROM universities WHERE id = ?)", universityID).
+			Order("user_roles.user_id").Scan(&roles).Error
+		return roles, err
+	}},
+	{"course_analytics.json", byCourse[models.CourseAnalytics]()},
+	{"course_grades.json", byCourse[models.CourseGrade]()},
+	{"test_analytics.json", func(db *gorm.DB, universityID uint) (interface{}, error) {
+		rows := []models.TestAnalytics{}
+		err := db.Where("test_id IN (SELECT id FROM tests WHERE university_id = ? AND deleted_at IS NULL)", universityID).
+			Order("id").Find(&rows).Error
+		return rows, err
+	}},
+}
+
+// UniversitySections — число файлов в архиве организации, используется как total для прогресса
+func UniversitySections() int64 {
+	return int64(len(universitySections))
+}
+
+// University пишет в w ZIP-архив со всеми курсами, тестами, участниками и аналитикой университета
+func University(ctx context.Context, db *gorm.DB, universityID uint, w io.Writer, progress Progress) error {
+	return writeArchive(ctx, db, universitySections, universityID, w, progress)
+}
+
+// byCourse загружает строки модели, относящиеся к курсам университета
+func byCourse[T any]() func(db *gorm.DB, universityID uint) (interface{}, error) {
+	return func(db *gorm.DB, universityID uint) (interface{}, error) {
+		rows := []T{}
+		err := db.Where("course_id IN (SELECT id FROM courses WHERE university_id = ? AND deleted_at IS NULL)", universityID).
+			Order("id").Find(&rows).Error
+		return rows, err
+	}
+}
+
+// SignDownload подписывает ссылку на скачивание выгрузки до момента expires
+func SignDownload(secret string, jobID uint, expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "export:%d:%d", jobID, expires.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDownload проверяет подпись ссылки и срок ее действия
+func VerifyDownload(secret string, jobID uint, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	expected := SignDownload(secret, jobID, time.Unix(expires, 0))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
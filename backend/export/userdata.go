@@ -0,0 +1,133 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// archiveSection — один JSON-файл в ZIP-архиве; Load получает ID пользователя или университета
+type archiveSection struct {
+	Name string
+	Load func(db *gorm.DB, id uint) (interface{}, error)
+}
+
+// userDataSections перечисляет все, что платформа хранит о пользователе.
+// Секреты (хеши паролей и API-ключей, версии токенов) в выгрузку не попадают.
+var userDataSections = []archiveSection{
+	{"profile.json", func(db *gorm.DB, userID uint) (interface{}, error) {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"id":                     user.ID,
+			"username":               user.Username,
+			"email":                  user.Email,
+			"role":                   user.Role,
+			"group":                  user.Group,
+			"university":             user.University,
+			"university_email":       user.UniversityEmail,
+			"university_verified_at": user.UniversityVerifiedAt,
+			"avatar_url":             user.AvatarURL,
+			"active":                 user.Active,
+			"banned_until":           user.BannedUntil,
+			"ban_reason":             user.BanReason,
+			"created_at":             user.CreatedAt,
+			"updated_at":             user.UpdatedAt,
+		}, nil
+	}},
+	{"settings.json", findAll[models.UserSettings]("user_id")},
+	{"progress.json", findAll[models.UserProgress]("user_id")},
+	{"course_progress.json", findAll[models.UserCourseProgress]("user_id")},
+	{"test_results.json", findAll[models.UserTestProgress]("user_id")},
+	{"test_rankings.json", findAll[models.TestRanking]("user_id")},
+	{"course_comments.json", findAll[models.CourseComment]("user_id")},
+	{"course_reviews.json", findAll[models.Review]("user_id")},
+	{"course_comment_replies.json", findAll[models.CourseCommentReply]("user_id")},
+	{"test_comments.json", findAll[models.TestComment]("user_id")},
+	{"test_comment_replies.json", findAll[models.TestCommentReply]("user_id")},
+	{"login_history.json", findAll[models.LoginHistory]("user_id")},
+	{"activity.json", findAll[models.UserActivity]("user_id")},
+	{"conduct_acknowledgements.json", findAll[models.CourseConductAcknowledgement]("user_id")},
+	{"orders.json", findAll[models.Order]("user_id")},
+	{"following.json", findAll[models.UserFollow]("follower_id")},
+	{"bookmarks.json", findAll[models.Bookmark]("user_id")},
+	{"attempt_answers.json", findAll[models.AttemptAnswer]("user_id")},
+	{"waitlists.json", findAll[models.CourseWaitlist]("user_id")},
+	{"api_keys.json", func(db *gorm.DB, userID uint) (interface{}, error) {
+		var keys []models.ApiKey
+		if err := db.Where("user_id = ?", userID).Find(&keys).Error; err != nil {
+			return nil, err
+		}
+		result := make([]map[string]interface{}, 0, len(keys))
+		for _, key := range keys {
+			result = append(result, map[string]interface{}{
+				"name":         key.Name,
+				"prefix":       key.Prefix,
+				"created_at":   key.CreatedAt,
+				"last_used_at": key.LastUsedAt,
+				"expires_at":   key.ExpiresAt,
+			})
+		}
+		return result, nil
+	}},
+}
+
+// UserDataSections — число файлов в архиве, используется как total для прогресса
+func UserDataSections() int64 {
+	return int64(len(userDataSections))
+}
+
+// UserData пишет в w ZIP-архив со всеми данными пользователя (по JSON-файлу на раздел).
+// progress вызывается после каждого файла.
+func UserData(ctx context.Context, db *gorm.DB, userID uint, w io.Writer, progress Progress) error {
+	return writeArchive(ctx, db, userDataSections, userID, w, progress)
+}
+
+// writeArchive пишет в w ZIP-архив с JSON-файлом на каждый раздел
+func writeArchive(ctx context.Context, db *gorm.DB, sections []archiveSection, id uint, w io.Writer, progress Progress) error {
+	db = db.WithContext(ctx)
+	archive := zip.NewWriter(w)
+
+	for i, section := range sections {
+		data, err := section.Load(db, id)
+		if err != nil {
+			return err
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     section.Name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress(int64(i + 1))
+		}
+	}
+
+	return archive.Close()
+}
+
+// findAll загружает все строки модели, принадлежащие пользователю через column
+func findAll[T any](column string) func(db *gorm.DB, userID uint) (interface{}, error) {
+	return func(db *gorm.DB, userID uint) (interface{}, error) {
+		rows := []T{}
+		err := db.Where(column+" = ?", userID).Order("id").Find(&rows).Error
+		return rows, err
+	}
+}
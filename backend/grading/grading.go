@@ -0,0 +1,231 @@
+package grading
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"project/backend/models"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Band — нижняя граница буквенной оценки в процентах
+type Band struct {
+	Letter string  `json:"letter"`
+	Min    float64 `json:"min"`
+}
+
+// DefaultScale действует для курсов без университета и университетов без своей шкалы
+var DefaultScale = []Band{{"A", 90}, {"B", 80}, {"C", 70}, {"D", 60}, {"F", 0}}
+
+// ComponentScore — балл студента по одной составляющей
+type ComponentScore struct {
+	ID     uint    `json:"id"`
+	Name   string  `json:"name"`
+	Kind   string  `json:"kind"`
+	Weight float64 `json:"weight"`
+	Score  float64 `json:"score"`
+}
+
+// Result — итоговая оценка студента с разбивкой по составляющим
+type Result struct {
+	Percent    float64          `json:"percent"`
+	Letter     string           `json:"letter"`
+	Components []ComponentScore `json:"components"`
+}
+
+// Letter переводит проценты в букву по шкале (полосы сортируются от высшей к низшей)
+func Letter(bands []Band, percent float64) string {
+	sorted := append([]Band(nil), bands...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Min > sorted[j].Min })
+	for _, band := range sorted {
+		if percent >= band.Min {
+			return band.Letter
+		}
+	}
+	if len(sorted) == 0 {
+		return ""
+	}
+	return sorted[len(sorted)-1].Letter
+}
+
+// ScaleFor возвращает шкалу университета или DefaultScale
+func ScaleFor(db *gorm.DB, universityID *uint) ([]Band, error) {
+	if universityID == nil {
+		return DefaultScale, nil
+	}
+	var scale models.GradeScale
+	err := db.Where("university_id = ?", *universityID).First(&scale).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultScale, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bands []Band
+	if err := json.Unmarshal([]byte(scale.Bands), &bands); err != nil || len(bands) == 0 {
+		return DefaultScale, nil
+	}
+	return bands, nil
+}
+
+// Components возвращает составляющие оценки курса в порядке показа
+func Components(db *gorm.DB, courseID uint) ([]models.CourseGradeComponent, error) {
+	var components []models.CourseGradeComponent
+	err := db.Where("course_id = ?", courseID).Order("sequence_order, id").Find(&components).Error
+	return components, err
+}
+
+// ParseTestIDs разбирает список тестов составляющей
+func ParseTestIDs(value string) []uint {
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64); err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// Compute считает итоговую оценку студента за курс. Возвращает nil, если автор не задал состав оценки.
+// Отсутствующие результаты (непройденный тест, невыставленный балл) считаются нулем.
+func Compute(db *gorm.DB, course models.Course, userID uint) (*Result, error) {
+	components, err := Components(db, course.ID)
+	if err != nil || len(components) == 0 {
+		return nil, err
+	}
+
+	result := &Result{Components: make([]ComponentScore, 0, len(components))}
+	var weighted, weights float64
+	for _, component := range components {
+		score, err := componentScore(db, course.ID, component, userID)
+		if err != nil {
+			return nil, err
+		}
+		score = math.Min(math.Max(score, 0), 100)
+		result.Components = append(result.Components, ComponentScore{
+			ID:     component.ID,
+			Name:   component.Name,
+			Kind:   component.Kind,
+			Weight: component.Weight,
+			Score:  math.Round(score*100) / 100,
+		})
+		weighted += score * component.Weight
+		weights += component.Weight
+	}
+	if weights > 0 {
+		result.Percent = math.Round(weighted/weights*100) / 100
+	}
+
+	bands, err := ScaleFor(db, course.UniversityID)
+	if err != nil {
+		return nil, err
+	}
+	result.Letter = Letter(bands, result.Percent)
+	return result, nil
+}
+
+func componentScore(db *gorm.DB, courseID uint, component models.CourseGradeComponent, userID uint) (float64, error) {
+	var score float64
+	switch component.Kind {
+	case models.GradeKindTests:
+		ids := ParseTestIDs(component.TestIDs)
+		if len(ids) == 0 {
+			return 0, nil
+		}
+		var total float64
+		err := db.Model(&models.UserTestProgress{}).
+			Select("COALESCE(SUM(score), 0)").
+			Where("user_id = ? AND test_id IN ?", userID, ids).
+			Scan(&total).Error
+		return total / float64(len(ids)), err
+	case models.GradeKindLessons:
+		err := db.Model(&models.UserCourseProgress{}).
+			Select("COALESCE(MAX(completion_rate), 0)").
+			Where("user_id = ? AND course_id = ?", userID, courseID).
+			Scan(&score).Error
+		return score, err
+	case models.GradeKindParticipation:
+		var comments, replies int64
+		if err := db.Model(&models.CourseComment{}).
+			Where("user_id = ? AND course_id = ?", userID, courseID).Count(&comments).Error; err != nil {
+			return 0, err
+		}
+		if err := db.Model(&models.CourseCommentReply{}).
+			Joins("JOIN course_comments ON course_comments.id = course_comment_replies.comment_id").
+			Where("course_comment_replies.user_id = ? AND course_comments.course_id = ?", userID, courseID).
+			Count(&replies).Error; err != nil {
+			return 0, err
+		}
+		target := component.Target
+		if target < 1 {
+			target = 1
+		}
+		return float64(comments+replies) / float64(target) * 100, nil
+	case models.GradeKindManual:
+		err := db.Model(&models.CourseGradeEntry{}).
+			Select("COALESCE(MAX(score), 0)").
+			Where("component_id = ? AND user_id = ?", component.ID, userID).
+			Scan(&score).Error
+		return score, err
+	}
+	return 0, nil
+}
+
+// RefreshUser пересчитывает сохраненную итоговую оценку студента. Вызывается в транзакции,
+// которая меняет прогресс, баллы или состав оценки.
+func RefreshUser(tx *gorm.DB, courseID, userID uint) error {
+	var course models.Course
+	if err := tx.Select("id", "university_id").First(&course, courseID).Error; err != nil {
+		return err
+	}
+
+	result, err := Compute(tx, course, userID)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return tx.Unscoped().Where("course_id = ? AND user_id = ?", courseID, userID).Delete(&models.CourseGrade{}).Error
+	}
+
+	grade := models.CourseGrade{CourseID: courseID, UserID: userID, Percent: result.Percent, Letter: result.Letter}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "course_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"percent", "letter", "updated_at", "deleted_at"}),
+	}).Create(&grade).Error
+}
+
+// RefreshCourse пересчитывает оценки всех студентов курса (после изменения состава или шкалы)
+func RefreshCourse(tx *gorm.DB, courseID uint) error {
+	var userIDs []uint
+	if err := tx.Model(&models.UserCourseProgress{}).
+		Where("course_id = ?", courseID).Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := RefreshUser(tx, courseID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshTest пересчитывает оценки студента во всех курсах, в состав которых входит тест
+func RefreshTest(tx *gorm.DB, testID, userID uint) error {
+	var courseIDs []uint
+	if err := tx.Model(&models.CourseGradeComponent{}).
+		Where("kind = ? AND (',' || REPLACE(test_ids, ' ', '') || ',') LIKE ?", models.GradeKindTests, "%,"+strconv.FormatUint(uint64(testID), 10)+",%").
+		Distinct().Pluck("course_id", &courseIDs).Error; err != nil {
+		return err
+	}
+	for _, courseID := range courseIDs {
+		if err := RefreshUser(tx, courseID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
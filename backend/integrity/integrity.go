@@ -0,0 +1,409 @@
+// Package integrity строит отчет о честности сдачи экзаменационной сессии после ее закрытия:
+// распределение баллов против прошлых сессий того же теста, слишком быстрые попытки, студентов
+// с общими IP и пары с похожими ответами. Отчет только подсказывает прокторам, кого проверить:
+// общий IP бывает у компьютерного класса, а совпадения ответов — у хорошо подготовленной группы.
+package integrity
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"project/backend/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// With fewer submissions the statistics are noise
+	minAttempts = 3
+	// Attempts shorter than this share of the session median are flagged
+	fastFraction = 0.3
+	// Session mean this many standard errors away from the historical one is unusual
+	shiftThreshold = 3
+	// Pairs are flagged when they share this many wrong answers and agree on most of the rest
+	minSharedWrong = 3
+	minSimilarity  = 0.9
+)
+
+// UserRef — студент в отчете
+type UserRef struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// ScoreSummary — распределение баллов: гистограмма по 10 баллов, последний столбец включает 100
+type ScoreSummary struct {
+	Count     int     `json:"count"`
+	Mean      float64 `json:"mean"`
+	Median    float64 `json:"median"`
+	StdDev    float64 `json:"std_dev"`
+	Histogram [10]int `json:"histogram"`
+}
+
+// ScoreComparison сравнивает баллы сессии с прошлыми сессиями теста. ZScore — отклонение среднего
+// сессии от исторического в стандартных ошибках, 0 без достаточной истории.
+type ScoreComparison struct {
+	Session    ScoreSummary `json:"session"`
+	Historical ScoreSummary `json:"historical"`
+	ZScore     float64      `json:"z_score"`
+	Unusual    bool         `json:"unusual"`
+}
+
+// TimeAnomaly — попытка, сданная намного быстрее остальных (время на паузе не считается)
+type TimeAnomaly struct {
+	UserRef
+	DurationSeconds int     `json:"duration_seconds"`
+	MedianSeconds   int     `json:"median_seconds"`
+	Score           float64 `json:"score"`
+}
+
+// IPCluster — несколько студентов, начавших или сдавших попытку с одного IP
+type IPCluster struct {
+	IP    string    `json:"ip"`
+	Users []UserRef `json:"users"`
+}
+
+// SimilarPair — пара студентов с похожими ответами. Common — вопросы, на которые ответили оба,
+// Identical — одинаковые ответы из них, SharedWrong — одинаковые неверные ответы.
+type SimilarPair struct {
+	A           UserRef `json:"a"`
+	B           UserRef `json:"b"`
+	Common      int     `json:"common"`
+	Identical   int     `json:"identical"`
+	SharedWrong int     `json:"shared_wrong"`
+	Similarity  float64 `json:"similarity"`
+}
+
+// Report — отчет по сессии. Attempts — сданные и не аннулированные попытки, по которым он построен.
+type Report struct {
+	SessionID     uint            `json:"session_id"`
+	TestID        uint            `json:"test_id"`
+	Title         string          `json:"title"`
+	GeneratedAt   time.Time       `json:"generated_at"`
+	Attempts      int             `json:"attempts"`
+	Scores        ScoreComparison `json:"scores"`
+	TimeAnomalies []TimeAnomaly   `json:"time_anomalies"`
+	IPClusters    []IPCluster     `json:"ip_clusters"`
+	SimilarPairs  []SimilarPair   `json:"similar_pairs"`
+	Flagged       []UserRef       `json:"flagged"`
+}
+
+type attemptRow struct {
+	models.ExamAttempt
+	Username string
+}
+
+// ClosesAt — момент, когда сессия закрыта для всех: конец сессии плюс самое большое продление
+func ClosesAt(db *gorm.DB, session models.ExamSession) (time.Time, error) {
+	var extra int
+	if err := db.Model(&models.ExamAttempt{}).Select("COALESCE(MAX(extra_minutes), 0)").
+		Where("session_id = ?", session.ID).Scan(&extra).Error; err != nil {
+		return time.Time{}, err
+	}
+	return session.EndsAt.Add(time.Duration(extra) * time.Minute), nil
+}
+
+// Build строит отчет по сессии
+func Build(db *gorm.DB, session models.ExamSession, now time.Time) (Report, error) {
+	report := Report{
+		SessionID:     session.ID,
+		TestID:        session.TestID,
+		Title:         session.Title,
+		GeneratedAt:   now,
+		TimeAnomalies: []TimeAnomaly{},
+		IPClusters:    []IPCluster{},
+		SimilarPairs:  []SimilarPair{},
+		Flagged:       []UserRef{},
+	}
+
+	var attempts []attemptRow
+	if err := db.Table("exam_attempts").
+		Select("exam_attempts.*, users.username").
+		Joins("LEFT JOIN users ON users.id = exam_attempts.user_id").
+		Where("exam_attempts.session_id = ? AND exam_attempts.deleted_at IS NULL", session.ID).
+		Where("exam_attempts.submitted_at IS NOT NULL AND exam_attempts.invalidated_at IS NULL").
+		Order("exam_attempts.user_id").
+		Scan(&attempts).Error; err != nil {
+		return report, err
+	}
+	report.Attempts = len(attempts)
+
+	// Earlier sessions of the same test are the baseline
+	var historical []float64
+	if err := db.Model(&models.ExamAttempt{}).
+		Joins("JOIN exam_sessions ON exam_sessions.id = exam_attempts.session_id AND exam_sessions.deleted_at IS NULL").
+		Where("exam_sessions.test_id = ? AND exam_sessions.id <> ? AND exam_sessions.ends_at <= ?", session.TestID, session.ID, session.StartsAt).
+		Where("exam_attempts.submitted_at IS NOT NULL AND exam_attempts.invalidated_at IS NULL AND exam_attempts.score IS NOT NULL").
+		Pluck("exam_attempts.score", &historical).Error; err != nil {
+		return report, err
+	}
+
+	var questions []models.TestQuestion
+	if err := db.Select("id", "correct_answer").Where("test_id = ?", session.TestID).Find(&questions).Error; err != nil {
+		return report, err
+	}
+	correct := make(map[uint]int, len(questions))
+	for _, question := range questions {
+		correct[question.ID] = question.CorrectAnswer
+	}
+
+	var scores []float64
+	for _, attempt := range attempts {
+		if attempt.Score != nil {
+			scores = append(scores, *attempt.Score)
+		}
+	}
+	report.Scores = compareScores(summarize(scores), summarize(historical))
+	report.TimeAnomalies = timeAnomalies(attempts)
+	report.IPClusters = ipClusters(attempts)
+	report.SimilarPairs = similarPairs(attempts, correct)
+	report.Flagged = flagged(report)
+	return report, nil
+}
+
+// Generate строит отчет и сохраняет его; повторный вызов перестраивает отчет
+func Generate(db *gorm.DB, session models.ExamSession, now time.Time) (*models.ExamIntegrityReport, error) {
+	report, err := Build(db, session, now)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := models.ExamIntegrityReport{SessionID: session.ID, Report: string(data), Flagged: len(report.Flagged), GeneratedAt: now}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"report", "flagged", "generated_at", "updated_at"}),
+	}).Create(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// GenerateDue строит отчеты по всем закрывшимся сессиям, для которых их еще нет
+func GenerateDue(db *gorm.DB, now time.Time) error {
+	var sessions []models.ExamSession
+	if err := db.Where("ends_at <= ?", now).
+		Where("id NOT IN (?)", db.Model(&models.ExamIntegrityReport{}).Select("session_id")).
+		Order("ends_at").Find(&sessions).Error; err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		closesAt, err := ClosesAt(db, session)
+		if err != nil {
+			return err
+		}
+		// Students with extra time are still writing
+		if closesAt.After(now) {
+			continue
+		}
+		if _, err := Generate(db, session, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find возвращает сохраненный отчет по сессии или nil, если его еще нет
+func Find(db *gorm.DB, sessionID uint) (*models.ExamIntegrityReport, error) {
+	var stored models.ExamIntegrityReport
+	err := db.Where("session_id = ?", sessionID).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func summarize(scores []float64) ScoreSummary {
+	summary := ScoreSummary{Count: len(scores)}
+	if len(scores) == 0 {
+		return summary
+	}
+
+	sorted := append([]float64(nil), scores...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, score := range sorted {
+		sum += score
+		summary.Histogram[min(max(int(score/10), 0), 9)]++
+	}
+	summary.Mean = sum / float64(len(sorted))
+	summary.Median = median(sorted)
+
+	var variance float64
+	for _, score := range sorted {
+		variance += (score - summary.Mean) * (score - summary.Mean)
+	}
+	summary.StdDev = math.Sqrt(variance / float64(len(sorted)))
+	return summary
+}
+
+func compareScores(session, historical ScoreSummary) ScoreComparison {
+	comparison := ScoreComparison{Session: session, Historical: historical}
+	if session.Count < minAttempts || historical.Count < minAttempts || historical.StdDev == 0 {
+		return comparison
+	}
+	comparison.ZScore = (session.Mean - historical.Mean) / (historical.StdDev / math.Sqrt(float64(session.Count)))
+	comparison.Unusual = math.Abs(comparison.ZScore) >= shiftThreshold
+	return comparison
+}
+
+func timeAnomalies(attempts []attemptRow) []TimeAnomaly {
+	anomalies := []TimeAnomaly{}
+	durations := make([]float64, len(attempts))
+	for i, attempt := range attempts {
+		durations[i] = (attempt.SubmittedAt.Sub(attempt.StartedAt) - attempt.PauseTime()).Seconds()
+	}
+	if len(durations) < minAttempts {
+		return anomalies
+	}
+
+	sorted := append([]float64(nil), durations...)
+	sort.Float64s(sorted)
+	typical := median(sorted)
+	for i, attempt := range attempts {
+		if durations[i] >= typical*fastFraction {
+			continue
+		}
+		anomaly := TimeAnomaly{
+			UserRef:         ref(attempt),
+			DurationSeconds: int(durations[i]),
+			MedianSeconds:   int(typical),
+		}
+		if attempt.Score != nil {
+			anomaly.Score = *attempt.Score
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool { return anomalies[i].DurationSeconds < anomalies[j].DurationSeconds })
+	return anomalies
+}
+
+func ipClusters(attempts []attemptRow) []IPCluster {
+	users := map[string][]UserRef{}
+	for _, attempt := range attempts {
+		seen := map[string]bool{}
+		for _, ip := range []string{attempt.StartIP, attempt.SubmitIP} {
+			if ip == "" || seen[ip] {
+				continue
+			}
+			seen[ip] = true
+			users[ip] = append(users[ip], ref(attempt))
+		}
+	}
+
+	clusters := []IPCluster{}
+	for ip, refs := range users {
+		if len(refs) > 1 {
+			clusters = append(clusters, IPCluster{IP: ip, Users: refs})
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Users) != len(clusters[j].Users) {
+			return len(clusters[i].Users) > len(clusters[j].Users)
+		}
+		return clusters[i].IP < clusters[j].IP
+	})
+	return clusters
+}
+
+func similarPairs(attempts []attemptRow, correct map[uint]int) []SimilarPair {
+	answers := make([]map[uint]int, len(attempts))
+	for i, attempt := range attempts {
+		answers[i] = parseAnswers(attempt.Answers)
+	}
+
+	pairs := []SimilarPair{}
+	for i := range attempts {
+		for j := i + 1; j < len(attempts); j++ {
+			pair := SimilarPair{A: ref(attempts[i]), B: ref(attempts[j])}
+			for questionID, answer := range answers[i] {
+				other, ok := answers[j][questionID]
+				if !ok {
+					continue
+				}
+				pair.Common++
+				if answer != other {
+					continue
+				}
+				pair.Identical++
+				if right, known := correct[questionID]; known && answer != right {
+					pair.SharedWrong++
+				}
+			}
+			if pair.Common == 0 {
+				continue
+			}
+			pair.Similarity = float64(pair.Identical) / float64(pair.Common)
+			if pair.SharedWrong >= minSharedWrong && pair.Similarity >= minSimilarity {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].SharedWrong != pairs[j].SharedWrong {
+			return pairs[i].SharedWrong > pairs[j].SharedWrong
+		}
+		return pairs[i].Similarity > pairs[j].Similarity
+	})
+	return pairs
+}
+
+func flagged(report Report) []UserRef {
+	seen := map[uint]bool{}
+	result := []UserRef{}
+	add := func(user UserRef) {
+		if !seen[user.UserID] {
+			seen[user.UserID] = true
+			result = append(result, user)
+		}
+	}
+	for _, anomaly := range report.TimeAnomalies {
+		add(anomaly.UserRef)
+	}
+	for _, cluster := range report.IPClusters {
+		for _, user := range cluster.Users {
+			add(user)
+		}
+	}
+	for _, pair := range report.SimilarPairs {
+		add(pair.A)
+		add(pair.B)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+// parseAnswers разбирает ответы попытки; JSON хранит id вопросов строками
+func parseAnswers(data string) map[uint]int {
+	var raw map[string]int
+	json.Unmarshal([]byte(data), &raw)
+	answers := make(map[uint]int, len(raw))
+	for key, answer := range raw {
+		if id, err := strconv.ParseUint(key, 10, 64); err == nil {
+			answers[uint(id)] = answer
+		}
+	}
+	return answers
+}
+
+func ref(attempt attemptRow) UserRef {
+	return UserRef{UserID: attempt.UserID, Username: attempt.Username}
+}
+
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/models"
+	"project/backend/storage"
+	"project/backend/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatch — сколько аккаунтов удаляется за один запуск
+const purgeBatch = 100
+
+// PurgeDeletedAccounts окончательно удаляет аккаунты, у которых истек срок ожидания после удаления.
+// Анонимизированные комментарии остаются (в БД user_id у них обнуляется внешним ключом).
+// Аккаунты под юридическим удержанием ждут его снятия.
+func PurgeDeletedAccounts(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+
+		var ids []uint
+		if err := tx.Unscoped().Model(&models.User{}).
+			Where("deleted_at IS NOT NULL AND purge_after < ?", time.Now()).
+			Where("id NOT IN (?)", utils.HeldIDs(tx, models.HoldSubjectUser)).
+			Limit(purgeBatch).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			// Export files outlive their rows otherwise; a missing file only makes a download 404
+			var keys []string
+			if err := tx.Model(&models.ExportJob{}).Where("user_id = ? AND file_key <> ''", id).Pluck("file_key", &keys).Error; err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if err := storage.Archive.Delete(ctx, key); err != nil {
+					return err
+				}
+			}
+
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				for _, model := range []interface{}{
+					&models.UserProgress{}, &models.UserCourseProgress{}, &models.UserTestProgress{},
+					&models.LoginHistory{}, &models.UserSettings{}, &models.ApiKey{}, &models.UserRole{},
+					&models.ExportJob{}, &models.UserArchive{}, &models.AffiliationVerification{},
+					&models.UserActivity{}, &models.UserProgressSnapshot{}, &models.EmailChange{},
+					&models.CourseConductAcknowledgement{}, &models.Bookmark{},
+					&models.AttemptAnswer{}, &models.CourseWaitlist{},
+				} {
+					if err := tx.Unscoped().Where("user_id = ?", id).Delete(model).Error; err != nil {
+						return err
+					}
+				}
+				if err := tx.Unscoped().Where("follower_id = ? OR author_id = ?", id, id).
+					Delete(&models.UserFollow{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Unscoped().Where("mentor_id = ? OR student_id = ?", id, id).
+					Delete(&models.MentorLink{}).Error; err != nil {
+					return err
+				}
+				return tx.Unscoped().Delete(&models.User{}, id).Error
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/models"
+	"project/backend/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AggregatePlatformAnalytics пересчитывает дневной срез метрик платформы
+func AggregatePlatformAnalytics(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		today := time.Now().UTC().Format("2006-01-02")
+
+		var totalUsers, activeUsers, courses, tests int64
+		var avgCourseProgress, avgTestScore float64
+
+		tx.Model(&models.User{}).Count(&totalUsers)
+		tx.Model(&models.LoginHistory{}).
+			Where("login_time > ? AND success", time.Now().AddDate(0, 0, -30)).
+			Distinct("user_id").
+			Count(&activeUsers)
+		tx.Model(&models.Course{}).Count(&courses)
+		tx.Model(&models.Test{}).Count(&tests)
+		tx.Model(&models.UserCourseProgress{}).Select("COALESCE(AVG(completion_rate), 0)").Scan(&avgCourseProgress)
+		tx.Model(&models.UserTestProgress{}).Select("COALESCE(AVG(score), 0)").Scan(&avgTestScore)
+
+		var snapshot models.PlatformAnalytics
+		if err := tx.Where("date = ?", today).FirstOrInit(&snapshot).Error; err != nil {
+			return err
+		}
+
+		snapshot.Date = today
+		snapshot.TotalUsers = int(totalUsers)
+		snapshot.ActiveUsers = int(activeUsers)
+		snapshot.CoursesCreated = int(courses)
+		snapshot.TestsCreated = int(tests)
+		snapshot.AvgCourseProgress = avgCourseProgress
+		snapshot.AvgTestScore = avgTestScore
+
+		return tx.Save(&snapshot).Error
+	}
+}
+
+// SnapshotUserProgress пересчитывает срезы текущего месяца для пользователей, активных в этом месяце.
+// Часы за месяц считаются как разница с последним срезом предыдущих месяцев.
+func SnapshotUserProgress(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		now := time.Now().UTC()
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		period := start.Format(models.SnapshotPeriodLayout)
+
+		var userIDs []uint
+		if err := tx.Raw(`SELECT user_id FROM user_progress WHERE last_active >= ? AND deleted_at IS NULL
+			UNION SELECT user_id FROM user_activities WHERE created_at >= ? AND deleted_at IS NULL`, start, start).
+			Scan(&userIDs).Error; err != nil {
+			return err
+		}
+
+		for _, userID := range userIDs {
+			if err := snapshotUserProgress(tx, userID, period, start); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func snapshotUserProgress(tx *gorm.DB, userID uint, period string, start time.Time) error {
+	snapshot := models.UserProgressSnapshot{UserID: userID, Period: period}
+
+	if err := tx.Model(&models.UserCourseProgress{}).Where("user_id = ?", userID).
+		Select("COALESCE(SUM(hours_spent), 0)").Scan(&snapshot.TotalHours).Error; err != nil {
+		return err
+	}
+	var previous models.UserProgressSnapshot
+	if err := tx.Where("user_id = ? AND period < ?", userID, period).Order("period DESC").
+		Limit(1).Find(&previous).Error; err != nil {
+		return err
+	}
+	snapshot.Hours = snapshot.TotalHours - previous.TotalHours
+
+	var month struct {
+		Lessons  int
+		AvgScore float64
+	}
+	if err := tx.Model(&models.UserActivity{}).
+		Select(`COALESCE(SUM(CASE WHEN action_type = ? THEN 1 ELSE 0 END), 0) AS lessons,
+			COALESCE(AVG(CASE WHEN action_type = ? THEN score END), 0) AS avg_score`,
+			models.ActivityLessonComplete, models.ActivityTestComplete).
+		Where("user_id = ? AND created_at >= ?", userID, start).
+		Scan(&month).Error; err != nil {
+		return err
+	}
+	snapshot.Lessons = month.Lessons
+	snapshot.AvgScore = month.AvgScore
+
+	if err := tx.Model(&models.UserProgress{}).Where("user_id = ?", userID).
+		Select("COALESCE(MAX(streak_days), 0)").Scan(&snapshot.BestStreak).Error; err != nil {
+		return err
+	}
+
+	// The streak only lives in user_progress, so the best one is kept across runs
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "period"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"total_hours": snapshot.TotalHours,
+			"hours":       snapshot.Hours,
+			"lessons":     snapshot.Lessons,
+			"avg_score":   snapshot.AvgScore,
+			"best_streak": gorm.Expr("GREATEST(user_progress_snapshots.best_streak, ?)", snapshot.BestStreak),
+			"updated_at":  time.Now(),
+		}),
+	}).Create(&snapshot).Error
+}
+
+// ResetStaleStreaks обнуляет серии дней у пользователей, пропустивших целый день
+// по своему часовому поясу
+func ResetStaleStreaks(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		now := time.Now()
+
+		// Пропустить локальный день, не отсутствуя хотя бы сутки, нельзя ни в одном поясе
+		var candidates []struct {
+			models.UserProgress
+			Timezone string
+		}
+		if err := tx.Model(&models.UserProgress{}).
+			Select("user_progress.*, COALESCE(user_settings.timezone, 'UTC') AS timezone").
+			Joins("LEFT JOIN user_settings ON user_settings.user_id = user_progress.user_id AND user_settings.deleted_at IS NULL").
+			Where("user_progress.last_active < ? AND user_progress.streak_days > 0", now.Add(-24*time.Hour)).
+			Scan(&candidates).Error; err != nil {
+			return err
+		}
+
+		var stale []uint
+		for _, candidate := range candidates {
+			loc, err := time.LoadLocation(candidate.Timezone)
+			if err != nil {
+				loc = time.UTC
+			}
+			if utils.CurrentStreak(candidate.UserProgress, now, loc) == 0 {
+				stale = append(stale, candidate.ID)
+			}
+		}
+		if len(stale) == 0 {
+			return nil
+		}
+		return tx.Model(&models.UserProgress{}).Where("id IN ?", stale).Update("streak_days", 0).Error
+	}
+}
@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"project/backend/config"
+	"project/backend/metrics"
+	"project/backend/models"
+	"project/backend/outbox"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// anomalyWindow — окно, за которое сравниваются метрики; базой служат anomalyBaselineWindows предыдущих окон
+	anomalyWindow          = time.Hour
+	anomalyBaselineWindows = 24
+
+	// Пороги, ниже которых колебания считаются шумом
+	anomalyMinLogins       = 20  // average logins per window before a drop is meaningful
+	anomalyMinFailedLogins = 20  // failed logins in the window
+	anomalyMinRequests     = 100 // requests in the window for the error rate to count
+	anomalyMinErrorRate    = 0.05
+)
+
+// windowSample — значения метрик за одно окно
+type windowSample struct {
+	Logins       float64
+	FailedLogins float64
+	Requests     float64
+	ServerErrors float64
+}
+
+func (s windowSample) errorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.ServerErrors / s.Requests
+}
+
+// DetectAnomalies сравнивает последнее завершившееся окно с предыдущими и оповещает администраторов
+// о резком падении входов, всплеске неудачных входов и росте доли ошибок сервера.
+// Каждая аномалия окна сохраняется и отправляется один раз.
+func DetectAnomalies(db *gorm.DB, cfg *config.Config) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		windowEnd := time.Now().UTC().Truncate(anomalyWindow)
+
+		current, err := sampleWindow(tx, windowEnd.Add(-anomalyWindow), windowEnd)
+		if err != nil {
+			return err
+		}
+		baseline := make([]windowSample, 0, anomalyBaselineWindows)
+		for i := 1; i <= anomalyBaselineWindows; i++ {
+			end := windowEnd.Add(-time.Duration(i) * anomalyWindow)
+			sample, err := sampleWindow(tx, end.Add(-anomalyWindow), end)
+			if err != nil {
+				return err
+			}
+			baseline = append(baseline, sample)
+		}
+
+		for _, alert := range detectAnomalies(current, baseline) {
+			alert.WindowStart, alert.WindowEnd = windowEnd.Add(-anomalyWindow), windowEnd
+			if err := raiseAlert(tx, cfg, alert); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func sampleWindow(tx *gorm.DB, from, to time.Time) (windowSample, error) {
+	var sample windowSample
+	var logins int64
+	if err := tx.Model(&models.LoginHistory{}).
+		Where("login_time >= ? AND login_time < ? AND success", from, to).
+		Count(&logins).Error; err != nil {
+		return sample, err
+	}
+	sample.Logins = float64(logins)
+
+	for name, dst := range map[string]*float64{
+		metrics.FailedLogins: &sample.FailedLogins,
+		metrics.Requests:     &sample.Requests,
+		metrics.ServerErrors: &sample.ServerErrors,
+	} {
+		total, err := metrics.Sum(tx, name, from, to)
+		if err != nil {
+			return sample, err
+		}
+		*dst = float64(total)
+	}
+	return sample, nil
+}
+
+// detectAnomalies применяет правила к окну; WindowStart и WindowEnd заполняет вызывающий
+func detectAnomalies(current windowSample, baseline []windowSample) []models.PlatformAlert {
+	var alerts []models.PlatformAlert
+
+	logins := meanStd(baseline, func(s windowSample) float64 { return s.Logins })
+	if logins.mean >= anomalyMinLogins && current.Logins < logins.mean/2 {
+		alerts = append(alerts, models.PlatformAlert{
+			Metric:   models.AlertLoginDrop,
+			Value:    current.Logins,
+			Baseline: logins.mean,
+			Message:  fmt.Sprintf("Logins dropped to %.0f per hour, usually %.0f", current.Logins, logins.mean),
+		})
+	}
+
+	failed := meanStd(baseline, func(s windowSample) float64 { return s.FailedLogins })
+	if current.FailedLogins >= anomalyMinFailedLogins &&
+		current.FailedLogins > failed.mean+3*failed.std && current.FailedLogins > 2*failed.mean {
+		alerts = append(alerts, models.PlatformAlert{
+			Metric:   models.AlertFailedLoginSpike,
+			Value:    current.FailedLogins,
+			Baseline: failed.mean,
+			Message:  fmt.Sprintf("%.0f failed logins in an hour, usually %.0f", current.FailedLogins, failed.mean),
+		})
+	}
+
+	rate := meanStd(baseline, windowSample.errorRate)
+	if current.Requests >= anomalyMinRequests &&
+		current.errorRate() > math.Max(3*rate.mean, anomalyMinErrorRate) {
+		alerts = append(alerts, models.PlatformAlert{
+			Metric:   models.AlertErrorRateSurge,
+			Value:    current.errorRate(),
+			Baseline: rate.mean,
+			Message: fmt.Sprintf("%.1f%% of requests failed with a server error, usually %.1f%%",
+				current.errorRate()*100, rate.mean*100),
+		})
+	}
+	return alerts
+}
+
+type stats struct {
+	mean, std float64
+}
+
+func meanStd(samples []windowSample, value func(windowSample) float64) stats {
+	if len(samples) == 0 {
+		return stats{}
+	}
+	var sum float64
+	for _, sample := range samples {
+		sum += value(sample)
+	}
+	mean := sum / float64(len(samples))
+
+	var variance float64
+	for _, sample := range samples {
+		variance += math.Pow(value(sample)-mean, 2)
+	}
+	return stats{mean: mean, std: math.Sqrt(variance / float64(len(samples)))}
+}
+
+// raiseAlert сохраняет аномалию и, если она новая, шлет webhook и письма администраторам
+func raiseAlert(db *gorm.DB, cfg *config.Config, alert models.PlatformAlert) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := outbox.EnqueueWebhook(tx, cfg, "platform.anomaly", map[string]interface{}{
+			"metric":       alert.Metric,
+			"window_start": alert.WindowStart,
+			"window_end":   alert.WindowEnd,
+			"value":        alert.Value,
+			"baseline":     alert.Baseline,
+			"message":      alert.Message,
+		}); err != nil {
+			return err
+		}
+
+		var emails []string
+		if err := tx.Model(&models.User{}).
+			Where(`active AND (role = 'admin' OR id IN (
+				SELECT user_roles.user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+				WHERE roles.name = 'admin' AND user_roles.deleted_at IS NULL))`).
+			Pluck("email", &emails).Error; err != nil {
+			return err
+		}
+		subject := "Platform anomaly: " + alert.Metric
+		body := fmt.Sprintf("%s (%s – %s UTC)", alert.Message,
+			alert.WindowStart.Format("2006-01-02 15:04"), alert.WindowEnd.Format("15:04"))
+		for _, email := range emails {
+			if err := outbox.EnqueueEmail(tx, email, subject, body); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
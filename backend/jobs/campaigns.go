@@ -0,0 +1,354 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// campaignLapseWindow — сколько после порога неактивности студенту еще отправляется напоминание:
+	// давно ушедшим пользователям новая кампания писем не рассылает
+	campaignLapseWindow = 7 * 24 * time.Hour
+	// campaignFailureWindow — за какой срок проваленные тесты еще получают ободрение
+	campaignFailureWindow = 7 * 24 * time.Hour
+	// CampaignConversionWindow — срок после напоминания, в который возвращение или сдача теста засчитываются кампании
+	CampaignConversionWindow = 7 * 24 * time.Hour
+	// nudgeCooldown — не больше одного напоминания студенту за этот срок по всем кампаниям
+	nudgeCooldown = 24 * time.Hour
+	// remediationLessons — сколько уроков для повторения перечисляется после проваленного теста
+	remediationLessons = 5
+)
+
+// ErrCampaignsNeedAppURL — без APP_URL ссылки в письмах кампаний были бы путями без адреса сайта
+var ErrCampaignsNeedAppURL = errors.New("campaigns need APP_URL for the links in their emails")
+
+// CampaignPlaceholders — подстановки в теме и тексте кампании по условию срабатывания
+var CampaignPlaceholders = map[string][]string{
+	models.CampaignInactive:   {"{name}", "{days}", "{course}", "{next_lesson}", "{link}"},
+	models.CampaignTestFailed: {"{name}", "{test}", "{score}", "{lessons}"},
+}
+
+// Тексты кампаний с пустыми Subject или Body
+var campaignDefaults = map[string][2]string{
+	models.CampaignInactive: {
+		"Your next lesson in {course} is waiting",
+		"Hi {name}, it's been {days} days since your last visit. Pick up where you left off with \"{next_lesson}\": {link}\n\n" +
+			"A few minutes a day is enough to keep going.",
+	},
+	models.CampaignTestFailed: {
+		"Don't give up on {test}",
+		"Hi {name}, {test} didn't go as planned this time ({score}%). Hard material takes more than one try. " +
+			"These lessons can help before your next attempt:\n{lessons}",
+	},
+}
+
+// nudge — напоминание студенту по событию reference со значениями подстановок
+type nudge struct {
+	userID    uint
+	reference string
+	targetID  uint
+	values    map[string]string
+}
+
+// RunCampaigns отправляет напоминания включенных кампаний по ленте активности и отмечает студентов,
+// которые после напоминания вернулись к учебе или сдали тест
+func RunCampaigns(db *gorm.DB, cfg *config.Config) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		now := time.Now()
+
+		var campaigns []models.Campaign
+		if err := tx.Where("enabled = ?", true).Order("id").Find(&campaigns).Error; err != nil {
+			return err
+		}
+		if len(campaigns) > 0 && cfg.AppURL == "" {
+			return ErrCampaignsNeedAppURL
+		}
+		for _, campaign := range campaigns {
+			var nudges []nudge
+			var err error
+			switch campaign.Trigger {
+			case models.CampaignInactive:
+				nudges, err = inactiveNudges(tx, cfg, campaign, now)
+			case models.CampaignTestFailed:
+				nudges, err = failedTestNudges(tx, cfg, campaign, now)
+			}
+			if err != nil {
+				return err
+			}
+			for _, n := range nudges {
+				if err := sendNudge(tx, campaign, n, now); err != nil {
+					return err
+				}
+			}
+		}
+		return markConversions(tx, now)
+	}
+}
+
+// inactiveNudges находит студентов, чья последняя активность была InactiveDays назад, и их следующий урок
+func inactiveNudges(tx *gorm.DB, cfg *config.Config, campaign models.Campaign, now time.Time) ([]nudge, error) {
+	days := max(campaign.InactiveDays, 1)
+	threshold := now.AddDate(0, 0, -days)
+
+	var rows []struct {
+		UserID     uint
+		Username   string
+		ActivityID uint
+	}
+	// One nudge per spell of inactivity: the reference is the last activity before it.
+	// Only the window is scanned; "nothing after it" is a lookup in the (user_id, created_at) index.
+	err := tx.Raw(`SELECT a.user_id, u.username, a.id AS activity_id
+		FROM user_activities a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.created_at < ? AND a.created_at >= ? AND a.deleted_at IS NULL
+			AND u.deleted_at IS NULL AND u.active AND u.purge_after IS NULL AND u.archived_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM user_activities b
+				WHERE b.user_id = a.user_id AND b.deleted_at IS NULL
+					AND (b.created_at > a.created_at OR (b.created_at = a.created_at AND b.id > a.id)))
+			AND NOT EXISTS (SELECT 1 FROM campaign_deliveries d
+				WHERE d.campaign_id = ? AND d.user_id = a.user_id AND d.reference = 'activity:' || a.id)
+		ORDER BY a.created_at, a.id`,
+		threshold, threshold.Add(-campaignLapseWindow), campaign.ID).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		userIDs = append(userIDs, row.UserID)
+	}
+	next, err := nextLessons(tx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var nudges []nudge
+	for _, row := range rows {
+		target, ok := next[row.UserID]
+		if !ok {
+			continue
+		}
+		nudges = append(nudges, nudge{
+			userID:    row.UserID,
+			reference: fmt.Sprintf("activity:%d", row.ActivityID),
+			targetID:  target.course.ID,
+			values: map[string]string{
+				"{name}":        row.Username,
+				"{days}":        fmt.Sprint(days),
+				"{course}":      target.course.Title,
+				"{next_lesson}": target.lesson.Title,
+				"{link}":        lessonLink(cfg, target.course.ID, target.lesson.ID),
+			},
+		})
+	}
+	return nudges, nil
+}
+
+// nextLesson — курс, которым студент занимался последним и не закончил, и его следующий урок
+type nextLesson struct {
+	course models.Course
+	lesson models.Lesson
+}
+
+// nextLessons находит следующий урок для каждого из студентов userIDs; у студентов без незаконченных курсов его нет
+func nextLessons(tx *gorm.DB, userIDs []uint) (map[uint]nextLesson, error) {
+	var progress []models.UserCourseProgress
+	if err := tx.Raw(`SELECT DISTINCT ON (user_id) * FROM user_course_progress
+		WHERE user_id IN ? AND completion_rate < 100 AND deleted_at IS NULL
+		ORDER BY user_id, updated_at DESC, id DESC`, userIDs).Scan(&progress).Error; err != nil {
+		return nil, err
+	}
+	if len(progress) == 0 {
+		return nil, nil
+	}
+
+	courseIDs := make([]uint, 0, len(progress))
+	for _, p := range progress {
+		courseIDs = append(courseIDs, p.CourseID)
+	}
+	var courses []models.Course
+	if err := tx.Select("id", "title").Where("id IN ?", courseIDs).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	var lessons []models.Lesson
+	if err := tx.Select("id", "course_id", "title", "sequence_order").Where("course_id IN ?", courseIDs).
+		Order("course_id, sequence_order, id").Find(&lessons).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Course, len(courses))
+	for _, course := range courses {
+		byID[course.ID] = course
+	}
+	outline := map[uint][]models.Lesson{}
+	for _, lesson := range lessons {
+		outline[lesson.CourseID] = append(outline[lesson.CourseID], lesson)
+	}
+
+	result := make(map[uint]nextLesson, len(progress))
+	for _, p := range progress {
+		course, ok := byID[p.CourseID]
+		list := outline[p.CourseID]
+		if !ok || p.LessonsCompleted >= len(list) {
+			continue
+		}
+		result[p.UserID] = nextLesson{course: course, lesson: list[max(p.LessonsCompleted, 0)]}
+	}
+	return result, nil
+}
+
+// failedTestNudges находит последние попытки тестов ниже ScoreBelow, после которых студент тест еще не пересдавал
+func failedTestNudges(tx *gorm.DB, cfg *config.Config, campaign models.Campaign, now time.Time) ([]nudge, error) {
+	since := now.Add(-campaignFailureWindow)
+	// A new campaign doesn't reach back to failures from before it existed
+	if campaign.CreatedAt.After(since) {
+		since = campaign.CreatedAt
+	}
+
+	var rows []struct {
+		ActivityID  uint
+		UserID      uint
+		Username    string
+		TargetID    uint
+		TargetTitle string
+		Score       float64
+	}
+	err := tx.Raw(`SELECT a.id AS activity_id, a.user_id, u.username, a.target_id, a.target_title, a.score
+		FROM user_activities a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.action_type = ? AND a.score < ? AND a.created_at >= ? AND a.deleted_at IS NULL
+			AND u.deleted_at IS NULL AND u.active AND u.purge_after IS NULL AND u.archived_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM user_activities b
+				WHERE b.user_id = a.user_id AND b.action_type = a.action_type AND b.target_id = a.target_id
+					AND b.created_at > a.created_at AND b.deleted_at IS NULL)
+			AND NOT EXISTS (SELECT 1 FROM campaign_deliveries d
+				WHERE d.campaign_id = ? AND d.user_id = a.user_id AND d.reference = 'activity:' || a.id)
+		ORDER BY a.created_at, a.id`,
+		models.ActivityTestComplete, campaign.ScoreBelow, since, campaign.ID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var nudges []nudge
+	for _, row := range rows {
+		lessons, err := remediationLinks(tx, cfg, row.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		nudges = append(nudges, nudge{
+			userID:    row.UserID,
+			reference: fmt.Sprintf("activity:%d", row.ActivityID),
+			targetID:  row.TargetID,
+			values: map[string]string{
+				"{name}":    row.Username,
+				"{test}":    row.TargetTitle,
+				"{score}":   fmt.Sprintf("%.0f", row.Score),
+				"{lessons}": lessons,
+			},
+		})
+	}
+	return nudges, nil
+}
+
+// remediationLinks перечисляет первые уроки курса, итоговым тестом которого служит testID
+func remediationLinks(tx *gorm.DB, cfg *config.Config, testID uint) (string, error) {
+	var lessons []models.Lesson
+	err := tx.Joins("JOIN courses ON courses.id = lessons.course_id AND courses.deleted_at IS NULL").
+		Where("courses.completion_final_test_id = ?", testID).
+		Order("lessons.course_id, lessons.sequence_order, lessons.id").
+		Limit(remediationLessons).Find(&lessons).Error
+	if err != nil {
+		return "", err
+	}
+	if len(lessons) == 0 {
+		return "- Go back over the course material and try again when you feel ready.", nil
+	}
+
+	lines := make([]string, 0, len(lessons))
+	for _, lesson := range lessons {
+		lines = append(lines, fmt.Sprintf("- %s: %s", lesson.Title, lessonLink(cfg, lesson.CourseID, lesson.ID)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func lessonLink(cfg *config.Config, courseID, lessonID uint) string {
+	return fmt.Sprintf("%s/courses/%d/lessons/%d", strings.TrimRight(cfg.AppURL, "/"), courseID, lessonID)
+}
+
+// sendNudge записывает отправку и ставит письмо в очередь. Студентам, отключившим напоминания, и тем,
+// кто уже получил напоминание за nudgeCooldown, письмо не отправляется; событие проверится при следующем запуске.
+func sendNudge(db *gorm.DB, campaign models.Campaign, n nudge, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		enabled, err := outbox.NotificationsEnabled(tx, n.userID, outbox.NotifyNudges)
+		if err != nil || !enabled {
+			return err
+		}
+		var recent int64
+		if err := tx.Model(&models.CampaignDelivery{}).
+			Where("user_id = ? AND sent_at > ?", n.userID, now.Add(-nudgeCooldown)).
+			Count(&recent).Error; err != nil || recent > 0 {
+			return err
+		}
+
+		// Another instance may have sent it in the meantime
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.CampaignDelivery{
+			CampaignID: campaign.ID,
+			UserID:     n.userID,
+			Reference:  n.reference,
+			TargetID:   n.targetID,
+			SentAt:     now,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		subject, body := RenderCampaign(campaign, n.values)
+		return outbox.EnqueueNotification(tx, n.userID, outbox.NotifyNudges, subject, body)
+	})
+}
+
+// RenderCampaign подставляет значения в тему и текст кампании (или в тексты по умолчанию)
+func RenderCampaign(campaign models.Campaign, values map[string]string) (string, string) {
+	subject, body := campaign.Subject, campaign.Body
+	defaults := campaignDefaults[campaign.Trigger]
+	if strings.TrimSpace(subject) == "" {
+		subject = defaults[0]
+	}
+	if strings.TrimSpace(body) == "" {
+		body = defaults[1]
+	}
+
+	pairs := make([]string, 0, len(values)*2)
+	for placeholder, value := range values {
+		pairs = append(pairs, placeholder, value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+	return replacer.Replace(subject), replacer.Replace(body)
+}
+
+// markConversions отмечает отправки, после которых студент в течение CampaignConversionWindow
+// вернулся к учебе (inactive) или сдал тот же тест (test_failed)
+func markConversions(tx *gorm.DB, now time.Time) error {
+	return tx.Exec(`UPDATE campaign_deliveries d SET converted_at = converted.at, updated_at = ?
+		FROM (SELECT d2.id, MIN(a.created_at) AS at
+			FROM campaign_deliveries d2
+			JOIN campaigns c ON c.id = d2.campaign_id
+			JOIN user_activities a ON a.user_id = d2.user_id AND a.deleted_at IS NULL
+				AND a.created_at > d2.sent_at AND a.created_at <= d2.sent_at + make_interval(secs => ?)
+			WHERE d2.converted_at IS NULL AND d2.deleted_at IS NULL AND d2.sent_at >= ?
+				AND (c.trigger = ? OR (c.trigger = ? AND a.action_type = ? AND a.target_id = d2.target_id AND a.score >= c.score_below))
+			GROUP BY d2.id) converted
+		WHERE d.id = converted.id`,
+		// A day of slack so that activity from the last hour of the window is still picked up
+		now, CampaignConversionWindow.Seconds(), now.Add(-CampaignConversionWindow-24*time.Hour),
+		models.CampaignInactive, models.CampaignTestFailed, models.ActivityTestComplete).Error
+}
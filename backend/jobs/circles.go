@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/circles"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AwardCircleBadges выдает значки испытаний кружков, которых не дали события прохождения уроков
+func AwardCircleBadges(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return circles.AwardDue(db.WithContext(ctx), time.Now())
+	}
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/storage"
+	"project/backend/utils"
+	"project/backend/waitlist"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ArchiveFinishedCourses переносит в архив опубликованные курсы, у которых прошла дата окончания и не осталось идущих потоков.
+// Записанные студенты сохраняют доступ к материалам и прогрессу, но курс пропадает из каталога.
+func ArchiveFinishedCourses(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		return tx.Model(&models.Course{}).
+			Where("status = ?", models.CoursePublished).
+			Where("id IN (?)", tx.Model(&models.CourseAccessSettings{}).Select("course_id").Where("ends_at <= ?", time.Now())).
+			// A cohort that is still studying keeps the course open
+			Where("id NOT IN (?)", tx.Model(&models.CourseRun{}).Select("course_id").Where("ends_at IS NULL OR ends_at > ?", time.Now())).
+			Update("status", models.CourseArchived).Error
+	}
+}
+
+// PromoteWaitlists отдает освободившиеся места курсов очереди и снимает просроченные брони платных курсов
+func PromoteWaitlists(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return waitlist.PromoteAll(db.WithContext(ctx), time.Now())
+	}
+}
+
+// PurgeTrashedLessons окончательно удаляет уроки, пролежавшие в корзине дольше TrashRetentionDays, вместе с их
+// блоками, черновиками и файлами в хранилище. Сама строка удаленного курса остается: на нее ссылаются
+// сертификаты и заказы. Курсы под юридическим удержанием ждут его снятия.
+func PurgeTrashedLessons(db *gorm.DB, cfg *config.Config) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if cfg.TrashRetentionDays <= 0 {
+			return nil
+		}
+		tx := db.WithContext(ctx)
+
+		var ids []uint
+		if err := tx.Unscoped().Model(&models.Lesson{}).
+			Where("deleted_at < ?", time.Now().AddDate(0, 0, -cfg.TrashRetentionDays)).
+			Where("course_id NOT IN (?)", utils.HeldIDs(tx, models.HoldSubjectCourse)).
+			Limit(purgeBatch).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		var keys []string
+		if err := tx.Unscoped().Model(&models.LessonAttachment{}).Where("lesson_id IN ?", ids).Pluck("key", &keys).Error; err != nil {
+			return err
+		}
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&models.LessonAttachment{}, &models.LessonBlock{}, &models.LessonDraft{}} {
+				if err := tx.Unscoped().Where("lesson_id IN ?", ids).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Lesson{}).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		// The rows are gone, a file that fails to delete is only wasted space
+		for _, key := range keys {
+			storage.Default.Delete(ctx, key)
+		}
+		return nil
+	}
+}
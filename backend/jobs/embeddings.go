@@ -0,0 +1,331 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"project/backend/llm"
+	"project/backend/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	embeddingBatch = 64
+	// Long lessons are cut so a single text stays within the model's input limit
+	embeddingMaxChars = 8000
+)
+
+// SimilarityFloor — наименьшее сходство, с которым пара попадает в content_similarities;
+// отчет о дублях принимает порог не ниже него
+const SimilarityFloor = 0.8
+
+// EmbeddingText — текст курса или урока, который превращается в вектор
+type EmbeddingText struct {
+	SubjectType string
+	SubjectID   uint
+	CourseID    uint
+	Text        string
+}
+
+// Checksum — отпечаток текста, по которому видно, что вектор устарел
+func (t EmbeddingText) Checksum() string {
+	sum := sha256.Sum256([]byte(t.Text))
+	return hex.EncodeToString(sum[:])
+}
+
+func embeddingText(subjectType string, subjectID, courseID uint, body string, parts ...string) EmbeddingText {
+	text := strings.Join(append(parts, body), "\n\n")
+	if len(text) > embeddingMaxChars {
+		text = strings.ToValidUTF8(text[:embeddingMaxChars], "")
+	}
+	return EmbeddingText{SubjectType: subjectType, SubjectID: subjectID, CourseID: courseID, Text: text}
+}
+
+// embeddingSource — откуда берутся тексты одного вида; пустые тексты векторов не получают
+type embeddingSource struct {
+	kind     string
+	table    string
+	nonEmpty string
+}
+
+var embeddingSources = []embeddingSource{
+	{models.EmbeddingCourse, "courses", `COALESCE(courses.short_desc, '') || COALESCE(courses.description, '') ~ '\S'`},
+	{models.EmbeddingLesson, "lessons", `COALESCE(lessons.content, '') ~ '\S'`},
+}
+
+// staleSubjects — подзапрос id текстов без актуального вектора: вектора нет, он построен другой моделью
+// или текст менялся после него
+func staleSubjects(db *gorm.DB, source embeddingSource, model string) *gorm.DB {
+	return db.Table(source.table).Select(source.table+".id").
+		Joins("LEFT JOIN content_embeddings ON content_embeddings.subject_type = ? AND content_embeddings.subject_id = "+
+			source.table+".id AND content_embeddings.deleted_at IS NULL", source.kind).
+		Where(source.table+".deleted_at IS NULL AND "+source.nonEmpty).
+		Where("content_embeddings.id IS NULL OR content_embeddings.embed_model IS DISTINCT FROM ? OR content_embeddings.updated_at < "+
+			source.table+".updated_at", model)
+}
+
+// RefreshEmbeddings пересчитывает векторы новых и измененных курсов и уроков и сравнивает новые векторы
+// с остальными, не больше пачки за запуск. Ничего не делает, если провайдер не умеет строить векторы.
+func RefreshEmbeddings(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := RefreshEmbeddingsNow(ctx, db)
+		return err
+	}
+}
+
+// RefreshEmbeddingsNow — то же для ручного запуска из отчета; возвращает, сколько векторов пересчитано
+func RefreshEmbeddingsNow(ctx context.Context, db *gorm.DB) (int, error) {
+	refreshed, err := refreshEmbeddings(ctx, db, embeddingBatch)
+	if err != nil {
+		return refreshed, err
+	}
+	_, err = compareEmbeddings(ctx, db, embeddingBatch)
+	return refreshed, err
+}
+
+func refreshEmbeddings(ctx context.Context, db *gorm.DB, limit int) (int, error) {
+	embedder, ok := llm.Default.(llm.Embedder)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return 0, nil
+	}
+	model := embedder.EmbeddingModel()
+	tx := db.WithContext(ctx)
+
+	stale, err := StaleEmbeddings(tx, model, limit)
+	if err != nil || len(stale) == 0 {
+		return 0, err
+	}
+
+	// A row touched without changing its text keeps its vector, only the timestamp moves
+	type subject struct {
+		kind string
+		id   uint
+	}
+	var stored []models.ContentEmbedding
+	for _, source := range embeddingSources {
+		var ids []uint
+		for _, text := range stale {
+			if text.SubjectType == source.kind {
+				ids = append(ids, text.SubjectID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		var rows []models.ContentEmbedding
+		if err := tx.Select("id", "subject_type", "subject_id", "embed_model", "checksum").
+			Where("subject_type = ? AND subject_id IN ?", source.kind, ids).Find(&rows).Error; err != nil {
+			return 0, err
+		}
+		stored = append(stored, rows...)
+	}
+	checksums := make(map[subject]string, len(stored))
+	for _, row := range stored {
+		if row.EmbedModel == model {
+			checksums[subject{row.SubjectType, row.SubjectID}] = row.Checksum
+		}
+	}
+	var unchanged []uint
+	changed := make([]EmbeddingText, 0, len(stale))
+	for _, text := range stale {
+		if checksums[subject{text.SubjectType, text.SubjectID}] == text.Checksum() {
+			for _, row := range stored {
+				if row.SubjectType == text.SubjectType && row.SubjectID == text.SubjectID {
+					unchanged = append(unchanged, row.ID)
+				}
+			}
+			continue
+		}
+		changed = append(changed, text)
+	}
+	if len(unchanged) > 0 {
+		if err := tx.Model(&models.ContentEmbedding{}).Where("id IN ?", unchanged).
+			UpdateColumn("updated_at", time.Now()).Error; err != nil {
+			return 0, err
+		}
+	}
+	if len(changed) == 0 {
+		return len(stale), nil
+	}
+
+	inputs := make([]string, len(changed))
+	for i, text := range changed {
+		inputs[i] = text.Text
+	}
+	vectors, err := embedder.Embed(ctx, inputs)
+	if err != nil {
+		return 0, err
+	}
+	if len(vectors) != len(changed) {
+		return 0, fmt.Errorf("embedding provider returned %d vectors for %d texts", len(vectors), len(changed))
+	}
+
+	rows := make([]models.ContentEmbedding, len(changed))
+	for i, text := range changed {
+		vector, err := json.Marshal(vectors[i])
+		if err != nil {
+			return 0, err
+		}
+		rows[i] = models.ContentEmbedding{
+			SubjectType: text.SubjectType,
+			SubjectID:   text.SubjectID,
+			CourseID:    text.CourseID,
+			EmbedModel:  model,
+			Checksum:    text.Checksum(),
+			Vector:      string(vector),
+		}
+	}
+	// A new vector has to be compared again, compared_at goes back to NULL
+	err = tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject_type"}, {Name: "subject_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"course_id", "embed_model", "checksum", "vector", "compared_at", "updated_at"}),
+	}).Create(&rows).Error
+	return len(stale), err
+}
+
+// StaleEmbeddings возвращает до limit текстов без актуального вектора. Какие тексты устарели, решает
+// запрос по времени изменения, поэтому читаются только они, а не все курсы и уроки.
+func StaleEmbeddings(db *gorm.DB, model string, limit int) ([]EmbeddingText, error) {
+	var texts []EmbeddingText
+	for _, source := range embeddingSources {
+		remaining := limit - len(texts)
+		if remaining <= 0 {
+			break
+		}
+		stale := staleSubjects(db, source, model)
+		switch source.kind {
+		case models.EmbeddingCourse:
+			var courses []models.Course
+			if err := db.Select("id", "title", "short_desc", "description").Where("id IN (?)", stale).
+				Order("id").Limit(remaining).Find(&courses).Error; err != nil {
+				return nil, err
+			}
+			for _, course := range courses {
+				texts = append(texts, embeddingText(models.EmbeddingCourse, course.ID, course.ID,
+					strings.TrimSpace(course.ShortDesc+"\n"+course.Description), course.Title))
+			}
+		case models.EmbeddingLesson:
+			var lessons []models.Lesson
+			if err := db.Select("id", "course_id", "title", "content").Where("id IN (?)", stale).
+				Order("id").Limit(remaining).Find(&lessons).Error; err != nil {
+				return nil, err
+			}
+			for _, lesson := range lessons {
+				texts = append(texts, embeddingText(models.EmbeddingLesson, lesson.ID, lesson.CourseID, lesson.Content, lesson.Title))
+			}
+		}
+	}
+	return texts, nil
+}
+
+// PendingEmbeddings считает тексты, ждущие вектора, и векторы, еще не сравненные с остальными
+func PendingEmbeddings(db *gorm.DB, model string) (int64, error) {
+	var pending int64
+	for _, source := range embeddingSources {
+		var stale int64
+		if err := db.Table("(?) AS stale", staleSubjects(db, source, model)).Count(&stale).Error; err != nil {
+			return 0, err
+		}
+		pending += stale
+	}
+	var uncompared int64
+	if err := db.Model(&models.ContentEmbedding{}).Where("embed_model = ? AND compared_at IS NULL", model).
+		Count(&uncompared).Error; err != nil {
+		return 0, err
+	}
+	return pending + uncompared, nil
+}
+
+// storedVector — сохраненный вектор, с которым сравниваются новые
+type storedVector struct {
+	subjectID uint
+	courseID  uint
+	vector    []float64
+}
+
+// compareEmbeddings сравнивает до limit еще не сравненных векторов со всеми векторами того же вида и модели
+// и заменяет их пары в content_similarities. Уроки сравниваются только с уроками других курсов.
+func compareEmbeddings(ctx context.Context, db *gorm.DB, limit int) (int, error) {
+	embedder, ok := llm.Default.(llm.Embedder)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return 0, nil
+	}
+	model := embedder.EmbeddingModel()
+	tx := db.WithContext(ctx)
+
+	var pending []models.ContentEmbedding
+	if err := tx.Where("embed_model = ? AND compared_at IS NULL", model).Order("id").Limit(limit).
+		Find(&pending).Error; err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	// Every vector of a kind is read once per run, the batch is compared with all of them
+	all := map[string][]storedVector{}
+	for _, row := range pending {
+		if _, loaded := all[row.SubjectType]; loaded {
+			continue
+		}
+		var rows []models.ContentEmbedding
+		if err := tx.Select("subject_id", "course_id", "vector").
+			Where("subject_type = ? AND embed_model = ?", row.SubjectType, model).Find(&rows).Error; err != nil {
+			return 0, err
+		}
+		vectors := make([]storedVector, 0, len(rows))
+		for _, stored := range rows {
+			item := storedVector{subjectID: stored.SubjectID, courseID: stored.CourseID}
+			if json.Unmarshal([]byte(stored.Vector), &item.vector) == nil {
+				vectors = append(vectors, item)
+			}
+		}
+		all[row.SubjectType] = vectors
+	}
+
+	for _, row := range pending {
+		var vector []float64
+		json.Unmarshal([]byte(row.Vector), &vector)
+
+		var pairs []models.ContentSimilarity
+		for _, other := range all[row.SubjectType] {
+			if other.subjectID == row.SubjectID || other.courseID == row.CourseID {
+				continue
+			}
+			similarity := llm.Cosine(vector, other.vector)
+			if similarity < SimilarityFloor {
+				continue
+			}
+			pair := models.ContentSimilarity{SubjectType: row.SubjectType, Similarity: similarity,
+				SubjectA: row.SubjectID, CourseA: row.CourseID, SubjectB: other.subjectID, CourseB: other.courseID}
+			if pair.CourseA > pair.CourseB {
+				pair.SubjectA, pair.SubjectB = pair.SubjectB, pair.SubjectA
+				pair.CourseA, pair.CourseB = pair.CourseB, pair.CourseA
+			}
+			pairs = append(pairs, pair)
+		}
+
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("subject_type = ? AND (subject_a = ? OR subject_b = ?)", row.SubjectType, row.SubjectID, row.SubjectID).
+				Delete(&models.ContentSimilarity{}).Error; err != nil {
+				return err
+			}
+			if len(pairs) > 0 {
+				if err := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "subject_type"}, {Name: "subject_a"}, {Name: "subject_b"}},
+					DoUpdates: clause.AssignmentColumns([]string{"course_a", "course_b", "similarity"}),
+				}).CreateInBatches(&pairs, 500).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Model(&row).UpdateColumn("compared_at", time.Now()).Error
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
+}
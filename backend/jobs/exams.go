@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/events"
+	"project/backend/integrity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GenerateIntegrityReports строит отчеты о честности сдачи по закрывшимся экзаменационным сессиям
+func GenerateIntegrityReports(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return integrity.GenerateDue(db.WithContext(ctx), time.Now())
+	}
+}
+
+// PurgePresence удаляет просроченные отметки тех, кто открыл тест и ушел, не отправив ответы
+func PurgePresence(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return events.PurgePresence(db.WithContext(ctx), time.Now())
+	}
+}
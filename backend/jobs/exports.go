@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/export"
+	"project/backend/storage"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RunExports собирает ждущие фоновые выгрузки по одной, пока очередь не опустеет.
+// Каждую выгрузку забирает ровно один воркер, сколько бы их ни разбирало очередь.
+func RunExports(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			job, err := export.ClaimJob(db.WithContext(ctx))
+			if err != nil || job == nil {
+				return err
+			}
+			if err := export.RunJob(ctx, db, storage.Archive, job); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// ReapExports завершает выгрузки, брошенные упавшим экземпляром, и удаляет просроченные файлы
+func ReapExports(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return export.ReapJobs(ctx, db, storage.Archive, time.Now())
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// WithAdvisoryLock выполняет fn, только если удалось взять advisory lock Postgres с именем name.
+// Блокировка транзакционная: она держится, пока выполняется fn, и снимается автоматически,
+// даже если экземпляр упал. Возвращает false, если блокировку держит другой экземпляр.
+func WithAdvisoryLock(ctx context.Context, db *gorm.DB, name string, fn func(ctx context.Context) error) (bool, error) {
+	acquired := false
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", name).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		return fn(ctx)
+	})
+
+	return acquired, err
+}
+
+// LockEntity блокирует отдельную сущность до конца транзакции tx
+// (например, обновление серии дней конкретного пользователя)
+func LockEntity(tx *gorm.DB, scope string, id uint) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?), ?)", scope, int32(id)).Error
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PartitionedTable — таблица, секционированная по месяцам (PARTITION BY RANGE по времени)
+type PartitionedTable struct {
+	Table           string
+	RetentionMonths int    // 0 = keep every partition
+	UserColumn      string // partitions holding rows of users under legal hold are kept past retention
+}
+
+// partitionSuffix задает имя месячной секции: login_history_y2026m10
+const partitionSuffix = "_y%04dm%02d"
+
+// MaintainPartitions заранее создает секции на monthsAhead месяцев вперед и удаляет секции старше срока хранения.
+// Таблицы, которые в этой БД не секционированы (например, созданные AutoMigrate), пропускаются.
+func MaintainPartitions(db *gorm.DB, monthsAhead int, tables []PartitionedTable) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		now := time.Now().UTC()
+		current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+		for _, table := range tables {
+			var partitioned bool
+			if err := tx.Raw(`SELECT EXISTS (
+				SELECT 1 FROM pg_partitioned_table p JOIN pg_class c ON c.oid = p.partrelid
+				WHERE c.relname = ? AND c.relnamespace = current_schema()::regnamespace)`, table.Table).
+				Scan(&partitioned).Error; err != nil {
+				return err
+			}
+			if !partitioned {
+				continue
+			}
+
+			for i := 0; i <= monthsAhead; i++ {
+				if err := createPartition(tx, table.Table, current.AddDate(0, i, 0)); err != nil {
+					return err
+				}
+			}
+
+			if table.RetentionMonths > 0 {
+				if err := dropPartitionsBefore(tx, table, current.AddDate(0, -table.RetentionMonths, 0)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// createPartition создает секцию за месяц, начинающийся в from, если ее еще нет
+func createPartition(tx *gorm.DB, table string, from time.Time) error {
+	name := table + fmt.Sprintf(partitionSuffix, from.Year(), from.Month())
+	return tx.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+		quoteIdent(name), quoteIdent(table), from.Format("2006-01-02"), from.AddDate(0, 1, 0).Format("2006-01-02"))).Error
+}
+
+// dropPartitionsBefore удаляет месячные секции, целиком лежащие раньше cutoff (секция DEFAULT не трогается)
+func dropPartitionsBefore(tx *gorm.DB, partitioned PartitionedTable, cutoff time.Time) error {
+	table := partitioned.Table
+	var partitions []string
+	if err := tx.Raw(`SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = ? AND p.relnamespace = current_schema()::regnamespace`, table).
+		Scan(&partitions).Error; err != nil {
+		return err
+	}
+
+	for _, name := range partitions {
+		var year, month int
+		if _, err := fmt.Sscanf(name[len(table):], partitionSuffix, &year, &month); err != nil {
+			continue
+		}
+		start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		if !start.AddDate(0, 1, 0).After(cutoff) {
+			if partitioned.UserColumn != "" {
+				var held bool
+				if err := tx.Raw(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s IN (?))", quoteIdent(name), quoteIdent(partitioned.UserColumn)),
+					utils.HeldIDs(tx, models.HoldSubjectUser)).Scan(&held).Error; err != nil {
+					return err
+				}
+				if held {
+					continue
+				}
+			}
+			if err := tx.Exec("DROP TABLE IF EXISTS " + quoteIdent(name)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func quoteIdent(name string) string {
+	return `"` + name + `"`
+}
@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/quota"
+
+	"gorm.io/gorm"
+)
+
+// CheckQuotas сверяет использование мягких лимитов у всех авторов курсов и тестов и отправляет
+// предупреждения о пересеченных порогах. Лимиты мягкие, поэтому запросы их не проверяют: предупреждение
+// приходит с ближайшим запуском, каким бы путем ни изменилось использование (загрузка, импорт, удаление).
+func CheckQuotas(db *gorm.DB, cfg *config.Config) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		var authors []uint
+		err := tx.Raw("SELECT author_id FROM courses WHERE deleted_at IS NULL UNION SELECT author_id FROM tests WHERE deleted_at IS NULL").
+			Scan(&authors).Error
+		if err != nil {
+			return err
+		}
+		// Authors who deleted everything still have warnings to clear
+		var warned []uint
+		if err := tx.Model(&models.QuotaWarning{}).Distinct("user_id").Pluck("user_id", &warned).Error; err != nil {
+			return err
+		}
+
+		seen := map[uint]bool{}
+		for _, userID := range append(authors, warned...) {
+			if userID == 0 || seen[userID] {
+				continue
+			}
+			seen[userID] = true
+			if err := tx.Transaction(func(tx *gorm.DB) error { return quota.Check(tx, cfg, userID) }); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
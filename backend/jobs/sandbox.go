@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/config"
+	"project/backend/sandbox"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ResetSandbox раз в сутки, в SANDBOX_RESET_HOUR по UTC, стирает данные песочницы и создает демо-данные заново.
+// Задача проверяет время чаще, чем раз в сутки, поэтому пропущенный из-за перезапуска сброс выполняется при следующей проверке.
+func ResetSandbox(db *gorm.DB, cfg *config.Config) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		state, err := sandbox.Load(ctx, db)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if !sandbox.Due(state, now, cfg.SandboxResetHour) {
+			return nil
+		}
+		_, err = sandbox.Reset(ctx, db, cfg.Sandbox, cfg.SandboxDemoPassword, now)
+		return err
+	}
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job - периодическая задача
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler запускает задачи по расписанию. Время последнего запуска хранится в scheduled_jobs,
+// поэтому при нескольких экземплярах API задача запускается раз в свой интервал на одном из них;
+// advisory lock не дает запуску начаться, пока не закончился предыдущий.
+type Scheduler struct {
+	DB     *gorm.DB
+	Logger *log.Logger
+	// Paused, если задан, пропускает запуски, пока возвращает true (например, в режиме только для чтения)
+	Paused func() bool
+	jobs   []Job
+}
+
+func NewScheduler(db *gorm.DB, logger *log.Logger) *Scheduler {
+	return &Scheduler{DB: db, Logger: logger}
+}
+
+// Every регистрирует задачу с заданным интервалом
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// Start запускает все зарегистрированные задачи до отмены контекста
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx, job)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.Logger.Printf("[jobs] %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	if s.Paused != nil && s.Paused() {
+		return
+	}
+
+	due, err := s.claim(ctx, job)
+	if err != nil {
+		s.Logger.Printf("[jobs] %s: could not check the last run: %v", job.Name, err)
+		return
+	}
+	if !due {
+		return
+	}
+
+	// A previous run that outlasted its interval still holds the lock; this one is skipped
+	if _, err := WithAdvisoryLock(ctx, s.DB, "job:"+job.Name, job.Run); err != nil {
+		s.Logger.Printf("[jobs] %s failed: %v", job.Name, err)
+	}
+}
+
+// claim отмечает запуск задачи, если с прошлого запуска на любом экземпляре прошел ее интервал.
+// Строка задачи блокируется, поэтому из экземпляров, проснувшихся одновременно, запуск получает один.
+func (s *Scheduler) claim(ctx context.Context, job Job) (bool, error) {
+	due := false
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ScheduledJob{Name: job.Name}).Error; err != nil {
+			return err
+		}
+
+		var row struct {
+			LastRunAt *time.Time
+			Now       time.Time
+		}
+		if err := tx.Raw("SELECT last_run_at, NOW()::timestamp AS now FROM scheduled_jobs WHERE name = ? FOR UPDATE", job.Name).
+			Scan(&row).Error; err != nil {
+			return err
+		}
+		// Tickers on different instances drift, so a run a little early still counts as on time
+		if row.LastRunAt != nil && row.LastRunAt.Add(job.Interval-job.Interval/10).After(row.Now) {
+			return nil
+		}
+		due = true
+		return tx.Model(&models.ScheduledJob{}).Where("name = ?", job.Name).Update("last_run_at", gorm.Expr("NOW()")).Error
+	})
+	return due, err
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"project/backend/config"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurgeUsageStats удаляет часовые счетчики использования API и дневные вызовы устаревших маршрутов
+// старше UsageRetentionDays, чтобы таблицы не росли без предела
+func PurgeUsageStats(db *gorm.DB, cfg *config.Config) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		cutoff := time.Now().UTC().AddDate(0, 0, -cfg.UsageRetentionDays)
+		if err := tx.Unscoped().Where("hour < ?", cutoff).Delete(&models.ApiUsageBucket{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("day < ?", cutoff.Truncate(24*time.Hour)).Delete(&models.DeprecatedRouteCall{}).Error
+	}
+}
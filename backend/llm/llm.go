@@ -0,0 +1,72 @@
+// Package llm — языковая модель для вспомогательных инструментов авторов.
+// Ответы модели только предлагаются автору и никогда не сохраняются без его участия.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"project/backend/config"
+	"time"
+)
+
+// ErrDisabled возвращается, если провайдер не настроен
+var ErrDisabled = errors.New("language model provider is not configured")
+
+// Provider отправляет запрос модели и возвращает текст ответа
+type Provider interface {
+	// Complete выполняет один запрос: system задает роль модели, prompt — само задание
+	Complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// Embedder строит векторы текстов, чтобы находить похожий по смыслу контент.
+// Провайдеры, которые этого не умеют, интерфейс не реализуют.
+type Embedder interface {
+	// Embed возвращает по вектору на каждый текст в том же порядке
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+	// EmbeddingModel — модель векторов; векторы разных моделей сравнивать нельзя
+	EmbeddingModel() string
+}
+
+// Default — провайдер, используемый контроллерами. Заменяется в main по конфигурации.
+var Default Provider = Disabled{}
+
+// Disabled — провайдер по умолчанию, на любой запрос отвечает ErrDisabled
+type Disabled struct{}
+
+func (Disabled) Complete(context.Context, string, string) (string, error) {
+	return "", ErrDisabled
+}
+
+// New создает провайдер по cfg.LLMProvider: пусто (выключено) или "openai"
+func New(cfg *config.Config) (Provider, error) {
+	switch cfg.LLMProvider {
+	case "":
+		return Disabled{}, nil
+	case "openai":
+		if cfg.LLMAPIKey == "" || cfg.LLMModel == "" {
+			return nil, fmt.Errorf("the openai LLM provider requires LLM_API_KEY and LLM_MODEL")
+		}
+		return NewOpenAI(cfg.LLMBaseURL, cfg.LLMAPIKey, cfg.LLMModel, cfg.LLMEmbeddingModel, time.Duration(cfg.LLMTimeoutSeconds)*time.Second), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.LLMProvider)
+	}
+}
+
+// Cosine — косинусная близость векторов: 1 — совпадают по смыслу, 0 — не связаны
+func Cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
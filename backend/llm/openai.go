@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI — провайдер для API chat completions в формате OpenAI; подходит и для совместимых серверов
+type OpenAI struct {
+	baseURL        string
+	apiKey         string
+	model          string
+	embeddingModel string
+	client         *http.Client
+}
+
+func NewOpenAI(baseURL, apiKey, model, embeddingModel string, timeout time.Duration) *OpenAI {
+	return &OpenAI{
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		apiKey:         apiKey,
+		model:          model,
+		embeddingModel: embeddingModel,
+		client:         &http.Client{Timeout: timeout},
+	}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (o *OpenAI) Complete(ctx context.Context, system, prompt string) (string, error) {
+	var result struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	err := o.post(ctx, "/chat/completions", map[string]interface{}{
+		"model": o.model,
+		"messages": []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+	}, &result)
+	if err != nil {
+		return "", err
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("LLM response has no choices")
+	}
+	return result.Choices[0].Message.Content, nil
+}
+
+func (o *OpenAI) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if o.embeddingModel == "" {
+		return nil, ErrDisabled
+	}
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := o.post(ctx, "/embeddings", map[string]interface{}{"model": o.embeddingModel, "input": texts}, &result); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, item := range result.Data {
+		if item.Index >= 0 && item.Index < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	for _, vector := range vectors {
+		if vector == nil {
+			return nil, errors.New("LLM response is missing embeddings")
+		}
+	}
+	return vectors, nil
+}
+
+func (o *OpenAI) EmbeddingModel() string {
+	return o.embeddingModel
+}
+
+// post отправляет JSON-запрос к API и разбирает ответ в result
+func (o *OpenAI) post(ctx context.Context, path string, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &failure) == nil && failure.Error != nil {
+			return fmt.Errorf("LLM provider returned %d: %s", resp.StatusCode, failure.Error.Message)
+		}
+		return fmt.Errorf("LLM provider returned %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("LLM response: %w", err)
+	}
+	return nil
+}
@@ -1,11 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
+	"project/backend/cache"
 	"project/backend/config"
+	"project/backend/deprecation"
+	"project/backend/events"
+	"project/backend/jobs"
+	"project/backend/llm"
+	"project/backend/markup"
+	"project/backend/metrics"
 	"project/backend/middleware"
+	"project/backend/outbox"
+	"project/backend/payments"
+	"project/backend/readonly"
 	"project/backend/routes"
+	"project/backend/storage"
+	"project/backend/usage"
 	"project/backend/utils"
+	"strings"
+	"time"
 
 	_ "project/backend/docs"
 
@@ -29,30 +44,184 @@ func main() {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
+	if err := utils.ValidateJWTConfig(cfg); err != nil {
+		log.Fatalf("Error loading JWT keys: %v", err)
+	}
+
 	// Initialize database
 	db, err := utils.InitDB(cfg)
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
 
+	// Refuse to run against a schema this build wasn't written for, or keep serving reads if configured so
+	if _, err := utils.CheckSchema(db, cfg.SchemaCheck); err != nil {
+		if cfg.SchemaMismatchAction != "readonly" {
+			log.Fatalf("Error checking database schema: %v", err)
+		}
+		log.Printf("Database schema mismatch, serving in read-only mode: %v", err)
+		readonly.Force(readonly.SourceSchema, "The service is temporarily read-only while it is being upgraded")
+	}
+	if cfg.ReadOnly {
+		readonly.Force(readonly.SourceConfig, cfg.ReadOnlyMessage)
+	}
+	if err := readonly.Refresh(context.Background(), db); err != nil {
+		log.Printf("Error loading read-only setting: %v", err)
+	}
+
+	// Per-query timeout for every statement issued through GORM
+	if err := utils.RegisterQueryTimeout(db, time.Duration(cfg.QueryTimeoutSeconds)*time.Second); err != nil {
+		log.Fatalf("Error registering query timeout: %v", err)
+	}
+
+	// Seed default roles and permissions
+	if !readonly.Current().Enabled {
+		if err := utils.SeedRBAC(db); err != nil {
+			log.Fatalf("Error seeding roles: %v", err)
+		}
+	}
+
 	// Initialize logger
 	logger := utils.InitLogger()
 
+	// Development only: log sequential scans over large tables
+	if cfg.QueryPlanGuard {
+		if err := utils.RegisterQueryPlanGuard(db, logger, int64(cfg.QueryPlanSeqScanRows)); err != nil {
+			log.Fatalf("Error registering query plan guard: %v", err)
+		}
+	}
+
+	// Background jobs: each runs once per interval across all instances (see jobs.Scheduler)
+	relay := outbox.NewRelay(db, cfg, logger)
+	scheduler := jobs.NewScheduler(db, logger)
+	scheduler.Every("outbox-relay", time.Duration(cfg.OutboxPollSeconds)*time.Second, func(ctx context.Context) error {
+		_, err := relay.ProcessBatch(ctx)
+		return err
+	})
+	scheduler.Every("export-jobs", 10*time.Second, jobs.RunExports(db))
+	scheduler.Every("export-reaper", 15*time.Minute, jobs.ReapExports(db))
+	scheduler.Every("platform-analytics", time.Hour, jobs.AggregatePlatformAnalytics(db))
+	scheduler.Every("streak-reset", time.Hour, jobs.ResetStaleStreaks(db))
+	scheduler.Every("progress-snapshots", time.Hour, jobs.SnapshotUserProgress(db))
+	scheduler.Every("account-purge", time.Hour, jobs.PurgeDeletedAccounts(db))
+	scheduler.Every("course-archive", time.Hour, jobs.ArchiveFinishedCourses(db))
+	scheduler.Every("trash-purge", time.Hour, jobs.PurgeTrashedLessons(db, cfg))
+	scheduler.Every("waitlist-promotion", 15*time.Minute, jobs.PromoteWaitlists(db))
+	scheduler.Every("circle-badges", 15*time.Minute, jobs.AwardCircleBadges(db))
+	scheduler.Every("exam-integrity-reports", 15*time.Minute, jobs.GenerateIntegrityReports(db))
+	scheduler.Every("presence-purge", 15*time.Minute, jobs.PurgePresence(db))
+	scheduler.Every("content-embeddings", 15*time.Minute, jobs.RefreshEmbeddings(db))
+	scheduler.Every("anomaly-detection", time.Hour, jobs.DetectAnomalies(db, cfg))
+	scheduler.Every("quota-warnings", time.Hour, jobs.CheckQuotas(db, cfg))
+	scheduler.Every("campaigns", time.Hour, jobs.RunCampaigns(db, cfg))
+	scheduler.Every("usage-retention", 6*time.Hour, jobs.PurgeUsageStats(db, cfg))
+	scheduler.Every("partition-maintenance", 6*time.Hour, jobs.MaintainPartitions(db, cfg.PartitionMonthsAhead, []jobs.PartitionedTable{
+		{Table: "login_history", RetentionMonths: cfg.LoginHistoryRetentionMonths, UserColumn: "user_id"},
+	}))
+	if cfg.Sandbox {
+		scheduler.Every("sandbox-reset", 10*time.Minute, jobs.ResetSandbox(db, cfg))
+	}
+	// Jobs write to the database, so they are paused while the platform is read-only
+	scheduler.Paused = func() bool { return readonly.Current().Enabled }
+	scheduler.Start(context.Background())
+
+	// Live events published on any instance reach the subscribers connected to this one
+	go events.Listen(context.Background(), db, events.Default, logger)
+
+	// Analytics cache lives in process memory, so it is purged locally on every instance;
+	// invalidation is local too, other instances catch up once their entries expire
+	go func() {
+		for range time.Tick(time.Minute) {
+			cache.Analytics.Purge()
+		}
+	}()
+
+	// Request and login counters are kept per instance too, each instance adds its own to the shared buckets
+	// (while read-only they keep adding up in memory)
+	go func() {
+		for range time.Tick(time.Minute) {
+			if readonly.Current().Enabled {
+				continue
+			}
+			if err := metrics.Flush(context.Background(), db); err != nil {
+				logger.Printf("metrics flush failed: %v", err)
+			}
+			if err := deprecation.Flush(context.Background(), db); err != nil {
+				logger.Printf("deprecated route calls flush failed: %v", err)
+			}
+			if err := usage.Flush(context.Background(), db); err != nil {
+				logger.Printf("api usage flush failed: %v", err)
+			}
+		}
+	}()
+
+	// Admins toggle read-only mode on one instance, the others pick it up here
+	go func() {
+		for range time.Tick(10 * time.Second) {
+			if err := readonly.Refresh(context.Background(), db); err != nil {
+				logger.Printf("read-only refresh failed: %v", err)
+			}
+		}
+	}()
+
 	// Create Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		// Bodies over the default limit are left unread, BodyLimitMiddleware lets them through only on upload routes
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		// c.IP() feeds rate limits, usage and integrity checks: behind a proxy it must be the client, not the proxy
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          splitList(cfg.TrustedProxies),
+		EnableIPValidation:      true,
+	})
+
+	app.Use(middleware.BodyLimitMiddleware(fiber.DefaultBodyLimit))
 
 	// Swagger
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
 
 	// Middleware
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:  "*",                           // Укажите явные домены
-		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS", // Добавьте методы
-		AllowHeaders:  "Origin,Content-Type,Accept,Authorization",
-		ExposeHeaders: "Content-Length", // Доп. заголовки
-		MaxAge:        86400,            // Кеширование CORS (сек)
+		AllowOrigins:  "*",                                 // Укажите явные домены
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,OPTIONS", // Добавьте методы
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-API-Key,X-Captcha-Token",
+		ExposeHeaders: "Content-Length,Retry-After,X-Request-ID", // Доп. заголовки
+		MaxAge:        86400,                                     // Кеширование CORS (сек)
 	}))
+	app.Use(middleware.RequestContextMiddleware(cfg))
 	app.Use(middleware.LoggingMiddleware(logger))
+	app.Use(middleware.MetricsMiddleware())
+	app.Use(middleware.UsageMiddleware(app))
+	app.Use(middleware.ReadOnlyMiddleware())
+
+	// Rate limiting: general per IP, stricter for auth, per user for writes
+	app.Use(middleware.RateLimitMiddleware(cfg, nil))
+	app.Use("/api/auth", middleware.AuthRateLimitMiddleware(cfg, nil))
+	app.Use("/api/oauth/token", middleware.AuthRateLimitMiddleware(cfg, nil))
+	app.Use(middleware.WriteRateLimitMiddleware(cfg, nil))
+
+	// Uploaded files: served locally for the disk driver, from the bucket for S3
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Error initializing storage: %v", err)
+	}
+	storage.Default = store
+	if hosts := splitList(cfg.EmbedHosts); len(hosts) > 0 {
+		markup.EmbedHosts = hosts
+	}
+	if disk, ok := store.(*storage.Disk); ok {
+		app.Static(cfg.StoragePublicURL, disk.Root)
+	}
+	if storage.Archive, err = storage.NewArchive(cfg); err != nil {
+		log.Fatalf("Error initializing archive storage: %v", err)
+	}
+	if llm.Default, err = llm.New(cfg); err != nil {
+		log.Fatalf("Error initializing LLM provider: %v", err)
+	}
+	if payments.Default, err = payments.New(cfg); err != nil {
+		log.Fatalf("Error initializing payment provider: %v", err)
+	}
 
 	// Setup routes
 	routes.SetupRoutes(app, db, cfg)
@@ -77,3 +246,14 @@ func main() {
 	// Start server
 	log.Fatal(app.Listen(":" + cfg.ServerPort))
 }
+
+// splitList разбирает список через запятую, пропуская пустые элементы
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,468 @@
+package markup
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreak = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	codeFence     = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	listItem      = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])( +|$)`)
+	blockQuote    = regexp.MustCompile(`^ {0,3}> ?`)
+	tableDivider  = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	autolink      = regexp.MustCompile(`^<((?:https?://|mailto:)[^\s<>]+)>`)
+)
+
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// Markdown переводит Markdown в очищенный HTML. Поддерживаются заголовки, абзацы, цитаты, списки
+// (в том числе вложенные), блоки кода с языком, таблицы, горизонтальная линия, выделение, зачеркивание,
+// код в строке, ссылки и изображения. HTML внутри Markdown выводится как текст.
+func Markdown(src string) string {
+	src = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\t", "    ").Replace(src)
+	return Sanitize(renderBlocks(strings.Split(src, "\n")))
+}
+
+func renderBlocks(lines []string) string {
+	var out []string
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case blank(line):
+			i++
+		case codeFence.MatchString(line):
+			m := codeFence.FindStringSubmatch(line)
+			var code []string
+			for i++; i < len(lines) && !closesFence(lines[i], m[1]); i++ {
+				code = append(code, lines[i])
+			}
+			i++ // the closing fence, if there is one
+			class := ""
+			if m[2] != "" {
+				class = ` class="language-` + html.EscapeString(m[2]) + `"`
+			}
+			out = append(out, "<pre><code"+class+">"+escapeText(strings.Join(code, "\n"))+"</code></pre>")
+		case atxHeading.MatchString(line):
+			m := atxHeading.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			out = append(out, "<h"+level+">"+inline(m[2])+"</h"+level+">")
+			i++
+		case thematicBreak.MatchString(line):
+			out = append(out, "<hr>")
+			i++
+		case blockQuote.MatchString(line):
+			// Lines without ">" continue the quote until a blank line
+			var quoted []string
+			for ; i < len(lines) && !blank(lines[i]); i++ {
+				quoted = append(quoted, blockQuote.ReplaceAllString(lines[i], ""))
+			}
+			out = append(out, "<blockquote>"+renderBlocks(quoted)+"</blockquote>")
+		case listItem.MatchString(line):
+			var list string
+			list, i = renderList(lines, i)
+			out = append(out, list)
+		case i+1 < len(lines) && strings.Contains(line, "|") && strings.Contains(lines[i+1], "|") && tableDivider.MatchString(lines[i+1]):
+			var table string
+			table, i = renderTable(lines, i)
+			out = append(out, table)
+		default:
+			var paragraph []string
+			for ; i < len(lines) && !blank(lines[i]) && (len(paragraph) == 0 || !startsBlock(lines[i])); i++ {
+				paragraph = append(paragraph, lines[i])
+			}
+			out = append(out, "<p>"+inlineLines(paragraph)+"</p>")
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// startsBlock сообщает, прерывает ли строка абзац
+func startsBlock(line string) bool {
+	return codeFence.MatchString(line) || atxHeading.MatchString(line) || thematicBreak.MatchString(line) ||
+		blockQuote.MatchString(line) || listItem.MatchString(line)
+}
+
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// renderList собирает список, начинающийся со строки i; строки пункта, сдвинутые на ширину маркера,
+// разбираются как вложенные блоки
+func renderList(lines []string, i int) (string, int) {
+	first := listItem.FindStringSubmatch(lines[i])
+	ordered := isOrdered(first[2])
+	tag, attrs := "ul", ""
+	if ordered {
+		tag = "ol"
+		if start, _ := strconv.Atoi(first[2][:len(first[2])-1]); start != 1 {
+			attrs = ` start="` + strconv.Itoa(start) + `"`
+		}
+	}
+
+	var items []string
+	for i < len(lines) {
+		m := listItem.FindStringSubmatch(lines[i])
+		if m == nil || isOrdered(m[2]) != ordered {
+			break
+		}
+		width := len(m[0])
+		if m[3] == "" {
+			width++
+		}
+		item := []string{lines[i][len(m[0]):]}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if blank(line) {
+				// A blank line stays in the item only if the item goes on after it
+				next := i
+				for next < len(lines) && blank(lines[next]) {
+					next++
+				}
+				if next == len(lines) || indent(lines[next]) < width {
+					break
+				}
+				item = append(item, "")
+				continue
+			}
+			if indent(line) >= width {
+				item = append(item, line[width:])
+				continue
+			}
+			if startsBlock(line) || (len(item) > 0 && blank(item[len(item)-1])) {
+				break
+			}
+			item = append(item, strings.TrimLeft(line, " "))
+		}
+
+		// The first paragraph of an item goes without <p>
+		body := renderBlocks(item)
+		if rest, ok := strings.CutPrefix(body, "<p>"); ok {
+			if text, after, ok := strings.Cut(rest, "</p>"); ok {
+				body = text + after
+			}
+		}
+		items = append(items, "<li>"+body+"</li>")
+		if i < len(lines) && blank(lines[i]) {
+			for i < len(lines) && blank(lines[i]) {
+				i++
+			}
+			if i < len(lines) && !listItem.MatchString(lines[i]) {
+				break
+			}
+		}
+	}
+	return "<" + tag + attrs + ">\n" + strings.Join(items, "\n") + "\n</" + tag + ">", i
+}
+
+func isOrdered(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+// renderTable собирает таблицу GFM: строка заголовков, разделитель с выравниванием, строки до пустой
+func renderTable(lines []string, i int) (string, int) {
+	header := tableCells(lines[i])
+	var aligns []string
+	for _, cell := range tableCells(lines[i+1]) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	row := func(cells []string, tag string) string {
+		var sb strings.Builder
+		sb.WriteString("<tr>")
+		for k := range header {
+			cell := ""
+			if k < len(cells) {
+				cell = cells[k]
+			}
+			align := ""
+			if k < len(aligns) && aligns[k] != "" {
+				align = ` align="` + aligns[k] + `"`
+			}
+			sb.WriteString("<" + tag + align + ">" + inline(cell) + "</" + tag + ">")
+		}
+		sb.WriteString("</tr>")
+		return sb.String()
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<table>\n<thead>\n" + row(header, "th") + "\n</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && !blank(lines[i]) && strings.Contains(lines[i], "|"); i++ {
+		sb.WriteString(row(tableCells(lines[i]), "td") + "\n")
+	}
+	sb.WriteString("</tbody>\n</table>")
+	return sb.String(), i
+}
+
+// tableCells делит строку таблицы по "|", экранированные "\|" остаются в ячейке
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	start := 0
+	for k := 0; k < len(line); k++ {
+		switch line[k] {
+		case '\\':
+			k++
+		case '|':
+			cells = append(cells, strings.TrimSpace(line[start:k]))
+			start = k + 1
+		}
+	}
+	return append(cells, strings.TrimSpace(line[start:]))
+}
+
+// inlineLines соединяет строки абзаца; два пробела или "\" в конце строки дают перенос <br>
+func inlineLines(lines []string) string {
+	for k, line := range lines {
+		trimmed := strings.TrimRight(strings.TrimLeft(line, " "), " ")
+		if k < len(lines)-1 && strings.HasSuffix(line, "  ") {
+			trimmed += "\\"
+		}
+		lines[k] = trimmed
+	}
+	return inline(strings.Join(lines, "\n"))
+}
+
+// inline переводит разметку внутри строки: код, ссылки, изображения, выделение; остальное экранируется
+func inline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			sb.WriteString("<br>\n")
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(punctuation, s[i+1]) >= 0:
+			sb.WriteString(escapeText(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			n := runLength(s, i)
+			if end := closingRun(s, i+n, n); end >= 0 {
+				code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				sb.WriteString("<code>" + escapeText(code) + "</code>")
+				i = end + n
+				continue
+			}
+			sb.WriteString(s[i : i+n])
+			i += n
+			continue
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, dest, title, n, ok := linkAt(s, i+1); ok {
+				sb.WriteString(`<img src="` + html.EscapeString(dest) + `" alt="` + html.EscapeString(unescape(text)) + `"` + titleAttr(title) + ">")
+				i += 1 + n
+				continue
+			}
+		case c == '[':
+			if text, dest, title, n, ok := linkAt(s, i); ok {
+				sb.WriteString(`<a href="` + html.EscapeString(dest) + `"` + titleAttr(title) + ">" + inline(text) + "</a>")
+				i += n
+				continue
+			}
+		case c == '<':
+			if m := autolink.FindStringSubmatch(s[i:]); m != nil {
+				sb.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + escapeText(m[1]) + "</a>")
+				i += len(m[0])
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if out, n, ok := emphasis(s, i); ok {
+				sb.WriteString(out)
+				i += n
+				continue
+			}
+		}
+		sb.WriteString(escapeText(s[i : i+1]))
+		i++
+	}
+	return sb.String()
+}
+
+// linkAt разбирает [текст](адрес "заголовок") с позиции i и возвращает его длину
+func linkAt(s string, i int) (text, dest, title string, n int, ok bool) {
+	depth, j := 0, i
+	for ; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if j+1 >= len(s) || s[j+1] != '(' {
+		return "", "", "", 0, false
+	}
+	text = s[i+1 : j]
+
+	k := j + 2
+	for k < len(s) && s[k] == ' ' {
+		k++
+	}
+	start, parens := k, 0
+	for ; k < len(s); k++ {
+		if s[k] == ' ' || s[k] == '\n' || (s[k] == ')' && parens == 0) {
+			break
+		}
+		switch s[k] {
+		case '(':
+			parens++
+		case ')':
+			parens--
+		}
+	}
+	dest = s[start:k]
+	for k < len(s) && (s[k] == ' ' || s[k] == '\n') {
+		k++
+	}
+	if k < len(s) && (s[k] == '"' || s[k] == '\'') {
+		end := strings.IndexByte(s[k+1:], s[k])
+		if end < 0 {
+			return "", "", "", 0, false
+		}
+		title = s[k+1 : k+1+end]
+		for k += end + 2; k < len(s) && s[k] == ' '; k++ {
+		}
+	}
+	if k >= len(s) || s[k] != ')' {
+		return "", "", "", 0, false
+	}
+	return text, dest, title, k + 1 - i, true
+}
+
+// emphasis разбирает *курсив*, **жирный**, ***оба*** (то же с _) и ~~зачеркнутый~~ текст с позиции i
+func emphasis(s string, i int) (string, int, bool) {
+	c := s[i]
+	n := runLength(s, i)
+	if n > 3 || (c == '~' && n != 2) || i+n >= len(s) || isSpace(s[i+n]) {
+		return "", 0, false
+	}
+	// Underscores inside words (snake_case) stay as they are
+	if c == '_' && i > 0 && isWordByte(s, i-1) {
+		return "", 0, false
+	}
+
+	for k := i + n; k < len(s); k++ {
+		switch {
+		case s[k] == '\\':
+			k++
+			continue
+		case s[k] == '`':
+			if end := closingRun(s, k+runLength(s, k), runLength(s, k)); end >= 0 {
+				k = end + runLength(s, k) - 1
+			}
+			continue
+		case s[k] != c:
+			continue
+		}
+		run := runLength(s, k)
+		if run != n || isSpace(s[k-1]) || k == i+n || (c == '_' && k+run < len(s) && isWordByte(s, k+run)) {
+			k += run - 1
+			continue
+		}
+
+		content := inline(s[i+n : k])
+		switch {
+		case c == '~':
+			content = "<del>" + content + "</del>"
+		case n == 1:
+			content = "<em>" + content + "</em>"
+		case n == 2:
+			content = "<strong>" + content + "</strong>"
+		default:
+			content = "<strong><em>" + content + "</em></strong>"
+		}
+		return content, k + run - i, true
+	}
+	return "", 0, false
+}
+
+func runLength(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// closingRun ищет с позиции from серию из n обратных кавычек, не длиннее и не короче
+func closingRun(s string, from, n int) int {
+	for k := from; k < len(s); {
+		if s[k] != '`' {
+			k++
+			continue
+		}
+		run := runLength(s, k)
+		if run == n {
+			return k
+		}
+		k += run
+	}
+	return -1
+}
+
+func titleAttr(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` title="` + html.EscapeString(unescape(title)) + `"`
+}
+
+// unescape убирает обратные слеши перед знаками препинания
+func unescape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(punctuation, s[i+1]) >= 0 {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func isWordByte(s string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(s[:i+1])
+	if r == utf8.RuneError {
+		r, _ = utf8.DecodeRuneInString(s[i:])
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n'
+}
+
+func blank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
@@ -0,0 +1,102 @@
+package markup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Markdown and Sanitize work on text only: these cases need neither the database nor the app
+func TestMarkdown(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		html string
+	}{
+		{"heading and paragraph", "## Frege\n\nSense and reference.", "<h2>Frege</h2>\n<p>Sense and reference.</p>"},
+		{"emphasis and strong", "The *morning star* is the **evening star**.", "<p>The <em>morning star</em> is the <strong>evening star</strong>.</p>"},
+		{"underscores inside words", "snake_case_name and _slanted_", "<p>snake_case_name and <em>slanted</em></p>"},
+		{"strikethrough and code", "~~Pegasus~~ is `a name`", "<p><del>Pegasus</del> is <code>a name</code></p>"},
+		{"unclosed emphasis", "2 * 3 = 6 and **open", "<p>2 * 3 = 6 and **open</p>"},
+		{"bullet list", "- sense\n- reference", "<ul>\n<li>sense</li>\n<li>reference</li>\n</ul>"},
+		{"ordered list with start", "3. third\n4. fourth", "<ol start=\"3\">\n<li>third</li>\n<li>fourth</li>\n</ol>"},
+		{"nested list", "- logic\n    - modal\n    - deontic\n- ethics", "<ul>\n<li>logic\n<ul>\n<li>modal</li>\n<li>deontic</li>\n</ul></li>\n<li>ethics</li>\n</ul>"},
+		{"blockquote", "> To be is to be\n> the value of a variable", "<blockquote><p>To be is to be\nthe value of a variable</p></blockquote>"},
+		{"fence with language", "```go\nif a < b {\n}\n```", "<pre><code class=\"language-go\">if a &lt; b {\n}</code></pre>"},
+		{"unclosed fence", "```\n*not emphasis*", "<pre><code>*not emphasis*</code></pre>"},
+		{"table with alignment", "| Term | Count |\n|:-----|------:|\n| sense | 2 |\n| reference | 10 |", "<table>\n<thead>\n<tr><th align=\"left\">Term</th><th align=\"right\">Count</th></tr>\n</thead>\n<tbody>\n<tr><td align=\"left\">sense</td><td align=\"right\">2</td></tr>\n<tr><td align=\"left\">reference</td><td align=\"right\">10</td></tr>\n</tbody>\n</table>"},
+		{"horizontal rule", "above\n\n---\n\nbelow", "<p>above</p>\n<hr>\n<p>below</p>"},
+		{"link with title", `[Kripke](https://example.com/naming "Naming")`, `<p><a href="https://example.com/naming" title="Naming">Kripke</a></p>`},
+		{"image", "![Frege](/uploads/frege.png)", `<p><img src="/uploads/frege.png" alt="Frege"></p>`},
+		{"escaped punctuation", `\*not emphasis\*`, "<p>*not emphasis*</p>"},
+		{"inline html is text", "Is <b>this</b> bold?", "<p>Is &lt;b&gt;this&lt;/b&gt; bold?</p>"},
+		{"javascript link", "[click](javascript:alert(1))", "<p><a>click</a></p>"},
+		{"javascript image", "![x](javascript:alert(1))", `<p><img alt="x"></p>`},
+		{"attribute breakout in link", `[x](https://example.com/"onmouseover="alert(1))`, `<p><a href="https://example.com/&#34;onmouseover=&#34;alert(1)">x</a></p>`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.html, Markdown(tc.src))
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		html string
+	}{
+		{"safe markup is kept", `<p>Plain <a href="/courses/1">link</a></p>`, `<p>Plain <a href="/courses/1">link</a></p>`},
+		{"unclosed tags are closed", `</p><div><b>open`, "<div><b>open</b></div>"},
+		{"script is dropped with its content", `<p>a<script>alert(1)</script>b</p>`, "<p>ab</p>"},
+		{"uppercase script", `<SCRIPT>alert(1)</SCRIPT>ok`, "ok"},
+		{"event handler", `<img src="/a.png" onerror="steal()" alt="A">`, `<img src="/a.png" alt="A">`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript href with tab and entity", `<a href=" java&#09;script:alert(1)">x</a>`, "<a>x</a>"},
+		{"data image", `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, "<img>"},
+		{"mailto only in links", `<a href="mailto:a@b.c">m</a><img src="mailto:a@b.c">`, `<a href="mailto:a@b.c">m</a><img>`},
+		{"target gets noopener", `<a href="https://example.com" target="_blank">x</a>`, `<a href="https://example.com" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"svg is dropped", `<svg><script>alert(1)</script></svg>ok`, "ok"},
+		{"unknown element is unwrapped", `<marquee>hi</marquee>`, "hi"},
+		{"comment is dropped", `a<!-- <script>alert(1)</script> -->b`, "ab"},
+		{"quote in attribute is escaped", `<img alt='x" onerror="alert(1)'>`, `<img alt="x&#34; onerror=&#34;alert(1)">`},
+		{"iframe on an unknown host", `<iframe src="https://evil.example/login">fallback</iframe>after`, "after"},
+		{"iframe over http", `<iframe src="http://www.youtube.com/embed/x"></iframe>`, ""},
+		{"iframe on a lookalike host", `<iframe src="https://youtube.com.evil.example/embed/x"></iframe>`, ""},
+		{"iframe with userinfo", `<iframe src="https://youtube.com@evil.example/"></iframe>`, ""},
+		{"allowed iframe gets sandbox", `<iframe src="https://www.youtube.com/embed/x" sandbox="allow-top-navigation" allowfullscreen></iframe>`, `<iframe src="https://www.youtube.com/embed/x" allowfullscreen sandbox="allow-scripts allow-same-origin allow-presentation allow-popups"></iframe>`},
+		{"color is kept", `<p style="color: #333">x</p>`, `<p style="color: #333">x</p>`},
+		{"overlay positioning is dropped", `<div style="position:fixed; top:0; left:0; z-index:9999; width:100%">x</div>`, `<div style="width: 100%">x</div>`},
+		{"url in background is dropped", `<p style="background: url(https://evil.example/x.png); color: red">x</p>`, `<p style="color: red">x</p>`},
+		{"expression is dropped", `<p style="width: expression(alert(1))">x</p>`, "<p>x</p>"},
+		{"escaped css is dropped", `<p style="color: \72 ed">x</p>`, "<p>x</p>"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.html, Sanitize(tc.src))
+		})
+	}
+}
+
+func TestEmbedAllowed(t *testing.T) {
+	cases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://www.youtube.com/embed/x", true},
+		{"https://youtube.com/embed/x", true},
+		{"https://player.vimeo.com/video/1", true},
+		{"HTTPS://WWW.YOUTUBE.COM/embed/x", true},
+		{"http://www.youtube.com/embed/x", false},
+		{"https://notyoutube.com/embed/x", false},
+		{"https://youtube.com.evil.example/", false},
+		{"https://youtube.com@evil.example/", false},
+		{"//www.youtube.com/embed/x", false},
+		{"javascript:alert(1)", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.allowed, EmbedAllowed(tc.url), tc.url)
+	}
+}
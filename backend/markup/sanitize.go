@@ -0,0 +1,267 @@
+// Package markup готовит пользовательский текст к показу: переводит Markdown в HTML и очищает HTML
+// по белому списку тегов и атрибутов. Все, что сохраняется как HTML урока или комментария, проходит
+// через Sanitize, поэтому скрипты, обработчики событий и javascript:-ссылки до клиентов не доходят.
+package markup
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// allowedAttrs — разрешенные теги и их атрибуты (кроме общих globalAttrs)
+var allowedAttrs = map[string][]string{
+	"p": nil, "br": nil, "hr": nil, "div": nil, "span": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"strong": nil, "b": nil, "em": nil, "i": nil, "u": nil, "s": nil, "del": nil, "ins": nil,
+	"mark": nil, "sub": nil, "sup": nil, "small": nil, "code": nil, "pre": nil, "kbd": nil, "abbr": nil,
+	"blockquote": {"cite"}, "q": {"cite"}, "cite": nil, "time": {"datetime"},
+	"ul": nil, "ol": {"start", "type", "reversed"}, "li": {"value"}, "dl": nil, "dt": nil, "dd": nil,
+	"section": nil, "article": nil, "aside": nil, "header": nil, "footer": nil, "details": {"open"}, "summary": nil,
+	"a":      {"href", "target"},
+	"img":    {"src", "alt", "width", "height", "loading"},
+	"figure": nil, "figcaption": nil,
+	"video":  {"src", "poster", "controls", "width", "height", "preload", "loop", "muted"},
+	"audio":  {"src", "controls", "preload", "loop", "muted"},
+	"source": {"src", "type"},
+	"track":  {"src", "kind", "srclang", "label", "default"},
+	"iframe": {"src", "title", "width", "height", "loading", "allowfullscreen"},
+	"table":  nil, "caption": nil, "thead": nil, "tbody": nil, "tfoot": nil, "tr": nil,
+	"th": {"colspan", "rowspan", "scope", "align"}, "td": {"colspan", "rowspan", "align"},
+	"colgroup": {"span"}, "col": {"span"},
+	// Older lessons color text with <font>, the accessibility check reads it
+	"font": {"color"},
+}
+
+var (
+	globalAttrs  = []string{"class", "title", "lang", "dir", "style"}
+	urlAttrs     = []string{"href", "src", "poster", "cite"}
+	booleanAttrs = []string{"controls", "loop", "muted", "default", "open", "reversed", "allowfullscreen"}
+	voidElements = []string{"br", "hr", "img", "source", "track", "col", "wbr"}
+
+	// Dropped together with everything inside them
+	droppedElements = []string{"script", "style", "noscript", "template", "object", "embed", "applet",
+		"frame", "frameset", "head", "title", "svg", "math", "textarea", "select", "button"}
+
+	// CSS that can load resources or run code in old browsers
+	unsafeStyle = regexp.MustCompile(`(?i)url\s*\(|expression\s*\(|javascript:|@import|behavior\s*:|-moz-binding|\\`)
+)
+
+// styleProperties — свойства CSS, которые остаются в атрибуте style: оформление текста и блоков.
+// Позиционирование (position, z-index, transform) отбрасывается: им урок мог бы накрыть страницу
+// поддельной формой.
+var styleProperties = []string{
+	"color", "background", "background-color", "opacity",
+	"font", "font-family", "font-size", "font-style", "font-weight", "font-variant",
+	"text-align", "text-decoration", "text-indent", "text-transform", "line-height", "letter-spacing",
+	"word-spacing", "white-space", "vertical-align", "direction",
+	"margin", "margin-top", "margin-right", "margin-bottom", "margin-left",
+	"padding", "padding-top", "padding-right", "padding-bottom", "padding-left",
+	"border", "border-top", "border-right", "border-bottom", "border-left",
+	"border-color", "border-style", "border-width", "border-radius", "border-collapse", "border-spacing",
+	"width", "height", "max-width", "max-height", "min-width", "min-height",
+	"list-style", "list-style-type", "float", "clear",
+}
+
+// EmbedHosts — сайты, плееры и интерактивы которых можно встраивать во фрейм (вместе с их поддоменами).
+// Заменяется в main по конфигурации.
+var EmbedHosts = []string{
+	"youtube.com", "youtube-nocookie.com", "vimeo.com", "loom.com",
+	"docs.google.com", "slides.com", "geogebra.org", "desmos.com", "phet.colorado.edu", "h5p.com",
+}
+
+// IframeSandbox — ограничения фрейма: скрипты плеера работают, но не уводят страницу и не открывают формы
+const IframeSandbox = "allow-scripts allow-same-origin allow-presentation allow-popups"
+
+// Sanitize оставляет в HTML только разрешенные теги, атрибуты и свойства CSS. Ссылки допускаются http(s),
+// mailto и относительные, фреймы — только https-адреса EmbedHosts и всегда с sandbox. Незакрытые теги
+// закрываются, лишние закрывающие отбрасываются, чтобы фрагмент не ломал разметку страницы, в которую вставлен.
+func Sanitize(src string) string {
+	var sb strings.Builder
+	var open []string
+	skip, skipDepth := "", 0
+
+	z := xhtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		// io.EOF or a malformed tail: what was read so far is kept
+		if tt == xhtml.ErrorToken {
+			break
+		}
+		token := z.Token()
+
+		if skip != "" {
+			switch {
+			case tt == xhtml.StartTagToken && token.Data == skip:
+				skipDepth++
+			case tt == xhtml.EndTagToken && token.Data == skip:
+				if skipDepth--; skipDepth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case xhtml.TextToken:
+			sb.WriteString(escapeText(token.Data))
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			allowed, known := allowedAttrs[token.Data]
+			drop := slices.Contains(droppedElements, token.Data)
+			// A frame pointing anywhere but a known embed host goes with its fallback content
+			if token.Data == "iframe" && !EmbedAllowed(attr(token, "src")) {
+				drop = true
+			}
+			if drop {
+				if tt == xhtml.StartTagToken && !slices.Contains(voidElements, token.Data) {
+					skip, skipDepth = token.Data, 1
+				}
+				continue
+			}
+			// Unknown elements are unwrapped, their text stays
+			if !known {
+				continue
+			}
+			sb.WriteString("<" + token.Data)
+			writeAttrs(&sb, token, allowed)
+			if token.Data == "iframe" {
+				sb.WriteString(` sandbox="` + IframeSandbox + `"`)
+			}
+			sb.WriteString(">")
+			if !slices.Contains(voidElements, token.Data) {
+				if tt == xhtml.SelfClosingTagToken {
+					sb.WriteString("</" + token.Data + ">")
+				} else {
+					open = append(open, token.Data)
+				}
+			}
+		case xhtml.EndTagToken:
+			// Closes the innermost element with that name and everything left open inside it
+			i := len(open) - 1
+			for i >= 0 && open[i] != token.Data {
+				i--
+			}
+			for j := len(open) - 1; i >= 0 && j >= i; j-- {
+				sb.WriteString("</" + open[j] + ">")
+			}
+			if i >= 0 {
+				open = open[:i]
+			}
+		}
+		// Comments and doctypes are dropped with the other token types
+	}
+
+	for j := len(open) - 1; j >= 0; j-- {
+		sb.WriteString("</" + open[j] + ">")
+	}
+	return sb.String()
+}
+
+func writeAttrs(sb *strings.Builder, token xhtml.Token, allowed []string) {
+	seen := map[string]bool{}
+	target := false
+	for _, a := range token.Attr {
+		name := a.Key
+		if a.Namespace != "" || seen[name] || (!slices.Contains(allowed, name) && !slices.Contains(globalAttrs, name)) {
+			continue
+		}
+		value := strings.TrimSpace(a.Val)
+		switch {
+		case slices.Contains(urlAttrs, name):
+			if !safeURL(value, name == "href") {
+				continue
+			}
+		case name == "style":
+			if a.Val = filterStyle(value); a.Val == "" {
+				continue
+			}
+		case name == "target":
+			target = true
+		}
+
+		seen[name] = true
+		if slices.Contains(booleanAttrs, name) && (value == "" || strings.EqualFold(value, name)) {
+			sb.WriteString(" " + name)
+			continue
+		}
+		sb.WriteString(" " + name + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	// Pages opened in a new tab don't get a handle on ours
+	if target {
+		sb.WriteString(` rel="noopener noreferrer"`)
+	}
+}
+
+func attr(token xhtml.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// safeURL пропускает http(s), mailto (только в ссылках) и адреса без схемы
+func safeURL(raw string, link bool) bool {
+	// Browsers ignore whitespace and control characters inside the scheme ("java\tscript:")
+	compact := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw)
+	end := strings.IndexAny(compact, "/?#")
+	if end < 0 {
+		end = len(compact)
+	}
+	colon := strings.IndexByte(compact[:end], ':')
+	if colon < 0 {
+		return true
+	}
+	switch strings.ToLower(compact[:colon]) {
+	case "http", "https":
+		return true
+	case "mailto":
+		return link
+	}
+	return false
+}
+
+// EmbedAllowed сообщает, можно ли встроить во фрейм адрес raw: только https и только сайты из EmbedHosts
+func EmbedAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range EmbedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterStyle оставляет из объявлений CSS только свойства styleProperties с безопасными значениями
+func filterStyle(style string) string {
+	var kept []string
+	for _, declaration := range strings.Split(style, ";") {
+		property, value, ok := strings.Cut(declaration, ":")
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.TrimSpace(value)
+		if !ok || value == "" || !slices.Contains(styleProperties, property) || unsafeStyle.MatchString(value) {
+			continue
+		}
+		kept = append(kept, property+": "+value)
+	}
+	return strings.Join(kept, "; ")
+}
+
+// escapeText экранирует текст так же, как браузер его прочтет, не трогая кавычки
+func escapeText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
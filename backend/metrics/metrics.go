@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"project/backend/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Счетчики платформы, которые не видны по данным в таблицах
+const (
+	Requests     = "requests"
+	ServerErrors = "server_errors" // responses with a 5xx status
+	FailedLogins = "failed_logins"
+)
+
+var (
+	mu       sync.Mutex
+	counters = map[string]int64{}
+)
+
+// Inc увеличивает счетчик текущего процесса; значения попадают в базу при Flush
+func Inc(name string) {
+	mu.Lock()
+	counters[name]++
+	mu.Unlock()
+}
+
+// Flush переносит накопленные счетчики в поминутные корзины metric_buckets.
+// Счетчики живут в памяти процесса, поэтому Flush вызывается на каждом экземпляре.
+func Flush(ctx context.Context, db *gorm.DB) error {
+	mu.Lock()
+	pending := counters
+	counters = map[string]int64{}
+	mu.Unlock()
+
+	bucket := time.Now().UTC().Truncate(time.Minute)
+	for name, value := range pending {
+		err := db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "name"}, {Name: "bucket"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"value":      gorm.Expr("metric_buckets.value + ?", value),
+				"updated_at": time.Now(),
+			}),
+		}).Create(&models.MetricBucket{Name: name, Bucket: bucket, Value: value}).Error
+		if err != nil {
+			// Keep what wasn't written for the next flush
+			mu.Lock()
+			for name, value := range pending {
+				counters[name] += value
+			}
+			mu.Unlock()
+			return err
+		}
+		delete(pending, name)
+	}
+	return nil
+}
+
+// Sum возвращает сумму счетчика за интервал [from, to)
+func Sum(db *gorm.DB, name string, from, to time.Time) (int64, error) {
+	var total int64
+	err := db.Model(&models.MetricBucket{}).
+		Select("COALESCE(SUM(value), 0)").
+		Where("name = ? AND bucket >= ? AND bucket < ?", name, from, to).
+		Scan(&total).Error
+	return total, err
+}
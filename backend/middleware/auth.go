@@ -2,35 +2,90 @@ package middleware
 
 import (
 	"project/backend/config"
+	"project/backend/models"
+	"project/backend/policy"
 	"project/backend/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware(cfg *config.Config) fiber.Handler {
+// AuthMiddleware принимает JWT в Authorization или API ключ в X-API-Key
+// и сохраняет ID пользователя в c.Locals("user_id")
+func AuthMiddleware(db *gorm.DB, cfg *config.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		_, err := utils.ExtractUserIDFromToken(c, cfg)
+		if apiKey := c.Get("X-API-Key"); apiKey != "" {
+			var key models.ApiKey
+			if err := db.Where("key_hash = ?", utils.HashAPIKey(apiKey)).First(&key).Error; err != nil {
+				// A sandbox key sent to the live API (or the other way round) gets a hint instead of a plain "invalid"
+				if cfg.Sandbox && utils.IsLiveAPIKey(apiKey) {
+					return utils.AuthFailure(c, utils.NewAuthError(utils.CodeAPIKeyEnvironment, "Live API keys don't work in the sandbox"))
+				}
+				if !cfg.Sandbox && utils.IsSandboxAPIKey(apiKey) {
+					return utils.AuthFailure(c, utils.NewAuthError(utils.CodeAPIKeyEnvironment, "Sandbox API keys only work against the sandbox"))
+				}
+				return utils.AuthFailure(c, utils.NewAuthError(utils.CodeAPIKeyInvalid, "Invalid API key"))
+			}
+			if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+				return utils.AuthFailure(c, utils.NewAuthError(utils.CodeAPIKeyExpired, "API key expired"))
+			}
+
+			var user models.User
+			if err := db.First(&user, key.UserID).Error; err != nil {
+				return utils.AuthFailure(c, utils.NewAuthError(utils.CodeAPIKeyInvalid, "Invalid API key"))
+			}
+
+			if authErr := utils.AccountBlocked(&user); authErr != nil {
+				return utils.AuthFailure(c, authErr)
+			}
+
+			db.Model(&key).UpdateColumn("last_used_at", time.Now())
+			c.Locals("user_id", key.UserID)
+			c.Locals("api_key_id", key.ID)
+			c.Locals("claims", utils.ClaimsForUser(&user))
+			policy.Attach(c, db, key.UserID)
+			return c.Next()
+		}
+
+		claims, err := utils.ExtractClaims(c, cfg)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Unauthorized",
-			})
+			return utils.AuthFailure(c, err)
 		}
+
+		// Tokens issued before the last version bump (password change etc.) are revoked
+		var user models.User
+		if err := db.Select("id", "token_version", "active", "banned_until", "ban_reason").
+			First(&user, claims.UserID).Error; err != nil || user.TokenVersion != claims.TokenVersion {
+			return utils.AuthFailure(c, utils.NewAuthError(utils.CodeTokenRevoked, "Token revoked"))
+		}
+
+		if authErr := utils.AccountBlocked(&user); authErr != nil {
+			return utils.AuthFailure(c, authErr)
+		}
+
+		if claims.ClientID != "" {
+			if authErr := checkClientScope(c, db, claims); authErr != nil {
+				return utils.AuthFailure(c, authErr)
+			}
+			c.Locals("client_id", claims.ClientID)
+		}
+
+		c.Locals("user_id", claims.UserID)
+		policy.Attach(c, db, claims.UserID)
 		return c.Next()
 	}
 }
 
-func AdminMiddleware(cfg *config.Config) fiber.Handler {
+func AdminMiddleware(db *gorm.DB, cfg *config.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userID, err := utils.ExtractUserIDFromToken(c, cfg)
+		claims, err := utils.ExtractClaims(c, cfg)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Unauthorized",
-			})
+			return utils.AuthFailure(c, err)
 		}
 
-		// Здесь должна быть проверка, что пользователь - администратор
-		// Это пример, вам нужно реализовать проверку в вашей базе данных
-		if userID != 1 { // Пример: предполагаем, что пользователь с ID 1 - администратор
+		// The role in the token may be stale: a demoted admin keeps it until the token expires
+		if !utils.IsAdmin(db, claims.UserID) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "Forbidden - Admin access required",
 			})
@@ -39,3 +94,24 @@ func AdminMiddleware(cfg *config.Config) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// PermissionMiddleware пропускает запрос, только если у пользователя есть все указанные разрешения
+func PermissionMiddleware(db *gorm.DB, cfg *config.Config, codes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := utils.ExtractUserIDFromToken(c, cfg)
+		if err != nil {
+			return utils.AuthFailure(c, err)
+		}
+
+		for _, code := range codes {
+			if !utils.HasPermission(db, userID, code) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error":      "Forbidden - missing permission",
+					"permission": code,
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"project/backend/utils"
+	"regexp"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errBodyTooLarge = errors.New("Request body is too large")
+
+// raisedBodyLimit — маршрут, которому нужен предел тела больше общего (загрузки файлов и пакетов)
+type raisedBodyLimit struct {
+	method string
+	path   *regexp.Regexp
+	limit  int
+}
+
+var (
+	bodyLimitsMu sync.RWMutex
+	bodyLimits   []raisedBodyLimit
+)
+
+var routeParam = regexp.MustCompile(`:[^/]+`)
+
+// RaiseBodyLimit поднимает предел тела для маршрута; route записывается как в Fiber, с :параметрами.
+// Вызывается при настройке маршрутов.
+func RaiseBodyLimit(method, route string, limit int) {
+	pattern := "^" + routeParam.ReplaceAllString(regexp.QuoteMeta(route), `[^/]+`) + "/?$"
+	bodyLimitsMu.Lock()
+	defer bodyLimitsMu.Unlock()
+	bodyLimits = append(bodyLimits, raisedBodyLimit{method: method, path: regexp.MustCompile(pattern), limit: limit})
+}
+
+// bodyLimitFor возвращает предел тела для запроса: поднятый для маршрута или общий
+func bodyLimitFor(c *fiber.Ctx, limit int) int {
+	bodyLimitsMu.RLock()
+	defer bodyLimitsMu.RUnlock()
+	for _, raised := range bodyLimits {
+		if raised.method == c.Method() && raised.path.MatchString(c.Path()) {
+			return raised.limit
+		}
+	}
+	return limit
+}
+
+// BodyLimitMiddleware отклоняет с 413 тела больше limit, кроме маршрутов из RaiseBodyLimit.
+// Сервер читает тела потоком (StreamRequestBody), поэтому большое тело не попадает в память,
+// пока его не пропустит эта проверка.
+func BodyLimitMiddleware(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed := bodyLimitFor(c, limit)
+		tooLarge := func() error {
+			// The rest of the body is never read, so the connection can't carry another request
+			c.Context().SetConnectionClose()
+			return utils.Error(c, fiber.StatusRequestEntityTooLarge, errBodyTooLarge, fiber.Map{"limit_bytes": allowed})
+		}
+
+		length := c.Request().Header.ContentLength()
+		if length > allowed {
+			return tooLarge()
+		}
+		// Chunked bodies don't declare their size: read them up to the limit
+		if length == -1 && c.Request().IsBodyStream() {
+			data, err := io.ReadAll(io.LimitReader(c.Request().BodyStream(), int64(allowed)+1))
+			if err != nil {
+				return utils.BadRequest(c, "Cannot read request body")
+			}
+			if len(data) > allowed {
+				return tooLarge()
+			}
+			c.Request().SetBody(data)
+		}
+		return c.Next()
+	}
+}
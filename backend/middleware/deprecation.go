@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"project/backend/deprecation"
+	"project/backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Deprecated помечает маршрут устаревшим: добавляет заголовки Deprecation (RFC 9745), Sunset (RFC 8594)
+// и Link на замену, считает вызовы по клиентам, а после даты отключения отвечает 410 Gone.
+// route — "METHOD /path" в виде шаблона маршрута; он попадает в отчет сразу, еще до первого вызова.
+func Deprecated(route string, notice deprecation.Notice) fiber.Handler {
+	deprecation.Register(route, notice)
+	return func(c *fiber.Ctx) error {
+		c.Set("Deprecation", fmt.Sprintf("@%d", notice.Since.Unix()))
+		if !notice.Sunset.IsZero() {
+			c.Set("Sunset", notice.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if notice.Successor != "" {
+			c.Set(fiber.HeaderLink, fmt.Sprintf(`<%s>; rel="successor-version"`, notice.Successor))
+		}
+
+		now := time.Now()
+		if notice.Sunsetted(now) {
+			deprecation.Record(route, deprecatedRouteClient(c), c.Get(fiber.HeaderUserAgent), now)
+			return utils.Error(c, fiber.StatusGone, fmt.Errorf("%s was switched off on %s", route, notice.Sunset.Format("2006-01-02")),
+				fiber.Map{"successor": notice.Successor})
+		}
+
+		err := c.Next()
+		// The auth middleware may run after this one, so the caller is known only now
+		deprecation.Record(route, deprecatedRouteClient(c), c.Get(fiber.HeaderUserAgent), now)
+		return err
+	}
+}
+
+// deprecatedRouteClient называет вызывающего для отчета об устаревших маршрутах
+func deprecatedRouteClient(c *fiber.Ctx) string {
+	if consumer := apiConsumer(c); consumer != "" {
+		return consumer
+	}
+	return "anonymous"
+}
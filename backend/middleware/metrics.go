@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"project/backend/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsMiddleware считает запросы и ответы с ошибкой сервера для поиска аномалий
+func MetricsMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		metrics.Inc(metrics.Requests)
+		if responseStatus(c, err) >= fiber.StatusInternalServerError {
+			metrics.Inc(metrics.ServerErrors)
+		}
+		return err
+	}
+}
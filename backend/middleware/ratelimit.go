@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"project/backend/config"
+	"project/backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// RateLimitMiddleware ограничивает общее число запросов с одного IP.
+// storage можно передать для общего хранилища (например, Redis) между экземплярами,
+// nil означает хранение в памяти процесса.
+func RateLimitMiddleware(cfg *config.Config, storage fiber.Storage) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:          cfg.RateLimitMax,
+		Expiration:   time.Duration(cfg.RateLimitWindowSeconds) * time.Second,
+		KeyGenerator: func(c *fiber.Ctx) string { return "ip:" + c.IP() },
+		LimitReached: limitReached,
+		Storage:      storage,
+	})
+}
+
+// AuthRateLimitMiddleware - более строгий лимит для /api/auth/* (защита от перебора паролей)
+func AuthRateLimitMiddleware(cfg *config.Config, storage fiber.Storage) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:          cfg.AuthRateLimitMax,
+		Expiration:   time.Duration(cfg.RateLimitWindowSeconds) * time.Second,
+		KeyGenerator: func(c *fiber.Ctx) string { return "auth:" + c.IP() },
+		LimitReached: limitReached,
+		Storage:      storage,
+	})
+}
+
+// WriteRateLimitMiddleware ограничивает изменяющие запросы на пользователя
+// (для анонимных запросов ключом служит IP)
+func WriteRateLimitMiddleware(cfg *config.Config, storage fiber.Storage) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Next: func(c *fiber.Ctx) bool {
+			switch c.Method() {
+			case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+				return true
+			}
+			return false
+		},
+		Max:        cfg.WriteRateLimitMax,
+		Expiration: time.Duration(cfg.RateLimitWindowSeconds) * time.Second,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			if userID, err := utils.ExtractUserIDFromToken(c, cfg); err == nil {
+				return "write:user:" + strconv.Itoa(int(userID))
+			}
+			return "write:ip:" + c.IP()
+		},
+		LimitReached: limitReached,
+		Storage:      storage,
+	})
+}
+
+func limitReached(c *fiber.Ctx) error {
+	// Retry-After уже выставлен limiter-ом
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"success":     false,
+		"error":       "Too Many Requests",
+		"message":     "Rate limit exceeded, try again later",
+		"retry_after": c.GetRespHeader(fiber.HeaderRetryAfter),
+	})
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"project/backend/readonly"
+	"project/backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultReadOnlyMessage = "The service is temporarily read-only, please try again later"
+
+// readOnlyExempt — изменяющие запросы, которые работают и в режиме только для чтения:
+// вход (чтобы можно было читать) и переключение самого режима
+var readOnlyExempt = map[string]bool{
+	"/api/auth/login":               true,
+	"/api/admin/platform/read-only": true,
+}
+
+// ReadOnlyMiddleware отклоняет изменяющие запросы с 503, пока включен режим только для чтения; чтение продолжает работать
+func ReadOnlyMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+
+		state := readonly.Current()
+		if !state.Enabled || readOnlyExempt[c.Path()] {
+			return c.Next()
+		}
+
+		message := state.Message
+		if message == "" {
+			message = defaultReadOnlyMessage
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(utils.ErrorResponse{
+			Success: false,
+			Error:   http.StatusText(fiber.StatusServiceUnavailable),
+			Code:    utils.CodeReadOnly,
+			Message: message,
+			Details: fiber.Map{"source": state.Source, "since": state.Since},
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"project/backend/config"
+	"project/backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequestContextMiddleware присваивает запросу ID, ограничивает время обработки
+// и передает контекст в обработчики через c.UserContext().
+// Если запрос или один из запросов к БД не уложился в таймаут, возвращается 504.
+func RequestContextMiddleware(cfg *config.Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		c.Set("X-Request-ID", requestID)
+		c.Locals("request_id", requestID)
+
+		ctx, cancel := utils.NewRequestContext(c.UserContext(), requestID,
+			time.Duration(cfg.RequestTimeoutSeconds)*time.Second)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) || utils.QueryTimedOut(ctx) {
+			return c.Status(fiber.StatusGatewayTimeout).JSON(utils.ErrorResponse{
+				Success: false,
+				Error:   "Gateway Timeout",
+				Message: "Request took too long to process",
+				Details: fiber.Map{"request_id": requestID},
+			})
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"project/backend/models"
+	"project/backend/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// scopeRule сопоставляет группу эндпоинтов ресурсу области доступа
+type scopeRule struct {
+	prefix   string
+	resource string
+}
+
+// scopeRules — эндпоинты, доступные клиентским приложениям. Остальное (администрирование, наставники,
+// заказы и т.д.) токенам клиентов недоступно вовсе, даже если у пользователя есть на это права.
+// Правила с пустым ресурсом закрывают вложенные пути: учетные данные меняет только сам пользователь.
+var scopeRules = []scopeRule{
+	{"/api/user/api-keys", ""},
+	{"/api/user/account", ""},
+	{"/api/user/email", ""},
+	{"/api/user/security", ""},
+	{"/api/user/export", ""},
+	{"/api/user", "profile"},
+	{"/api/users", "profile"},
+	{"/api/courses", "courses"},
+	{"/api/comments", "courses"},
+	{"/api/tests", "tests"},
+	{"/api/exam-sessions", "tests"},
+	{"/api/progress", "progress"},
+	{"/api/overview", "progress"},
+	{"/api/analytics", "analytics"},
+	{"/api/admin/courses", "authoring"},
+	{"/api/admin/tests", "authoring"},
+}
+
+// clientDeniedFields — поля, которые клиентские приложения не меняют даже с областью на запись:
+// email и пароль меняет только сам пользователь
+var clientDeniedFields = map[string][]string{
+	"/api/user/profile": {"email", "old_password", "new_password"},
+}
+
+// deniedFields возвращает закрытые для клиентов поля, переданные в теле запроса на изменение
+func deniedFields(c *fiber.Ctx) []string {
+	fields := clientDeniedFields[strings.TrimSuffix(c.Path(), "/")]
+	if len(fields) == 0 || c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		return nil
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return nil
+	}
+	var denied []string
+	for _, field := range fields {
+		if value, ok := body[field]; ok && string(value) != `""` && string(value) != "null" {
+			denied = append(denied, field)
+		}
+	}
+	return denied
+}
+
+// requiredScope возвращает область, нужную для запроса: ресурс и :read для чтения или :write для изменений.
+// Пустая строка означает, что эндпоинт клиентам закрыт.
+func requiredScope(method, path string) string {
+	for _, rule := range scopeRules {
+		if path != rule.prefix && !strings.HasPrefix(path, rule.prefix+"/") {
+			continue
+		}
+		if rule.resource == "" {
+			return ""
+		}
+		if method == fiber.MethodGet || method == fiber.MethodHead {
+			return rule.resource + ":read"
+		}
+		return rule.resource + ":write"
+	}
+	return ""
+}
+
+// checkClientScope пропускает токен клиентского приложения, только если клиент не отозван,
+// а запрос входит в области, выданные пользователем и все еще разрешенные клиенту
+func checkClientScope(c *fiber.Ctx, db *gorm.DB, claims *utils.Claims) *utils.AuthError {
+	var client models.OAuthClient
+	if err := db.Where("client_id = ?", claims.ClientID).First(&client).Error; err != nil || client.RevokedAt != nil {
+		return utils.NewAuthError(utils.CodeClientRevoked, "The client app has been revoked")
+	}
+
+	scope := requiredScope(c.Method(), c.Path())
+	if scope == "" || !claims.HasScope(scope) || !utils.ScopeIncludes(client.Scopes, scope) {
+		return &utils.AuthError{
+			Status:  fiber.StatusForbidden,
+			Code:    utils.CodeInsufficientScope,
+			Message: "The token doesn't grant access to this endpoint",
+			Details: fiber.Map{"required_scope": scope},
+		}
+	}
+	if fields := deniedFields(c); len(fields) > 0 {
+		return &utils.AuthError{
+			Status:  fiber.StatusForbidden,
+			Code:    utils.CodeInsufficientScope,
+			Message: "Client apps can't change the email or password",
+			Details: fiber.Map{"fields": fields},
+		}
+	}
+	return nil
+}
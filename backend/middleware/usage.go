@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/usage"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UsageMiddleware считает запросы по потребителям API и маршрутам. Стоит перед ограничителями частоты,
+// чтобы отклоненные ими запросы тоже попадали в статистику.
+func UsageMiddleware(app *fiber.App) fiber.Handler {
+	// Routes are registered after the middleware, so the set is built on the first request
+	var once sync.Once
+	endpoints := map[string]bool{}
+	return func(c *fiber.Ctx) error {
+		once.Do(func() {
+			for _, route := range app.GetRoutes(true) {
+				endpoints[route.Method+" "+route.Path] = true
+			}
+		})
+
+		now := time.Now()
+		err := c.Next()
+
+		// Route and caller are known only after the router and the auth middleware have run
+		label := c.Method() + " " + c.Route().Path
+		if !endpoints[label] {
+			// Answered by a middleware (rate limit) or the 404 handler: the raw path would explode the cardinality
+			label = "(unmatched)"
+		}
+		consumer := apiConsumer(c)
+		if consumer == "" {
+			consumer = "ip:" + c.IP()
+		}
+		usage.Record(consumer, label, responseStatus(c, err), now)
+		return err
+	}
+}
+
+// apiConsumer называет вызывающего: клиентское приложение, API ключ или пользователь с токеном;
+// для анонимного запроса — пустая строка
+func apiConsumer(c *fiber.Ctx) string {
+	if clientID, ok := c.Locals("client_id").(string); ok && clientID != "" {
+		return "oauth:" + clientID
+	}
+	if keyID, ok := c.Locals("api_key_id").(uint); ok {
+		return fmt.Sprintf("api_key:%d", keyID)
+	}
+	if userID, ok := c.Locals("user_id").(uint); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return ""
+}
+
+// responseStatus возвращает статус ответа с учетом ошибки, которую еще обработает обработчик ошибок приложения
+func responseStatus(c *fiber.Ctx, err error) int {
+	// An error returned to the app's error handler hasn't been written to the response yet
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
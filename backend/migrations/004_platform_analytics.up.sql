@@ -0,0 +1,16 @@
+-- Дневные срезы метрик платформы (заполняются задачей platform-analytics)
+CREATE TABLE IF NOT EXISTS platform_analytics (
+    id SERIAL PRIMARY KEY,
+    total_users INTEGER DEFAULT 0,
+    active_users INTEGER DEFAULT 0,
+    courses_created INTEGER DEFAULT 0,
+    tests_created INTEGER DEFAULT 0,
+    avg_course_progress FLOAT DEFAULT 0,
+    avg_test_score FLOAT DEFAULT 0,
+    date VARCHAR(10) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_platform_analytics_date ON platform_analytics(date);
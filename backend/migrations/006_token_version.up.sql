@@ -0,0 +1,2 @@
+-- Версия токена: увеличивается при смене пароля, чтобы отозвать выданные JWT
+ALTER TABLE users ADD COLUMN token_version INTEGER DEFAULT 0;
@@ -0,0 +1,18 @@
+-- Приглашения для регистрации в закрытые группы
+CREATE TABLE invitations (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(64) UNIQUE NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    "group" VARCHAR(255),
+    university VARCHAR(255),
+    course_id INTEGER REFERENCES courses(id) ON DELETE SET NULL,
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_invitations_email ON invitations(email);
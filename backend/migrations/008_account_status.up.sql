@@ -0,0 +1,4 @@
+-- Деактивация и временный бан аккаунтов
+ALTER TABLE users ADD COLUMN active BOOLEAN DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN banned_until TIMESTAMP;
+ALTER TABLE users ADD COLUMN ban_reason TEXT;
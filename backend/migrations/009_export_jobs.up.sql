@@ -0,0 +1,18 @@
+-- Фоновые выгрузки ведомостей в CSV
+CREATE TABLE export_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending',
+    processed BIGINT DEFAULT 0,
+    total BIGINT DEFAULT 0,
+    file_path TEXT,
+    error TEXT,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_export_jobs_user_id ON export_jobs(user_id);
@@ -0,0 +1,12 @@
+-- Подписки на авторов
+CREATE TABLE user_follows (
+    id SERIAL PRIMARY KEY,
+    follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_user_follows_pair ON user_follows(follower_id, author_id);
+CREATE INDEX idx_user_follows_author_id ON user_follows(author_id);
@@ -0,0 +1,35 @@
+-- Иерархический каталог тем вместо свободного текста в courses.topic / tests.topic
+CREATE TABLE topics (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    parent_id INTEGER REFERENCES topics(id) ON DELETE RESTRICT,
+    path TEXT,
+    sort_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_topics_slug ON topics(slug);
+CREATE INDEX idx_topics_parent_id ON topics(parent_id);
+CREATE INDEX idx_topics_path ON topics(path text_pattern_ops);
+
+ALTER TABLE courses ADD COLUMN topic_id INTEGER REFERENCES topics(id) ON DELETE SET NULL;
+ALTER TABLE tests ADD COLUMN topic_id INTEGER REFERENCES topics(id) ON DELETE SET NULL;
+CREATE INDEX idx_courses_topic_id ON courses(topic_id);
+CREATE INDEX idx_tests_topic_id ON tests(topic_id);
+
+-- Существующие темы становятся корневыми узлами
+INSERT INTO topics (name, slug)
+SELECT DISTINCT topic, lower(regexp_replace(trim(topic), '[^[:alnum:]]+', '-', 'g'))
+FROM (SELECT topic FROM courses UNION SELECT topic FROM tests) AS existing
+WHERE trim(COALESCE(topic, '')) <> ''
+ON CONFLICT DO NOTHING;
+
+UPDATE topics SET path = '/' || id || '/' WHERE path IS NULL;
+
+UPDATE courses SET topic_id = t.id FROM topics t
+WHERE t.slug = lower(regexp_replace(trim(courses.topic), '[^[:alnum:]]+', '-', 'g'));
+UPDATE tests SET topic_id = t.id FROM topics t
+WHERE t.slug = lower(regexp_replace(trim(tests.topic), '[^[:alnum:]]+', '-', 'g'));
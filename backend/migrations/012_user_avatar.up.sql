@@ -0,0 +1,3 @@
+-- Аватары пользователей
+ALTER TABLE users ADD COLUMN avatar_url TEXT;
+ALTER TABLE users ADD COLUMN avatar_key TEXT;
@@ -0,0 +1,57 @@
+-- Справочник университетов и подтверждение принадлежности по email
+CREATE TABLE universities (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    short_name VARCHAR(100),
+    country VARCHAR(100),
+    website TEXT,
+    logo_url TEXT,
+    email_domains TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_universities_name ON universities(name);
+CREATE UNIQUE INDEX idx_universities_slug ON universities(slug);
+
+CREATE TABLE affiliation_verifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    university_id INTEGER REFERENCES universities(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    verified_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_affiliation_verifications_token_hash ON affiliation_verifications(token_hash);
+CREATE INDEX idx_affiliation_verifications_user_id ON affiliation_verifications(user_id);
+
+ALTER TABLE users ADD COLUMN university_id INTEGER REFERENCES universities(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN university_verified_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN university_email VARCHAR(255);
+ALTER TABLE courses ADD COLUMN university_id INTEGER REFERENCES universities(id) ON DELETE SET NULL;
+ALTER TABLE tests ADD COLUMN university_id INTEGER REFERENCES universities(id) ON DELETE SET NULL;
+CREATE INDEX idx_users_university_id ON users(university_id);
+CREATE INDEX idx_courses_university_id ON courses(university_id);
+CREATE INDEX idx_tests_university_id ON tests(university_id);
+
+-- Существующие значения переносятся в справочник как есть (без доменов, их заполняет администратор)
+INSERT INTO universities (name, slug)
+SELECT DISTINCT trim(university), lower(regexp_replace(trim(university), '[^[:alnum:]]+', '-', 'g'))
+FROM (
+    SELECT university FROM users
+    UNION SELECT university FROM courses
+    UNION SELECT university FROM tests
+) AS existing
+WHERE trim(COALESCE(university, '')) <> ''
+ON CONFLICT DO NOTHING;
+
+UPDATE users SET university_id = u.id FROM universities u WHERE u.name = trim(users.university);
+UPDATE courses SET university_id = u.id FROM universities u WHERE u.name = trim(courses.university);
+UPDATE tests SET university_id = u.id FROM universities u WHERE u.name = trim(tests.university);
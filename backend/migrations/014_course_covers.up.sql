@@ -0,0 +1,14 @@
+-- Обложки курсов: загрузка с обрезкой и стандартная галерея
+CREATE TABLE stock_covers (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    key TEXT,
+    sort_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+ALTER TABLE courses ADD COLUMN cover_key TEXT;
+ALTER TABLE courses ADD COLUMN stock_cover_id INTEGER REFERENCES stock_covers(id) ON DELETE SET NULL;
@@ -0,0 +1,16 @@
+-- Настройки пользователей: язык, часовой пояс, тема и email-уведомления
+CREATE TABLE user_settings (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    locale VARCHAR(10) DEFAULT 'en',
+    timezone VARCHAR(64) DEFAULT 'UTC',
+    theme VARCHAR(10) DEFAULT 'system',
+    email_notifications BOOLEAN DEFAULT TRUE,
+    email_comments BOOLEAN DEFAULT TRUE,
+    email_course_updates BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_user_settings_user_id ON user_settings(user_id);
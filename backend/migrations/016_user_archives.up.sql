@@ -0,0 +1,16 @@
+-- Холодное хранилище данных неактивных пользователей
+ALTER TABLE users ADD COLUMN archived_at TIMESTAMP;
+
+CREATE TABLE user_archives (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL,
+    size INTEGER,
+    archived_at TIMESTAMP NOT NULL,
+    restored_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_user_archives_user_id ON user_archives(user_id);
@@ -0,0 +1,14 @@
+-- Удаление аккаунтов: отложенная очистка и сохранение анонимизированных комментариев
+ALTER TABLE users ADD COLUMN purge_after TIMESTAMP;
+CREATE INDEX idx_users_purge_after ON users(purge_after) WHERE purge_after IS NOT NULL;
+
+-- Comments outlive their authors: purging a user only detaches them
+ALTER TABLE course_comments DROP CONSTRAINT IF EXISTS course_comments_user_id_fkey;
+ALTER TABLE course_comments ADD CONSTRAINT course_comments_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE course_comment_replies DROP CONSTRAINT IF EXISTS course_comment_replies_user_id_fkey;
+ALTER TABLE course_comment_replies ADD CONSTRAINT course_comment_replies_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE test_comments DROP CONSTRAINT IF EXISTS test_comments_user_id_fkey;
+ALTER TABLE test_comments ADD CONSTRAINT test_comments_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,38 @@
+-- Месячное секционирование login_history по login_time.
+-- Новые секции заранее создает фоновая задача partition-maintenance, старые она же удаляет по сроку хранения.
+ALTER TABLE login_history RENAME TO login_history_legacy;
+
+CREATE TABLE login_history (
+    id SERIAL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    login_time TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    PRIMARY KEY (id, login_time)
+) PARTITION BY RANGE (login_time);
+
+CREATE INDEX idx_login_history_user_time ON login_history(user_id, login_time DESC);
+
+-- Rows outside every monthly partition land here instead of failing the insert
+CREATE TABLE login_history_default PARTITION OF login_history DEFAULT;
+
+-- Partitions for the existing data and the next three months
+DO $$
+DECLARE
+    month DATE := date_trunc('month', COALESCE((SELECT MIN(login_time) FROM login_history_legacy), now()));
+BEGIN
+    WHILE month <= date_trunc('month', now()) + INTERVAL '3 months' LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF login_history FOR VALUES FROM (%L) TO (%L)',
+            'login_history_' || to_char(month, '"y"YYYY"m"MM'), month, month + INTERVAL '1 month');
+        month := month + INTERVAL '1 month';
+    END LOOP;
+END $$;
+
+INSERT INTO login_history (id, user_id, login_time, created_at, updated_at)
+SELECT id, user_id, COALESCE(login_time, created_at, now()), created_at, created_at
+FROM login_history_legacy;
+
+SELECT setval(pg_get_serial_sequence('login_history', 'id'), COALESCE((SELECT MAX(id) FROM login_history), 0) + 1, false);
+
+DROP TABLE login_history_legacy;
@@ -0,0 +1,6 @@
+-- Составные индексы под частые выборки прогресса и фильтры доступа
+CREATE INDEX IF NOT EXISTS idx_user_course_progress_user_course ON user_course_progress(user_id, course_id);
+CREATE INDEX IF NOT EXISTS idx_user_test_progress_user_test ON user_test_progress(user_id, test_id);
+CREATE INDEX IF NOT EXISTS idx_user_test_progress_test_updated ON user_test_progress(test_id, updated_at);
+CREATE INDEX IF NOT EXISTS idx_course_access_settings_level ON course_access_settings(access_level, course_id);
+CREATE INDEX IF NOT EXISTS idx_test_access_settings_level ON test_access_settings(access_level, test_id);
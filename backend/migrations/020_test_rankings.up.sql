@@ -0,0 +1,21 @@
+-- Рейтинг по тестам: места считаются оконной функцией и обновляются при каждой попытке
+CREATE TABLE test_rankings (
+    id SERIAL PRIMARY KEY,
+    test_id INTEGER NOT NULL REFERENCES tests(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rank INTEGER NOT NULL,
+    previous_rank INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_test_rankings_pair ON test_rankings(test_id, user_id);
+CREATE INDEX idx_test_rankings_rank ON test_rankings(test_id, rank);
+
+-- Начальное заполнение по уже сохраненным результатам
+INSERT INTO test_rankings (test_id, user_id, score, rank)
+SELECT test_id, user_id, score, RANK() OVER (PARTITION BY test_id ORDER BY score DESC)
+FROM user_test_progress
+WHERE deleted_at IS NULL;
@@ -0,0 +1,14 @@
+-- Роли внутри курса (ассистенты преподавателя)
+CREATE TABLE course_staff (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL DEFAULT 'ta',
+    added_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_course_staff_pair ON course_staff(course_id, user_id);
+CREATE INDEX idx_course_staff_user_id ON course_staff(user_id);
@@ -0,0 +1,59 @@
+-- Учебные группы и членство в них вместо свободного текста в users.group и recommended_for
+CREATE TABLE study_groups (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    university_id INTEGER REFERENCES universities(id) ON DELETE SET NULL,
+    university VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_study_groups_slug ON study_groups(slug);
+CREATE INDEX idx_study_groups_university_id ON study_groups(university_id);
+
+CREATE TABLE study_group_members (
+    id SERIAL PRIMARY KEY,
+    group_id INTEGER NOT NULL REFERENCES study_groups(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_study_group_members_pair ON study_group_members(group_id, user_id);
+CREATE INDEX idx_study_group_members_user_id ON study_group_members(user_id);
+
+ALTER TABLE users ADD COLUMN study_group_id INTEGER REFERENCES study_groups(id) ON DELETE SET NULL;
+ALTER TABLE courses ADD COLUMN recommended_group_id INTEGER REFERENCES study_groups(id) ON DELETE SET NULL;
+ALTER TABLE tests ADD COLUMN recommended_group_id INTEGER REFERENCES study_groups(id) ON DELETE SET NULL;
+CREATE INDEX idx_users_study_group_id ON users(study_group_id);
+CREATE INDEX idx_courses_recommended_group_id ON courses(recommended_group_id);
+CREATE INDEX idx_tests_recommended_group_id ON tests(recommended_group_id);
+
+-- Существующие значения переносятся как есть; одинаковые написания в разных регистрах и с пробелами сливаются
+INSERT INTO study_groups (name, slug)
+SELECT DISTINCT ON (slug) name, slug
+FROM (
+    SELECT trim(value) AS name, lower(regexp_replace(trim(value), '[^[:alnum:]]+', '-', 'g')) AS slug
+    FROM (
+        SELECT "group" AS value FROM users
+        UNION SELECT recommended_for FROM courses
+        UNION SELECT recommended_for FROM tests
+    ) AS existing
+    WHERE trim(COALESCE(value, '')) <> ''
+) AS cleaned
+ORDER BY slug, name
+ON CONFLICT DO NOTHING;
+
+UPDATE users SET study_group_id = g.id, "group" = g.name FROM study_groups g
+WHERE g.slug = lower(regexp_replace(trim(users."group"), '[^[:alnum:]]+', '-', 'g'));
+UPDATE courses SET recommended_group_id = g.id, recommended_for = g.name FROM study_groups g
+WHERE g.slug = lower(regexp_replace(trim(courses.recommended_for), '[^[:alnum:]]+', '-', 'g'));
+UPDATE tests SET recommended_group_id = g.id, recommended_for = g.name FROM study_groups g
+WHERE g.slug = lower(regexp_replace(trim(tests.recommended_for), '[^[:alnum:]]+', '-', 'g'));
+
+INSERT INTO study_group_members (group_id, user_id)
+SELECT study_group_id, id FROM users WHERE study_group_id IS NOT NULL;
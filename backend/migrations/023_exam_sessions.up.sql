@@ -0,0 +1,47 @@
+-- Экзаменационные сессии с прокторами и попытками студентов
+CREATE TABLE exam_sessions (
+    id SERIAL PRIMARY KEY,
+    test_id INTEGER NOT NULL REFERENCES tests(id) ON DELETE CASCADE,
+    title VARCHAR(255),
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    duration_minutes INTEGER NOT NULL DEFAULT 0,
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_exam_sessions_test_id ON exam_sessions(test_id);
+
+CREATE TABLE exam_session_proctors (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES exam_sessions(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_exam_session_proctors_pair ON exam_session_proctors(session_id, user_id);
+CREATE INDEX idx_exam_session_proctors_user_id ON exam_session_proctors(user_id);
+
+CREATE TABLE exam_attempts (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES exam_sessions(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    started_at TIMESTAMP NOT NULL,
+    submitted_at TIMESTAMP,
+    extra_minutes INTEGER NOT NULL DEFAULT 0,
+    extended_by INTEGER,
+    invalidated_at TIMESTAMP,
+    invalidated_by INTEGER,
+    invalidation_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_exam_attempts_pair ON exam_attempts(session_id, user_id);
+CREATE INDEX idx_exam_attempts_user_id ON exam_attempts(user_id);
@@ -0,0 +1,40 @@
+-- Анкеты по окончании курса и сертификаты о прохождении
+CREATE TABLE course_surveys (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    questions TEXT,
+    required BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_course_surveys_course_id ON course_surveys(course_id);
+
+CREATE TABLE course_survey_responses (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    answers TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_course_survey_responses_pair ON course_survey_responses(course_id, user_id);
+CREATE INDEX idx_course_survey_responses_user_id ON course_survey_responses(user_id);
+
+CREATE TABLE course_certificates (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    serial VARCHAR(64) NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_course_certificates_pair ON course_certificates(course_id, user_id);
+CREATE UNIQUE INDEX idx_course_certificates_serial ON course_certificates(serial);
+CREATE INDEX idx_course_certificates_user_id ON course_certificates(user_id);
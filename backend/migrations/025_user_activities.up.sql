@@ -0,0 +1,20 @@
+-- Лента активности пользователей
+CREATE TABLE IF NOT EXISTS user_activities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action_type VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20),
+    target_id INTEGER,
+    target_title VARCHAR(255),
+    subject_id INTEGER,
+    subject_title VARCHAR(255),
+    score DOUBLE PRECISION DEFAULT 0,
+    timestamp VARCHAR(64),
+    duration DOUBLE PRECISION DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Лента читается keyset-пагинацией от новых к старым
+CREATE INDEX idx_user_activities_feed ON user_activities(user_id, created_at DESC, id DESC);
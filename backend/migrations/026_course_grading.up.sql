@@ -0,0 +1,55 @@
+-- Итоговая оценка курса: составляющие с весами, ручные баллы, пересчитанные оценки и шкалы университетов
+CREATE TABLE course_grade_components (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    name VARCHAR(255),
+    kind VARCHAR(20) NOT NULL,
+    weight DOUBLE PRECISION NOT NULL,
+    test_ids TEXT,
+    target INTEGER DEFAULT 0,
+    sequence_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_course_grade_components_course_id ON course_grade_components(course_id);
+
+CREATE TABLE course_grade_entries (
+    id SERIAL PRIMARY KEY,
+    component_id INTEGER NOT NULL REFERENCES course_grade_components(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION DEFAULT 0,
+    graded_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_course_grade_entries_pair ON course_grade_entries(component_id, user_id);
+CREATE INDEX idx_course_grade_entries_user_id ON course_grade_entries(user_id);
+
+CREATE TABLE course_grades (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    percent DOUBLE PRECISION DEFAULT 0,
+    letter VARCHAR(10),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_course_grades_pair ON course_grades(course_id, user_id);
+CREATE INDEX idx_course_grades_user_id ON course_grades(user_id);
+
+CREATE TABLE grade_scales (
+    id SERIAL PRIMARY KEY,
+    university_id INTEGER NOT NULL REFERENCES universities(id) ON DELETE CASCADE,
+    bands TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_grade_scales_university_id ON grade_scales(university_id);
@@ -0,0 +1,11 @@
+-- Настраиваемые колонки выгрузки оценок курса в SIS
+CREATE TABLE course_sis_mappings (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    columns TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_course_sis_mappings_course_id ON course_sis_mappings(course_id);
@@ -0,0 +1,4 @@
+-- Настройки приватности профиля
+ALTER TABLE user_settings ADD COLUMN hide_email BOOLEAN DEFAULT FALSE;
+ALTER TABLE user_settings ADD COLUMN hide_university BOOLEAN DEFAULT FALSE;
+ALTER TABLE user_settings ADD COLUMN private_profile BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,16 @@
+-- Месячные срезы прогресса пользователей (заполняются задачей progress-snapshots)
+CREATE TABLE IF NOT EXISTS user_progress_snapshots (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period VARCHAR(7) NOT NULL,
+    total_hours FLOAT DEFAULT 0,
+    hours FLOAT DEFAULT 0,
+    lessons INTEGER DEFAULT 0,
+    avg_score FLOAT DEFAULT 0,
+    best_streak INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_progress_snapshots_period ON user_progress_snapshots(user_id, period);
@@ -0,0 +1,15 @@
+-- Связи наставников со студентами: просмотр прогресса только с согласия студента
+CREATE TABLE IF NOT EXISTS mentor_links (
+    id SERIAL PRIMARY KEY,
+    mentor_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    student_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    note TEXT,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentor_links_pair ON mentor_links(mentor_id, student_id);
+CREATE INDEX IF NOT EXISTS idx_mentor_links_student_id ON mentor_links(student_id);
@@ -0,0 +1,15 @@
+-- Смена email с подтверждением по коду, отправленному на новый адрес
+CREATE TABLE IF NOT EXISTS email_changes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    confirmed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_changes_token_hash ON email_changes(token_hash);
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id ON email_changes(user_id);
@@ -0,0 +1,28 @@
+-- Поминутные счетчики запросов, ошибок и неудачных входов (пишутся каждым экземпляром)
+CREATE TABLE IF NOT EXISTS metric_buckets (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    value BIGINT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_buckets_name_bucket ON metric_buckets(name, bucket);
+
+-- Аномалии метрик платформы (заполняются задачей anomaly-detection)
+CREATE TABLE IF NOT EXISTS platform_alerts (
+    id SERIAL PRIMARY KEY,
+    metric VARCHAR(50) NOT NULL,
+    window_start TIMESTAMP NOT NULL,
+    window_end TIMESTAMP NOT NULL,
+    value FLOAT DEFAULT 0,
+    baseline FLOAT DEFAULT 0,
+    message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_platform_alerts_window ON platform_alerts(metric, window_start);
@@ -0,0 +1,9 @@
+-- Подробности входов: откуда и с какого устройства, неудачные попытки и отметки о новых устройствах/странах.
+-- Колонки добавляются в секционированную таблицу и наследуются всеми секциями.
+ALTER TABLE login_history ADD COLUMN ip VARCHAR(45);
+ALTER TABLE login_history ADD COLUMN user_agent TEXT;
+ALTER TABLE login_history ADD COLUMN device VARCHAR(100);
+ALTER TABLE login_history ADD COLUMN country VARCHAR(2);
+ALTER TABLE login_history ADD COLUMN success BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE login_history ADD COLUMN new_device BOOLEAN DEFAULT FALSE;
+ALTER TABLE login_history ADD COLUMN new_country BOOLEAN DEFAULT FALSE;
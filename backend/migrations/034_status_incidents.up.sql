@@ -0,0 +1,16 @@
+-- Заметки об инцидентах и плановых работах для публичной страницы статуса
+CREATE TABLE IF NOT EXISTS status_incidents (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    message TEXT,
+    severity VARCHAR(20) NOT NULL DEFAULT 'minor',
+    components VARCHAR(255),
+    started_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_incidents_resolved_at ON status_incidents(resolved_at);
@@ -0,0 +1,15 @@
+-- Разделы курса: уроки группируются по модулям, уроки без модуля остаются на верхнем уровне
+CREATE TABLE IF NOT EXISTS course_modules (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    title VARCHAR(255),
+    sequence_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_course_modules_course_id ON course_modules(course_id);
+
+ALTER TABLE lessons ADD COLUMN module_id INTEGER REFERENCES course_modules(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_lessons_module_id ON lessons(module_id);
@@ -0,0 +1,12 @@
+-- Настройки всей платформы, общие для всех экземпляров API (например, режим только для чтения)
+CREATE TABLE IF NOT EXISTS platform_settings (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(100) NOT NULL,
+    value TEXT,
+    updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_platform_settings_key ON platform_settings(key);
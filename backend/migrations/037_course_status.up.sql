@@ -0,0 +1,7 @@
+-- Жизненный цикл курса: draft, review, published, archived.
+-- Курсы, созданные до появления статусов, уже видны в каталоге, поэтому считаются опубликованными.
+ALTER TABLE courses ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft';
+ALTER TABLE courses ADD COLUMN published_at TIMESTAMP;
+UPDATE courses SET status = 'published', published_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_courses_status ON courses(status);
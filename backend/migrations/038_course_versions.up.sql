@@ -0,0 +1,15 @@
+-- Версии курса: снимок разделов и уроков сохраняется при каждой публикации
+CREATE TABLE IF NOT EXISTS course_versions (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    changelog TEXT,
+    changes TEXT,
+    snapshot TEXT,
+    published_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_course_versions_number ON course_versions(course_id, number);
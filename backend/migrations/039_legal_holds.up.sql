@@ -0,0 +1,16 @@
+-- Юридические удержания пользователей и контента; строки не удаляются и служат журналом
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id SERIAL PRIMARY KEY,
+    subject_type VARCHAR(20) NOT NULL,
+    subject_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    applied_by INTEGER,
+    released_at TIMESTAMP,
+    released_by INTEGER,
+    release_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_legal_holds_subject ON legal_holds(subject_type, subject_id);
@@ -0,0 +1,36 @@
+-- Каталог курсов для обмена между организациями: заявки с модерацией и импорт курсов из них
+CREATE TABLE IF NOT EXISTS marketplace_listings (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    submitted_by INTEGER NOT NULL,
+    university VARCHAR(255),
+    title VARCHAR(255),
+    summary TEXT,
+    license VARCHAR(20),
+    attribution TEXT,
+    bundle TEXT,
+    status VARCHAR(20) DEFAULT 'pending',
+    reviewed_by INTEGER,
+    reviewed_at TIMESTAMP,
+    review_note TEXT,
+    imports INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_marketplace_listings_course_id ON marketplace_listings(course_id);
+CREATE INDEX IF NOT EXISTS idx_marketplace_listings_status ON marketplace_listings(status);
+
+CREATE TABLE IF NOT EXISTS marketplace_imports (
+    id SERIAL PRIMARY KEY,
+    listing_id INTEGER NOT NULL REFERENCES marketplace_listings(id) ON DELETE CASCADE,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    imported_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_marketplace_imports_listing_id ON marketplace_imports(listing_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_marketplace_imports_course_id ON marketplace_imports(course_id);
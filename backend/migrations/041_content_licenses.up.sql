@@ -0,0 +1,14 @@
+-- Лицензии курсов и тестов. Существующий контент считается закрытым, пока автор не выберет открытую лицензию.
+-- source_* хранят обязательства перед открытым контентом, из которого курс скопирован или импортирован.
+ALTER TABLE courses ADD COLUMN license VARCHAR(20) NOT NULL DEFAULT 'proprietary';
+ALTER TABLE courses ADD COLUMN attribution TEXT;
+ALTER TABLE courses ADD COLUMN source_license VARCHAR(20);
+ALTER TABLE courses ADD COLUMN source_attribution TEXT;
+
+ALTER TABLE tests ADD COLUMN license VARCHAR(20) NOT NULL DEFAULT 'proprietary';
+ALTER TABLE tests ADD COLUMN attribution TEXT;
+
+-- Courses imported from the marketplace keep the license they were shared under
+UPDATE courses SET license = l.license, attribution = l.attribution, source_license = l.license, source_attribution = l.attribution
+FROM marketplace_imports i JOIN marketplace_listings l ON l.id = i.listing_id
+WHERE i.course_id = courses.id;
@@ -0,0 +1,17 @@
+-- Списки допуска к курсам с уровнем доступа restricted: пользователи, email и учебные группы
+CREATE TABLE IF NOT EXISTS course_access_lists (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    group_id INTEGER REFERENCES study_groups(id) ON DELETE CASCADE,
+    added_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_course_access_lists_course_id ON course_access_lists(course_id);
+CREATE INDEX IF NOT EXISTS idx_course_access_lists_user_id ON course_access_lists(user_id);
+CREATE INDEX IF NOT EXISTS idx_course_access_lists_email ON course_access_lists(email);
+CREATE INDEX IF NOT EXISTS idx_course_access_lists_group_id ON course_access_lists(group_id);
@@ -0,0 +1,12 @@
+-- Со-администраторы курсов переезжают из строки course_access_settings.admins ("3,15,42") в course_staff с ролью editor.
+-- Колонка остается для экземпляров предыдущей версии, новый код ее не читает.
+INSERT INTO course_staff (course_id, user_id, role, added_by, created_at, updated_at)
+SELECT DISTINCT s.course_id, CAST(TRIM(a.id) AS INTEGER), 'editor', c.author_id, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM course_access_settings s
+JOIN courses c ON c.id = s.course_id
+CROSS JOIN LATERAL UNNEST(STRING_TO_ARRAY(s.admins, ',')) AS a(id)
+WHERE s.deleted_at IS NULL
+  AND TRIM(a.id) ~ '^[0-9]+$'
+  AND CAST(TRIM(a.id) AS INTEGER) <> c.author_id
+  AND EXISTS (SELECT 1 FROM users u WHERE u.id = CAST(TRIM(a.id) AS INTEGER))
+ON CONFLICT (course_id, user_id) DO NOTHING;
@@ -0,0 +1,29 @@
+-- Правила курса, которые студент подтверждает до первого урока или попытки, и журнал подтверждений для разбора споров
+CREATE TABLE IF NOT EXISTS course_conducts (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    statement TEXT,
+    version INTEGER DEFAULT 1,
+    required BOOLEAN DEFAULT FALSE,
+    updated_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_course_conducts_course_id ON course_conducts(course_id);
+
+CREATE TABLE IF NOT EXISTS course_conduct_acknowledgements (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    statement TEXT,
+    acknowledged_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_course_conduct_acks_triple ON course_conduct_acknowledgements(course_id, user_id, version);
+CREATE INDEX IF NOT EXISTS idx_course_conduct_acknowledgements_user_id ON course_conduct_acknowledgements(user_id);
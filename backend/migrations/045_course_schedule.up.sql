@@ -0,0 +1,30 @@
+-- Даты начала и окончания курса как время: прогресс принимается только внутри этого окна,
+-- а закончившиеся курсы переносятся в архив. Текстовые start_date/end_date остаются для старых экземпляров.
+ALTER TABLE course_access_settings ADD COLUMN starts_at TIMESTAMP;
+ALTER TABLE course_access_settings ADD COLUMN ends_at TIMESTAMP;
+
+-- Dates typed by hand may not parse; those rows keep an open schedule
+DO $$
+DECLARE
+    r RECORD;
+BEGIN
+    FOR r IN SELECT id, start_date, end_date FROM course_access_settings
+             WHERE COALESCE(start_date, '') <> '' OR COALESCE(end_date, '') <> '' LOOP
+        BEGIN
+            UPDATE course_access_settings SET starts_at = CASE
+                WHEN r.start_date ~ '^\d{4}-\d{2}-\d{2}$' THEN r.start_date::date::timestamp
+                WHEN COALESCE(r.start_date, '') <> '' THEN (r.start_date::timestamptz AT TIME ZONE 'UTC')
+            END WHERE id = r.id;
+        EXCEPTION WHEN others THEN NULL;
+        END;
+        BEGIN
+            UPDATE course_access_settings SET ends_at = CASE
+                WHEN r.end_date ~ '^\d{4}-\d{2}-\d{2}$' THEN r.end_date::date::timestamp + INTERVAL '1 day'
+                WHEN COALESCE(r.end_date, '') <> '' THEN (r.end_date::timestamptz AT TIME ZONE 'UTC')
+            END WHERE id = r.id;
+        EXCEPTION WHEN others THEN NULL;
+        END;
+    END LOOP;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_course_access_settings_ends_at ON course_access_settings(ends_at) WHERE ends_at IS NOT NULL;
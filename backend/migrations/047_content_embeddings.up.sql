@@ -0,0 +1,16 @@
+-- Векторы текстов курсов и уроков для отчета о дублированном контенте
+CREATE TABLE IF NOT EXISTS content_embeddings (
+    id SERIAL PRIMARY KEY,
+    subject_type VARCHAR(20) NOT NULL,
+    subject_id INTEGER NOT NULL,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    embed_model VARCHAR(100),
+    checksum VARCHAR(64),
+    vector TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_embeddings_subject ON content_embeddings(subject_type, subject_id);
+CREATE INDEX IF NOT EXISTS idx_content_embeddings_course_id ON content_embeddings(course_id);
@@ -0,0 +1,22 @@
+-- Переводы вопросов тестов: черновики машинного перевода, которые автор проверяет перед показом студентам
+CREATE TABLE IF NOT EXISTS test_question_translations (
+    id SERIAL PRIMARY KEY,
+    test_id INTEGER NOT NULL REFERENCES tests(id) ON DELETE CASCADE,
+    question_id INTEGER NOT NULL REFERENCES test_questions(id) ON DELETE CASCADE,
+    locale VARCHAR(10) NOT NULL,
+    title TEXT,
+    description TEXT,
+    question TEXT,
+    options TEXT,
+    status VARCHAR(20) DEFAULT 'needs_review',
+    source VARCHAR(20),
+    source_checksum VARCHAR(64),
+    reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_question_translations_locale ON test_question_translations(question_id, locale);
+CREATE INDEX IF NOT EXISTS idx_test_question_translations_test_id ON test_question_translations(test_id);
@@ -0,0 +1,26 @@
+-- Платные курсы: цена курса и заказы, оплаченные через платежного провайдера
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS price BIGINT DEFAULT 0;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS currency VARCHAR(3);
+
+-- Заказы не ссылаются на users: они остаются после удаления аккаунта
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    course_id INTEGER NOT NULL REFERENCES courses(id),
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending',
+    provider VARCHAR(20),
+    provider_session_id VARCHAR(255),
+    provider_payment_id VARCHAR(255),
+    checkout_url TEXT,
+    paid_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_course_id ON orders(course_id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
+CREATE INDEX IF NOT EXISTS idx_orders_provider_session_id ON orders(provider_session_id);
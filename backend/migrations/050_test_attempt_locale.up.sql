@@ -0,0 +1,2 @@
+-- Язык варианта теста, на котором студент сдавал последнюю попытку (пусто — исходный текст)
+ALTER TABLE user_test_progress ADD COLUMN IF NOT EXISTS locale VARCHAR(10) DEFAULT '';
@@ -0,0 +1,20 @@
+-- Потоки курса: одно содержание для нескольких учебных групп со своими датами, составом и аналитикой
+CREATE TABLE IF NOT EXISTS course_runs (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    group_id INTEGER REFERENCES study_groups(id) ON DELETE SET NULL,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_course_runs_course_id ON course_runs(course_id);
+CREATE INDEX IF NOT EXISTS idx_course_runs_group_id ON course_runs(group_id);
+
+-- Поток, в котором студент проходит курс; прогресс до появления потоков остается без него
+ALTER TABLE user_course_progress ADD COLUMN IF NOT EXISTS run_id INTEGER REFERENCES course_runs(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_user_course_progress_run_id ON user_course_progress(run_id);
@@ -0,0 +1,15 @@
+-- Объявления курса для записанных студентов, по всему курсу или одному его потоку
+CREATE TABLE IF NOT EXISTS announcements (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    run_id INTEGER REFERENCES course_runs(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_announcements_course_id ON announcements(course_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_announcements_run_id ON announcements(run_id);
@@ -0,0 +1,20 @@
+-- Ограничение времени на вопрос (блиц-тесты): 0 — без ограничения
+ALTER TABLE test_questions ADD COLUMN IF NOT EXISTS time_limit_seconds INTEGER DEFAULT 0;
+
+-- Когда вопрос выдан студенту в попытке и когда пришел ответ; по ним сервер проверяет лимит времени
+CREATE TABLE IF NOT EXISTS question_serves (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    test_id INTEGER NOT NULL REFERENCES tests(id) ON DELETE CASCADE,
+    question_id INTEGER NOT NULL REFERENCES test_questions(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    served_at TIMESTAMP NOT NULL,
+    answered_at TIMESTAMP,
+    answer INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_question_serves_attempt ON question_serves(user_id, question_id, attempt);
+CREATE INDEX IF NOT EXISTS idx_question_serves_test_id ON question_serves(test_id);
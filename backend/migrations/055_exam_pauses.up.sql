@@ -0,0 +1,9 @@
+-- Бюджет пауз на попытку экзамена в минутах: 0 — паузы запрещены
+ALTER TABLE test_access_settings ADD COLUMN IF NOT EXISTS max_pause_minutes INTEGER DEFAULT 0;
+
+-- Пауза попытки: время на паузе не входит в личный лимит сессии, продолжить можно по токену
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP;
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS pause_ends_at TIMESTAMP;
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS paused_seconds INTEGER DEFAULT 0;
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS pauses INTEGER DEFAULT 0;
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS resume_token VARCHAR(64);
@@ -0,0 +1,15 @@
+-- Ответы, сохраненные по ходу попытки; при сдаче засчитываются и удаляются
+CREATE TABLE IF NOT EXISTS attempt_answers (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    test_id INTEGER NOT NULL REFERENCES tests(id) ON DELETE CASCADE,
+    question_id INTEGER NOT NULL REFERENCES test_questions(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    answer INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attempt_answers_attempt ON attempt_answers(user_id, question_id, attempt);
+CREATE INDEX IF NOT EXISTS idx_attempt_answers_test_id ON attempt_answers(test_id);
@@ -0,0 +1,4 @@
+-- Правила прохождения курса: доля уроков и итоговый тест с проходным баллом
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS completion_lesson_percent INTEGER DEFAULT 100;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS completion_final_test_id INTEGER REFERENCES tests(id) ON DELETE SET NULL;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS completion_min_score FLOAT DEFAULT 0;
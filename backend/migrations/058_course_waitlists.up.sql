@@ -0,0 +1,16 @@
+-- Число мест на курсе: 0 — без ограничения
+ALTER TABLE course_access_settings ADD COLUMN IF NOT EXISTS max_enrollments INTEGER DEFAULT 0;
+
+-- Очередь на курс, все места которого заняты
+CREATE TABLE IF NOT EXISTS course_waitlists (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    offered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_course_waitlists_pair ON course_waitlists(course_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_course_waitlists_user_id ON course_waitlists(user_id);
@@ -0,0 +1,23 @@
+-- Оценки курсов переезжают из комментариев в отзывы: один отзыв пользователя на курс
+CREATE TABLE IF NOT EXISTS course_reviews (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    rating INTEGER NOT NULL CHECK (rating >= 1 AND rating <= 5),
+    text TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_course_reviews_pair ON course_reviews(course_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_course_reviews_user_id ON course_reviews(user_id);
+
+-- Из нескольких оцененных комментариев пользователя отзывом становится последний.
+-- Колонка course_comments.rating остается для экземпляров предыдущей версии, новый код ее не читает.
+INSERT INTO course_reviews (course_id, user_id, rating, text, created_at, updated_at)
+SELECT DISTINCT ON (course_id, user_id) course_id, user_id, rating, text, created_at, updated_at
+FROM course_comments
+WHERE rating BETWEEN 1 AND 5 AND deleted_at IS NULL
+ORDER BY course_id, user_id, created_at DESC, id DESC
+ON CONFLICT (course_id, user_id) DO NOTHING;
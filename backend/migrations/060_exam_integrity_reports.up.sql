@@ -0,0 +1,19 @@
+-- Что нужно отчету о честности: балл и ответы каждой попытки, IP при начале и сдаче
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS score FLOAT;
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS answers TEXT;
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS start_ip VARCHAR(45);
+ALTER TABLE exam_attempts ADD COLUMN IF NOT EXISTS submit_ip VARCHAR(45);
+
+-- Отчет строится один раз после закрытия сессии
+CREATE TABLE IF NOT EXISTS exam_integrity_reports (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES exam_sessions(id) ON DELETE CASCADE,
+    report TEXT,
+    flagged INTEGER DEFAULT 0,
+    generated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exam_integrity_reports_session_id ON exam_integrity_reports(session_id);
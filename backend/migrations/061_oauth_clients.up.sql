@@ -0,0 +1,36 @@
+-- Реестр клиентских приложений с разрешенными областями доступа
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id SERIAL PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255),
+    kind VARCHAR(20),
+    secret_hash VARCHAR(64),
+    scopes TEXT,
+    redirect_uris TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_clients_client_id ON oauth_clients(client_id);
+
+-- Одноразовые коды авторизации, живут несколько минут
+CREATE TABLE IF NOT EXISTS oauth_codes (
+    id SERIAL PRIMARY KEY,
+    code_hash VARCHAR(64) NOT NULL,
+    client_id INTEGER NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope TEXT,
+    redirect_uri TEXT,
+    code_challenge VARCHAR(128),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_codes_code_hash ON oauth_codes(code_hash);
+CREATE INDEX IF NOT EXISTS idx_oauth_codes_client_id ON oauth_codes(client_id);
@@ -0,0 +1,15 @@
+-- Вызовы устаревших маршрутов по клиентам и дням: кто еще не перешел на замену до даты отключения
+CREATE TABLE IF NOT EXISTS deprecated_route_calls (
+    id SERIAL PRIMARY KEY,
+    route VARCHAR(255) NOT NULL,
+    client VARCHAR(100) NOT NULL,
+    day DATE NOT NULL,
+    calls BIGINT DEFAULT 0,
+    user_agent TEXT,
+    last_called_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deprecated_route_calls_key ON deprecated_route_calls(route, client, day);
@@ -0,0 +1,20 @@
+-- Содержимое уроков как упорядоченные типизированные блоки; lessons.content остается их HTML-версией
+CREATE TABLE IF NOT EXISTS lesson_blocks (
+    id SERIAL PRIMARY KEY,
+    lesson_id INTEGER NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    data JSONB NOT NULL,
+    sequence_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lesson_blocks_lesson_id ON lesson_blocks(lesson_id);
+
+-- Существующее содержимое становится одним текстовым блоком урока
+INSERT INTO lesson_blocks (lesson_id, type, data, sequence_order)
+SELECT l.id, 'text', jsonb_build_object('html', l.content), 1
+FROM lessons l
+WHERE l.content IS NOT NULL AND l.content <> ''
+  AND NOT EXISTS (SELECT 1 FROM lesson_blocks b WHERE b.lesson_id = l.id);
@@ -0,0 +1,21 @@
+-- Почасовая статистика запросов по потребителям API и маршрутам: самые активные клиенты,
+-- доля ошибок и подозрительные шаблоны для настройки ограничений частоты запросов
+CREATE TABLE IF NOT EXISTS api_usage_buckets (
+    id SERIAL PRIMARY KEY,
+    consumer VARCHAR(100) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    hour TIMESTAMP NOT NULL,
+    requests BIGINT DEFAULT 0,
+    client_errors BIGINT DEFAULT 0,
+    server_errors BIGINT DEFAULT 0,
+    auth_failures BIGINT DEFAULT 0,
+    not_found BIGINT DEFAULT 0,
+    rate_limited BIGINT DEFAULT 0,
+    peak_per_minute BIGINT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_usage_buckets_key ON api_usage_buckets(consumer, route, hour);
+CREATE INDEX IF NOT EXISTS idx_api_usage_buckets_hour ON api_usage_buckets(hour);
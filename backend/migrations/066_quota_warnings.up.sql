@@ -0,0 +1,15 @@
+-- Предупреждения авторам о приближении к мягким лимитам: по одной записи на пройденный порог,
+-- чтобы каждый порог предупреждал один раз.
+CREATE TABLE IF NOT EXISTS quota_warnings (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    resource VARCHAR(20) NOT NULL,
+    threshold INTEGER NOT NULL,
+    used BIGINT,
+    "limit" BIGINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quota_warnings_level ON quota_warnings(user_id, resource, threshold);
@@ -0,0 +1,36 @@
+-- Кампании напоминаний: неактивным студентам — следующий урок, после проваленного теста — уроки для повторения.
+-- Отправки хранятся по событию, на которое ушло письмо, и отмечаются, если студент вернулся или сдал тест.
+CREATE TABLE IF NOT EXISTS campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    trigger VARCHAR(20) NOT NULL,
+    inactive_days INTEGER DEFAULT 0,
+    score_below DOUBLE PRECISION DEFAULT 0,
+    subject VARCHAR(255),
+    body TEXT,
+    enabled BOOLEAN DEFAULT TRUE,
+    created_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS campaign_deliveries (
+    id SERIAL PRIMARY KEY,
+    campaign_id INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reference VARCHAR(100) NOT NULL,
+    target_id INTEGER,
+    sent_at TIMESTAMP NOT NULL,
+    converted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_campaign_deliveries_key ON campaign_deliveries(campaign_id, user_id, reference);
+CREATE INDEX IF NOT EXISTS idx_campaign_deliveries_user_id ON campaign_deliveries(user_id);
+CREATE INDEX IF NOT EXISTS idx_campaign_deliveries_sent_at ON campaign_deliveries(sent_at);
+
+-- Отдельный переключатель для напоминаний
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS email_nudges BOOLEAN DEFAULT TRUE;
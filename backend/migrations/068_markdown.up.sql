@@ -0,0 +1,14 @@
+-- Markdown в уроках и комментариях: исходный текст хранится рядом с очищенным HTML, который отдается клиентам.
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS markdown TEXT DEFAULT '';
+
+ALTER TABLE course_comments ADD COLUMN IF NOT EXISTS text_html TEXT DEFAULT '';
+ALTER TABLE course_comment_replies ADD COLUMN IF NOT EXISTS text_html TEXT DEFAULT '';
+
+-- Старые комментарии показываются как простой текст: экранируется все, переносы строк сохраняются.
+-- Ранее сохраненный HTML уроков очищается командой backend/cmd/sanitize.
+UPDATE course_comments
+SET text_html = '<p>' || replace(replace(replace(replace(text, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), E'\n', '<br>' || E'\n') || '</p>'
+WHERE text IS NOT NULL AND text <> '';
+UPDATE course_comment_replies
+SET text_html = '<p>' || replace(replace(replace(replace(text, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), E'\n', '<br>' || E'\n') || '</p>'
+WHERE text IS NOT NULL AND text <> '';
@@ -0,0 +1,31 @@
+-- Учебные кружки: студенты одного курса объединяются по коду приглашения и видят прогресс друг друга,
+-- если участник сам открыл его кружку.
+CREATE TABLE IF NOT EXISTS study_circles (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    join_code VARCHAR(16) NOT NULL,
+    max_members INTEGER DEFAULT 8,
+    created_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_study_circles_join_code ON study_circles(join_code);
+CREATE INDEX IF NOT EXISTS idx_study_circles_course_id ON study_circles(course_id);
+
+CREATE TABLE IF NOT EXISTS study_circle_members (
+    id SERIAL PRIMARY KEY,
+    circle_id INTEGER NOT NULL REFERENCES study_circles(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    share_progress BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_study_circle_members_pair ON study_circle_members(circle_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_study_circle_members_user_id ON study_circle_members(user_id);
@@ -0,0 +1,32 @@
+-- Испытания учебных кружков: цель на ограниченный срок (уроки курса или модуль целиком)
+-- и значки участников, которые ее достигли.
+CREATE TABLE IF NOT EXISTS circle_challenges (
+    id SERIAL PRIMARY KEY,
+    circle_id INTEGER NOT NULL REFERENCES study_circles(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,
+    goal VARCHAR(20) NOT NULL,
+    module_id INTEGER REFERENCES course_modules(id) ON DELETE SET NULL,
+    target INTEGER,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    created_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_circle_challenges_circle_id ON circle_challenges(circle_id);
+CREATE INDEX IF NOT EXISTS idx_circle_challenges_ends_at ON circle_challenges(ends_at);
+
+CREATE TABLE IF NOT EXISTS circle_badges (
+    id SERIAL PRIMARY KEY,
+    challenge_id INTEGER NOT NULL REFERENCES circle_challenges(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    earned_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_circle_badges_pair ON circle_badges(challenge_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_circle_badges_user_id ON circle_badges(user_id);
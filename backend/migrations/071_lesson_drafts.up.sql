@@ -0,0 +1,17 @@
+-- Черновики уроков: автосохраненные правки автора, которые попадают в урок только при публикации.
+CREATE TABLE IF NOT EXISTS lesson_drafts (
+    id SERIAL PRIMARY KEY,
+    lesson_id INTEGER NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    title VARCHAR(255),
+    description TEXT,
+    content TEXT,
+    markdown TEXT,
+    revision INTEGER DEFAULT 0,
+    base_updated_at TIMESTAMP,
+    updated_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lesson_drafts_lesson_id ON lesson_drafts(lesson_id);
@@ -0,0 +1,19 @@
+-- Ответы на комментарии к тестам: модель была, таблицы не было, и обновление аватара, экспорт данных,
+-- удаление и слияние аккаунтов падали на ней.
+CREATE TABLE IF NOT EXISTS test_comment_replies (
+    id SERIAL PRIMARY KEY,
+    comment_id INTEGER REFERENCES test_comments(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    user_name VARCHAR(255),
+    user_image VARCHAR(255),
+    text TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_test_comment_replies_comment_id ON test_comment_replies(comment_id);
+CREATE INDEX IF NOT EXISTS idx_test_comment_replies_user_id ON test_comment_replies(user_id);
+
+-- Аватар автора в комментариях к тестам, как в комментариях к курсам
+ALTER TABLE test_comments ADD COLUMN IF NOT EXISTS user_image VARCHAR(255);
@@ -0,0 +1,17 @@
+-- Похожие пары курсов и уроков считаются фоновой задачей, отчет о дублях только выбирает их
+ALTER TABLE content_embeddings ADD COLUMN IF NOT EXISTS compared_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS content_similarities (
+    id SERIAL PRIMARY KEY,
+    subject_type VARCHAR(20) NOT NULL,
+    subject_a INTEGER NOT NULL,
+    subject_b INTEGER NOT NULL,
+    course_a INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    course_b INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    similarity DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_similarities_pair ON content_similarities(subject_type, subject_a, subject_b);
+CREATE INDEX IF NOT EXISTS idx_content_similarities_subject_b ON content_similarities(subject_b);
+CREATE INDEX IF NOT EXISTS idx_content_similarities_courses ON content_similarities(course_a, course_b);
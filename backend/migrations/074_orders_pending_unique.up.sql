@@ -0,0 +1,9 @@
+-- Один незавершенный заказ на пользователя и курс: параллельные оформления больше не создают дублей.
+-- Из уже накопившихся дублей остается последний, остальные отменяются.
+UPDATE orders SET status = 'canceled', updated_at = CURRENT_TIMESTAMP
+WHERE status = 'pending' AND deleted_at IS NULL AND id NOT IN (
+    SELECT MAX(id) FROM orders WHERE status = 'pending' AND deleted_at IS NULL GROUP BY user_id, course_id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_pending ON orders(user_id, course_id)
+WHERE status = 'pending' AND deleted_at IS NULL;
@@ -0,0 +1,2 @@
+-- Кампании напоминаний ищут активность в окне по времени, а не по пользователю
+CREATE INDEX IF NOT EXISTS idx_user_activities_created_at ON user_activities(created_at) WHERE deleted_at IS NULL;
@@ -0,0 +1,24 @@
+-- Один кружок на студента в каждом курсе: у участия появляется курс, и пара (пользователь, курс) уникальна.
+-- Из уже накопившихся двойных участий остается самое раннее; кружок, потерявший так владельца,
+-- переходит к участнику с наибольшим стажем, как при уходе владельца.
+ALTER TABLE study_circle_members ADD COLUMN IF NOT EXISTS course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE;
+
+UPDATE study_circle_members m SET course_id = c.course_id
+FROM study_circles c
+WHERE c.id = m.circle_id AND m.course_id IS NULL;
+
+DELETE FROM study_circle_members m
+USING study_circle_members earlier
+WHERE earlier.user_id = m.user_id AND earlier.course_id = m.course_id
+  AND (earlier.created_at, earlier.id) < (m.created_at, m.id);
+
+UPDATE study_circle_members SET role = 'owner'
+WHERE id IN (
+    SELECT DISTINCT ON (circle_id) id FROM study_circle_members s
+    WHERE NOT EXISTS (SELECT 1 FROM study_circle_members o WHERE o.circle_id = s.circle_id AND o.role = 'owner')
+    ORDER BY circle_id, created_at, id
+);
+
+ALTER TABLE study_circle_members ALTER COLUMN course_id SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_study_circle_members_course ON study_circle_members(user_id, course_id);
@@ -0,0 +1,2 @@
+-- Письма о значках за испытания кружков отключаются отдельно от новостей курсов
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS email_badges BOOLEAN DEFAULT TRUE;
@@ -0,0 +1,13 @@
+-- Фоновые выгрузки забираются из очереди планировщиком на любом экземпляре, а файлы лежат в общем
+-- закрытом хранилище. Выгрузки, начатые прежним кодом в памяти процесса, считаются прерванными,
+-- а их локальные файлы — недоступными.
+ALTER TABLE export_jobs RENAME COLUMN file_path TO file_key;
+ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS format VARCHAR(50) DEFAULT '';
+ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS started_at TIMESTAMP;
+ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+UPDATE export_jobs SET status = 'failed', error = 'The export was interrupted, start it again', finished_at = CURRENT_TIMESTAMP
+WHERE status IN ('pending', 'running');
+UPDATE export_jobs SET status = 'expired', file_key = '' WHERE status = 'done';
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_status ON export_jobs(status);
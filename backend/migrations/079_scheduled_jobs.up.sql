@@ -0,0 +1,6 @@
+-- Последний запуск каждой периодической задачи: экземпляр API запускает задачу, только если
+-- с прошлого запуска на любом экземпляре прошел ее интервал
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+    name VARCHAR(100) PRIMARY KEY,
+    last_run_at TIMESTAMP
+);
@@ -0,0 +1,6 @@
+-- Письма, разложенные из рассылки, помнят родительское сообщение и получателя: повторная попытка
+-- рассылки не ставит письма в очередь второй раз
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS parent_id INTEGER;
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS recipient_id INTEGER;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_messages_fanout ON outbox_messages(parent_id, recipient_id);
@@ -0,0 +1,9 @@
+-- Кто сейчас проходит тест: отметки присутствия живут в базе, чтобы их видели все экземпляры API
+CREATE TABLE IF NOT EXISTS presence_marks (
+    topic VARCHAR(100) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (topic, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_presence_marks_expires_at ON presence_marks (expires_at);
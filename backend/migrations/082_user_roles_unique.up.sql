@@ -0,0 +1,11 @@
+-- Одна действующая роль на пользователя и область: параллельные назначения больше не создают дублей.
+-- Из уже накопившихся дублей остается первое назначение, остальные отзываются.
+UPDATE user_roles SET university = '' WHERE university IS NULL;
+
+UPDATE user_roles SET deleted_at = CURRENT_TIMESTAMP
+WHERE deleted_at IS NULL AND id NOT IN (
+    SELECT MIN(id) FROM user_roles WHERE deleted_at IS NULL GROUP BY user_id, role_id, university
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_roles_scope ON user_roles(user_id, role_id, university)
+WHERE deleted_at IS NULL;
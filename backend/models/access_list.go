@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// Уровни доступа к курсу
+const (
+	AccessPublic     = "public"     // listed in the catalog, anyone can enroll
+	AccessPrivate    = "private"    // invitation only
+	AccessRestricted = "restricted" // only users on the course access list
+)
+
+// CourseAccessList — запись списка допуска к курсу с уровнем доступа restricted.
+// Задается ровно одно из полей: пользователь, email (в том числе еще не зарегистрированного) или учебная группа.
+type CourseAccessList struct {
+	gorm.Model
+	CourseID uint   `gorm:"index;not null"`
+	UserID   *uint  `gorm:"index"`
+	Email    string `gorm:"index"` // lowercased
+	GroupID  *uint  `gorm:"index"`
+	AddedBy  uint
+}
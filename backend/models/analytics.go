@@ -26,14 +26,30 @@ type TestAnalytics struct {
 	TimeSpent         float64 // in minutes
 }
 
+// Типы событий ленты активности
+const (
+	ActivityCourseStart    = "course_start"
+	ActivityLessonComplete = "lesson_complete"
+	ActivityCourseComplete = "course_complete"
+	ActivityTestStart      = "test_start"
+	ActivityTestComplete   = "test_complete"
+	ActivityComment        = "comment"
+	ActivityCommentReply   = "comment_reply"
+)
+
+// UserActivity — событие ленты активности пользователя
 type UserActivity struct {
 	gorm.Model
-	UserID      uint
-	ActionType  string // "course_start", "course_complete", "test_start", "test_complete"
-	TargetID    uint   // course_id or test_id
-	TargetTitle string
-	Timestamp   string
-	Duration    float64 // for completed actions
+	UserID       uint   `gorm:"index"`
+	ActionType   string // one of the Activity* constants
+	TargetType   string // "courses" or "tests"
+	TargetID     uint   // course_id or test_id
+	TargetTitle  string
+	SubjectID    uint    // lesson or comment inside the target, if any
+	SubjectTitle string  // lesson title or comment excerpt
+	Score        float64 // for completed tests
+	Timestamp    string
+	Duration     float64 // for completed actions
 }
 
 type PlatformAnalytics struct {
@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// Announcement — объявление автора или редакторов курса для записанных студентов.
+// Объявление может быть адресовано одному потоку курса, тогда его видят и получают только студенты потока.
+type Announcement struct {
+	gorm.Model
+	CourseID uint   `gorm:"index;not null"`
+	RunID    *uint  `gorm:"index"` // nil: every student of the course
+	AuthorID uint   `gorm:"not null"`
+	Title    string `gorm:"not null"`
+	Body     string `gorm:"type:text"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ApiKey struct {
+	gorm.Model
+	UserID     uint   `gorm:"index"`
+	Name       string // e.g. "Moodle integration"
+	Prefix     string // first characters of the key, shown in listings
+	KeyHash    string `gorm:"unique;not null"` // sha256 of the key, the key itself is never stored
+	LastUsedAt *time.Time
+	ExpiresAt  *time.Time
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApiUsageBucket — запросы одного потребителя API к одному маршруту за час (см. пакет usage)
+type ApiUsageBucket struct {
+	gorm.Model
+	Consumer      string    `gorm:"uniqueIndex:idx_api_usage_buckets_key;not null"` // oauth:<client_id>, api_key:<id>, user:<id> or ip:<address>
+	Route         string    `gorm:"uniqueIndex:idx_api_usage_buckets_key;not null"` // "GET /api/courses/:id" or "(unmatched)"
+	Hour          time.Time `gorm:"uniqueIndex:idx_api_usage_buckets_key;not null"` // start of the hour, UTC
+	Requests      int64
+	ClientErrors  int64 // 4xx, including the three below
+	ServerErrors  int64 // 5xx
+	AuthFailures  int64 // 401 and 403
+	NotFound      int64
+	RateLimited   int64 // 429
+	PeakPerMinute int64 // the consumer's busiest minute on one instance over all routes, see usage.Flush
+}
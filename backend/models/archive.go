@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserArchive — выгрузка данных неактивного пользователя в холодное хранилище
+type UserArchive struct {
+	gorm.Model
+	UserID     uint   `gorm:"index;not null"`
+	StorageKey string `gorm:"not null"`
+	Size       int
+	ArchivedAt time.Time
+	RestoredAt *time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// AttemptAnswer — ответ, сохраненный студентом по ходу попытки. При сдаче попытки сохраненные ответы
+// засчитываются наравне с присланными, поэтому обрыв связи или падение браузера не теряют работу.
+type AttemptAnswer struct {
+	gorm.Model
+	UserID     uint `gorm:"uniqueIndex:idx_attempt_answers_attempt;not null"`
+	TestID     uint `gorm:"index;not null"`
+	QuestionID uint `gorm:"uniqueIndex:idx_attempt_answers_attempt;not null"`
+	Attempt    int  `gorm:"uniqueIndex:idx_attempt_answers_attempt;not null"` // AttemptsUsed + 1 while the attempt runs
+	Answer     int
+}
@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// Bookmark — курс или тест, сохраненный пользователем на потом без записи на него
+type Bookmark struct {
+	gorm.Model
+	UserID     uint   `gorm:"uniqueIndex:idx_bookmarks_target;not null"`
+	TargetType string `gorm:"uniqueIndex:idx_bookmarks_target;size:20;not null"` // courses, tests
+	TargetID   uint   `gorm:"uniqueIndex:idx_bookmarks_target;not null"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Условия срабатывания кампаний напоминаний (см. jobs.RunCampaigns)
+const (
+	CampaignInactive   = "inactive"    // no activity for InactiveDays: a reminder with the next lesson
+	CampaignTestFailed = "test_failed" // a test finished below ScoreBelow: encouragement with lessons to revisit
+)
+
+var CampaignTriggers = []string{CampaignInactive, CampaignTestFailed}
+
+// Campaign — напоминание, которое планировщик отправляет студентам по событиям ленты активности.
+// Subject и Body — шаблоны с подстановками вида {name}, пустые означают текст по умолчанию.
+type Campaign struct {
+	gorm.Model
+	Name         string  `gorm:"not null"`
+	Trigger      string  `gorm:"not null"`
+	InactiveDays int     // for inactive
+	ScoreBelow   float64 // for test_failed, percent
+	Subject      string
+	Body         string
+	Enabled      bool `gorm:"default:true"`
+	CreatedByID  uint
+}
+
+// CampaignDelivery — напоминание кампании, отправленное студенту. Reference — событие, на которое оно отправлено,
+// поэтому на одно событие уходит одно письмо.
+type CampaignDelivery struct {
+	gorm.Model
+	CampaignID  uint       `gorm:"uniqueIndex:idx_campaign_deliveries_key;not null"`
+	UserID      uint       `gorm:"uniqueIndex:idx_campaign_deliveries_key;index;not null"`
+	Reference   string     `gorm:"uniqueIndex:idx_campaign_deliveries_key;not null"` // activity:<id> of the last or the failed activity
+	TargetID    uint       // course of the suggested lesson or the failed test
+	SentAt      time.Time  `gorm:"index"`
+	ConvertedAt *time.Time // the student came back (inactive) or passed the test (test_failed) after the nudge
+}
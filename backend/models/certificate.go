@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CourseSurvey — анкета, которую студент заполняет по окончании курса
+type CourseSurvey struct {
+	gorm.Model
+	CourseID  uint   `gorm:"uniqueIndex;not null"`
+	Questions string // JSON array of question texts
+	Required  bool   `gorm:"default:false"` // the certificate is issued only after the survey is answered
+}
+
+// CourseSurveyResponse — ответы студента на анкету курса
+type CourseSurveyResponse struct {
+	gorm.Model
+	CourseID uint   `gorm:"uniqueIndex:idx_course_survey_responses_pair;not null"`
+	UserID   uint   `gorm:"uniqueIndex:idx_course_survey_responses_pair;index;not null"`
+	Answers  string // JSON array of answers in question order
+}
+
+// CourseCertificate — сертификат о прохождении курса
+type CourseCertificate struct {
+	gorm.Model
+	CourseID uint   `gorm:"uniqueIndex:idx_course_certificates_pair;not null"`
+	UserID   uint   `gorm:"uniqueIndex:idx_course_certificates_pair;index;not null"`
+	Serial   string `gorm:"uniqueIndex;not null"` // public number used to verify the certificate
+	IssuedAt time.Time
+}
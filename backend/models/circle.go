@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Роли в учебном кружке
+const (
+	CircleOwner  = "owner"  // created the circle or took it over, manages members and the join code
+	CircleMember = "member" // joined by code
+)
+
+// StudyCircle — учебный кружок: несколько студентов одного курса, объединившихся сами по коду приглашения.
+// Не путать со StudyGroup — группой из справочника университета.
+type StudyCircle struct {
+	gorm.Model
+	CourseID    uint   `gorm:"index;not null"`
+	Name        string `gorm:"not null"`
+	Description string
+	JoinCode    string `gorm:"uniqueIndex;not null"` // handed out by members, the owner can replace it to stop new joins
+	MaxMembers  int    `gorm:"default:8"`
+	CreatedByID uint
+}
+
+// StudyCircleMember — участие студента в кружке. Прогресс участника виден остальным,
+// только если он сам включил ShareProgress. Курс кружка повторен в CourseID, чтобы база
+// не пускала студента во второй кружок того же курса.
+type StudyCircleMember struct {
+	gorm.Model
+	CircleID      uint   `gorm:"uniqueIndex:idx_study_circle_members_pair;not null"`
+	UserID        uint   `gorm:"uniqueIndex:idx_study_circle_members_pair;uniqueIndex:idx_study_circle_members_course,priority:1;index;not null"`
+	CourseID      uint   `gorm:"uniqueIndex:idx_study_circle_members_course,priority:2;not null"`
+	Role          string `gorm:"not null;default:member"`
+	ShareProgress bool   `gorm:"default:false"`
+}
+
+// Цели испытаний кружка
+const (
+	ChallengeLessons = "lessons" // complete Target lessons of the course, any of them
+	ChallengeModule  = "module"  // complete every lesson of ModuleID
+)
+
+// CircleChallenge — испытание кружка на ограниченный срок, например «пройти модуль 3 за неделю».
+// Прогресс участников считается по их событиям (UserActivity) между StartsAt и EndsAt.
+type CircleChallenge struct {
+	gorm.Model
+	CircleID    uint   `gorm:"index;not null"`
+	Title       string `gorm:"not null"`
+	Goal        string `gorm:"not null"`
+	ModuleID    *uint
+	Target      int       // lessons to complete for the lessons goal, a module goal uses the module's lesson count
+	StartsAt    time.Time `gorm:"not null"`
+	EndsAt      time.Time `gorm:"not null;index"`
+	CreatedByID uint
+}
+
+// CircleBadge — значок участника за выполненное испытание. Остается у студента, даже если испытание удалено.
+type CircleBadge struct {
+	gorm.Model
+	ChallengeID uint `gorm:"uniqueIndex:idx_circle_badges_pair;not null"`
+	UserID      uint `gorm:"uniqueIndex:idx_circle_badges_pair;index;not null"`
+	EarnedAt    time.Time
+}
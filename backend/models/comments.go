@@ -8,8 +8,8 @@ type CourseComment struct {
 	UserID    uint
 	UserName  string
 	UserImage string
-	Text      string
-	Rating    int `gorm:"check:rating>=0 AND rating<=5"`
+	Text      string // Markdown as written
+	TextHTML  string // Text rendered and sanitized, see the markup package
 	Replies   []CourseCommentReply
 }
 
@@ -19,7 +19,8 @@ type CourseCommentReply struct {
 	UserID    uint
 	UserName  string
 	UserImage string
-	Text      string
+	Text      string // Markdown as written
+	TextHTML  string // Text rendered and sanitized, see the markup package
 }
 
 type TestComment struct {
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CourseConduct — правила курса (например, заявление об академической честности),
+// которые студент подтверждает до первого урока или попытки теста курса
+type CourseConduct struct {
+	gorm.Model
+	CourseID  uint   `gorm:"uniqueIndex;not null"`
+	Statement string // text shown to students
+	Version   int    `gorm:"default:1"`     // bumped when the statement changes, earlier acknowledgements stop counting
+	Required  bool   `gorm:"default:false"` // lessons and course tests are blocked until the current version is acknowledged
+	UpdatedBy uint
+}
+
+// CourseConductAcknowledgement — подтверждение студентом конкретной версии правил курса.
+// Текст сохраняется вместе с подтверждением, чтобы в споре об экзамене было видно, с чем именно студент согласился.
+type CourseConductAcknowledgement struct {
+	gorm.Model
+	CourseID       uint `gorm:"uniqueIndex:idx_course_conduct_acks_triple;not null"`
+	UserID         uint `gorm:"uniqueIndex:idx_course_conduct_acks_triple;index;not null"`
+	Version        int  `gorm:"uniqueIndex:idx_course_conduct_acks_triple;not null"`
+	Statement      string
+	AcknowledgedAt time.Time `gorm:"not null"`
+}
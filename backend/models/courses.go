@@ -1,48 +1,173 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Этапы жизненного цикла курса; в каталоге видны только опубликованные курсы
+const (
+	CourseDraft     = "draft"
+	CourseReview    = "review"
+	CoursePublished = "published"
+	CourseArchived  = "archived"
+)
 
 type Course struct {
 	gorm.Model
-	Title          string
-	ShortDesc      string
-	Description    string
-	Difficulty     string // beginner, intermediate, advanced
-	RecommendedFor string // group
-	University     string
-	Topic          string
-	AuthorID       uint
-	LogoURL        string
-	CompletionRate float64
-	Lessons        []Lesson
-	Comments       []CourseComment
-	AccessSettings CourseAccessSettings
+	Title              string
+	ShortDesc          string
+	Description        string
+	Difficulty         string // beginner, intermediate, advanced
+	RecommendedFor     string // denormalized name of RecommendedGroupID
+	RecommendedGroupID *uint  `gorm:"index"`
+	University         string // denormalized name of UniversityID
+	UniversityID       *uint  `gorm:"index"`
+	Topic              string // denormalized name of TopicID, kept for text filters
+	TopicID            *uint  `gorm:"index"`
+	AuthorID           uint
+	LogoURL            string // public URL of the cover, managed through cover uploads or the stock gallery
+	CoverKey           string // storage key of an uploaded cover, empty for stock covers
+	StockCoverID       *uint
+	CompletionRate     float64
+	Status             string     `gorm:"default:draft;index"` // one of the Course* lifecycle constants
+	PublishedAt        *time.Time // last time the course was published
+	License            string     `gorm:"default:proprietary"` // one of the License* constants
+	Attribution        string     // how reusers must credit the authors, required for open licenses
+	SourceLicense      string     // license of the open content the course was copied from, empty for original work
+	SourceAttribution  string     // credit owed to that content, kept on every further copy
+	Price              int64      `gorm:"default:0"` // in minor units of Currency; 0 = free
+	Currency           string     // ISO 4217 code of Price
+	Modules            []CourseModule
+	Lessons            []Lesson
+	Comments           []CourseComment
+	AccessSettings     CourseAccessSettings
+	CompletionPolicy   CompletionPolicy `gorm:"embedded;embeddedPrefix:completion_"`
+}
+
+// CompletionPolicy — что считается прохождением курса: доля уроков и, если задан, итоговый тест
+// с проходным баллом. Прогресс студента (CompletionRate) считается по этим правилам.
+type CompletionPolicy struct {
+	LessonPercent int     `gorm:"default:100"` // share of lessons to complete, 0 = lessons aren't required
+	FinalTestID   *uint   // test to pass on top of the lessons, nil = none
+	MinScore      float64 // pass mark of the final test, percent
+}
+
+// CourseModule — раздел курса, объединяющий уроки
+type CourseModule struct {
+	gorm.Model
+	CourseID      uint `gorm:"index;not null"`
+	Title         string
+	SequenceOrder int
+}
+
+// CourseVersion — снимок содержимого курса, сохраняемый при каждой публикации
+type CourseVersion struct {
+	gorm.Model
+	CourseID    uint   `gorm:"uniqueIndex:idx_course_versions_number;not null"`
+	Number      int    `gorm:"uniqueIndex:idx_course_versions_number;not null"`
+	Changelog   string // note written by the author when publishing
+	Changes     string // JSON summary of lessons added, removed and edited since the previous version
+	Snapshot    string // JSON of the course text, modules and lessons at publish time
+	PublishedBy uint
 }
 
 type Lesson struct {
 	gorm.Model
 	CourseID      uint
+	ModuleID      *uint `gorm:"index"` // nil while the lesson isn't placed in a module
 	Title         string
 	Description   string
-	Content       string
+	Content       string // HTML of the lesson blocks, read by search, embeddings, exports and older clients
+	Markdown      string // source of Content when the lesson is written in Markdown as one text
 	SequenceOrder int
 }
 
 type CourseAccessSettings struct {
 	gorm.Model
-	CourseID    uint
-	AccessLevel string // public, private, restricted
-	StartDate   string
-	EndDate     string
-	Admins      string // comma-separated IDs
+	CourseID       uint       `gorm:"index:idx_course_access_settings_level,priority:2"`
+	AccessLevel    string     `gorm:"index:idx_course_access_settings_level,priority:1"` // public, private, restricted
+	StartDate      string     // as entered by the author, kept for older clients
+	EndDate        string     // as entered by the author, kept for older clients
+	StartsAt       *time.Time // parsed StartDate: progress is accepted from this moment
+	EndsAt         *time.Time // parsed EndDate: progress is accepted until this moment, then the course is archived
+	MaxEnrollments int        `gorm:"default:0"` // seats on the course, 0 = unlimited; then new students join the waitlist
+}
+
+// Состояние расписания курса
+const (
+	ScheduleUpcoming = "upcoming"
+	ScheduleOpen     = "open"
+	ScheduleClosed   = "closed"
+)
+
+// ScheduleStatus сообщает, идет ли курс в момент now; курс без дат открыт всегда
+func (s CourseAccessSettings) ScheduleStatus(now time.Time) string {
+	switch {
+	case s.StartsAt != nil && now.Before(*s.StartsAt):
+		return ScheduleUpcoming
+	case s.EndsAt != nil && !now.Before(*s.EndsAt):
+		return ScheduleClosed
+	}
+	return ScheduleOpen
 }
 
 type UserCourseProgress struct {
 	gorm.Model
-	UserID           uint
-	CourseID         uint
+	UserID           uint `gorm:"index:idx_user_course_progress_user_course,priority:1"`
+	CourseID         uint `gorm:"index:idx_user_course_progress_user_course,priority:2"`
 	LessonsCompleted int
 	HoursSpent       float64
 	LastAccessed     string
 	CompletionRate   float64
+	RunID            *uint `gorm:"index"` // cohort the student studies with, nil outside of runs
+}
+
+// Типы блоков содержимого урока (см. пакет blocks)
+const (
+	BlockText  = "text"
+	BlockImage = "image"
+	BlockVideo = "video"
+	BlockEmbed = "embed"
+	BlockCode  = "code"
+	BlockQuiz  = "quiz"
+)
+
+var BlockTypes = []string{BlockText, BlockImage, BlockVideo, BlockEmbed, BlockCode, BlockQuiz}
+
+// LessonBlock — блок содержимого урока; урок показывается как его блоки по sequence_order
+type LessonBlock struct {
+	gorm.Model
+	LessonID      uint   `gorm:"index;not null"`
+	Type          string `gorm:"not null"`
+	Data          string `gorm:"type:jsonb;not null"` // fields of the type, see the blocks package
+	SequenceOrder int
+}
+
+// LessonAttachment — файл к уроку (PDF, слайды, набор данных). Скачивается через API с проверкой доступа к курсу,
+// поэтому URL хранилища не отдается.
+type LessonAttachment struct {
+	gorm.Model
+	LessonID     uint   `gorm:"index;not null"`
+	Title        string `gorm:"not null"`
+	FileName     string `gorm:"not null"` // as uploaded, sent back in Content-Disposition
+	ContentType  string `gorm:"not null"`
+	Size         int64
+	Key          string `gorm:"not null"` // object key in storage.Default
+	UploadedByID uint
+}
+
+// LessonDraft — черновик урока: автосохраняемые правки автора, которые студенты не видят до публикации.
+// У урока не больше одного черновика; после публикации или отмены он удаляется.
+type LessonDraft struct {
+	gorm.Model
+	LessonID      uint `gorm:"uniqueIndex;not null"`
+	Title         string
+	Description   string
+	Content       string    // sanitized HTML, rendered from Markdown when it is set
+	Markdown      string    // source of Content, as in Lesson
+	Revision      int       // bumped on every autosave, sent back by editors to detect each other
+	BaseUpdatedAt time.Time // lesson's UpdatedAt when the draft was started, publishing checks it is still the same
+	UpdatedByID   uint
 }
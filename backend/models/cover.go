@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// StockCover — обложка из стандартной галереи, которую можно выбрать для курса без загрузки
+type StockCover struct {
+	gorm.Model
+	Title     string `gorm:"not null"`
+	URL       string `gorm:"not null"`
+	Key       string // storage key, used to delete the file
+	SortOrder int    `gorm:"default:0"`
+}
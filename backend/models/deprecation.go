@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeprecatedRouteCall — вызовы устаревшего маршрута одним клиентом за день (см. пакет deprecation)
+type DeprecatedRouteCall struct {
+	gorm.Model
+	Route        string    `gorm:"uniqueIndex:idx_deprecated_route_calls_key;not null"` // "GET /api/courses/"
+	Client       string    `gorm:"uniqueIndex:idx_deprecated_route_calls_key;not null"` // oauth:<client_id>, api_key:<id>, user:<id> or anonymous
+	Day          time.Time `gorm:"type:date;uniqueIndex:idx_deprecated_route_calls_key;not null"`
+	Calls        int64
+	UserAgent    string // the last one seen, helps to tell which app a user token belongs to
+	LastCalledAt time.Time
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Чей текст превращен в вектор
+const (
+	EmbeddingCourse = "course" // title and descriptions of a course
+	EmbeddingLesson = "lesson" // title and content of a lesson
+)
+
+// ContentEmbedding — вектор текста курса или урока для поиска дублированного контента.
+// Пересчитывается фоновой задачей, когда меняется текст или модель векторов.
+type ContentEmbedding struct {
+	gorm.Model
+	SubjectType string     `gorm:"uniqueIndex:idx_content_embeddings_subject;not null"`
+	SubjectID   uint       `gorm:"uniqueIndex:idx_content_embeddings_subject;not null"`
+	CourseID    uint       `gorm:"index;not null"`
+	EmbedModel  string     // vectors of different models can't be compared
+	Checksum    string     // sha256 of the embedded text
+	Vector      string     // JSON array of floats
+	ComparedAt  *time.Time // nil until the vector is compared with the others, reset by a new vector
+}
+
+// ContentSimilarity — пара похожих курсов (по описанию) или уроков разных курсов. Пары находит фоновая
+// задача, сравнивая каждый новый вектор с остальными, и хранит их не ниже минимального порога, чтобы отчет
+// о дублях только выбирал их. CourseA меньше CourseB; SubjectA относится к CourseA.
+type ContentSimilarity struct {
+	ID          uint    `gorm:"primarykey"`
+	SubjectType string  `gorm:"uniqueIndex:idx_content_similarities_pair,priority:1;not null"`
+	SubjectA    uint    `gorm:"uniqueIndex:idx_content_similarities_pair,priority:2;not null"`
+	SubjectB    uint    `gorm:"uniqueIndex:idx_content_similarities_pair,priority:3;index;not null"`
+	CourseA     uint    `gorm:"index:idx_content_similarities_courses,priority:1;not null"`
+	CourseB     uint    `gorm:"index:idx_content_similarities_courses,priority:2;not null"`
+	Similarity  float64 `gorm:"not null"`
+	CreatedAt   time.Time
+}
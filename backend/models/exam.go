@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Роли сотрудников экзаменационной сессии
+const (
+	ExamRoleProctor = "proctor" // проктор: продлевает время, аннулирует попытки, следит за ходом; без правки теста
+)
+
+// ExamSession — окно, в которое студенты сдают тест под наблюдением прокторов
+type ExamSession struct {
+	gorm.Model
+	TestID          uint `gorm:"index;not null"`
+	Title           string
+	StartsAt        time.Time
+	EndsAt          time.Time
+	DurationMinutes int // time per student from the first opening, 0 = until EndsAt
+	CreatedBy       uint
+}
+
+// ExamSessionProctor — проктор, назначенный на сессию
+type ExamSessionProctor struct {
+	gorm.Model
+	SessionID uint `gorm:"uniqueIndex:idx_exam_session_proctors_pair;not null"`
+	UserID    uint `gorm:"uniqueIndex:idx_exam_session_proctors_pair;index;not null"`
+	AddedBy   uint
+}
+
+// ExamAttempt — попытка студента в рамках сессии, начинается при первом открытии теста
+type ExamAttempt struct {
+	gorm.Model
+	SessionID          uint `gorm:"uniqueIndex:idx_exam_attempts_pair;not null"`
+	UserID             uint `gorm:"uniqueIndex:idx_exam_attempts_pair;index;not null"`
+	StartedAt          time.Time
+	SubmittedAt        *time.Time
+	ExtraMinutes       int `gorm:"default:0"` // granted by a proctor on top of the session limits
+	ExtendedBy         *uint
+	InvalidatedAt      *time.Time
+	InvalidatedBy      *uint
+	InvalidationReason string
+	PausedAt           *time.Time
+	PauseEndsAt        *time.Time // the pause ends by itself here once the budget runs out
+	PausedSeconds      int        `gorm:"default:0"` // finished pauses, not counted toward the time limit
+	Pauses             int        `gorm:"default:0"`
+	ResumeToken        string
+	Score              *float64 // score of this attempt, set on submission
+	Answers            string   // JSON object question_id -> answer as submitted, for the integrity report
+	StartIP            string
+	SubmitIP           string
+}
+
+// ExamIntegrityReport — отчет о честности сдачи, который строится после закрытия сессии:
+// распределение баллов против прошлых сессий, слишком быстрые попытки, общие IP и похожие ответы
+type ExamIntegrityReport struct {
+	gorm.Model
+	SessionID   uint   `gorm:"uniqueIndex;not null"`
+	Report      string `gorm:"type:text"` // JSON of integrity.Report
+	Flagged     int    // students flagged by at least one check
+	GeneratedAt time.Time
+}
+
+// Deadline — момент, после которого ответы попытки не принимаются. Время на паузе отодвигает личный
+// лимит, но не конец сессии; идущая пауза учитывается целиком, до PauseEndsAt.
+func (a ExamAttempt) Deadline(session ExamSession) time.Time {
+	deadline := session.EndsAt
+	if session.DurationMinutes > 0 {
+		personal := a.StartedAt.Add(time.Duration(session.DurationMinutes)*time.Minute + a.PauseTime())
+		if personal.Before(deadline) {
+			deadline = personal
+		}
+	}
+	return deadline.Add(time.Duration(a.ExtraMinutes) * time.Minute)
+}
+
+// PauseTime — сколько попытка провела на паузе, включая идущую паузу до ее конца
+func (a ExamAttempt) PauseTime() time.Duration {
+	paused := time.Duration(a.PausedSeconds) * time.Second
+	if a.PausedAt != nil && a.PauseEndsAt != nil {
+		paused += a.PauseEndsAt.Sub(*a.PausedAt)
+	}
+	return paused
+}
+
+// Paused сообщает, стоит ли попытка на паузе в момент now
+func (a ExamAttempt) Paused(now time.Time) bool {
+	return a.PausedAt != nil && a.PauseEndsAt != nil && now.Before(*a.PauseEndsAt)
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Export kinds and statuses
+const (
+	ExportKindCourse = "course"
+	ExportKindTest   = "test"
+	// Personal data archive of the requesting user (EntityID is the user ID)
+	ExportKindUserData = "user_data"
+	// Full archive of a university for offboarding (EntityID is the university ID)
+	ExportKindUniversity = "university"
+
+	ExportStatusPending = "pending"
+	ExportStatusRunning = "running"
+	ExportStatusDone    = "done"
+	ExportStatusFailed  = "failed"
+	// The file was deleted after its retention period
+	ExportStatusExpired = "expired"
+)
+
+type ExportJob struct {
+	gorm.Model
+	UserID     uint   `gorm:"index"`
+	Kind       string // "course", "test", "user_data", "university"
+	EntityID   uint
+	Format     string // SIS format of a course export, empty for the plain gradebook
+	Status     string `gorm:"default:pending;index"`
+	Processed  int64
+	Total      int64
+	FileKey    string // key of the finished file in storage.Archive
+	Error      string
+	StartedAt  *time.Time // when a worker claimed the job
+	FinishedAt *time.Time
+	ExpiresAt  *time.Time // when the file is deleted
+}
+
+// CourseSISMapping — сохраненное соответствие колонок выгрузки оценок курса в SIS (формат custom)
+type CourseSISMapping struct {
+	gorm.Model
+	CourseID uint   `gorm:"uniqueIndex;not null"`
+	Columns  string // JSON array of {"header": "...", "field": "..."}
+}
+
+// TableName фиксирует имя таблицы из миграций
+func (CourseSISMapping) TableName() string {
+	return "course_sis_mappings"
+}
@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// UserFollow — подписка пользователя на автора курсов и тестов
+type UserFollow struct {
+	gorm.Model
+	FollowerID uint `gorm:"uniqueIndex:idx_user_follows_pair;not null"`
+	AuthorID   uint `gorm:"uniqueIndex:idx_user_follows_pair;index;not null"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "gorm.io/gorm"
+
+// Виды составляющих итоговой оценки курса
+const (
+	GradeKindTests         = "tests"         // average score of the linked tests
+	GradeKindLessons       = "lessons"       // course completion rate
+	GradeKindParticipation = "participation" // comments and replies on the course against a target
+	GradeKindManual        = "manual"        // scores entered by course staff, e.g. assignments
+)
+
+// CourseGradeComponent — составляющая итоговой оценки курса с весом в процентах
+type CourseGradeComponent struct {
+	gorm.Model
+	CourseID      uint   `gorm:"index;not null"`
+	Name          string // shown to students, e.g. "Assignments"
+	Kind          string // one of the GradeKind* constants
+	Weight        float64
+	TestIDs       string // comma-separated IDs, for the tests kind
+	Target        int    // comments needed for a full participation score
+	SequenceOrder int
+}
+
+// CourseGradeEntry — балл студента по составляющей, выставленный вручную (0–100)
+type CourseGradeEntry struct {
+	gorm.Model
+	ComponentID uint `gorm:"uniqueIndex:idx_course_grade_entries_pair;not null"`
+	UserID      uint `gorm:"uniqueIndex:idx_course_grade_entries_pair;index;not null"`
+	Score       float64
+	GradedBy    uint
+}
+
+// CourseGrade — итоговая оценка студента за курс. Таблица пересчитывается при изменении
+// прогресса, состава оценки или шкалы (см. пакет grading).
+type CourseGrade struct {
+	gorm.Model
+	CourseID uint `gorm:"uniqueIndex:idx_course_grades_pair;not null"`
+	UserID   uint `gorm:"uniqueIndex:idx_course_grades_pair;index;not null"`
+	Percent  float64
+	Letter   string
+}
+
+// GradeScale — перевод процентов в буквенную оценку, настраивается для университета
+type GradeScale struct {
+	gorm.Model
+	UniversityID uint   `gorm:"uniqueIndex;not null"`
+	Bands        string // JSON array of {"letter": "A", "min": 90}, highest first
+}
@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// StudyGroup — учебная группа из справочника (например, "ФИЛ-101"), обычно принадлежит университету
+type StudyGroup struct {
+	gorm.Model
+	Name         string `gorm:"not null"`
+	Slug         string `gorm:"uniqueIndex;not null"`
+	UniversityID *uint  `gorm:"index"`
+	University   string // denormalized name of UniversityID
+}
+
+// StudyGroupMember — участие пользователя в группе. Основная группа дублируется в User.StudyGroupID.
+type StudyGroupMember struct {
+	gorm.Model
+	GroupID uint `gorm:"uniqueIndex:idx_study_group_members_pair;not null"`
+	UserID  uint `gorm:"uniqueIndex:idx_study_group_members_pair;index;not null"`
+	AddedBy uint
+}
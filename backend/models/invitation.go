@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Invitation struct {
+	gorm.Model
+	Code       string `gorm:"unique;not null"`
+	Email      string `gorm:"index;not null"`
+	Group      string
+	University string
+	CourseID   *uint // optional course the invitee is enrolled into on registration
+	InvitedBy  uint
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	AcceptedBy *uint
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Виды данных, на которые ставится удержание
+const (
+	HoldSubjectUser   = "user"
+	HoldSubjectCourse = "course"
+	HoldSubjectTest   = "test"
+)
+
+// LegalHold — юридическое удержание: пока оно действует, данные нельзя удалить, анонимизировать
+// или вычистить по сроку хранения. Записи не удаляются, снятие фиксируется в той же строке,
+// поэтому таблица служит журналом того, кто и почему ставил и снимал удержание.
+type LegalHold struct {
+	gorm.Model
+	SubjectType   string `gorm:"index:idx_legal_holds_subject,priority:1;not null"`
+	SubjectID     uint   `gorm:"index:idx_legal_holds_subject,priority:2;not null"`
+	Reason        string `gorm:"not null"`
+	AppliedBy     uint
+	ReleasedAt    *time.Time // nil while the hold is active
+	ReleasedBy    *uint
+	ReleaseReason string
+}
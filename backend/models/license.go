@@ -0,0 +1,28 @@
+package models
+
+// Лицензии курсов и тестов
+const (
+	LicenseProprietary = "proprietary" // all rights reserved, the default
+	LicenseCCBY        = "cc-by"
+	LicenseCCBYSA      = "cc-by-sa"
+)
+
+// Licenses — все лицензии, которые можно назначить контенту
+var Licenses = []string{LicenseProprietary, LicenseCCBY, LicenseCCBYSA}
+
+// OpenLicense сообщает, разрешает ли лицензия копировать и менять контент при указании авторства
+func OpenLicense(license string) bool {
+	return license == LicenseCCBY || license == LicenseCCBYSA
+}
+
+// Upstream возвращает лицензию и указание авторства, которые обязана сохранить копия курса:
+// обязательства перед оригиналом, если курс сам скопирован из открытого контента, иначе его собственную открытую лицензию
+func (c *Course) Upstream() (license, attribution string) {
+	if c.SourceLicense != "" {
+		return c.SourceLicense, c.SourceAttribution
+	}
+	if OpenLicense(c.License) {
+		return c.License, c.Attribution
+	}
+	return "", ""
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Статусы заявки курса в каталог для обмена между организациями
+const (
+	ListingPending   = "pending"
+	ListingApproved  = "approved"
+	ListingRejected  = "rejected"
+	ListingWithdrawn = "withdrawn"
+)
+
+// MarketplaceListing — курс, предложенный организациям платформы. Содержимое замораживается
+// в бандле при подаче заявки, поэтому дальнейшие правки исходного курса в каталог не попадают.
+type MarketplaceListing struct {
+	gorm.Model
+	CourseID    uint   `gorm:"index;not null"`
+	SubmittedBy uint   `gorm:"not null"`
+	University  string // organization offering the course
+	Title       string
+	Summary     string
+	License     string // an open license, see OpenLicense
+	Attribution string // how reusers must credit the authors
+	Bundle      string // JSON course bundle, see package bundle
+	Status      string `gorm:"default:pending;index"`
+	ReviewedBy  *uint
+	ReviewedAt  *time.Time
+	ReviewNote  string
+	Imports     int `gorm:"default:0"`
+}
+
+// MarketplaceImport — курс, созданный из заявки каталога
+type MarketplaceImport struct {
+	gorm.Model
+	ListingID  uint `gorm:"index;not null"`
+	CourseID   uint `gorm:"uniqueIndex;not null"`
+	ImportedBy uint
+}
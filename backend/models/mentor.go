@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Статусы связи наставника со студентом
+const (
+	MentorLinkPending  = "pending"  // наставник отправил запрос, студент еще не ответил
+	MentorLinkAccepted = "accepted" // студент дал согласие на просмотр своего прогресса
+)
+
+// MentorLink — связь наставника (преподавателя, куратора) со студентом.
+// Прогресс студента доступен наставнику только на чтение и только после согласия студента.
+type MentorLink struct {
+	gorm.Model
+	MentorID   uint   `gorm:"uniqueIndex:idx_mentor_links_pair;not null"`
+	StudentID  uint   `gorm:"uniqueIndex:idx_mentor_links_pair;index;not null"`
+	Status     string `gorm:"not null;default:pending"`
+	Note       string // message from the mentor shown with the request
+	AcceptedAt *time.Time
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MetricBucket — значение счетчика платформы за минуту (см. пакет metrics)
+type MetricBucket struct {
+	gorm.Model
+	Name   string    `gorm:"uniqueIndex:idx_metric_buckets_name_bucket;not null"`
+	Bucket time.Time `gorm:"uniqueIndex:idx_metric_buckets_name_bucket;not null"` // start of the minute, UTC
+	Value  int64
+}
+
+// Метрики, по которым ищутся аномалии
+const (
+	AlertLoginDrop        = "login_drop"
+	AlertFailedLoginSpike = "failed_login_spike"
+	AlertErrorRateSurge   = "error_rate_surge"
+)
+
+// PlatformAlert — аномалия метрики за окно, о которой оповещены администраторы
+type PlatformAlert struct {
+	gorm.Model
+	Metric      string    `gorm:"uniqueIndex:idx_platform_alerts_window;not null"` // one of the Alert* constants
+	WindowStart time.Time `gorm:"uniqueIndex:idx_platform_alerts_window;not null"`
+	WindowEnd   time.Time
+	Value       float64 // value of the metric in the window
+	Baseline    float64 // what the previous windows suggested
+	Message     string
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Типы клиентских приложений. Мобильное приложение не может хранить секрет, поэтому вместо него
+// обязательна проверка PKCE; веб- и сторонние приложения предъявляют секрет при обмене кода на токен.
+const (
+	ClientWeb        = "web"
+	ClientMobile     = "mobile"
+	ClientThirdParty = "third_party"
+)
+
+var ClientKinds = []string{ClientWeb, ClientMobile, ClientThirdParty}
+
+// Области доступа токенов клиентов: ресурс и :read (GET) или :write (остальные методы)
+const (
+	ScopeProfileRead    = "profile:read"
+	ScopeProfileWrite   = "profile:write"
+	ScopeCoursesRead    = "courses:read"
+	ScopeCoursesWrite   = "courses:write"
+	ScopeTestsRead      = "tests:read"
+	ScopeTestsWrite     = "tests:write"
+	ScopeProgressRead   = "progress:read"
+	ScopeAnalyticsRead  = "analytics:read"
+	ScopeAuthoringRead  = "authoring:read"
+	ScopeAuthoringWrite = "authoring:write"
+)
+
+var Scopes = []string{
+	ScopeProfileRead, ScopeProfileWrite, ScopeCoursesRead, ScopeCoursesWrite, ScopeTestsRead, ScopeTestsWrite,
+	ScopeProgressRead, ScopeAnalyticsRead, ScopeAuthoringRead, ScopeAuthoringWrite,
+}
+
+// OAuthClient — зарегистрированное приложение, которому пользователи выдают доступ с ограниченными областями
+type OAuthClient struct {
+	gorm.Model
+	ClientID     string `gorm:"uniqueIndex;not null"` // public identifier, e.g. "cl_1f2e..."
+	Name         string
+	Kind         string // one of the Client* constants
+	SecretHash   string // sha256 of the secret, empty for mobile clients
+	Scopes       string // space-separated scopes the client may ask for
+	RedirectURIs string // space-separated exact redirect URIs
+	CreatedBy    uint
+	RevokedAt    *time.Time // revoked clients can't get tokens and their issued tokens stop working
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// OAuthCode — одноразовый код авторизации, который клиент обменивает на токен
+type OAuthCode struct {
+	gorm.Model
+	CodeHash      string `gorm:"uniqueIndex;not null"`
+	ClientID      uint   `gorm:"index"`
+	UserID        uint
+	Scope         string
+	RedirectURI   string
+	CodeChallenge string // PKCE S256 challenge, required for mobile clients
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+func (OAuthCode) TableName() string {
+	return "oauth_codes"
+}
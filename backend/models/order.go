@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Статусы заказа
+const (
+	OrderPending  = "pending"
+	OrderPaid     = "paid"
+	OrderFailed   = "failed"
+	OrderCanceled = "canceled"
+)
+
+// Order — покупка платного курса. Студент зачисляется на курс, только когда провайдер
+// подтвердит оплату. Заказы хранятся и после удаления аккаунта — это бухгалтерские записи.
+// Незавершенный заказ на пользователя и курс может быть только один.
+type Order struct {
+	gorm.Model
+	UserID            uint  `gorm:"index;uniqueIndex:idx_orders_pending,where:status = 'pending' AND deleted_at IS NULL;not null"`
+	CourseID          uint  `gorm:"index;uniqueIndex:idx_orders_pending,where:status = 'pending' AND deleted_at IS NULL;not null"`
+	Amount            int64 // price at the moment of the order, in minor units
+	Currency          string
+	Status            string `gorm:"default:pending;index"` // pending, paid, failed, canceled
+	Provider          string // stripe, yookassa
+	ProviderSessionID string `gorm:"index"` // checkout session (Stripe) or payment (YooKassa)
+	ProviderPaymentID string
+	CheckoutURL       string
+	PaidAt            *time.Time
+}
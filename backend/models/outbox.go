@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxMessage struct {
+	gorm.Model
+	Kind          string    `gorm:"index"` // "email", "webhook", "xapi", "fanout"
+	Payload       string    // JSON payload
+	Status        string    `gorm:"default:pending;index"` // "pending", "sent", "failed"
+	Attempts      int       `gorm:"default:0"`
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string
+	SentAt        *time.Time
+	// Emails expanded from a fan-out: the parent message and the user they go to, unique together
+	ParentID    *uint `gorm:"uniqueIndex:idx_outbox_messages_fanout"`
+	RecipientID *uint `gorm:"uniqueIndex:idx_outbox_messages_fanout"`
+}
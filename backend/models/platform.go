@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ключи настроек платформы
+const (
+	SettingReadOnly = "read_only"
+	SettingSandbox  = "sandbox"
+)
+
+// PlatformSetting — настройка всей платформы, общая для всех экземпляров API (значение в JSON)
+type PlatformSetting struct {
+	gorm.Model
+	Key       string `gorm:"uniqueIndex;not null"`
+	Value     string // JSON
+	UpdatedBy uint
+}
+
+// ScheduledJob — последний запуск периодической задачи, общий для всех экземпляров API
+type ScheduledJob struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	LastRunAt *time.Time
+}
+
+// PresenceMark — отметка, что пользователь сейчас работает с сущностью топика (например, test:42)
+type PresenceMark struct {
+	Topic     string    `gorm:"primaryKey;size:100"`
+	UserID    uint      `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type MonthlyProgress struct {
 	Month            time.Month
@@ -10,9 +14,43 @@ type MonthlyProgress struct {
 	LoginFrequency   map[string]int // day -> count
 }
 
+// TrendDelta — значение показателя за текущий и предыдущий период и разница между ними
+type TrendDelta struct {
+	Current  float64
+	Previous float64
+	Delta    float64
+}
+
+// ProgressTrends сравнивает текущий месяц с предыдущим по срезам UserProgressSnapshot
+type ProgressTrends struct {
+	Period         string // "2006-01", UTC
+	PreviousPeriod string
+	Hours          TrendDelta
+	Lessons        TrendDelta
+	AvgScore       TrendDelta
+	BestStreak     TrendDelta
+}
+
 type ProgressOverview struct {
 	TotalStreakDays       int
 	TotalCoursesCompleted int
 	TotalTestsCompleted   int
 	MonthlyProgress       []MonthlyProgress
+	Trends                ProgressTrends
+}
+
+// SnapshotPeriodLayout — формат периода среза: календарный месяц по UTC
+const SnapshotPeriodLayout = "2006-01"
+
+// UserProgressSnapshot — месячный срез прогресса пользователя (заполняется задачей progress-snapshots).
+// Срез текущего месяца пересчитывается, пока месяц не закончится.
+type UserProgressSnapshot struct {
+	gorm.Model
+	UserID     uint    `gorm:"uniqueIndex:idx_user_progress_snapshots_period"`
+	Period     string  `gorm:"uniqueIndex:idx_user_progress_snapshots_period"` // "2006-01", UTC
+	TotalHours float64 // hours spent on all courses as of the snapshot
+	Hours      float64 // hours spent during the period
+	Lessons    int     // lessons completed during the period
+	AvgScore   float64 // average score of tests completed during the period
+	BestStreak int     // longest streak seen during the period
 }
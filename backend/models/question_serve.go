@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuestionServe — выдача вопроса студенту в попытке теста: когда вопрос показан и когда на него ответили.
+// По этим отметкам сервер проверяет ограничение времени на вопрос, не доверяя таймеру клиента.
+type QuestionServe struct {
+	gorm.Model
+	UserID     uint `gorm:"uniqueIndex:idx_question_serves_attempt;not null"`
+	TestID     uint `gorm:"index;not null"`
+	QuestionID uint `gorm:"uniqueIndex:idx_question_serves_attempt;not null"`
+	Attempt    int  `gorm:"uniqueIndex:idx_question_serves_attempt;not null"` // AttemptsUsed + 1 while the attempt runs
+	ServedAt   time.Time
+	AnsweredAt *time.Time
+	Answer     *int // nil when the answer came after the time limit
+}
+
+// Deadline — момент, до которого принимается ответ на вопрос с ограничением limitSeconds
+func (s QuestionServe) Deadline(limitSeconds int) time.Time {
+	return s.ServedAt.Add(time.Duration(limitSeconds) * time.Second)
+}
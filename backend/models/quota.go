@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// QuotaWarning — предупреждение автору о приближении к мягкому лимиту, отправленное на пороге Threshold.
+// Хранится, чтобы каждый порог предупреждал один раз; удаляется, когда использование опускается ниже порога.
+type QuotaWarning struct {
+	gorm.Model
+	UserID    uint   `gorm:"uniqueIndex:idx_quota_warnings_level;not null"`
+	Resource  string `gorm:"uniqueIndex:idx_quota_warnings_level;not null"` // one of the quota.Resource* constants
+	Threshold int    `gorm:"uniqueIndex:idx_quota_warnings_level;not null"` // percent of the limit
+	Used      int64  // usage when the warning was sent
+	Limit     int64
+}
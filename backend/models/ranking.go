@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// TestRanking — место пользователя в рейтинге теста по последнему результату. Новая попытка
+// сдвигает только затронутые строки, полный пересчет нужен при слиянии аккаунтов (см. пакет rankings).
+// PreviousRank — место до последнего изменения, из него считается сдвиг.
+type TestRanking struct {
+	gorm.Model
+	TestID       uint `gorm:"uniqueIndex:idx_test_rankings_pair;index:idx_test_rankings_rank,priority:1;not null"`
+	UserID       uint `gorm:"uniqueIndex:idx_test_rankings_pair;not null"`
+	Score        float64
+	Rank         int `gorm:"index:idx_test_rankings_rank,priority:2"`
+	PreviousRank *int
+}
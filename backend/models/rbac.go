@@ -0,0 +1,48 @@
+package models
+
+import "gorm.io/gorm"
+
+// Коды разрешений
+const (
+	PermCoursesCreate      = "courses.create"
+	PermCoursesEdit        = "courses.edit"
+	PermTestsCreate        = "tests.create"
+	PermTestsEdit          = "tests.edit"
+	PermCommentsModerate   = "comments.moderate"
+	PermAnalyticsView      = "analytics.view"
+	PermPlatformView       = "platform.view"
+	PermUsersManage        = "users.manage"
+	PermUsersInvite        = "users.invite"
+	PermRolesManage        = "roles.manage"
+	PermTopicsManage       = "topics.manage"
+	PermUniversitiesManage = "universities.manage"
+	PermCoversManage       = "covers.manage"
+	PermStudentsMentor     = "students.mentor"
+	PermStatusManage       = "status.manage"
+	PermPlatformManage     = "platform.manage"
+	PermUniversityExport   = "university.export"
+	PermLegalHoldManage    = "legal_hold.manage"
+	PermMarketplaceReview  = "marketplace.review"
+)
+
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"unique;not null"`
+	Description string
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+}
+
+type Permission struct {
+	gorm.Model
+	Code        string `gorm:"unique;not null"`
+	Description string
+}
+
+type UserRole struct {
+	gorm.Model
+	UserID     uint `gorm:"uniqueIndex:idx_user_roles_scope,where:deleted_at IS NULL"`
+	RoleID     uint `gorm:"uniqueIndex:idx_user_roles_scope,where:deleted_at IS NULL"`
+	Role       Role
+	University string `gorm:"uniqueIndex:idx_user_roles_scope,where:deleted_at IS NULL"` // empty = global, otherwise role is scoped to this university
+	AssignedBy uint
+}
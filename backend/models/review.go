@@ -0,0 +1,18 @@
+package models
+
+import "gorm.io/gorm"
+
+// Review — оценка курса пользователем с отзывом. У пользователя один отзыв на курс, его можно
+// изменить; обсуждение курса идет отдельно, в комментариях. Как и комментарии, отзыв остается после
+// удаления аккаунта автора.
+type Review struct {
+	gorm.Model
+	CourseID uint   `gorm:"uniqueIndex:idx_course_reviews_pair;not null"`
+	UserID   uint   `gorm:"uniqueIndex:idx_course_reviews_pair;index"`
+	Rating   int    `gorm:"check:rating>=1 AND rating<=5;not null"`
+	Text     string `gorm:"type:text"`
+}
+
+func (Review) TableName() string {
+	return "course_reviews"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CourseRun — поток (когорта) курса: то же содержание для отдельной учебной группы
+// со своими датами, составом и аналитикой. Даты потока заменяют расписание курса для его студентов.
+type CourseRun struct {
+	gorm.Model
+	CourseID  uint   `gorm:"index;not null"`
+	Title     string `gorm:"not null"` // e.g. "Spring 2027, group PH-21"
+	GroupID   *uint  `gorm:"index"`    // enrolled study group, nil while the roster isn't assigned
+	StartsAt  *time.Time
+	EndsAt    *time.Time
+	CreatedBy uint
+}
+
+// ScheduleStatus сообщает, идет ли поток в момент now; правила те же, что у расписания курса
+func (r CourseRun) ScheduleStatus(now time.Time) string {
+	return CourseAccessSettings{StartsAt: r.StartsAt, EndsAt: r.EndsAt}.ScheduleStatus(now)
+}
@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// UserSettings — персональные настройки пользователя (одна запись на пользователя)
+type UserSettings struct {
+	gorm.Model
+	UserID             uint   `gorm:"uniqueIndex;not null"`
+	Locale             string `gorm:"default:en"`
+	Timezone           string `gorm:"default:UTC"`    // IANA name, used for date bucketing in analytics
+	Theme              string `gorm:"default:system"` // light, dark, system
+	EmailNotifications bool   `gorm:"default:true"`   // master switch for non-essential emails
+	EmailComments      bool   `gorm:"default:true"`   // comments on the user's courses
+	EmailCourseUpdates bool   `gorm:"default:true"`   // changes in enrolled courses
+	EmailNudges        bool   `gorm:"default:true"`   // reminders to come back and encouragement after a failed test
+	EmailBadges        bool   `gorm:"default:true"`   // badges earned in study circle challenges
+	HideEmail          bool   `gorm:"default:false"`  // email is not shown to other users and authors
+	HideUniversity     bool   `gorm:"default:false"`  // university and study group are not shown to others
+	PrivateProfile     bool   `gorm:"default:false"`  // others only see the username and avatar
+}
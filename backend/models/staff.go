@@ -0,0 +1,27 @@
+package models
+
+import "gorm.io/gorm"
+
+// Роли соавторов и сотрудников курса
+const (
+	CourseRoleEditor = "editor" // соавтор: правит содержимое и управляет курсом наравне с автором
+	CourseRoleTA     = "ta"     // ассистент: проверка, ответы на комментарии, аналитика; без правки содержимого
+	CourseRoleViewer = "viewer" // наблюдатель: видит содержимое и аналитику, ничего не меняет
+)
+
+// CourseRoles — роли, которые можно выдать в курсе
+var CourseRoles = []string{CourseRoleEditor, CourseRoleTA, CourseRoleViewer}
+
+// CourseStaff — соавтор или сотрудник курса: пользователь с ролью в конкретном курсе
+type CourseStaff struct {
+	gorm.Model
+	CourseID uint   `gorm:"uniqueIndex:idx_course_staff_pair;not null"`
+	UserID   uint   `gorm:"uniqueIndex:idx_course_staff_pair;index;not null"`
+	Role     string `gorm:"not null;default:ta"`
+	AddedBy  uint
+}
+
+// TableName фиксирует имя таблицы из миграций
+func (CourseStaff) TableName() string {
+	return "course_staff"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Компоненты платформы на странице статуса
+const (
+	ComponentAPI        = "api"
+	ComponentDatabase   = "database"
+	ComponentStorage    = "storage"
+	ComponentEmailQueue = "email_queue"
+)
+
+// Важность инцидента
+const (
+	IncidentMinor       = "minor"
+	IncidentMajor       = "major"
+	IncidentMaintenance = "maintenance"
+)
+
+// StatusIncident — заметка администратора об инциденте или плановых работах для баннера статуса
+type StatusIncident struct {
+	gorm.Model
+	Title      string `gorm:"not null"`
+	Message    string
+	Severity   string     `gorm:"not null;default:'minor'"` // one of the Incident* constants
+	Components string     // comma-separated Component* codes the incident affects
+	StartedAt  time.Time  `gorm:"not null"`
+	ResolvedAt *time.Time `gorm:"index"`
+	CreatedBy  uint
+}
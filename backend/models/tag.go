@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// Tag — метка каталога поперек дерева тем («для начинающих», «подготовка к экзамену»).
+// Тема задает категорию курса или теста, меток у них может быть сколько угодно.
+type Tag struct {
+	gorm.Model
+	Name string `gorm:"not null"`
+	Slug string `gorm:"uniqueIndex;not null"`
+}
+
+// CourseTag — метка курса
+type CourseTag struct {
+	gorm.Model
+	CourseID uint `gorm:"uniqueIndex:idx_course_tags_pair;not null"`
+	TagID    uint `gorm:"uniqueIndex:idx_course_tags_pair;index;not null"`
+}
+
+// TestTag — метка теста
+type TestTag struct {
+	gorm.Model
+	TestID uint `gorm:"uniqueIndex:idx_test_tags_pair;not null"`
+	TagID  uint `gorm:"uniqueIndex:idx_test_tags_pair;index;not null"`
+}
@@ -4,49 +4,57 @@ import "gorm.io/gorm"
 
 type Test struct {
 	gorm.Model
-	Title          string
-	ShortDesc      string
-	Description    string
-	Difficulty     string // beginner, intermediate, advanced
-	RecommendedFor string // group
-	University     string
-	Topic          string
-	AuthorID       uint
-	LogoURL        string
-	CompletionRate float64
-	Questions      []TestQuestion
-	Comments       []TestComment
-	AccessSettings TestAccessSettings
+	Title              string
+	ShortDesc          string
+	Description        string
+	Difficulty         string // beginner, intermediate, advanced
+	RecommendedFor     string // denormalized name of RecommendedGroupID
+	RecommendedGroupID *uint  `gorm:"index"`
+	University         string // denormalized name of UniversityID
+	UniversityID       *uint  `gorm:"index"`
+	Topic              string // denormalized name of TopicID, kept for text filters
+	TopicID            *uint  `gorm:"index"`
+	AuthorID           uint
+	LogoURL            string
+	CompletionRate     float64
+	License            string `gorm:"default:proprietary"` // one of the License* constants
+	Attribution        string // how reusers must credit the authors, required for open licenses
+	Questions          []TestQuestion
+	Comments           []TestComment
+	AccessSettings     TestAccessSettings
 }
 
 type TestQuestion struct {
 	gorm.Model
-	TestID        uint
-	Title         string
-	Description   string
-	Question      string
-	Options       string // JSON array of options
-	CorrectAnswer int
-	SequenceOrder int
+	TestID           uint
+	Title            string
+	Description      string
+	Question         string
+	Options          string // JSON array of options
+	CorrectAnswer    int
+	SequenceOrder    int
+	TimeLimitSeconds int `gorm:"default:0"` // time to answer after the question is served, 0 = no limit
 }
 
 type TestAccessSettings struct {
 	gorm.Model
-	TestID          uint
-	AccessLevel     string // public, private, restricted
+	TestID          uint   `gorm:"index:idx_test_access_settings_level,priority:2"`
+	AccessLevel     string `gorm:"index:idx_test_access_settings_level,priority:1"` // public, private, restricted
 	StartDate       string
 	EndDate         string
 	Admins          string // comma-separated IDs
 	AttemptsAllowed int    `gorm:"default:1"`
+	MaxPauseMinutes int    `gorm:"default:0"` // pause budget per exam attempt, 0 = attempts can't be paused
 }
 
 type UserTestProgress struct {
 	gorm.Model
-	UserID            uint
-	TestID            uint
+	UserID            uint `gorm:"index:idx_user_test_progress_user_test,priority:1"`
+	TestID            uint `gorm:"index:idx_user_test_progress_user_test,priority:2"`
 	QuestionsAnswered int
 	CorrectAnswers    int
 	Score             float64
 	AttemptsUsed      int
 	LastAttempt       string
+	Locale            string // localized variant of the last attempt, empty for the original text
 }
@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// Topic — узел иерархического каталога тем (Философия → Этика → Метаэтика)
+type Topic struct {
+	gorm.Model
+	Name      string `gorm:"not null"`
+	Slug      string `gorm:"uniqueIndex;not null"`
+	ParentID  *uint  `gorm:"index"`
+	Path      string `gorm:"index"` // materialized path of IDs from the root including self, e.g. "/1/4/9/"
+	SortOrder int    `gorm:"default:0"`
+}
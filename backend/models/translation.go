@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Статусы перевода вопроса
+const (
+	TranslationNeedsReview = "needs_review"
+	TranslationApproved    = "approved"
+)
+
+// Откуда взялся текст перевода
+const (
+	TranslationMachine = "machine"
+	TranslationAuthor  = "author"
+)
+
+// TestQuestionTranslation — перевод вопроса теста на другой язык. Студентам он показывается
+// только после одобрения автором и пока исходный вопрос не изменился.
+type TestQuestionTranslation struct {
+	gorm.Model
+	TestID         uint   `gorm:"index;not null"`
+	QuestionID     uint   `gorm:"uniqueIndex:idx_question_translations_locale;not null"`
+	Locale         string `gorm:"uniqueIndex:idx_question_translations_locale;size:10;not null"`
+	Title          string
+	Description    string
+	Question       string
+	Options        string // JSON array in the order of the original, so CorrectAnswer still applies
+	Status         string `gorm:"default:needs_review"` // needs_review, approved
+	Source         string // machine, author
+	SourceChecksum string // checksum of the original question the text was written for
+	ReviewedBy     *uint  // author who approved the translation
+	ReviewedAt     *time.Time
+}
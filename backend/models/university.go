@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// University — запись справочника университетов
+type University struct {
+	gorm.Model
+	Name         string `gorm:"uniqueIndex;not null"`
+	Slug         string `gorm:"uniqueIndex;not null"`
+	ShortName    string
+	Country      string
+	Website      string
+	LogoURL      string
+	EmailDomains string // comma-separated domains that prove affiliation, e.g. "msu.ru,student.msu.ru"
+}
+
+// AffiliationVerification — запрос на подтверждение принадлежности к университету через институциональный email
+type AffiliationVerification struct {
+	gorm.Model
+	UserID       uint `gorm:"index"`
+	UniversityID uint
+	Email        string
+	TokenHash    string `gorm:"uniqueIndex"`
+	ExpiresAt    time.Time
+	VerifiedAt   *time.Time
+}
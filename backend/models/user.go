@@ -8,12 +8,35 @@ import (
 
 type User struct {
 	gorm.Model
-	Username     string `gorm:"unique;not null"`
-	Email        string `gorm:"unique;not null"`
-	PasswordHash string `gorm:"not null"`
-	Role         string `gorm:"default:user"` // user, admin
-	Group        string
-	University   string
+	Username             string     `gorm:"unique;not null"`
+	Email                string     `gorm:"unique;not null"`
+	PasswordHash         string     `gorm:"not null"`
+	Role                 string     `gorm:"default:user"` // user, admin
+	Group                string     // denormalized name of StudyGroupID
+	StudyGroupID         *uint      `gorm:"index"`
+	University           string     // denormalized name of UniversityID
+	UniversityID         *uint      `gorm:"index"`
+	UniversityVerifiedAt *time.Time // set once an institutional email of the university is confirmed
+	UniversityEmail      string
+	TokenVersion         int        `gorm:"default:0"`    // bump to invalidate all issued JWTs
+	Active               bool       `gorm:"default:true"` // false = deactivated by admin
+	BannedUntil          *time.Time // soft-ban, access is denied until this moment
+	BanReason            string
+	AvatarURL            string
+	AvatarKey            string     // storage key of the current avatar, used to delete it on replace
+	ArchivedAt           *time.Time // activity data moved to cold storage, restored on next login
+	PurgeAfter           *time.Time // set on self-deletion, the row is hard-deleted after this moment
+}
+
+// EmailChange — запрос на смену email. Адрес меняется только после подтверждения кодом,
+// отправленным на новый адрес.
+type EmailChange struct {
+	gorm.Model
+	UserID      uint `gorm:"index"`
+	NewEmail    string
+	TokenHash   string `gorm:"uniqueIndex"`
+	ExpiresAt   time.Time
+	ConfirmedAt *time.Time
 }
 
 type UserProgress struct {
@@ -25,8 +48,23 @@ type UserProgress struct {
 	TestsCompleted   int `gorm:"default:0"`
 }
 
+// LoginHistory в Postgres секционирована по месяцам (login_time), поэтому запросы
+// по возможности должны ограничивать login_time, чтобы затрагивать только нужные секции.
+// Неудачные попытки (неверный пароль) тоже записываются, с Success = false.
 type LoginHistory struct {
 	gorm.Model
-	UserID    uint
-	LoginTime time.Time
+	UserID     uint
+	LoginTime  time.Time
+	IP         string
+	UserAgent  string
+	Device     string // browser and OS parsed from the user agent, e.g. "Chrome on Windows"
+	Country    string // ISO code from the geo header of the proxy, empty if unknown
+	Success    bool   `gorm:"not null"`
+	NewDevice  bool   // first successful login from this device
+	NewCountry bool   // first successful login from this country
+}
+
+// TableName совпадает с таблицей из миграций, которую обслуживает jobs.MaintainPartitions
+func (LoginHistory) TableName() string {
+	return "login_history"
 }
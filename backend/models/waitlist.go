@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CourseWaitlist — пользователь в очереди на курс, все места которого заняты. Очередь идет по CreatedAt;
+// запись удаляется, когда пользователь записан на курс.
+type CourseWaitlist struct {
+	gorm.Model
+	CourseID  uint       `gorm:"uniqueIndex:idx_course_waitlists_pair;not null"`
+	UserID    uint       `gorm:"uniqueIndex:idx_course_waitlists_pair;index;not null"`
+	OfferedAt *time.Time // a seat of a paid course is held for the user since then, until they buy it
+}
@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"project/backend/config"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Deliverer доставляет сообщение outbox во внешнюю систему
+type Deliverer interface {
+	Deliver(ctx context.Context, msg models.OutboxMessage) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// EmailDeliverer отправляет письма через SMTP.
+// Если SMTP не настроен, письма только логируются (удобно для разработки).
+type EmailDeliverer struct {
+	Cfg    *config.Config
+	Logger *log.Logger
+}
+
+func (d *EmailDeliverer) Deliver(ctx context.Context, msg models.OutboxMessage) error {
+	var email EmailMessage
+	if err := json.Unmarshal([]byte(msg.Payload), &email); err != nil {
+		return err
+	}
+
+	if d.Cfg.SMTPHost == "" {
+		d.Logger.Printf("[outbox] email to %s: %s", email.To, email.Subject)
+		return nil
+	}
+
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		d.Cfg.SMTPFrom, email.To, email.Subject, email.Body)
+
+	var auth smtp.Auth
+	if d.Cfg.SMTPUser != "" {
+		auth = smtp.PlainAuth("", d.Cfg.SMTPUser, d.Cfg.SMTPPassword, d.Cfg.SMTPHost)
+	}
+
+	return smtp.SendMail(d.Cfg.SMTPHost+":"+d.Cfg.SMTPPort, auth, d.Cfg.SMTPFrom, []string{email.To}, []byte(body))
+}
+
+// WebhookDeliverer отправляет события POST-запросом с HMAC подписью
+type WebhookDeliverer struct {
+	Cfg *config.Config
+}
+
+func (d *WebhookDeliverer) Deliver(ctx context.Context, msg models.OutboxMessage) error {
+	var event WebhookEvent
+	if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, event.URL, bytes.NewBufferString(msg.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event", event.Event)
+	req.Header.Set("X-Delivery-ID", fmt.Sprint(msg.ID))
+
+	if d.Cfg.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(d.Cfg.WebhookSecret))
+		mac.Write([]byte(msg.Payload))
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return doRequest(req)
+}
+
+// XAPIDeliverer отправляет утверждения в LRS
+type XAPIDeliverer struct {
+	Cfg *config.Config
+}
+
+func (d *XAPIDeliverer) Deliver(ctx context.Context, msg models.OutboxMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Cfg.XAPIEndpoint+"/statements", bytes.NewBufferString(msg.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Experience-API-Version", "1.0.3")
+	if d.Cfg.XAPIUsername != "" {
+		req.SetBasicAuth(d.Cfg.XAPIUsername, d.Cfg.XAPIPassword)
+	}
+
+	return doRequest(req)
+}
+
+// FanoutDeliverer раскладывает уведомление студентам курса на письма каждому получателю.
+// Письма пишутся в outbox одной транзакцией и уходят следующими пачками relay. Письмо помнит
+// родительское сообщение и получателя, поэтому повторная попытка не шлет письма второй раз.
+type FanoutDeliverer struct {
+	DB *gorm.DB
+}
+
+// fanoutBatchSize — сколько писем вставляется одним INSERT
+const fanoutBatchSize = 500
+
+func (d *FanoutDeliverer) Deliver(ctx context.Context, msg models.OutboxMessage) error {
+	var fanout Fanout
+	if err := json.Unmarshal([]byte(msg.Payload), &fanout); err != nil {
+		return err
+	}
+
+	return d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var recipients []struct {
+			ID    uint
+			Email string
+		}
+		if err := FanoutRecipients(tx, fanout).Scan(&recipients).Error; err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		emails := make([]models.OutboxMessage, 0, len(recipients))
+		for _, recipient := range recipients {
+			data, err := json.Marshal(EmailMessage{To: recipient.Email, Subject: fanout.Subject, Body: fanout.Body})
+			if err != nil {
+				return err
+			}
+			emails = append(emails, models.OutboxMessage{
+				Kind: KindEmail, Payload: string(data), Status: StatusPending, NextAttemptAt: now,
+				ParentID: &msg.ID, RecipientID: &recipient.ID,
+			})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&emails, fanoutBatchSize).Error
+	})
+}
+
+func doRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package outbox
+
+import (
+	"encoding/json"
+	"project/backend/config"
+	"project/backend/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Типы внешних доставок
+const (
+	KindEmail   = "email"
+	KindWebhook = "webhook"
+	KindXAPI    = "xapi"
+	KindFanout  = "fanout" // expanded by the relay into one email per recipient
+)
+
+// Статусы сообщений outbox
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// EmailMessage описывает письмо для отправки
+type EmailMessage struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// WebhookEvent описывает событие для отправки во внешний webhook
+type WebhookEvent struct {
+	URL        string      `json:"url"`
+	Event      string      `json:"event"`
+	Data       interface{} `json:"data"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// Statement - упрощенное xAPI утверждение (actor - verb - object)
+type Statement struct {
+	Actor     StatementActor   `json:"actor"`
+	Verb      StatementVerb    `json:"verb"`
+	Object    StatementObject  `json:"object"`
+	Result    *StatementResult `json:"result,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+type StatementActor struct {
+	ObjectType string           `json:"objectType"`
+	Name       string           `json:"name,omitempty"`
+	Account    StatementAccount `json:"account"`
+}
+
+type StatementAccount struct {
+	HomePage string `json:"homePage"`
+	Name     string `json:"name"`
+}
+
+type StatementVerb struct {
+	ID      string            `json:"id"`
+	Display map[string]string `json:"display"`
+}
+
+type StatementObject struct {
+	ObjectType string                 `json:"objectType"`
+	ID         string                 `json:"id"`
+	Definition map[string]interface{} `json:"definition,omitempty"`
+}
+
+type StatementResult struct {
+	Score      *StatementScore `json:"score,omitempty"`
+	Completion bool            `json:"completion"`
+	Success    *bool           `json:"success,omitempty"`
+}
+
+type StatementScore struct {
+	Scaled float64 `json:"scaled"`
+	Raw    float64 `json:"raw"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// Enqueue записывает сообщение в outbox. Должен вызываться в той же транзакции,
+// что и изменение предметной области, чтобы доставка не потерялась при сбое.
+func Enqueue(tx *gorm.DB, kind string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxMessage{
+		Kind:          kind,
+		Payload:       string(data),
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// EnqueueEmail ставит письмо в очередь на отправку
+func EnqueueEmail(tx *gorm.DB, to, subject, body string) error {
+	return Enqueue(tx, KindEmail, EmailMessage{To: to, Subject: subject, Body: body})
+}
+
+// Категории уведомлений, которые пользователь может отключить в настройках
+const (
+	NotifyComments      = "comments"
+	NotifyCourseUpdates = "course_updates"
+	NotifyLimits        = "limits" // approaching soft limits, only the master switch turns it off
+	NotifyNudges        = "nudges"
+	NotifyBadges        = "badges" // badges earned in study circle challenges
+)
+
+// EnqueueNotification ставит уведомление пользователю в очередь, если он не отключил эту категорию писем
+func EnqueueNotification(tx *gorm.DB, userID uint, category, subject, body string) error {
+	var user models.User
+	if err := tx.Select("id", "email").Limit(1).Find(&user, userID).Error; err != nil || user.ID == 0 {
+		return err
+	}
+
+	enabled, err := NotificationsEnabled(tx, userID, category)
+	if err != nil || !enabled {
+		return err
+	}
+	return EnqueueEmail(tx, user.Email, subject, body)
+}
+
+// NotificationsEnabled сообщает, получает ли пользователь письма категории category
+func NotificationsEnabled(tx *gorm.DB, userID uint, category string) (bool, error) {
+	var settings models.UserSettings
+	if err := tx.Where("user_id = ?", userID).Limit(1).Find(&settings).Error; err != nil {
+		return false, err
+	}
+	// Without a settings row every category is enabled
+	if settings.ID == 0 {
+		return true, nil
+	}
+	enabled := settings.EmailNotifications
+	switch category {
+	case NotifyComments:
+		enabled = enabled && settings.EmailComments
+	case NotifyCourseUpdates:
+		enabled = enabled && settings.EmailCourseUpdates
+	case NotifyNudges:
+		enabled = enabled && settings.EmailNudges
+	case NotifyBadges:
+		enabled = enabled && settings.EmailBadges
+	}
+	return enabled, nil
+}
+
+// Fanout — уведомление студентам курса. Запрос ставит в очередь одно такое сообщение, а письма каждому
+// получателю раскладывает relay при доставке, так что транзакция запроса не растет с числом студентов.
+type Fanout struct {
+	CourseID  uint   `json:"course_id"`
+	RunID     *uint  `json:"run_id,omitempty"`     // only the learners of one run
+	ExcludeID uint   `json:"exclude_id,omitempty"` // usually the author who caused the notification
+	Category  string `json:"category"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+}
+
+// EnqueueFanout ставит уведомление студентам курса в очередь
+func EnqueueFanout(tx *gorm.DB, fanout Fanout) error {
+	return Enqueue(tx, KindFanout, fanout)
+}
+
+// FanoutRecipients выбирает id и email записанных на курс студентов, которые не отключили категорию уведомления
+func FanoutRecipients(tx *gorm.DB, fanout Fanout) *gorm.DB {
+	query := tx.Table("users").Select("DISTINCT users.id, users.email").
+		Joins("JOIN user_course_progress AS p ON p.user_id = users.id AND p.deleted_at IS NULL").
+		Joins("LEFT JOIN user_settings AS s ON s.user_id = users.id AND s.deleted_at IS NULL").
+		Where("p.course_id = ? AND users.id <> ? AND users.deleted_at IS NULL", fanout.CourseID, fanout.ExcludeID)
+	if fanout.RunID != nil {
+		query = query.Where("p.run_id = ?", *fanout.RunID)
+	}
+	// Without a settings row every category is enabled, as in NotificationsEnabled
+	enabled := "s.email_notifications"
+	if column := categoryColumn(fanout.Category); column != "" {
+		enabled += " AND s." + column
+	}
+	return query.Where("s.id IS NULL OR (" + enabled + ")")
+}
+
+// categoryColumn — колонка user_settings, отключающая категорию; пусто, если действует только общий флаг
+func categoryColumn(category string) string {
+	switch category {
+	case NotifyComments:
+		return "email_comments"
+	case NotifyCourseUpdates:
+		return "email_course_updates"
+	case NotifyNudges:
+		return "email_nudges"
+	case NotifyBadges:
+		return "email_badges"
+	}
+	return ""
+}
+
+// EnqueueWebhook ставит событие в очередь, если webhook настроен
+func EnqueueWebhook(tx *gorm.DB, cfg *config.Config, event string, data interface{}) error {
+	if cfg.WebhookURL == "" {
+		return nil
+	}
+	return Enqueue(tx, KindWebhook, WebhookEvent{
+		URL:        cfg.WebhookURL,
+		Event:      event,
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	})
+}
+
+// EnqueueStatement ставит xAPI утверждение в очередь, если LRS настроен
+func EnqueueStatement(tx *gorm.DB, cfg *config.Config, statement Statement) error {
+	if cfg.XAPIEndpoint == "" {
+		return nil
+	}
+	return Enqueue(tx, KindXAPI, statement)
+}
+
+// NewStatement собирает xAPI утверждение для пользователя платформы
+func NewStatement(cfg *config.Config, userID uint, userName, verb, objectType string, objectID uint, objectName string) Statement {
+	return Statement{
+		Actor: StatementActor{
+			ObjectType: "Agent",
+			Name:       userName,
+			Account: StatementAccount{
+				HomePage: cfg.XAPIHomePage,
+				Name:     strconv.Itoa(int(userID)),
+			},
+		},
+		Verb: StatementVerb{
+			ID:      "http://adlnet.gov/expapi/verbs/" + verb,
+			Display: map[string]string{"en-US": verb},
+		},
+		Object: StatementObject{
+			ObjectType: "Activity",
+			ID:         cfg.XAPIHomePage + "/" + objectType + "/" + strconv.Itoa(int(objectID)),
+			Definition: map[string]interface{}{
+				"name": map[string]string{"en-US": objectName},
+			},
+		},
+		Timestamp: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"project/backend/config"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	relayBatchSize = 50
+	baseBackoff    = 30 * time.Second
+	maxBackoff     = time.Hour
+	// claimLease — сколько забранные сообщения недоступны другим экземплярам; с запасом больше
+	// времени доставки пачки (таймаут HTTP — 10 секунд на сообщение)
+	claimLease = 15 * time.Minute
+)
+
+// Relay периодически забирает неотправленные сообщения из outbox и доставляет их
+type Relay struct {
+	DB         *gorm.DB
+	Cfg        *config.Config
+	Logger     *log.Logger
+	Deliverers map[string]Deliverer
+}
+
+func NewRelay(db *gorm.DB, cfg *config.Config, logger *log.Logger) *Relay {
+	return &Relay{
+		DB:     db,
+		Cfg:    cfg,
+		Logger: logger,
+		Deliverers: map[string]Deliverer{
+			KindEmail:   &EmailDeliverer{Cfg: cfg, Logger: logger},
+			KindWebhook: &WebhookDeliverer{Cfg: cfg},
+			KindXAPI:    &XAPIDeliverer{Cfg: cfg},
+			KindFanout:  &FanoutDeliverer{DB: db},
+		},
+	}
+}
+
+// ProcessBatch доставляет одну пачку готовых к отправке сообщений. Пачка забирается короткой транзакцией
+// через SKIP LOCKED: следующая попытка сообщений сдвигается на claimLease, поэтому другие экземпляры
+// их не берут, а доставка идет уже без транзакции и блокировок. Если экземпляр упал посреди доставки,
+// сообщения снова станут готовыми, когда истечет claimLease.
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	messages, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	db := r.DB.WithContext(ctx)
+	for i := range messages {
+		msg := &messages[i]
+		r.deliver(ctx, msg)
+		if err := db.Model(msg).Select("status", "sent_at", "last_error", "next_attempt_at").Updates(msg).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(messages), nil
+}
+
+// claim забирает пачку готовых сообщений и засчитывает им попытку
+func (r *Relay) claim(ctx context.Context) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Order("id").
+			Limit(relayBatchSize).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+			messages[i].Attempts++
+		}
+		return tx.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(claimLease),
+		}).Error
+	})
+	return messages, err
+}
+
+// deliver отправляет сообщение, уже забранное claim, и записывает в него результат
+func (r *Relay) deliver(ctx context.Context, msg *models.OutboxMessage) {
+	deliverer, ok := r.Deliverers[msg.Kind]
+	var err error
+	if !ok {
+		err = fmt.Errorf("no deliverer for kind %q", msg.Kind)
+	} else {
+		err = deliverer.Deliver(ctx, *msg)
+	}
+
+	if err == nil {
+		now := time.Now()
+		msg.Status = StatusSent
+		msg.SentAt = &now
+		msg.LastError = ""
+		return
+	}
+
+	msg.LastError = err.Error()
+	if msg.Attempts >= r.Cfg.OutboxMaxAttempts {
+		msg.Status = StatusFailed
+		r.Logger.Printf("[outbox] message %d (%s) failed permanently: %v", msg.ID, msg.Kind, err)
+		return
+	}
+	msg.NextAttemptAt = time.Now().Add(Backoff(msg.Attempts))
+}
+
+// Backoff возвращает задержку перед следующей попыткой (экспоненциально, с ограничением)
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,16 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, 60*time.Second, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, time.Hour, Backoff(8))
+	assert.Equal(t, time.Hour, Backoff(20))
+}
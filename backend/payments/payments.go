@@ -0,0 +1,96 @@
+// Package payments — оплата платных курсов через внешнего провайдера.
+// Провайдер создает страницу оплаты и сообщает о результате вебхуком; доступ к курсу
+// выдается только по подтвержденному вебхуку, а не по возврату студента со страницы оплаты.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/backend/config"
+	"time"
+)
+
+var (
+	// ErrDisabled возвращается, если провайдер не настроен
+	ErrDisabled = errors.New("payment provider is not configured")
+	// ErrSignature — вебхук не прошел проверку подлинности
+	ErrSignature = errors.New("invalid webhook signature")
+)
+
+// Результаты оплаты, о которых сообщает вебхук
+const (
+	EventPaid     = "paid"
+	EventFailed   = "failed"
+	EventCanceled = "canceled"
+)
+
+const requestTimeout = 15 * time.Second
+
+// Checkout — что нужно провайдеру, чтобы создать страницу оплаты заказа
+type Checkout struct {
+	OrderID     uint
+	Amount      int64  // in minor units: cents, kopecks
+	Currency    string // ISO 4217 code
+	Description string
+	Email       string
+	ReturnURL   string
+}
+
+// Session — созданная страница оплаты
+type Session struct {
+	ID  string // checkout session or payment ID at the provider
+	URL string // where to send the student
+}
+
+// Event — разобранный вебхук. Пустой Type — событие, которое нас не касается.
+type Event struct {
+	Type      string
+	OrderID   uint
+	SessionID string
+	PaymentID string
+}
+
+// Provider создает оплату и разбирает уведомления о ней
+type Provider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, checkout Checkout) (Session, error)
+	// ParseWebhook проверяет подлинность уведомления и возвращает его смысл
+	ParseWebhook(ctx context.Context, body []byte, header func(string) string) (Event, error)
+}
+
+// Default — провайдер, используемый контроллерами. Заменяется в main по конфигурации.
+var Default Provider = Disabled{}
+
+// Disabled — провайдер по умолчанию: платные курсы купить нельзя
+type Disabled struct{}
+
+func (Disabled) Name() string { return "" }
+
+func (Disabled) CreateCheckout(context.Context, Checkout) (Session, error) {
+	return Session{}, ErrDisabled
+}
+
+func (Disabled) ParseWebhook(context.Context, []byte, func(string) string) (Event, error) {
+	return Event{}, ErrDisabled
+}
+
+// New создает провайдер по cfg.PaymentProvider: пусто (выключено), "stripe" или "yookassa"
+func New(cfg *config.Config) (Provider, error) {
+	switch cfg.PaymentProvider {
+	case "":
+		return Disabled{}, nil
+	case "stripe":
+		if cfg.PaymentSecretKey == "" || cfg.PaymentWebhookSecret == "" {
+			return nil, fmt.Errorf("the stripe payment provider requires PAYMENT_SECRET_KEY and PAYMENT_WEBHOOK_SECRET")
+		}
+		return NewStripe(cfg.PaymentSecretKey, cfg.PaymentWebhookSecret), nil
+	case "yookassa":
+		if cfg.PaymentShopID == "" || cfg.PaymentSecretKey == "" {
+			return nil, fmt.Errorf("the yookassa payment provider requires PAYMENT_SHOP_ID and PAYMENT_SECRET_KEY")
+		}
+		return NewYooKassa(cfg.PaymentShopID, cfg.PaymentSecretKey), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.PaymentProvider)
+	}
+}
@@ -0,0 +1,167 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stripe signs webhooks with a timestamp; older deliveries are treated as replays
+const stripeSignatureTolerance = 5 * time.Minute
+
+// Stripe — оплата через Stripe Checkout
+type Stripe struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	client        *http.Client
+}
+
+func NewStripe(secretKey, webhookSecret string) *Stripe {
+	return &Stripe{
+		baseURL:       "https://api.stripe.com/v1",
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (s *Stripe) Name() string { return "stripe" }
+
+func (s *Stripe) CreateCheckout(ctx context.Context, checkout Checkout) (Session, error) {
+	orderID := strconv.FormatUint(uint64(checkout.OrderID), 10)
+	form := url.Values{
+		"mode":                                   {"payment"},
+		"success_url":                            {checkout.ReturnURL},
+		"cancel_url":                             {checkout.ReturnURL},
+		"client_reference_id":                    {orderID},
+		"metadata[order_id]":                     {orderID},
+		"line_items[0][quantity]":                {"1"},
+		"line_items[0][price_data][currency]":    {strings.ToLower(checkout.Currency)},
+		"line_items[0][price_data][unit_amount]": {strconv.FormatInt(checkout.Amount, 10)},
+		"line_items[0][price_data][product_data][name]": {checkout.Description},
+	}
+	if checkout.Email != "" {
+		form.Set("customer_email", checkout.Email)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Session{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+s.secretKey)
+	// A retried request for the same order doesn't open a second session
+	req.Header.Set("Idempotency-Key", "order-"+orderID)
+
+	var result struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := doJSON(s.client, req, &result); err != nil {
+		return Session{}, err
+	}
+	return Session{ID: result.ID, URL: result.URL}, nil
+}
+
+func (s *Stripe) ParseWebhook(_ context.Context, body []byte, header func(string) string) (Event, error) {
+	if err := s.verify(body, header("Stripe-Signature"), time.Now()); err != nil {
+		return Event{}, err
+	}
+
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID                string `json:"id"`
+				PaymentIntent     string `json:"payment_intent"`
+				PaymentStatus     string `json:"payment_status"`
+				ClientReferenceID string `json:"client_reference_id"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Event{}, err
+	}
+
+	session := event.Data.Object
+	orderID, _ := strconv.ParseUint(session.ClientReferenceID, 10, 64)
+	result := Event{OrderID: uint(orderID), SessionID: session.ID, PaymentID: session.PaymentIntent}
+	switch event.Type {
+	case "checkout.session.completed":
+		// Delayed methods (bank debits) complete the session before the money arrives
+		if session.PaymentStatus == "paid" {
+			result.Type = EventPaid
+		}
+	case "checkout.session.async_payment_succeeded":
+		result.Type = EventPaid
+	case "checkout.session.async_payment_failed":
+		result.Type = EventFailed
+	case "checkout.session.expired":
+		result.Type = EventCanceled
+	}
+	return result, nil
+}
+
+// verify проверяет заголовок Stripe-Signature: t=<время>,v1=<HMAC-SHA256 от "t.тело">
+func (s *Stripe) verify(body []byte, signature string, now time.Time) error {
+	var timestamp string
+	var candidates []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			candidates = append(candidates, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(candidates) == 0 {
+		return ErrSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return ErrSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	for _, candidate := range candidates {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+// doJSON выполняет запрос к API провайдера и разбирает JSON-ответ в result
+func doJSON(client *http.Client, req *http.Request, result interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("payment provider returned %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("payment provider response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"errors"
+	"project/backend/models"
+	"project/backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+// localsKey — ключ c.Locals, под которым AuthMiddleware оставляет данные для Authorize
+const localsKey = "policy"
+
+type requestSubject struct {
+	db      *gorm.DB
+	userID  uint
+	loaded  bool
+	subject Subject
+	err     error
+}
+
+// Attach привязывает к запросу аутентифицированного пользователя.
+// Субъект загружается из БД только при первом вызове Authorize.
+func Attach(c *fiber.Ctx, db *gorm.DB, userID uint) {
+	c.Locals(localsKey, &requestSubject{db: db, userID: userID})
+}
+
+// Authorize проверяет, может ли текущий пользователь выполнить action над resource.
+// Недостающие факты (настройки доступа, участие в курсе) подгружаются из БД.
+func Authorize(c *fiber.Ctx, action Action, resource Resource) error {
+	state, ok := c.Locals(localsKey).(*requestSubject)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	db := state.db.WithContext(c.UserContext())
+	if !state.loaded {
+		state.subject, state.err = LoadSubject(db, state.userID)
+		state.loaded = true
+	}
+	if state.err != nil {
+		return state.err
+	}
+
+	if err := complete(db, state.userID, &resource); err != nil {
+		return err
+	}
+	if !Default.Allowed(state.subject, action, resource) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Status подбирает HTTP-статус для ошибки Authorize
+func Status(err error) int {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		return fiber.StatusForbidden
+	case errors.Is(err, ErrUnauthenticated):
+		return fiber.StatusUnauthorized
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// LoadSubject загружает роли пользователя: признак администратора и разрешения по университетам
+func LoadSubject(db *gorm.DB, userID uint) (Subject, error) {
+	subject := Subject{UserID: userID, Admin: utils.IsAdmin(db, userID), Grants: make(map[string][]string)}
+
+	var grants []struct {
+		Code       string
+		University string
+	}
+	if err := db.Table("user_roles").
+		Select("DISTINCT permissions.code, COALESCE(user_roles.university, '') AS university").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ? AND user_roles.deleted_at IS NULL", userID).
+		Scan(&grants).Error; err != nil {
+		return Subject{}, err
+	}
+	for _, grant := range grants {
+		subject.Grants[grant.Code] = append(subject.Grants[grant.Code], grant.University)
+	}
+	return subject, nil
+}
+
+// complete дозагружает настройки доступа ресурса, участие пользователя в нем, место в списке допуска
+// и его роль в курсе или сессии
+func complete(db *gorm.DB, userID uint, r *Resource) error {
+	var (
+		settings interface{}
+		progress interface{}
+		column   string
+		ownerID  = r.ID
+	)
+	switch r.Kind {
+	case KindCourse:
+		settings, progress, column = &models.CourseAccessSettings{}, &models.UserCourseProgress{}, "course_id"
+	case KindTest:
+		settings, progress, column = &models.TestAccessSettings{}, &models.UserTestProgress{}, "test_id"
+	case KindExamSession:
+		settings, progress, column, ownerID = &models.TestAccessSettings{}, &models.UserTestProgress{}, "test_id", r.TestID
+	default:
+		return nil
+	}
+
+	if !r.settingsLoaded {
+		// Course co-authors live in course_staff, only tests keep co-admins in their access settings
+		columns := []string{"access_level", "admins"}
+		if r.Kind == KindCourse {
+			columns = columns[:1]
+		}
+		var row struct {
+			AccessLevel string
+			Admins      string
+		}
+		if err := db.Model(settings).Select(columns).
+			Where(column+" = ?", ownerID).Limit(1).Scan(&row).Error; err != nil {
+			return err
+		}
+		r.withSettings(row.AccessLevel, row.Admins)
+	}
+
+	var enrolled int64
+	if err := db.Model(progress).Where("user_id = ? AND "+column+" = ?", userID, ownerID).
+		Count(&enrolled).Error; err != nil {
+		return err
+	}
+	r.Enrolled = enrolled > 0
+
+	r.Listed = false
+	if r.Kind == KindCourse && r.AccessLevel == models.AccessRestricted {
+		var listed int64
+		if err := db.Model(&models.CourseAccessList{}).Where("course_id = ?", r.ID).
+			Where("user_id = ? OR email = (SELECT LOWER(email) FROM users WHERE id = ?) OR group_id IN (?)",
+				userID, userID, db.Model(&models.StudyGroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+			Count(&listed).Error; err != nil {
+			return err
+		}
+		r.Listed = listed > 0
+	}
+
+	r.StaffRole = ""
+	switch r.Kind {
+	case KindCourse:
+		var roles []string
+		if err := db.Model(&models.CourseStaff{}).Where("course_id = ? AND user_id = ?", r.ID, userID).
+			Limit(1).Pluck("role", &roles).Error; err != nil {
+			return err
+		}
+		if len(roles) > 0 {
+			r.StaffRole = roles[0]
+		}
+	case KindExamSession:
+		var proctors int64
+		if err := db.Model(&models.ExamSessionProctor{}).Where("session_id = ? AND user_id = ?", r.ID, userID).
+			Count(&proctors).Error; err != nil {
+			return err
+		}
+		if proctors > 0 {
+			r.StaffRole = models.ExamRoleProctor
+		}
+	}
+	return nil
+}
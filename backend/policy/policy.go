@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"project/backend/models"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Action — действие над ресурсом
+type Action string
+
+const (
+	ActionView          Action = "view"           // открыть курс или тест
+	ActionEdit          Action = "edit"           // менять содержимое, настройки и обложку
+	ActionViewAnalytics Action = "view_analytics" // смотреть аналитику и живую ленту
+	ActionInvite        Action = "invite"         // приглашать пользователей в курс
+	ActionGrade         Action = "grade"          // выгружать ведомость с результатами студентов
+	ActionModerate      Action = "moderate"       // отвечать на комментарии
+	ActionManageStaff   Action = "manage_staff"   // назначать ассистентов курса и прокторов сессии
+	ActionExtendTime    Action = "extend_time"    // продлевать время попытки студента
+	ActionInvalidate    Action = "invalidate"     // аннулировать попытку студента
+)
+
+// Kind — тип ресурса
+type Kind string
+
+const (
+	KindCourse      Kind = "course"
+	KindTest        Kind = "test"
+	KindExamSession Kind = "exam_session"
+)
+
+// Subject — пользователь, выполняющий действие
+type Subject struct {
+	UserID uint
+	Admin  bool                // администратор платформы: разрешено все, что проверяется через Can
+	Grants map[string][]string // код разрешения -> университеты, где оно выдано ("" = везде)
+}
+
+// Can сообщает, есть ли у субъекта разрешение code в университете university
+func (s Subject) Can(code, university string) bool {
+	if s.Admin {
+		return true
+	}
+	for _, scope := range s.Grants[code] {
+		if scope == "" || scope == university {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource — курс, тест или экзаменационная сессия вместе с фактами, которые нужны правилам
+type Resource struct {
+	Kind        Kind
+	ID          uint
+	TestID      uint // тест экзаменационной сессии: автор, настройки доступа и участие берутся от него
+	AuthorID    uint
+	University  string
+	AccessLevel string // public, private, restricted
+	CoAdmins    []uint // со-администраторы теста из настроек доступа; соавторы курса приходят через StaffRole
+	Enrolled    bool   // субъект уже проходит курс или тест
+	Listed      bool   // субъект есть в списке допуска курса с уровнем restricted
+	StaffRole   string // роль субъекта в курсе (models.CourseRole*) или сессии (models.ExamRoleProctor)
+
+	// set once the access settings above are known; Authorize loads them otherwise
+	settingsLoaded bool
+}
+
+// Course описывает курс как ресурс. Настройки доступа берутся из course.AccessSettings, если они загружены.
+func Course(course *models.Course) Resource {
+	resource := Resource{Kind: KindCourse, ID: course.ID, AuthorID: course.AuthorID, University: course.University}
+	if course.AccessSettings.ID != 0 {
+		resource.withSettings(course.AccessSettings.AccessLevel, "")
+	}
+	return resource
+}
+
+// Test описывает тест как ресурс. Настройки доступа берутся из test.AccessSettings, если они загружены.
+func Test(test *models.Test) Resource {
+	resource := Resource{Kind: KindTest, ID: test.ID, AuthorID: test.AuthorID, University: test.University}
+	if test.AccessSettings.ID != 0 {
+		resource.withSettings(test.AccessSettings.AccessLevel, test.AccessSettings.Admins)
+	}
+	return resource
+}
+
+// ExamSession описывает экзаменационную сессию как ресурс; права автора и со-администраторов наследуются от теста
+func ExamSession(session *models.ExamSession, test *models.Test) Resource {
+	resource := Test(test)
+	resource.Kind, resource.ID, resource.TestID = KindExamSession, session.ID, test.ID
+	return resource
+}
+
+func (r *Resource) withSettings(accessLevel, admins string) {
+	r.AccessLevel = accessLevel
+	r.CoAdmins = ParseAdmins(admins)
+	r.settingsLoaded = true
+}
+
+// ParseAdmins разбирает список со-администраторов вида "3,15,42"
+func ParseAdmins(admins string) []uint {
+	var ids []uint
+	for _, part := range strings.Split(admins, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64); err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// Rule — именованное правило: разрешает действие, если Allow вернул true
+type Rule struct {
+	Name  string
+	Allow func(s Subject, r Resource) bool
+}
+
+// Author — автор ресурса
+var Author = Rule{"author", func(s Subject, r Resource) bool {
+	return r.AuthorID != 0 && r.AuthorID == s.UserID
+}}
+
+// CoAdmin — со-администратор теста из настроек доступа
+var CoAdmin = Rule{"co-admin", func(s Subject, r Resource) bool {
+	return slices.Contains(r.CoAdmins, s.UserID)
+}}
+
+// EnrolledStudent — студент, который уже проходит курс или тест
+var EnrolledStudent = Rule{"enrolled-student", func(s Subject, r Resource) bool {
+	return r.Enrolled
+}}
+
+// CourseEditor — соавтор курса из course_staff: правит содержимое и управляет курсом наравне с автором
+var CourseEditor = Rule{"editor", func(s Subject, r Resource) bool {
+	return r.StaffRole == models.CourseRoleEditor
+}}
+
+// CourseViewer — наблюдатель курса из course_staff: видит содержимое и аналитику, ничего не меняет
+var CourseViewer = Rule{"viewer", func(s Subject, r Resource) bool {
+	return r.StaffRole == models.CourseRoleViewer
+}}
+
+// TeachingAssistant — ассистент курса из course_staff
+var TeachingAssistant = Rule{"teaching-assistant", func(s Subject, r Resource) bool {
+	return r.StaffRole == models.CourseRoleTA
+}}
+
+// Proctor — проктор экзаменационной сессии
+var Proctor = Rule{"proctor", func(s Subject, r Resource) bool {
+	return r.StaffRole == models.ExamRoleProctor
+}}
+
+// PublicAccess — ресурс открыт всем
+var PublicAccess = Rule{"public", func(s Subject, r Resource) bool {
+	return r.AccessLevel == "public"
+}}
+
+// AccessListed — пользователь из списка допуска курса с уровнем доступа restricted
+var AccessListed = Rule{"access-list", func(s Subject, r Resource) bool {
+	return r.AccessLevel == models.AccessRestricted && r.Listed
+}}
+
+// OrgAdmin — роль с разрешением code в университете ресурса (или глобально)
+func OrgAdmin(code string) Rule {
+	return Rule{"org-admin:" + code, func(s Subject, r Resource) bool {
+		return s.Can(code, r.University)
+	}}
+}
+
+// Engine хранит правила для каждого типа ресурса и действия. Действие разрешено,
+// если сработало хотя бы одно правило; для неизвестного действия все запрещено.
+type Engine struct {
+	rules map[Kind]map[Action][]Rule
+}
+
+func NewEngine() *Engine {
+	return &Engine{rules: make(map[Kind]map[Action][]Rule)}
+}
+
+// Allow добавляет правила для действия над ресурсом типа kind
+func (e *Engine) Allow(kind Kind, action Action, rules ...Rule) *Engine {
+	if e.rules[kind] == nil {
+		e.rules[kind] = make(map[Action][]Rule)
+	}
+	e.rules[kind][action] = append(e.rules[kind][action], rules...)
+	return e
+}
+
+// Decide возвращает имя сработавшего правила или false, если действие запрещено
+func (e *Engine) Decide(s Subject, action Action, r Resource) (string, bool) {
+	for _, rule := range e.rules[r.Kind][action] {
+		if rule.Allow(s, r) {
+			return rule.Name, true
+		}
+	}
+	return "", false
+}
+
+// Allowed сообщает, разрешено ли действие
+func (e *Engine) Allowed(s Subject, action Action, r Resource) bool {
+	_, ok := e.Decide(s, action, r)
+	return ok
+}
+
+// Default — правила платформы
+var Default = NewEngine().
+	Allow(KindCourse, ActionView, PublicAccess, AccessListed, EnrolledStudent, Author, CourseEditor, TeachingAssistant, CourseViewer, OrgAdmin(models.PermCoursesEdit)).
+	Allow(KindCourse, ActionEdit, Author, CourseEditor, OrgAdmin(models.PermCoursesEdit)).
+	Allow(KindCourse, ActionViewAnalytics, Author, CourseEditor, TeachingAssistant, CourseViewer, OrgAdmin(models.PermAnalyticsView)).
+	Allow(KindCourse, ActionInvite, Author, CourseEditor, OrgAdmin(models.PermCoursesEdit)).
+	Allow(KindCourse, ActionGrade, Author, CourseEditor, TeachingAssistant, OrgAdmin(models.PermAnalyticsView)).
+	Allow(KindCourse, ActionModerate, Author, CourseEditor, TeachingAssistant, OrgAdmin(models.PermCoursesEdit)).
+	Allow(KindCourse, ActionManageStaff, Author, CourseEditor, OrgAdmin(models.PermCoursesEdit)).
+	Allow(KindTest, ActionView, PublicAccess, EnrolledStudent, Author, CoAdmin, OrgAdmin(models.PermTestsEdit)).
+	Allow(KindTest, ActionEdit, Author, CoAdmin, OrgAdmin(models.PermTestsEdit)).
+	Allow(KindTest, ActionViewAnalytics, Author, CoAdmin, OrgAdmin(models.PermAnalyticsView)).
+	Allow(KindTest, ActionGrade, Author, CoAdmin, OrgAdmin(models.PermAnalyticsView)).
+	Allow(KindExamSession, ActionEdit, Author, CoAdmin, OrgAdmin(models.PermTestsEdit)).
+	Allow(KindExamSession, ActionManageStaff, Author, CoAdmin, OrgAdmin(models.PermTestsEdit)).
+	Allow(KindExamSession, ActionViewAnalytics, Author, CoAdmin, Proctor, OrgAdmin(models.PermAnalyticsView)).
+	Allow(KindExamSession, ActionExtendTime, Author, CoAdmin, Proctor, OrgAdmin(models.PermTestsEdit)).
+	Allow(KindExamSession, ActionInvalidate, Author, CoAdmin, Proctor, OrgAdmin(models.PermTestsEdit))
@@ -0,0 +1,114 @@
+package policy_test
+
+import (
+	"project/backend/models"
+	"project/backend/policy"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The policy engine is pure: these cases need neither the database nor the app
+func TestPolicyEngine(t *testing.T) {
+	course := policy.Resource{
+		Kind:        policy.KindCourse,
+		ID:          1,
+		AuthorID:    10,
+		University:  "MSU",
+		AccessLevel: "private",
+	}
+	student := policy.Subject{UserID: 20}
+	engine := policy.Default
+
+	cases := []struct {
+		name    string
+		subject policy.Subject
+		action  policy.Action
+		enroll  bool
+		allowed bool
+		rule    string
+	}{
+		{"author edits", policy.Subject{UserID: 10}, policy.ActionEdit, false, true, "author"},
+		{"student can't edit", student, policy.ActionEdit, true, false, ""},
+		{"enrolled student views private course", student, policy.ActionView, true, true, "enrolled-student"},
+		{"outsider can't view private course", student, policy.ActionView, false, false, ""},
+		{"org admin of the university edits",
+			policy.Subject{UserID: 30, Grants: map[string][]string{models.PermCoursesEdit: {"MSU"}}},
+			policy.ActionEdit, false, true, "org-admin:" + models.PermCoursesEdit},
+		{"org admin of another university can't edit",
+			policy.Subject{UserID: 31, Grants: map[string][]string{models.PermCoursesEdit: {"SPbU"}}},
+			policy.ActionEdit, false, false, ""},
+		{"global grant works everywhere",
+			policy.Subject{UserID: 32, Grants: map[string][]string{models.PermAnalyticsView: {""}}},
+			policy.ActionViewAnalytics, false, true, "org-admin:" + models.PermAnalyticsView},
+		{"platform admin", policy.Subject{UserID: 33, Admin: true}, policy.ActionInvite, false, true, "org-admin:" + models.PermCoursesEdit},
+		{"assistant can't edit", student, policy.ActionEdit, false, false, ""},
+		{"unknown action is denied", policy.Subject{UserID: 10}, policy.Action("delete"), false, false, ""},
+	}
+
+	for _, tc := range cases {
+		resource := course
+		resource.Enrolled = tc.enroll
+		rule, allowed := engine.Decide(tc.subject, tc.action, resource)
+		assert.Equal(t, tc.allowed, allowed, tc.name)
+		assert.Equal(t, tc.rule, rule, tc.name)
+	}
+
+	// Co-admins of a test come from its access settings, matched by whole ids
+	coAdmined := course
+	coAdmined.Kind, coAdmined.CoAdmins = policy.KindTest, policy.ParseAdmins("11, 12")
+	rule, allowed := engine.Decide(policy.Subject{UserID: 12}, policy.ActionEdit, coAdmined)
+	assert.True(t, allowed)
+	assert.Equal(t, "co-admin", rule)
+	assert.False(t, engine.Allowed(policy.Subject{UserID: 1}, policy.ActionEdit, coAdmined), "co-admin id is not a substring match")
+
+	// Course co-authors: editors manage the course with its author, viewers only look
+	editor := course
+	editor.StaffRole = models.CourseRoleEditor
+	for _, action := range []policy.Action{policy.ActionEdit, policy.ActionInvite, policy.ActionManageStaff, policy.ActionGrade} {
+		rule, allowed := engine.Decide(student, action, editor)
+		assert.True(t, allowed, action)
+		assert.Equal(t, "editor", rule, action)
+	}
+	viewer := course
+	viewer.StaffRole = models.CourseRoleViewer
+	for _, action := range []policy.Action{policy.ActionView, policy.ActionViewAnalytics} {
+		rule, allowed := engine.Decide(student, action, viewer)
+		assert.True(t, allowed, action)
+		assert.Equal(t, "viewer", rule, action)
+	}
+	for _, action := range []policy.Action{policy.ActionEdit, policy.ActionGrade, policy.ActionModerate, policy.ActionManageStaff} {
+		assert.False(t, engine.Allowed(student, action, viewer), action)
+	}
+
+	// Teaching assistants grade, answer comments and view analytics, but don't manage the course
+	assistant := course
+	assistant.StaffRole = models.CourseRoleTA
+	for _, action := range []policy.Action{policy.ActionView, policy.ActionGrade, policy.ActionModerate, policy.ActionViewAnalytics} {
+		rule, allowed := engine.Decide(student, action, assistant)
+		assert.True(t, allowed, action)
+		assert.Equal(t, "teaching-assistant", rule, action)
+	}
+	for _, action := range []policy.Action{policy.ActionEdit, policy.ActionInvite, policy.ActionManageStaff} {
+		assert.False(t, engine.Allowed(student, action, assistant), action)
+	}
+
+	// Proctors administer attempts of their exam session, but not the test itself
+	session := course
+	session.Kind, session.TestID, session.StaffRole = policy.KindExamSession, 5, models.ExamRoleProctor
+	for _, action := range []policy.Action{policy.ActionExtendTime, policy.ActionInvalidate, policy.ActionViewAnalytics} {
+		assert.True(t, engine.Allowed(student, action, session), action)
+	}
+	for _, action := range []policy.Action{policy.ActionEdit, policy.ActionManageStaff} {
+		assert.False(t, engine.Allowed(student, action, session), action)
+	}
+
+	public := course
+	public.AccessLevel = "public"
+	assert.True(t, engine.Allowed(student, policy.ActionView, public))
+
+	// Invitations are a course-only action
+	test := course
+	test.Kind = policy.KindTest
+	assert.False(t, engine.Allowed(policy.Subject{UserID: 10}, policy.ActionInvite, test))
+}
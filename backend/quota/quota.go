@@ -0,0 +1,204 @@
+// Package quota считает, сколько преподаватель использует из мягких лимитов платформы — место под файлы
+// уроков, число курсов и размер банка вопросов, — и предупреждает его письмом, когда использование
+// пересекает пороги QuotaWarnPercents. Лимиты мягкие: ничего не запрещается, автор только узнает о них заранее.
+package quota
+
+import (
+	"fmt"
+	"math"
+	"project/backend/config"
+	"project/backend/models"
+	"project/backend/outbox"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Лимиты автора
+const (
+	ResourceStorage   = "storage"   // bytes of lesson attachments in the author's courses
+	ResourceCourses   = "courses"   // courses the user authors
+	ResourceQuestions = "questions" // questions in the author's tests
+)
+
+// Resources — все лимиты в порядке показа
+var Resources = []string{ResourceStorage, ResourceCourses, ResourceQuestions}
+
+// Usage — использование одного лимита
+type Usage struct {
+	Resource  string  `json:"resource"`
+	Unit      string  `json:"unit"` // bytes or items
+	Used      int64   `json:"used"`
+	Limit     int64   `json:"limit"`     // 0 = unlimited
+	Percent   float64 `json:"percent"`   // share of the limit, 0 when unlimited
+	Threshold int     `json:"threshold"` // highest warning threshold reached, 0 = none
+}
+
+// Limit возвращает лимит ресурса из конфигурации; 0 — без ограничения
+func Limit(cfg *config.Config, resource string) int64 {
+	switch resource {
+	case ResourceStorage:
+		return int64(max(cfg.StorageQuotaBytes, 0))
+	case ResourceCourses:
+		return int64(max(cfg.MaxCoursesPerAuthor, 0))
+	case ResourceQuestions:
+		return int64(max(cfg.MaxQuestionsPerAuthor, 0))
+	}
+	return 0
+}
+
+// Thresholds разбирает QuotaWarnPercents: проценты от 1 до 100 по возрастанию, неверные значения пропускаются
+func Thresholds(cfg *config.Config) []int {
+	seen := map[int]bool{}
+	var thresholds []int
+	for _, part := range strings.Split(cfg.QuotaWarnPercents, ",") {
+		percent, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || percent < 1 || percent > 100 || seen[percent] {
+			continue
+		}
+		seen[percent] = true
+		thresholds = append(thresholds, percent)
+	}
+	sort.Ints(thresholds)
+	return thresholds
+}
+
+// Current считает использование всех лимитов автора
+func Current(db *gorm.DB, cfg *config.Config, userID uint) ([]Usage, error) {
+	thresholds := Thresholds(cfg)
+	result := make([]Usage, 0, len(Resources))
+	for _, resource := range Resources {
+		used, err := measure(db, resource, userID)
+		if err != nil {
+			return nil, err
+		}
+		usage := Usage{Resource: resource, Unit: "items", Used: used, Limit: Limit(cfg, resource)}
+		if resource == ResourceStorage {
+			usage.Unit = "bytes"
+		}
+		if usage.Limit > 0 {
+			usage.Percent = math.Round(float64(used)*1000/float64(usage.Limit)) / 10
+			for _, threshold := range thresholds {
+				if usage.Percent >= float64(threshold) {
+					usage.Threshold = threshold
+				}
+			}
+		}
+		result = append(result, usage)
+	}
+	return result, nil
+}
+
+func measure(db *gorm.DB, resource string, userID uint) (int64, error) {
+	var used int64
+	var err error
+	switch resource {
+	case ResourceStorage:
+		// Files of courses in the trash still take space until they are purged
+		err = db.Model(&models.LessonAttachment{}).Select("COALESCE(SUM(lesson_attachments.size), 0)").
+			Joins("JOIN lessons ON lessons.id = lesson_attachments.lesson_id").
+			Joins("JOIN courses ON courses.id = lessons.course_id").
+			Where("courses.author_id = ?", userID).
+			Scan(&used).Error
+	case ResourceCourses:
+		err = db.Model(&models.Course{}).Where("author_id = ?", userID).Count(&used).Error
+	case ResourceQuestions:
+		err = db.Model(&models.TestQuestion{}).
+			Joins("JOIN tests ON tests.id = test_questions.test_id AND tests.deleted_at IS NULL").
+			Where("tests.author_id = ?", userID).
+			Count(&used).Error
+	}
+	return used, err
+}
+
+// Check сверяет использование автора с порогами и ставит в очередь письмо о каждом лимите, пересекшем новый
+// порог; если пройдено сразу несколько порогов, письмо одно, по старшему. Когда использование опускается
+// ниже порога, отметка о нем снимается, и следующее пересечение предупредит снова.
+// Вызывается в транзакции: отметка о пороге без поставленного в очередь письма подавила бы предупреждение.
+func Check(tx *gorm.DB, cfg *config.Config, userID uint) error {
+	usage, err := Current(tx, cfg, userID)
+	if err != nil {
+		return err
+	}
+
+	thresholds := Thresholds(cfg)
+	for _, u := range usage {
+		var sent []models.QuotaWarning
+		if err := tx.Where("user_id = ? AND resource = ?", userID, u.Resource).Find(&sent).Error; err != nil {
+			return err
+		}
+		warned := map[int]bool{}
+		for _, warning := range sent {
+			if u.Limit == 0 || float64(warning.Threshold) > u.Percent {
+				// Hard delete: the (user, resource, threshold) triple is unique
+				if err := tx.Unscoped().Delete(&warning).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			warned[warning.Threshold] = true
+		}
+		if u.Limit == 0 {
+			continue
+		}
+
+		reached := 0
+		for _, threshold := range thresholds {
+			if float64(threshold) > u.Percent || warned[threshold] {
+				continue
+			}
+			warning := models.QuotaWarning{UserID: userID, Resource: u.Resource, Threshold: threshold, Used: u.Used, Limit: u.Limit}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&warning)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				reached = threshold
+			}
+		}
+		if reached > 0 {
+			subject, body := message(u, reached)
+			if err := outbox.EnqueueNotification(tx, userID, outbox.NotifyLimits, subject, body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// message — тема и текст предупреждения о лимите
+func message(u Usage, threshold int) (string, string) {
+	var what, amount string
+	switch u.Resource {
+	case ResourceStorage:
+		what = "storage for lesson files"
+		amount = formatBytes(u.Used) + " of " + formatBytes(u.Limit)
+	case ResourceCourses:
+		what = "course limit"
+		amount = fmt.Sprintf("%d of %d courses", u.Used, u.Limit)
+	case ResourceQuestions:
+		what = "question bank limit"
+		amount = fmt.Sprintf("%d of %d questions", u.Used, u.Limit)
+	}
+	subject := fmt.Sprintf("You have used %d%% of your %s", threshold, what)
+	body := fmt.Sprintf("You are using %s (%.1f%%). Nothing is blocked yet, but consider cleaning up "+
+		"what you no longer need or contact the administrators to raise the limit.", amount, u.Percent)
+	return subject, body
+}
+
+// formatBytes выводит размер в двоичных единицах: 512 B, 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for value := n / unit; value >= unit; value /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,179 @@
+package rankings
+
+import (
+	"project/backend/jobs"
+	"project/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Position — место пользователя в рейтинге теста
+type Position struct {
+	Rank         int
+	PreviousRank *int
+	Total        int64
+}
+
+// Delta — на сколько мест пользователь поднялся (положительное) или опустился (отрицательное)
+// при последнем изменении рейтинга
+func (p Position) Delta() int {
+	if p.PreviousRank == nil {
+		return 0
+	}
+	return *p.PreviousRank - p.Rank
+}
+
+// Entry — строка таблицы лидеров
+type Entry struct {
+	UserID       uint
+	Username     string
+	Score        float64
+	Rank         int
+	PreviousRank *int
+}
+
+// RefreshTest полностью пересчитывает рейтинг одного теста. Места считаются RANK() по последнему
+// результату — тому же баллу, что пользователь видит в своем прогрессе, — поэтому одинаковые баллы
+// делят место. Обновляются только строки, у которых изменились место или балл; previous_rank сохраняет
+// место до изменения. Нужен, когда меняются результаты сразу нескольких пользователей (слияние аккаунтов);
+// после попытки одного пользователя достаточно RecordScore.
+func RefreshTest(tx *gorm.DB, testID uint) error {
+	// Concurrent attempts on the same test would otherwise interleave their upserts
+	if err := jobs.LockEntity(tx, "test_rankings", testID); err != nil {
+		return err
+	}
+
+	if err := tx.Exec(`
+		WITH ranked AS (
+			SELECT user_id, score, RANK() OVER (ORDER BY score DESC) AS rank
+			FROM user_test_progress
+			WHERE test_id = ? AND deleted_at IS NULL
+		)
+		INSERT INTO test_rankings (test_id, user_id, score, rank, created_at, updated_at)
+		SELECT ?, user_id, score, rank, NOW(), NOW() FROM ranked
+		ON CONFLICT (test_id, user_id) DO UPDATE SET
+			previous_rank = CASE WHEN test_rankings.rank IS DISTINCT FROM EXCLUDED.rank
+				THEN test_rankings.rank ELSE test_rankings.previous_rank END,
+			rank = EXCLUDED.rank,
+			score = EXCLUDED.score,
+			updated_at = NOW(),
+			deleted_at = NULL
+		WHERE test_rankings.rank IS DISTINCT FROM EXCLUDED.rank
+			OR test_rankings.score IS DISTINCT FROM EXCLUDED.score
+			OR test_rankings.deleted_at IS NOT NULL`,
+		testID, testID).Error; err != nil {
+		return err
+	}
+
+	// Progress rows that disappeared (deleted accounts, reset attempts) leave the ranking
+	return tx.Exec(`DELETE FROM test_rankings WHERE test_id = ? AND user_id NOT IN (
+		SELECT user_id FROM user_test_progress WHERE test_id = ? AND deleted_at IS NULL)`,
+		testID, testID).Error
+}
+
+// RecordScore обновляет рейтинг после попытки одного пользователя, не пересчитывая весь тест:
+// сдвигаются на одно место только те, чей балл лежит между старым и новым баллом пользователя.
+// Места те же, что дал бы RefreshTest. Вызывается в транзакции сохранения попытки.
+func RecordScore(tx *gorm.DB, testID, userID uint) error {
+	if err := jobs.LockEntity(tx, "test_rankings", testID); err != nil {
+		return err
+	}
+
+	var scores []float64
+	if err := tx.Model(&models.UserTestProgress{}).Where("test_id = ? AND user_id = ?", testID, userID).
+		Limit(1).Pluck("score", &scores).Error; err != nil {
+		return err
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+	score := scores[0]
+
+	var current []models.TestRanking
+	if err := tx.Where("test_id = ? AND user_id = ?", testID, userID).Limit(1).Find(&current).Error; err != nil {
+		return err
+	}
+
+	// Everyone the user passed moves down a place, everyone who passed the user moves up;
+	// equal scores share a place, so the ranges are half-open
+	shift := func(by int, from, to float64) error {
+		return tx.Exec(`UPDATE test_rankings SET previous_rank = rank, rank = rank + ?, updated_at = NOW()
+			WHERE test_id = ? AND user_id <> ? AND deleted_at IS NULL AND score >= ? AND score < ?`,
+			by, testID, userID, from, to).Error
+	}
+	switch {
+	case len(current) == 0:
+		if err := tx.Exec(`UPDATE test_rankings SET previous_rank = rank, rank = rank + 1, updated_at = NOW()
+			WHERE test_id = ? AND user_id <> ? AND deleted_at IS NULL AND score < ?`,
+			testID, userID, score).Error; err != nil {
+			return err
+		}
+	case current[0].Score == score:
+		return nil
+	case current[0].Score < score:
+		if err := shift(1, current[0].Score, score); err != nil {
+			return err
+		}
+	default:
+		if err := shift(-1, score, current[0].Score); err != nil {
+			return err
+		}
+	}
+
+	var higher int64
+	if err := tx.Model(&models.TestRanking{}).
+		Where("test_id = ? AND user_id <> ? AND score > ?", testID, userID, score).
+		Count(&higher).Error; err != nil {
+		return err
+	}
+
+	// A soft-deleted row is a fresh entry, its old place is no longer meaningful
+	return tx.Exec(`
+		INSERT INTO test_rankings (test_id, user_id, score, rank, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(), NOW())
+		ON CONFLICT (test_id, user_id) DO UPDATE SET
+			previous_rank = CASE WHEN test_rankings.deleted_at IS NOT NULL THEN NULL
+				WHEN test_rankings.rank IS DISTINCT FROM EXCLUDED.rank THEN test_rankings.rank
+				ELSE test_rankings.previous_rank END,
+			rank = EXCLUDED.rank,
+			score = EXCLUDED.score,
+			updated_at = NOW(),
+			deleted_at = NULL`,
+		testID, userID, score, int(higher)+1).Error
+}
+
+// ForUser возвращает место пользователя в рейтинге теста; nil, если он еще не проходил тест
+func ForUser(db *gorm.DB, testID, userID uint) (*Position, error) {
+	var ranking []struct {
+		Rank         int
+		PreviousRank *int
+	}
+	if err := db.Table("test_rankings").Select("rank, previous_rank").
+		Where("test_id = ? AND user_id = ? AND deleted_at IS NULL", testID, userID).
+		Limit(1).Scan(&ranking).Error; err != nil {
+		return nil, err
+	}
+	if len(ranking) == 0 {
+		return nil, nil
+	}
+
+	position := &Position{Rank: ranking[0].Rank, PreviousRank: ranking[0].PreviousRank}
+	if err := db.Table("test_rankings").Where("test_id = ? AND deleted_at IS NULL", testID).
+		Count(&position.Total).Error; err != nil {
+		return nil, err
+	}
+	return position, nil
+}
+
+// Top возвращает первые limit мест рейтинга теста
+func Top(db *gorm.DB, testID uint, limit int) ([]Entry, error) {
+	entries := []Entry{}
+	err := db.Table("test_rankings").
+		Select("test_rankings.user_id, users.username, test_rankings.score, test_rankings.rank, test_rankings.previous_rank").
+		Joins("JOIN users ON users.id = test_rankings.user_id AND users.deleted_at IS NULL").
+		Where("test_rankings.test_id = ? AND test_rankings.deleted_at IS NULL", testID).
+		Order("test_rankings.rank, test_rankings.user_id").
+		Limit(limit).
+		Scan(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,112 @@
+package readonly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"project/backend/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Откуда включен режим только для чтения
+const (
+	SourceSchema = "schema" // the database schema doesn't match this build
+	SourceConfig = "config" // READ_ONLY environment variable
+	SourceAdmin  = "admin"  // platform setting toggled by an administrator
+)
+
+// ErrForced — режим включен при запуске, и снять его можно только перезапуском
+var ErrForced = errors.New("read-only mode is set by the deployment and can't be changed at runtime")
+
+// State — текущее состояние режима
+type State struct {
+	Enabled bool       `json:"enabled"`
+	Source  string     `json:"source,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+var (
+	mu      sync.RWMutex
+	forced  *State // set at startup, wins over the platform setting
+	toggled State  // last known platform setting
+)
+
+// Force включает режим на этом экземпляре до перезапуска
+func Force(source, message string) {
+	now := time.Now()
+	mu.Lock()
+	forced = &State{Enabled: true, Source: source, Message: message, Since: &now}
+	mu.Unlock()
+}
+
+// Current возвращает действующее состояние
+func Current() State {
+	mu.RLock()
+	defer mu.RUnlock()
+	if forced != nil {
+		return *forced
+	}
+	return toggled
+}
+
+// Refresh перечитывает настройку платформы; вызывается периодически на каждом экземпляре
+func Refresh(ctx context.Context, db *gorm.DB) error {
+	var setting models.PlatformSetting
+	err := db.WithContext(ctx).Where("key = ?", models.SettingReadOnly).Limit(1).Find(&setting).Error
+	if err != nil {
+		return err
+	}
+
+	state := State{}
+	if setting.ID != 0 {
+		if err := json.Unmarshal([]byte(setting.Value), &state); err != nil {
+			return err
+		}
+	}
+	if state.Enabled {
+		state.Source = SourceAdmin
+	}
+
+	mu.Lock()
+	toggled = state
+	mu.Unlock()
+	return nil
+}
+
+// Set сохраняет настройку платформы; остальные экземпляры подхватят ее при следующем Refresh
+func Set(ctx context.Context, db *gorm.DB, enabled bool, message string, userID uint) (State, error) {
+	mu.RLock()
+	locked := forced != nil
+	mu.RUnlock()
+	if locked {
+		return Current(), ErrForced
+	}
+
+	state := State{}
+	if enabled {
+		now := time.Now()
+		state = State{Enabled: true, Source: SourceAdmin, Message: message, Since: &now}
+	}
+	value, err := json.Marshal(state)
+	if err != nil {
+		return state, err
+	}
+
+	err = db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+	}).Create(&models.PlatformSetting{Key: models.SettingReadOnly, Value: string(value), UpdatedBy: userID}).Error
+	if err != nil {
+		return state, err
+	}
+
+	mu.Lock()
+	toggled = state
+	mu.Unlock()
+	return state, nil
+}
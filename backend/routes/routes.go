@@ -3,7 +3,10 @@ package routes
 import (
 	"project/backend/config"
 	"project/backend/controllers"
+	"project/backend/deprecation"
 	"project/backend/middleware"
+	"project/backend/models"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -15,9 +18,22 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 	app.Post("/api/auth/register", authController.Register)
 	app.Post("/api/auth/login", authController.Login)
 
+	// Public status page: component health and incident notes for the status banner
+	statusController := controllers.NewStatusController(db, cfg)
+	app.Get("/api/public/status", statusController.GetStatus)
+
+	// Public verification of course certificates by their number
+	app.Get("/api/certificates/:code", controllers.NewCertificatesController(db, cfg).VerifyCertificate)
+
+	// Payment provider notifications, authenticated by the provider's signature
+	ordersController := controllers.NewOrdersController(db, cfg)
+	app.Post("/api/payments/webhook", ordersController.PaymentWebhook)
+
 	// Middleware
-	authMiddleware := middleware.AuthMiddleware(cfg)
-	adminMiddleware := middleware.AdminMiddleware(cfg)
+	authMiddleware := middleware.AuthMiddleware(db, cfg)
+	requirePermission := func(codes ...string) fiber.Handler {
+		return middleware.PermissionMiddleware(db, cfg, codes...)
+	}
 
 	// Progress routes
 	progressController := controllers.NewProgressController(db, cfg)
@@ -27,66 +43,551 @@ func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 	// Courses routes
 	coursesController := controllers.NewCoursesController(db, cfg)
 	courses := app.Group("/api/courses", authMiddleware)
-	courses.Get("/", coursesController.GetUserCourses)
+	// Superseded by /api/user/courses with the response envelope and pagination
+	courses.Get("/", middleware.Deprecated("GET /api/courses/", deprecation.Notice{
+		Since:     time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2027, 4, 16, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/user/courses",
+	}), coursesController.GetUserCourses)
 	courses.Get("/available", coursesController.GetAvailableCourses)
 	courses.Get("/:id", coursesController.GetCourseDetails)
 	courses.Post("/:id/progress", coursesController.UpdateCourseProgress)
-	courses.Get("/:id/analytics", adminMiddleware, coursesController.GetCourseAnalytics)
+	// Paid courses: checkout at the payment provider, enrollment comes with the webhook
+	courses.Post("/:id/checkout", ordersController.CreateCheckout)
+	app.Get("/api/orders", authMiddleware, ordersController.GetOrders)
+	app.Get("/api/orders/:id", authMiddleware, ordersController.GetOrder)
+	// Superseded by /analytics/students with the response envelope and cursor pagination
+	courses.Get("/:id/analytics", middleware.Deprecated("GET /api/courses/:id/analytics", deprecation.Notice{
+		Since:     time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2027, 4, 16, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/courses/:id/analytics/students",
+	}), requirePermission(models.PermAnalyticsView), coursesController.GetCourseAnalytics)
+	courses.Get("/:id/analytics/students", requirePermission(models.PermAnalyticsView), coursesController.GetCourseAnalyticsPage)
 
 	// Tests routes
 	testsController := controllers.NewTestsController(db, cfg)
 	tests := app.Group("/api/tests", authMiddleware)
-	tests.Get("/", testsController.GetUserTests)
+	// Superseded by /api/user/tests with the response envelope and pagination
+	tests.Get("/", middleware.Deprecated("GET /api/tests/", deprecation.Notice{
+		Since:     time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2027, 4, 16, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/user/tests",
+	}), testsController.GetUserTests)
 	tests.Get("/available", testsController.GetAvailableTests)
 	tests.Get("/:id", testsController.GetTestDetails)
 	tests.Post("/:id/progress", testsController.UpdateTestProgress)
-	tests.Get("/:id/analytics", adminMiddleware, testsController.GetTestAnalytics)
+	// Superseded by /analytics/students with the response envelope and cursor pagination
+	tests.Get("/:id/analytics", middleware.Deprecated("GET /api/tests/:id/analytics", deprecation.Notice{
+		Since:     time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2027, 4, 16, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/tests/:id/analytics/students",
+	}), requirePermission(models.PermAnalyticsView), testsController.GetTestAnalytics)
+	tests.Get("/:id/analytics/students", requirePermission(models.PermAnalyticsView), testsController.GetTestAnalyticsPage)
 	tests.Get("/:id/result", testsController.GetTestResult)
+	tests.Get("/:id/leaderboard", testsController.GetTestLeaderboard)
+	tests.Get("/:id/live", testsController.StreamTestActivity)
+
+	// Questions served one at a time; answers to timed questions are accepted only within their limit
+	questionTimingController := controllers.NewQuestionTimingController(db, cfg)
+	tests.Post("/:id/questions/:questionId/serve", questionTimingController.ServeQuestion)
+	tests.Post("/:id/questions/:questionId/answer", questionTimingController.AnswerQuestion)
 
 	// Admin routes for courses
-	adminCourses := app.Group("/api/admin/courses", authMiddleware, adminMiddleware)
-	adminCourses.Post("/", coursesController.CreateCourse)
-	adminCourses.Put("/:id/description", coursesController.UpdateCourseDescription)
-	adminCourses.Post("/:id/lessons", coursesController.AddLesson)
-	adminCourses.Put("/:id/lessons/:lessonId", coursesController.UpdateLesson)
-	adminCourses.Get("/:id/comments", coursesController.GetCourseComments)
-	adminCourses.Put("/:id/settings", coursesController.UpdateCourseSettings)
+	adminCourses := app.Group("/api/admin/courses", authMiddleware)
+	adminCourses.Post("/", requirePermission(models.PermCoursesCreate), coursesController.CreateCourse)
+	adminCourses.Put("/:id/description", requirePermission(models.PermCoursesEdit), coursesController.UpdateCourseDescription)
+	adminCourses.Patch("/:id/description", requirePermission(models.PermCoursesEdit), coursesController.UpdateCourseDescription)
+	adminCourses.Post("/:id/lessons", requirePermission(models.PermCoursesEdit), coursesController.AddLesson)
+	adminCourses.Put("/:id/lessons/:lessonId", requirePermission(models.PermCoursesEdit), coursesController.UpdateLesson)
+	adminCourses.Patch("/:id/lessons/:lessonId", requirePermission(models.PermCoursesEdit), coursesController.UpdateLesson)
+	adminCourses.Delete("/:id/lessons/:lessonId", requirePermission(models.PermCoursesEdit), coursesController.DeleteLesson)
+
+	// Lesson content as ordered typed blocks (text, image, video, embed, code, quiz)
+	lessonBlocksController := controllers.NewLessonBlocksController(db, cfg)
+	adminCourses.Get("/:id/lessons/:lessonId/blocks", requirePermission(models.PermCoursesEdit), lessonBlocksController.GetBlocks)
+	adminCourses.Post("/:id/lessons/:lessonId/blocks", requirePermission(models.PermCoursesEdit), lessonBlocksController.CreateBlock)
+	adminCourses.Put("/:id/lessons/:lessonId/blocks/order", requirePermission(models.PermCoursesEdit), lessonBlocksController.ReorderBlocks)
+	adminCourses.Put("/:id/lessons/:lessonId/blocks/:blockId", requirePermission(models.PermCoursesEdit), lessonBlocksController.UpdateBlock)
+	adminCourses.Patch("/:id/lessons/:lessonId/blocks/:blockId", requirePermission(models.PermCoursesEdit), lessonBlocksController.UpdateBlock)
+	adminCourses.Delete("/:id/lessons/:lessonId/blocks/:blockId", requirePermission(models.PermCoursesEdit), lessonBlocksController.DeleteBlock)
+	courses.Get("/:id/lessons/:lessonId/blocks", lessonBlocksController.GetLessonBlocks)
+
+	// Lesson drafts: autosaved edits kept apart from the live lesson until the author publishes them
+	lessonDraftsController := controllers.NewLessonDraftsController(db, cfg)
+	adminCourses.Get("/:id/lessons/:lessonId/draft", requirePermission(models.PermCoursesEdit), lessonDraftsController.GetDraft)
+	adminCourses.Put("/:id/lessons/:lessonId/draft", requirePermission(models.PermCoursesEdit), lessonDraftsController.SaveDraft)
+	adminCourses.Patch("/:id/lessons/:lessonId/draft", requirePermission(models.PermCoursesEdit), lessonDraftsController.SaveDraft)
+	adminCourses.Delete("/:id/lessons/:lessonId/draft", requirePermission(models.PermCoursesEdit), lessonDraftsController.DiscardDraft)
+	adminCourses.Post("/:id/lessons/:lessonId/draft/publish", requirePermission(models.PermCoursesEdit), lessonDraftsController.PublishDraft)
+
+	// Files attached to lessons (PDFs, slides, datasets), downloaded through the API
+	attachmentsController := controllers.NewLessonAttachmentsController(db, cfg)
+	middleware.RaiseBodyLimit(fiber.MethodPost, "/api/admin/courses/:id/lessons/:lessonId/attachments", cfg.AttachmentMaxBytes)
+	adminCourses.Post("/:id/lessons/:lessonId/attachments", requirePermission(models.PermCoursesEdit), attachmentsController.UploadAttachment)
+	adminCourses.Delete("/:id/lessons/:lessonId/attachments/:attachmentId", requirePermission(models.PermCoursesEdit), attachmentsController.DeleteAttachment)
+	courses.Get("/:id/lessons/:lessonId/attachments", attachmentsController.GetAttachments)
+	courses.Get("/:id/lessons/:lessonId/attachments/:attachmentId", attachmentsController.DownloadAttachment)
+	adminCourses.Delete("/:id", requirePermission(models.PermCoursesEdit), coursesController.DeleteCourse)
+	adminCourses.Get("/:id/comments", requirePermission(models.PermCoursesEdit), coursesController.GetCourseComments)
+
+	// Course sections grouping lessons
+	modulesController := controllers.NewModulesController(db, cfg)
+	adminCourses.Get("/:id/modules", requirePermission(models.PermCoursesEdit), modulesController.GetModules)
+	adminCourses.Post("/:id/modules", requirePermission(models.PermCoursesEdit), modulesController.CreateModule)
+	adminCourses.Put("/:id/modules/:moduleId", requirePermission(models.PermCoursesEdit), modulesController.UpdateModule)
+	adminCourses.Patch("/:id/modules/:moduleId", requirePermission(models.PermCoursesEdit), modulesController.UpdateModule)
+	adminCourses.Delete("/:id/modules/:moduleId", requirePermission(models.PermCoursesEdit), modulesController.DeleteModule)
+	adminCourses.Put("/:id/settings", requirePermission(models.PermCoursesEdit), coursesController.UpdateCourseSettings)
+
+	// Who may open and enroll in a restricted course: users, emails and study groups
+	accessListController := controllers.NewAccessListController(db, cfg)
+	adminCourses.Get("/:id/access-list", requirePermission(models.PermCoursesEdit), accessListController.GetAccessList)
+	adminCourses.Post("/:id/access-list", requirePermission(models.PermCoursesEdit), accessListController.AddAccessListEntry)
+	adminCourses.Delete("/:id/access-list/:entryId", requirePermission(models.PermCoursesEdit), accessListController.RemoveAccessListEntry)
+
+	// Lifecycle: draft -> review -> published -> archived; only published courses are listed in the catalog
+	adminCourses.Post("/:id/submit", requirePermission(models.PermCoursesEdit), coursesController.SubmitCourse)
+	adminCourses.Post("/:id/publish", requirePermission(models.PermCoursesEdit), coursesController.PublishCourse)
+	adminCourses.Post("/:id/reject", requirePermission(models.PermCoursesEdit), coursesController.RejectCourse)
+	adminCourses.Post("/:id/unpublish", requirePermission(models.PermCoursesEdit), coursesController.UnpublishCourse)
+	adminCourses.Post("/:id/archive", requirePermission(models.PermCoursesEdit), coursesController.ArchiveCourse)
+	adminCourses.Post("/:id/restore", requirePermission(models.PermCoursesEdit), coursesController.RestoreCourse)
+
+	// Versions are snapshots taken on every publish; lesson content can be rolled back to any of them
+	versionsController := controllers.NewVersionsController(db, cfg)
+	courses.Get("/:id/versions", versionsController.GetCourseVersions)
+	courses.Get("/:id/versions/:number", versionsController.GetCourseVersion)
+	adminCourses.Post("/:id/versions/:number/rollback", requirePermission(models.PermCoursesEdit), versionsController.RollbackLessons)
+
+	// Shared marketplace: courses submitted by organizations, reviewed by platform admins and imported as drafts
+	marketplaceController := controllers.NewMarketplaceController(db, cfg)
+	adminCourses.Post("/:id/marketplace", requirePermission(models.PermCoursesEdit), marketplaceController.SubmitListing)
+	adminCourses.Delete("/:id/marketplace", requirePermission(models.PermCoursesEdit), marketplaceController.WithdrawListing)
+	app.Get("/api/marketplace", authMiddleware, marketplaceController.GetListings)
+	app.Get("/api/marketplace/:id", authMiddleware, marketplaceController.GetListing)
+	app.Post("/api/marketplace/:id/import", authMiddleware, requirePermission(models.PermCoursesCreate), marketplaceController.ImportListing)
+	reviewMarketplace := requirePermission(models.PermMarketplaceReview)
+	app.Get("/api/admin/marketplace", authMiddleware, reviewMarketplace, marketplaceController.GetReviewQueue)
+	app.Post("/api/admin/marketplace/:id/approve", authMiddleware, reviewMarketplace, marketplaceController.ApproveListing)
+	app.Post("/api/admin/marketplace/:id/reject", authMiddleware, reviewMarketplace, marketplaceController.RejectListing)
+
+	// A copy of the course without student data, used as a template for the next semester
+	adminCourses.Post("/:id/clone", requirePermission(models.PermCoursesCreate), coursesController.CloneCourse)
+
+	// Portable course bundles for backups and moving courses between instances
+	bundlesController := controllers.NewBundlesController(db, cfg)
+	adminCourses.Get("/:id/bundle", bundlesController.ExportCourse)
+	middleware.RaiseBodyLimit(fiber.MethodPost, "/api/admin/courses/import", controllers.MaxBundleBytes)
+	middleware.RaiseBodyLimit(fiber.MethodPost, "/api/admin/courses/import/package", cfg.PackageMaxBytes)
+	adminCourses.Post("/import", requirePermission(models.PermCoursesCreate), bundlesController.ImportCourse)
+	// Courses migrated from other LMSs as SCORM 1.2/2004 or xAPI packages
+	adminCourses.Post("/import/package", requirePermission(models.PermCoursesCreate), bundlesController.ImportPackage)
+
+	// Course co-authors and staff: editors manage the course with its author, teaching assistants grade,
+	// answer comments and view analytics, viewers only look; rights are checked by the policy engine
+	staffController := controllers.NewStaffController(db, cfg)
+	courses.Get("/:id/staff", staffController.GetCourseStaff)
+	courses.Post("/:id/staff", staffController.AddCourseStaff)
+	courses.Delete("/:id/staff/:userId", staffController.RemoveCourseStaff)
+
+	// End-of-course survey and certificates; authors can require the survey before the certificate is issued
+	certificatesController := controllers.NewCertificatesController(db, cfg)
+	courses.Get("/:id/survey", certificatesController.GetCourseSurvey)
+	courses.Post("/:id/survey", certificatesController.SubmitCourseSurvey)
+	courses.Get("/:id/certificate", certificatesController.GetCourseCertificate)
+	adminCourses.Put("/:id/survey", requirePermission(models.PermCoursesEdit), certificatesController.UpdateCourseSurvey)
+
+	// Course rules students acknowledge before the first lesson or graded test; the log is kept for exam disputes
+	conductController := controllers.NewConductController(db, cfg)
+	courses.Get("/:id/conduct", conductController.GetCourseConduct)
+	courses.Post("/:id/conduct/acknowledge", conductController.AcknowledgeCourseConduct)
+	courses.Get("/:id/conduct/acknowledgements", conductController.GetConductAcknowledgements)
+	adminCourses.Put("/:id/conduct", requirePermission(models.PermCoursesEdit), conductController.UpdateCourseConduct)
+
+	// Announcements from the course staff; enrolled students are notified by email unless they opted out
+	announcementsController := controllers.NewAnnouncementsController(db, cfg)
+	courses.Get("/:id/announcements", announcementsController.GetAnnouncements)
+	adminCourses.Post("/:id/announcements", requirePermission(models.PermCoursesEdit), announcementsController.CreateAnnouncement)
+	adminCourses.Delete("/:id/announcements/:announcementId", requirePermission(models.PermCoursesEdit), announcementsController.DeleteAnnouncement)
+
+	// Final course grade: weighted components defined by the author, manual scores entered by course staff
+	gradingController := controllers.NewGradingController(db, cfg)
+	courses.Get("/:id/grading", gradingController.GetCourseGrading)
+	courses.Put("/:id/grading/:componentId/grades/:userId", gradingController.SetGradeEntry)
+	adminCourses.Put("/:id/grading", requirePermission(models.PermCoursesEdit), gradingController.UpdateCourseGrading)
+
+	// Completion rules: share of lessons and an optional final test with a pass mark
+	completionController := controllers.NewCompletionController(db, cfg)
+	courses.Get("/:id/completion-policy", completionController.GetCompletionPolicy)
+	adminCourses.Put("/:id/completion-policy", requirePermission(models.PermCoursesEdit), completionController.UpdateCompletionPolicy)
+
+	// Enrollment with a seat limit: a full course puts students on its waitlist
+	waitlistController := controllers.NewWaitlistController(db, cfg)
+	courses.Post("/:id/enroll", waitlistController.Enroll)
+	courses.Get("/:id/waitlist", waitlistController.GetWaitlist)
+	courses.Delete("/:id/waitlist", waitlistController.LeaveWaitlist)
+
+	// Course covers: uploads with cropping and the stock gallery
+	coversController := controllers.NewCoversController(db, cfg)
+	adminCourses.Post("/:id/cover", requirePermission(models.PermCoursesEdit), coversController.UploadCourseCover)
+	adminCourses.Put("/:id/cover", requirePermission(models.PermCoursesEdit), coversController.SetCourseCover)
+	adminCourses.Delete("/:id/cover", requirePermission(models.PermCoursesEdit), coversController.DeleteCourseCover)
+	app.Get("/api/covers", authMiddleware, coversController.GetStockCovers)
+	manageCovers := requirePermission(models.PermCoversManage)
+	app.Post("/api/admin/covers", authMiddleware, manageCovers, coversController.CreateStockCover)
+	app.Delete("/api/admin/covers/:id", authMiddleware, manageCovers, coversController.DeleteStockCover)
+
+	// Course runs: the same content offered to several study groups with their own dates, rosters and analytics
+	runsController := controllers.NewRunsController(db, cfg)
+	adminCourses.Get("/:id/runs", requirePermission(models.PermCoursesEdit), runsController.GetRuns)
+	adminCourses.Post("/:id/runs", requirePermission(models.PermCoursesEdit), runsController.CreateRun)
+	adminCourses.Put("/:id/runs/:runId", requirePermission(models.PermCoursesEdit), runsController.UpdateRun)
+	adminCourses.Patch("/:id/runs/:runId", requirePermission(models.PermCoursesEdit), runsController.UpdateRun)
+	adminCourses.Delete("/:id/runs/:runId", requirePermission(models.PermCoursesEdit), runsController.DeleteRun)
+	courses.Get("/:id/runs/:runId/roster", runsController.GetRunRoster)
+	courses.Get("/:id/runs/:runId/analytics", runsController.GetRunAnalytics)
+
+	// Accessibility check of lesson content: missing alt text, low contrast, long paragraphs
+	accessibilityController := controllers.NewAccessibilityController(db, cfg)
+	adminCourses.Get("/:id/accessibility", requirePermission(models.PermCoursesEdit), accessibilityController.GetCourseAccessibility)
+	adminCourses.Post("/:id/accessibility/check", requirePermission(models.PermCoursesEdit), accessibilityController.CheckContent)
 
 	// Admin routes for tests
-	adminTests := app.Group("/api/admin/tests", authMiddleware, adminMiddleware)
-	adminTests.Post("/", testsController.CreateTest)
-	adminTests.Put("/:id/description", testsController.UpdateTestDescription)
-	adminTests.Post("/:id/questions", testsController.AddQuestion)
-	adminTests.Put("/:id/questions/:questionId", testsController.UpdateQuestion)
-	adminTests.Get("/:id/comments", testsController.GetTestComments)
-	adminTests.Put("/:id/settings", testsController.UpdateTestSettings)
+	adminTests := app.Group("/api/admin/tests", authMiddleware)
+	adminTests.Post("/", requirePermission(models.PermTestsCreate), testsController.CreateTest)
+	adminTests.Put("/:id/description", requirePermission(models.PermTestsEdit), testsController.UpdateTestDescription)
+	adminTests.Patch("/:id/description", requirePermission(models.PermTestsEdit), testsController.UpdateTestDescription)
+	adminTests.Post("/:id/questions", requirePermission(models.PermTestsEdit), testsController.AddQuestion)
+	adminTests.Put("/:id/questions/:questionId", requirePermission(models.PermTestsEdit), testsController.UpdateQuestion)
+	adminTests.Patch("/:id/questions/:questionId", requirePermission(models.PermTestsEdit), testsController.UpdateQuestion)
+	adminTests.Delete("/:id/questions/:questionId", requirePermission(models.PermTestsEdit), testsController.DeleteQuestion)
+
+	// Wrong answer options suggested by the language model; returned to the author, never saved
+	distractorsController := controllers.NewDistractorsController(db, cfg)
+	adminTests.Post("/:id/distractors", requirePermission(models.PermTestsEdit), distractorsController.SuggestDistractors)
+	adminTests.Post("/:id/questions/:questionId/distractors", requirePermission(models.PermTestsEdit), distractorsController.SuggestDistractors)
+	// Machine translation drafts of questions; students only see them after the author approves
+	translationsController := controllers.NewTranslationsController(db, cfg)
+	adminTests.Get("/:id/translations", requirePermission(models.PermTestsEdit), translationsController.GetTranslations)
+	adminTests.Post("/:id/translations", requirePermission(models.PermTestsEdit), translationsController.TranslateQuestions)
+	adminTests.Put("/:id/translations/:translationId", requirePermission(models.PermTestsEdit), translationsController.UpdateTranslation)
+	adminTests.Patch("/:id/translations/:translationId", requirePermission(models.PermTestsEdit), translationsController.UpdateTranslation)
+	adminTests.Post("/:id/translations/:translationId/approve", requirePermission(models.PermTestsEdit), translationsController.ApproveTranslation)
+	adminTests.Delete("/:id/translations/:translationId", requirePermission(models.PermTestsEdit), translationsController.DeleteTranslation)
+	adminTests.Delete("/:id", requirePermission(models.PermTestsEdit), testsController.DeleteTest)
+
+	// Deleted courses, lessons, tests and questions, restored together with what was deleted with them
+	trashController := controllers.NewTrashController(db, cfg)
+	app.Get("/api/admin/trash/:kind", authMiddleware, trashController.GetTrash)
+	app.Post("/api/admin/trash/:kind/:id/restore", authMiddleware, trashController.RestoreTrash)
+	adminTests.Get("/:id/comments", requirePermission(models.PermTestsEdit), testsController.GetTestComments)
+	adminTests.Put("/:id/settings", requirePermission(models.PermTestsEdit), testsController.UpdateTestSettings)
+
+	// Exam sessions: proctors extend time, invalidate attempts and watch progress without editing the test
+	examSessionsController := controllers.NewExamSessionsController(db, cfg)
+	adminTests.Post("/:id/sessions", requirePermission(models.PermTestsEdit), examSessionsController.CreateExamSession)
+	tests.Get("/:id/sessions", examSessionsController.GetTestExamSessions)
+	tests.Post("/:id/pause", examSessionsController.PauseExamAttempt)
+	tests.Post("/:id/resume", examSessionsController.ResumeExamAttempt)
+	examSessions := app.Group("/api/exam-sessions", authMiddleware)
+	examSessions.Get("/:id/attempts", examSessionsController.GetExamSessionAttempts)
+	examSessions.Post("/:id/attempts/:userId/extend", examSessionsController.ExtendExamTime)
+	examSessions.Post("/:id/attempts/:userId/invalidate", examSessionsController.InvalidateExamAttempt)
+	examSessions.Get("/:id/integrity-report", examSessionsController.GetIntegrityReport)
+	examSessions.Get("/:id/proctors", examSessionsController.GetExamSessionProctors)
+	examSessions.Post("/:id/proctors", examSessionsController.AddExamSessionProctor)
+	examSessions.Delete("/:id/proctors/:userId", examSessionsController.RemoveExamSessionProctor)
+
+	// Answers saved while the attempt runs, so a crash or disconnect doesn't lose work
+	attemptAnswersController := controllers.NewAttemptAnswersController(db, cfg)
+	tests.Get("/:id/attempts/active", attemptAnswersController.GetActiveAttempt)
+	tests.Get("/:id/attempts/:attemptId/answers", attemptAnswersController.GetSavedAnswers)
+	tests.Put("/:id/attempts/:attemptId/answers/:questionId", attemptAnswersController.SaveAnswer)
+
+	// Admin routes for roles and permissions
+	rolesController := controllers.NewRolesController(db, cfg)
+	manageRoles := requirePermission(models.PermRolesManage)
+	app.Get("/api/admin/roles", authMiddleware, manageRoles, rolesController.GetRoles)
+	app.Post("/api/admin/roles", authMiddleware, manageRoles, rolesController.CreateRole)
+	app.Put("/api/admin/roles/:id/permissions", authMiddleware, manageRoles, rolesController.UpdateRolePermissions)
+	app.Get("/api/admin/permissions", authMiddleware, manageRoles, rolesController.GetPermissions)
+	app.Get("/api/admin/users/:id/roles", authMiddleware, manageRoles, rolesController.GetUserRoles)
+	app.Post("/api/admin/users/:id/roles", authMiddleware, manageRoles, rolesController.AssignRole)
+	app.Delete("/api/admin/users/:id/roles/:roleId", authMiddleware, manageRoles, rolesController.RevokeRole)
+
+	// Admin routes for account status
+	adminUsersController := controllers.NewAdminUsersController(db, cfg)
+	manageUsers := requirePermission(models.PermUsersManage)
+	app.Get("/api/admin/users", authMiddleware, manageUsers, adminUsersController.GetUsers)
+	app.Post("/api/admin/users/:id/deactivate", authMiddleware, manageUsers, adminUsersController.DeactivateUser)
+	app.Post("/api/admin/users/:id/activate", authMiddleware, manageUsers, adminUsersController.ActivateUser)
+	app.Post("/api/admin/users/:id/ban", authMiddleware, manageUsers, adminUsersController.BanUser)
+	app.Delete("/api/admin/users/:id/ban", authMiddleware, manageUsers, adminUsersController.UnbanUser)
+	app.Post("/api/admin/users/:id/merge", authMiddleware, manageUsers, adminUsersController.MergeUser)
+
+	// Legal holds block deletion, anonymization and retention purges of users and content
+	legalHoldsController := controllers.NewLegalHoldsController(db, cfg)
+	manageLegalHolds := requirePermission(models.PermLegalHoldManage)
+	app.Get("/api/admin/legal-holds", authMiddleware, manageLegalHolds, legalHoldsController.GetLegalHolds)
+	app.Post("/api/admin/legal-holds", authMiddleware, manageLegalHolds, legalHoldsController.CreateLegalHold)
+	app.Post("/api/admin/legal-holds/:id/release", authMiddleware, manageLegalHolds, legalHoldsController.ReleaseLegalHold)
+
+	// Admin routes for status incident notes
+	manageStatus := requirePermission(models.PermStatusManage)
+	app.Get("/api/admin/status/incidents", authMiddleware, manageStatus, statusController.GetIncidents)
+	app.Post("/api/admin/status/incidents", authMiddleware, manageStatus, statusController.CreateIncident)
+	app.Put("/api/admin/status/incidents/:id", authMiddleware, manageStatus, statusController.UpdateIncident)
+	app.Patch("/api/admin/status/incidents/:id", authMiddleware, manageStatus, statusController.UpdateIncident)
+	app.Delete("/api/admin/status/incidents/:id", authMiddleware, manageStatus, statusController.DeleteIncident)
+
+	// Read-only mode for the whole platform (migrations, restores, incident response)
+	managePlatform := requirePermission(models.PermPlatformManage)
+	app.Get("/api/admin/platform/read-only", authMiddleware, managePlatform, statusController.GetReadOnly)
+	app.Put("/api/admin/platform/read-only", authMiddleware, managePlatform, statusController.UpdateReadOnly)
+
+	// Developer sandbox: demo data for integrators, wiped and recreated every night
+	if cfg.Sandbox {
+		sandboxController := controllers.NewSandboxController(db, cfg)
+		app.Get("/api/public/sandbox", sandboxController.GetSandbox)
+		app.Post("/api/admin/sandbox/reset", authMiddleware, managePlatform, sandboxController.ResetSandbox)
+	}
+
+	// Deprecated routes still in use, by client, to know who has to move before the sunset
+	deprecationsController := controllers.NewDeprecationsController(db, cfg)
+	app.Get("/api/admin/deprecations", authMiddleware, managePlatform, deprecationsController.GetDeprecationReport)
+
+	// Re-engagement nudges sent by the scheduler from the activity feed, with per-campaign results
+	campaignsController := controllers.NewCampaignsController(db, cfg)
+	app.Get("/api/admin/campaigns", authMiddleware, managePlatform, campaignsController.GetCampaigns)
+	app.Post("/api/admin/campaigns", authMiddleware, managePlatform, campaignsController.CreateCampaign)
+	app.Put("/api/admin/campaigns/:id", authMiddleware, managePlatform, campaignsController.UpdateCampaign)
+	app.Patch("/api/admin/campaigns/:id", authMiddleware, managePlatform, campaignsController.UpdateCampaign)
+	app.Delete("/api/admin/campaigns/:id", authMiddleware, managePlatform, campaignsController.DeleteCampaign)
+	app.Get("/api/admin/campaigns/:id/stats", authMiddleware, managePlatform, campaignsController.GetCampaignStats)
+
+	// Requests per API consumer and route: top consumers, error rates and abusive patterns for rate-limit tuning
+	apiUsageController := controllers.NewApiUsageController(db, cfg)
+	app.Get("/api/admin/api-usage", authMiddleware, managePlatform, apiUsageController.GetConsumers)
+	app.Get("/api/admin/api-usage/endpoints", authMiddleware, managePlatform, apiUsageController.GetEndpoints)
+
+	// Registered client apps (web, mobile, third-party) with scoped access tokens
+	oauthController := controllers.NewOAuthController(db, cfg)
+	app.Get("/api/admin/oauth/clients", authMiddleware, managePlatform, oauthController.GetClients)
+	app.Post("/api/admin/oauth/clients", authMiddleware, managePlatform, oauthController.CreateClient)
+	app.Put("/api/admin/oauth/clients/:id", authMiddleware, managePlatform, oauthController.UpdateClient)
+	app.Patch("/api/admin/oauth/clients/:id", authMiddleware, managePlatform, oauthController.UpdateClient)
+	app.Post("/api/admin/oauth/clients/:id/secret", authMiddleware, managePlatform, oauthController.RotateClientSecret)
+	app.Delete("/api/admin/oauth/clients/:id", authMiddleware, managePlatform, oauthController.RevokeClient)
+	app.Post("/api/oauth/authorize", authMiddleware, oauthController.Authorize)
+	app.Post("/api/oauth/token", oauthController.Token)
+
+	// Admin routes for cold storage of inactive users
+	archivesController := controllers.NewArchivesController(db, cfg)
+	app.Post("/api/admin/archives", authMiddleware, manageUsers, archivesController.ArchiveInactiveUsers)
+	app.Get("/api/admin/users/:id/archives", authMiddleware, manageUsers, archivesController.GetUserArchives)
+	app.Post("/api/admin/users/:id/restore", authMiddleware, manageUsers, archivesController.RestoreUser)
+
+	// Admin routes for invitations
+	invitationsController := controllers.NewInvitationsController(db, cfg)
+	inviteUsers := requirePermission(models.PermUsersInvite)
+	app.Get("/api/admin/invitations", authMiddleware, inviteUsers, invitationsController.GetInvitations)
+	app.Post("/api/admin/invitations", authMiddleware, inviteUsers, invitationsController.CreateInvitation)
+
+	// Admin routes for gradebook exports
+	exportsController := controllers.NewExportsController(db, cfg)
+	viewAnalytics := requirePermission(models.PermAnalyticsView)
+	app.Get("/api/admin/courses/:id/export", authMiddleware, viewAnalytics, exportsController.ExportCourseGradebook)
+	app.Get("/api/admin/tests/:id/export", authMiddleware, viewAnalytics, exportsController.ExportTestGradebook)
+	app.Post("/api/admin/exports", authMiddleware, viewAnalytics, exportsController.CreateExportJob)
+	app.Get("/api/admin/exports/:id", authMiddleware, viewAnalytics, exportsController.GetExportJob)
+	app.Get("/api/admin/exports/:id/download", authMiddleware, viewAnalytics, exportsController.DownloadExportJob)
+	// Offboarding archive of a whole university; the permission is checked against the university scope
+	app.Post("/api/admin/universities/:id/export", authMiddleware, exportsController.ExportUniversity)
+	app.Get("/api/exports/:id/download", exportsController.DownloadSignedExport)
+	courses.Get("/:id/gradebook", exportsController.ExportCourseGradebook)
+	courses.Get("/:id/sis-mapping", exportsController.GetSISMapping)
+	courses.Put("/:id/sis-mapping", exportsController.UpdateSISMapping)
+
+	// Nightly SIS synchronization, authenticated with an integration API key
+	app.Get("/api/integrations/courses/:id/grades", authMiddleware, exportsController.PullCourseGrades)
+
+	// Topic taxonomy: catalog browsing and admin management
+	topicsController := controllers.NewTopicsController(db, cfg)
+	app.Get("/api/topics", authMiddleware, topicsController.GetTopicTree)
+	app.Get("/api/topics/:id", authMiddleware, topicsController.GetTopic)
+	manageTopics := requirePermission(models.PermTopicsManage)
+	app.Post("/api/admin/topics", authMiddleware, manageTopics, topicsController.CreateTopic)
+	app.Put("/api/admin/topics/:id", authMiddleware, manageTopics, topicsController.UpdateTopic)
+	app.Patch("/api/admin/topics/:id", authMiddleware, manageTopics, topicsController.UpdateTopic)
+	app.Delete("/api/admin/topics/:id", authMiddleware, manageTopics, topicsController.DeleteTopic)
+
+	// Catalog tags across the topic tree; authors tag their own courses and tests
+	tagsController := controllers.NewTagsController(db, cfg)
+	app.Get("/api/tags", authMiddleware, tagsController.GetTags)
+	app.Post("/api/admin/tags", authMiddleware, manageTopics, tagsController.CreateTag)
+	app.Put("/api/admin/tags/:id", authMiddleware, manageTopics, tagsController.UpdateTag)
+	app.Patch("/api/admin/tags/:id", authMiddleware, manageTopics, tagsController.UpdateTag)
+	app.Delete("/api/admin/tags/:id", authMiddleware, manageTopics, tagsController.DeleteTag)
+	app.Put("/api/admin/courses/:id/tags", authMiddleware, requirePermission(models.PermCoursesEdit), tagsController.SetCourseTags)
+	app.Put("/api/admin/tests/:id/tags", authMiddleware, requirePermission(models.PermTestsEdit), tagsController.SetTestTags)
+
+	// Report of courses with duplicated descriptions or lessons, compared by text embeddings
+	duplicatesController := controllers.NewDuplicatesController(db, cfg)
+	viewPlatform := requirePermission(models.PermPlatformView)
+	app.Get("/api/admin/reports/duplicates", authMiddleware, viewPlatform, duplicatesController.GetDuplicateReport)
+	app.Post("/api/admin/reports/duplicates/refresh", authMiddleware, viewPlatform, duplicatesController.RefreshDuplicateReport)
+
+	// University directory, landing pages and affiliation verification
+	universitiesController := controllers.NewUniversitiesController(db, cfg)
+	app.Get("/api/universities", authMiddleware, universitiesController.GetUniversities)
+	app.Get("/api/universities/:slug", authMiddleware, universitiesController.GetUniversity)
+	manageUniversities := requirePermission(models.PermUniversitiesManage)
+	app.Post("/api/admin/universities", authMiddleware, manageUniversities, universitiesController.CreateUniversity)
+	app.Put("/api/admin/universities/:id", authMiddleware, manageUniversities, universitiesController.UpdateUniversity)
+	app.Patch("/api/admin/universities/:id", authMiddleware, manageUniversities, universitiesController.UpdateUniversity)
+	app.Delete("/api/admin/universities/:id", authMiddleware, manageUniversities, universitiesController.DeleteUniversity)
+	app.Get("/api/admin/universities/:id/grade-scale", authMiddleware, manageUniversities, gradingController.GetGradeScale)
+	app.Put("/api/admin/universities/:id/grade-scale", authMiddleware, manageUniversities, gradingController.UpdateGradeScale)
+
+	// Study groups and their members, so filters don't depend on how a group name was typed
+	groupsController := controllers.NewGroupsController(db, cfg)
+	app.Get("/api/groups", authMiddleware, groupsController.GetGroups)
+	app.Get("/api/groups/:id", authMiddleware, groupsController.GetGroup)
+	app.Post("/api/admin/groups", authMiddleware, manageUniversities, groupsController.CreateGroup)
+	app.Put("/api/admin/groups/:id", authMiddleware, manageUniversities, groupsController.UpdateGroup)
+	app.Patch("/api/admin/groups/:id", authMiddleware, manageUniversities, groupsController.UpdateGroup)
+	app.Delete("/api/admin/groups/:id", authMiddleware, manageUniversities, groupsController.DeleteGroup)
+	app.Get("/api/admin/groups/:id/members", authMiddleware, manageUniversities, groupsController.GetGroupMembers)
+	app.Post("/api/admin/groups/:id/members", authMiddleware, manageUniversities, groupsController.AddGroupMember)
+	app.Delete("/api/admin/groups/:id/members/:userId", authMiddleware, manageUniversities, groupsController.RemoveGroupMember)
 
 	// Comments routes
 	commentsController := controllers.NewCommentsController(db, cfg)
-	comments := app.Group("/api/comments", middleware.AuthMiddleware(cfg))
+	comments := app.Group("/api/comments", authMiddleware)
 	comments.Post("/course/:id", commentsController.AddCourseComment)
-	comments.Get("/course/:id", commentsController.GetCourseComments)
+	// Superseded by /api/courses/:id/comments with the response envelope and cursor pagination
+	comments.Get("/course/:id", middleware.Deprecated("GET /api/comments/course/:id", deprecation.Notice{
+		Since:     time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2027, 4, 16, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/courses/:id/comments",
+	}), commentsController.GetCourseComments)
+	courses.Get("/:id/comments", commentsController.GetCourseCommentsPage)
+	comments.Post("/course/:id/:commentId/replies", commentsController.ReplyToCourseComment)
+
+	// Course reviews: one rating with a review per student, kept apart from the discussion
+	reviewsController := controllers.NewReviewsController(db, cfg)
+	courses.Get("/:id/reviews", reviewsController.GetCourseReviews)
+	courses.Put("/:id/review", reviewsController.SaveCourseReview)
+	courses.Delete("/:id/review", reviewsController.DeleteCourseReview)
 
 	// User routes
 	userController := controllers.NewUserController(db, cfg)
-	user := app.Group("/api/user", middleware.AuthMiddleware(cfg))
+	user := app.Group("/api/user", authMiddleware)
 	user.Get("/profile", userController.GetProfile)
 	user.Put("/profile", userController.UpdateProfile)
+	user.Patch("/profile", userController.UpdateProfile)
+	user.Post("/email/confirm", userController.ConfirmEmailChange)
+	user.Delete("/account", userController.DeleteAccount)
+	user.Post("/avatar", userController.UploadAvatar)
+	user.Delete("/avatar", userController.DeleteAvatar)
 	user.Get("/courses", userController.GetUserCourses)
 	user.Get("/tests", userController.GetUserTests)
 	user.Get("/activity", userController.GetUserActivity)
 
+	// Activity feed for the dashboard: typed events emitted by courses, tests and comments
+	feedController := controllers.NewFeedController(db, cfg)
+	user.Get("/feed", feedController.GetUserFeed)
+	user.Get("/activity/history", userController.GetActivityHistory)
+
+	// Sign-ins with device and location, including failed attempts
+	securityController := controllers.NewSecurityController(db, cfg)
+	user.Get("/security/logins", securityController.GetLogins)
+	user.Get("/search", userController.SearchEnrolledContent)
+	user.Get("/usage", userController.GetUsage)
+	user.Get("/export", exportsController.ExportUserData)
+
+	// Personal preferences read by notifications and analytics
+	settingsController := controllers.NewSettingsController(db, cfg)
+	user.Get("/settings", settingsController.GetSettings)
+	user.Put("/settings", settingsController.UpdateSettings)
+	user.Patch("/settings", settingsController.UpdateSettings)
+	user.Post("/affiliation", universitiesController.RequestAffiliation)
+	user.Post("/affiliation/verify", universitiesController.VerifyAffiliation)
+
+	// Public author pages
+	authorsController := controllers.NewAuthorsController(db, cfg)
+	users := app.Group("/api/users", authMiddleware)
+	users.Get("/:id", userController.GetPublicProfile)
+	users.Get("/:id/profile", authorsController.GetAuthorProfile)
+	users.Post("/:id/follow", authorsController.FollowAuthor)
+	users.Delete("/:id/follow", authorsController.UnfollowAuthor)
+
+	// Mentors follow the progress of students who accepted their request
+	mentorController := controllers.NewMentorController(db, cfg)
+	mentor := app.Group("/api/mentor", authMiddleware, requirePermission(models.PermStudentsMentor))
+	mentor.Get("/students", mentorController.GetStudents)
+	mentor.Post("/students", mentorController.RequestStudent)
+	mentor.Delete("/students/:id", mentorController.RemoveStudent)
+	mentor.Get("/students/:id/progress", mentorController.GetStudentProgress)
+	user.Get("/mentors", mentorController.GetMentors)
+	user.Post("/mentors/:id/accept", mentorController.AcceptMentor)
+	user.Delete("/mentors/:id", mentorController.RemoveMentor)
+
+	// Study circles: small groups of students on one course, joined by code, with a board of shared progress
+	circlesController := controllers.NewStudyCirclesController(db, cfg)
+	courses.Post("/:id/circles", circlesController.CreateCircle)
+	user.Get("/circles", circlesController.GetMyCircles)
+	circles := app.Group("/api/circles", authMiddleware)
+	circles.Post("/join", circlesController.JoinCircle)
+	circles.Get("/:id", circlesController.GetCircle)
+	circles.Put("/:id", circlesController.UpdateCircle)
+	circles.Patch("/:id", circlesController.UpdateCircle)
+	circles.Delete("/:id", circlesController.DeleteCircle)
+	circles.Post("/:id/code", circlesController.RotateCircleCode)
+	circles.Put("/:id/membership", circlesController.UpdateMembership)
+	circles.Patch("/:id/membership", circlesController.UpdateMembership)
+	circles.Delete("/:id/members/:userId", circlesController.RemoveCircleMember)
+	circles.Get("/:id/board", circlesController.GetCircleBoard)
+
+	// Circle challenges: time-boxed goals tracked from completed lessons, with a leaderboard and badges
+	challengesController := controllers.NewCircleChallengesController(db, cfg)
+	user.Get("/badges", challengesController.GetMyBadges)
+	circles.Get("/:id/challenges", challengesController.GetChallenges)
+	circles.Post("/:id/challenges", challengesController.CreateChallenge)
+	circles.Get("/:id/challenges/:challengeId", challengesController.GetChallenge)
+	circles.Delete("/:id/challenges/:challengeId", challengesController.DeleteChallenge)
+
+	// Everything the dashboard needs on startup in one round trip
+	bootstrapController := controllers.NewBootstrapController(db, cfg)
+	user.Get("/bootstrap", bootstrapController.GetBootstrap)
+
+	// Courses and tests saved for later without enrolling
+	bookmarksController := controllers.NewBookmarksController(db, cfg)
+	courses.Post("/:id/bookmark", bookmarksController.BookmarkCourse)
+	courses.Delete("/:id/bookmark", bookmarksController.UnbookmarkCourse)
+	tests.Post("/:id/bookmark", bookmarksController.BookmarkTest)
+	tests.Delete("/:id/bookmark", bookmarksController.UnbookmarkTest)
+	user.Get("/bookmarks", bookmarksController.GetBookmarks)
+
+	// API keys for external integrations
+	apiKeysController := controllers.NewApiKeysController(db, cfg)
+	user.Get("/api-keys", apiKeysController.GetApiKeys)
+	user.Post("/api-keys", apiKeysController.CreateApiKey)
+	user.Put("/api-keys/:id", apiKeysController.UpdateApiKey)
+	user.Delete("/api-keys/:id", apiKeysController.DeleteApiKey)
+
 	// Analytics routes
 	analyticsController := controllers.NewAnalyticsController(db, cfg)
-	analytics := app.Group("/api/analytics", middleware.AuthMiddleware(cfg))
+	analytics := app.Group("/api/analytics", authMiddleware)
 	analytics.Get("/progress", analyticsController.GetUserProgressAnalytics)
 	analytics.Get("/course/:id", analyticsController.GetCourseAnalytics)
 	analytics.Get("/test/:id", analyticsController.GetTestAnalytics)
 	analytics.Get("/platform", analyticsController.GetPlatformAnalytics)
+	analytics.Get("/alerts", analyticsController.GetPlatformAlerts)
 
 	// Overview routes
 	overviewController := controllers.NewOverviewController(db, cfg)
-	overview := app.Group("/api/overview", middleware.AuthMiddleware(cfg))
+	overview := app.Group("/api/overview", authMiddleware)
 	overview.Get("/", overviewController.GetUserOverview)
 	overview.Get("/courses", overviewController.SearchCourses)
 	overview.Get("/tests", overviewController.SearchTests)
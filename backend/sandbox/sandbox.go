@@ -0,0 +1,379 @@
+// Package sandbox обслуживает публичную песочницу для интеграторов: отдельный экземпляр API
+// (SANDBOX_MODE=true) со своей базой, демо-данными и ключами pk_sandbox_. Каждую ночь все, кроме
+// учетных записей, их ролей и ключей, стирается, и демо-данные создаются заново с теми же ID.
+// Кроме флага сброс требует, чтобы сама база была помечена как база песочницы (см. Mark).
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Демо-аккаунты с общим паролем из SANDBOX_DEMO_PASSWORD
+const (
+	DemoInstructor = "sandbox_instructor"
+	DemoStudent    = "sandbox_student"
+)
+
+// integratorRole выдается всем, кто регистрируется в песочнице, чтобы можно было проверить и авторские эндпоинты
+const integratorRole = "professor"
+
+var ErrNotSandbox = errors.New("the sandbox reset wipes the database and only runs on an instance started with SANDBOX_MODE=true")
+
+var ErrUnmarkedDatabase = errors.New("the database isn't marked as a sandbox: name it *_sandbox or run go run ./backend/cmd/sandbox -confirm <DB_NAME>")
+
+// markerTable хранит имя базы, помеченной как песочница. Таблицы нет в миграциях: она создается
+// только командой sandbox, поэтому случайно выставленный SANDBOX_MODE не сотрет боевую базу.
+const markerTable = "sandbox_marker"
+
+// databaseSuffix — базы с таким окончанием имени считаются песочницей без отметки
+const databaseSuffix = "_sandbox"
+
+// keptTables переживают сброс: интеграторы не должны заново регистрироваться и выпускать ключи каждое утро
+var keptTables = []string{
+	"users", "user_settings", "api_keys",
+	"roles", "permissions", "role_permissions", "user_roles",
+	"platform_settings", "schema_migrations", markerTable,
+}
+
+// detachedTables — справочники, на которые ссылаются пользователи. TRUNCATE задел бы и users,
+// поэтому они удаляются построчно, после того как ссылки на них обнулены (в порядке зависимостей).
+var detachedTables = []string{"study_groups", "universities"}
+
+// State — сведения о последнем сбросе, хранятся в настройке платформы
+type State struct {
+	LastResetAt *time.Time `json:"last_reset_at,omitempty"`
+	Tables      int        `json:"tables"`
+}
+
+// Report — итог сброса
+type Report struct {
+	Tables  []string `json:"tables"`
+	Courses int      `json:"courses"`
+	Tests   int      `json:"tests"`
+}
+
+// NextReset возвращает ближайший момент сброса после now; hour — час по UTC
+func NextReset(now time.Time, hour int) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Due сообщает, пора ли сбрасывать песочницу: с последнего сброса прошло очередное время hour
+func Due(state State, now time.Time, hour int) bool {
+	if state.LastResetAt == nil {
+		return true
+	}
+	return !NextReset(*state.LastResetAt, hour).After(now)
+}
+
+// Load читает сведения о последнем сбросе
+func Load(ctx context.Context, db *gorm.DB) (State, error) {
+	var setting models.PlatformSetting
+	if err := db.WithContext(ctx).Where("key = ?", models.SettingSandbox).Limit(1).Find(&setting).Error; err != nil {
+		return State{}, err
+	}
+
+	state := State{}
+	if setting.ID != 0 {
+		if err := json.Unmarshal([]byte(setting.Value), &state); err != nil {
+			return State{}, err
+		}
+	}
+	return state, nil
+}
+
+// Mark помечает текущую базу как базу песочницы
+func Mark(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`CREATE TABLE IF NOT EXISTS ` + markerTable + ` (
+			db_name TEXT PRIMARY KEY,
+			marked_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO ` + markerTable + ` (db_name) VALUES (current_database()) ON CONFLICT DO NOTHING`).Error
+	})
+}
+
+// Marked сообщает, помечена ли текущая база как база песочницы: по окончанию имени или строкой в markerTable.
+// Отметка хранит имя базы, поэтому дамп песочницы, восстановленный под другим именем, помеченным не считается.
+func Marked(db *gorm.DB) (bool, error) {
+	var database string
+	if err := db.Raw("SELECT current_database()").Scan(&database).Error; err != nil {
+		return false, err
+	}
+	if strings.HasSuffix(database, databaseSuffix) {
+		return true, nil
+	}
+
+	var exists bool
+	if err := db.Raw("SELECT to_regclass(?) IS NOT NULL", markerTable).Scan(&exists).Error; err != nil || !exists {
+		return false, err
+	}
+	var count int64
+	if err := db.Raw(`SELECT COUNT(*) FROM ` + markerTable + ` WHERE db_name = current_database()`).Scan(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Reset стирает данные песочницы и заново создает демо-данные в одной транзакции.
+// enabled — флаг SANDBOX_MODE; кроме него база должна быть помечена (Marked),
+// так что на боевой базе сброс не запускается ни при каких условиях.
+func Reset(ctx context.Context, db *gorm.DB, enabled bool, demoPassword string, now time.Time) (Report, error) {
+	if !enabled {
+		return Report{}, ErrNotSandbox
+	}
+
+	var report Report
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		marked, err := Marked(tx)
+		if err != nil {
+			return fmt.Errorf("checking the sandbox marker: %w", err)
+		}
+		if !marked {
+			return ErrUnmarkedDatabase
+		}
+
+		tables, err := wipe(tx)
+		if err != nil {
+			return err
+		}
+		if report, err = seed(tx, demoPassword, now); err != nil {
+			return fmt.Errorf("seeding demo data: %w", err)
+		}
+		report.Tables = tables
+
+		value, err := json.Marshal(State{LastResetAt: &now, Tables: len(tables)})
+		if err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&models.PlatformSetting{Key: models.SettingSandbox, Value: string(value)}).Error
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// GrantIntegratorRole дает зарегистрировавшемуся в песочнице право создавать курсы и тесты
+func GrantIntegratorRole(tx *gorm.DB, userID uint) error {
+	var role models.Role
+	if err := tx.Where("name = ?", integratorRole).First(&role).Error; err != nil {
+		return err
+	}
+	return tx.Where(models.UserRole{UserID: userID, RoleID: role.ID}).FirstOrCreate(&models.UserRole{}).Error
+}
+
+// wipe очищает все таблицы, кроме keptTables, и сбрасывает их последовательности, чтобы демо-данные получили те же ID
+func wipe(tx *gorm.DB) ([]string, error) {
+	var all []string
+	// Partitions are emptied through their parent table
+	if err := tx.Raw(`SELECT c.relname FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+		ORDER BY c.relname`).Scan(&all).Error; err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+
+	var truncated []string
+	for _, table := range all {
+		if !slices.Contains(keptTables, table) && !slices.Contains(detachedTables, table) {
+			truncated = append(truncated, table)
+		}
+	}
+
+	if len(truncated) > 0 {
+		quoted := make([]string, len(truncated))
+		for i, table := range truncated {
+			quoted[i] = `"` + table + `"`
+		}
+		if err := tx.Exec("TRUNCATE " + strings.Join(quoted, ", ") + " RESTART IDENTITY").Error; err != nil {
+			return nil, fmt.Errorf("truncating: %w", err)
+		}
+	}
+
+	// Accounts stay, their memberships in the reference data don't
+	if err := tx.Unscoped().Model(&models.User{}).Where("1 = 1").Updates(map[string]interface{}{
+		"study_group_id":         nil,
+		"group":                  "",
+		"university_id":          nil,
+		"university":             "",
+		"university_verified_at": nil,
+		"university_email":       "",
+	}).Error; err != nil {
+		return nil, fmt.Errorf("detaching users: %w", err)
+	}
+	for _, table := range detachedTables {
+		if !slices.Contains(all, table) {
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf(`DELETE FROM "%s"`, table)).Error; err != nil {
+			return nil, fmt.Errorf("clearing %s: %w", table, err)
+		}
+		if err := tx.Exec(fmt.Sprintf(`ALTER SEQUENCE IF EXISTS "%s_id_seq" RESTART`, table)).Error; err != nil {
+			return nil, fmt.Errorf("restarting %s ids: %w", table, err)
+		}
+		truncated = append(truncated, table)
+	}
+	return truncated, nil
+}
+
+// seed создает демо-аккаунты (или возвращает им пароль) и опубликованные демо-курсы с тестом
+func seed(tx *gorm.DB, demoPassword string, now time.Time) (Report, error) {
+	hash, err := utils.Passwords.Hash(demoPassword)
+	if err != nil {
+		return Report{}, err
+	}
+
+	instructor, err := demoUser(tx, DemoInstructor, hash)
+	if err != nil {
+		return Report{}, err
+	}
+	if err := GrantIntegratorRole(tx, instructor.ID); err != nil {
+		return Report{}, err
+	}
+	student, err := demoUser(tx, DemoStudent, hash)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var report Report
+	for _, demo := range demoCourses {
+		course := models.Course{
+			Title:       demo.Title,
+			ShortDesc:   demo.ShortDesc,
+			Description: demo.ShortDesc,
+			Difficulty:  "beginner",
+			AuthorID:    instructor.ID,
+			Status:      models.CoursePublished,
+			PublishedAt: &now,
+			License:     models.LicenseCCBY,
+			Attribution: "Philosofium sandbox",
+			AccessSettings: models.CourseAccessSettings{
+				AccessLevel: "public",
+			},
+		}
+		for i, lesson := range demo.Lessons {
+			course.Lessons = append(course.Lessons, models.Lesson{
+				Title:         lesson,
+				Content:       "<p>" + lesson + "</p>",
+				SequenceOrder: i + 1,
+			})
+		}
+		if err := tx.Create(&course).Error; err != nil {
+			return Report{}, err
+		}
+		report.Courses++
+
+		test := models.Test{
+			Title:          demo.Title + ": quiz",
+			ShortDesc:      "Check what you remember from " + demo.Title,
+			Difficulty:     "beginner",
+			AuthorID:       instructor.ID,
+			License:        models.LicenseCCBY,
+			Attribution:    "Philosofium sandbox",
+			AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 3},
+		}
+		for i, question := range demo.Questions {
+			options, _ := json.Marshal(question.Options)
+			test.Questions = append(test.Questions, models.TestQuestion{
+				Title:         fmt.Sprintf("Question %d", i+1),
+				Question:      question.Text,
+				Options:       string(options),
+				CorrectAnswer: question.Correct,
+				SequenceOrder: i + 1,
+			})
+		}
+		if err := tx.Create(&test).Error; err != nil {
+			return Report{}, err
+		}
+		report.Tests++
+
+		// The demo student is halfway through every course, so progress endpoints return something
+		if err := tx.Create(&models.UserCourseProgress{
+			UserID:           student.ID,
+			CourseID:         course.ID,
+			LessonsCompleted: len(demo.Lessons) / 2,
+			LastAccessed:     now.Format(time.RFC3339),
+			CompletionRate:   float64(len(demo.Lessons)/2) / float64(len(demo.Lessons)) * 100,
+		}).Error; err != nil {
+			return Report{}, err
+		}
+	}
+	return report, nil
+}
+
+// demoUser создает демо-аккаунт или сбрасывает пароль существующего; старые токены отзываются
+func demoUser(tx *gorm.DB, username, passwordHash string) (models.User, error) {
+	var user models.User
+	err := tx.Unscoped().Where("username = ?", username).Limit(1).Find(&user).Error
+	if err != nil {
+		return user, err
+	}
+	if user.ID == 0 {
+		user = models.User{Username: username, Email: username + "@example.invalid", PasswordHash: passwordHash, Active: true}
+		return user, tx.Create(&user).Error
+	}
+
+	return user, tx.Unscoped().Model(&user).Updates(map[string]interface{}{
+		"password_hash": passwordHash,
+		"token_version": gorm.Expr("token_version + 1"),
+		"active":        true,
+		"banned_until":  nil,
+		"deleted_at":    nil,
+		"purge_after":   nil,
+	}).Error
+}
+
+type demoQuestion struct {
+	Text    string
+	Options []string
+	Correct int
+}
+
+type demoCourse struct {
+	Title     string
+	ShortDesc string
+	Lessons   []string
+	Questions []demoQuestion
+}
+
+var demoCourses = []demoCourse{
+	{
+		Title:     "Introduction to Logic",
+		ShortDesc: "Arguments, validity and the most common fallacies",
+		Lessons:   []string{"What is an argument", "Deduction and induction", "Validity and soundness", "Informal fallacies"},
+		Questions: []demoQuestion{
+			{Text: "An argument whose premises guarantee its conclusion is called", Options: []string{"inductive", "deductive", "circular"}, Correct: 1},
+			{Text: "A valid argument with true premises is", Options: []string{"sound", "strong", "cogent"}, Correct: 0},
+		},
+	},
+	{
+		Title:     "Ancient Philosophy",
+		ShortDesc: "From the Presocratics to Aristotle",
+		Lessons:   []string{"The Presocratics", "Socrates", "Plato", "Aristotle"},
+		Questions: []demoQuestion{
+			{Text: "Who wrote the Republic?", Options: []string{"Aristotle", "Plato", "Heraclitus"}, Correct: 1},
+			{Text: "Who was Alexander the Great's tutor?", Options: []string{"Aristotle", "Socrates", "Thales"}, Correct: 0},
+		},
+	},
+}
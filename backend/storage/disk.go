@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Disk хранит файлы в локальной директории, которая раздается как статика по BaseURL
+type Disk struct {
+	Root    string
+	BaseURL string
+}
+
+func NewDisk(root, baseURL string) *Disk {
+	return &Disk{Root: root, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (d *Disk) Put(ctx context.Context, key string, body []byte, contentType string) (string, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	// Write to a temp file first so readers never see a half-written image
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	return d.BaseURL + "/" + key, nil
+}
+
+func (d *Disk) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (d *Disk) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path не дает ключу выйти за пределы корневой директории
+func (d *Disk) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", errors.New("empty storage key")
+	}
+	return filepath.Join(d.Root, clean), nil
+}
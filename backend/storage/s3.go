@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// S3 хранит файлы в S3-совместимом хранилище (AWS, MinIO и т.п.).
+// Запросы подписываются AWS Signature V4 без внешних SDK.
+type S3 struct {
+	Bucket    string
+	Region    string
+	Endpoint  string // custom endpoint (MinIO etc.), path-style addressing is used when set
+	AccessKey string
+	SecretKey string
+	PublicURL string // base URL objects are served from; defaults to the object URL
+	Client    *http.Client
+}
+
+func NewS3(bucket, region, endpoint, accessKey, secretKey, publicURL string) *S3 {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		Bucket:    bucket,
+		Region:    region,
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PublicURL: strings.TrimRight(publicURL, "/"),
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *S3) Put(ctx context.Context, key string, body []byte, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if _, err := s.do(req, body); err != nil {
+		return "", err
+	}
+
+	if s.PublicURL != "" {
+		return s.PublicURL + "/" + key, nil
+	}
+	return s.objectURL(key), nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(req, nil)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(req, nil)
+	return err
+}
+
+func (s *S3) objectURL(key string) string {
+	if s.Endpoint != "" {
+		return s.Endpoint + "/" + s.Bucket + "/" + uriEncode(key, false)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, uriEncode(key, false))
+}
+
+// do подписывает и выполняет запрос, возвращая тело успешного ответа
+func (s *S3) do(req *http.Request, body []byte) ([]byte, error) {
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		if req.Method == http.MethodDelete {
+			return nil, nil
+		}
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// sign добавляет к запросу заголовки AWS Signature V4
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append([]string{"content-type"}, signedHeaders...)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// uriEncode кодирует строку по правилам SigV4: без изменений остаются только A-Z a-z 0-9 - _ . ~ (и / в путях)
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/backend/config"
+)
+
+// ErrNotFound возвращается Get, если объекта нет
+var ErrNotFound = errors.New("storage object not found")
+
+// Storage хранит загруженные пользователями файлы (аватары и т.п.)
+type Storage interface {
+	// Put сохраняет объект под ключом key и возвращает его публичный URL
+	Put(ctx context.Context, key string, body []byte, contentType string) (string, error)
+	// Get читает объект целиком
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete удаляет объект; отсутствие объекта ошибкой не считается
+	Delete(ctx context.Context, key string) error
+}
+
+// Default — хранилище, используемое контроллерами. Заменяется в main по конфигурации.
+var Default Storage = NewDisk("./uploads", "/uploads")
+
+// Archive — закрытое хранилище для выгрузок архивированных пользователей и файлов фоновых выгрузок,
+// не раздается как статика
+var Archive Storage = NewDisk("./archive", "")
+
+// New создает хранилище по cfg.StorageDriver: "disk" (по умолчанию) или "s3"
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "", "disk":
+		return NewDisk(cfg.StorageDir, cfg.StoragePublicURL), nil
+	case "s3":
+		if cfg.S3Bucket == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("S3 storage requires S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY")
+		}
+		return NewS3(cfg.S3Bucket, cfg.S3Region, cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PublicURL), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// NewArchive создает хранилище архивов по cfg.ArchiveDriver. Для S3 используются те же ключи доступа,
+// но отдельный (закрытый) bucket ArchiveS3Bucket.
+func NewArchive(cfg *config.Config) (Storage, error) {
+	switch cfg.ArchiveDriver {
+	case "", "disk":
+		return NewDisk(cfg.ArchiveDir, ""), nil
+	case "s3":
+		if cfg.ArchiveS3Bucket == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("S3 archive requires ARCHIVE_S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY")
+		}
+		return NewS3(cfg.ArchiveS3Bucket, cfg.S3Region, cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, ""), nil
+	default:
+		return nil, fmt.Errorf("unknown archive driver %q", cfg.ArchiveDriver)
+	}
+}
@@ -0,0 +1,382 @@
+// Package usage считает запросы к API по потребителям (клиентское приложение, API ключ, пользователь,
+// анонимный IP) и маршрутам: сколько запросов, какая доля ошибок, упирается ли клиент в ограничения частоты.
+// Счетчики, как и в пакете metrics, копятся в памяти экземпляра и пишутся в почасовые корзины Flush.
+package usage
+
+import (
+	"context"
+	"project/backend/models"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Признаки подозрительного поведения потребителя в отчете
+const (
+	FlagRateLimited  = "rate_limited"    // keeps hitting the rate limits
+	FlagAuthFailures = "auth_failures"   // mostly 401/403: a revoked key or credential guessing
+	FlagErrorRate    = "high_error_rate" // most requests are rejected as invalid
+	FlagScanning     = "scanning"        // many 404s: probing for routes or walking IDs
+)
+
+// Пороги признаков: доля от запросов потребителя и минимальное число случаев, чтобы не ловить единичные ошибки
+const (
+	rateLimitedShare  = 0.05
+	rateLimitedMin    = 10
+	authFailuresShare = 0.5
+	authFailuresMin   = 20
+	errorRateShare    = 0.5
+	errorRateMin      = 100
+	notFoundShare     = 0.3
+	notFoundMin       = 50
+)
+
+// Сортировки отчета по потребителям
+const (
+	SortRequests    = "requests"
+	SortErrors      = "errors"
+	SortRateLimited = "rate_limited"
+	SortPeak        = "peak"
+)
+
+// SortOrders — допустимые значения сортировки отчета
+var SortOrders = []string{SortRequests, SortErrors, SortRateLimited, SortPeak}
+
+type bucketKey struct {
+	consumer string
+	route    string
+	hour     time.Time
+}
+
+type counts struct {
+	requests, clientErrors, serverErrors, authFailures, notFound, rateLimited int64
+}
+
+func (c *counts) add(other counts) {
+	c.requests += other.requests
+	c.clientErrors += other.clientErrors
+	c.serverErrors += other.serverErrors
+	c.authFailures += other.authFailures
+	c.notFound += other.notFound
+	c.rateLimited += other.rateLimited
+}
+
+// minuteKey — минута запросов потребителя, из них складывается peak_per_minute
+type minuteKey struct {
+	consumer string
+	minute   time.Time
+}
+
+var (
+	mu      sync.Mutex
+	pending = map[bucketKey]*counts{}
+	minutes = map[minuteKey]int64{}
+)
+
+// Record учитывает ответ со статусом status на запрос потребителя к маршруту; в базу попадает при Flush
+func Record(consumer, route string, status int, at time.Time) {
+	var delta counts
+	delta.requests = 1
+	switch {
+	case status >= 500:
+		delta.serverErrors = 1
+	case status >= 400:
+		delta.clientErrors = 1
+		switch status {
+		case 401, 403:
+			delta.authFailures = 1
+		case 404:
+			delta.notFound = 1
+		case 429:
+			delta.rateLimited = 1
+		}
+	}
+	key := bucketKey{consumer: consumer, route: route, hour: at.UTC().Truncate(time.Hour)}
+
+	mu.Lock()
+	defer mu.Unlock()
+	minutes[minuteKey{consumer: consumer, minute: at.UTC().Truncate(time.Minute)}]++
+	entry := pending[key]
+	if entry == nil {
+		entry = &counts{}
+		pending[key] = entry
+	}
+	entry.add(delta)
+}
+
+// Flush добавляет накопленные запросы к почасовым корзинам api_usage_buckets и поднимает peak_per_minute
+// корзин потребителя до его самой нагруженной минуты за час на этом экземпляре (столько же видит ограничитель
+// частоты, который тоже живет в памяти экземпляра). Минуты считаются по времени запросов, а не по вызовам Flush,
+// поэтому лишний или пропущенный Flush пик не искажает; текущая минута остается в памяти до своего конца.
+func Flush(ctx context.Context, db *gorm.DB) error {
+	now := time.Now().UTC().Truncate(time.Minute)
+	mu.Lock()
+	batch := pending
+	pending = map[bucketKey]*counts{}
+	peaks := map[bucketKey]int64{}
+	var done []minuteKey
+	for key, requests := range minutes {
+		hour := bucketKey{consumer: key.consumer, hour: key.minute.Truncate(time.Hour)}
+		peaks[hour] = max(peaks[hour], requests)
+		if key.minute.Before(now) {
+			done = append(done, key)
+		}
+	}
+	mu.Unlock()
+
+	if err := flushCounts(ctx, db, batch); err != nil {
+		return err
+	}
+	for key, peak := range peaks {
+		if err := db.WithContext(ctx).Model(&models.ApiUsageBucket{}).
+			Where("consumer = ? AND hour = ?", key.consumer, key.hour).
+			Update("peak_per_minute", gorm.Expr("GREATEST(peak_per_minute, ?)", peak)).Error; err != nil {
+			return err
+		}
+	}
+
+	// Minutes that are over are written for good
+	mu.Lock()
+	for _, key := range done {
+		delete(minutes, key)
+	}
+	mu.Unlock()
+	return nil
+}
+
+// flushCounts пишет счетчики корзин; то, что не удалось записать, возвращается в память до следующего Flush
+func flushCounts(ctx context.Context, db *gorm.DB, batch map[bucketKey]*counts) error {
+	for key, entry := range batch {
+		err := db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "consumer"}, {Name: "route"}, {Name: "hour"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"requests":      gorm.Expr("api_usage_buckets.requests + ?", entry.requests),
+				"client_errors": gorm.Expr("api_usage_buckets.client_errors + ?", entry.clientErrors),
+				"server_errors": gorm.Expr("api_usage_buckets.server_errors + ?", entry.serverErrors),
+				"auth_failures": gorm.Expr("api_usage_buckets.auth_failures + ?", entry.authFailures),
+				"not_found":     gorm.Expr("api_usage_buckets.not_found + ?", entry.notFound),
+				"rate_limited":  gorm.Expr("api_usage_buckets.rate_limited + ?", entry.rateLimited),
+				"updated_at":    time.Now(),
+			}),
+		}).Create(&models.ApiUsageBucket{
+			Consumer:     key.consumer,
+			Route:        key.route,
+			Hour:         key.hour,
+			Requests:     entry.requests,
+			ClientErrors: entry.clientErrors,
+			ServerErrors: entry.serverErrors,
+			AuthFailures: entry.authFailures,
+			NotFound:     entry.notFound,
+			RateLimited:  entry.rateLimited,
+		}).Error
+		if err != nil {
+			// Keep what wasn't written for the next flush
+			mu.Lock()
+			for key, entry := range batch {
+				if current := pending[key]; current != nil {
+					current.add(*entry)
+				} else {
+					pending[key] = entry
+				}
+			}
+			mu.Unlock()
+			return err
+		}
+		delete(batch, key)
+	}
+	return nil
+}
+
+// Totals — суммы запросов потребителя или маршрута за период отчета
+type Totals struct {
+	Requests     int64   `json:"requests"`
+	ClientErrors int64   `json:"client_errors"`
+	ServerErrors int64   `json:"server_errors"`
+	AuthFailures int64   `json:"auth_failures"`
+	NotFound     int64   `json:"not_found"`
+	RateLimited  int64   `json:"rate_limited"`
+	ErrorRate    float64 `json:"error_rate"` // share of 4xx and 5xx responses
+}
+
+func (t *Totals) computeErrorRate() {
+	if t.Requests > 0 {
+		t.ErrorRate = float64(t.ClientErrors+t.ServerErrors) / float64(t.Requests)
+	}
+}
+
+// share возвращает долю n от запросов
+func (t Totals) share(n int64) float64 {
+	if t.Requests == 0 {
+		return 0
+	}
+	return float64(n) / float64(t.Requests)
+}
+
+// ConsumerReport — потребитель API за период: суммы, число разных маршрутов, пиковая нагрузка и признаки
+type ConsumerReport struct {
+	Consumer string `json:"consumer"`
+	Totals
+	Routes        int       `json:"routes"`
+	PeakPerMinute int64     `json:"peak_per_minute"`
+	LastSeen      time.Time `json:"last_seen"` // start of the last hour with requests
+	Flags         []string  `json:"flags"`
+}
+
+// flag выставляет признаки подозрительного поведения по порогам пакета
+func (r *ConsumerReport) flag() {
+	r.Flags = []string{}
+	if r.RateLimited >= rateLimitedMin && r.share(r.RateLimited) >= rateLimitedShare {
+		r.Flags = append(r.Flags, FlagRateLimited)
+	}
+	if r.AuthFailures >= authFailuresMin && r.share(r.AuthFailures) >= authFailuresShare {
+		r.Flags = append(r.Flags, FlagAuthFailures)
+	}
+	if r.Requests >= errorRateMin && r.share(r.ClientErrors) >= errorRateShare {
+		r.Flags = append(r.Flags, FlagErrorRate)
+	}
+	if r.NotFound >= notFoundMin && r.share(r.NotFound) >= notFoundShare {
+		r.Flags = append(r.Flags, FlagScanning)
+	}
+}
+
+// Consumers собирает потребителей с запросами начиная с часа from, отсортированных по sortBy (SortOrders).
+// flaggedOnly оставляет только потребителей с признаками подозрительного поведения; limit <= 0 — без ограничения.
+func Consumers(db *gorm.DB, from time.Time, sortBy string, flaggedOnly bool, limit int) ([]ConsumerReport, error) {
+	var rows []struct {
+		Consumer                                                                  string
+		Requests, ClientErrors, ServerErrors, AuthFailures, NotFound, RateLimited int64
+		Routes                                                                    int
+		PeakPerMinute                                                             int64
+		LastSeen                                                                  time.Time
+	}
+	query := db.Model(&models.ApiUsageBucket{}).
+		Select(`consumer, SUM(requests) AS requests, SUM(client_errors) AS client_errors,
+			SUM(server_errors) AS server_errors, SUM(auth_failures) AS auth_failures, SUM(not_found) AS not_found,
+			SUM(rate_limited) AS rate_limited, COUNT(DISTINCT route) AS routes,
+			MAX(peak_per_minute) AS peak_per_minute, MAX(hour) AS last_seen`).
+		Where("hour >= ?", from.UTC().Truncate(time.Hour)).
+		Group("consumer")
+	if flaggedOnly {
+		// Only consumers that can cross a threshold, the rest aren't worth loading
+		query = query.Having("SUM(client_errors) > 0")
+	} else if limit > 0 {
+		query = query.Order(orderClause(sortBy) + ", consumer").Limit(limit)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]ConsumerReport, 0, len(rows))
+	for _, row := range rows {
+		report := ConsumerReport{
+			Consumer: row.Consumer,
+			Totals: Totals{
+				Requests:     row.Requests,
+				ClientErrors: row.ClientErrors,
+				ServerErrors: row.ServerErrors,
+				AuthFailures: row.AuthFailures,
+				NotFound:     row.NotFound,
+				RateLimited:  row.RateLimited,
+			},
+			Routes:        row.Routes,
+			PeakPerMinute: row.PeakPerMinute,
+			LastSeen:      row.LastSeen,
+		}
+		report.computeErrorRate()
+		report.flag()
+		if flaggedOnly && len(report.Flags) == 0 {
+			continue
+		}
+		result = append(result, report)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := sortValue(result[i], sortBy), sortValue(result[j], sortBy)
+		if a != b {
+			return a > b
+		}
+		return result[i].Consumer < result[j].Consumer
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func orderClause(sortBy string) string {
+	switch sortBy {
+	case SortErrors:
+		return "SUM(client_errors) + SUM(server_errors) DESC"
+	case SortRateLimited:
+		return "SUM(rate_limited) DESC"
+	case SortPeak:
+		return "MAX(peak_per_minute) DESC"
+	}
+	return "SUM(requests) DESC"
+}
+
+func sortValue(r ConsumerReport, sortBy string) int64 {
+	switch sortBy {
+	case SortErrors:
+		return r.ClientErrors + r.ServerErrors
+	case SortRateLimited:
+		return r.RateLimited
+	case SortPeak:
+		return r.PeakPerMinute
+	}
+	return r.Requests
+}
+
+// EndpointReport — маршрут за период: суммы и число разных потребителей
+type EndpointReport struct {
+	Route string `json:"route"`
+	Totals
+	Consumers int `json:"consumers"`
+}
+
+// Endpoints собирает маршруты по числу запросов начиная с часа from; consumer != "" — только его запросы
+func Endpoints(db *gorm.DB, from time.Time, consumer string, limit int) ([]EndpointReport, error) {
+	var rows []struct {
+		Route                                                                     string
+		Requests, ClientErrors, ServerErrors, AuthFailures, NotFound, RateLimited int64
+		Consumers                                                                 int
+	}
+	query := db.Model(&models.ApiUsageBucket{}).
+		Select(`route, SUM(requests) AS requests, SUM(client_errors) AS client_errors,
+			SUM(server_errors) AS server_errors, SUM(auth_failures) AS auth_failures, SUM(not_found) AS not_found,
+			SUM(rate_limited) AS rate_limited, COUNT(DISTINCT consumer) AS consumers`).
+		Where("hour >= ?", from.UTC().Truncate(time.Hour)).
+		Group("route").
+		Order("SUM(requests) DESC, route")
+	if consumer != "" {
+		query = query.Where("consumer = ?", consumer)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]EndpointReport, 0, len(rows))
+	for _, row := range rows {
+		report := EndpointReport{
+			Route: row.Route,
+			Totals: Totals{
+				Requests:     row.Requests,
+				ClientErrors: row.ClientErrors,
+				ServerErrors: row.ServerErrors,
+				AuthFailures: row.AuthFailures,
+				NotFound:     row.NotFound,
+				RateLimited:  row.RateLimited,
+			},
+			Consumers: row.Consumers,
+		}
+		report.computeErrorRate()
+		result = append(result, report)
+	}
+	return result, nil
+}
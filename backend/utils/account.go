@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"project/backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PermanentBan — срок бана, если администратор не указал длительность
+var PermanentBan = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// AccountBlocked возвращает ошибку 403 с причиной, если доступ пользователю закрыт, иначе nil
+func AccountBlocked(user *models.User) *AuthError {
+	if !user.Active {
+		return &AuthError{
+			Status:  fiber.StatusForbidden,
+			Code:    CodeAccountDeactivated,
+			Message: "Account is deactivated",
+		}
+	}
+
+	if user.BannedUntil != nil && user.BannedUntil.After(time.Now()) {
+		return &AuthError{
+			Status:  fiber.StatusForbidden,
+			Code:    CodeAccountLocked,
+			Message: "Account is banned",
+			Details: fiber.Map{
+				"reason":       user.BanReason,
+				"banned_until": user.BannedUntil,
+			},
+		}
+	}
+
+	return nil
+}
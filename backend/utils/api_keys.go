@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	apiKeyPrefix = "pk_"
+	// sandboxKeyPrefix отличает ключи песочницы, чтобы их нельзя было перепутать с боевыми
+	sandboxKeyPrefix = "pk_sandbox_"
+)
+
+// GenerateAPIKey создает новый API ключ и возвращает его, короткий префикс и хеш для хранения.
+// Экземпляр в режиме песочницы выдает ключи с префиксом pk_sandbox_.
+func GenerateAPIKey(sandbox bool) (key, prefix, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+
+	keyPrefix := apiKeyPrefix
+	if sandbox {
+		keyPrefix = sandboxKeyPrefix
+	}
+	key = keyPrefix + hex.EncodeToString(buf)
+	return key, key[:len(keyPrefix)+8], HashAPIKey(key), nil
+}
+
+// HashAPIKey возвращает sha256 хеш ключа
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsSandboxAPIKey сообщает, выдан ли ключ песочницей
+func IsSandboxAPIKey(key string) bool {
+	return strings.HasPrefix(key, sandboxKeyPrefix)
+}
+
+// IsLiveAPIKey сообщает, похож ли ключ на выданный боевым экземпляром
+func IsLiveAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix) && !IsSandboxAPIKey(key)
+}
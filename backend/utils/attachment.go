@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrUnsupportedAttachment = errors.New("Unsupported file type, use PDF, slides (PPTX, PPT, ODP, KEY), documents (DOCX, ODT) or datasets (CSV, TSV, JSON, XLSX, XLS, ODS, Parquet, ZIP)")
+
+// attachmentKind — допустимое расширение вложения: тип содержимого для скачивания и проверка сигнатуры файла
+type attachmentKind struct {
+	contentType string
+	sniff       func(data []byte) bool
+}
+
+var (
+	zipMagic     = []byte("PK\x03\x04")
+	oleMagic     = []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1") // legacy Office (PPT, XLS)
+	parquetMagic = []byte("PAR1")
+)
+
+func isPDF(data []byte) bool { return http.DetectContentType(data) == "application/pdf" }
+func isZip(data []byte) bool { return bytes.HasPrefix(data, zipMagic) }
+func isOLE(data []byte) bool { return bytes.HasPrefix(data, oleMagic) }
+
+func isParquet(data []byte) bool {
+	return bytes.HasPrefix(data, parquetMagic) && bytes.HasSuffix(data, parquetMagic)
+}
+
+// isText пропускает только текст в UTF-8 без управляющих символов (CSV, TSV, JSON)
+func isText(data []byte) bool {
+	return utf8.Valid(data) && strings.HasPrefix(http.DetectContentType(data), "text/plain")
+}
+
+// attachmentKinds — материалы к урокам: документы, слайды и наборы данных. HTML, SVG и исполняемые
+// файлы не принимаются: вложения отдаются с того же домена, что и API.
+var attachmentKinds = map[string]attachmentKind{
+	".pdf":     {"application/pdf", isPDF},
+	".pptx":    {"application/vnd.openxmlformats-officedocument.presentationml.presentation", isZip},
+	".ppt":     {"application/vnd.ms-powerpoint", isOLE},
+	".odp":     {"application/vnd.oasis.opendocument.presentation", isZip},
+	".key":     {"application/vnd.apple.keynote", isZip},
+	".docx":    {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", isZip},
+	".odt":     {"application/vnd.oasis.opendocument.text", isZip},
+	".csv":     {"text/csv; charset=utf-8", isText},
+	".tsv":     {"text/tab-separated-values; charset=utf-8", isText},
+	".json":    {"application/json", isText},
+	".xlsx":    {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", isZip},
+	".xls":     {"application/vnd.ms-excel", isOLE},
+	".ods":     {"application/vnd.oasis.opendocument.spreadsheet", isZip},
+	".parquet": {"application/vnd.apache.parquet", isParquet},
+	".zip":     {"application/zip", isZip},
+}
+
+// DetectAttachment проверяет, что файл name с содержимым data — допустимое вложение урока,
+// и возвращает его тип содержимого. Тип определяется по расширению и подтверждается сигнатурой файла,
+// заявленный клиентом Content-Type не учитывается.
+func DetectAttachment(name string, data []byte) (string, error) {
+	kind, ok := attachmentKinds[strings.ToLower(path.Ext(name))]
+	if !ok || len(data) == 0 || !kind.sniff(data) {
+		return "", ErrUnsupportedAttachment
+	}
+	return kind.contentType, nil
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorCode — машиночитаемый код ошибки, по которому фронтенд выбирает реакцию
+type ErrorCode string
+
+const (
+	CodeTokenMissing       ErrorCode = "TOKEN_MISSING"
+	CodeTokenExpired       ErrorCode = "TOKEN_EXPIRED"
+	CodeTokenInvalid       ErrorCode = "TOKEN_INVALID"
+	CodeTokenRevoked       ErrorCode = "TOKEN_REVOKED"
+	CodeAPIKeyInvalid      ErrorCode = "API_KEY_INVALID"
+	CodeAPIKeyExpired      ErrorCode = "API_KEY_EXPIRED"
+	CodeAPIKeyEnvironment  ErrorCode = "API_KEY_WRONG_ENVIRONMENT"
+	CodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
+	CodeAccountLocked      ErrorCode = "ACCOUNT_LOCKED"
+	CodeAccountDeactivated ErrorCode = "ACCOUNT_DEACTIVATED"
+	CodeCaptchaFailed      ErrorCode = "CAPTCHA_FAILED"
+	CodeReadOnly           ErrorCode = "READ_ONLY"
+	CodeLegalHold          ErrorCode = "LEGAL_HOLD"
+	CodeInsufficientScope  ErrorCode = "INSUFFICIENT_SCOPE"
+	CodeClientRevoked      ErrorCode = "CLIENT_REVOKED"
+)
+
+// AuthError — ошибка аутентификации или авторизации с HTTP статусом и кодом
+type AuthError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Details interface{}
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// NewAuthError создает ошибку 401 с указанным кодом
+func NewAuthError(code ErrorCode, message string) *AuthError {
+	return &AuthError{Status: fiber.StatusUnauthorized, Code: code, Message: message}
+}
+
+// AuthFailure отправляет ответ для ошибки аутентификации.
+// Ошибки, не являющиеся AuthError, считаются недействительным токеном.
+func AuthFailure(c *fiber.Ctx, err error) error {
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		authErr = NewAuthError(CodeTokenInvalid, "Invalid token")
+	}
+
+	return c.Status(authErr.Status).JSON(ErrorResponse{
+		Success: false,
+		Error:   http.StatusText(authErr.Status),
+		Code:    authErr.Code,
+		Message: authErr.Message,
+		Details: authErr.Details,
+	})
+}
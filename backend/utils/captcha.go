@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"project/backend/config"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCaptchaMissing = errors.New("CAPTCHA token is required")
+	ErrCaptchaFailed  = errors.New("CAPTCHA verification failed")
+)
+
+var captchaVerifyURLs = map[string]string{
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+}
+
+var captchaClient = &http.Client{Timeout: 5 * time.Second}
+
+// CaptchaEnabled сообщает, включена ли проверка CAPTCHA
+func CaptchaEnabled(cfg *config.Config) bool {
+	return cfg.CaptchaProvider != ""
+}
+
+// VerifyCaptcha проверяет токен у провайдера (reCAPTCHA или hCaptcha) по его адресу проверки
+// или по CaptchaVerifyURL, если он задан. Если провайдер не настроен, проверка пропускается.
+func VerifyCaptcha(ctx context.Context, cfg *config.Config, token, remoteIP string) error {
+	if !CaptchaEnabled(cfg) {
+		return nil
+	}
+	if token == "" {
+		return ErrCaptchaMissing
+	}
+
+	verifyURL, ok := captchaVerifyURLs[cfg.CaptchaProvider]
+	if !ok {
+		return errors.New("unknown CAPTCHA provider: " + cfg.CaptchaProvider)
+	}
+	if cfg.CaptchaVerifyURL != "" {
+		verifyURL = cfg.CaptchaVerifyURL
+	}
+
+	form := url.Values{
+		"secret":   {cfg.CaptchaSecret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := captchaClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return ErrCaptchaFailed
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"project/backend/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCaptcha(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("response") {
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "good":
+			assert.Equal(t, "captcha-secret", r.Form.Get("secret"))
+			assert.Equal(t, "203.0.113.7", r.Form.Get("remoteip"))
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false}`))
+	}))
+	defer server.Close()
+
+	previous := captchaClient
+	defer func() { captchaClient = previous }()
+	captchaClient = &http.Client{Timeout: 50 * time.Millisecond}
+
+	cfg := &config.Config{CaptchaProvider: "hcaptcha", CaptchaSecret: "captcha-secret", CaptchaVerifyURL: server.URL}
+	ctx := context.Background()
+
+	assert.NoError(t, VerifyCaptcha(ctx, cfg, "good", "203.0.113.7"))
+	assert.ErrorIs(t, VerifyCaptcha(ctx, cfg, "bad", ""), ErrCaptchaFailed)
+	assert.ErrorIs(t, VerifyCaptcha(ctx, cfg, "", ""), ErrCaptchaMissing)
+
+	// A provider that doesn't answer in time is an error, not a pass
+	err := VerifyCaptcha(ctx, cfg, "slow", "")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCaptchaFailed)
+
+	// Without a provider the check is skipped
+	assert.NoError(t, VerifyCaptcha(ctx, &config.Config{}, "", ""))
+}
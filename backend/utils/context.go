@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type requestStateKey struct{}
+
+// requestState хранит признаки, выставляемые во время обработки запроса
+type requestState struct {
+	RequestID     string
+	queryTimedOut atomic.Bool
+}
+
+// NewRequestContext создает контекст запроса с общим таймаутом и ID запроса
+func NewRequestContext(parent context.Context, requestID string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := context.WithValue(parent, requestStateKey{}, &requestState{RequestID: requestID})
+	return context.WithTimeout(ctx, timeout)
+}
+
+// RequestIDFromContext возвращает ID запроса из контекста
+func RequestIDFromContext(ctx context.Context) string {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+		return state.RequestID
+	}
+	return ""
+}
+
+// QueryTimedOut сообщает, превысил ли хотя бы один запрос к БД свой таймаут
+func QueryTimedOut(ctx context.Context) bool {
+	if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+		return state.queryTimedOut.Load()
+	}
+	return false
+}
+
+// RegisterQueryTimeout ограничивает время выполнения каждого запроса GORM.
+// Запрос получает собственный дедлайн поверх контекста запроса; после выполнения контекст цепочки
+// восстанавливается, так что Count и Find на одном *gorm.DB получают каждый свой дедлайн.
+func RegisterQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	before := func(tx *gorm.DB) {
+		ctx, cancel := context.WithTimeout(tx.Statement.Context, timeout)
+		tx.InstanceSet("query_timeout:parent", tx.Statement.Context)
+		tx.InstanceSet("query_timeout:cancel", cancel)
+		tx.Statement.Context = ctx
+	}
+	after := func(tx *gorm.DB) {
+		if tx.Error != nil && errors.Is(tx.Error, context.DeadlineExceeded) {
+			if state, ok := tx.Statement.Context.Value(requestStateKey{}).(*requestState); ok {
+				state.queryTimedOut.Store(true)
+			}
+		}
+		if cancel, ok := tx.InstanceGet("query_timeout:cancel"); ok {
+			cancel.(context.CancelFunc)()
+		}
+		// Statement общий для цепочки: следующий вызов не должен получить отмененный контекст
+		if parent, ok := tx.InstanceGet("query_timeout:parent"); ok {
+			tx.Statement.Context = parent.(context.Context)
+		}
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("query_timeout:before_create", before); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("query_timeout:after_create", after); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("query_timeout:before_query", before); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("query_timeout:after_query", after); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("query_timeout:before_update", before); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("query_timeout:after_update", after); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("query_timeout:before_delete", before); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("query_timeout:after_delete", after); err != nil {
+		return err
+	}
+	// Row/Rows не получают своего дедлайна: строки читаются уже после callback-а, и отменить контекст
+	// после их закрытия негде. Их ограничивает общий таймаут контекста запроса.
+	if err := cb.Raw().Before("gorm:raw").Register("query_timeout:before_raw", before); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("query_timeout:after_raw", after)
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCursorLimit = 20
+	maxCursorLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor указывает на последнюю отданную запись: (время, id)
+type Cursor struct {
+	Time time.Time `json:"t"`
+	ID   uint      `json:"id"`
+}
+
+// EncodeCursor упаковывает позицию в непрозрачную строку
+func EncodeCursor(t time.Time, id uint) string {
+	data, _ := json.Marshal(Cursor{Time: t, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает строку, полученную из EncodeCursor
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// ParseCursorParams читает ?cursor= и ?limit= из запроса
+func ParseCursorParams(c *fiber.Ctx) (*Cursor, int, error) {
+	limit, _ := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultCursorLimit)))
+	if limit < 1 {
+		limit = defaultCursorLimit
+	}
+	if limit > maxCursorLimit {
+		limit = maxCursorLimit
+	}
+
+	raw := c.Query("cursor")
+	if raw == "" {
+		return nil, limit, nil
+	}
+
+	cursor, err := DecodeCursor(raw)
+	return cursor, limit, err
+}
+
+// ApplyCursor добавляет keyset-условие и сортировку от новых к старым.
+// Выбирается limit+1 запись, чтобы понять, есть ли следующая страница.
+func ApplyCursor(query *gorm.DB, timeColumn, idColumn string, cursor *Cursor, limit int) *gorm.DB {
+	if cursor != nil {
+		query = query.Where("("+timeColumn+", "+idColumn+") < (?, ?)", cursor.Time, cursor.ID)
+	}
+	return query.Order(timeColumn + " DESC").Order(idColumn + " DESC").Limit(limit + 1)
+}
+
+// TrimPage отрезает лишнюю запись, выбранную ApplyCursor, и возвращает курсор следующей страницы
+func TrimPage[T any](items []T, limit int, key func(T) (time.Time, uint)) ([]T, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+
+	items = items[:limit]
+	t, id := key(items[len(items)-1])
+	return items, EncodeCursor(t, id)
+}
+
+// CursorPaginatedResponse структура для ответов с keyset-пагинацией
+type CursorPaginatedResponse struct {
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data"`
+	PageSize   int         `json:"pageSize"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+// PaginateCursor создает ответ с курсором следующей страницы (пустой, если страниц больше нет)
+func PaginateCursor(c *fiber.Ctx, data interface{}, nextCursor string, pageSize int) error {
+	return c.JSON(CursorPaginatedResponse{
+		Success:    true,
+		Data:       data,
+		PageSize:   pageSize,
+		NextCursor: nextCursor,
+	})
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Порядок важен: Edge и Opera содержат "Chrome", Chrome содержит "Safari", Android — "Linux"
+var (
+	browserMarkers = []struct{ marker, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"CriOS/", "Chrome"}, {"Safari/", "Safari"},
+	}
+	osMarkers = []struct{ marker, name string }{
+		{"Windows", "Windows"}, {"iPhone", "iOS"}, {"iPad", "iOS"}, {"Android", "Android"},
+		{"Mac OS X", "macOS"}, {"Linux", "Linux"},
+	}
+)
+
+// DeviceName сводит User-Agent к браузеру и ОС, например "Chrome on Windows".
+// Версии отбрасываются, чтобы обновление браузера не выглядело как новое устройство.
+func DeviceName(userAgent string) string {
+	if strings.TrimSpace(userAgent) == "" {
+		return "Unknown device"
+	}
+	browser, system := "Unknown browser", "unknown OS"
+	for _, b := range browserMarkers {
+		if strings.Contains(userAgent, b.marker) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range osMarkers {
+		if strings.Contains(userAgent, o.marker) {
+			system = o.name
+			break
+		}
+	}
+	return browser + " on " + system
+}
+
+// RequestCountry возвращает ISO код страны клиента из заголовка прокси или пустую строку
+func RequestCountry(c *fiber.Ctx, header string) string {
+	if header == "" {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(c.Get(header)))
+	// Cloudflare uses XX for unknown and T1 for Tor
+	if len(country) != 2 || country == "XX" || country == "T1" {
+		return ""
+	}
+	return country
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+const (
+	AvatarSize        = 256
+	CoverWidth        = 1280
+	CoverHeight       = 720
+	maxImagePixels    = 40_000_000 // guards against decompression bombs
+	minAvatarSourcePx = 32
+)
+
+var (
+	ErrUnsupportedImage = errors.New("Unsupported image format, use JPEG, PNG or GIF")
+	ErrImageTooSmall    = errors.New("Image must be at least 32x32 pixels")
+	ErrImageTooLarge    = errors.New("Image dimensions are too large")
+	ErrCoverTooSmall    = errors.New("Cover must be at least 640x360 pixels")
+	ErrInvalidCrop      = errors.New("Crop must lie inside the image and have a 16:9 aspect ratio")
+)
+
+// ProcessAvatar проверяет изображение, обрезает его до квадрата по центру и уменьшает до AvatarSize.
+// PNG сохраняет прозрачность, остальные форматы перекодируются в JPEG.
+func ProcessAvatar(data []byte) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if cfg.Width < minAvatarSourcePx || cfg.Height < minAvatarSourcePx {
+		return nil, "", ErrImageTooSmall
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+
+	return encodeImage(resizeArea(cropSquare(src.Bounds()), src, AvatarSize, AvatarSize), format)
+}
+
+// ProcessCover проверяет обложку курса, вырезает область crop (или 16:9 по центру, если crop пустой)
+// и приводит ее к CoverWidth×CoverHeight. Координаты crop задаются в пикселях исходного изображения.
+func ProcessCover(data []byte, crop image.Rectangle) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if cfg.Width < CoverWidth/2 || cfg.Height < CoverHeight/2 {
+		return nil, "", ErrCoverTooSmall
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+
+	bounds := src.Bounds()
+	if crop.Empty() {
+		crop = cropAspect(bounds, CoverWidth, CoverHeight)
+	} else {
+		crop = crop.Add(bounds.Min)
+		// Allow a pixel of rounding in client-side croppers
+		ratio := float64(crop.Dx()) * CoverHeight / (float64(crop.Dy()) * CoverWidth)
+		if !crop.In(bounds) || ratio < 0.99 || ratio > 1.01 ||
+			crop.Dx() < CoverWidth/2 || crop.Dy() < CoverHeight/2 {
+			return nil, "", ErrInvalidCrop
+		}
+	}
+
+	return encodeImage(resizeArea(crop, src, CoverWidth, CoverHeight), format)
+}
+
+// encodeImage сохраняет PNG как PNG (ради прозрачности), остальное — как JPEG
+func encodeImage(img image.Image, format string) ([]byte, string, error) {
+	var out bytes.Buffer
+	if format == "png" {
+		err := png.Encode(&out, img)
+		return out.Bytes(), "image/png", err
+	}
+	err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 85})
+	return out.Bytes(), "image/jpeg", err
+}
+
+// ReadLimited читает не больше limit байт и сообщает, если данных было больше
+func ReadLimited(r io.Reader, limit int64) ([]byte, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > limit {
+		return nil, true, nil
+	}
+	return data, false, nil
+}
+
+// cropSquare возвращает наибольший квадрат по центру изображения
+func cropSquare(b image.Rectangle) image.Rectangle {
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// cropAspect возвращает наибольшую область по центру с соотношением сторон width:height
+func cropAspect(b image.Rectangle, width, height int) image.Rectangle {
+	w, h := b.Dx(), b.Dx()*height/width
+	if h > b.Dy() {
+		w, h = b.Dy()*width/height, b.Dy()
+	}
+	x := b.Min.X + (b.Dx()-w)/2
+	y := b.Min.Y + (b.Dy()-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// resizeArea приводит область src к размеру width×height усреднением пикселей (box filter).
+// Если исходник меньше, пиксели просто повторяются.
+func resizeArea(area image.Rectangle, src image.Image, width, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	scaleX := float64(area.Dx()) / float64(width)
+	scaleY := float64(area.Dy()) / float64(height)
+
+	for dy := 0; dy < height; dy++ {
+		y0 := area.Min.Y + int(float64(dy)*scaleY)
+		y1 := area.Min.Y + int(float64(dy+1)*scaleY)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < width; dx++ {
+			x0 := area.Min.X + int(float64(dx)*scaleX)
+			x1 := area.Min.X + int(float64(dx+1)*scaleX)
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := src.At(x, y).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+
+			// Averaged values are alpha-premultiplied, convert back for NRGBA
+			px := color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)}
+			dst.Set(dx, dy, px)
+		}
+	}
+	return dst
+}
@@ -1,49 +1,145 @@
 package utils
 
 import (
+	"errors"
 	"project/backend/config"
+	"project/backend/models"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func GenerateJWTToken(userID uint, cfg *config.Config) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
+// Claims содержит данные пользователя, зашитые в JWT
+type Claims struct {
+	UserID       uint   `json:"user_id"`
+	Role         string `json:"role"`
+	Group        string `json:"group"`
+	TokenVersion int    `json:"token_version"`
+	// Set on tokens issued to a registered client app: requests are limited to the granted scopes
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"` // space-separated
+	jwt.RegisteredClaims
+}
+
+// GenerateJWTToken подписывает токен алгоритмом и сроком жизни из конфигурации
+func GenerateJWTToken(user *models.User, cfg *config.Config) (string, error) {
+	claims := *ClaimsForUser(user)
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(jwtExpiry(cfg)))
+	return signClaims(claims, cfg)
+}
+
+// GenerateClientToken подписывает токен, выданный пользователем клиентскому приложению: с ним доступны
+// только запросы в пределах scope. Возвращает токен и срок его жизни.
+func GenerateClientToken(user *models.User, clientID, scope string, cfg *config.Config) (string, time.Duration, error) {
+	ttl := time.Duration(cfg.OAuthTokenTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = time.Hour
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWTSecret))
+	claims := *ClaimsForUser(user)
+	claims.ClientID, claims.Scope = clientID, scope
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(ttl))
+	token, err := signClaims(claims, cfg)
+	return token, ttl, err
 }
 
-func ExtractUserIDFromToken(c *fiber.Ctx, cfg *config.Config) (uint, error) {
-	tokenString := c.Get("Authorization")
+func signClaims(claims Claims, cfg *config.Config) (string, error) {
+	method, err := jwtSigningMethod(cfg)
+	if err != nil {
+		return "", err
+	}
+	key, err := jwtSigningKey(cfg, method)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if cfg.JWTKeyID != "" {
+		token.Header["kid"] = cfg.JWTKeyID
+	}
+	return token.SignedString(key)
+}
+
+// ClaimsForUser собирает claims для пользователя, аутентифицированного не через JWT (например, по API ключу)
+func ClaimsForUser(user *models.User) *Claims {
+	return &Claims{
+		UserID:       user.ID,
+		Role:         user.Role,
+		Group:        user.Group,
+		TokenVersion: user.TokenVersion,
+	}
+}
+
+// ExtractClaims возвращает claims текущего запроса.
+// Если AuthMiddleware уже разобрал токен, повторного парсинга не происходит.
+// Ошибки возвращаются как *AuthError с кодом (TOKEN_MISSING, TOKEN_EXPIRED, TOKEN_INVALID).
+func ExtractClaims(c *fiber.Ctx, cfg *config.Config) (*Claims, error) {
+	if claims, ok := c.Locals("claims").(*Claims); ok {
+		return claims, nil
+	}
+
+	// Client apps send the standard "Bearer <token>", the first-party frontend sends the bare token
+	tokenString := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
 	if tokenString == "" {
-		return 0, fiber.NewError(fiber.StatusUnauthorized, "Missing authorization token")
+		return nil, NewAuthError(CodeTokenMissing, "Missing authorization token")
+	}
+
+	method, err := jwtSigningMethod(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid signing method")
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Only the configured algorithm is accepted, so an HS256 token can't be forged with the RSA public key
+		if token.Method.Alg() != method.Alg() {
+			return nil, NewAuthError(CodeTokenInvalid, "Invalid signing method")
 		}
-		return []byte(cfg.JWTSecret), nil
+		kid, _ := token.Header["kid"].(string)
+		return jwtVerificationKeyByID(cfg, method, kid)
 	})
 
 	if err != nil {
-		return 0, fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, NewAuthError(CodeTokenExpired, "Token has expired")
+		}
+		return nil, NewAuthError(CodeTokenInvalid, "Invalid token")
+	}
+
+	if !token.Valid || claims.UserID == 0 {
+		return nil, NewAuthError(CodeTokenInvalid, "Invalid token claims")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return 0, fiber.NewError(fiber.StatusUnauthorized, "Invalid token claims")
+	c.Locals("claims", claims)
+	return claims, nil
+}
+
+func ExtractUserIDFromToken(c *fiber.Ctx, cfg *config.Config) (uint, error) {
+	// Пользователь уже определен AuthMiddleware (JWT или API ключ)
+	if userID, ok := c.Locals("user_id").(uint); ok {
+		return userID, nil
 	}
 
-	userIDFloat, ok := claims["user_id"].(float64)
-	if !ok {
-		return 0, fiber.NewError(fiber.StatusUnauthorized, "Invalid user ID in token")
+	claims, err := ExtractClaims(c, cfg)
+	if err != nil {
+		return 0, err
 	}
+	return claims.UserID, nil
+}
 
-	return uint(userIDFloat), nil
+// HasScope сообщает, выдана ли токену область scope
+func (c *Claims) HasScope(scope string) bool {
+	return ScopeIncludes(c.Scope, scope)
+}
+
+// ScopeIncludes сообщает, входит ли scope в список областей через пробел
+func ScopeIncludes(list, scope string) bool {
+	for _, granted := range strings.Fields(list) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
 }
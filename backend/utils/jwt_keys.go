@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"project/backend/config"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const defaultJWTExpiry = 72 * time.Hour
+
+// rsaKeys кэширует разобранные PEM ключи по пути к файлу
+var rsaKeys sync.Map
+
+// jwtSigningMethod возвращает алгоритм подписи из конфигурации (по умолчанию HS256)
+func jwtSigningMethod(cfg *config.Config) (jwt.SigningMethod, error) {
+	switch strings.ToUpper(cfg.JWTAlgorithm) {
+	case "", "HS256":
+		return jwt.SigningMethodHS256, nil
+	case "RS256":
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+}
+
+// jwtExpiry возвращает время жизни токена из конфигурации
+func jwtExpiry(cfg *config.Config) time.Duration {
+	if cfg.JWTExpiryHours <= 0 {
+		return defaultJWTExpiry
+	}
+	return time.Duration(cfg.JWTExpiryHours) * time.Hour
+}
+
+// jwtSigningKey возвращает ключ для подписи: секрет для HS256 или приватный RSA ключ для RS256
+func jwtSigningKey(cfg *config.Config, method jwt.SigningMethod) (interface{}, error) {
+	if method != jwt.SigningMethodRS256 {
+		return []byte(cfg.JWTSecret), nil
+	}
+	if cfg.JWTPrivateKeyFile == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for RS256")
+	}
+	return loadRSAKey(cfg.JWTPrivateKeyFile, func(pem []byte) (interface{}, error) {
+		return jwt.ParseRSAPrivateKeyFromPEM(pem)
+	})
+}
+
+// jwtVerificationKey возвращает ключ для проверки подписи.
+// Для RS256 достаточно публичного ключа, поэтому другие сервисы могут проверять токены без секрета.
+func jwtVerificationKey(cfg *config.Config, method jwt.SigningMethod) (interface{}, error) {
+	if method != jwt.SigningMethodRS256 {
+		return []byte(cfg.JWTSecret), nil
+	}
+	if cfg.JWTPublicKeyFile == "" {
+		// Fall back to the public half of the private key
+		key, err := jwtSigningKey(cfg, method)
+		if err != nil {
+			return nil, err
+		}
+		return &key.(*rsa.PrivateKey).PublicKey, nil
+	}
+	return loadRSAKey(cfg.JWTPublicKeyFile, func(pem []byte) (interface{}, error) {
+		return jwt.ParseRSAPublicKeyFromPEM(pem)
+	})
+}
+
+// jwtVerificationKeyByID возвращает ключ проверки для kid из заголовка токена: текущий ключ (JWTKeyID)
+// или один из выведенных из оборота (JWTPreviousKeys), чтобы после ротации выданные токены доживали свой срок
+func jwtVerificationKeyByID(cfg *config.Config, method jwt.SigningMethod, kid string) (interface{}, error) {
+	if kid == cfg.JWTKeyID {
+		return jwtVerificationKey(cfg, method)
+	}
+
+	previous, err := jwtPreviousKeys(cfg, method)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := previous[kid]
+	if !ok {
+		return nil, NewAuthError(CodeTokenInvalid, "Unknown signing key")
+	}
+	return key, nil
+}
+
+// jwtPreviousKeys разбирает JWTPreviousKeys: "kid:secret" для HS256 или "kid:/path/public.pem" для RS256 через запятую.
+// Запись без "kid:" — ключ токенов, выданных до появления kid в заголовке.
+func jwtPreviousKeys(cfg *config.Config, method jwt.SigningMethod) (map[string]interface{}, error) {
+	keys := map[string]interface{}{}
+	for _, entry := range strings.Split(cfg.JWTPreviousKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, value := "", entry
+		if i := strings.Index(entry, ":"); i >= 0 {
+			kid, value = entry[:i], entry[i+1:]
+		}
+		if kid == cfg.JWTKeyID {
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEYS reuses the current key ID %q", kid)
+		}
+
+		if method != jwt.SigningMethodRS256 {
+			keys[kid] = []byte(value)
+			continue
+		}
+		key, err := loadRSAKey(value, func(pem []byte) (interface{}, error) {
+			return jwt.ParseRSAPublicKeyFromPEM(pem)
+		})
+		if err != nil {
+			return nil, err
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+func loadRSAKey(path string, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	if key, ok := rsaKeys.Load(path); ok {
+		return key, nil
+	}
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT key: %w", err)
+	}
+	key, err := parse(pem)
+	if err != nil {
+		return nil, fmt.Errorf("parse JWT key %s: %w", path, err)
+	}
+
+	rsaKeys.Store(path, key)
+	return key, nil
+}
+
+// ValidateJWTConfig проверяет алгоритм и загружает ключи при старте, чтобы ошибка конфигурации
+// не всплыла только на первом логине
+func ValidateJWTConfig(cfg *config.Config) error {
+	method, err := jwtSigningMethod(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := jwtSigningKey(cfg, method); err != nil {
+		return err
+	}
+	if _, err := jwtVerificationKey(cfg, method); err != nil {
+		return err
+	}
+	_, err = jwtPreviousKeys(cfg, method)
+	return err
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"project/backend/config"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var jwtTestUser = models.User{Model: gorm.Model{ID: 7}, Username: "jwt_user"}
+
+func TestRS256TokenVerifiedWithPublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "jwt.key")
+	publicPath := filepath.Join(dir, "jwt.pub")
+	publicDER, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600)
+	os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644)
+
+	rsaCfg := &config.Config{JWTAlgorithm: "RS256", JWTExpiryHours: 1, JWTPrivateKeyFile: privatePath, JWTPublicKeyFile: publicPath}
+	assert.NoError(t, ValidateJWTConfig(rsaCfg))
+
+	token, err := GenerateJWTToken(&jwtTestUser, rsaCfg)
+	assert.NoError(t, err)
+
+	// Another service only needs the public key
+	parsed, err := jwt.ParseWithClaims(token, &Claims{}, func(*jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+	assert.Equal(t, jwtTestUser.ID, parsed.Claims.(*Claims).UserID)
+
+	// Signing without a private key fails instead of silently falling back to HS256
+	_, err = GenerateJWTToken(&jwtTestUser, &config.Config{JWTAlgorithm: "RS256", JWTPublicKeyFile: publicPath})
+	assert.Error(t, err)
+}
+
+func TestJWTSigningKeyRotation(t *testing.T) {
+	legacyCfg := &config.Config{JWTSecret: "legacy-secret", JWTExpiryHours: 1}
+	oldCfg := &config.Config{JWTSecret: "old-secret", JWTKeyID: "2026-01", JWTExpiryHours: 1}
+	rotatedCfg := &config.Config{
+		JWTSecret:       "new-secret",
+		JWTKeyID:        "2026-06",
+		JWTExpiryHours:  1,
+		JWTPreviousKeys: "2026-01:old-secret, legacy-secret",
+	}
+	assert.NoError(t, ValidateJWTConfig(rotatedCfg))
+	assert.Error(t, ValidateJWTConfig(&config.Config{JWTSecret: "s", JWTKeyID: "a", JWTPreviousKeys: "a:other"}))
+
+	verify := func(cfg *config.Config, token string) int {
+		verifier := fiber.New()
+		verifier.Get("/", func(c *fiber.Ctx) error {
+			if _, err := ExtractClaims(c, cfg); err != nil {
+				return AuthFailure(c, err)
+			}
+			return c.SendStatus(fiber.StatusOK)
+		})
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", token)
+		resp, err := verifier.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	newToken, err := GenerateJWTToken(&jwtTestUser, rotatedCfg)
+	assert.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-06", parsed.Header["kid"])
+
+	// Tokens signed with a retired key (or before kid headers) keep working until they expire
+	oldToken, _ := GenerateJWTToken(&jwtTestUser, oldCfg)
+	legacyToken, _ := GenerateJWTToken(&jwtTestUser, legacyCfg)
+	assert.Equal(t, fiber.StatusOK, verify(rotatedCfg, newToken))
+	assert.Equal(t, fiber.StatusOK, verify(rotatedCfg, oldToken))
+	assert.Equal(t, fiber.StatusOK, verify(rotatedCfg, legacyToken))
+
+	// Once the retired key is dropped from the list its tokens are refused
+	droppedCfg := &config.Config{JWTSecret: "new-secret", JWTKeyID: "2026-06", JWTExpiryHours: 1}
+	assert.Equal(t, fiber.StatusOK, verify(droppedCfg, newToken))
+	assert.Equal(t, fiber.StatusUnauthorized, verify(droppedCfg, oldToken))
+	assert.Equal(t, fiber.StatusUnauthorized, verify(droppedCfg, legacyToken))
+}
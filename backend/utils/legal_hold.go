@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"project/backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var ErrLegalHold = errors.New("The data is under legal hold and can't be deleted")
+
+// HeldIDs — подзапрос с ID объектов вида subjectType под действующим удержанием
+func HeldIDs(db *gorm.DB, subjectType string) *gorm.DB {
+	return db.Model(&models.LegalHold{}).Select("subject_id").
+		Where("subject_type = ? AND released_at IS NULL", subjectType)
+}
+
+// UnderLegalHold проверяет, действует ли удержание на объект
+func UnderLegalHold(db *gorm.DB, subjectType string, id uint) bool {
+	var count int64
+	db.Model(&models.LegalHold{}).
+		Where("subject_type = ? AND subject_id = ? AND released_at IS NULL", subjectType, id).
+		Count(&count)
+	return count > 0
+}
+
+// LegalHoldConflict отправляет 409 для операции, запрещенной удержанием
+func LegalHoldConflict(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+		Success: false,
+		Error:   http.StatusText(fiber.StatusConflict),
+		Code:    CodeLegalHold,
+		Message: ErrLegalHold.Error(),
+	})
+}
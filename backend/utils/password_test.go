@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewArgon2idHasher()
+
+	hash, err := hasher.Hash("password123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$"))
+
+	ok, err := hasher.Verify("password123", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, _ = hasher.Verify("wrong", hash)
+	assert.False(t, ok)
+	assert.False(t, hasher.NeedsRehash(hash))
+}
+
+func TestLegacyBcryptNeedsRehash(t *testing.T) {
+	hasher := NewArgon2idHasher()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	ok, err := hasher.Verify("password123", string(legacy))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, hasher.NeedsRehash(string(legacy)))
+}
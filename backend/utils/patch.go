@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Optional — поле входных данных, различающее «не передано», null и значение.
+// Нужно для PATCH по JSON Merge Patch (RFC 7396), где null очищает поле.
+type Optional[T any] struct {
+	Set   bool // ключ присутствовал в JSON
+	Null  bool // передан null
+	Value T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Apply записывает значение в dst.
+// В режиме merge (PATCH) null сбрасывает поле в нулевое значение, а пустые строки и 0 сохраняются как есть.
+// В режиме PUT пустое значение, как и раньше, означает «без изменений».
+func (o Optional[T]) Apply(dst *T, merge bool) bool {
+	if !o.Set {
+		return false
+	}
+
+	if o.Null || reflect.ValueOf(&o.Value).Elem().IsZero() {
+		if !merge {
+			return false
+		}
+		var zero T
+		*dst = zero
+		return true
+	}
+
+	*dst = o.Value
+	return true
+}
+
+// IsMergePatch сообщает, что запрос нужно обрабатывать по правилам JSON Merge Patch
+func IsMergePatch(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPatch
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"project/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// defaultRoles описывает базовый набор ролей и их разрешений
+var defaultRoles = map[string][]string{
+	"admin": {
+		models.PermCoursesCreate, models.PermCoursesEdit,
+		models.PermTestsCreate, models.PermTestsEdit,
+		models.PermCommentsModerate, models.PermAnalyticsView,
+		models.PermPlatformView, models.PermUsersManage, models.PermRolesManage,
+		models.PermUsersInvite, models.PermTopicsManage, models.PermUniversitiesManage,
+		models.PermCoversManage, models.PermStudentsMentor, models.PermStatusManage,
+		models.PermPlatformManage, models.PermUniversityExport, models.PermLegalHoldManage,
+		models.PermMarketplaceReview,
+	},
+	"professor": {
+		models.PermCoursesCreate, models.PermCoursesEdit,
+		models.PermTestsCreate, models.PermTestsEdit,
+		models.PermAnalyticsView, models.PermUsersInvite,
+		models.PermStudentsMentor,
+	},
+	"moderator": {
+		models.PermCommentsModerate,
+	},
+	// Assigned with a university scope: manages the organization's data, e.g. the offboarding export
+	"university_admin": {
+		models.PermUniversityExport, models.PermAnalyticsView,
+	},
+}
+
+// SeedRBAC создает базовые роли и разрешения, если их еще нет
+func SeedRBAC(db *gorm.DB) error {
+	for roleName, codes := range defaultRoles {
+		role := models.Role{Name: roleName}
+		if err := db.Where("name = ?", roleName).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+
+		var perms []models.Permission
+		for _, code := range codes {
+			perm := models.Permission{Code: code}
+			if err := db.Where("code = ?", code).FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+			perms = append(perms, perm)
+		}
+
+		if err := db.Model(&role).Association("Permissions").Append(perms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsAdmin проверяет, является ли пользователь администратором платформы
+func IsAdmin(db *gorm.DB, userID uint) bool {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+
+	var count int64
+	db.Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ? AND user_roles.deleted_at IS NULL AND roles.name = 'admin'", userID).
+		Count(&count)
+	return count > 0
+}
+
+// HasPermission проверяет наличие разрешения у пользователя в любой области
+func HasPermission(db *gorm.DB, userID uint, code string) bool {
+	if IsAdmin(db, userID) {
+		return true
+	}
+
+	var count int64
+	permissionQuery(db, userID, code).Count(&count)
+	return count > 0
+}
+
+// HasScopedPermission проверяет разрешение с учетом университета.
+// Глобальные роли (без университета) действуют везде.
+func HasScopedPermission(db *gorm.DB, userID uint, code, university string) bool {
+	if IsAdmin(db, userID) {
+		return true
+	}
+
+	var count int64
+	permissionQuery(db, userID, code).
+		Where("(COALESCE(user_roles.university, '') = '' OR user_roles.university = ?)", university).
+		Count(&count)
+	return count > 0
+}
+
+func permissionQuery(db *gorm.DB, userID uint, code string) *gorm.DB {
+	return db.Table("user_roles").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ? AND user_roles.deleted_at IS NULL AND permissions.code = ?", userID, code)
+}
+
+// Outranks сообщает, есть ли у target права, которых нет у actor: target — администратор, а actor нет,
+// или у target есть разрешение (в любой области), которого нет у actor
+func Outranks(db *gorm.DB, targetID, actorID uint) (bool, error) {
+	if IsAdmin(db, actorID) {
+		return false, nil
+	}
+	if IsAdmin(db, targetID) {
+		return true, nil
+	}
+
+	var codes []string
+	if err := db.Table("user_roles").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ? AND user_roles.deleted_at IS NULL", targetID).
+		Distinct().Pluck("permissions.code", &codes).Error; err != nil {
+		return false, err
+	}
+	for _, code := range codes {
+		if !HasPermission(db, actorID, code) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
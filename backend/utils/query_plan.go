@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// planNode — узел плана из EXPLAIN (FORMAT JSON); нужны только поля для поиска seq scan
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	PlanRows     float64    `json:"Plan Rows"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// RegisterQueryPlanGuard включает проверку планов для режима разработки: каждый SELECT,
+// выполненный через GORM, повторно прогоняется через EXPLAIN, и последовательные сканы таблиц,
+// в которых не меньше minRows строк (по статистике pg_class), пишутся в лог.
+// Один и тот же SQL логируется один раз. В продакшене не включать: каждый запрос выполняется дважды.
+func RegisterQueryPlanGuard(db *gorm.DB, logger *log.Logger, minRows int64) error {
+	var reported sync.Map
+
+	guard := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.SQL.Len() == 0 {
+			return
+		}
+		sql := tx.Statement.SQL.String()
+		if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sql)), "SELECT") {
+			return
+		}
+		if _, seen := reported.Load(sql); seen {
+			return
+		}
+
+		// The request context may already be past its query deadline
+		ctx := context.WithoutCancel(tx.Statement.Context)
+		var raw string
+		if err := tx.Statement.ConnPool.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+sql, tx.Statement.Vars...).Scan(&raw); err != nil {
+			logger.Printf("query plan guard: explain failed: %v", err)
+			return
+		}
+		var plans []struct {
+			Plan planNode `json:"Plan"`
+		}
+		if err := json.Unmarshal([]byte(raw), &plans); err != nil || len(plans) == 0 {
+			return
+		}
+
+		for _, scan := range seqScans(plans[0].Plan) {
+			var tuples float64
+			if err := tx.Statement.ConnPool.QueryRowContext(ctx,
+				"SELECT COALESCE(reltuples, 0) FROM pg_class WHERE oid = to_regclass($1)", scan.RelationName,
+			).Scan(&tuples); err != nil {
+				continue
+			}
+			if int64(tuples) < minRows {
+				continue
+			}
+			reported.Store(sql, struct{}{})
+			logger.Printf("query plan guard: Seq Scan on %s (~%d rows in table, ~%d returned) in %s: %s",
+				scan.RelationName, int64(tuples), int64(scan.PlanRows), tx.Statement.Table, sql)
+		}
+	}
+
+	return db.Callback().Query().After("gorm:query").Register("query_plan:guard", guard)
+}
+
+// seqScans собирает узлы Seq Scan из дерева плана
+func seqScans(node planNode) []planNode {
+	var result []planNode
+	if node.NodeType == "Seq Scan" {
+		result = append(result, node)
+	}
+	for _, child := range node.Plans {
+		result = append(result, seqScans(child)...)
+	}
+	return result
+}
@@ -18,6 +18,7 @@ type SuccessResponse struct {
 type ErrorResponse struct {
 	Success bool        `json:"success"`
 	Error   string      `json:"error"`
+	Code    ErrorCode   `json:"code,omitempty"`
 	Message string      `json:"message,omitempty"`
 	Details interface{} `json:"details,omitempty"`
 }
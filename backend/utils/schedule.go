@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrScheduleDate = errors.New("use YYYY-MM-DD or an RFC 3339 timestamp")
+
+// ParseScheduleDate разбирает дату начала или окончания курса. Дата без времени начинается в полночь UTC;
+// для окончания (endOfDay) она включает весь день, то есть курс закрывается в полночь следующего дня.
+// Пустая строка означает отсутствие даты.
+func ParseScheduleDate(value string, endOfDay bool) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, ErrScheduleDate
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// SchemaVersion — номер последней миграции в backend/migrations, под которую написан этот код.
+// Увеличивается в том же коммите, что добавляет миграцию.
+const SchemaVersion = 82
+
+// Режимы проверки схемы при запуске
+const (
+	// SchemaCheckCompat допускает базу, ушедшую вперед: миграции только добавляют таблицы и колонки,
+	// поэтому старые экземпляры продолжают работать, пока новые выкатываются (blue/green, rolling deploy)
+	SchemaCheckCompat = "compat"
+	// SchemaCheckStrict требует точного совпадения версий
+	SchemaCheckStrict = "strict"
+	SchemaCheckOff    = "off"
+)
+
+var (
+	ErrSchemaBehind  = errors.New("database schema is older than this build expects, run the migrations first")
+	ErrSchemaAhead   = errors.New("database schema is newer than this build expects")
+	ErrSchemaDirty   = errors.New("the last migration failed halfway and has to be fixed by hand")
+	ErrSchemaUnknown = errors.New("schema_migrations table not found, the migration version is unknown")
+)
+
+// SchemaState — версия схемы из таблицы schema_migrations, которую ведет golang-migrate
+type SchemaState struct {
+	Known   bool
+	Version int
+	Dirty   bool
+}
+
+// ReadSchemaState читает текущую версию миграций базы
+func ReadSchemaState(db *gorm.DB) (SchemaState, error) {
+	var state SchemaState
+	if !db.Migrator().HasTable("schema_migrations") {
+		return state, nil
+	}
+
+	var row struct {
+		Version int
+		Dirty   bool
+	}
+	result := db.Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&row)
+	if result.Error != nil {
+		return state, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return state, nil
+	}
+	return SchemaState{Known: true, Version: row.Version, Dirty: row.Dirty}, nil
+}
+
+// CheckSchemaCompatibility сообщает, может ли код с версией expected работать со схемой state в режиме mode
+func CheckSchemaCompatibility(state SchemaState, expected int, mode string) error {
+	if mode == SchemaCheckOff {
+		return nil
+	}
+
+	switch {
+	case !state.Known:
+		return ErrSchemaUnknown
+	case state.Dirty:
+		return fmt.Errorf("version %d: %w", state.Version, ErrSchemaDirty)
+	case state.Version < expected:
+		return fmt.Errorf("version %d, expected %d: %w", state.Version, expected, ErrSchemaBehind)
+	case state.Version > expected && mode == SchemaCheckStrict:
+		return fmt.Errorf("version %d, expected %d: %w", state.Version, expected, ErrSchemaAhead)
+	}
+	return nil
+}
+
+// CheckSchema сверяет версию схемы базы с SchemaVersion
+func CheckSchema(db *gorm.DB, mode string) (SchemaState, error) {
+	if mode == SchemaCheckOff {
+		return SchemaState{}, nil
+	}
+
+	state, err := ReadSchemaState(db)
+	if err != nil {
+		return state, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return state, CheckSchemaCompatibility(state, SchemaVersion, mode)
+}
@@ -0,0 +1,11 @@
+package utils
+
+import "strings"
+
+// likeEscaper экранирует спецсимволы LIKE обратной косой чертой (запрос должен содержать ESCAPE '\')
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ContainsPattern строит шаблон ILIKE "содержит q": % и _ из ввода ищутся как обычные символы
+func ContainsPattern(q string) string {
+	return "%" + likeEscaper.Replace(q) + "%"
+}
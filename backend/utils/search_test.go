@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainsPattern(t *testing.T) {
+	assert.Equal(t, "%ethics%", ContainsPattern("ethics"))
+	assert.Equal(t, `%100\%%`, ContainsPattern("100%"))
+	assert.Equal(t, `%snake\_case%`, ContainsPattern("snake_case"))
+	assert.Equal(t, `%C:\\Users%`, ContainsPattern(`C:\Users`))
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"project/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Допустимые значения настроек
+var (
+	SupportedLocales = []string{"en", "ru"}
+	SupportedThemes  = []string{"light", "dark", "system"}
+)
+
+// DefaultUserSettings возвращает настройки пользователя, который еще ничего не менял
+func DefaultUserSettings(userID uint) models.UserSettings {
+	return models.UserSettings{
+		UserID:             userID,
+		Locale:             "en",
+		Timezone:           "UTC",
+		Theme:              "system",
+		EmailNotifications: true,
+		EmailComments:      true,
+		EmailCourseUpdates: true,
+		EmailNudges:        true,
+		EmailBadges:        true,
+	}
+}
+
+// LoadUserSettings читает настройки пользователя; если записи нет, возвращаются значения по умолчанию
+func LoadUserSettings(db *gorm.DB, userID uint) (models.UserSettings, error) {
+	var settings models.UserSettings
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultUserSettings(userID), nil
+	}
+	return settings, err
+}
+
+// UserLocation возвращает часовой пояс пользователя (UTC, если он не задан или неизвестен)
+func UserLocation(db *gorm.DB, userID uint) *time.Location {
+	settings, err := LoadUserSettings(db, userID)
+	if err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
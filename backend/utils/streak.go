@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"project/backend/models"
+	"time"
+)
+
+// LocalDayStart возвращает начало календарного дня, в который попадает t, в часовом поясе loc
+func LocalDayStart(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// CalendarDaysBetween возвращает, сколько полуночей в часовом поясе loc лежит между from и to.
+// Даты сравниваются как календарные, поэтому переход на летнее время не сдвигает результат.
+func CalendarDaysBetween(from, to time.Time, loc *time.Location) int {
+	fy, fm, fd := from.In(loc).Date()
+	ty, tm, td := to.In(loc).Date()
+	days := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC))
+	return int(days.Hours() / 24)
+}
+
+// CurrentStreak возвращает серию дней, действующую на момент now: она прерывается,
+// если после последней активности пропущен хотя бы один целый локальный день
+func CurrentStreak(progress models.UserProgress, now time.Time, loc *time.Location) int {
+	if progress.LastActive.IsZero() || CalendarDaysBetween(progress.LastActive, now, loc) > 1 {
+		return 0
+	}
+	return progress.StreakDays
+}
+
+// NextStreak возвращает серию после активности в момент now: повторный вход в тот же
+// локальный день ее не меняет, вход на следующий день продлевает, иначе серия начинается заново
+func NextStreak(progress models.UserProgress, now time.Time, loc *time.Location) int {
+	if progress.LastActive.IsZero() {
+		return 1
+	}
+	switch days := CalendarDaysBetween(progress.LastActive, now, loc); {
+	case days <= 0:
+		if progress.StreakDays < 1 {
+			return 1
+		}
+		return progress.StreakDays
+	case days == 1:
+		return progress.StreakDays + 1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,193 @@
+// Package waitlist ведет очередь на курсы с ограниченным числом мест (CourseAccessSettings.MaxEnrollments).
+// Место занимает каждый записанный студент. Когда место освобождается, первый в очереди продвигается:
+// на бесплатный курс он записывается сразу, а на платном место придерживается за ним на HoldPeriod,
+// чтобы он успел оплатить курс. О продвижении пользователь получает письмо.
+package waitlist
+
+import (
+	"errors"
+	"fmt"
+	"project/backend/models"
+	"project/backend/outbox"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HoldPeriod — сколько места платного курса ждут оплаты продвинутого из очереди
+const HoldPeriod = 48 * time.Hour
+
+// Taken — сколько мест курса занято: записанные студенты и придержанные за очередью места
+func Taken(db *gorm.DB, courseID uint, now time.Time) (int64, error) {
+	var enrolled, held int64
+	if err := db.Model(&models.UserCourseProgress{}).Where("course_id = ?", courseID).Count(&enrolled).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&models.CourseWaitlist{}).
+		Where("course_id = ? AND offered_at > ?", courseID, now.Add(-HoldPeriod)).Count(&held).Error; err != nil {
+		return 0, err
+	}
+	return enrolled + held, nil
+}
+
+// Lock блокирует строку курса до конца транзакции. Проверка свободного места и запись студента идут
+// под этой блокировкой, иначе одновременные записи занимают одно и то же последнее место.
+func Lock(tx *gorm.DB, courseID uint) error {
+	var course models.Course
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&course, courseID).Error
+}
+
+// HasSeat сообщает, может ли пользователь записаться: мест не ограничено, место придержано за ним
+// или свободное место остается после всех, кто стоит в очереди перед ним
+func HasSeat(db *gorm.DB, settings models.CourseAccessSettings, userID uint, now time.Time) (bool, error) {
+	if settings.MaxEnrollments <= 0 {
+		return true, nil
+	}
+
+	var held int64
+	if err := db.Model(&models.CourseWaitlist{}).
+		Where("course_id = ? AND user_id = ? AND offered_at > ?", settings.CourseID, userID, now.Add(-HoldPeriod)).
+		Count(&held).Error; err != nil {
+		return false, err
+	}
+	if held > 0 {
+		return true, nil
+	}
+
+	taken, err := Taken(db, settings.CourseID, now)
+	if err != nil {
+		return false, err
+	}
+	// Newcomers don't overtake the queue while it waits for the next promotion
+	ahead, err := Position(db, settings.CourseID, userID)
+	if err != nil {
+		return false, err
+	}
+	if ahead > 0 {
+		ahead--
+	} else if err := db.Model(&models.CourseWaitlist{}).
+		Where("course_id = ? AND offered_at IS NULL", settings.CourseID).Count(&ahead).Error; err != nil {
+		return false, err
+	}
+	return taken+ahead < int64(settings.MaxEnrollments), nil
+}
+
+// Join ставит пользователя в очередь; повторный вызов оставляет его на прежнем месте
+func Join(db *gorm.DB, courseID, userID uint) (int64, error) {
+	entry := models.CourseWaitlist{CourseID: courseID, UserID: userID}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		return 0, err
+	}
+	return Position(db, courseID, userID)
+}
+
+// Position — место пользователя в очереди, начиная с 1; 0 — не в очереди или место ему уже предложено
+func Position(db *gorm.DB, courseID, userID uint) (int64, error) {
+	var entry models.CourseWaitlist
+	err := db.Where("course_id = ? AND user_id = ?", courseID, userID).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && entry.OfferedAt != nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var ahead int64
+	err = db.Model(&models.CourseWaitlist{}).
+		Where("course_id = ? AND offered_at IS NULL AND (created_at < ? OR (created_at = ? AND id < ?))",
+			courseID, entry.CreatedAt, entry.CreatedAt, entry.ID).
+		Count(&ahead).Error
+	return ahead + 1, err
+}
+
+// Leave убирает пользователя из очереди, в том числе отказывается от придержанного места
+func Leave(db *gorm.DB, courseID, userID uint) error {
+	// Hard delete so the (course, user) pair can queue again
+	return db.Unscoped().Where("course_id = ? AND user_id = ?", courseID, userID).Delete(&models.CourseWaitlist{}).Error
+}
+
+// Promote отдает свободные места курса первым в очереди. Просроченные брони платного курса снимаются,
+// а записавшиеся другим путем (по приглашению, оплатой) убираются из очереди. Возвращает число продвинутых.
+func Promote(tx *gorm.DB, course models.Course, now time.Time) (int, error) {
+	if err := tx.Unscoped().Where("course_id = ? AND (offered_at <= ? OR user_id IN (?))", course.ID, now.Add(-HoldPeriod),
+		tx.Model(&models.UserCourseProgress{}).Select("user_id").Where("course_id = ?", course.ID)).
+		Delete(&models.CourseWaitlist{}).Error; err != nil {
+		return 0, err
+	}
+
+	query := tx.Where("course_id = ? AND offered_at IS NULL", course.ID).Order("created_at, id")
+	if seats := course.AccessSettings.MaxEnrollments; seats > 0 {
+		taken, err := Taken(tx, course.ID, now)
+		if err != nil {
+			return 0, err
+		}
+		if taken >= int64(seats) {
+			return 0, nil
+		}
+		query = query.Limit(seats - int(taken))
+	}
+	var entries []models.CourseWaitlist
+	if err := query.Find(&entries).Error; err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if err := promote(tx, course, entry, now); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// PromoteAll продвигает очереди всех курсов, где кто-то ждет. Места освобождаются и без участия
+// автора (например, при удалении аккаунта), поэтому очереди проверяются периодически.
+func PromoteAll(db *gorm.DB, now time.Time) error {
+	var courseIDs []uint
+	if err := db.Model(&models.CourseWaitlist{}).Distinct().Pluck("course_id", &courseIDs).Error; err != nil {
+		return err
+	}
+	for _, courseID := range courseIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := Lock(tx, courseID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+			var course models.Course
+			if err := tx.Preload("AccessSettings").First(&course, courseID).Error; err != nil {
+				return err
+			}
+			_, err := Promote(tx, course, now)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func promote(tx *gorm.DB, course models.Course, entry models.CourseWaitlist, now time.Time) error {
+	if course.Price > 0 {
+		if err := tx.Model(&entry).Update("offered_at", now).Error; err != nil {
+			return err
+		}
+		return outbox.EnqueueNotification(tx, entry.UserID, outbox.NotifyCourseUpdates,
+			fmt.Sprintf("A seat in %s is reserved for you", course.Title),
+			fmt.Sprintf("A seat opened up in \"%s\". It is held for you until %s; buy the course before then to take it.",
+				course.Title, now.Add(HoldPeriod).UTC().Format("2006-01-02 15:04 UTC")))
+	}
+
+	progress := models.UserCourseProgress{UserID: entry.UserID, CourseID: course.ID}
+	if err := tx.Where(progress).FirstOrCreate(&progress).Error; err != nil {
+		return err
+	}
+	if err := Leave(tx, course.ID, entry.UserID); err != nil {
+		return err
+	}
+	return outbox.EnqueueNotification(tx, entry.UserID, outbox.NotifyCourseUpdates,
+		fmt.Sprintf("You are enrolled in %s", course.Title),
+		fmt.Sprintf("A seat opened up in \"%s\" and you have been enrolled from the waitlist.", course.Title))
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLessonAccessibility(t *testing.T) {
+	course := models.Course{Title: "Accessible Ethics", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.Lesson{CourseID: course.ID, Title: "Clean", SequenceOrder: 1,
+		Content: `<p>Virtue is the only good.</p><img src="stoa.png" alt="The Stoa Poikile"><img src="line.png" alt="">`})
+	db.Create(&models.Lesson{CourseID: course.ID, Title: "Broken", SequenceOrder: 2,
+		Content: `<img src="zeno.png"><p style="color: #ccc">Faint text</p><font color="yellow">Yellow</font>` +
+			`<p style="color:#fff; background-color:#000">Readable</p>![](chart.png)` +
+			"<p>" + strings.Repeat("word ", 200) + "</p>"})
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/admin/courses/%d/accessibility", course.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	data := result["data"].(map[string]interface{})
+
+	lessons := data["lessons"].([]interface{})
+	assert.Len(t, lessons, 2)
+	worst := lessons[0].(map[string]interface{})
+	assert.Equal(t, "Broken", worst["title"])
+	kinds := map[string]int{}
+	for _, issue := range worst["issues"].([]interface{}) {
+		kinds[issue.(map[string]interface{})["kind"].(string)]++
+	}
+	assert.Equal(t, map[string]int{"missing_alt": 2, "low_contrast": 2, "long_paragraph": 1}, kinds)
+	assert.Equal(t, float64(100-2*15-2*10-5), worst["score"])
+
+	clean := lessons[1].(map[string]interface{})
+	assert.Equal(t, float64(100), clean["score"])
+	assert.Empty(t, clean["issues"])
+	assert.Equal(t, float64((100+45)/2), data["score"])
+
+	// Drafts are checked before they are saved
+	status, result := postJSON(t, fmt.Sprintf("/api/admin/courses/%d/accessibility/check", course.ID),
+		map[string]interface{}{"content": `<span style="color: rgb(200, 200, 200)">pale</span>`})
+	assert.Equal(t, fiber.StatusOK, status)
+	report := result["data"].(map[string]interface{})
+	assert.Equal(t, float64(90), report["score"])
+	assert.Len(t, report["issues"], 1)
+}
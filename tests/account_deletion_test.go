@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	hash, _ := utils.Passwords.Hash("leaving123")
+	leaver := models.User{Username: "leaver", Email: "leaver@example.com", PasswordHash: hash}
+	db.Create(&leaver)
+	token, err := utils.GenerateJWTToken(&leaver, cfg)
+	assert.NoError(t, err)
+
+	comment := models.CourseComment{CourseID: 1, UserID: leaver.ID, UserName: "leaver", Text: "Goodbye"}
+	db.Create(&comment)
+	db.Create(&models.LoginHistory{UserID: leaver.ID, LoginTime: time.Now()})
+
+	deleteAccount := func(password string) int {
+		body, _ := json.Marshal(map[string]string{"password": password})
+		req := httptest.NewRequest("DELETE", "/api/user/account", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusUnauthorized, deleteAccount("wrong"))
+	assert.Equal(t, fiber.StatusOK, deleteAccount("leaving123"))
+
+	// Comments stay but no longer point to a name
+	db.First(&comment, comment.ID)
+	assert.Equal(t, "Deleted user", comment.UserName)
+
+	var logins int64
+	db.Model(&models.LoginHistory{}).Where("user_id = ?", leaver.ID).Count(&logins)
+	assert.Equal(t, int64(0), logins)
+
+	var deleted models.User
+	assert.Error(t, db.First(&deleted, leaver.ID).Error)
+	assert.NoError(t, db.Unscoped().First(&deleted, leaver.ID).Error)
+	assert.NotNil(t, deleted.PurgeAfter)
+
+	// The old token is revoked
+	profileReq := httptest.NewRequest("GET", "/api/user/profile", nil)
+	profileReq.Header.Set("Authorization", token)
+	profileResp, err := app.Test(profileReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, profileResp.StatusCode)
+
+	// After the grace period the account is purged for good
+	db.Unscoped().Model(&deleted).Update("purge_after", time.Now().Add(-time.Minute))
+	assert.NoError(t, jobs.PurgeDeletedAccounts(db)(context.Background()))
+
+	var remaining int64
+	db.Unscoped().Model(&models.User{}).Where("id = ?", leaver.ID).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+	assert.NoError(t, db.First(&comment, comment.ID).Error)
+}
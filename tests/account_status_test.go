@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBannedUserGetsForbidden(t *testing.T) {
+	student := models.User{
+		Username:     "student_banned",
+		Email:        "student_banned@example.com",
+		PasswordHash: "hash",
+	}
+	db.Where("username = ?", student.Username).FirstOrCreate(&student)
+
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	jsonData, _ := json.Marshal(map[string]interface{}{"reason": "Spam in comments", "days": 7})
+	req := httptest.NewRequest("POST", "/api/admin/users/"+strconv.Itoa(int(student.ID))+"/ban", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	profileReq := httptest.NewRequest("GET", "/api/user/profile", nil)
+	profileReq.Header.Set("Authorization", token)
+
+	profileResp, err := app.Test(profileReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, profileResp.StatusCode)
+
+	var result struct {
+		Code    string                 `json:"code"`
+		Details map[string]interface{} `json:"details"`
+	}
+	json.NewDecoder(profileResp.Body).Decode(&result)
+	assert.Equal(t, "ACCOUNT_LOCKED", result.Code)
+	assert.Equal(t, "Spam in comments", result.Details["reason"])
+}
+
+func TestStatusChangeRespectsRank(t *testing.T) {
+	var usersManage models.Permission
+	assert.NoError(t, db.Where("code = ?", models.PermUsersManage).First(&usersManage).Error)
+	support := models.Role{Name: "user_support", Permissions: []models.Permission{usersManage}}
+	db.Where("name = ?", support.Name).FirstOrCreate(&support)
+	var professor models.Role
+	assert.NoError(t, db.Where("name = ?", "professor").First(&professor).Error)
+
+	agent := models.User{Username: "support_agent", Email: "support_agent@example.com", PasswordHash: "hash"}
+	author := models.User{Username: "support_author", Email: "support_author@example.com", PasswordHash: "hash"}
+	learner := models.User{Username: "support_learner", Email: "support_learner@example.com", PasswordHash: "hash"}
+	for _, user := range []*models.User{&agent, &author, &learner} {
+		db.Where("username = ?", user.Username).FirstOrCreate(user)
+	}
+	db.Create(&models.UserRole{UserID: agent.ID, RoleID: support.ID})
+	db.Create(&models.UserRole{UserID: author.ID, RoleID: professor.ID})
+	token, err := utils.GenerateJWTToken(&agent, cfg)
+	assert.NoError(t, err)
+
+	deactivate := func(user models.User) int {
+		status, _ := sendJSONAs(t, "POST", "/api/admin/users/"+strconv.Itoa(int(user.ID))+"/deactivate", token, nil)
+		return status
+	}
+
+	// Admins and users with permissions the agent lacks are out of reach, and so is the agent
+	assert.Equal(t, fiber.StatusForbidden, deactivate(testUser))
+	assert.Equal(t, fiber.StatusForbidden, deactivate(author))
+	assert.Equal(t, fiber.StatusBadRequest, deactivate(agent))
+	assert.Equal(t, fiber.StatusOK, deactivate(learner))
+
+	db.First(&author, author.ID)
+	assert.True(t, author.Active)
+}
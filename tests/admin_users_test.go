@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminUserDirectory(t *testing.T) {
+	seeded := []models.User{
+		{Username: "dir_alice", Email: "dir_alice@example.com", PasswordHash: "hash", Group: "PHIL-1"},
+		{Username: "dir_bob", Email: "dir_bob@example.com", PasswordHash: "hash", Group: "PHIL-1"},
+		{Username: "dir_carol", Email: "dir_carol@example.com", PasswordHash: "hash", Group: "PHIL-2"},
+	}
+	for i := range seeded {
+		assert.NoError(t, db.Create(&seeded[i]).Error)
+	}
+	db.Model(&seeded[0]).UpdateColumn("created_at", time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC))
+	db.Create(&models.UserProgress{UserID: seeded[1].ID, LastActive: time.Now()})
+
+	list := func(query string) (int, struct {
+		Data  []map[string]interface{} `json:"data"`
+		Total int64                    `json:"total"`
+	}) {
+		req := httptest.NewRequest("GET", "/api/admin/users?"+query, nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		var result struct {
+			Data  []map[string]interface{} `json:"data"`
+			Total int64                    `json:"total"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, page := list("search=dir_&group=PHIL-1&sort=username&order=asc")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, int64(2), page.Total)
+	if assert.Len(t, page.Data, 2) {
+		assert.Equal(t, "dir_alice", page.Data[0]["username"])
+		assert.Equal(t, "dir_bob", page.Data[1]["username"])
+	}
+
+	status, page = list("search=dir_&registered_to=2020-03-10")
+	assert.Equal(t, fiber.StatusOK, status)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "dir_alice", page.Data[0]["username"])
+	}
+
+	status, page = list("search=dir_&active_from=" + time.Now().AddDate(0, 0, -1).Format("2006-01-02"))
+	assert.Equal(t, fiber.StatusOK, status)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "dir_bob", page.Data[0]["username"])
+	}
+
+	status, page = list("search=dir_&page=2&page_size=2&sort=username&order=asc")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, int64(3), page.Total)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "dir_carol", page.Data[0]["username"])
+	}
+
+	status, _ = list("sort=password_hash")
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = list("registered_from=yesterday")
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+}
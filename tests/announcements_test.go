@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseAnnouncements(t *testing.T) {
+	course := models.Course{Title: "Announced Logic", AuthorID: testUser.ID, AccessSettings: models.CourseAccessSettings{AccessLevel: "public"}}
+	assert.NoError(t, db.Create(&course).Error)
+	run := models.CourseRun{CourseID: course.ID, Title: "Evening cohort"}
+	db.Create(&run)
+
+	tokens := map[string]string{}
+	for _, name := range []string{"ann_daytime", "ann_evening", "ann_muted", "ann_outsider"} {
+		student := models.User{Username: name, Email: name + "@example.com", PasswordHash: "hash"}
+		assert.NoError(t, db.Create(&student).Error)
+		token, err := utils.GenerateJWTToken(&student, cfg)
+		assert.NoError(t, err)
+		tokens[name] = token
+
+		switch name {
+		case "ann_evening":
+			db.Create(&models.UserCourseProgress{UserID: student.ID, CourseID: course.ID, RunID: &run.ID})
+		case "ann_muted":
+			db.Create(&models.UserCourseProgress{UserID: student.ID, CourseID: course.ID})
+			settings := models.UserSettings{UserID: student.ID}
+			db.Create(&settings)
+			db.Model(&settings).Update("email_course_updates", false)
+		case "ann_daytime":
+			db.Create(&models.UserCourseProgress{UserID: student.ID, CourseID: course.ID})
+		}
+	}
+
+	adminPath := fmt.Sprintf("/api/admin/courses/%d/announcements", course.ID)
+	listPath := fmt.Sprintf("/api/courses/%d/announcements", course.ID)
+	queued := func(email, text string) int64 {
+		var count int64
+		db.Model(&models.OutboxMessage{}).
+			Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%"+email+"%", "%"+text+"%").
+			Count(&count)
+		return count
+	}
+
+	status, _ := sendJSONAs(t, "POST", adminPath, jwtToken, map[string]interface{}{"body": "No title"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSONAs(t, "POST", adminPath, tokens["ann_daytime"], map[string]interface{}{"title": "Students can't post"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, result := sendJSONAs(t, "POST", adminPath, jwtToken, map[string]interface{}{"title": "Exam moved", "body": "The exam is on Friday"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	// The student who turned off course updates isn't counted
+	assert.Equal(t, float64(2), result["data"].(map[string]interface{})["recipients"])
+	status, result = sendJSONAs(t, "POST", adminPath, jwtToken, map[string]interface{}{"title": "Evening room change", "run_id": run.ID})
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, float64(1), result["data"].(map[string]interface{})["recipients"])
+
+	// The requests queue one fan-out each, the relay expands them into emails
+	var fanouts []models.OutboxMessage
+	db.Where("kind = ? AND payload LIKE ?", outbox.KindFanout, "%Announced Logic%").Find(&fanouts)
+	assert.Len(t, fanouts, 2)
+	assert.Equal(t, int64(0), queued("ann_daytime@example.com", "Exam moved"))
+	deliverer := &outbox.FanoutDeliverer{DB: db}
+	for _, fanout := range fanouts {
+		assert.NoError(t, deliverer.Deliver(context.Background(), fanout))
+	}
+
+	// Emails respect the course updates setting and the target run
+	assert.Equal(t, int64(1), queued("ann_daytime@example.com", "Exam moved"))
+	assert.Equal(t, int64(0), queued("ann_daytime@example.com", "Evening room change"))
+	assert.Equal(t, int64(1), queued("ann_evening@example.com", "Evening room change"))
+	assert.Equal(t, int64(0), queued("ann_muted@example.com", "Exam moved"))
+
+	titles := func(token string) []string {
+		status, result := sendJSONAs(t, "GET", listPath, token, nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		var list []string
+		for _, item := range result["data"].([]interface{}) {
+			list = append(list, item.(map[string]interface{})["title"].(string))
+		}
+		return list
+	}
+	assert.Equal(t, []string{"Exam moved"}, titles(tokens["ann_daytime"]))
+	assert.Equal(t, []string{"Evening room change", "Exam moved"}, titles(tokens["ann_evening"]))
+	assert.Equal(t, []string{"Evening room change", "Exam moved"}, titles(jwtToken))
+
+	status, _ = sendJSONAs(t, "GET", listPath, tokens["ann_outsider"], nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	var announcement models.Announcement
+	db.Where("course_id = ? AND title = ?", course.ID, "Exam moved").First(&announcement)
+	status, _ = sendJSONAs(t, "DELETE", fmt.Sprintf("%s/%d", adminPath, announcement.ID), jwtToken, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	assert.Empty(t, titles(tokens["ann_daytime"]))
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/metrics"
+	"project/backend/models"
+	"project/backend/outbox"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalyAlerts(t *testing.T) {
+	windowEnd := time.Now().UTC().Truncate(time.Hour)
+	bucket := func(name string, hoursAgo int, value int64) {
+		db.Create(&models.MetricBucket{Name: name, Bucket: windowEnd.Add(-time.Duration(hoursAgo) * time.Hour), Value: value})
+	}
+
+	// A quiet day: 1000 requests an hour with 0.5% errors and a couple of failed logins
+	for hour := 2; hour <= 25; hour++ {
+		bucket(metrics.Requests, hour, 1000)
+		bucket(metrics.ServerErrors, hour, 5)
+		bucket(metrics.FailedLogins, hour, 2)
+	}
+	// The last hour: a fifth of the requests fail, failed logins jump
+	bucket(metrics.Requests, 1, 1000)
+	bucket(metrics.ServerErrors, 1, 200)
+	bucket(metrics.FailedLogins, 1, 150)
+
+	detect := jobs.DetectAnomalies(db, cfg)
+	assert.NoError(t, detect(context.Background()))
+	// Running again for the same window doesn't alert twice
+	assert.NoError(t, detect(context.Background()))
+
+	var alerts []models.PlatformAlert
+	db.Where("window_end = ?", windowEnd).Order("metric").Find(&alerts)
+	if assert.Len(t, alerts, 2) {
+		assert.Equal(t, models.AlertErrorRateSurge, alerts[0].Metric)
+		assert.InDelta(t, 0.2, alerts[0].Value, 0.001)
+		assert.Equal(t, models.AlertFailedLoginSpike, alerts[1].Metric)
+		assert.Equal(t, float64(150), alerts[1].Value)
+	}
+
+	var emails int64
+	db.Model(&models.OutboxMessage{}).
+		Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%"+testUser.Email+"%", "%Platform anomaly%").
+		Count(&emails)
+	assert.Equal(t, int64(2), emails)
+
+	req := httptest.NewRequest("GET", "/api/analytics/alerts?metric="+models.AlertFailedLoginSpike, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var result struct {
+		Data []models.PlatformAlert `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Len(t, result.Data, 1)
+}
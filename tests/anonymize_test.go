@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"project/backend/anonymize"
+	"project/backend/integrity"
+	"project/backend/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAnonymizeStaging(t *testing.T) {
+	s := anonymize.NewScrambler("staging-secret")
+	assert.Equal(t, s.Email("Ann@Example.com"), s.Email("ann@example.com"))
+	assert.NotEqual(t, s.Email("ann@example.com"), anonymize.NewScrambler("other").Email("ann@example.com"))
+	assert.True(t, strings.HasSuffix(s.InstitutionalEmail("ann@msu.ru"), "@msu.ru"))
+	assert.NotNil(t, net.ParseIP(s.IP("2001:db8::1")).To16())
+	assert.Nil(t, net.ParseIP(s.IP("2001:db8::1")).To4())
+	assert.True(t, strings.HasPrefix(s.IP("203.0.113.7"), "10."))
+
+	_, err := anonymize.Run(context.Background(), db, anonymize.Options{})
+	assert.ErrorIs(t, err, anonymize.ErrSecretRequired)
+
+	// Everything runs in a transaction that is rolled back, the other tests keep their data
+	errRollback := errors.New("rollback")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		user := models.User{Username: "real_person", Email: "real.person@example.com", PasswordHash: "x", AvatarURL: "/uploads/face.png"}
+		tx.Create(&user)
+		tx.Create(&models.CourseComment{CourseID: 1, UserID: user.ID, UserName: user.Username, Text: "Great course"})
+		tx.Create(&models.LoginHistory{UserID: user.ID, LoginTime: time.Now(), IP: "203.0.113.7", UserAgent: "Mozilla/5.0 (Real Laptop)", Success: true})
+		tx.Create(&models.DeprecatedRouteCall{Route: "GET /api/anonymized/", Client: "anonymous", Day: time.Now(), Calls: 1,
+			UserAgent: "Mozilla/5.0 (Real Laptop)", LastCalledAt: time.Now()})
+		tx.Create(&models.OutboxMessage{Kind: "email", Payload: `{"to":"real.person@example.com"}`, NextAttemptAt: time.Now()})
+		session := models.ExamSession{TestID: 1, Title: "Anonymized exam", StartsAt: time.Now(), EndsAt: time.Now()}
+		tx.Create(&session)
+		tx.Create(&models.ExamAttempt{SessionID: session.ID, UserID: user.ID, StartedAt: time.Now(), StartIP: "203.0.113.7", SubmitIP: "198.51.100.9"})
+		integrityReport, _ := json.Marshal(integrity.Report{SessionID: session.ID, IPClusters: []integrity.IPCluster{
+			{IP: "203.0.113.7", Users: []integrity.UserRef{{UserID: user.ID, Username: user.Username}}},
+		}})
+		tx.Create(&models.ExamIntegrityReport{SessionID: session.ID, Report: string(integrityReport), Flagged: 1})
+		// Addresses that differ only in case scramble alike but must not collide in the unique index
+		twin := models.User{Username: "real_person_twin", Email: "Real.Person@example.com", PasswordHash: "x"}
+		tx.Create(&twin)
+
+		report, err := anonymize.Run(context.Background(), tx, anonymize.Options{Secret: "staging-secret", Password: "staging"})
+		if !assert.NoError(t, err) {
+			return errRollback
+		}
+		assert.Positive(t, report["users.email"])
+
+		var stored models.User
+		tx.First(&stored, user.ID)
+		assert.Equal(t, s.Email("real.person@example.com"), stored.Email)
+		assert.Equal(t, s.Username("real_person"), stored.Username)
+		var storedTwin models.User
+		tx.First(&storedTwin, twin.ID)
+		assert.NotEqual(t, stored.Email, storedTwin.Email)
+		assert.Contains(t, storedTwin.Email, "@example.invalid")
+		assert.Empty(t, stored.AvatarURL)
+		assert.NotEqual(t, "x", stored.PasswordHash)
+
+		// Copies of the username stay consistent with the user
+		var comment models.CourseComment
+		tx.Where("user_id = ?", user.ID).First(&comment)
+		assert.Equal(t, stored.Username, comment.UserName)
+		assert.Equal(t, "Great course", comment.Text)
+
+		var login models.LoginHistory
+		tx.Where("user_id = ?", user.ID).First(&login)
+		assert.Equal(t, s.IP("203.0.113.7"), login.IP)
+		assert.Equal(t, s.UserAgent("Mozilla/5.0 (Real Laptop)"), login.UserAgent)
+		var call models.DeprecatedRouteCall
+		tx.Where("route = ?", "GET /api/anonymized/").First(&call)
+		assert.Equal(t, login.UserAgent, call.UserAgent)
+
+		// Exam attempts and the integrity report built from them get the same replacements
+		var attempt models.ExamAttempt
+		tx.Where("session_id = ?", session.ID).First(&attempt)
+		assert.Equal(t, s.IP("203.0.113.7"), attempt.StartIP)
+		assert.Equal(t, s.IP("198.51.100.9"), attempt.SubmitIP)
+		var storedReport models.ExamIntegrityReport
+		tx.Where("session_id = ?", session.ID).First(&storedReport)
+		assert.NotContains(t, storedReport.Report, "203.0.113.7")
+		assert.NotContains(t, storedReport.Report, "real_person")
+		var scrubbed integrity.Report
+		assert.NoError(t, json.Unmarshal([]byte(storedReport.Report), &scrubbed))
+		if assert.Len(t, scrubbed.IPClusters, 1) {
+			assert.Equal(t, attempt.StartIP, scrubbed.IPClusters[0].IP)
+			assert.Equal(t, stored.Username, scrubbed.IPClusters[0].Users[0].Username)
+		}
+
+		var queued int64
+		tx.Model(&models.OutboxMessage{}).Where("payload LIKE ?", "%real.person%").Count(&queued)
+		assert.Zero(t, queued)
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+
+	var leaked int64
+	db.Model(&models.User{}).Where("username = ?", "real_person").Count(&leaked)
+	assert.Zero(t, leaked)
+}
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiKeyAuthentication(t *testing.T) {
+	jsonData, _ := json.Marshal(map[string]string{"name": "LMS integration"})
+
+	req := httptest.NewRequest("POST", "/api/user/api-keys", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	apiKey := result["data"].(map[string]interface{})["key"].(string)
+	assert.NotEmpty(t, apiKey)
+
+	// The key authenticates requests on behalf of the owner
+	profileReq := httptest.NewRequest("GET", "/api/user/profile", nil)
+	profileReq.Header.Set("X-API-Key", apiKey)
+
+	profileResp, err := app.Test(profileReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, profileResp.StatusCode)
+
+	// Unknown keys are rejected
+	badReq := httptest.NewRequest("GET", "/api/user/profile", nil)
+	badReq.Header.Set("X-API-Key", "pk_invalid")
+
+	badResp, err := app.Test(badReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, badResp.StatusCode)
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/config"
+	"project/backend/jobs"
+	"project/backend/middleware"
+	"project/backend/models"
+	"project/backend/usage"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiUsage(t *testing.T) {
+	// A client with a revoked key hammering one route: the first 20 requests are rejected by the handler,
+	// the other 10 by the rate limiter
+	keyID := uint(1_000_000 + time.Now().UnixNano()%1_000_000)
+	consumer := fmt.Sprintf("api_key:%d", keyID)
+
+	probe := fiber.New()
+	probe.Use(middleware.UsageMiddleware(probe))
+	probe.Use(func(c *fiber.Ctx) error {
+		c.Locals("api_key_id", keyID)
+		return c.Next()
+	})
+	probe.Use(middleware.RateLimitMiddleware(&config.Config{RateLimitMax: 20, RateLimitWindowSeconds: 60}, nil))
+	probe.Get("/api/probe/:id", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusUnauthorized).SendString("revoked")
+	})
+	for i := 0; i < 30; i++ {
+		resp, err := probe.Test(httptest.NewRequest("GET", fmt.Sprintf("/api/probe/%d", i), nil))
+		assert.NoError(t, err)
+		if i < 20 {
+			assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+		} else {
+			assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+		}
+	}
+
+	// The report flushes the counters first
+	req := httptest.NewRequest("GET", "/api/admin/api-usage?flagged=true&sort=rate_limited&limit=500", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var consumers struct {
+		Data []usage.ConsumerReport `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	json.NewDecoder(resp.Body).Decode(&consumers)
+	assert.NotNil(t, consumers.Meta["rate_limits"])
+
+	var report *usage.ConsumerReport
+	for i := range consumers.Data {
+		if consumers.Data[i].Consumer == consumer {
+			report = &consumers.Data[i]
+		}
+	}
+	if assert.NotNil(t, report) {
+		assert.Equal(t, int64(30), report.Requests)
+		assert.Equal(t, int64(20), report.AuthFailures)
+		assert.Equal(t, int64(10), report.RateLimited)
+		assert.Equal(t, int64(30), report.PeakPerMinute)
+		assert.Equal(t, 2, report.Routes)
+		assert.InDelta(t, 1.0, report.ErrorRate, 0.001)
+		assert.ElementsMatch(t, []string{usage.FlagRateLimited, usage.FlagAuthFailures}, report.Flags)
+	}
+
+	// Per-route breakdown of the consumer: the requests the limiter turned away never reached a route
+	req = httptest.NewRequest("GET", "/api/admin/api-usage/endpoints?consumer="+consumer, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var endpoints struct {
+		Data []usage.EndpointReport `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&endpoints)
+	if assert.Len(t, endpoints.Data, 2) {
+		assert.Equal(t, "GET /api/probe/:id", endpoints.Data[0].Route)
+		assert.Equal(t, int64(20), endpoints.Data[0].AuthFailures)
+		assert.Equal(t, "(unmatched)", endpoints.Data[1].Route)
+		assert.Equal(t, int64(10), endpoints.Data[1].RateLimited)
+	}
+
+	req = httptest.NewRequest("GET", "/api/admin/api-usage?sort=latency", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestApiUsagePeakAndRetention(t *testing.T) {
+	consumer := fmt.Sprintf("api_key:%d", 2_000_000+time.Now().UnixNano()%1_000_000)
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	// 5 requests in one minute and 3 in the next, recorded across three flushes:
+	// the peak is the busiest minute by request time, not the requests per flush
+	for i := 0; i < 5; i++ {
+		usage.Record(consumer, "GET /api/probe", fiber.StatusOK, hour.Add(time.Minute+time.Duration(i)*time.Second))
+	}
+	assert.NoError(t, usage.Flush(context.Background(), db))
+	assert.NoError(t, usage.Flush(context.Background(), db))
+	for i := 0; i < 3; i++ {
+		usage.Record(consumer, "GET /api/probe", fiber.StatusOK, hour.Add(2*time.Minute))
+	}
+	assert.NoError(t, usage.Flush(context.Background(), db))
+
+	var bucket models.ApiUsageBucket
+	assert.NoError(t, db.Where("consumer = ?", consumer).First(&bucket).Error)
+	assert.Equal(t, int64(8), bucket.Requests)
+	assert.Equal(t, int64(5), bucket.PeakPerMinute)
+
+	// Rows past the retention window are removed
+	old := models.ApiUsageBucket{Consumer: consumer, Route: "GET /api/probe", Hour: hour.AddDate(0, 0, -40), Requests: 1}
+	db.Create(&old)
+	call := models.DeprecatedRouteCall{Route: "GET /api/probe", Client: consumer, Day: hour.AddDate(0, 0, -40), Calls: 1}
+	db.Create(&call)
+	assert.NoError(t, jobs.PurgeUsageStats(db, &config.Config{UsageRetentionDays: 30})(context.Background()))
+
+	var count int64
+	db.Unscoped().Model(&models.ApiUsageBucket{}).Where("consumer = ?", consumer).Count(&count)
+	assert.Equal(t, int64(1), count)
+	db.Unscoped().Model(&models.DeprecatedRouteCall{}).Where("client = ?", consumer).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
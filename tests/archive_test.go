@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/storage"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveInactiveUser(t *testing.T) {
+	previous := storage.Archive
+	storage.Archive = storage.NewDisk(t.TempDir(), "")
+	defer func() { storage.Archive = previous }()
+
+	longAgo := time.Now().AddDate(-2, 0, 0)
+	dormant := models.User{Username: "dormant", Email: "dormant@example.com", PasswordHash: "hash"}
+	db.Create(&dormant)
+	db.Model(&dormant).UpdateColumn("created_at", longAgo)
+	db.Create(&models.LoginHistory{UserID: dormant.ID, LoginTime: longAgo})
+	db.Create(&models.UserCourseProgress{UserID: dormant.ID, CourseID: 1, CompletionRate: 40})
+
+	// Admins granted through RBAC, integrations using an API key and held accounts are kept
+	var adminRole models.Role
+	db.Where("name = ?", "admin").First(&adminRole)
+	rbacAdmin := models.User{Username: "dormant_rbac_admin", Email: "dormant_rbac_admin@example.com", PasswordHash: "hash"}
+	integration := models.User{Username: "dormant_integration", Email: "dormant_integration@example.com", PasswordHash: "hash"}
+	held := models.User{Username: "dormant_held", Email: "dormant_held@example.com", PasswordHash: "hash"}
+	for _, user := range []*models.User{&rbacAdmin, &integration, &held} {
+		db.Create(user)
+		db.Model(user).UpdateColumn("created_at", longAgo)
+	}
+	db.Create(&models.UserRole{UserID: rbacAdmin.ID, RoleID: adminRole.ID})
+	recently := time.Now().Add(-time.Hour)
+	db.Create(&models.ApiKey{UserID: integration.ID, Name: "LMS", Prefix: "dormant", KeyHash: fmt.Sprintf("dormant-%d", integration.ID), LastUsedAt: &recently})
+	db.Create(&models.LegalHold{SubjectType: models.HoldSubjectUser, SubjectID: held.ID, Reason: "Litigation", AppliedBy: testUser.ID})
+
+	body, _ := json.Marshal(map[string]interface{}{"inactive_days": 365})
+	req := httptest.NewRequest("POST", "/api/admin/archives", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data struct {
+			Archived []uint `json:"archived"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Contains(t, result.Data.Archived, dormant.ID)
+	assert.NotContains(t, result.Data.Archived, testUser.ID)
+	assert.NotContains(t, result.Data.Archived, rbacAdmin.ID)
+	assert.NotContains(t, result.Data.Archived, integration.ID)
+	assert.NotContains(t, result.Data.Archived, held.ID)
+
+	// Activity data left the primary database
+	var remaining int64
+	db.Model(&models.UserCourseProgress{}).Where("user_id = ?", dormant.ID).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+	db.First(&dormant, dormant.ID)
+	assert.NotNil(t, dormant.ArchivedAt)
+
+	// Activity while archived survives the restore
+	db.Create(&models.LoginHistory{UserID: dormant.ID, LoginTime: time.Now(), Success: true})
+	db.Create(&models.UserCourseProgress{UserID: dormant.ID, CourseID: 2, CompletionRate: 10})
+
+	restoreReq := httptest.NewRequest("POST", fmt.Sprintf("/api/admin/users/%d/restore", dormant.ID), nil)
+	restoreReq.Header.Set("Authorization", jwtToken)
+	restoreResp, err := app.Test(restoreReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, restoreResp.StatusCode)
+
+	var progress []models.UserCourseProgress
+	db.Where("user_id = ?", dormant.ID).Order("course_id").Find(&progress)
+	if assert.Len(t, progress, 2) {
+		assert.Equal(t, float64(40), progress[0].CompletionRate)
+		assert.Equal(t, float64(10), progress[1].CompletionRate)
+	}
+	var logins int64
+	db.Model(&models.LoginHistory{}).Where("user_id = ?", dormant.ID).Count(&logins)
+	assert.Equal(t, int64(2), logins)
+	db.First(&dormant, dormant.ID)
+	assert.Nil(t, dormant.ArchivedAt)
+
+	// Restoring twice is rejected
+	again := httptest.NewRequest("POST", fmt.Sprintf("/api/admin/users/%d/restore", dormant.ID), nil)
+	again.Header.Set("Authorization", jwtToken)
+	againResp, err := app.Test(again)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, againResp.StatusCode)
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttemptAnswerAutosave(t *testing.T) {
+	test := models.Test{
+		Title:    "Autosaved Quiz",
+		AuthorID: testUser.ID,
+		Questions: []models.TestQuestion{
+			{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1, SequenceOrder: 1},
+			{Question: "Q2", Options: `["a","b"]`, CorrectAnswer: 0, SequenceOrder: 2},
+			{Question: "Blitz", Options: `["a","b"]`, CorrectAnswer: 0, SequenceOrder: 3, TimeLimitSeconds: 30},
+		},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 2},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+	q1, q2, blitz := test.Questions[0], test.Questions[1], test.Questions[2]
+
+	student := models.User{Username: "autosave_student", Email: "autosave_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	send := func(method, path string, payload interface{}) (int, map[string]interface{}) {
+		return sendJSONAs(t, method, path, token, payload)
+	}
+	answerPath := func(attempt int, question models.TestQuestion) string {
+		return fmt.Sprintf("/api/tests/%d/attempts/%d/answers/%d", test.ID, attempt, question.ID)
+	}
+
+	status, _ := send("PUT", answerPath(1, q1), map[string]int{"answer": 0})
+	assert.Equal(t, fiber.StatusOK, status)
+	// A later save replaces the earlier one
+	status, _ = send("PUT", answerPath(1, q1), map[string]int{"answer": 1})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = send("PUT", answerPath(1, q2), map[string]int{"answer": 1})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, _ = send("PUT", answerPath(1, blitz), map[string]int{"answer": 0})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = send("PUT", answerPath(2, q1), map[string]int{"answer": 0})
+	assert.Equal(t, fiber.StatusConflict, status)
+	status, _ = send("PUT", answerPath(1, q1), map[string]int{})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	// After a reconnect the client restores its answers
+	status, saved := send("GET", fmt.Sprintf("/api/tests/%d/attempts/1/answers", test.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, saved["data"].(map[string]interface{})["answers"], 2)
+
+	// The submit overrides Q2 and finalizes the saved Q1
+	status, _ = send("POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), map[string]interface{}{
+		"answers": []map[string]interface{}{{"question_id": q2.ID, "answer": 0}},
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var progress models.UserTestProgress
+	db.Where("user_id = ? AND test_id = ?", student.ID, test.ID).First(&progress)
+	assert.Equal(t, 2, progress.QuestionsAnswered)
+	assert.Equal(t, 2, progress.CorrectAnswers)
+
+	var left int64
+	db.Model(&models.AttemptAnswer{}).Where("user_id = ? AND test_id = ?", student.ID, test.ID).Count(&left)
+	assert.Equal(t, int64(0), left)
+	status, _ = send("PUT", answerPath(1, q1), map[string]int{"answer": 0})
+	assert.Equal(t, fiber.StatusConflict, status)
+}
+
+func TestActiveAttemptRecovery(t *testing.T) {
+	test := models.Test{
+		Title:    "Recovered Exam",
+		AuthorID: testUser.ID,
+		Questions: []models.TestQuestion{
+			{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1, SequenceOrder: 1},
+			{Question: "Blitz", Options: `["a","b"]`, CorrectAnswer: 0, SequenceOrder: 2, TimeLimitSeconds: 60},
+		},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 2, MaxPauseMinutes: 15},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+	q1, blitz := test.Questions[0], test.Questions[1]
+
+	student := models.User{Username: "recovery_student", Email: "recovery_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	testPath := fmt.Sprintf("/api/tests/%d", test.ID)
+
+	status, _ := sendJSONAs(t, "GET", testPath+"/attempts/active", token, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/admin/tests/%d/sessions", test.ID), jwtToken, map[string]interface{}{
+		"starts_at":        time.Now().Add(-time.Minute),
+		"ends_at":          time.Now().Add(2 * time.Hour),
+		"duration_minutes": 60,
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = sendJSONAs(t, "GET", testPath, token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("%s/attempts/1/answers/%d", testPath, q1.ID), token, map[string]int{"answer": 1})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("%s/questions/%d/serve", testPath, blitz.ID), token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, paused := sendJSONAs(t, "POST", testPath+"/pause", token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// The browser crashed: everything needed to continue comes back in one call
+	status, active := sendJSONAs(t, "GET", testPath+"/attempts/active", token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	data := active["data"].(map[string]interface{})
+	assert.Equal(t, float64(1), data["attempt"])
+	assert.Len(t, data["answers"], 1)
+	served := data["served"].([]interface{})
+	assert.Len(t, served, 1)
+	assert.Greater(t, served[0].(map[string]interface{})["seconds_left"], float64(0))
+	exam := data["exam"].(map[string]interface{})
+	assert.Equal(t, "paused", exam["status"])
+	assert.Equal(t, paused["data"].(map[string]interface{})["resume_token"], exam["resume_token"])
+	assert.Greater(t, exam["seconds_left"], float64(3000))
+
+	status, _ = sendJSONAs(t, "POST", testPath+"/resume", token, map[string]interface{}{"resume_token": exam["resume_token"]})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", testPath+"/progress", token, map[string]interface{}{"answers": []interface{}{}})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, _ = sendJSONAs(t, "GET", testPath+"/attempts/active", token, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthErrorCodes(t *testing.T) {
+	expired := jwt.NewWithClaims(jwt.SigningMethodHS256, utils.Claims{
+		UserID: testUser.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		},
+	})
+	expiredToken, _ := expired.SignedString([]byte(cfg.JWTSecret))
+
+	cases := map[string]struct {
+		token string
+		code  utils.ErrorCode
+	}{
+		"missing": {"", utils.CodeTokenMissing},
+		"expired": {expiredToken, utils.CodeTokenExpired},
+		"invalid": {"not-a-jwt", utils.CodeTokenInvalid},
+	}
+
+	for name, tc := range cases {
+		req := httptest.NewRequest("GET", "/api/user/profile", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", tc.token)
+		}
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err, name)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, name)
+
+		var result utils.ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		assert.Equal(t, tc.code, result.Code, name)
+	}
+}
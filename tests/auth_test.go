@@ -2,11 +2,16 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
+	"log"
 	"net/http/httptest"
 	"os"
 	"project/backend/config"
 	"project/backend/controllers"
+	"project/backend/events"
+	"project/backend/middleware"
 	"project/backend/models"
 	"project/backend/routes"
 	"project/backend/utils"
@@ -26,6 +31,101 @@ var (
 	jwtToken string
 )
 
+// testModels are migrated by setup and dropped by teardown; new models go here only
+var testModels = []interface{}{
+	&models.User{},
+	&models.UserProgress{},
+	&models.LoginHistory{},
+	&models.Course{},
+	&models.Lesson{},
+	&models.CourseComment{},
+	&models.CourseCommentReply{},
+	&models.CourseAccessSettings{},
+	&models.UserCourseProgress{},
+	&models.Test{},
+	&models.TestQuestion{},
+	&models.TestComment{},
+	&models.TestCommentReply{},
+	&models.TestAccessSettings{},
+	&models.UserTestProgress{},
+	&models.Role{},
+	&models.Permission{},
+	&models.UserRole{},
+	&models.OutboxMessage{},
+	&models.ApiKey{},
+	&models.Invitation{},
+	&models.ExportJob{},
+	&models.UserFollow{},
+	&models.Topic{},
+	&models.University{},
+	&models.AffiliationVerification{},
+	&models.StockCover{},
+	&models.UserSettings{},
+	&models.UserArchive{},
+	&models.TestRanking{},
+	&models.CourseStaff{},
+	&models.StudyGroup{},
+	&models.StudyGroupMember{},
+	&models.ExamSession{},
+	&models.ExamSessionProctor{},
+	&models.ExamAttempt{},
+	&models.CourseSurvey{},
+	&models.CourseSurveyResponse{},
+	&models.CourseCertificate{},
+	&models.UserActivity{},
+	&models.CourseGradeComponent{},
+	&models.CourseGradeEntry{},
+	&models.CourseGrade{},
+	&models.GradeScale{},
+	&models.CourseSISMapping{},
+	&models.UserProgressSnapshot{},
+	&models.MentorLink{},
+	&models.EmailChange{},
+	&models.MetricBucket{},
+	&models.PlatformAlert{},
+	&models.StatusIncident{},
+	&models.CourseModule{},
+	&models.PlatformSetting{},
+	&models.CourseVersion{},
+	&models.LegalHold{},
+	&models.MarketplaceListing{},
+	&models.MarketplaceImport{},
+	&models.CourseAccessList{},
+	&models.CourseConduct{},
+	&models.CourseConductAcknowledgement{},
+	&models.Tag{},
+	&models.CourseTag{},
+	&models.TestTag{},
+	&models.ContentEmbedding{},
+	&models.ContentSimilarity{},
+	&models.TestQuestionTranslation{},
+	&models.Order{},
+	&models.CourseRun{},
+	&models.Announcement{},
+	&models.QuestionServe{},
+	&models.Bookmark{},
+	&models.AttemptAnswer{},
+	&models.CourseWaitlist{},
+	&models.Review{},
+	&models.ExamIntegrityReport{},
+	&models.OAuthClient{},
+	&models.OAuthCode{},
+	&models.DeprecatedRouteCall{},
+	&models.LessonBlock{},
+	&models.ApiUsageBucket{},
+	&models.LessonAttachment{},
+	&models.QuotaWarning{},
+	&models.Campaign{},
+	&models.CampaignDelivery{},
+	&models.StudyCircle{},
+	&models.StudyCircleMember{},
+	&models.CircleChallenge{},
+	&models.CircleBadge{},
+	&models.LessonDraft{},
+	&models.ScheduledJob{},
+	&models.PresenceMark{},
+}
+
 func TestMain(m *testing.M) {
 	// Setup
 	setup()
@@ -56,27 +156,20 @@ func setup() {
 	}
 
 	// Migrate test database
-	db.AutoMigrate(
-		&models.User{},
-		&models.UserProgress{},
-		&models.LoginHistory{},
-		&models.Course{},
-		&models.Lesson{},
-		&models.CourseComment{},
-		&models.CourseAccessSettings{},
-		&models.UserCourseProgress{},
-		&models.Test{},
-		&models.TestQuestion{},
-		&models.TestComment{},
-		&models.TestAccessSettings{},
-		&models.UserTestProgress{},
-	)
+	db.AutoMigrate(testModels...)
+	if err := utils.SeedRBAC(db); err != nil {
+		panic(err)
+	}
 
 	// Create test app
 	app = fiber.New()
+	app.Use(middleware.ReadOnlyMiddleware())
 	authCtrl = controllers.NewAuthController(db, cfg)
 	routes.SetupRoutes(app, db, cfg)
 
+	// Live events go through Postgres, as they do between instances
+	go events.Listen(context.Background(), db, events.Default, log.New(io.Discard, "", 0))
+
 	// Create test user
 	testUser = models.User{
 		Username:     "testuser",
@@ -84,25 +177,17 @@ func setup() {
 		PasswordHash: "$2a$10$XvgWZzX7J6ybBp5nD5vQj.9vqJZJQ7Q8QJZJQ7Q8QJZJQ7Q8QJZJQ7Q8", // "password"
 	}
 	db.Create(&testUser)
+
+	// Test user manages courses and tests through the admin endpoints
+	var adminRole models.Role
+	db.Where("name = ?", "admin").First(&adminRole)
+	db.Create(&models.UserRole{UserID: testUser.ID, RoleID: adminRole.ID})
 }
 
 func teardown() {
 	// Clean up test database
-	db.Migrator().DropTable(
-		&models.User{},
-		&models.UserProgress{},
-		&models.LoginHistory{},
-		&models.Course{},
-		&models.Lesson{},
-		&models.CourseComment{},
-		&models.CourseAccessSettings{},
-		&models.UserCourseProgress{},
-		&models.Test{},
-		&models.TestQuestion{},
-		&models.TestComment{},
-		&models.TestAccessSettings{},
-		&models.UserTestProgress{},
-	)
+	// The many2many join table of roles has no model of its own
+	db.Migrator().DropTable(append(testModels, "role_permissions")...)
 }
 
 func TestRegister(t *testing.T) {
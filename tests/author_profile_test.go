@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorProfile(t *testing.T) {
+	author := models.User{Username: "author_kant", Email: "kant@example.com", PasswordHash: "x", Active: true}
+	db.Create(&author)
+
+	published := models.Course{Title: "Critique of Pure Reason", AuthorID: author.ID}
+	draft := models.Course{Title: "Unpublished Draft", AuthorID: author.ID}
+	db.Create(&published)
+	db.Create(&draft)
+	db.Create(&models.CourseAccessSettings{CourseID: published.ID, AccessLevel: "public"})
+	db.Create(&models.CourseAccessSettings{CourseID: draft.ID, AccessLevel: "private"})
+	db.Create(&models.Review{CourseID: published.ID, UserID: testUser.ID, Rating: 4, Text: "Great"})
+
+	url := "/api/users/" + strconv.Itoa(int(author.ID))
+	followReq := httptest.NewRequest("POST", url+"/follow", nil)
+	followReq.Header.Set("Authorization", jwtToken)
+	followResp, err := app.Test(followReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, followResp.StatusCode)
+
+	req := httptest.NewRequest("GET", url+"/profile", nil)
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	assert.Equal(t, "author_kant", result.Data["username"])
+	assert.NotContains(t, result.Data, "email")
+	assert.Len(t, result.Data["courses"], 1)
+	assert.Equal(t, float64(4), result.Data["rating"])
+	assert.Equal(t, float64(1), result.Data["followers"])
+	assert.Equal(t, true, result.Data["is_following"])
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"project/backend/models"
+	"project/backend/storage"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadAvatar(t *testing.T) {
+	dir := t.TempDir()
+	previous := storage.Default
+	storage.Default = storage.NewDisk(dir, "/uploads")
+	defer func() { storage.Default = previous }()
+
+	comment := models.CourseComment{CourseID: 1, UserID: testUser.ID, UserName: "testuser", Text: "Before avatar"}
+	db.Create(&comment)
+
+	src := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for x := 0; x < 640; x++ {
+		for y := 0; y < 480; y++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var img bytes.Buffer
+	png.Encode(&img, src)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("avatar", "me.png")
+	part.Write(img.Bytes())
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/user/avatar", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data struct {
+			AvatarURL string `json:"avatar_url"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.True(t, strings.HasPrefix(result.Data.AvatarURL, "/uploads/avatars/"))
+
+	// Stored file is cropped and resized to a square
+	stored, err := os.Open(filepath.Join(dir, strings.TrimPrefix(result.Data.AvatarURL, "/uploads/")))
+	if assert.NoError(t, err) {
+		defer stored.Close()
+		cfg, _, err := image.DecodeConfig(stored)
+		assert.NoError(t, err)
+		assert.Equal(t, 256, cfg.Width)
+		assert.Equal(t, 256, cfg.Height)
+	}
+
+	// Existing comments pick up the new avatar
+	db.First(&comment, comment.ID)
+	assert.Equal(t, result.Data.AvatarURL, comment.UserImage)
+
+	// Non-images are rejected
+	var bad bytes.Buffer
+	badForm := multipart.NewWriter(&bad)
+	badPart, _ := badForm.CreateFormFile("avatar", "notes.txt")
+	badPart.Write([]byte("definitely not an image"))
+	badForm.Close()
+
+	badReq := httptest.NewRequest("POST", "/api/user/avatar", &bad)
+	badReq.Header.Set("Content-Type", badForm.FormDataContentType())
+	badReq.Header.Set("Authorization", jwtToken)
+	badResp, err := app.Test(badReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, badResp.StatusCode)
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"project/backend/middleware"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	probe := fiber.New(fiber.Config{BodyLimit: 1024, StreamRequestBody: true, DisablePreParseMultipartForm: true})
+	probe.Use(middleware.BodyLimitMiddleware(1024))
+	middleware.RaiseBodyLimit(fiber.MethodPost, "/api/probe/:id/upload", 8192)
+	size := func(c *fiber.Ctx) error {
+		return c.SendString(strconv.Itoa(len(c.Body())))
+	}
+	probe.Post("/api/probe/login", size)
+	probe.Post("/api/probe/:id/upload", size)
+
+	send := func(path string, body io.Reader) (int, string) {
+		req := httptest.NewRequest("POST", path, body)
+		if req.ContentLength == -1 {
+			req.TransferEncoding = []string{"chunked"}
+		}
+		resp, err := probe.Test(req, -1)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, body := send("/api/probe/login", bytes.NewReader(make([]byte, 512)))
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "512", body)
+
+	// Only the upload route takes bodies over the default limit
+	status, _ = send("/api/probe/login", bytes.NewReader(make([]byte, 4096)))
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, status)
+	status, body = send("/api/probe/7/upload", bytes.NewReader(make([]byte, 4096)))
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "4096", body)
+	status, _ = send("/api/probe/7/upload", bytes.NewReader(make([]byte, 9000)))
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, status)
+
+	// A chunked body doesn't declare its size and is cut off at the limit
+	status, _ = send("/api/probe/login", io.MultiReader(bytes.NewReader(make([]byte, 4096))))
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, status)
+	status, body = send("/api/probe/login", io.MultiReader(bytes.NewReader(make([]byte, 256))))
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "256", body)
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarks(t *testing.T) {
+	course := models.Course{Title: "Bookmarked Aesthetics", AuthorID: testUser.ID, Status: models.CoursePublished, AccessSettings: models.CourseAccessSettings{AccessLevel: "public"}}
+	restricted := models.Course{Title: "Invite-only Seminar", AuthorID: testUser.ID, AccessSettings: models.CourseAccessSettings{AccessLevel: models.AccessRestricted}}
+	test := models.Test{Title: "Bookmarked Quiz", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&restricted)
+	db.Create(&test)
+
+	student := models.User{Username: "bookmark_student", Email: "bookmark_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	send := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(nil))
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+	bookmarked := func(kind string) []string {
+		status, result := send("GET", "/api/user/bookmarks?type="+kind)
+		assert.Equal(t, fiber.StatusOK, status)
+		var titles []string
+		for _, item := range result["data"].([]interface{}) {
+			titles = append(titles, item.(map[string]interface{})["title"].(string))
+		}
+		return titles
+	}
+
+	status, _ := send("POST", fmt.Sprintf("/api/courses/%d/bookmark", course.ID))
+	assert.Equal(t, fiber.StatusNoContent, status)
+	// Saving twice is harmless
+	status, _ = send("POST", fmt.Sprintf("/api/courses/%d/bookmark", course.ID))
+	assert.Equal(t, fiber.StatusNoContent, status)
+	status, _ = send("POST", fmt.Sprintf("/api/tests/%d/bookmark", test.ID))
+	assert.Equal(t, fiber.StatusNoContent, status)
+	status, _ = send("POST", fmt.Sprintf("/api/courses/%d/bookmark", restricted.ID))
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = send("POST", "/api/tests/999999/bookmark")
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, result := send("GET", "/api/user/bookmarks")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(2), result["total"])
+	items := result["data"].([]interface{})
+	assert.Equal(t, "tests", items[0].(map[string]interface{})["type"])
+	assert.Equal(t, false, items[1].(map[string]interface{})["enrolled"])
+	assert.Equal(t, []string{"Bookmarked Aesthetics"}, bookmarked("courses"))
+
+	status, details := send("GET", fmt.Sprintf("/api/courses/%d", course.ID))
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, true, details["course"].(map[string]interface{})["bookmarked"])
+
+	status, _ = send("DELETE", fmt.Sprintf("/api/courses/%d/bookmark", course.ID))
+	assert.Equal(t, fiber.StatusNoContent, status)
+	assert.Empty(t, bookmarked("courses"))
+
+	// Deleted content drops out of the list
+	db.Delete(&test)
+	assert.Empty(t, bookmarked("tests"))
+}
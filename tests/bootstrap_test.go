@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserBootstrap(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/user/bootstrap", nil)
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+		Meta struct {
+			Errors map[string]string `json:"errors"`
+		} `json:"meta"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	for _, section := range []string{"profile", "overview", "progress", "courses", "tests"} {
+		assert.Contains(t, result.Data, section)
+	}
+	assert.Empty(t, result.Meta.Errors)
+	assert.Equal(t, "testuser", result.Data["profile"].(map[string]interface{})["username"])
+}
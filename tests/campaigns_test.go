@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/outbox"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaigns(t *testing.T) {
+	queued := func(email, text string) int64 {
+		var count int64
+		db.Model(&models.OutboxMessage{}).
+			Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%"+email+"%", "%"+text+"%").
+			Count(&count)
+		return count
+	}
+	activity := func(userID uint, action string, targetID uint, score float64, at time.Time) {
+		a := models.UserActivity{UserID: userID, ActionType: action, TargetType: "tests", TargetID: targetID, TargetTitle: "Modal Logic Quiz", Score: score}
+		db.Create(&a)
+		db.Model(&a).Update("created_at", at)
+	}
+
+	// Links in the emails need the site address
+	appURL := cfg.AppURL
+	cfg.AppURL = ""
+	defer func() { cfg.AppURL = appURL }()
+	status, _ := sendJSON(t, "POST", "/api/admin/campaigns", map[string]interface{}{"name": "Lapsed", "trigger": "inactive", "inactive_days": 7})
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	cfg.AppURL = "https://philosofium.example"
+
+	status, _ = sendJSON(t, "POST", "/api/admin/campaigns", map[string]interface{}{"name": "Lapsed", "trigger": "inactive"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, result := sendJSON(t, "POST", "/api/admin/campaigns", map[string]interface{}{"name": "Lapsed", "trigger": "inactive", "inactive_days": 7})
+	assert.Equal(t, fiber.StatusCreated, status)
+	inactiveID := int(result["data"].(map[string]interface{})["id"].(float64))
+	status, result = sendJSON(t, "POST", "/api/admin/campaigns", map[string]interface{}{
+		"name": "Encouragement", "trigger": "test_failed", "score_below": 60,
+		"subject": "Keep at {test}", "body": "Hi {name}, you scored {score}%. Try these:\n{lessons}",
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	failedID := int(result["data"].(map[string]interface{})["id"].(float64))
+	// Failures from before a campaign existed are left alone, so it is dated back past the failures below
+	db.Model(&models.Campaign{}).Where("id = ?", failedID).Update("created_at", time.Now().Add(-2*time.Hour))
+
+	users := map[string]models.User{}
+	for _, name := range []string{"nudge_lapsed", "nudge_gone", "nudge_failed", "nudge_muted"} {
+		user := models.User{Username: name, Email: name + "@example.com", PasswordHash: "hash"}
+		assert.NoError(t, db.Create(&user).Error)
+		users[name] = user
+	}
+	settings := models.UserSettings{UserID: users["nudge_muted"].ID}
+	db.Create(&settings)
+	db.Model(&settings).Update("email_nudges", false)
+
+	// Lapsed a week ago in the middle of a course; another student left a month ago and is left alone
+	course := models.Course{Title: "Metaphysics", AuthorID: testUser.ID}
+	db.Create(&course)
+	for i, title := range []string{"Substance", "Causation", "Time"} {
+		db.Create(&models.Lesson{CourseID: course.ID, Title: title, SequenceOrder: i + 1})
+	}
+	for _, name := range []string{"nudge_lapsed", "nudge_gone"} {
+		db.Create(&models.UserCourseProgress{UserID: users[name].ID, CourseID: course.ID, LessonsCompleted: 1, CompletionRate: 33})
+	}
+	activity(users["nudge_lapsed"].ID, models.ActivityLessonComplete, course.ID, 0, time.Now().AddDate(0, 0, -8))
+	activity(users["nudge_gone"].ID, models.ActivityLessonComplete, course.ID, 0, time.Now().AddDate(0, 0, -30))
+
+	// Failed the final test of a course, whose lessons are suggested for review
+	test := models.Test{Title: "Modal Logic Quiz", AuthorID: testUser.ID}
+	db.Create(&test)
+	review := models.Course{Title: "Modal Logic", AuthorID: testUser.ID, CompletionPolicy: models.CompletionPolicy{FinalTestID: &test.ID, MinScore: 60}}
+	db.Create(&review)
+	db.Create(&models.Lesson{CourseID: review.ID, Title: "Possible Worlds", SequenceOrder: 1})
+	for _, name := range []string{"nudge_failed", "nudge_muted"} {
+		activity(users[name].ID, models.ActivityTestComplete, test.ID, 35, time.Now().Add(-time.Hour))
+	}
+	// A second failure of the same student waits out the cooldown after the first nudge
+	other := models.Test{Title: "Deontic Logic Quiz", AuthorID: testUser.ID}
+	db.Create(&other)
+	activity(users["nudge_failed"].ID, models.ActivityTestComplete, other.ID, 20, time.Now().Add(-30*time.Minute))
+	db.Model(&models.UserActivity{}).Where("target_id = ? AND user_id = ?", other.ID, users["nudge_failed"].ID).
+		Update("target_title", other.Title)
+
+	run := jobs.RunCampaigns(db, cfg)
+	assert.NoError(t, run(context.Background()))
+	assert.NoError(t, run(context.Background()))
+
+	assert.Equal(t, int64(1), queued("nudge_lapsed@example.com", "Causation"))
+	assert.Equal(t, int64(1), queued("nudge_lapsed@example.com", fmt.Sprintf("https://philosofium.example/courses/%d/lessons/", course.ID)))
+	assert.Equal(t, int64(0), queued("nudge_gone@example.com", ""))
+	assert.Equal(t, int64(1), queued("nudge_failed@example.com", "Keep at Modal Logic Quiz"))
+	assert.Equal(t, int64(1), queued("nudge_failed@example.com", "Possible Worlds"))
+	assert.Equal(t, int64(0), queued("nudge_muted@example.com", ""))
+	assert.Equal(t, int64(0), queued("nudge_failed@example.com", "Deontic Logic Quiz"))
+
+	// Coming back and passing the retake count as conversions
+	activity(users["nudge_lapsed"].ID, models.ActivityLessonComplete, course.ID, 0, time.Now().Add(time.Minute))
+	activity(users["nudge_failed"].ID, models.ActivityTestComplete, test.ID, 80, time.Now().Add(time.Minute))
+	assert.NoError(t, run(context.Background()))
+
+	for _, id := range []int{inactiveID, failedID} {
+		status, result = sendJSON(t, "GET", fmt.Sprintf("/api/admin/campaigns/%d/stats", id), nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		data := result["data"].(map[string]interface{})
+		assert.Equal(t, float64(1), data["sent"])
+		assert.Equal(t, float64(1), data["converted"])
+		assert.Equal(t, float64(1), data["conversion_rate"])
+		assert.Len(t, data["daily"], 1)
+	}
+
+	// A day after the first nudge the waiting one goes out
+	db.Model(&models.CampaignDelivery{}).Where("user_id = ?", users["nudge_failed"].ID).
+		Update("sent_at", time.Now().Add(-25*time.Hour))
+	assert.NoError(t, run(context.Background()))
+	assert.Equal(t, int64(1), queued("nudge_failed@example.com", "Keep at Deontic Logic Quiz"))
+
+	// Campaigns are paused rather than deleted to keep their results
+	status, result = sendJSON(t, "PATCH", fmt.Sprintf("/api/admin/campaigns/%d", failedID), map[string]interface{}{"enabled": false})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, false, result["data"].(map[string]interface{})["enabled"])
+}
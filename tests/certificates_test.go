@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseCertificateSurvey(t *testing.T) {
+	course := models.Course{
+		Title:          "Surveyed Course",
+		Status:         models.CoursePublished,
+		AuthorID:       testUser.ID,
+		Lessons:        []models.Lesson{{Title: "Only lesson", SequenceOrder: 1}},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+
+	student := models.User{Username: "survey_student", Email: "survey_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	surveyPath := fmt.Sprintf("/api/courses/%d/survey", course.ID)
+	certificatePath := fmt.Sprintf("/api/courses/%d/certificate", course.ID)
+
+	status, _ := sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/courses/%d/survey", course.ID), jwtToken, map[string]interface{}{"required": true})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/courses/%d/survey", course.ID), jwtToken, map[string]interface{}{
+		"questions": []string{"What did you like?", "What would you change?"},
+		"required":  true,
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Not finished yet
+	status, _ = sendJSONAs(t, "GET", certificatePath, token, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	// Finishing the last lesson doesn't issue the certificate while the survey is unanswered
+	status, progress := sendJSONAs(t, "POST", fmt.Sprintf("/api/courses/%d/progress", course.ID), token, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, true, progress["survey_required"])
+	assert.Nil(t, progress["certificate"])
+
+	status, denied := sendJSONAs(t, "GET", certificatePath, token, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, true, denied["details"].(map[string]interface{})["survey_required"])
+
+	status, _ = sendJSONAs(t, "POST", surveyPath, token, map[string]interface{}{"answers": []string{"Everything"}})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, submitted := sendJSONAs(t, "POST", surveyPath, token, map[string]interface{}{"answers": []string{"Everything", "Nothing"}})
+	assert.Equal(t, fiber.StatusCreated, status)
+	issued := submitted["data"].(map[string]interface{})["certificate"].(map[string]interface{})
+	assert.NotEmpty(t, issued["Serial"])
+
+	// The certificate is issued once
+	status, fetched := sendJSONAs(t, "GET", certificatePath, token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, issued["Serial"], fetched["data"].(map[string]interface{})["Serial"])
+
+	var certificates int64
+	db.Model(&models.CourseCertificate{}).Where("course_id = ? AND user_id = ?", course.ID, student.ID).Count(&certificates)
+	assert.Equal(t, int64(1), certificates)
+
+	// Without an Accept header the record comes as JSON
+	req := httptest.NewRequest("GET", certificatePath, nil)
+	req.Header.Set("Authorization", token)
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "application/json")
+
+	// The printable PDF has to be asked for, its link doesn't follow the Host header
+	req = httptest.NewRequest("GET", certificatePath, nil)
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Accept", "application/pdf")
+	req.Host = "attacker.example"
+	resp, err = app.Test(req, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	pdf := new(bytes.Buffer)
+	pdf.ReadFrom(resp.Body)
+	assert.True(t, bytes.HasPrefix(pdf.Bytes(), []byte("%PDF-")))
+	assert.Contains(t, pdf.String(), issued["Serial"].(string))
+	assert.NotContains(t, pdf.String(), "attacker.example")
+
+	// Anyone can check the number, in any case
+	status, verified := sendJSONAs(t, "GET", "/api/certificates/"+strings.ToLower(issued["Serial"].(string)), "", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	data := verified["data"].(map[string]interface{})
+	assert.Equal(t, true, data["valid"])
+	assert.Equal(t, "survey_student", data["holder"])
+	assert.Equal(t, "Surveyed Course", data["course"].(map[string]interface{})["title"])
+
+	status, _ = sendJSONAs(t, "GET", "/api/certificates/0000000000000000", "", nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircleChallenges(t *testing.T) {
+	course := models.Course{
+		Title:          "Stoicism",
+		Status:         models.CoursePublished,
+		AuthorID:       testUser.ID,
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+	module := models.CourseModule{CourseID: course.ID, Title: "Epictetus", SequenceOrder: 3}
+	assert.NoError(t, db.Create(&module).Error)
+	var lessons []models.Lesson
+	for i, title := range []string{"Dichotomy of control", "Impressions", "Meditations"} {
+		lesson := models.Lesson{CourseID: course.ID, Title: title, SequenceOrder: i + 1}
+		if i < 2 {
+			lesson.ModuleID = &module.ID
+		}
+		assert.NoError(t, db.Create(&lesson).Error)
+		lessons = append(lessons, lesson)
+	}
+
+	circle := models.StudyCircle{CourseID: course.ID, Name: "Porch", JoinCode: "STOA2345", MaxMembers: 4}
+	assert.NoError(t, db.Create(&circle).Error)
+	tokens, users := map[string]string{}, map[string]models.User{}
+	for _, name := range []string{"stoic_owner", "stoic_private"} {
+		student := models.User{Username: name, Email: name + "@example.com", PasswordHash: "hash"}
+		assert.NoError(t, db.Create(&student).Error)
+		token, err := utils.GenerateJWTToken(&student, cfg)
+		assert.NoError(t, err)
+		tokens[name], users[name] = token, student
+		member := models.StudyCircleMember{CircleID: circle.ID, UserID: student.ID, CourseID: course.ID, Role: models.CircleMember}
+		if name == "stoic_owner" {
+			member.Role, member.ShareProgress = models.CircleOwner, true
+		}
+		assert.NoError(t, db.Create(&member).Error)
+	}
+
+	challengesPath := fmt.Sprintf("/api/circles/%d/challenges", circle.ID)
+
+	// Only the owner sets challenges, and a module goal needs a module of the course
+	status, _ := sendJSONAs(t, "POST", challengesPath, tokens["stoic_private"], map[string]interface{}{"goal": "lessons", "target": 1, "days": 7})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = sendJSONAs(t, "POST", challengesPath, tokens["stoic_owner"], map[string]interface{}{"goal": "module", "days": 7})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, result := sendJSONAs(t, "POST", challengesPath, tokens["stoic_owner"], map[string]interface{}{"goal": "module", "module_id": module.ID, "days": 7})
+	assert.Equal(t, fiber.StatusCreated, status)
+	challenge := result["data"].(map[string]interface{})
+	assert.Equal(t, "Complete Epictetus", challenge["title"])
+	assert.Equal(t, float64(2), challenge["target"])
+	assert.Equal(t, "active", challenge["status"])
+	challengePath := fmt.Sprintf("%s/%d", challengesPath, int(challenge["id"].(float64)))
+
+	// Completed lessons count automatically; a lesson outside the module does not
+	progressPath := fmt.Sprintf("/api/courses/%d/progress", course.ID)
+	for _, lesson := range []models.Lesson{lessons[2], lessons[0]} {
+		status, _ = sendJSONAs(t, "POST", progressPath, tokens["stoic_owner"], map[string]interface{}{"lesson_id": lesson.ID, "mark_completed": true})
+		assert.Equal(t, fiber.StatusOK, status)
+	}
+	status, result = sendJSONAs(t, "GET", challengesPath, tokens["stoic_owner"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(1), result["data"].([]interface{})[0].(map[string]interface{})["progress"])
+	assert.Equal(t, float64(1), result["meta"].(map[string]interface{})["streak_days"])
+	assert.Equal(t, true, result["meta"].(map[string]interface{})["active_today"])
+
+	// Finishing the module earns the badge
+	status, _ = sendJSONAs(t, "POST", progressPath, tokens["stoic_owner"], map[string]interface{}{"lesson_id": lessons[1].ID, "mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, result = sendJSONAs(t, "GET", "/api/user/badges", tokens["stoic_owner"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	if badges := result["data"].([]interface{}); assert.Len(t, badges, 1) {
+		assert.Equal(t, "Complete Epictetus", badges[0].(map[string]interface{})["title"])
+	}
+
+	// The leaderboard shows numbers of members who share progress and always one's own
+	status, result = sendJSONAs(t, "GET", challengePath, tokens["stoic_private"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, float64(1), data["completed_count"])
+	board := data["leaderboard"].([]interface{})
+	if assert.Len(t, board, 2) {
+		first := board[0].(map[string]interface{})
+		assert.Equal(t, "stoic_owner", first["username"])
+		assert.Equal(t, float64(2), first["progress"])
+		assert.Equal(t, true, first["completed"])
+		assert.Equal(t, float64(1), first["rank"])
+		assert.Equal(t, float64(0), board[1].(map[string]interface{})["progress"])
+	}
+	status, result = sendJSONAs(t, "GET", challengePath, tokens["stoic_owner"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NotContains(t, result["data"].(map[string]interface{})["leaderboard"].([]interface{})[1], "progress")
+
+	// The badge dates from the lesson that reached the goal and is announced in its own email category
+	var badge models.CircleBadge
+	db.Where("user_id = ?", users["stoic_owner"].ID).First(&badge)
+	var reached models.UserActivity
+	db.Where("user_id = ? AND action_type = ? AND subject_id = ?", users["stoic_owner"].ID, models.ActivityLessonComplete, lessons[1].ID).First(&reached)
+	assert.WithinDuration(t, reached.CreatedAt, badge.EarnedAt, time.Millisecond)
+	queued := func(email string) int64 {
+		var count int64
+		db.Model(&models.OutboxMessage{}).
+			Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%"+email+"%", "%Challenge completed%").
+			Count(&count)
+		return count
+	}
+	assert.Equal(t, int64(1), queued("stoic_owner@example.com"))
+
+	// Reading a challenge never awards badges: goals that no event reached, such as a module that lost
+	// a lesson, are caught up by the scheduler
+	settings := models.UserSettings{UserID: users["stoic_private"].ID}
+	db.Create(&settings)
+	db.Model(&settings).Update("email_badges", false)
+	status, _ = sendJSONAs(t, "POST", progressPath, tokens["stoic_private"], map[string]interface{}{"lesson_id": lessons[0].ID, "mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	db.Model(&lessons[1]).Update("module_id", nil)
+	badges := func() int64 {
+		var count int64
+		db.Model(&models.CircleBadge{}).Where("challenge_id = ?", badge.ChallengeID).Count(&count)
+		return count
+	}
+	status, _ = sendJSONAs(t, "GET", challengePath, tokens["stoic_private"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, int64(1), badges())
+	assert.NoError(t, jobs.AwardCircleBadges(db)(context.Background()))
+	assert.Equal(t, int64(2), badges())
+	assert.Equal(t, int64(0), queued("stoic_private@example.com"))
+
+	// Badges stay after the challenge is removed
+	status, _ = sendJSONAs(t, "DELETE", challengePath, tokens["stoic_owner"], nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	status, result = sendJSONAs(t, "GET", "/api/user/badges", tokens["stoic_owner"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, result["data"], 1)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseCompletionPolicy(t *testing.T) {
+	course := models.Course{
+		Title:    "Policy Course",
+		Status:   models.CoursePublished,
+		AuthorID: testUser.ID,
+		Lessons: []models.Lesson{
+			{Title: "One", SequenceOrder: 1}, {Title: "Two", SequenceOrder: 2},
+			{Title: "Three", SequenceOrder: 3}, {Title: "Four", SequenceOrder: 4},
+		},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+	final := models.Test{
+		Title:          "Policy Final",
+		AuthorID:       testUser.ID,
+		Questions:      []models.TestQuestion{{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1}},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 3},
+	}
+	assert.NoError(t, db.Create(&final).Error)
+
+	student := models.User{Username: "policy_student", Email: "policy_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	policyPath := fmt.Sprintf("/api/admin/courses/%d/completion-policy", course.ID)
+	rate := func() float64 {
+		var progress models.UserCourseProgress
+		db.Where("user_id = ? AND course_id = ?", student.ID, course.ID).First(&progress)
+		return progress.CompletionRate
+	}
+
+	status, _ := sendJSONAs(t, "PUT", policyPath, jwtToken, map[string]interface{}{"lesson_percent": 0})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSONAs(t, "PUT", policyPath, jwtToken, map[string]interface{}{"min_score": 70})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSONAs(t, "PUT", policyPath, token, map[string]interface{}{"lesson_percent": 50})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	// The final test has to be one the course's author or editor wrote
+	foreign := models.Test{Title: "Someone else's final", AuthorID: student.ID}
+	assert.NoError(t, db.Create(&foreign).Error)
+	status, _ = sendJSONAs(t, "PUT", policyPath, jwtToken, map[string]interface{}{"lesson_percent": 50, "final_test_id": foreign.ID})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// Half of the lessons and a passed final test
+	status, _ = sendJSONAs(t, "PUT", policyPath, jwtToken, map[string]interface{}{"lesson_percent": 50, "final_test_id": final.ID, "min_score": 70})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	for range 2 {
+		status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/courses/%d/progress", course.ID), token, map[string]interface{}{"mark_completed": true})
+		assert.Equal(t, fiber.StatusOK, status)
+	}
+	assert.InDelta(t, 66.67, rate(), 0.01)
+	status, _ = sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d/certificate", course.ID), token, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	// Passing the final test completes the course and issues the certificate
+	status, result := sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", final.ID), token, map[string]interface{}{
+		"answers": []map[string]interface{}{{"question_id": final.Questions[0].ID, "answer": 1}},
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, result["certificates"], 1)
+	assert.Equal(t, float64(100), rate())
+
+	status, summary := sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d/completion-policy", course.ID), token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	data := summary["data"].(map[string]interface{})
+	assert.Equal(t, float64(2), data["lessons_required"])
+	assert.Equal(t, true, data["final_test_passed"])
+	assert.Equal(t, true, data["completed"])
+
+	// Requiring every lesson again recalculates existing progress
+	status, _ = sendJSONAs(t, "PUT", policyPath, jwtToken, map[string]interface{}{"lesson_percent": 100})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(50), rate())
+
+	// Deleting and restoring a lesson recounts progress by the same rules, not by the lesson share alone
+	status, _ = sendJSONAs(t, "PUT", policyPath, jwtToken, map[string]interface{}{"lesson_percent": 50, "final_test_id": final.ID, "min_score": 70})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(100), rate())
+	status, _ = sendJSONAs(t, "DELETE", fmt.Sprintf("/api/admin/courses/%d/lessons/%d", course.ID, course.Lessons[3].ID), jwtToken, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	assert.Equal(t, float64(100), rate())
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/admin/trash/lessons/%d/restore", course.Lessons[3].ID), jwtToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(100), rate())
+}
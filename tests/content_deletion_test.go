@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentDeletion(t *testing.T) {
+	deleteRequest := func(path string) int {
+		req := httptest.NewRequest("DELETE", path, nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	trash := func(kind string) []uint {
+		req := httptest.NewRequest("GET", "/api/admin/trash/"+kind, nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var body struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		ids := []uint{}
+		for _, item := range body.Data {
+			ids = append(ids, uint(item["id"].(float64)))
+		}
+		return ids
+	}
+
+	course := models.Course{Title: "Doomed Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "public"})
+	first := models.Lesson{CourseID: course.ID, Title: "First", SequenceOrder: 1}
+	second := models.Lesson{CourseID: course.ID, Title: "Second", SequenceOrder: 2}
+	db.Create(&first)
+	db.Create(&second)
+	progress := models.UserCourseProgress{UserID: testUser.ID, CourseID: course.ID, LessonsCompleted: 2, CompletionRate: 100}
+	db.Create(&progress)
+
+	// Deleting a lesson recomputes progress against the remaining lessons
+	assert.Equal(t, fiber.StatusNoContent, deleteRequest(fmt.Sprintf("/api/admin/courses/%d/lessons/%d", course.ID, second.ID)))
+	db.First(&progress, progress.ID)
+	assert.Equal(t, float64(100), progress.CompletionRate)
+	assert.Contains(t, trash("lessons"), second.ID)
+	status, _ := postJSON(t, fmt.Sprintf("/api/admin/trash/lessons/%d/restore", second.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NotContains(t, trash("lessons"), second.ID)
+
+	// The course goes with its lessons and student progress
+	assert.Equal(t, fiber.StatusNoContent, deleteRequest(fmt.Sprintf("/api/admin/courses/%d", course.ID)))
+	assert.Equal(t, fiber.StatusNotFound, deleteRequest(fmt.Sprintf("/api/admin/courses/%d", course.ID)))
+	var count int64
+	db.Model(&models.Lesson{}).Where("course_id = ?", course.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	db.Model(&models.UserCourseProgress{}).Where("course_id = ?", course.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	assert.Contains(t, trash("courses"), course.ID)
+
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/trash/courses/%d/restore", course.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	db.Model(&models.Lesson{}).Where("course_id = ?", course.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+	db.Model(&models.UserCourseProgress{}).Where("course_id = ?", course.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/trash/courses/%d/restore", course.ID), nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	// Tests and their questions
+	test := models.Test{Title: "Doomed Test", AuthorID: testUser.ID}
+	db.Create(&test)
+	question := models.TestQuestion{TestID: test.ID, Title: "Why?", Question: "Why?"}
+	db.Create(&question)
+
+	assert.Equal(t, fiber.StatusNoContent, deleteRequest(fmt.Sprintf("/api/admin/tests/%d/questions/%d", test.ID, question.ID)))
+	assert.Contains(t, trash("questions"), question.ID)
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/trash/questions/%d/restore", question.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	assert.Equal(t, fiber.StatusNoContent, deleteRequest(fmt.Sprintf("/api/admin/tests/%d", test.ID)))
+	db.Model(&models.TestQuestion{}).Where("test_id = ?", test.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/trash/tests/%d/restore", test.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	db.Model(&models.TestQuestion{}).Where("test_id = ?", test.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// Held content can't be deleted
+	db.Create(&models.LegalHold{SubjectType: models.HoldSubjectCourse, SubjectID: course.ID, Reason: "Audit", AppliedBy: testUser.ID})
+	assert.Equal(t, fiber.StatusConflict, deleteRequest(fmt.Sprintf("/api/admin/courses/%d", course.ID)))
+	assert.Equal(t, fiber.StatusConflict, deleteRequest(fmt.Sprintf("/api/admin/courses/%d/lessons/%d", course.ID, first.ID)))
+
+	status, _ = postJSON(t, "/api/admin/trash/everything/1/restore", nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentLicense(t *testing.T) {
+	patch := func(path string, payload interface{}) int {
+		status, _ := sendJSON(t, "PATCH", path, payload)
+		return status
+	}
+
+	status, _ := postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Unlicensed", "license": "wtfpl"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Uncredited", "license": "cc-by"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// Courses are proprietary unless the author picks an open license
+	status, created := postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Plain Course"})
+	assert.Equal(t, fiber.StatusOK, status)
+	var plain models.Course
+	db.First(&plain, uint(created["course"].(map[string]interface{})["ID"].(float64)))
+	assert.Equal(t, models.LicenseProprietary, plain.License)
+
+	status, created = postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Open Logic", "license": "cc-by-sa", "attribution": "Logic Dept."})
+	assert.Equal(t, fiber.StatusOK, status)
+	courseID := uint(created["course"].(map[string]interface{})["ID"].(float64))
+	db.Model(&models.Course{}).Where("id = ?", courseID).Update("status", models.CoursePublished)
+	db.Model(&models.CourseAccessSettings{}).Where("course_id = ?", courseID).Update("access_level", "public")
+
+	// The catalog shows the license and who to credit
+	req := httptest.NewRequest("GET", "/api/courses/available", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	var catalog []map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&catalog)
+	found := false
+	for _, item := range catalog {
+		if uint(item["id"].(float64)) == courseID {
+			found = true
+			assert.Equal(t, "cc-by-sa", item["license"])
+			assert.Equal(t, "Logic Dept.", item["attribution"])
+		}
+	}
+	assert.True(t, found)
+
+	// A copy owes credit to the original and stays share-alike
+	status, cloned := postJSON(t, fmt.Sprintf("/api/admin/courses/%d/clone", courseID), nil)
+	assert.Equal(t, fiber.StatusCreated, status)
+	cloneID := uint(cloned["data"].(map[string]interface{})["id"].(float64))
+	var clone models.Course
+	db.First(&clone, cloneID)
+	assert.Equal(t, models.LicenseCCBYSA, clone.License)
+	assert.Equal(t, models.LicenseCCBYSA, clone.SourceLicense)
+	assert.Equal(t, "Logic Dept.", clone.SourceAttribution)
+
+	descriptionPath := fmt.Sprintf("/api/admin/courses/%d/description", cloneID)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, patch(descriptionPath, map[string]interface{}{"license": "proprietary"}))
+	assert.Equal(t, fiber.StatusUnprocessableEntity, patch(descriptionPath, map[string]interface{}{"attribution": ""}))
+	assert.Equal(t, fiber.StatusOK, patch(descriptionPath, map[string]interface{}{"attribution": "Ethics Dept."}))
+	db.First(&clone, cloneID)
+	assert.Equal(t, "Logic Dept.", clone.SourceAttribution)
+
+	// Sharing the copy keeps crediting the original
+	db.Model(&clone).Update("status", models.CoursePublished)
+	status, listed := postJSON(t, fmt.Sprintf("/api/admin/courses/%d/marketplace", cloneID), map[string]interface{}{"summary": "Adapted"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, "Ethics Dept.; adapted from Logic Dept.", listed["data"].(map[string]interface{})["attribution"])
+	assert.Equal(t, "cc-by-sa", listed["data"].(map[string]interface{})["license"])
+
+	// Tests carry a license too
+	test := models.Test{Title: "Licensed Test", AuthorID: testUser.ID}
+	db.Create(&test)
+	testPath := fmt.Sprintf("/api/admin/tests/%d/description", test.ID)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, patch(testPath, map[string]interface{}{"license": "cc-by"}))
+	assert.Equal(t, fiber.StatusOK, patch(testPath, map[string]interface{}{"license": "cc-by", "attribution": "Quiz Team"}))
+	db.First(&test, test.ID)
+	assert.Equal(t, models.LicenseCCBY, test.License)
+	assert.Equal(t, "Quiz Team", test.Attribution)
+}
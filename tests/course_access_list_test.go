@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseAccessList(t *testing.T) {
+	hash, _ := utils.Passwords.Hash("outsider123")
+	outsider := models.User{Username: "outsider", Email: "outsider@example.com", PasswordHash: hash}
+	db.Create(&outsider)
+	token, _ := utils.GenerateJWTToken(&outsider, cfg)
+
+	course := models.Course{Title: "Seminar", AuthorID: testUser.ID, Status: models.CoursePublished}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: models.AccessRestricted})
+	db.Create(&models.Lesson{CourseID: course.ID, Title: "Reading", SequenceOrder: 1})
+	listPath := fmt.Sprintf("/api/admin/courses/%d/access-list", course.ID)
+
+	asOutsider := func(method, path string) int {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	details := func() int { return asOutsider("GET", fmt.Sprintf("/api/courses/%d", course.ID)) }
+	remove := func(entryID uint) int {
+		req := httptest.NewRequest("DELETE", fmt.Sprintf("%s/%d", listPath, entryID), nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusForbidden, details())
+	assert.Equal(t, fiber.StatusForbidden, asOutsider("POST", fmt.Sprintf("/api/courses/%d/progress", course.ID)))
+
+	status, _ := postJSON(t, listPath, map[string]interface{}{"email": "outsider@example.com", "user_id": outsider.ID})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = postJSON(t, listPath, map[string]interface{}{"email": "not an email"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// Emails match regardless of case
+	status, created := postJSON(t, listPath, map[string]interface{}{"email": "Outsider@Example.com"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	emailEntry := uint(created["data"].(map[string]interface{})["id"].(float64))
+	status, _ = postJSON(t, listPath, map[string]interface{}{"email": "outsider@example.com"})
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, fiber.StatusOK, details())
+
+	assert.Equal(t, fiber.StatusNoContent, remove(emailEntry))
+	assert.Equal(t, fiber.StatusNotFound, remove(emailEntry))
+	assert.Equal(t, fiber.StatusForbidden, details())
+
+	// Membership in a listed group is enough
+	group := models.StudyGroup{Name: "PHIL-1", Slug: "phil-1-access"}
+	db.Create(&group)
+	db.Create(&models.StudyGroupMember{GroupID: group.ID, UserID: outsider.ID})
+	status, created = postJSON(t, listPath, map[string]interface{}{"group_id": group.ID})
+	assert.Equal(t, fiber.StatusCreated, status)
+	groupEntry := uint(created["data"].(map[string]interface{})["id"].(float64))
+	assert.Equal(t, fiber.StatusOK, details())
+	assert.Equal(t, fiber.StatusOK, asOutsider("POST", fmt.Sprintf("/api/courses/%d/progress", course.ID)))
+
+	// Students who already enrolled keep their access
+	assert.Equal(t, fiber.StatusNoContent, remove(groupEntry))
+	assert.Equal(t, fiber.StatusOK, details())
+
+	req := httptest.NewRequest("GET", listPath, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, fiber.StatusForbidden, asOutsider("GET", listPath))
+
+	body, _ := json.Marshal(map[string]interface{}{"access_level": "secret"})
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/api/admin/courses/%d/settings", course.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+}
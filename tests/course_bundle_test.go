@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"project/backend/models"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCourseBundleExportImport(t *testing.T) {
+	source := models.Course{Title: "Stoicism", ShortDesc: "Live according to nature", AuthorID: testUser.ID,
+		License: models.LicenseCCBY, Attribution: "Stoa Society"}
+	db.Create(&source)
+	module := models.CourseModule{CourseID: source.ID, Title: "Founders", SequenceOrder: 1}
+	db.Create(&module)
+	db.Create(&models.Lesson{CourseID: source.ID, ModuleID: &module.ID, Title: "Zeno of Citium", Content: "<p>The Stoa Poikile</p>", SequenceOrder: 1})
+	db.Create(&models.Lesson{CourseID: source.ID, Title: "Marcus Aurelius", Description: "Meditations", Content: "Book **II**", SequenceOrder: 2})
+
+	download := func(format string) (int, string, []byte) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/admin/courses/%d/bundle?format=%s", source.ID, format), nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderContentType), body
+	}
+	upload := func(body []byte, contentType string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/api/admin/courses/import", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+	imported := func(result map[string]interface{}) models.Course {
+		var course models.Course
+		db.Preload("Modules").Preload("Lessons", func(tx *gorm.DB) *gorm.DB { return tx.Order("sequence_order") }).
+			First(&course, uint(result["data"].(map[string]interface{})["id"].(float64)))
+		return course
+	}
+
+	status, contentType, jsonBundle := download("json")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.True(t, strings.HasPrefix(contentType, fiber.MIMEApplicationJSON))
+	assert.Contains(t, string(jsonBundle), "Zeno of Citium")
+
+	status, result := upload(jsonBundle, fiber.MIMEApplicationJSON)
+	assert.Equal(t, fiber.StatusCreated, status)
+	restored := imported(result)
+	assert.NotEqual(t, source.ID, restored.ID)
+	assert.Equal(t, models.CourseDraft, restored.Status)
+	assert.Equal(t, models.LicenseCCBY, restored.License)
+	if assert.Len(t, restored.Lessons, 2) && assert.Len(t, restored.Modules, 1) {
+		assert.Equal(t, restored.Modules[0].ID, *restored.Lessons[0].ModuleID)
+		assert.Equal(t, "Book **II**", restored.Lessons[1].Content)
+	}
+
+	// The Markdown archive round-trips the same content
+	status, contentType, archive := download("markdown")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "application/zip", contentType)
+	status, result = upload(archive, "application/zip")
+	assert.Equal(t, fiber.StatusCreated, status)
+	restored = imported(result)
+	if assert.Len(t, restored.Lessons, 2) {
+		assert.Equal(t, "<p>The Stoa Poikile</p>", restored.Lessons[0].Content)
+		assert.Equal(t, "Meditations", restored.Lessons[1].Description)
+		assert.NotNil(t, restored.Lessons[0].ModuleID)
+	}
+
+	// The bundle has a path of its own, the gradebook export keeps /export
+	status, _, _ = download("canvas")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/admin/courses/%d/export?format=canvas", source.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), "text/csv"))
+
+	status, _ = upload([]byte("not a bundle"), fiber.MIMETextPlain)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = upload([]byte(`{"version": 99, "title": "From the future"}`), fiber.MIMEApplicationJSON)
+	assert.Equal(t, fiber.StatusConflict, status)
+}
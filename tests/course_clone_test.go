@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloneCourse(t *testing.T) {
+	source := models.Course{Title: "Ethics 2025", AuthorID: testUser.ID, Status: models.CoursePublished}
+	db.Create(&source)
+	db.Create(&models.CourseAccessSettings{CourseID: source.ID, AccessLevel: "public", StartDate: "2025-09-01", EndDate: "2025-12-20"})
+	module := models.CourseModule{CourseID: source.ID, Title: "Virtue", SequenceOrder: 1}
+	db.Create(&module)
+	lesson := models.Lesson{CourseID: source.ID, ModuleID: &module.ID, Title: "Aristotle", Content: "Nicomachean Ethics", SequenceOrder: 1}
+	db.Create(&lesson)
+	db.Create(&models.Lesson{CourseID: source.ID, Title: "Glossary", SequenceOrder: 2})
+	db.Create(&models.CourseSurvey{CourseID: source.ID, Questions: `["Was it useful?"]`, Required: true})
+	db.Create(&models.CourseGradeComponent{CourseID: source.ID, Name: "Lessons", Kind: models.GradeKindLessons, Weight: 100})
+	// Student data stays with the old course
+	db.Create(&models.UserCourseProgress{UserID: testUser.ID, CourseID: source.ID, LessonsCompleted: 2, CompletionRate: 100})
+
+	status, result := postJSON(t, fmt.Sprintf("/api/admin/courses/%d/clone", source.ID), map[string]interface{}{"title": "Ethics 2026", "start_date": "2026-09-01"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	cloneID := uint(result["data"].(map[string]interface{})["id"].(float64))
+	assert.NotEqual(t, source.ID, cloneID)
+
+	var clone models.Course
+	db.Preload("Modules").Preload("Lessons").Preload("AccessSettings").First(&clone, cloneID)
+	assert.Equal(t, "Ethics 2026", clone.Title)
+	assert.Equal(t, models.CourseDraft, clone.Status)
+	assert.Equal(t, "public", clone.AccessSettings.AccessLevel)
+	assert.Equal(t, "2026-09-01", clone.AccessSettings.StartDate)
+	assert.Empty(t, clone.AccessSettings.EndDate)
+
+	// Lessons point at the copied module, not the original
+	assert.Len(t, clone.Modules, 1)
+	assert.Len(t, clone.Lessons, 2)
+	for _, copied := range clone.Lessons {
+		if copied.Title == "Aristotle" {
+			assert.Equal(t, "Nicomachean Ethics", copied.Content)
+			if assert.NotNil(t, copied.ModuleID) {
+				assert.Equal(t, clone.Modules[0].ID, *copied.ModuleID)
+			}
+		} else {
+			assert.Nil(t, copied.ModuleID)
+		}
+	}
+
+	var count int64
+	db.Model(&models.CourseSurvey{}).Where("course_id = ? AND required = ?", cloneID, true).Count(&count)
+	assert.Equal(t, int64(1), count)
+	db.Model(&models.CourseGradeComponent{}).Where("course_id = ?", cloneID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	db.Model(&models.UserCourseProgress{}).Where("course_id = ?", cloneID).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// Without a title the copy is named after the source
+	status, result = postJSON(t, fmt.Sprintf("/api/admin/courses/%d/clone", source.ID), nil)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, "Ethics 2025 (copy)", result["data"].(map[string]interface{})["title"])
+
+	status, _ = postJSON(t, "/api/admin/courses/999999/clone", nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseConduct(t *testing.T) {
+	hash, _ := utils.Passwords.Hash("student123")
+	student := models.User{Username: "conduct_student", Email: "conduct_student@example.com", PasswordHash: hash}
+	db.Create(&student)
+	token, _ := utils.GenerateJWTToken(&student, cfg)
+
+	course := models.Course{Title: "Logic Exam Prep", AuthorID: testUser.ID, Status: models.CoursePublished}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: models.AccessPublic})
+	db.Create(&models.Lesson{CourseID: course.ID, Title: "Syllogisms", SequenceOrder: 1})
+	test := models.Test{Title: "Logic Midterm", AuthorID: testUser.ID}
+	db.Create(&test)
+	db.Create(&models.TestQuestion{TestID: test.ID, Title: "Q1", Question: "Barbara?"})
+	db.Create(&models.CourseGradeComponent{CourseID: course.ID, Name: "Midterm", Kind: models.GradeKindTests, Weight: 100, TestIDs: fmt.Sprint(test.ID)})
+
+	asStudent := func(method, path string, payload interface{}) (int, map[string]interface{}) {
+		return sendJSONAs(t, method, path, token, payload)
+	}
+	setConduct := func(payload interface{}) (int, map[string]interface{}) {
+		return sendJSON(t, "PUT", fmt.Sprintf("/api/admin/courses/%d/conduct", course.ID), payload)
+	}
+	conductPath := fmt.Sprintf("/api/courses/%d/conduct", course.ID)
+	progressPath := fmt.Sprintf("/api/courses/%d/progress", course.ID)
+	testPath := fmt.Sprintf("/api/tests/%d/progress", test.ID)
+
+	status, _ := asStudent("GET", conductPath, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, _ = setConduct(map[string]interface{}{"statement": " ", "required": true})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, result := setConduct(map[string]interface{}{"statement": "I will not use notes during exams.", "required": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(1), result["data"].(map[string]interface{})["version"])
+
+	// No lessons until the rules are acknowledged
+	status, result = asStudent("POST", progressPath, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, true, result["conduct_required"])
+
+	status, result = asStudent("GET", conductPath, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, false, result["data"].(map[string]interface{})["acknowledged"])
+
+	status, _ = asStudent("POST", conductPath+"/acknowledge", map[string]interface{}{"version": 1})
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = asStudent("POST", conductPath+"/acknowledge", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, _ = asStudent("POST", progressPath, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	answers := map[string]interface{}{"answers": []map[string]interface{}{}}
+	status, _ = asStudent("POST", testPath, answers)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// New wording has to be acknowledged again, for lessons and graded tests alike
+	status, result = setConduct(map[string]interface{}{"statement": "I will not use notes or other people during exams.", "required": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(2), result["data"].(map[string]interface{})["version"])
+
+	status, _ = asStudent("POST", progressPath, map[string]interface{}{})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, result = asStudent("POST", testPath, answers)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, float64(course.ID), result["course_id"])
+	// Opening the test would start the attempt, so it waits as well
+	status, result = asStudent("GET", fmt.Sprintf("/api/tests/%d", test.ID), nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, true, result["conduct_required"])
+
+	status, _ = asStudent("POST", conductPath+"/acknowledge", map[string]interface{}{"version": 1})
+	assert.Equal(t, fiber.StatusConflict, status)
+	status, _ = asStudent("POST", conductPath+"/acknowledge", map[string]interface{}{"version": 2})
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = asStudent("POST", testPath, answers)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Both versions stay in the log, with the text the student agreed to
+	status, _ = asStudent("GET", conductPath+"/acknowledgements", nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("%s/acknowledgements?user_id=%d", conductPath, student.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var log map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&log)
+	assert.Equal(t, float64(2), log["total"])
+	rows := log["data"].([]interface{})
+	assert.Equal(t, "I will not use notes or other people during exams.", rows[0].(map[string]interface{})["statement"])
+	assert.Equal(t, "conduct_student", rows[0].(map[string]interface{})["username"])
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/analytics/course/%d", course.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	var analytics map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&analytics)
+	conduct := analytics["data"].(map[string]interface{})["conduct"].(map[string]interface{})
+	assert.Equal(t, float64(1), conduct["acknowledged"])
+	assert.Equal(t, float64(0), conduct["pending"])
+
+	// Optional rules don't block anything
+	status, _ = setConduct(map[string]interface{}{"statement": "Be kind in the comments.", "required": false})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = asStudent("POST", progressPath, map[string]interface{}{})
+	assert.Equal(t, fiber.StatusOK, status)
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseLifecycle(t *testing.T) {
+	status, created := postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Lifecycle Course", "status": "published"})
+	assert.Equal(t, fiber.StatusOK, status)
+	courseID := uint(created["course"].(map[string]interface{})["ID"].(float64))
+	// The status can't be set on creation
+	var course models.Course
+	db.First(&course, courseID)
+	assert.Equal(t, models.CourseDraft, course.Status)
+	db.Model(&models.CourseAccessSettings{}).Where("course_id = ?", courseID).Update("access_level", "public")
+
+	listed := func(path string) bool {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var body json.RawMessage
+		json.NewDecoder(resp.Body).Decode(&body)
+		// The catalog returns a bare array, search wraps it in the usual envelope
+		var items []map[string]interface{}
+		if json.Unmarshal(body, &items) != nil {
+			var wrapped struct {
+				Data []map[string]interface{} `json:"data"`
+			}
+			json.Unmarshal(body, &wrapped)
+			items = wrapped.Data
+		}
+		for _, item := range items {
+			if uint(item["id"].(float64)) == courseID {
+				return true
+			}
+		}
+		return false
+	}
+	transition := func(action string) int {
+		status, _ := postJSON(t, fmt.Sprintf("/api/admin/courses/%d/%s", courseID, action), nil)
+		return status
+	}
+	reviewerToken := courseReviewer(t)
+
+	assert.False(t, listed("/api/courses/available"))
+	assert.False(t, listed("/api/overview/courses?search=Lifecycle"))
+
+	assert.Equal(t, fiber.StatusOK, transition("submit"))
+	assert.Equal(t, fiber.StatusConflict, transition("submit"))
+	// The author can't sign off on their own course
+	assert.Equal(t, fiber.StatusForbidden, transition("publish"))
+	status, _ = postJSONAs(t, reviewerToken, fmt.Sprintf("/api/admin/courses/%d/publish", courseID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	db.First(&course, courseID)
+	assert.Equal(t, models.CoursePublished, course.Status)
+	assert.NotNil(t, course.PublishedAt)
+
+	assert.True(t, listed("/api/courses/available"))
+	assert.True(t, listed("/api/overview/courses?search=Lifecycle"))
+
+	// Archived courses leave the catalog and come back only as drafts
+	assert.Equal(t, fiber.StatusOK, transition("archive"))
+	assert.False(t, listed("/api/courses/available"))
+	assert.Equal(t, fiber.StatusConflict, transition("publish"))
+	assert.Equal(t, fiber.StatusOK, transition("restore"))
+	db.First(&course, courseID)
+	assert.Equal(t, models.CourseDraft, course.Status)
+}
+
+// courseReviewer returns the token of an admin other than the test user, who authors the test courses
+func courseReviewer(t *testing.T) string {
+	reviewer := models.User{Username: "course_reviewer", Email: "course_reviewer@example.com", PasswordHash: "hash"}
+	db.Where("username = ?", reviewer.Username).FirstOrCreate(&reviewer)
+	var adminRole models.Role
+	db.Where("name = ?", "admin").First(&adminRole)
+	db.Where(models.UserRole{UserID: reviewer.ID, RoleID: adminRole.ID}).FirstOrCreate(&models.UserRole{})
+	token, err := utils.GenerateJWTToken(&reviewer, cfg)
+	assert.NoError(t, err)
+	return token
+}
+
+func TestCourseVisibilityByStatus(t *testing.T) {
+	course := models.Course{
+		Title:          "Unreleased Metaethics",
+		AuthorID:       testUser.ID,
+		Lessons:        []models.Lesson{{Title: "Realism", SequenceOrder: 1}},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+	student := models.User{Username: "lifecycle_student", Email: "lifecycle_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	detailsPath := fmt.Sprintf("/api/courses/%d", course.ID)
+	progressPath := fmt.Sprintf("/api/courses/%d/progress", course.ID)
+	lesson := map[string]interface{}{"lesson_id": course.Lessons[0].ID}
+
+	// Drafts and courses under review don't exist for outsiders, and can't be enrolled in
+	for _, status := range []string{models.CourseDraft, models.CourseReview} {
+		db.Model(&course).Update("status", status)
+		code, _ := sendJSONAs(t, "GET", detailsPath, token, nil)
+		assert.Equal(t, fiber.StatusNotFound, code)
+		code, _ = sendJSONAs(t, "POST", progressPath, token, lesson)
+		assert.Equal(t, fiber.StatusNotFound, code)
+	}
+	var enrolled int64
+	db.Model(&models.UserCourseProgress{}).Where("user_id = ? AND course_id = ?", student.ID, course.ID).Count(&enrolled)
+	assert.Zero(t, enrolled)
+	// The author keeps working on the draft
+	code, _ := sendJSON(t, "GET", detailsPath, nil)
+	assert.Equal(t, fiber.StatusOK, code)
+
+	db.Model(&course).Update("status", models.CoursePublished)
+	code, _ = sendJSONAs(t, "POST", progressPath, token, lesson)
+	assert.Equal(t, fiber.StatusOK, code)
+
+	// Unpublishing keeps the course open to its students
+	db.Model(&course).Update("status", models.CourseDraft)
+	code, _ = sendJSONAs(t, "GET", detailsPath, token, nil)
+	assert.Equal(t, fiber.StatusOK, code)
+
+	// An archived course can still be read, but no progress is recorded, not even by its author
+	db.Model(&course).Update("status", models.CourseArchived)
+	code, _ = sendJSONAs(t, "GET", detailsPath, token, nil)
+	assert.Equal(t, fiber.StatusOK, code)
+	code, _ = sendJSONAs(t, "POST", progressPath, token, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusConflict, code)
+	code, _ = sendJSON(t, "POST", progressPath, lesson)
+	assert.Equal(t, fiber.StatusConflict, code)
+	var progress models.UserCourseProgress
+	db.Where("user_id = ? AND course_id = ?", student.ID, course.ID).First(&progress)
+	assert.Zero(t, progress.LessonsCompleted)
+}
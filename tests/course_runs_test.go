@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseRuns(t *testing.T) {
+	course := models.Course{
+		Title:          "Ethics for Cohorts",
+		Status:         models.CoursePublished,
+		AuthorID:       testUser.ID,
+		Lessons:        []models.Lesson{{Title: "Virtue", SequenceOrder: 1}, {Title: "Duty", SequenceOrder: 2}},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+
+	autumn := models.StudyGroup{Name: "ETH-A", Slug: "eth-a-runs"}
+	spring := models.StudyGroup{Name: "ETH-S", Slug: "eth-s-runs"}
+	db.Create(&autumn)
+	db.Create(&spring)
+
+	students := map[string]string{}
+	ids := map[string]uint{}
+	for name, group := range map[string]uint{"run_early": autumn.ID, "run_idle": autumn.ID, "run_late": spring.ID} {
+		student := models.User{Username: name, Email: name + "@example.com", PasswordHash: "hash"}
+		assert.NoError(t, db.Create(&student).Error)
+		db.Create(&models.StudyGroupMember{GroupID: group, UserID: student.ID})
+		token, err := utils.GenerateJWTToken(&student, cfg)
+		assert.NoError(t, err)
+		students[name], ids[name] = token, student.ID
+	}
+
+	runsPath := fmt.Sprintf("/api/admin/courses/%d/runs", course.ID)
+	progressPath := fmt.Sprintf("/api/courses/%d/progress", course.ID)
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format("2006-01-02") }
+
+	status, _ := sendJSONAs(t, "POST", runsPath, jwtToken, map[string]interface{}{"title": "Backwards", "start_date": day(5), "end_date": day(1)})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSONAs(t, "POST", runsPath, jwtToken, map[string]interface{}{"title": "Nobody", "group_id": 999999})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, created := sendJSONAs(t, "POST", runsPath, jwtToken, map[string]interface{}{
+		"title": "Autumn", "group_id": autumn.ID, "start_date": day(-1), "end_date": day(30),
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	autumnRun := created["data"].(map[string]interface{})
+	assert.Equal(t, models.ScheduleOpen, autumnRun["status"])
+
+	status, created = sendJSONAs(t, "POST", runsPath, jwtToken, map[string]interface{}{
+		"title": "Spring", "group_id": spring.ID, "start_date": day(60), "end_date": day(120),
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	springRun := created["data"].(map[string]interface{})
+	assert.Equal(t, models.ScheduleUpcoming, springRun["status"])
+
+	// Each cohort follows its own dates
+	status, _ = sendJSONAs(t, "POST", progressPath, students["run_early"], map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", progressPath, students["run_late"], map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	var progress models.UserCourseProgress
+	db.Where("user_id = ? AND course_id = ?", ids["run_early"], course.ID).First(&progress)
+	if assert.NotNil(t, progress.RunID) {
+		assert.Equal(t, uint(autumnRun["id"].(float64)), *progress.RunID)
+	}
+
+	status, details := sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d", course.ID), students["run_late"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	courseDetails := details["course"].(map[string]interface{})
+	assert.Equal(t, "Spring", courseDetails["run"].(map[string]interface{})["title"])
+	assert.Equal(t, models.ScheduleUpcoming, courseDetails["schedule"].(map[string]interface{})["status"])
+
+	// Roster lists the whole group, including members who haven't started
+	autumnPath := fmt.Sprintf("/api/courses/%d/runs/%d", course.ID, int(autumnRun["id"].(float64)))
+	status, result := sendJSONAs(t, "GET", autumnPath+"/roster", jwtToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	started := map[string]bool{}
+	for _, entry := range result["data"].(map[string]interface{})["roster"].([]interface{}) {
+		member := entry.(map[string]interface{})
+		started[member["username"].(string)] = member["started"].(bool)
+	}
+	assert.Equal(t, map[string]bool{"run_early": true, "run_idle": false}, started)
+
+	status, result = sendJSONAs(t, "GET", autumnPath+"/analytics", jwtToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	stats := result["data"].(map[string]interface{})["stats"].(map[string]interface{})
+	assert.Equal(t, float64(2), stats["roster_size"])
+	assert.Equal(t, float64(1), stats["total_enrollments"])
+	assert.Equal(t, float64(50), stats["avg_completion_rate"])
+
+	// Opening the spring run early lets its students in
+	springPath := fmt.Sprintf("%s/%d", runsPath, int(springRun["id"].(float64)))
+	status, result = sendJSONAs(t, "PATCH", springPath, jwtToken, map[string]interface{}{"start_date": nil})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Nil(t, result["data"].(map[string]interface{})["starts_at"])
+	status, _ = sendJSONAs(t, "POST", progressPath, students["run_late"], map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, result = sendJSONAs(t, "GET", runsPath, jwtToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, result["data"], 2)
+
+	// The group is taken by a run
+	status, _ = sendJSONAs(t, "DELETE", fmt.Sprintf("/api/admin/groups/%d", autumn.ID), jwtToken, nil)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, _ = sendJSONAs(t, "DELETE", springPath, jwtToken, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	db.Where("user_id = ? AND course_id = ?", ids["run_late"], course.ID).First(&progress)
+	assert.Nil(t, progress.RunID)
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseSchedule(t *testing.T) {
+	hash, _ := utils.Passwords.Hash("cohort123")
+	student := models.User{Username: "cohort_student", Email: "cohort_student@example.com", PasswordHash: hash}
+	db.Create(&student)
+	token, _ := utils.GenerateJWTToken(&student, cfg)
+
+	course := models.Course{Title: "Spring Cohort: Stoicism", AuthorID: testUser.ID, Status: models.CoursePublished}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: models.AccessPublic})
+	db.Create(&models.Lesson{CourseID: course.ID, Title: "Seneca", SequenceOrder: 1})
+
+	putSettings := func(payload interface{}) int {
+		status, _ := sendJSON(t, "PUT", fmt.Sprintf("/api/admin/courses/%d/settings", course.ID), payload)
+		return status
+	}
+	setSchedule := func(start, end string) int {
+		return putSettings(map[string]string{"start_date": start, "end_date": end})
+	}
+	asStudent := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+	progress := func() (int, map[string]interface{}) {
+		return asStudent("POST", fmt.Sprintf("/api/courses/%d/progress", course.ID))
+	}
+	schedule := func() map[string]interface{} {
+		_, result := asStudent("GET", fmt.Sprintf("/api/courses/%d", course.ID))
+		return result["course"].(map[string]interface{})["schedule"].(map[string]interface{})
+	}
+
+	assert.Equal(t, fiber.StatusUnprocessableEntity, setSchedule("next monday", ""))
+	assert.Equal(t, fiber.StatusUnprocessableEntity, setSchedule("2030-03-01", "2030-02-01"))
+
+	// Not started yet
+	opens := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+	assert.Equal(t, fiber.StatusOK, setSchedule(opens, time.Now().AddDate(0, 3, 0).Format("2006-01-02")))
+	status, result := progress()
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.NotNil(t, result["opens_at"])
+	assert.Equal(t, models.ScheduleUpcoming, schedule()["status"])
+
+	// null removes a date that was set, an omitted date stays
+	assert.Equal(t, fiber.StatusOK, putSettings(map[string]interface{}{"start_date": nil}))
+	assert.Equal(t, models.ScheduleOpen, schedule()["status"])
+	assert.NotNil(t, schedule()["closes_at"])
+
+	// The end date counts as a whole day
+	assert.Equal(t, fiber.StatusOK, setSchedule(time.Now().AddDate(0, 0, -7).Format("2006-01-02"), time.Now().Format("2006-01-02")))
+	status, _ = progress()
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, models.ScheduleOpen, schedule()["status"])
+
+	// Finished: progress is closed and the course leaves the catalog
+	assert.Equal(t, fiber.StatusOK, setSchedule(time.Now().AddDate(0, 0, -30).Format("2006-01-02"), time.Now().Add(-time.Hour).Format(time.RFC3339)))
+	status, result = progress()
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.NotNil(t, result["closed_at"])
+	assert.Equal(t, models.ScheduleClosed, schedule()["status"])
+
+	assert.NoError(t, jobs.ArchiveFinishedCourses(db)(context.Background()))
+	var archived models.Course
+	db.First(&archived, course.ID)
+	assert.Equal(t, models.CourseArchived, archived.Status)
+
+	// Courses without dates are left alone
+	open := models.Course{Title: "Self-paced Logic", AuthorID: testUser.ID, Status: models.CoursePublished}
+	db.Create(&open)
+	db.Create(&models.CourseAccessSettings{CourseID: open.ID, AccessLevel: models.AccessPublic})
+	assert.NoError(t, jobs.ArchiveFinishedCourses(db)(context.Background()))
+	db.First(&open, open.ID)
+	assert.Equal(t, models.CoursePublished, open.Status)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseVersions(t *testing.T) {
+	course := models.Course{Title: "Versioned Course", AuthorID: testUser.ID, Status: models.CourseDraft}
+	db.Create(&course)
+	intro := models.Lesson{CourseID: course.ID, Title: "Intro", Content: "First draft", SequenceOrder: 1}
+	db.Create(&intro)
+	base := fmt.Sprintf("/api/admin/courses/%d", course.ID)
+	reviewerToken := courseReviewer(t)
+
+	status, result := postJSONAs(t, reviewerToken, base+"/publish", map[string]interface{}{"changelog": "First run"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(1), result["data"].(map[string]interface{})["version"])
+
+	// Edit the lesson, add another one and publish again
+	postJSON(t, base+"/unpublish", nil)
+	db.Model(&intro).Update("content", "Rewritten")
+	db.Create(&models.Lesson{CourseID: course.ID, Title: "Ethics", SequenceOrder: 2})
+	status, result = postJSONAs(t, reviewerToken, base+"/publish", map[string]interface{}{"changelog": "Added ethics"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(2), result["data"].(map[string]interface{})["version"])
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/courses/%d/versions", course.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var versions struct {
+		Data []struct {
+			Number    int    `json:"number"`
+			Changelog string `json:"changelog"`
+			Changes   struct {
+				LessonsAdded  []string `json:"lessons_added"`
+				LessonsEdited []string `json:"lessons_edited"`
+			} `json:"changes"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&versions)
+	if assert.Len(t, versions.Data, 2) {
+		assert.Equal(t, 2, versions.Data[0].Number)
+		assert.Equal(t, "Added ethics", versions.Data[0].Changelog)
+		assert.Equal(t, []string{"Ethics"}, versions.Data[0].Changes.LessonsAdded)
+		assert.Equal(t, []string{"Intro"}, versions.Data[0].Changes.LessonsEdited)
+	}
+
+	// Rolling back to the first version restores the lesson text but leaves the new lesson alone
+	status, result = postJSON(t, base+"/versions/1/rollback", map[string]interface{}{})
+	assert.Equal(t, fiber.StatusOK, status)
+	db.First(&intro, intro.ID)
+	assert.Equal(t, "First draft", intro.Content)
+	var count int64
+	db.Model(&models.Lesson{}).Where("course_id = ?", course.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	status, _ = postJSON(t, base+"/versions/9/rollback", nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
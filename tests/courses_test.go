@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http/httptest"
+	"project/backend/models"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,6 +12,9 @@ import (
 )
 
 func TestCreateCourse(t *testing.T) {
+	db.FirstOrCreate(&models.University{}, models.University{Name: "Test University", Slug: "test-university"})
+	db.FirstOrCreate(&models.StudyGroup{}, models.StudyGroup{Name: "Students", Slug: "students"})
+
 	courseData := map[string]interface{}{
 		"title":           "Test Course",
 		"short_desc":      "Short description",
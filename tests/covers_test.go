@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"project/backend/models"
+	"project/backend/storage"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func uploadCover(t *testing.T, path string, fields map[string]string) (int, string) {
+	src := image.NewRGBA(image.Rect(0, 0, 1600, 1200))
+	for x := 0; x < 1600; x += 4 {
+		for y := 0; y < 1200; y += 4 {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 64, A: 255})
+		}
+	}
+	var img bytes.Buffer
+	jpeg.Encode(&img, src, nil)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	part, _ := form.CreateFormFile("cover", "cover.jpg")
+	part.Write(img.Bytes())
+	form.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	url, _ := result.Data["logo_url"].(string)
+	if url == "" {
+		url, _ = result.Data["url"].(string)
+	}
+	return resp.StatusCode, url
+}
+
+func TestCourseCover(t *testing.T) {
+	dir := t.TempDir()
+	previous := storage.Default
+	storage.Default = storage.NewDisk(dir, "/uploads")
+	defer func() { storage.Default = previous }()
+
+	course := models.Course{Title: "Aesthetics", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "public"})
+	path := fmt.Sprintf("/api/admin/courses/%d/cover", course.ID)
+
+	// A crop that isn't 16:9 is rejected
+	status, _ := uploadCover(t, path, map[string]string{"x": "0", "y": "0", "width": "800", "height": "800"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, url := uploadCover(t, path, map[string]string{"x": "100", "y": "200", "width": "1280", "height": "720"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.True(t, strings.HasPrefix(url, "/uploads/covers/courses/"))
+
+	stored, err := os.Open(filepath.Join(dir, strings.TrimPrefix(url, "/uploads/")))
+	if assert.NoError(t, err) {
+		defer stored.Close()
+		cfg, _, err := image.DecodeConfig(stored)
+		assert.NoError(t, err)
+		assert.Equal(t, 1280, cfg.Width)
+		assert.Equal(t, 720, cfg.Height)
+	}
+
+	// Picking a stock cover replaces the upload and removes its file
+	status, stockURL := uploadCover(t, "/api/admin/covers", map[string]string{"title": "Marble"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	var stock models.StockCover
+	db.Where("url = ?", stockURL).First(&stock)
+
+	body, _ := json.Marshal(map[string]interface{}{"stock_cover_id": stock.ID})
+	req := httptest.NewRequest("PUT", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	_, err = os.Stat(filepath.Join(dir, strings.TrimPrefix(url, "/uploads/")))
+	assert.True(t, os.IsNotExist(err))
+
+	// Catalog payloads carry the cover URL
+	detailsReq := httptest.NewRequest("GET", fmt.Sprintf("/api/courses/%d", course.ID), nil)
+	detailsReq.Header.Set("Authorization", jwtToken)
+	detailsResp, err := app.Test(detailsReq)
+	assert.NoError(t, err)
+
+	var details struct {
+		Course struct {
+			LogoURL string `json:"logo_url"`
+		} `json:"course"`
+	}
+	json.NewDecoder(detailsResp.Body).Decode(&details)
+	assert.Equal(t, stockURL, details.Course.LogoURL)
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityHistoryCursorPagination(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		db.Create(&models.LoginHistory{UserID: testUser.ID, LoginTime: base.Add(time.Duration(i) * time.Minute), Success: true})
+	}
+
+	seen := map[float64]bool{}
+	next := ""
+	pages := 0
+	for {
+		url := "/api/user/activity/history?limit=2"
+		if next != "" {
+			url += "&cursor=" + next
+		}
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", jwtToken)
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var result struct {
+			Data       []map[string]interface{} `json:"data"`
+			NextCursor string                   `json:"next_cursor"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		assert.LessOrEqual(t, len(result.Data), 2)
+
+		for _, item := range result.Data {
+			id := item["ID"].(float64)
+			assert.False(t, seen[id], "item returned twice")
+			seen[id] = true
+		}
+
+		pages++
+		next = result.NextCursor
+		if next == "" || pages > 10 {
+			break
+		}
+	}
+
+	assert.GreaterOrEqual(t, len(seen), 3)
+
+	badReq := httptest.NewRequest("GET", "/api/user/activity/history?cursor=not-a-cursor", nil)
+	badReq.Header.Set("Authorization", jwtToken)
+	badResp, err := app.Test(badReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, badResp.StatusCode)
+}
+
+func TestCourseCommentsCursorPagination(t *testing.T) {
+	course := models.Course{Title: "Cursor Comments Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	for i := 0; i < 3; i++ {
+		db.Create(&models.CourseComment{CourseID: course.ID, UserID: testUser.ID, Text: fmt.Sprintf("Comment %d", i)})
+	}
+
+	get := func(url string) (*http.Response, []byte) {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	// The old route keeps its plain array and announces the successor
+	resp, body := get(fmt.Sprintf("/api/comments/course/%d", course.ID))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Deprecation"))
+	assert.Contains(t, resp.Header.Get(fiber.HeaderLink), "/api/courses/:id/comments")
+	var all []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(body, &all))
+	assert.Len(t, all, 3)
+
+	resp, body = get(fmt.Sprintf("/api/courses/%d/comments?limit=2", course.ID))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var page struct {
+		Data       []map[string]interface{} `json:"data"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &page))
+	assert.Len(t, page.Data, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	_, body = get(fmt.Sprintf("/api/courses/%d/comments?limit=2&cursor=%s", course.ID, page.NextCursor))
+	assert.NoError(t, json.Unmarshal(body, &page))
+	assert.Len(t, page.Data, 1)
+	assert.Empty(t, page.NextCursor)
+}
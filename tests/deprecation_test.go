@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/deprecation"
+	"project/backend/middleware"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecatedRoutes(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/courses/", nil)
+	req.Header.Set("Authorization", jwtToken)
+	req.Header.Set("User-Agent", "legacy-dashboard/1.0")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf("@%d", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC).Unix()), resp.Header.Get("Deprecation"))
+	assert.Equal(t, "Fri, 16 Apr 2027 00:00:00 GMT", resp.Header.Get("Sunset"))
+	assert.Equal(t, `</api/user/courses>; rel="successor-version"`, resp.Header.Get("Link"))
+
+	// The successor itself carries no deprecation headers
+	req = httptest.NewRequest("GET", "/api/user/courses", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, _ = app.Test(req)
+	assert.Empty(t, resp.Header.Get("Deprecation"))
+
+	// The report shows who still calls the route
+	req = httptest.NewRequest("GET", "/api/admin/deprecations", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var result struct {
+		Data []deprecation.RouteReport `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	var courses, testAnalytics *deprecation.RouteReport
+	for i := range result.Data {
+		switch result.Data[i].Route {
+		case "GET /api/courses/":
+			courses = &result.Data[i]
+		case "GET /api/tests/:id/analytics":
+			testAnalytics = &result.Data[i]
+		}
+	}
+	// Routes are listed from startup, before anyone calls them
+	if assert.NotNil(t, testAnalytics) {
+		assert.Equal(t, "/api/tests/:id/analytics/students", testAnalytics.Successor)
+	}
+	if assert.NotNil(t, courses) {
+		assert.Equal(t, "/api/user/courses", courses.Successor)
+		assert.GreaterOrEqual(t, courses.Calls, int64(1))
+		if assert.NotEmpty(t, courses.Clients) {
+			assert.Equal(t, fmt.Sprintf("user:%d", testUser.ID), courses.Clients[0].Client)
+			assert.Equal(t, "legacy-dashboard/1.0", courses.Clients[0].UserAgent)
+		}
+	}
+
+	// After the sunset the route is gone, but the caller still learns where to go
+	sunsetApp := fiber.New()
+	sunsetApp.Get("/api/old", middleware.Deprecated("GET /api/old", deprecation.Notice{
+		Since:     time.Now().AddDate(-1, 0, 0),
+		Sunset:    time.Now().Add(-time.Hour),
+		Successor: "/api/new",
+	}), func(c *fiber.Ctx) error { return c.SendString("still here") })
+	resp, err = sunsetApp.Test(httptest.NewRequest("GET", "/api/old", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusGone, resp.StatusCode)
+	assert.Equal(t, `</api/new>; rel="successor-version"`, resp.Header.Get("Link"))
+}
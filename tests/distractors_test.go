@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/backend/llm"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLLM отвечает заранее заданным текстом, запоминает последний запрос и считает вызовы
+type fakeLLM struct {
+	reply  string
+	err    error
+	prompt string
+	calls  int
+}
+
+func (f *fakeLLM) Complete(_ context.Context, _, prompt string) (string, error) {
+	f.prompt = prompt
+	f.calls++
+	return f.reply, f.err
+}
+
+func TestDistractorSuggestions(t *testing.T) {
+	previous := llm.Default
+	defer func() { llm.Default = previous }()
+
+	test := models.Test{Title: "Ethics Quiz", AuthorID: testUser.ID}
+	db.Create(&test)
+	question := models.TestQuestion{TestID: test.ID, Title: "Q1", Question: "Who wrote the Nicomachean Ethics?",
+		Options: `["Aristotle","Plato"]`, CorrectAnswer: 0}
+	db.Create(&question)
+	questionPath := fmt.Sprintf("/api/admin/tests/%d/questions/%d/distractors", test.ID, question.ID)
+
+	llm.Default = llm.Disabled{}
+	status, _ := postJSON(t, questionPath, map[string]interface{}{})
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+
+	// Repeats of the correct answer and of existing options are dropped
+	fake := &fakeLLM{reply: "Sure:\n[\"Plato\", \"Kant\", \"aristotle\", \"Kant\", \"Hume\", \"Spinoza\"]"}
+	llm.Default = fake
+	status, result := postJSON(t, questionPath, map[string]interface{}{"count": 2})
+	assert.Equal(t, fiber.StatusOK, status)
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, "Aristotle", data["correct_answer"])
+	assert.Equal(t, []interface{}{"Kant", "Hume"}, data["suggestions"])
+	assert.Contains(t, fake.prompt, "Nicomachean")
+	assert.Contains(t, fake.prompt, "Plato")
+
+	// Suggestions are never saved into the question
+	var stored models.TestQuestion
+	db.First(&stored, question.ID)
+	assert.Equal(t, `["Aristotle","Plato"]`, stored.Options)
+
+	// Drafts that aren't saved yet
+	draftPath := fmt.Sprintf("/api/admin/tests/%d/distractors", test.ID)
+	status, _ = postJSON(t, draftPath, map[string]interface{}{"question": "What is the good life?"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, result = postJSON(t, draftPath, map[string]interface{}{"question": "Who wrote Leviathan?", "correct_answer": "Hobbes"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, result["data"].(map[string]interface{})["suggestions"], 3)
+
+	status, _ = postJSON(t, draftPath, map[string]interface{}{"question": "Who wrote Leviathan?", "correct_answer": "Hobbes", "count": 50})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	fake.reply = "I can't help with that."
+	status, _ = postJSON(t, questionPath, nil)
+	assert.Equal(t, fiber.StatusBadGateway, status)
+	fake.err = errors.New("timeout")
+	status, _ = postJSON(t, questionPath, nil)
+	assert.Equal(t, fiber.StatusBadGateway, status)
+}
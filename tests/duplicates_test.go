@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/llm"
+	"project/backend/models"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEmbedder кладет тексты с маркером в одну точку, остальные — в разные стороны
+type fakeEmbedder struct {
+	fakeLLM
+	next int
+}
+
+func (f *fakeEmbedder) EmbeddingModel() string { return "fake-embeddings" }
+
+func (f *fakeEmbedder) Embed(_ context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vector := make([]float64, 512)
+		switch {
+		case strings.Contains(text, "Meditations duplicate"):
+			vector[0] = 1
+		case strings.Contains(text, "Dichotomy of control"):
+			vector[1] = 1
+		default:
+			f.next++
+			vector[2+f.next%510] = 1
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func TestDuplicateContentReport(t *testing.T) {
+	previous := llm.Default
+	defer func() { llm.Default = previous }()
+
+	get := func(path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	llm.Default = llm.Disabled{}
+	status, _ := get("/api/admin/reports/duplicates")
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+
+	original := models.Course{Title: "Stoicism 101", Description: "Meditations duplicate: a reading of Marcus Aurelius", AuthorID: testUser.ID}
+	copied := models.Course{Title: "Intro to Stoicism", Description: "Meditations duplicate: a reading of Marcus Aurelius", AuthorID: testUser.ID}
+	db.Create(&original)
+	db.Create(&copied)
+	db.Create(&models.Lesson{CourseID: original.ID, Title: "Control", Content: "Dichotomy of control in Epictetus"})
+	db.Create(&models.Lesson{CourseID: original.ID, Title: "Control again", Content: "Dichotomy of control, revisited"})
+	db.Create(&models.Lesson{CourseID: copied.ID, Title: "What is up to us", Content: "Dichotomy of control explained"})
+
+	llm.Default = &fakeEmbedder{}
+	for i := 0; i < 100; i++ {
+		status, result := postJSON(t, "/api/admin/reports/duplicates/refresh", nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		if result["data"].(map[string]interface{})["pending"].(float64) == 0 {
+			break
+		}
+	}
+
+	status, _ = get("/api/admin/reports/duplicates?threshold=2")
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	// Pairs below the floor aren't stored, so the report can't go lower
+	status, _ = get("/api/admin/reports/duplicates?threshold=0.5")
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, result := get("/api/admin/reports/duplicates")
+	assert.Equal(t, fiber.StatusOK, status)
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, float64(0), data["pending"])
+
+	var pair map[string]interface{}
+	for _, item := range data["pairs"].([]interface{}) {
+		candidate := item.(map[string]interface{})
+		courses := candidate["courses"].([]interface{})
+		ids := []float64{courses[0].(map[string]interface{})["id"].(float64), courses[1].(map[string]interface{})["id"].(float64)}
+		if ids[0] == float64(original.ID) && ids[1] == float64(copied.ID) {
+			pair = candidate
+		}
+	}
+	if assert.NotNil(t, pair) {
+		assert.InDelta(t, 1, pair["description_similarity"], 0.0001)
+		// Both lessons of the original match the single lesson of the copy; lessons of one course aren't paired
+		assert.Len(t, pair["duplicated_lessons"], 2)
+		assert.Equal(t, float64(1), pair["lesson_share"])
+	}
+
+	_, result = get("/api/admin/reports/duplicates?page_size=1")
+	assert.Len(t, result["data"].(map[string]interface{})["pairs"], 1)
+	assert.Equal(t, float64(1), result["meta"].(map[string]interface{})["page_size"])
+
+	// Deleted courses drop out of the report
+	db.Delete(&copied)
+	_, result = get("/api/admin/reports/duplicates")
+	for _, item := range result["data"].(map[string]interface{})["pairs"].([]interface{}) {
+		for _, course := range item.(map[string]interface{})["courses"].([]interface{}) {
+			assert.NotEqual(t, float64(copied.ID), course.(map[string]interface{})["id"])
+		}
+	}
+}
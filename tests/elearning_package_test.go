@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"project/backend/models"
+	"project/backend/storage"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestELearningPackageImport(t *testing.T) {
+	dir := t.TempDir()
+	previous := storage.Default
+	storage.Default = storage.NewDisk(dir, "/uploads")
+	defer func() { storage.Default = previous }()
+
+	zipped := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		for name, content := range files {
+			w, _ := archive.Create(name)
+			w.Write([]byte(content))
+		}
+		archive.Close()
+		return buf.Bytes()
+	}
+	upload := func(data []byte) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/api/admin/courses/import/package", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/zip")
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	// A SCORM 1.2 package zipped together with its folder
+	status, result := upload(zipped(map[string]string{
+		"kant/imsmanifest.xml": `<?xml version="1.0"?>
+<manifest identifier="kant" xmlns="http://www.imsproject.org/xsd/imscp_rootv1p1p2" xmlns:adlcp="http://www.adlnet.org/xsd/adlcp_rootv1p2">
+  <metadata><schema>ADL SCORM</schema><schemaversion>1.2</schemaversion></metadata>
+  <organizations default="org">
+    <organization identifier="org">
+      <title>Kant's Critiques</title>
+      <item identifier="intro" identifierref="res_intro"><title>Introduction</title></item>
+      <item identifier="first">
+        <title>Critique of Pure Reason</title>
+        <item identifier="aesthetic" identifierref="res_aesthetic"><title>Transcendental Aesthetic</title></item>
+        <item identifier="lecture" identifierref="res_video"><title>Lecture</title></item>
+      </item>
+    </organization>
+  </organizations>
+  <resources>
+    <resource identifier="res_intro" type="webcontent" adlcp:scormtype="sco" href="intro.html"/>
+    <resource identifier="res_aesthetic" type="webcontent" adlcp:scormtype="sco" href="pages/aesthetic.html?lang=en"/>
+    <resource identifier="res_video" type="webcontent" adlcp:scormtype="asset" href="media/lecture.mp4"/>
+  </resources>
+</manifest>`,
+		"kant/intro.html": `<html><head><title>Intro</title><script src="scorm_api.js"></script></head>
+<body onload="init()"><h1>Kant</h1><img src="media/portrait.png" alt="Kant"><script>LMSInitialize("")</script><a href="https://example.com">More</a></body></html>`,
+		"kant/pages/aesthetic.html": `<body><p>Space and time</p><img src='../media/portrait.png#top'></body>`,
+		"kant/media/portrait.png":   "png",
+		"kant/media/lecture.mp4":    "mp4",
+		"kant/scorm_api.js":         "function LMSInitialize() {}",
+	}))
+	assert.Equal(t, fiber.StatusCreated, status)
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, "Kant's Critiques", data["title"])
+	assert.Equal(t, "scorm_1.2", data["standard"])
+	assert.Equal(t, float64(1), data["modules"])
+	assert.Equal(t, float64(3), data["lessons"])
+	assert.Equal(t, float64(2), data["assets"])
+
+	var course models.Course
+	db.First(&course, uint(data["id"].(float64)))
+	assert.Equal(t, models.CourseDraft, course.Status)
+
+	var lessons []models.Lesson
+	db.Where("course_id = ?", course.ID).Order("sequence_order").Find(&lessons)
+	if assert.Len(t, lessons, 3) {
+		// Scripts are dropped, assets point to storage, the shared image is stored once
+		assert.Nil(t, lessons[0].ModuleID)
+		assert.NotContains(t, lessons[0].Content, "<script")
+		assert.Contains(t, lessons[0].Content, `<h1>Kant</h1>`)
+		assert.Contains(t, lessons[0].Content, `href="https://example.com"`)
+		assert.Regexp(t, `src="/uploads/packages/\d+-\d+/media/portrait\.png"`, lessons[0].Content)
+		assert.NotNil(t, lessons[1].ModuleID)
+		assert.Regexp(t, `src="/uploads/packages/\d+-\d+/media/portrait\.png#top"`, lessons[1].Content)
+		assert.Contains(t, lessons[2].Content, "<video controls")
+	}
+
+	// Only media is copied, the package's scripts never reach the public storage
+	stored, _ := filepath.Glob(filepath.Join(dir, "packages", "*", "media", "*"))
+	assert.Len(t, stored, 2)
+	scripts, _ := filepath.Glob(filepath.Join(dir, "packages", "*", "*.js"))
+	assert.Empty(t, scripts)
+
+	// xAPI packages describe their activities in tincan.xml
+	status, result = upload(zipped(map[string]string{
+		"tincan.xml": `<?xml version="1.0"?>
+<tincan xmlns="http://projecttincan.com/tincan.xsd"><activities>
+  <activity id="http://example.com/hume" type="http://adlnet.gov/expapi/activities/course">
+    <name>Hume on Causation</name><description lang="en-US">Constant conjunction</description>
+    <launch lang="en-US">index.html</launch>
+  </activity>
+</activities></tincan>`,
+		"index.html": "<p>Billiard balls</p>",
+	}))
+	assert.Equal(t, fiber.StatusCreated, status)
+	data = result["data"].(map[string]interface{})
+	assert.Equal(t, "xapi", data["standard"])
+	assert.Equal(t, "Hume on Causation", data["title"])
+
+	var hume models.Lesson
+	db.Where("course_id = ?", uint(data["id"].(float64))).First(&hume)
+	assert.Equal(t, "<p>Billiard balls</p>", strings.TrimSpace(hume.Content))
+
+	status, _ = upload(zipped(map[string]string{"readme.txt": "not a package"}))
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"encoding/json"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeEmail(t *testing.T) {
+	user := models.User{Username: "email_changer", Email: "old.address@example.com", PasswordHash: "x", Active: true}
+	db.Create(&user)
+	token, err := utils.GenerateJWTToken(&user, cfg)
+	assert.NoError(t, err)
+
+	send := func(method, path string, payload interface{}) (int, map[string]interface{}) {
+		return sendJSONAs(t, method, path, token, payload)
+	}
+
+	status, result := send("PATCH", "/api/user/profile", map[string]string{"email": "New.Address@example.com"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "new.address@example.com", result["data"].(map[string]interface{})["pending_email"])
+
+	// Nothing changes until the new address is confirmed
+	var stored models.User
+	db.First(&stored, user.ID)
+	assert.Equal(t, "old.address@example.com", stored.Email)
+
+	var message models.OutboxMessage
+	db.Where("kind = ? AND payload LIKE ?", outbox.KindEmail, "%new.address@example.com%").Last(&message)
+	var email outbox.EmailMessage
+	json.Unmarshal([]byte(message.Payload), &email)
+	code := email.Body[strings.LastIndex(email.Body, " ")+1:]
+
+	status, _ = send("POST", "/api/user/email/confirm", map[string]string{"token": "wrong"})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, result = send("POST", "/api/user/email/confirm", map[string]string{"token": code})
+	assert.Equal(t, fiber.StatusOK, status)
+	db.First(&stored, user.ID)
+	assert.Equal(t, "new.address@example.com", stored.Email)
+
+	// The old address is told about the change, and the code can't be reused
+	var notices int64
+	db.Model(&models.OutboxMessage{}).
+		Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%old.address@example.com%", "%was changed%").
+		Count(&notices)
+	assert.Equal(t, int64(1), notices)
+	status, _ = send("POST", "/api/user/email/confirm", map[string]string{"token": code})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/integrity"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExamIntegrityReport(t *testing.T) {
+	test := models.Test{
+		Title:    "Integrity Exam",
+		AuthorID: testUser.ID,
+		Questions: []models.TestQuestion{
+			{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1}, {Question: "Q2", Options: `["a","b"]`, CorrectAnswer: 1},
+			{Question: "Q3", Options: `["a","b"]`, CorrectAnswer: 1}, {Question: "Q4", Options: `["a","b"]`, CorrectAnswer: 1},
+		},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 1},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+	session := models.ExamSession{TestID: test.ID, Title: "Finals", StartsAt: time.Now().Add(-time.Hour), EndsAt: time.Now().Add(time.Hour), CreatedBy: testUser.ID}
+	assert.NoError(t, db.Create(&session).Error)
+
+	// Two students copy the same wrong answers, one of them in a minute; the others take their time
+	picks := [][]int{{0, 0, 0, 0}, {0, 0, 0, 0}, {1, 1, 1, 1}, {1, 0, 1, 1}}
+	var students []models.User
+	for i, pick := range picks {
+		student := models.User{Username: fmt.Sprintf("integrity_%d", i), Email: fmt.Sprintf("integrity_%d@example.com", i), PasswordHash: "hash"}
+		assert.NoError(t, db.Create(&student).Error)
+		students = append(students, student)
+		token, err := utils.GenerateJWTToken(&student, cfg)
+		assert.NoError(t, err)
+
+		status, _ := sendJSONAs(t, "GET", fmt.Sprintf("/api/tests/%d", test.ID), token, nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		startedAt := time.Now().Add(-20 * time.Minute)
+		if i == 0 {
+			startedAt = time.Now().Add(-time.Minute)
+		}
+		db.Model(&models.ExamAttempt{}).Where("session_id = ? AND user_id = ?", session.ID, student.ID).Update("started_at", startedAt)
+
+		answers := []map[string]interface{}{}
+		for q, answer := range pick {
+			answers = append(answers, map[string]interface{}{"question_id": test.Questions[q].ID, "answer": answer})
+		}
+		status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), token, map[string]interface{}{"answers": answers})
+		assert.Equal(t, fiber.StatusOK, status)
+	}
+
+	reportPath := fmt.Sprintf("/api/exam-sessions/%d/integrity-report", session.ID)
+	status, _ := sendJSONAs(t, "GET", reportPath, jwtToken, nil)
+	assert.Equal(t, fiber.StatusConflict, status)
+	studentToken, _ := utils.GenerateJWTToken(&students[2], cfg)
+	status, _ = sendJSONAs(t, "GET", reportPath, studentToken, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	// Once the session is over the report is downloadable
+	db.Model(&session).Update("ends_at", time.Now().Add(-time.Minute))
+	req := httptest.NewRequest("GET", reportPath, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+
+	var report integrity.Report
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, 4, report.Attempts)
+	assert.Equal(t, 4, report.Scores.Session.Count)
+	assert.Equal(t, 2, report.Scores.Session.Histogram[0])
+	assert.Equal(t, 1, report.Scores.Session.Histogram[9])
+	if assert.Len(t, report.TimeAnomalies, 1) {
+		assert.Equal(t, students[0].ID, report.TimeAnomalies[0].UserID)
+	}
+	if assert.Len(t, report.SimilarPairs, 1) {
+		assert.Equal(t, students[0].ID, report.SimilarPairs[0].A.UserID)
+		assert.Equal(t, students[1].ID, report.SimilarPairs[0].B.UserID)
+		assert.Equal(t, 4, report.SimilarPairs[0].SharedWrong)
+	}
+	// Test requests all come from one address
+	if assert.Len(t, report.IPClusters, 1) {
+		assert.Len(t, report.IPClusters[0].Users, 4)
+	}
+
+	var stored models.ExamIntegrityReport
+	assert.NoError(t, db.Where("session_id = ?", session.ID).First(&stored).Error)
+	assert.Equal(t, 4, stored.Flagged)
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExamPauses(t *testing.T) {
+	test := models.Test{
+		Title:          "Long Exam",
+		AuthorID:       testUser.ID,
+		Questions:      []models.TestQuestion{{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1}},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 3},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+
+	student := models.User{Username: "pause_student", Email: "pause_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	testPath := fmt.Sprintf("/api/tests/%d", test.ID)
+	answers := map[string]interface{}{"answers": []map[string]interface{}{{"question_id": test.Questions[0].ID, "answer": 1}}}
+
+	status, created := sendJSONAs(t, "POST", fmt.Sprintf("/api/admin/tests/%d/sessions", test.ID), jwtToken, map[string]interface{}{
+		"starts_at":        time.Now().Add(-time.Minute),
+		"ends_at":          time.Now().Add(3 * time.Hour),
+		"duration_minutes": 30,
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	sessionID := uint(created["data"].(map[string]interface{})["id"].(float64))
+
+	status, _ = sendJSONAs(t, "GET", testPath, token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Pausing is off until the author sets a budget
+	status, _ = sendJSONAs(t, "POST", testPath+"/pause", token, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/tests/%d/settings", test.ID), jwtToken, map[string]int{"attempts_allowed": 3, "max_pause_minutes": -1})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/tests/%d/settings", test.ID), jwtToken, map[string]int{"attempts_allowed": 3, "max_pause_minutes": 10})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, paused := sendJSONAs(t, "POST", testPath+"/pause", token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	resumeToken := paused["data"].(map[string]interface{})["resume_token"].(string)
+	assert.NotEmpty(t, resumeToken)
+
+	// While paused the questions are hidden and answers aren't accepted
+	status, details := sendJSONAs(t, "GET", testPath, token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "paused", details["exam"].(map[string]interface{})["status"])
+	assert.NotContains(t, details["test"].(map[string]interface{})["questions"].([]interface{})[0], "question")
+	status, _ = sendJSONAs(t, "POST", testPath+"/progress", token, answers)
+	assert.Equal(t, fiber.StatusConflict, status)
+	status, _ = sendJSONAs(t, "POST", testPath+"/pause", token, nil)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	status, _ = sendJSONAs(t, "POST", testPath+"/resume", token, map[string]string{"resume_token": "wrong"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, resumed := sendJSONAs(t, "POST", testPath+"/resume", token, map[string]string{"resume_token": resumeToken})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "in_progress", resumed["data"].(map[string]interface{})["status"])
+
+	// Ten paused minutes used up the budget and moved the personal deadline
+	db.Model(&models.ExamAttempt{}).Where("session_id = ? AND user_id = ?", sessionID, student.ID).
+		Updates(map[string]interface{}{"paused_seconds": 600, "started_at": time.Now().Add(-35 * time.Minute)})
+	status, _ = sendJSONAs(t, "POST", testPath+"/pause", token, nil)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	status, _ = sendJSONAs(t, "POST", testPath+"/progress", token, answers)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", testPath+"/pause", token, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProctoredExamSession(t *testing.T) {
+	test := models.Test{
+		Title:          "Proctored Exam",
+		AuthorID:       testUser.ID,
+		Questions:      []models.TestQuestion{{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1}},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 3},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+
+	proctor := models.User{Username: "exam_proctor", Email: "exam_proctor@example.com", PasswordHash: "hash"}
+	late := models.User{Username: "exam_late", Email: "exam_late@example.com", PasswordHash: "hash"}
+	cheater := models.User{Username: "exam_cheater", Email: "exam_cheater@example.com", PasswordHash: "hash"}
+	for _, user := range []*models.User{&proctor, &late, &cheater} {
+		assert.NoError(t, db.Create(user).Error)
+	}
+	tokenFor := func(user *models.User) string {
+		token, err := utils.GenerateJWTToken(user, cfg)
+		assert.NoError(t, err)
+		return token
+	}
+	proctorToken, lateToken, cheaterToken := tokenFor(&proctor), tokenFor(&late), tokenFor(&cheater)
+
+	answers := map[string]interface{}{"answers": []map[string]interface{}{{"question_id": test.Questions[0].ID, "answer": 1}}}
+
+	status, created := sendJSONAs(t, "POST", fmt.Sprintf("/api/admin/tests/%d/sessions", test.ID), jwtToken, map[string]interface{}{
+		"starts_at":        time.Now().Add(-time.Minute),
+		"ends_at":          time.Now().Add(time.Hour),
+		"duration_minutes": 30,
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	sessionID := uint(created["data"].(map[string]interface{})["id"].(float64))
+	sessionPath := fmt.Sprintf("/api/exam-sessions/%d", sessionID)
+
+	// Proctors are assigned by the test author, not by themselves
+	status, _ = sendJSONAs(t, "POST", sessionPath+"/proctors", proctorToken, map[string]interface{}{"user_id": proctor.ID})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = sendJSONAs(t, "POST", sessionPath+"/proctors", jwtToken, map[string]interface{}{"user_id": proctor.ID})
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// Opening the test starts the clock
+	for _, token := range []string{lateToken, cheaterToken} {
+		status, details := sendJSONAs(t, "GET", fmt.Sprintf("/api/tests/%d", test.ID), token, nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, "in_progress", details["exam"].(map[string]interface{})["status"])
+	}
+
+	// The late student ran out of time; a proctor gives them ten more minutes
+	db.Model(&models.ExamAttempt{}).Where("session_id = ? AND user_id = ?", sessionID, late.ID).
+		Update("started_at", time.Now().Add(-35*time.Minute))
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), lateToken, answers)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, extended := sendJSONAs(t, "POST", fmt.Sprintf("%s/attempts/%d/extend", sessionPath, late.ID), proctorToken, map[string]int{"minutes": 10})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(10), extended["data"].(map[string]interface{})["extra_minutes"])
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), lateToken, answers)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// The cheater's submitted result is voided
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), cheaterToken, answers)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("%s/attempts/%d/invalidate", sessionPath, cheater.ID), proctorToken, map[string]string{"reason": "Phone on the desk"})
+	assert.Equal(t, fiber.StatusNoContent, status)
+
+	var progress models.UserTestProgress
+	db.Where("user_id = ? AND test_id = ?", cheater.ID, test.ID).First(&progress)
+	assert.Equal(t, float64(0), progress.Score)
+	assert.Equal(t, 1, progress.AttemptsUsed)
+
+	status, live := sendJSONAs(t, "GET", sessionPath+"/attempts", proctorToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	statuses := map[string]string{}
+	for _, item := range live["data"].(map[string]interface{})["attempts"].([]interface{}) {
+		attempt := item.(map[string]interface{})
+		statuses[attempt["username"].(string)] = attempt["status"].(string)
+	}
+	assert.Equal(t, map[string]string{"exam_late": "submitted", "exam_cheater": "invalidated"}, statuses)
+
+	// Submitting without opening the test still goes through the session's clock, once
+	direct := models.User{Username: "exam_direct", Email: "exam_direct@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&direct).Error)
+	directToken := tokenFor(&direct)
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), directToken, answers)
+	assert.Equal(t, fiber.StatusOK, status)
+	var started models.ExamAttempt
+	assert.NoError(t, db.Where("session_id = ? AND user_id = ?", sessionID, direct.ID).First(&started).Error)
+	assert.NotNil(t, started.SubmittedAt)
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), directToken, answers)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	// Proctoring doesn't grant editing rights
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/tests/%d/description", test.ID), proctorToken, map[string]string{"title": "Changed"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+}
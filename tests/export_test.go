@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCourseGradebook(t *testing.T) {
+	course := models.Course{Title: "Gradebook Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.UserCourseProgress{
+		UserID:           testUser.ID,
+		CourseID:         course.ID,
+		LessonsCompleted: 3,
+		HoursSpent:       1.5,
+		CompletionRate:   60,
+	})
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/admin/courses/%d/export", course.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "lessons_completed", records[0][5])
+	assert.Equal(t, "testuser", records[1][1])
+	assert.Equal(t, "3", records[1][5])
+	assert.Equal(t, "60.00", records[1][7])
+}
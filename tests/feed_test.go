@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserActivityFeed(t *testing.T) {
+	course := models.Course{
+		Title:    "Ethics 101",
+		Status:   models.CoursePublished,
+		AuthorID: testUser.ID,
+		Lessons: []models.Lesson{
+			{Title: "Lesson 2", SequenceOrder: 2},
+			{Title: "Lesson 1", SequenceOrder: 1},
+		},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+
+	student := models.User{Username: "feed_student", Email: "feed_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	send := func(method, path string, payload interface{}) (int, map[string]interface{}) {
+		return sendJSONAs(t, method, path, token, payload)
+	}
+
+	progressPath := fmt.Sprintf("/api/courses/%d/progress", course.ID)
+	for i := 0; i < 2; i++ {
+		status, _ := send("POST", progressPath, map[string]interface{}{"mark_completed": true, "hours_spent": 1.5})
+		assert.Equal(t, fiber.StatusOK, status)
+	}
+	status, _ := send("POST", fmt.Sprintf("/api/comments/course/%d", course.ID), map[string]interface{}{"text": "Great course"})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Newest first, two pages
+	var messages, types []string
+	next := ""
+	for page := 0; page < 3; page++ {
+		path := "/api/user/feed?limit=3"
+		if next != "" {
+			path += "&cursor=" + next
+		}
+		status, result := send("GET", path, nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		for _, item := range result["data"].([]interface{}) {
+			event := item.(map[string]interface{})
+			messages = append(messages, event["message"].(string))
+			types = append(types, event["type"].(string))
+		}
+		next, _ = result["next_cursor"].(string)
+		if next == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{
+		models.ActivityComment,
+		models.ActivityCourseComplete,
+		models.ActivityLessonComplete,
+		models.ActivityLessonComplete,
+		models.ActivityCourseStart,
+	}, types)
+	assert.Equal(t, "completed Lesson 2 of Ethics 101", messages[2])
+	assert.Equal(t, "completed Lesson 1 of Ethics 101", messages[3])
+	assert.Equal(t, "started Ethics 101", messages[4])
+
+	status, filtered := send("GET", "/api/user/feed?type="+models.ActivityLessonComplete, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, filtered["data"], 2)
+}
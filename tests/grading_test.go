@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedCourseGrade(t *testing.T) {
+	university := models.University{Name: "Grading University", Slug: "grading-university"}
+	assert.NoError(t, db.Create(&university).Error)
+	course := models.Course{
+		Title:          "Graded Course",
+		Status:         models.CoursePublished,
+		AuthorID:       testUser.ID,
+		UniversityID:   &university.ID,
+		Lessons:        []models.Lesson{{Title: "Lesson 1", SequenceOrder: 1}, {Title: "Lesson 2", SequenceOrder: 2}},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+	test := models.Test{
+		Title:          "Graded Quiz",
+		AuthorID:       testUser.ID,
+		Questions:      []models.TestQuestion{{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1}},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+
+	student := models.User{Username: "graded_student", Email: "graded_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	studentToken, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	gradingPath := fmt.Sprintf("/api/admin/courses/%d/grading", course.ID)
+
+	status, _ := sendJSONAs(t, "PUT", gradingPath, jwtToken, map[string]interface{}{"components": []map[string]interface{}{
+		{"kind": "tests", "weight": 60, "test_ids": []uint{test.ID}},
+		{"kind": "manual", "weight": 30},
+	}})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// Someone else's test can't be pulled into the grade
+	foreign := models.Test{Title: "Foreign Quiz", AuthorID: student.ID}
+	assert.NoError(t, db.Create(&foreign).Error)
+	status, _ = sendJSONAs(t, "PUT", gradingPath, jwtToken, map[string]interface{}{"components": []map[string]interface{}{
+		{"kind": "tests", "weight": 100, "test_ids": []uint{foreign.ID}},
+	}})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, saved := sendJSONAs(t, "PUT", gradingPath, jwtToken, map[string]interface{}{"components": []map[string]interface{}{
+		{"name": "Tests", "kind": "tests", "weight": 60, "test_ids": []uint{test.ID}},
+		{"name": "Assignments", "kind": "manual", "weight": 30},
+		{"name": "Participation", "kind": "participation", "weight": 10, "target": 2},
+	}})
+	assert.Equal(t, fiber.StatusOK, status)
+	components := saved["data"].(map[string]interface{})["components"].([]interface{})
+	assignmentsID := uint(components[1].(map[string]interface{})["id"].(float64))
+
+	// Full marks on the quiz, half the participation target, assignments graded at 50
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/courses/%d/progress", course.ID), studentToken, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), studentToken, map[string]interface{}{
+		"answers": []map[string]interface{}{{"question_id": test.Questions[0].ID, "answer": 1}},
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/comments/course/%d", course.ID), studentToken, map[string]interface{}{"text": "Nice"})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	entryPath := fmt.Sprintf("/api/courses/%d/grading/%d/grades/%d", course.ID, assignmentsID, student.ID)
+	status, _ = sendJSONAs(t, "PUT", entryPath, studentToken, map[string]float64{"score": 100})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, graded := sendJSONAs(t, "PUT", entryPath, jwtToken, map[string]float64{"score": 50})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(80), graded["data"].(map[string]interface{})["percent"])
+	assert.Equal(t, "B", graded["data"].(map[string]interface{})["letter"])
+
+	// The university grades more generously
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/universities/%d/grade-scale", university.ID), jwtToken, map[string]interface{}{
+		"bands": []map[string]interface{}{{"letter": "A", "min": 80}, {"letter": "B", "min": 60}},
+	})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/universities/%d/grade-scale", university.ID), jwtToken, map[string]interface{}{
+		"bands": []map[string]interface{}{{"letter": "A", "min": 80}, {"letter": "B", "min": 60}, {"letter": "F", "min": 0}},
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// The student sees the grade on the course page...
+	status, details := sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d", course.ID), studentToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	grade := details["grade"].(map[string]interface{})
+	assert.Equal(t, float64(80), grade["percent"])
+	assert.Equal(t, "A", grade["letter"])
+	assert.Len(t, grade["components"], 3)
+
+	// ...and the author in the gradebook
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/courses/%d/gradebook", course.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, []string{"final_grade", "letter_grade"}, records[0][9:])
+	assert.Equal(t, []string{"80.00", "A"}, records[1][9:])
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStudyGroups(t *testing.T) {
+	var university models.University
+	db.FirstOrCreate(&university, models.University{Name: "Ural Federal University", Slug: "ural-federal-university"})
+
+	status, created := postJSON(t, "/api/admin/groups", map[string]interface{}{"name": "FIL-201", "university_id": university.ID})
+	assert.Equal(t, fiber.StatusCreated, status)
+	group := created["data"].(map[string]interface{})
+	groupID := uint(group["id"].(float64))
+	assert.Equal(t, "ural-federal-university-fil-201", group["slug"])
+	assert.Equal(t, "Ural Federal University", group["university"])
+
+	// Another spelling of the same name resolves to the directory entry
+	status, _ = postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Ethics", "recommended_for": "fil 201"})
+	assert.Equal(t, fiber.StatusOK, status)
+	var course models.Course
+	db.Where("title = ?", "Ethics").Last(&course)
+	assert.Equal(t, groupID, *course.RecommendedGroupID)
+	assert.Equal(t, "FIL-201", course.RecommendedFor)
+
+	status, _ = postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Ethics", "recommended_for": "No such group"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	student := models.User{Username: "group_student", Email: "group_student@example.com", PasswordHash: "hash"}
+	db.Create(&student)
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/groups/%d/members", groupID), map[string]interface{}{"user_id": student.ID, "primary": true})
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// Renaming the group renames it everywhere it is referenced
+	body, _ := json.Marshal(map[string]string{"name": "ФИЛ-201"})
+	req := httptest.NewRequest("PATCH", fmt.Sprintf("/api/admin/groups/%d", groupID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	db.First(&student, student.ID)
+	assert.Equal(t, "ФИЛ-201", student.Group)
+	db.First(&course, course.ID)
+	assert.Equal(t, "ФИЛ-201", course.RecommendedFor)
+
+	// Directory filters go through memberships
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/admin/users?group_id=%d", groupID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	var users struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&users)
+	assert.Len(t, users.Data, 1)
+
+	// A group recommended by content can't be deleted
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/admin/groups/%d", groupID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/admin/groups/%d/members/%d", groupID, student.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	db.First(&student, student.ID)
+	assert.Nil(t, student.StudyGroupID)
+	assert.Empty(t, student.Group)
+}
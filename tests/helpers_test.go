@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sendJSON sends payload as JSON on behalf of the test user and decodes the JSON response
+func sendJSON(t *testing.T, method, path string, payload interface{}) (int, map[string]interface{}) {
+	return sendJSONAs(t, method, path, jwtToken, payload)
+}
+
+// sendJSONAs sends payload as JSON with token as the Authorization header (none when empty)
+// and decodes the JSON response
+func sendJSONAs(t *testing.T, method, path, token string, payload interface{}) (int, map[string]interface{}) {
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func postJSON(t *testing.T, path string, payload interface{}) (int, map[string]interface{}) {
+	return sendJSONAs(t, "POST", path, jwtToken, payload)
+}
+
+func postJSONAs(t *testing.T, token, path string, payload interface{}) (int, map[string]interface{}) {
+	return sendJSONAs(t, "POST", path, token, payload)
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterWithInvitation(t *testing.T) {
+	course := models.Course{Title: "Invite-only seminar", AuthorID: testUser.ID}
+	db.Create(&course)
+	var university models.University
+	db.FirstOrCreate(&university, models.University{Name: "MSU", Slug: "msu"})
+	group := models.StudyGroup{Name: "PHIL-101", Slug: "msu-phil-101", UniversityID: &university.ID, University: "MSU"}
+	db.FirstOrCreate(&group, models.StudyGroup{Slug: group.Slug})
+
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"email":      "invited@example.com",
+		"group":      "phil 101",
+		"university": "MSU",
+		"course_id":  course.ID,
+	})
+
+	req := httptest.NewRequest("POST", "/api/admin/invitations", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	code := result["data"].(map[string]interface{})["Code"].(string)
+	assert.NotEmpty(t, code)
+
+	// Registering with the code applies the group and enrolls into the course
+	registerData, _ := json.Marshal(map[string]string{
+		"username":      "invited",
+		"email":         "invited@example.com",
+		"password_hash": "password123",
+		"invite_code":   code,
+	})
+	registerReq := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(registerData))
+	registerReq.Header.Set("Content-Type", "application/json")
+
+	registerResp, err := app.Test(registerReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, registerResp.StatusCode)
+
+	var user models.User
+	db.Where("username = ?", "invited").First(&user)
+	assert.Equal(t, "PHIL-101", user.Group)
+	assert.Equal(t, group.ID, *user.StudyGroupID)
+
+	var memberships int64
+	db.Model(&models.StudyGroupMember{}).Where("group_id = ? AND user_id = ?", group.ID, user.ID).Count(&memberships)
+	assert.Equal(t, int64(1), memberships)
+	assert.Equal(t, "MSU", user.University)
+
+	var enrollments int64
+	db.Model(&models.UserCourseProgress{}).Where("user_id = ? AND course_id = ?", user.ID, course.ID).Count(&enrollments)
+	assert.Equal(t, int64(1), enrollments)
+
+	// The code can't be reused
+	reuseData, _ := json.Marshal(map[string]string{
+		"username":      "invited2",
+		"email":         "invited@example.com",
+		"password_hash": "password123",
+		"invite_code":   code,
+	})
+	reuseReq := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(reuseData))
+	reuseReq.Header.Set("Content-Type", "application/json")
+
+	reuseResp, err := app.Test(reuseReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, reuseResp.StatusCode)
+}
+
+func TestInvitationToFullCourse(t *testing.T) {
+	course := models.Course{
+		Title:          "Invite-only seminar with one seat",
+		AuthorID:       testUser.ID,
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public", MaxEnrollments: 1},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+	taken := models.User{Username: "seat_holder", Email: "seat_holder@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&taken).Error)
+	assert.NoError(t, db.Create(&models.UserCourseProgress{UserID: taken.ID, CourseID: course.ID}).Error)
+
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"email":     "late_invitee@example.com",
+		"course_id": course.ID,
+	})
+	req := httptest.NewRequest("POST", "/api/admin/invitations", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	code := result["data"].(map[string]interface{})["Code"].(string)
+
+	// Registration still succeeds, but the invitee waits for a seat instead of overbooking the course
+	registerData, _ := json.Marshal(map[string]string{
+		"username":      "late_invitee",
+		"email":         "late_invitee@example.com",
+		"password_hash": "password123",
+		"invite_code":   code,
+	})
+	registerReq := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(registerData))
+	registerReq.Header.Set("Content-Type", "application/json")
+	registerResp, err := app.Test(registerReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, registerResp.StatusCode)
+
+	var user models.User
+	assert.NoError(t, db.Where("username = ?", "late_invitee").First(&user).Error)
+	var enrollments, queued int64
+	db.Model(&models.UserCourseProgress{}).Where("course_id = ?", course.ID).Count(&enrollments)
+	db.Model(&models.CourseWaitlist{}).Where("user_id = ? AND course_id = ?", user.ID, course.ID).Count(&queued)
+	assert.Equal(t, int64(1), enrollments)
+	assert.Equal(t, int64(1), queued)
+}
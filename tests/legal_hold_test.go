@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegalHold(t *testing.T) {
+	hash, _ := utils.Passwords.Hash("held12345")
+	witness := models.User{Username: "witness", Email: "witness@example.com", PasswordHash: hash}
+	db.Create(&witness)
+	token, _ := utils.GenerateJWTToken(&witness, cfg)
+
+	deleteAccount := func() (int, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]string{"password": "held12345"})
+		req := httptest.NewRequest("DELETE", "/api/user/account", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, _ := postJSON(t, "/api/admin/legal-holds", map[string]interface{}{"subject_type": "user", "subject_id": witness.ID})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = postJSON(t, "/api/admin/legal-holds", map[string]interface{}{"subject_type": "user", "subject_id": 999999, "reason": "Litigation"})
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, created := postJSON(t, "/api/admin/legal-holds", map[string]interface{}{"subject_type": "user", "subject_id": witness.ID, "reason": "Litigation #42"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	holdID := uint(created["data"].(map[string]interface{})["ID"].(float64))
+	status, _ = postJSON(t, "/api/admin/legal-holds", map[string]interface{}{"subject_type": "user", "subject_id": witness.ID, "reason": "Again"})
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	// The user can't delete the account while the hold is active
+	status, result := deleteAccount()
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, string(utils.CodeLegalHold), result["code"])
+
+	// An account deleted before the hold isn't purged either
+	purgeAfter := time.Now().Add(-time.Minute)
+	db.Model(&witness).Update("purge_after", purgeAfter)
+	db.Delete(&witness)
+	assert.NoError(t, jobs.PurgeDeletedAccounts(db)(context.Background()))
+	var remaining int64
+	db.Unscoped().Model(&models.User{}).Where("id = ?", witness.ID).Count(&remaining)
+	assert.Equal(t, int64(1), remaining)
+
+	// Releasing keeps the record with who released it and why
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/legal-holds/%d/release", holdID), map[string]interface{}{})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/legal-holds/%d/release", holdID), map[string]interface{}{"reason": "Case closed"})
+	assert.Equal(t, fiber.StatusOK, status)
+	var hold models.LegalHold
+	db.First(&hold, holdID)
+	assert.NotNil(t, hold.ReleasedAt)
+	assert.Equal(t, "Case closed", hold.ReleaseReason)
+	assert.Equal(t, testUser.ID, *hold.ReleasedBy)
+
+	assert.NoError(t, jobs.PurgeDeletedAccounts(db)(context.Background()))
+	db.Unscoped().Model(&models.User{}).Where("id = ?", witness.ID).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+
+	// Content under hold keeps its modules
+	course := models.Course{Title: "Held Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	module := models.CourseModule{CourseID: course.ID, Title: "Evidence"}
+	db.Create(&module)
+	postJSON(t, "/api/admin/legal-holds", map[string]interface{}{"subject_type": "course", "subject_id": course.ID, "reason": "Audit"})
+	req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/admin/courses/%d/modules/%d", course.ID, module.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"project/backend/config"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/storage"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func uploadAttachment(t *testing.T, path, fileName string, data []byte, title string) (int, map[string]interface{}) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if title != "" {
+		form.WriteField("title", title)
+	}
+	part, _ := form.CreateFormFile("file", fileName)
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result.Data
+}
+
+func TestLessonAttachments(t *testing.T) {
+	previous := storage.Default
+	storage.Default = storage.NewDisk(t.TempDir(), "/uploads")
+	defer func() { storage.Default = previous }()
+
+	course := models.Course{Title: "Formal Logic", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "public"})
+	lesson := models.Lesson{CourseID: course.ID, Title: "Truth tables", SequenceOrder: 1}
+	db.Create(&lesson)
+	path := fmt.Sprintf("/api/admin/courses/%d/lessons/%d/attachments", course.ID, lesson.ID)
+
+	pdf := []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\ntrailer << /Root 1 0 R >>\n%%EOF\n")
+	status, created := uploadAttachment(t, path, "truth-tables.pdf", pdf, "")
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, "truth-tables", created["title"])
+	assert.Equal(t, "application/pdf", created["content_type"])
+	assert.Equal(t, float64(len(pdf)), created["size"])
+
+	status, _ = uploadAttachment(t, path, "connectives.csv", []byte("p,q,p and q\n1,1,1\n1,0,0\n"), "Connectives dataset")
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// Unsupported types and files whose content doesn't match the extension are rejected
+	status, _ = uploadAttachment(t, path, "notes.html", []byte("<html><script>alert(1)</script></html>"), "")
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = uploadAttachment(t, path, "slides.pdf", []byte("MZ\x90\x00 not a pdf"), "")
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = uploadAttachment(t, path, "slides.pptx", pdf, "")
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// Students list the files and download them through the API
+	lessonPath := fmt.Sprintf("/api/courses/%d/lessons/%d/attachments", course.ID, lesson.ID)
+	req := httptest.NewRequest("GET", lessonPath, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	if assert.Len(t, list.Data, 2) {
+		assert.Equal(t, "Connectives dataset", list.Data[1]["title"])
+	}
+
+	downloadURL := created["download_url"].(string)
+	assert.Equal(t, fmt.Sprintf("%s/%d", lessonPath, int(created["id"].(float64))), downloadURL)
+	req = httptest.NewRequest("GET", downloadURL, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), `filename="truth-tables.pdf"`)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, pdf, body)
+
+	// Deleting removes the row and the stored file
+	var attachment models.LessonAttachment
+	db.First(&attachment, uint(created["id"].(float64)))
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("%s/%d", path, attachment.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	_, err = storage.Default.Get(context.Background(), attachment.Key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	req = httptest.NewRequest("GET", downloadURL, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, _ = app.Test(req)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestLessonAttachmentsAccessAndCopies(t *testing.T) {
+	previous := storage.Default
+	storage.Default = storage.NewDisk(t.TempDir(), "/uploads")
+	defer func() { storage.Default = previous }()
+
+	course := models.Course{Title: "Epistemology", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "public"})
+	lesson := models.Lesson{CourseID: course.ID, Title: "Gettier cases", SequenceOrder: 1}
+	db.Create(&lesson)
+	csv := []byte("case,justified,true\nsheep,1,1\n")
+	status, _ := uploadAttachment(t, fmt.Sprintf("/api/admin/courses/%d/lessons/%d/attachments", course.ID, lesson.ID), "cases.csv", csv, "")
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	outsider := models.User{Username: "attachment_outsider", Email: "attachment_outsider@example.com", PasswordHash: "hash"}
+	db.Create(&outsider)
+	token, err := utils.GenerateJWTToken(&outsider, cfg)
+	assert.NoError(t, err)
+	list := func() int {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/courses/%d/lessons/%d/attachments", course.ID, lesson.ID), nil)
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// A draft isn't visible outside the course, a private course only to its students
+	assert.Equal(t, fiber.StatusNotFound, list())
+	db.Model(&course).Update("status", models.CoursePublished)
+	assert.Equal(t, fiber.StatusOK, list())
+	db.Model(&models.CourseAccessSettings{}).Where("course_id = ?", course.ID).Update("access_level", "private")
+	assert.Equal(t, fiber.StatusForbidden, list())
+	db.Create(&models.UserCourseProgress{UserID: outsider.ID, CourseID: course.ID})
+	assert.Equal(t, fiber.StatusOK, list())
+
+	// Copies of the course get their own files
+	copiedFile := func(courseID uint) []byte {
+		var attachment models.LessonAttachment
+		assert.NoError(t, db.Where("lesson_id IN (?)", db.Model(&models.Lesson{}).Select("id").Where("course_id = ?", courseID)).
+			First(&attachment).Error)
+		data, err := storage.Default.Get(context.Background(), attachment.Key)
+		assert.NoError(t, err)
+		return data
+	}
+	status, result := postJSON(t, fmt.Sprintf("/api/admin/courses/%d/clone", course.ID), nil)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, csv, copiedFile(uint(result["data"].(map[string]interface{})["id"].(float64))))
+
+	for _, format := range []string{"json", "markdown"} {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/admin/courses/%d/bundle?format=%s", course.ID, format), nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		exported, _ := io.ReadAll(resp.Body)
+
+		req = httptest.NewRequest("POST", "/api/admin/courses/import", bytes.NewReader(exported))
+		req.Header.Set("Authorization", jwtToken)
+		resp, err = app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode, format)
+		var created struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&created)
+		assert.Equal(t, csv, copiedFile(uint(created.Data["id"].(float64))), format)
+	}
+
+	// Past the trash retention the lesson is purged together with its files
+	var attachment models.LessonAttachment
+	db.Where("lesson_id = ?", lesson.ID).First(&attachment)
+	db.Model(&lesson).Update("deleted_at", time.Now().AddDate(0, 0, -40))
+	assert.NoError(t, jobs.PurgeTrashedLessons(db, &config.Config{TrashRetentionDays: 30})(context.Background()))
+	var count int64
+	db.Unscoped().Model(&models.Lesson{}).Where("id = ?", lesson.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	db.Unscoped().Model(&models.LessonAttachment{}).Where("id = ?", attachment.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	_, err = storage.Default.Get(context.Background(), attachment.Key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
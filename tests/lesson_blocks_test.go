@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLessonBlocks(t *testing.T) {
+	course := models.Course{Title: "Philosophy of Mind", AuthorID: testUser.ID}
+	db.Create(&course)
+	lesson := models.Lesson{CourseID: course.ID, Title: "Qualia", Content: "<p>What is it like to be a bat?</p>", SequenceOrder: 1}
+	db.Create(&lesson)
+	path := fmt.Sprintf("/api/admin/courses/%d/lessons/%d/blocks", course.ID, lesson.ID)
+
+	blockIDs := func(result map[string]interface{}) []uint {
+		var ids []uint
+		for _, block := range result["data"].([]interface{}) {
+			ids = append(ids, uint(block.(map[string]interface{})["id"].(float64)))
+		}
+		return ids
+	}
+
+	// A lesson written as one string reads as a single text block
+	status, result := sendJSON(t, "GET", path, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []uint{0}, blockIDs(result))
+
+	status, created := sendJSON(t, "POST", path, map[string]interface{}{
+		"type":     "image",
+		"data":     map[string]string{"url": "/uploads/bat.png", "alt": "A bat", "caption": "Nagel's bat"},
+		"position": 1,
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	imageID := uint(created["data"].(map[string]interface{})["id"].(float64))
+
+	status, _ = sendJSON(t, "POST", path, map[string]interface{}{
+		"type": "code",
+		"data": map[string]string{"language": "Python", "code": "print('<red>')"},
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	status, _ = sendJSON(t, "POST", path, map[string]interface{}{
+		"type": "quiz",
+		"data": map[string]interface{}{"question": "Who wrote the bat paper?", "options": []string{"Nagel"}, "correct": 0},
+	})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSON(t, "POST", path, map[string]interface{}{"type": "embed", "data": map[string]string{"url": "http://example.com"}})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// The old content was kept as a text block behind the inserted image
+	status, result = sendJSON(t, "GET", path, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	ids := blockIDs(result)
+	if assert.Len(t, ids, 3) {
+		assert.Equal(t, imageID, ids[0])
+		assert.Equal(t, "text", result["data"].([]interface{})[1].(map[string]interface{})["type"])
+	}
+
+	db.First(&lesson, lesson.ID)
+	assert.Equal(t, `<figure><img src="/uploads/bat.png" alt="A bat"><figcaption>Nagel&#39;s bat</figcaption></figure>
+<p>What is it like to be a bat?</p>
+<pre><code class="language-python">print(&#39;&lt;red&gt;&#39;)</code></pre>`, lesson.Content)
+
+	// Reordering has to list every block once
+	status, _ = sendJSON(t, "PUT", path+"/order", map[string]interface{}{"block_ids": ids[:2]})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, result = sendJSON(t, "PUT", path+"/order", map[string]interface{}{"block_ids": []uint{ids[2], ids[1], ids[0]}})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []uint{ids[2], ids[1], ids[0]}, blockIDs(result))
+
+	status, updated := sendJSON(t, "PATCH", fmt.Sprintf("%s/%d", path, ids[1]), map[string]interface{}{
+		"data": map[string]string{"html": "<p>Mary's room</p>"},
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(2), updated["data"].(map[string]interface{})["sequence_order"])
+
+	status, _ = sendJSON(t, "DELETE", fmt.Sprintf("%s/%d", path, ids[2]), nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+
+	// Students read the same blocks
+	status, result = sendJSON(t, "GET", fmt.Sprintf("/api/courses/%d/lessons/%d/blocks", course.ID, lesson.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []uint{ids[1], ids[0]}, blockIDs(result))
+	db.First(&lesson, lesson.ID)
+	assert.Equal(t, "<p>Mary's room</p>\n<figure><img src=\"/uploads/bat.png\" alt=\"A bat\"><figcaption>Nagel&#39;s bat</figcaption></figure>", lesson.Content)
+
+	// Older clients that still send content replace the blocks with it
+	status, _ = sendJSON(t, "PATCH", fmt.Sprintf("/api/admin/courses/%d/lessons/%d", course.ID, lesson.ID), map[string]interface{}{
+		"content": "<p>Zombies</p>",
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	var remaining int64
+	db.Model(&models.LessonBlock{}).Where("lesson_id = ?", lesson.ID).Count(&remaining)
+	assert.Zero(t, remaining)
+	status, result = sendJSON(t, "GET", path, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, blockIDs(result), 1)
+}
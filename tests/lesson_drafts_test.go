@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLessonDrafts(t *testing.T) {
+	course := models.Course{Title: "Phenomenology", AuthorID: testUser.ID}
+	assert.NoError(t, db.Create(&course).Error)
+	lesson := models.Lesson{CourseID: course.ID, Title: "Epoché", Content: "<p>Bracketing</p>", SequenceOrder: 1}
+	assert.NoError(t, db.Create(&lesson).Error)
+	lessonPath := fmt.Sprintf("/api/admin/courses/%d/lessons/%d", course.ID, lesson.ID)
+	draftPath := lessonPath + "/draft"
+
+	status, _ := sendJSON(t, "GET", draftPath, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	// Autosaves go to the draft, the live lesson stays as it is
+	status, result := sendJSON(t, "PATCH", draftPath, map[string]interface{}{"markdown": "Suspend the **natural attitude**"})
+	assert.Equal(t, fiber.StatusOK, status)
+	draft := result["data"].(map[string]interface{})
+	assert.Equal(t, "Epoché", draft["title"])
+	assert.Equal(t, "<p>Suspend the <strong>natural attitude</strong></p>", draft["content"])
+	assert.Equal(t, float64(1), draft["revision"])
+	status, result = sendJSON(t, "PATCH", draftPath, map[string]interface{}{"title": "The Epoché", "revision": 1})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(2), result["data"].(map[string]interface{})["revision"])
+	var stored models.Lesson
+	db.First(&stored, lesson.ID)
+	assert.Equal(t, "Epoché", stored.Title)
+	assert.Equal(t, "<p>Bracketing</p>", stored.Content)
+
+	// A save based on an older revision means another editor saved in between
+	status, _ = sendJSON(t, "PATCH", draftPath, map[string]interface{}{"title": "Stale", "revision": 1})
+	assert.Equal(t, fiber.StatusConflict, status)
+	// Once the draft exists, a save has to name the revision it builds on
+	status, _ = sendJSON(t, "PATCH", draftPath, map[string]interface{}{"title": "Blind"})
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	// Publishing doesn't overwrite a lesson edited directly after the draft was started
+	status, _ = sendJSON(t, "PATCH", lessonPath, map[string]interface{}{"description": "Husserl"})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, result = sendJSON(t, "GET", draftPath, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, true, result["data"].(map[string]interface{})["out_of_date"])
+	status, _ = sendJSON(t, "POST", draftPath+"/publish", nil)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	status, result = sendJSON(t, "POST", draftPath+"/publish", map[string]interface{}{"force": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	published := result["data"].(map[string]interface{})
+	assert.Equal(t, "The Epoché", published["Title"])
+	assert.Equal(t, "Suspend the **natural attitude**", published["Markdown"])
+	db.First(&stored, lesson.ID)
+	assert.Equal(t, "<p>Suspend the <strong>natural attitude</strong></p>", stored.Content)
+
+	// The draft is gone once published, and a new one can be started and discarded
+	status, _ = sendJSON(t, "GET", draftPath, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	// Two editors starting the draft at once: one creates it, the other is told to reload
+	statuses := make(chan int, 2)
+	var wg sync.WaitGroup
+	for _, content := range []string{"<p>Noema</p>", "<p>Noesis</p>"} {
+		wg.Add(1)
+		go func(content string) {
+			defer wg.Done()
+			status, _ := sendJSON(t, "PATCH", draftPath, map[string]interface{}{"content": content})
+			statuses <- status
+		}(content)
+	}
+	wg.Wait()
+	close(statuses)
+	var saved, conflicts int
+	for status := range statuses {
+		switch status {
+		case fiber.StatusOK:
+			saved++
+		case fiber.StatusConflict:
+			conflicts++
+		}
+	}
+	assert.Equal(t, 1, saved)
+	assert.Equal(t, 1, conflicts)
+	status, _ = sendJSON(t, "DELETE", draftPath, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+
+	status, _ = sendJSON(t, "PATCH", draftPath, map[string]interface{}{"content": "<p>Noema</p>"})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSON(t, "DELETE", draftPath, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	db.First(&stored, lesson.ID)
+	assert.Equal(t, "The Epoché", stored.Title)
+
+	// A lesson that moved to blocks keeps them: its draft can't be published, even with force, nor saved again
+	status, _ = sendJSON(t, "PATCH", draftPath, map[string]interface{}{"content": "<p>Hyle</p>"})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSON(t, "POST", lessonPath+"/blocks", map[string]interface{}{
+		"type": "code",
+		"data": map[string]string{"language": "Python", "code": "print('noesis')"},
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = sendJSON(t, "POST", draftPath+"/publish", map[string]interface{}{"force": true})
+	assert.Equal(t, fiber.StatusConflict, status)
+	status, _ = sendJSON(t, "PATCH", draftPath, map[string]interface{}{"content": "<p>Noesis</p>", "revision": 1})
+	assert.Equal(t, fiber.StatusConflict, status)
+	var blocks int64
+	db.Model(&models.LessonBlock{}).Where("lesson_id = ?", lesson.ID).Count(&blocks)
+	assert.Equal(t, int64(2), blocks)
+	status, _ = sendJSON(t, "DELETE", draftPath, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/events"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveTestActivity(t *testing.T) {
+	test := models.Test{
+		Title:          "Live Exam",
+		AuthorID:       testUser.ID,
+		Questions:      []models.TestQuestion{{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 1}},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 1},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+
+	student := models.User{Username: "live_student", Email: "live_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	stream, unsubscribe := events.Default.Subscribe(events.Topic("test", test.ID))
+	defer unsubscribe()
+	next := func() events.Event {
+		select {
+		case event := <-stream:
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return events.Event{}
+		}
+	}
+	// Wait until the listener is connected, events published before that are lost
+	for ready := false; !ready; {
+		assert.NoError(t, events.Publish(db, events.Topic("test", test.ID), "ping", nil))
+		select {
+		case <-stream:
+			ready = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Opening the test marks the student as taking it
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/tests/%d", test.ID), nil)
+	req.Header.Set("Authorization", token)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	// Events arrive as JSON from whichever instance published them
+	event := next()
+	assert.Equal(t, "presence", event.Type)
+	assert.Equal(t, float64(1), event.Data.(map[string]interface{})["taking_now"])
+	var marks int64
+	db.Model(&models.PresenceMark{}).Where("topic = ?", events.Topic("test", test.ID)).Count(&marks)
+	assert.Equal(t, int64(1), marks)
+
+	body, _ := json.Marshal(map[string]interface{}{"answers": []map[string]interface{}{
+		{"question_id": test.Questions[0].ID, "answer": 1},
+	}})
+	req = httptest.NewRequest("POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	event = next()
+	assert.Equal(t, "attempt", event.Type)
+	assert.Equal(t, "live_student", event.Data.(map[string]interface{})["username"])
+	assert.Equal(t, float64(100), event.Data.(map[string]interface{})["score"])
+	event = next()
+	assert.Equal(t, "presence", event.Type)
+	assert.Equal(t, float64(0), event.Data.(map[string]interface{})["taking_now"])
+
+	// Expired marks are purged, fresh ones stay
+	assert.NoError(t, db.Create(&models.PresenceMark{Topic: "test:live-expired", UserID: student.ID, ExpiresAt: time.Now().Add(-time.Minute)}).Error)
+	assert.NoError(t, events.PurgePresence(db, time.Now()))
+	db.Model(&models.PresenceMark{}).Where("topic = ?", "test:live-expired").Count(&marks)
+	assert.Zero(t, marks)
+
+	// Only the author (or analytics viewers) may watch the stream
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/tests/%d/live", test.ID), nil)
+	req.Header.Set("Authorization", token)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	chromeOnWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	safariOnIPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+)
+
+func TestLoginSecurityLog(t *testing.T) {
+	assert.Equal(t, "Chrome on Windows", utils.DeviceName(chromeOnWindows))
+	assert.Equal(t, "Safari on iOS", utils.DeviceName(safariOnIPhone))
+
+	hash, err := utils.Passwords.Hash("correct horse")
+	assert.NoError(t, err)
+	user := models.User{Username: "security_student", Email: "security@example.com", PasswordHash: hash, Active: true}
+	db.Create(&user)
+
+	login := func(password, userAgent, country string) (int, string) {
+		body, _ := json.Marshal(map[string]string{"username": user.Username, "password": password})
+		req := httptest.NewRequest("POST", "/api/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("CF-IPCountry", country)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		token, _ := result["token"].(string)
+		return resp.StatusCode, token
+	}
+
+	status, token := login("correct horse", chromeOnWindows, "DE")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = login("wrong", chromeOnWindows, "DE")
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+	status, _ = login("correct horse", safariOnIPhone, "FR")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	req := httptest.NewRequest("GET", "/api/user/security/logins", nil)
+	req.Header.Set("Authorization", token)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var result struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	// Newest first: the flagged login, the failed attempt, the very first login (never flagged)
+	if assert.Len(t, result.Data, 3) {
+		assert.Equal(t, "Safari on iOS", result.Data[0]["device"])
+		assert.Equal(t, "FR", result.Data[0]["country"])
+		assert.Equal(t, true, result.Data[0]["new_device"])
+		assert.Equal(t, true, result.Data[0]["new_country"])
+		assert.Equal(t, false, result.Data[1]["success"])
+		assert.Equal(t, false, result.Data[2]["new_device"])
+	}
+
+	var alerts int64
+	db.Model(&models.OutboxMessage{}).
+		Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%security@example.com%", "%New sign-in%").
+		Count(&alerts)
+	assert.Equal(t, int64(1), alerts)
+}
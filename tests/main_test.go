@@ -7,17 +7,127 @@ import (
 func TestAll(t *testing.T) {
 	t.Run("Auth", TestAuth)
 	t.Run("Courses", TestCourses)
+	t.Run("RBAC", TestRBAC)
 }
 
 func TestCourses(t *testing.T) {
 	t.Run("CreateCourse", TestCreateCourse)
 	t.Run("GetCourseDetails", TestGetCourseDetails)
 	t.Run("UpdateCourseProgress", TestUpdateCourseProgress)
+	t.Run("OptionalMergePatch", TestOptionalMergePatch)
+	t.Run("UpdateQuestionZeroAndNull", TestUpdateQuestionZeroAndNull)
+	t.Run("ExportCourseGradebook", TestExportCourseGradebook)
+	t.Run("SearchEnrolledContent", TestSearchEnrolledContent)
+	t.Run("AuthorProfile", TestAuthorProfile)
+	t.Run("TopicTaxonomy", TestTopicTaxonomy)
+	t.Run("UniversityAffiliation", TestUniversityAffiliation)
+	t.Run("StudyGroups", TestStudyGroups)
+	t.Run("CourseCover", TestCourseCover)
+	t.Run("TestLeaderboard", TestTestLeaderboard)
+	t.Run("LiveTestActivity", TestLiveTestActivity)
+	t.Run("ProctoredExamSession", TestProctoredExamSession)
+	t.Run("CourseCertificateSurvey", TestCourseCertificateSurvey)
+	t.Run("UserActivityFeed", TestUserActivityFeed)
+	t.Run("WeightedCourseGrade", TestWeightedCourseGrade)
+	t.Run("SISGradeExport", TestSISGradeExport)
+	t.Run("CourseModules", TestCourseModules)
+	t.Run("CourseLifecycle", TestCourseLifecycle)
+	t.Run("CourseVisibilityByStatus", TestCourseVisibilityByStatus)
+	t.Run("CloneCourse", TestCloneCourse)
+	t.Run("CourseBundleExportImport", TestCourseBundleExportImport)
+	t.Run("ELearningPackageImport", TestELearningPackageImport)
+	t.Run("LessonBlocks", TestLessonBlocks)
+	t.Run("LessonAttachments", TestLessonAttachments)
+	t.Run("LessonAttachmentsAccessAndCopies", TestLessonAttachmentsAccessAndCopies)
+	t.Run("MarkdownContent", TestMarkdownContent)
+	t.Run("LessonDrafts", TestLessonDrafts)
+	t.Run("CourseVersions", TestCourseVersions)
+	t.Run("CourseMarketplace", TestCourseMarketplace)
+	t.Run("ContentDeletion", TestContentDeletion)
+	t.Run("ContentLicense", TestContentLicense)
+	t.Run("CourseAccessList", TestCourseAccessList)
+	t.Run("CourseConduct", TestCourseConduct)
+	t.Run("DistractorSuggestions", TestDistractorSuggestions)
+	t.Run("CourseSchedule", TestCourseSchedule)
+	t.Run("CatalogTags", TestCatalogTags)
+	t.Run("DuplicateContentReport", TestDuplicateContentReport)
+	t.Run("QuestionTranslations", TestQuestionTranslations)
+	t.Run("PaidCourseEnrollment", TestPaidCourseEnrollment)
+	t.Run("LessonAccessibility", TestLessonAccessibility)
+	t.Run("CourseRuns", TestCourseRuns)
+	t.Run("CourseAnnouncements", TestCourseAnnouncements)
+	t.Run("QuotaWarnings", TestQuotaWarnings)
+	t.Run("Campaigns", TestCampaigns)
+	t.Run("StudyCircles", TestStudyCircles)
+	t.Run("CircleChallenges", TestCircleChallenges)
+	t.Run("QuestionTimeLimits", TestQuestionTimeLimits)
+	t.Run("Bookmarks", TestBookmarks)
+	t.Run("ExamPauses", TestExamPauses)
+	t.Run("AttemptAnswerAutosave", TestAttemptAnswerAutosave)
+	t.Run("CourseCompletionPolicy", TestCourseCompletionPolicy)
+	t.Run("ActiveAttemptRecovery", TestActiveAttemptRecovery)
+	t.Run("CourseWaitlist", TestCourseWaitlist)
+	t.Run("CourseReviews", TestCourseReviews)
+	t.Run("ExamIntegrityReport", TestExamIntegrityReport)
+	t.Run("LessonNotificationsFanOut", TestLessonNotificationsFanOut)
+	t.Run("OutboxRelayClaimsBeforeDelivery", TestOutboxRelayClaimsBeforeDelivery)
+}
+
+func TestRBAC(t *testing.T) {
+	t.Run("CreateCourseWithoutPermission", TestCreateCourseWithoutPermission)
+	t.Run("AssignRoleGrantsPermission", TestAssignRoleGrantsPermission)
+	t.Run("RevokeRoleKeepsOtherScopes", TestRevokeRoleKeepsOtherScopes)
+	t.Run("AdminMiddlewareChecksCurrentRole", TestAdminMiddlewareChecksCurrentRole)
+	t.Run("RegisterWithInvitation", TestRegisterWithInvitation)
+	t.Run("InvitationToFullCourse", TestInvitationToFullCourse)
+	t.Run("BannedUserGetsForbidden", TestBannedUserGetsForbidden)
+	t.Run("StatusChangeRespectsRank", TestStatusChangeRespectsRank)
+	t.Run("ArchiveInactiveUser", TestArchiveInactiveUser)
+	t.Run("MaintainPartitions", TestMaintainPartitions)
+	t.Run("SchedulerRunsJobOnce", TestSchedulerRunsJobOnce)
+	t.Run("QueryPlanGuard", TestQueryPlanGuard)
+	t.Run("QueryTimeout", TestQueryTimeout)
+	t.Run("AdminUserDirectory", TestAdminUserDirectory)
+	t.Run("MergeDuplicateAccounts", TestMergeDuplicateAccounts)
+	t.Run("CourseTeachingAssistant", TestCourseTeachingAssistant)
+	t.Run("CourseCollaborators", TestCourseCollaborators)
+	t.Run("MentorLinks", TestMentorLinks)
+	t.Run("AnomalyAlerts", TestAnomalyAlerts)
+	t.Run("PublicStatus", TestPublicStatus)
+	t.Run("SchemaCompatibility", TestSchemaCompatibility)
+	t.Run("ReadOnlyMode", TestReadOnlyMode)
+	t.Run("AnonymizeStaging", TestAnonymizeStaging)
+	t.Run("ExportUniversity", TestExportUniversity)
+	t.Run("LegalHold", TestLegalHold)
+	t.Run("DeveloperSandbox", TestDeveloperSandbox)
+	t.Run("DeprecatedRoutes", TestDeprecatedRoutes)
+	t.Run("RateLimits", TestRateLimits)
+	t.Run("BodyLimit", TestBodyLimit)
+	t.Run("ApiUsage", TestApiUsage)
+	t.Run("ApiUsagePeakAndRetention", TestApiUsagePeakAndRetention)
 }
 
 func TestAuth(t *testing.T) {
 	// Здесь ты можешь вызвать нужные тесты для авторизации
 	t.Run("Register", TestRegister)
+	t.Run("RegisterEnqueuesWelcomeEmail", TestRegisterEnqueuesWelcomeEmail)
 	t.Run("Login", TestLogin)
 	t.Run("GetProfile", TestGetProfile)
+	t.Run("AuthErrorCodes", TestAuthErrorCodes)
+	t.Run("UploadAvatar", TestUploadAvatar)
+	t.Run("OAuthClients", TestOAuthClients)
+	t.Run("ClientTokenCredentials", TestClientTokenCredentials)
+	t.Run("ActivityHistoryCursorPagination", TestActivityHistoryCursorPagination)
+	t.Run("CourseCommentsCursorPagination", TestCourseCommentsCursorPagination)
+	t.Run("UserBootstrap", TestUserBootstrap)
+	t.Run("ApiKeyAuthentication", TestApiKeyAuthentication)
+	t.Run("UserSettings", TestUserSettings)
+	t.Run("ExportUserData", TestExportUserData)
+	t.Run("ExportJobReaper", TestExportJobReaper)
+	t.Run("DeleteAccount", TestDeleteAccount)
+	t.Run("TimezoneStreaks", TestTimezoneStreaks)
+	t.Run("ProfilePrivacy", TestProfilePrivacy)
+	t.Run("ProgressTrends", TestProgressTrends)
+	t.Run("ChangeEmail", TestChangeEmail)
+	t.Run("LoginSecurityLog", TestLoginSecurityLog)
 }
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseMarketplace(t *testing.T) {
+	course := models.Course{Title: "Shared Logic", AuthorID: testUser.ID, Status: models.CourseDraft}
+	db.Create(&course)
+	module := models.CourseModule{CourseID: course.ID, Title: "Syllogisms", SequenceOrder: 1}
+	db.Create(&module)
+	db.Create(&models.Lesson{CourseID: course.ID, ModuleID: &module.ID, Title: "Barbara", Content: "All A are B", SequenceOrder: 1})
+	submitPath := fmt.Sprintf("/api/admin/courses/%d/marketplace", course.ID)
+	submission := map[string]interface{}{"summary": "Intro to logic", "license": "cc-by", "attribution": "Logic Dept."}
+
+	status, _ := postJSON(t, submitPath, map[string]interface{}{"license": "proprietary"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	// Drafts can't be shared
+	status, _ = postJSON(t, submitPath, submission)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	db.Model(&course).Update("status", models.CoursePublished)
+	status, created := postJSON(t, submitPath, submission)
+	assert.Equal(t, fiber.StatusCreated, status)
+	listingID := uint(created["data"].(map[string]interface{})["id"].(float64))
+	status, _ = postJSON(t, submitPath, submission)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	listed := func() int {
+		req := httptest.NewRequest("GET", "/api/marketplace?search=logic", nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result struct {
+			Total int `json:"total"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result.Total
+	}
+	importPath := fmt.Sprintf("/api/marketplace/%d/import", listingID)
+
+	// Pending listings are neither listed nor importable
+	assert.Equal(t, 0, listed())
+	status, _ = postJSON(t, importPath, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/marketplace/%d/reject", listingID), nil)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/marketplace/%d/approve", listingID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/marketplace/%d/reject", listingID), map[string]interface{}{"note": "Too late"})
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, 1, listed())
+
+	// Later edits of the source don't leak into the frozen bundle
+	db.Model(&models.Lesson{}).Where("course_id = ?", course.ID).Update("content", "Changed after submission")
+
+	status, imported := postJSON(t, importPath, nil)
+	assert.Equal(t, fiber.StatusCreated, status)
+	copyID := uint(imported["data"].(map[string]interface{})["id"].(float64))
+
+	var copied models.Course
+	db.Preload("Modules").Preload("Lessons").First(&copied, copyID)
+	assert.Equal(t, "Shared Logic", copied.Title)
+	assert.Equal(t, models.CourseDraft, copied.Status)
+	assert.Equal(t, models.LicenseCCBY, copied.License)
+	assert.Equal(t, "Logic Dept.", copied.SourceAttribution)
+	if assert.Len(t, copied.Lessons, 1) && assert.Len(t, copied.Modules, 1) {
+		assert.Equal(t, "All A are B", copied.Lessons[0].Content)
+		assert.Equal(t, copied.Modules[0].ID, *copied.Lessons[0].ModuleID)
+	}
+
+	var listing models.MarketplaceListing
+	db.First(&listing, listingID)
+	assert.Equal(t, 1, listing.Imports)
+
+	req := httptest.NewRequest("DELETE", submitPath, nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, 0, listed())
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownContent(t *testing.T) {
+	course := models.Course{Title: "Philosophy of Language", AuthorID: testUser.ID}
+	db.Create(&course)
+	lessonsPath := fmt.Sprintf("/api/admin/courses/%d/lessons", course.ID)
+
+	// Markdown is kept as written and rendered into the lesson HTML
+	status, result := sendJSON(t, "POST", lessonsPath, map[string]interface{}{
+		"title":    "Sense and Reference",
+		"markdown": "## Frege\n\nThe *morning star* is the **evening star**.\n\n- sense\n- reference\n\n[Über Sinn](javascript:alert(1))",
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	lesson := result["lesson"].(map[string]interface{})
+	lessonID := int(lesson["ID"].(float64))
+	assert.Contains(t, lesson["Markdown"], "## Frege")
+	assert.Contains(t, lesson["Content"], "<h2>Frege</h2>")
+	assert.Contains(t, lesson["Content"], "<em>morning star</em> is the <strong>evening star</strong>")
+	assert.Contains(t, lesson["Content"], "<li>sense</li>")
+	assert.NotContains(t, lesson["Content"], "javascript:")
+
+	// HTML is cleaned of scripts and event handlers and replaces the Markdown source
+	status, result = sendJSON(t, "PATCH", fmt.Sprintf("%s/%d", lessonsPath, lessonID), map[string]interface{}{
+		"content": `<p onclick="steal()">Names <script>alert(1)</script><img src="/uploads/frege.png" onerror="steal()" alt="Frege"></p>`,
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	lesson = result["lesson"].(map[string]interface{})
+	assert.Equal(t, `<p>Names <img src="/uploads/frege.png" alt="Frege"></p>`, lesson["Content"])
+	assert.Equal(t, "", lesson["Markdown"])
+
+	// Text blocks accept Markdown as well
+	status, result = sendJSON(t, "POST", fmt.Sprintf("%s/%d/blocks", lessonsPath, lessonID), map[string]interface{}{
+		"type": "text",
+		"data": map[string]interface{}{"markdown": "Definite descriptions: `the present King of France`"},
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	data := result["data"].(map[string]interface{})["data"].(map[string]interface{})
+	assert.Equal(t, "<p>Definite descriptions: <code>the present King of France</code></p>", data["html"])
+	var stored models.Lesson
+	db.First(&stored, lessonID)
+	assert.Contains(t, stored.Content, "<code>the present King of France</code>")
+
+	// Comments are Markdown too and come back rendered next to the raw text
+	status, result = sendJSON(t, "POST", fmt.Sprintf("/api/comments/course/%d", course.ID), map[string]interface{}{
+		"text": "Is **Pegasus** a name? <script>alert(1)</script>",
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "Is **Pegasus** a name? <script>alert(1)</script>", result["Text"])
+	assert.Equal(t, "<p>Is <strong>Pegasus</strong> a name? &lt;script&gt;alert(1)&lt;/script&gt;</p>", result["TextHTML"])
+	commentID := int(result["ID"].(float64))
+
+	status, result = sendJSON(t, "POST", fmt.Sprintf("/api/comments/course/%d/%d/replies", course.ID, commentID), map[string]interface{}{
+		"text": "See [Kripke](https://example.com/naming)",
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, `<p>See <a href="https://example.com/naming">Kripke</a></p>`, result["data"].(map[string]interface{})["TextHTML"])
+}
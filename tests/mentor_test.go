@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentorLinks(t *testing.T) {
+	mentor := models.User{Username: "mentor_socrates", Email: "socrates@example.com", PasswordHash: "x", Active: true}
+	student := models.User{Username: "mentee_plato", Email: "plato@example.com", PasswordHash: "x", Active: true}
+	db.Create(&mentor)
+	db.Create(&student)
+
+	var professor models.Role
+	db.Where("name = ?", "professor").First(&professor)
+	db.Create(&models.UserRole{UserID: mentor.ID, RoleID: professor.ID})
+
+	course := models.Course{Title: "Dialogues"}
+	db.Create(&course)
+	db.Create(&models.UserCourseProgress{UserID: student.ID, CourseID: course.ID, CompletionRate: 50})
+
+	mentorToken, err := utils.GenerateJWTToken(&mentor, cfg)
+	assert.NoError(t, err)
+	studentToken, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	progressURL := "/api/mentor/students/" + strconv.Itoa(int(student.ID)) + "/progress"
+	mentorsURL := "/api/user/mentors/" + strconv.Itoa(int(mentor.ID))
+
+	// Students can't act as mentors
+	status, _ := sendJSONAs(t, "POST", "/api/mentor/students", studentToken, fiber.Map{"student_id": mentor.ID})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, _ = sendJSONAs(t, "POST", "/api/mentor/students", mentorToken, fiber.Map{"student_id": student.ID, "note": "Your advisor"})
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// Nothing is visible until the student consents
+	status, _ = sendJSONAs(t, "GET", progressURL, mentorToken, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, result := sendJSONAs(t, "GET", "/api/user/mentors", studentToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	mentors := result["data"].([]interface{})
+	assert.Len(t, mentors, 1)
+	assert.Equal(t, models.MentorLinkPending, mentors[0].(map[string]interface{})["status"])
+
+	status, _ = sendJSONAs(t, "POST", mentorsURL+"/accept", studentToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, result = sendJSONAs(t, "GET", progressURL, mentorToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, "mentee_plato", data["student"].(map[string]interface{})["username"])
+	assert.Len(t, data["courses"], 1)
+
+	// Revoking the consent closes the access again
+	status, _ = sendJSONAs(t, "DELETE", mentorsURL, studentToken, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	status, _ = sendJSONAs(t, "GET", progressURL, mentorToken, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"project/backend/models"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeDuplicateAccounts(t *testing.T) {
+	keep := models.User{Username: "merge_keep", Email: "merge_keep@example.com", PasswordHash: "hash"}
+	dup := models.User{Username: "merge_dup", Email: "merge_dup@uni.example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&keep).Error)
+	assert.NoError(t, db.Create(&dup).Error)
+
+	course := models.Course{Title: "Merge Course", AuthorID: dup.ID}
+	assert.NoError(t, db.Create(&course).Error)
+	test := models.Test{Title: "Merge Test", AuthorID: testUser.ID}
+	assert.NoError(t, db.Create(&test).Error)
+
+	db.Create(&models.UserCourseProgress{UserID: keep.ID, CourseID: course.ID, LessonsCompleted: 1, CompletionRate: 20, HoursSpent: 1})
+	db.Create(&models.UserCourseProgress{UserID: dup.ID, CourseID: course.ID, LessonsCompleted: 3, CompletionRate: 60, HoursSpent: 2})
+	db.Create(&models.UserTestProgress{UserID: dup.ID, TestID: test.ID, Score: 80, AttemptsUsed: 1})
+	db.Create(&models.CourseComment{CourseID: course.ID, UserID: dup.ID, UserName: dup.Username, Text: "Great"})
+
+	merge := func(sourceID uint, payload map[string]interface{}) int {
+		status, _ := sendJSON(t, "POST", "/api/admin/users/"+strconv.Itoa(int(sourceID))+"/merge", payload)
+		return status
+	}
+
+	assert.Equal(t, fiber.StatusUnprocessableEntity, merge(dup.ID, map[string]interface{}{"into_user_id": dup.ID}))
+	assert.Equal(t, fiber.StatusOK, merge(dup.ID, map[string]interface{}{"into_user_id": keep.ID}))
+
+	// The same course keeps the furthest progress and the total time
+	var courseProgress []models.UserCourseProgress
+	db.Where("course_id = ?", course.ID).Find(&courseProgress)
+	if assert.Len(t, courseProgress, 1) {
+		assert.Equal(t, keep.ID, courseProgress[0].UserID)
+		assert.Equal(t, 3, courseProgress[0].LessonsCompleted)
+		assert.Equal(t, float64(60), courseProgress[0].CompletionRate)
+		assert.Equal(t, float64(3), courseProgress[0].HoursSpent)
+	}
+
+	var testProgress models.UserTestProgress
+	assert.NoError(t, db.Where("test_id = ?", test.ID).First(&testProgress).Error)
+	assert.Equal(t, keep.ID, testProgress.UserID)
+
+	var ranking models.TestRanking
+	assert.NoError(t, db.Where("test_id = ?", test.ID).First(&ranking).Error)
+	assert.Equal(t, keep.ID, ranking.UserID)
+
+	var comment models.CourseComment
+	assert.NoError(t, db.Where("course_id = ?", course.ID).First(&comment).Error)
+	assert.Equal(t, keep.ID, comment.UserID)
+	assert.Equal(t, "merge_keep", comment.UserName)
+
+	var movedCourse models.Course
+	db.First(&movedCourse, course.ID)
+	assert.Equal(t, keep.ID, movedCourse.AuthorID)
+
+	// The duplicate is gone
+	var remaining int64
+	db.Model(&models.User{}).Where("id = ?", dup.ID).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+	assert.Equal(t, fiber.StatusNotFound, merge(dup.ID, map[string]interface{}{"into_user_id": keep.ID}))
+}
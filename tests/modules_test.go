@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseModules(t *testing.T) {
+	course := models.Course{Title: "Modular Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	base := fmt.Sprintf("/api/admin/courses/%d", course.ID)
+
+	status, created := postJSON(t, base+"/modules", map[string]interface{}{"title": "Ancient philosophy"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	ancientID := uint(created["data"].(map[string]interface{})["id"].(float64))
+	_, created = postJSON(t, base+"/modules", map[string]interface{}{"title": "Modern philosophy"})
+	modernID := uint(created["data"].(map[string]interface{})["id"].(float64))
+
+	status, _ = postJSON(t, base+"/modules", map[string]interface{}{"title": " "})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// Lessons go into a module on creation or later; a module of another course is refused
+	status, _ = postJSON(t, base+"/lessons", map[string]interface{}{"title": "Plato", "module_id": ancientID})
+	assert.Equal(t, fiber.StatusOK, status)
+	postJSON(t, base+"/lessons", map[string]interface{}{"title": "Descartes"})
+	postJSON(t, base+"/lessons", map[string]interface{}{"title": "Glossary"})
+	var descartes models.Lesson
+	db.Where("course_id = ? AND title = ?", course.ID, "Descartes").First(&descartes)
+	status, _ = sendJSON(t, "PATCH", fmt.Sprintf("%s/lessons/%d", base, descartes.ID), map[string]interface{}{"module_id": modernID})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	other := models.Course{Title: "Other Course", AuthorID: testUser.ID}
+	db.Create(&other)
+	foreign := models.CourseModule{CourseID: other.ID, Title: "Elsewhere"}
+	db.Create(&foreign)
+	status, _ = postJSON(t, base+"/lessons", map[string]interface{}{"title": "Stray", "module_id": foreign.ID})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	// Modern philosophy moves in front of the ancient one
+	status, _ = sendJSON(t, "PATCH", fmt.Sprintf("%s/modules/%d", base, modernID), map[string]interface{}{"sequence_order": 0})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/courses/%d", course.ID), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	var details struct {
+		Course struct {
+			Lessons []interface{} `json:"lessons"`
+			Modules []struct {
+				Title   string `json:"title"`
+				Lessons []struct {
+					Title string `json:"Title"`
+				} `json:"lessons"`
+			} `json:"modules"`
+			Unassigned []struct {
+				Title string `json:"Title"`
+			} `json:"unassigned_lessons"`
+		} `json:"course"`
+	}
+	json.NewDecoder(resp.Body).Decode(&details)
+	assert.Len(t, details.Course.Lessons, 3)
+	if assert.Len(t, details.Course.Modules, 2) {
+		assert.Equal(t, "Modern philosophy", details.Course.Modules[0].Title)
+		assert.Equal(t, "Descartes", details.Course.Modules[0].Lessons[0].Title)
+		assert.Equal(t, "Plato", details.Course.Modules[1].Lessons[0].Title)
+	}
+	if assert.Len(t, details.Course.Unassigned, 1) {
+		assert.Equal(t, "Glossary", details.Course.Unassigned[0].Title)
+	}
+
+	// Deleting a module keeps its lessons in the course
+	status, _ = sendJSON(t, "DELETE", fmt.Sprintf("%s/modules/%d", base, ancientID), nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	var plato models.Lesson
+	db.Where("course_id = ? AND title = ?", course.ID, "Plato").First(&plato)
+	assert.Nil(t, plato.ModuleID)
+}
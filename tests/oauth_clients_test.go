@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthClients(t *testing.T) {
+	status, created := postJSON(t, "/api/admin/oauth/clients", map[string]interface{}{
+		"name":          "Reading companion",
+		"kind":          "mobile",
+		"scopes":        []string{"profile:read", "courses:read"},
+		"redirect_uris": []string{"philosofium-companion://callback"},
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	client := created["data"].(map[string]interface{})
+	clientID := client["client_id"].(string)
+	assert.Nil(t, client["client_secret"], "mobile clients are public and use PKCE")
+
+	// A client can't be granted more than it was registered for
+	status, _ = postJSON(t, "/api/oauth/authorize", map[string]interface{}{
+		"client_id":      clientID,
+		"scope":          "courses:write",
+		"code_challenge": "x",
+	})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	verifier := "a-long-random-verifier-for-the-companion-app-0123456789"
+	sum := sha256.Sum256([]byte(verifier))
+	status, authorized := postJSON(t, "/api/oauth/authorize", map[string]interface{}{
+		"client_id":             clientID,
+		"scope":                 "profile:read",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	code := authorized["data"].(map[string]interface{})["code"].(string)
+
+	exchange := func(verifier string) (int, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]interface{}{
+			"grant_type":    "authorization_code",
+			"code":          code,
+			"client_id":     clientID,
+			"redirect_uri":  "philosofium-companion://callback",
+			"code_verifier": verifier,
+		})
+		req := httptest.NewRequest("POST", "/api/oauth/token", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, result := exchange("wrong-verifier")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", result["error"])
+
+	status, result = exchange(verifier)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "Bearer", result["token_type"])
+	assert.Equal(t, "profile:read", result["scope"])
+	token := result["access_token"].(string)
+
+	// The code is good for one token only
+	status, _ = exchange(verifier)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	request := func(method, path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, _ = request("GET", "/api/user/profile")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// The user is an admin, but the app only got profile:read
+	status, result = request("GET", "/api/courses/")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, "INSUFFICIENT_SCOPE", result["code"])
+	status, _ = request("GET", "/api/admin/oauth/clients")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = request("GET", "/api/user/api-keys")
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	// Revoking the client invalidates the tokens it already holds
+	req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/admin/oauth/clients/%d", int(client["id"].(float64))), nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	status, result = request("GET", "/api/user/profile")
+	assert.Equal(t, fiber.StatusUnauthorized, status)
+	assert.Equal(t, "CLIENT_REVOKED", result["code"])
+}
+
+func TestClientTokenCredentials(t *testing.T) {
+	client := models.OAuthClient{ClientID: "cl_profile_editor", Name: "Profile editor", Kind: models.ClientWeb,
+		Scopes: models.ScopeProfileRead + " " + models.ScopeProfileWrite}
+	assert.NoError(t, db.Create(&client).Error)
+	user := models.User{Username: "client_profile_owner", Email: "client_profile_owner@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&user).Error)
+	token, _, err := utils.GenerateClientToken(&user, client.ClientID, models.ScopeProfileWrite, cfg)
+	assert.NoError(t, err)
+
+	update := func(payload map[string]interface{}) (int, map[string]interface{}) {
+		return sendJSONAs(t, "PUT", "/api/user/profile", "Bearer "+token, payload)
+	}
+
+	// profile:write covers the profile, not the credentials
+	status, result := update(map[string]interface{}{"old_password": "hash", "new_password": "hijacked"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, "INSUFFICIENT_SCOPE", result["code"])
+	status, _ = update(map[string]interface{}{"email": "attacker@example.com"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, _ = update(map[string]interface{}{"username": "client_profile_renamed", "email": ""})
+	assert.Equal(t, fiber.StatusOK, status)
+	var saved models.User
+	db.First(&saved, user.ID)
+	assert.Equal(t, "client_profile_renamed", saved.Username)
+	assert.Equal(t, "client_profile_owner@example.com", saved.Email)
+}
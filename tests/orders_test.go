@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/payments"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePayments открывает «страницу оплаты» без сети и принимает вебхуки с заголовком X-Test-Signature: ok
+type fakePayments struct {
+	checkouts int
+}
+
+func (f *fakePayments) Name() string { return "fake" }
+
+func (f *fakePayments) CreateCheckout(_ context.Context, checkout payments.Checkout) (payments.Session, error) {
+	f.checkouts++
+	id := fmt.Sprintf("sess_%d", checkout.OrderID)
+	return payments.Session{ID: id, URL: "https://pay.example.com/" + id}, nil
+}
+
+func (f *fakePayments) ParseWebhook(_ context.Context, body []byte, header func(string) string) (payments.Event, error) {
+	if header("X-Test-Signature") != "ok" {
+		return payments.Event{}, payments.ErrSignature
+	}
+	var event payments.Event
+	err := json.Unmarshal(body, &event)
+	return event, err
+}
+
+func TestPaidCourseEnrollment(t *testing.T) {
+	previous := payments.Default
+	defer func() { payments.Default = previous }()
+
+	course := models.Course{
+		Title:          "Paid Seminar",
+		AuthorID:       testUser.ID,
+		Status:         models.CoursePublished,
+		Lessons:        []models.Lesson{{Title: "Only lesson", Content: "<p>The paid part</p>", SequenceOrder: 1}},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+	student := models.User{Username: "paying_student", Email: "paying_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	send := func(method, path, auth string, payload interface{}, headers ...string) (int, map[string]interface{}) {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+	checkoutPath := fmt.Sprintf("/api/courses/%d/checkout", course.ID)
+	progressPath := fmt.Sprintf("/api/courses/%d/progress", course.ID)
+
+	// Free courses keep the usual flow
+	status, _ := send("POST", checkoutPath, token, nil)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	settingsPath := fmt.Sprintf("/api/admin/courses/%d/settings", course.ID)
+	status, _ = send("PUT", settingsPath, jwtToken, map[string]interface{}{"price": 1500, "currency": "euro"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, updated := send("PUT", settingsPath, jwtToken, map[string]interface{}{"price": 1500, "currency": "EUR"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"amount": float64(1500), "currency": "eur"}, updated["price"])
+
+	status, denied := send("POST", progressPath, token, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusPaymentRequired, status)
+	assert.Equal(t, true, denied["payment_required"])
+	// The author doesn't pay for their own course
+	status, _ = send("POST", progressPath, jwtToken, map[string]interface{}{"hours_spent": 1})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Before buying, the student sees the outline but not the lessons themselves
+	lessonContent := func() interface{} {
+		_, details := send("GET", fmt.Sprintf("/api/courses/%d", course.ID), token, nil)
+		return details["course"].(map[string]interface{})["lessons"].([]interface{})[0].(map[string]interface{})["Content"]
+	}
+	blocksPath := fmt.Sprintf("/api/courses/%d/lessons/%d/blocks", course.ID, course.Lessons[0].ID)
+	assert.Equal(t, "", lessonContent())
+	status, _ = send("GET", blocksPath, token, nil)
+	assert.Equal(t, fiber.StatusPaymentRequired, status)
+	status, _ = send("GET", blocksPath, jwtToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	payments.Default = payments.Disabled{}
+	status, _ = send("POST", checkoutPath, token, nil)
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+
+	fake := &fakePayments{}
+	payments.Default = fake
+	status, created := send("POST", checkoutPath, token, nil)
+	assert.Equal(t, fiber.StatusCreated, status)
+	order := created["data"].(map[string]interface{})
+	orderID := uint(order["id"].(float64))
+	assert.Equal(t, models.OrderPending, order["status"])
+	assert.Equal(t, fmt.Sprintf("https://pay.example.com/sess_%d", orderID), order["checkout_url"])
+
+	// Pressing the button again reuses the open order
+	status, again := send("POST", checkoutPath, token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, order["id"], again["data"].(map[string]interface{})["id"])
+	assert.Equal(t, 1, fake.checkouts)
+	// A second pending order for the same course can't be created even bypassing the handler
+	assert.Error(t, db.Create(&models.Order{UserID: student.ID, CourseID: course.ID, Status: models.OrderPending, Provider: "fake"}).Error)
+
+	// Coming back from the payment page enrolls nobody, only the webhook does
+	status, _ = send("POST", progressPath, token, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusPaymentRequired, status)
+
+	paid := map[string]interface{}{"Type": payments.EventPaid, "OrderID": orderID, "SessionID": fmt.Sprintf("sess_%d", orderID), "PaymentID": "pi_1"}
+	status, _ = send("POST", "/api/payments/webhook", "", paid)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, result := send("POST", "/api/payments/webhook", "", paid, "X-Test-Signature", "ok")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, true, result["data"].(map[string]interface{})["processed"])
+	status, result = send("POST", "/api/payments/webhook", "", paid, "X-Test-Signature", "ok")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, false, result["data"].(map[string]interface{})["processed"])
+
+	status, fetched := send("GET", fmt.Sprintf("/api/orders/%d", orderID), token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, models.OrderPaid, fetched["data"].(map[string]interface{})["status"])
+	status, _ = send("GET", fmt.Sprintf("/api/orders/%d", orderID), jwtToken, nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, _ = send("POST", progressPath, token, map[string]interface{}{"mark_completed": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "<p>The paid part</p>", lessonContent())
+	status, _ = send("GET", blocksPath, token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	var started int64
+	db.Model(&models.UserActivity{}).Where("user_id = ? AND target_id = ? AND action_type = ?", student.ID, course.ID, models.ActivityCourseStart).Count(&started)
+	assert.Equal(t, int64(1), started)
+
+	status, _ = send("POST", checkoutPath, token, nil)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	status, listed := send("GET", "/api/orders", token, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(1), listed["total"])
+}
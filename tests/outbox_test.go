@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/outbox"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestRegisterEnqueuesWelcomeEmail(t *testing.T) {
+	registerData := map[string]string{
+		"username":      "outboxuser",
+		"email":         "outboxuser@example.com",
+		"password_hash": "password123",
+	}
+	jsonData, _ := json.Marshal(registerData)
+
+	req := httptest.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var count int64
+	db.Model(&models.OutboxMessage{}).
+		Where("kind = ? AND status = ? AND payload LIKE ?", outbox.KindEmail, outbox.StatusPending, "%outboxuser@example.com%").
+		Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestLessonNotificationsFanOut(t *testing.T) {
+	course := models.Course{Title: "Fan-out Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	learners := []models.User{
+		{Username: "fanout_reader", Email: "fanout_reader@example.com", PasswordHash: "hash"},
+		{Username: "fanout_muted", Email: "fanout_muted@example.com", PasswordHash: "hash"},
+	}
+	for i := range learners {
+		db.Where("username = ?", learners[i].Username).FirstOrCreate(&learners[i])
+		db.Create(&models.UserCourseProgress{UserID: learners[i].ID, CourseID: course.ID})
+	}
+	db.Unscoped().Where("user_id = ?", learners[1].ID).Delete(&models.UserSettings{})
+	muted := models.UserSettings{UserID: learners[1].ID}
+	db.Create(&muted)
+	// false is a zero value, Create would leave the column at its default
+	db.Model(&muted).Update("email_course_updates", false)
+
+	body, _ := json.Marshal(map[string]string{"title": "Fan-out Lesson", "content": "Text"})
+	req := httptest.NewRequest("POST", "/api/admin/courses/"+strconv.Itoa(int(course.ID))+"/lessons", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	countEmails := func(to string) int64 {
+		var count int64
+		db.Model(&models.OutboxMessage{}).
+			Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%"+to+"%", "%Fan-out Lesson%").
+			Count(&count)
+		return count
+	}
+
+	// The request queues a single message, not one email per learner
+	var fanout models.OutboxMessage
+	assert.NoError(t, db.Where("kind = ? AND payload LIKE ?", outbox.KindFanout, "%Fan-out Lesson%").First(&fanout).Error)
+	assert.Equal(t, int64(0), countEmails("fanout_reader@example.com"))
+
+	deliverer := &outbox.FanoutDeliverer{DB: db}
+	assert.NoError(t, deliverer.Deliver(context.Background(), fanout))
+	assert.Equal(t, int64(1), countEmails("fanout_reader@example.com"))
+	assert.Equal(t, int64(0), countEmails("fanout_muted@example.com"))
+
+	// A retry of the fan-out after its emails were queued doesn't queue them again
+	assert.NoError(t, deliverer.Deliver(context.Background(), fanout))
+	assert.Equal(t, int64(1), countEmails("fanout_reader@example.com"))
+}
+
+// probeDeliverer checks from another connection that the message being delivered isn't locked
+type probeDeliverer struct {
+	locked bool
+}
+
+func (p *probeDeliverer) Deliver(ctx context.Context, msg models.OutboxMessage) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "NOWAIT"}).First(&models.OutboxMessage{}, msg.ID).Error
+	})
+	p.locked = err != nil
+	return nil
+}
+
+func TestOutboxRelayClaimsBeforeDelivery(t *testing.T) {
+	msg := models.OutboxMessage{Kind: "probe", Payload: "{}", Status: outbox.StatusPending, NextAttemptAt: time.Now().Add(-time.Second)}
+	assert.NoError(t, db.Create(&msg).Error)
+
+	probe := &probeDeliverer{}
+	relay := outbox.NewRelay(db, cfg, log.New(io.Discard, "", 0))
+	relay.Deliverers = map[string]outbox.Deliverer{"probe": probe}
+	// Messages queued by other tests come first and are put off by the missing deliverer
+	for {
+		processed, err := relay.ProcessBatch(context.Background())
+		assert.NoError(t, err)
+		if processed == 0 || err != nil {
+			break
+		}
+	}
+
+	// Delivery runs after the claim is committed, without holding the row
+	assert.False(t, probe.locked)
+	db.First(&msg, msg.ID)
+	assert.Equal(t, outbox.StatusSent, msg.Status)
+	assert.Equal(t, 1, msg.Attempts)
+}
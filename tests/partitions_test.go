@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"project/backend/jobs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintainPartitions(t *testing.T) {
+	assert.NoError(t, db.Exec(`CREATE TABLE partition_probe (id SERIAL, happened_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (id, happened_at)) PARTITION BY RANGE (happened_at)`).Error)
+	defer db.Exec("DROP TABLE IF EXISTS partition_probe CASCADE")
+
+	now := time.Now().UTC()
+	old := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -14, 0)
+	oldName := fmt.Sprintf("partition_probe_y%04dm%02d", old.Year(), old.Month())
+	assert.NoError(t, db.Exec(fmt.Sprintf(`CREATE TABLE %s PARTITION OF partition_probe FOR VALUES FROM ('%s') TO ('%s')`,
+		oldName, old.Format("2006-01-02"), old.AddDate(0, 1, 0).Format("2006-01-02"))).Error)
+
+	maintain := jobs.MaintainPartitions(db, 2, []jobs.PartitionedTable{
+		{Table: "partition_probe", RetentionMonths: 12},
+		{Table: "login_history"}, // a plain table in the test database, skipped
+	})
+	assert.NoError(t, maintain(context.Background()))
+	// Running twice is harmless
+	assert.NoError(t, maintain(context.Background()))
+
+	var partitions []string
+	db.Raw(`SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent WHERE p.relname = 'partition_probe' ORDER BY 1`).Scan(&partitions)
+	assert.Len(t, partitions, 3)
+	assert.NotContains(t, partitions, oldName)
+
+	// Inserts for the current month are routed to its partition
+	assert.NoError(t, db.Exec("INSERT INTO partition_probe (happened_at) VALUES (?)", now).Error)
+}
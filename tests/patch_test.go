@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionalMergePatch(t *testing.T) {
+	var input struct {
+		Title         utils.Optional[string] `json:"title"`
+		Topic         utils.Optional[string] `json:"topic"`
+		SequenceOrder utils.Optional[int]    `json:"sequence_order"`
+		Difficulty    utils.Optional[string] `json:"difficulty"`
+	}
+	err := json.Unmarshal([]byte(`{"title": "", "topic": null, "sequence_order": 0}`), &input)
+	assert.NoError(t, err)
+
+	title, topic, order, difficulty := "Ethics", "Philosophy", 3, "hard"
+
+	// PUT keeps the old "empty means no change" behaviour
+	assert.False(t, input.Title.Apply(&title, false))
+	assert.False(t, input.SequenceOrder.Apply(&order, false))
+	assert.Equal(t, "Ethics", title)
+	assert.Equal(t, 3, order)
+
+	// PATCH clears fields and allows zero values; absent keys stay untouched
+	input.Title.Apply(&title, true)
+	input.Topic.Apply(&topic, true)
+	input.SequenceOrder.Apply(&order, true)
+	input.Difficulty.Apply(&difficulty, true)
+	assert.Equal(t, "", title)
+	assert.Equal(t, "", topic)
+	assert.Equal(t, 0, order)
+	assert.Equal(t, "hard", difficulty)
+}
+
+func TestUpdateQuestionZeroAndNull(t *testing.T) {
+	test := models.Test{Title: "Zero Values Quiz", AuthorID: testUser.ID}
+	db.Create(&test)
+	question := models.TestQuestion{TestID: test.ID, Title: "Q1", Question: "Who taught Aristotle?",
+		Options: `["Plato","Socrates"]`, CorrectAnswer: 1}
+	db.Create(&question)
+	path := fmt.Sprintf("/api/admin/tests/%d/questions/%d", test.ID, question.ID)
+
+	// correct_answer 0 is the first option, PUT must not drop it
+	status, _ := sendJSON(t, "PUT", path, map[string]interface{}{"correct_answer": 0})
+	assert.Equal(t, fiber.StatusOK, status)
+	db.First(&question, question.ID)
+	assert.Equal(t, 0, question.CorrectAnswer)
+
+	// null would blank a required field
+	status, _ = sendJSON(t, "PATCH", path, map[string]interface{}{"title": nil})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	db.First(&question, question.ID)
+	assert.Equal(t, "Q1", question.Title)
+
+	status, _ = sendJSON(t, "PATCH", path, map[string]interface{}{"correct_answer": 5})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+}
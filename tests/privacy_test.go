@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfilePrivacy(t *testing.T) {
+	student := models.User{Username: "privacy_student", Email: "hume@example.com", PasswordHash: "x",
+		University: "Edinburgh", Group: "PH-1", Active: true}
+	viewer := models.User{Username: "privacy_viewer", Email: "viewer@example.com", PasswordHash: "x", Active: true}
+	db.Create(&student)
+	db.Create(&viewer)
+
+	settings := utils.DefaultUserSettings(student.ID)
+	settings.HideEmail = true
+	settings.HideUniversity = true
+	db.Create(&settings)
+
+	viewerToken, err := utils.GenerateJWTToken(&viewer, cfg)
+	assert.NoError(t, err)
+	studentToken, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	get := func(url, token string) map[string]interface{} {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var result struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result.Data
+	}
+
+	url := "/api/users/" + strconv.Itoa(int(student.ID))
+
+	// Other students don't see hidden fields
+	profile := get(url, viewerToken)
+	assert.Equal(t, "privacy_student", profile["username"])
+	assert.NotContains(t, profile, "email")
+	assert.NotContains(t, profile, "university")
+	assert.NotContains(t, profile, "group")
+	assert.Contains(t, profile, "stats")
+
+	// The email isn't shown to other students even when it isn't hidden
+	assert.NotContains(t, get("/api/users/"+strconv.Itoa(int(viewer.ID)), studentToken), "email")
+
+	// Admins and the student themself see everything
+	assert.Equal(t, "hume@example.com", get(url, jwtToken)["email"])
+	assert.Equal(t, "Edinburgh", get(url, studentToken)["university"])
+
+	// The preview matches what others see, the own profile lists the flags
+	preview := get("/api/user/profile?view=public", studentToken)
+	assert.NotContains(t, preview, "email")
+	own := get("/api/user/profile", studentToken)
+	assert.Equal(t, "hume@example.com", own["email"])
+	assert.Equal(t, true, own["privacy"].(map[string]interface{})["hide_email"])
+
+	// A private profile keeps only the name and avatar, also on the author page
+	db.Model(&models.UserSettings{}).Where("user_id = ?", student.ID).Update("private_profile", true)
+	profile = get(url, viewerToken)
+	assert.Equal(t, true, profile["private"])
+	assert.NotContains(t, profile, "stats")
+	author := get(url+"/profile", viewerToken)
+	assert.Equal(t, true, author["private"])
+	assert.NotContains(t, author, "member_since")
+	assert.Contains(t, author, "courses")
+}
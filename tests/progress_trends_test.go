@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressTrends(t *testing.T) {
+	user := models.User{Username: "trends_student", Email: "trends@example.com", PasswordHash: "x", Active: true}
+	db.Create(&user)
+
+	now := time.Now().UTC()
+	previous := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	db.Create(&models.UserProgressSnapshot{
+		UserID:     user.ID,
+		Period:     previous.Format(models.SnapshotPeriodLayout),
+		TotalHours: 4,
+		Hours:      4,
+		Lessons:    3,
+		AvgScore:   70,
+		BestStreak: 5,
+	})
+
+	course := models.Course{Title: "Trends 101"}
+	db.Create(&course)
+	db.Create(&models.UserCourseProgress{UserID: user.ID, CourseID: course.ID, HoursSpent: 10})
+	db.Create(&models.UserProgress{UserID: user.ID, LastActive: now, StreakDays: 2})
+	for _, activity := range []models.UserActivity{
+		{UserID: user.ID, ActionType: models.ActivityLessonComplete},
+		{UserID: user.ID, ActionType: models.ActivityTestComplete, Score: 80},
+		{UserID: user.ID, ActionType: models.ActivityTestComplete, Score: 100},
+	} {
+		db.Create(&activity)
+	}
+
+	assert.NoError(t, jobs.SnapshotUserProgress(db)(context.Background()))
+
+	token, err := utils.GenerateJWTToken(&user, cfg)
+	assert.NoError(t, err)
+	req := httptest.NewRequest("GET", "/api/progress/overview", nil)
+	req.Header.Set("Authorization", token)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var overview models.ProgressOverview
+	json.NewDecoder(resp.Body).Decode(&overview)
+	trends := overview.Trends
+	assert.Equal(t, previous.Format(models.SnapshotPeriodLayout), trends.PreviousPeriod)
+	// Hours of the month are counted from the previous snapshot's total
+	assert.Equal(t, models.TrendDelta{Current: 6, Previous: 4, Delta: 2}, trends.Hours)
+	assert.Equal(t, models.TrendDelta{Current: 1, Previous: 3, Delta: -2}, trends.Lessons)
+	assert.Equal(t, models.TrendDelta{Current: 90, Previous: 70, Delta: 20}, trends.AvgScore)
+	assert.Equal(t, models.TrendDelta{Current: 2, Previous: 5, Delta: -3}, trends.BestStreak)
+
+	// A broken streak doesn't lower the best one of the month
+	db.Model(&models.UserProgress{}).Where("user_id = ?", user.ID).Update("streak_days", 1)
+	assert.NoError(t, jobs.SnapshotUserProgress(db)(context.Background()))
+	var snapshot models.UserProgressSnapshot
+	db.Where("user_id = ? AND period = ?", user.ID, trends.Period).First(&snapshot)
+	assert.Equal(t, 2, snapshot.BestStreak)
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"bytes"
+	"log"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryPlanGuard(t *testing.T) {
+	// A separate connection, so the guard doesn't stay registered for the other tests
+	guarded, err := utils.InitDB(cfg)
+	assert.NoError(t, err)
+	sqlDB, _ := guarded.DB()
+	defer sqlDB.Close()
+
+	var buf bytes.Buffer
+	assert.NoError(t, utils.RegisterQueryPlanGuard(guarded, log.New(&buf, "", 0), 0))
+	assert.NoError(t, db.Exec("ANALYZE users").Error)
+
+	var users []models.User
+	assert.NoError(t, guarded.Find(&users).Error)
+	assert.Contains(t, buf.String(), "Seq Scan on users")
+
+	// The same statement is reported once
+	buf.Reset()
+	assert.NoError(t, guarded.Find(&users).Error)
+	assert.Empty(t, buf.String())
+
+	// Tables below the threshold are ignored
+	quiet, err := utils.InitDB(cfg)
+	assert.NoError(t, err)
+	quietDB, _ := quiet.DB()
+	defer quietDB.Close()
+	assert.NoError(t, utils.RegisterQueryPlanGuard(quiet, log.New(&buf, "", 0), 1<<40))
+	assert.NoError(t, quiet.Find(&users).Error)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"context"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	// A separate connection, so the callbacks don't stay registered for the other tests
+	limited, err := utils.InitDB(cfg)
+	assert.NoError(t, err)
+	sqlDB, _ := limited.DB()
+	defer sqlDB.Close()
+	assert.NoError(t, utils.RegisterQueryTimeout(limited, 200*time.Millisecond))
+
+	ctx, cancel := utils.NewRequestContext(context.Background(), "query-timeout-test", 5*time.Second)
+	defer cancel()
+
+	// Count and Find on one handle: the deadline of the first statement must not cancel the second
+	query := limited.WithContext(ctx).Model(&models.User{}).Where("id = ?", testUser.ID)
+	var total int64
+	assert.NoError(t, query.Count(&total).Error)
+	var users []models.User
+	assert.NoError(t, query.Find(&users).Error)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, users, 1)
+	assert.False(t, utils.QueryTimedOut(ctx))
+
+	// Each statement gets the timeout on its own, not the whole chain
+	time.Sleep(300 * time.Millisecond)
+	assert.NoError(t, query.Find(&users).Error)
+
+	err = limited.WithContext(ctx).Exec("SELECT pg_sleep(1)").Error
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, utils.QueryTimedOut(ctx))
+}
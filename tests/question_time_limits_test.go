@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuestionTimeLimits(t *testing.T) {
+	test := models.Test{Title: "Rapid-fire Logic", AuthorID: testUser.ID}
+	db.Create(&test)
+	untimed := models.TestQuestion{TestID: test.ID, Title: "Q1", Question: "Is modus ponens valid?", Options: `["Yes","No"]`, CorrectAnswer: 0, SequenceOrder: 1}
+	quick := models.TestQuestion{TestID: test.ID, Title: "Q2", Question: "Who wrote the Organon?", Options: `["Plato","Aristotle"]`, CorrectAnswer: 1, SequenceOrder: 2, TimeLimitSeconds: 30}
+	missed := models.TestQuestion{TestID: test.ID, Title: "Q3", Question: "Is affirming the consequent valid?", Options: `["Yes","No"]`, CorrectAnswer: 1, SequenceOrder: 3, TimeLimitSeconds: 30}
+	for _, question := range []*models.TestQuestion{&untimed, &quick, &missed} {
+		assert.NoError(t, db.Create(question).Error)
+	}
+
+	student := models.User{Username: "rapid_student", Email: "rapid_student@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&student).Error)
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	send := func(method, path string, payload interface{}) (int, map[string]interface{}) {
+		return sendJSONAs(t, method, path, token, payload)
+	}
+	questionPath := func(question models.TestQuestion, action string) string {
+		return fmt.Sprintf("/api/tests/%d/questions/%d/%s", test.ID, question.ID, action)
+	}
+
+	// Timed questions are not readable before they are served
+	status, details := send("GET", fmt.Sprintf("/api/tests/%d", test.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	questions := details["test"].(map[string]interface{})["questions"].([]interface{})
+	assert.Contains(t, questions[0].(map[string]interface{}), "question")
+	assert.NotContains(t, questions[1].(map[string]interface{}), "question")
+	assert.Equal(t, float64(30), questions[1].(map[string]interface{})["time_limit_seconds"])
+
+	status, _ = send("POST", questionPath(quick, "answer"), map[string]interface{}{"answer": 1})
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	status, served := send("POST", questionPath(quick, "serve"), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	first := served["data"].(map[string]interface{})
+	assert.Equal(t, "Who wrote the Organon?", first["question"])
+	assert.Greater(t, first["seconds_left"].(float64), float64(0))
+
+	// Serving again doesn't restart the clock
+	_, served = send("POST", questionPath(quick, "serve"), nil)
+	assert.Equal(t, first["served_at"], served["data"].(map[string]interface{})["served_at"])
+
+	status, _ = send("POST", questionPath(quick, "answer"), map[string]interface{}{"answer": 1})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = send("POST", questionPath(quick, "answer"), map[string]interface{}{"answer": 0})
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	// The third question was opened a while ago
+	status, _ = send("POST", questionPath(missed, "serve"), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	db.Model(&models.QuestionServe{}).Where("user_id = ? AND question_id = ?", student.ID, missed.ID).
+		Update("served_at", time.Now().Add(-time.Minute))
+	status, result := send("POST", questionPath(missed, "answer"), map[string]interface{}{"answer": 1})
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, true, result["details"].(map[string]interface{})["timed_out"])
+
+	// The recorded answer wins over the submitted one, the late one counts as unanswered
+	status, result = send("POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), map[string]interface{}{
+		"answers": []map[string]interface{}{
+			{"question_id": untimed.ID, "answer": 0},
+			{"question_id": quick.ID, "answer": 0},
+			{"question_id": missed.ID, "answer": 1},
+		},
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	progress := result["progress"].(map[string]interface{})
+	assert.Equal(t, float64(2), progress["questions_answered"])
+	assert.Equal(t, float64(2), progress["correct_answers"])
+	assert.Equal(t, []interface{}{float64(missed.ID)}, result["timed_out"])
+
+	// Attempts are unlimited, so the results keep timed questions hidden until they are served again
+	status, result = send("GET", fmt.Sprintf("/api/tests/%d/result", test.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	reviewed := result["test"].(map[string]interface{})["questions"].([]interface{})
+	assert.Equal(t, float64(0), reviewed[0].(map[string]interface{})["correct_answer"])
+	assert.NotContains(t, reviewed[1].(map[string]interface{}), "question")
+	assert.NotContains(t, reviewed[1].(map[string]interface{}), "correct_answer")
+
+	// The next attempt starts every clock over
+	status, served = send("POST", questionPath(missed, "serve"), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(2), served["data"].(map[string]interface{})["attempt"])
+	assert.Equal(t, false, served["data"].(map[string]interface{})["answered"])
+
+	// Co-authors read the timed questions like the author does
+	coauthor := models.User{Username: "rapid_coauthor", Email: "rapid_coauthor@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&coauthor).Error)
+	db.Create(&models.TestAccessSettings{TestID: test.ID, AccessLevel: "public", Admins: fmt.Sprint(coauthor.ID), AttemptsAllowed: 5})
+	coauthorToken, err := utils.GenerateJWTToken(&coauthor, cfg)
+	assert.NoError(t, err)
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/tests/%d", test.ID), nil)
+	req.Header.Set("Authorization", coauthorToken)
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	json.NewDecoder(resp.Body).Decode(&details)
+	questions = details["test"].(map[string]interface{})["questions"].([]interface{})
+	assert.Equal(t, "Who wrote the Organon?", questions[1].(map[string]interface{})["question"])
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/quota"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestQuotaWarnings(t *testing.T) {
+	limit, percents := cfg.MaxCoursesPerAuthor, cfg.QuotaWarnPercents
+	cfg.MaxCoursesPerAuthor, cfg.QuotaWarnPercents = 4, "75, 50, nope"
+	defer func() { cfg.MaxCoursesPerAuthor, cfg.QuotaWarnPercents = limit, percents }()
+
+	author := models.User{Username: "quota_author", Email: "quota_author@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&author).Error)
+	token, err := utils.GenerateJWTToken(&author, cfg)
+	assert.NoError(t, err)
+
+	warnings := func(percent string) int64 {
+		var count int64
+		db.Model(&models.OutboxMessage{}).
+			Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%quota_author@example.com%", "%used "+percent+"%").
+			Count(&count)
+		return count
+	}
+	// The way the hourly job runs it
+	check := func() {
+		assert.NoError(t, db.Transaction(func(tx *gorm.DB) error { return quota.Check(tx, cfg, author.ID) }))
+	}
+	var courses []models.Course
+	addCourse := func() {
+		course := models.Course{Title: "Quota course", AuthorID: author.ID}
+		assert.NoError(t, db.Create(&course).Error)
+		courses = append(courses, course)
+	}
+
+	// Half of the limit warns once, however many times it is checked
+	addCourse()
+	addCourse()
+	check()
+	check()
+	assert.Equal(t, int64(1), warnings("50%"))
+
+	addCourse()
+	check()
+	assert.Equal(t, int64(1), warnings("75%"))
+
+	req := httptest.NewRequest("GET", "/api/user/usage", nil)
+	req.Header.Set("Authorization", token)
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Equal(t, []interface{}{float64(50), float64(75)}, result["meta"].(map[string]interface{})["warn_percents"])
+	usage := map[string]map[string]interface{}{}
+	for _, item := range result["data"].([]interface{}) {
+		usage[item.(map[string]interface{})["resource"].(string)] = item.(map[string]interface{})
+	}
+	assert.Equal(t, float64(3), usage[quota.ResourceCourses]["used"])
+	assert.Equal(t, float64(75), usage[quota.ResourceCourses]["percent"])
+	assert.Equal(t, float64(75), usage[quota.ResourceCourses]["threshold"])
+	assert.Equal(t, float64(0), usage[quota.ResourceStorage]["limit"])
+
+	// Going back below the thresholds clears them, so crossing again warns again
+	db.Delete(&courses[2])
+	db.Delete(&courses[1])
+	check()
+	var left int64
+	db.Model(&models.QuotaWarning{}).Where("user_id = ?", author.ID).Count(&left)
+	assert.Equal(t, int64(0), left)
+	addCourse()
+	check()
+	assert.Equal(t, int64(2), warnings("50%"))
+}
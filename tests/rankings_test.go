@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestLeaderboard(t *testing.T) {
+	test := models.Test{
+		Title:    "Ranked Test",
+		AuthorID: testUser.ID,
+		Questions: []models.TestQuestion{
+			{Question: "Q1", Options: `["a","b"]`, CorrectAnswer: 0},
+			{Question: "Q2", Options: `["a","b"]`, CorrectAnswer: 1},
+		},
+		AccessSettings: models.TestAccessSettings{AccessLevel: "public", AttemptsAllowed: 5},
+	}
+	assert.NoError(t, db.Create(&test).Error)
+
+	rival := models.User{Username: "rival", Email: "rival@example.com", PasswordHash: "hash"}
+	assert.NoError(t, db.Create(&rival).Error)
+	rivalToken, err := utils.GenerateJWTToken(&rival, cfg)
+	assert.NoError(t, err)
+
+	submit := func(token string, answers ...int) map[string]interface{} {
+		payload := []map[string]interface{}{}
+		for i, answer := range answers {
+			payload = append(payload, map[string]interface{}{"question_id": test.Questions[i].ID, "answer": answer})
+		}
+		body, _ := json.Marshal(map[string]interface{}{"answers": payload})
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		ranking, _ := result["ranking"].(map[string]interface{})
+		return ranking
+	}
+
+	// Rival scores 50, the test user 0
+	ranking := submit(rivalToken, 0, 0)
+	assert.Equal(t, float64(1), ranking["rank"])
+	ranking = submit(jwtToken, 1, 0)
+	assert.Equal(t, float64(2), ranking["rank"])
+	assert.Equal(t, float64(2), ranking["total"])
+
+	// A perfect retry moves the test user up one place
+	ranking = submit(jwtToken, 0, 1)
+	assert.Equal(t, float64(1), ranking["rank"])
+	assert.Equal(t, float64(1), ranking["delta"])
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/tests/%d/leaderboard", test.ID), nil)
+	req.Header.Set("Authorization", rivalToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var board struct {
+		Leaderboard []map[string]interface{} `json:"leaderboard"`
+		Me          map[string]interface{}   `json:"me"`
+	}
+	json.NewDecoder(resp.Body).Decode(&board)
+	if assert.Len(t, board.Leaderboard, 2) {
+		assert.Equal(t, "testuser", board.Leaderboard[0]["username"])
+		assert.Equal(t, "rival", board.Leaderboard[1]["username"])
+	}
+	assert.Equal(t, float64(2), board.Me["rank"])
+	assert.Equal(t, float64(-1), board.Me["delta"])
+
+	// The latest attempt counts, not the best one, as in the user's progress
+	ranking = submit(jwtToken, 1, 0)
+	assert.Equal(t, float64(2), ranking["rank"])
+	assert.Equal(t, float64(-1), ranking["delta"])
+
+	// The board is hidden from those who can't open the test
+	assert.NoError(t, db.Model(&models.TestAccessSettings{}).Where("test_id = ?", test.ID).
+		Update("access_level", models.AccessRestricted).Error)
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/tests/%d/leaderboard", test.ID), nil)
+	req.Header.Set("Authorization", rivalToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 403, resp.StatusCode)
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/config"
+	"project/backend/middleware"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimits(t *testing.T) {
+	limits := &config.Config{
+		JWTSecret:              cfg.JWTSecret,
+		RateLimitWindowSeconds: 60,
+		RateLimitMax:           5,
+		AuthRateLimitMax:       2,
+		WriteRateLimitMax:      3,
+	}
+	probe := fiber.New()
+	probe.Use(middleware.RateLimitMiddleware(limits, nil))
+	probe.Use("/api/auth", middleware.AuthRateLimitMiddleware(limits, nil))
+	probe.Use(middleware.WriteRateLimitMiddleware(limits, nil))
+	probe.All("/api/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send := func(path string) int {
+		resp, err := probe.Test(httptest.NewRequest("GET", path, nil))
+		assert.NoError(t, err)
+		if resp.StatusCode == fiber.StatusTooManyRequests {
+			assert.NotEmpty(t, resp.Header.Get(fiber.HeaderRetryAfter))
+			var body map[string]interface{}
+			json.NewDecoder(resp.Body).Decode(&body)
+			assert.Equal(t, false, body["success"])
+			assert.NotEmpty(t, body["retry_after"])
+		}
+		return resp.StatusCode
+	}
+
+	t.Run("AuthRoutesHaveStricterLimit", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, send("/api/auth/login"))
+		assert.Equal(t, fiber.StatusOK, send("/api/auth/login"))
+		assert.Equal(t, fiber.StatusTooManyRequests, send("/api/auth/login"))
+		// Other routes only count against the general limit
+		assert.Equal(t, fiber.StatusOK, send("/api/courses"))
+	})
+
+	t.Run("GeneralLimitPerIP", func(t *testing.T) {
+		// The auth subtest already used 4 of the 5 requests
+		assert.Equal(t, fiber.StatusOK, send("/api/courses"))
+		assert.Equal(t, fiber.StatusTooManyRequests, send("/api/courses"))
+	})
+
+	t.Run("WritesLimitedPerUser", func(t *testing.T) {
+		writers := fiber.New()
+		writers.Use(middleware.WriteRateLimitMiddleware(limits, nil))
+		writers.All("/api/*", func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+		write := func(method, token string) int {
+			req := httptest.NewRequest(method, "/api/courses", nil)
+			if token != "" {
+				req.Header.Set("Authorization", token)
+			}
+			resp, err := writers.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		other := models.User{Username: "ratelimit_writer", Email: "ratelimit_writer@example.com", PasswordHash: "hash"}
+		db.Where("username = ?", other.Username).FirstOrCreate(&other)
+		otherToken, err := utils.GenerateJWTToken(&other, limits)
+		assert.NoError(t, err)
+		adminToken, err := utils.GenerateJWTToken(&testUser, limits)
+		assert.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, fiber.StatusOK, write("POST", adminToken))
+		}
+		assert.Equal(t, fiber.StatusTooManyRequests, write("PUT", adminToken))
+		// Reads are never limited by the write limiter
+		assert.Equal(t, fiber.StatusOK, write("GET", adminToken))
+		// Another user has a bucket of their own
+		assert.Equal(t, fiber.StatusOK, write("POST", otherToken))
+		// Anonymous writes are keyed by IP, separately from users
+		assert.Equal(t, fiber.StatusOK, write("DELETE", ""))
+	})
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/middleware"
+	"project/backend/models"
+	"project/backend/utils"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCourseWithoutPermission(t *testing.T) {
+	student := models.User{
+		Username:     "student_rbac",
+		Email:        "student_rbac@example.com",
+		PasswordHash: "hash",
+	}
+	db.Where("username = ?", student.Username).FirstOrCreate(&student)
+
+	token, err := utils.GenerateJWTToken(&student, cfg)
+	assert.NoError(t, err)
+
+	jsonData, _ := json.Marshal(map[string]interface{}{"title": "Forbidden Course"})
+	req := httptest.NewRequest("POST", "/api/admin/courses", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
+
+func TestAssignRoleGrantsPermission(t *testing.T) {
+	professor := models.User{
+		Username:     "professor_rbac",
+		Email:        "professor_rbac@example.com",
+		PasswordHash: "hash",
+	}
+	db.Where("username = ?", professor.Username).FirstOrCreate(&professor)
+	db.Where("user_id = ?", professor.ID).Delete(&models.UserRole{})
+
+	assignData, _ := json.Marshal(map[string]string{"role": "professor"})
+	assignReq := httptest.NewRequest("POST", "/api/admin/users/"+strconv.Itoa(int(professor.ID))+"/roles", bytes.NewBuffer(assignData))
+	assignReq.Header.Set("Content-Type", "application/json")
+	assignReq.Header.Set("Authorization", jwtToken)
+
+	assignResp, err := app.Test(assignReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, assignResp.StatusCode)
+
+	assert.True(t, utils.HasPermission(db, professor.ID, models.PermCoursesCreate))
+	assert.False(t, utils.HasPermission(db, professor.ID, models.PermRolesManage))
+
+	// Concurrent assignments of the same scoped role leave exactly one row
+	scoped := map[string]string{"role": "professor", "university": "MSU"}
+	statuses := make(chan int, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := sendJSON(t, "POST", "/api/admin/users/"+strconv.Itoa(int(professor.ID))+"/roles", scoped)
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	created := 0
+	for status := range statuses {
+		if status == fiber.StatusCreated {
+			created++
+		} else {
+			assert.Equal(t, fiber.StatusBadRequest, status)
+		}
+	}
+	assert.Equal(t, 1, created)
+	var assignments int64
+	db.Model(&models.UserRole{}).Where("user_id = ? AND university = ?", professor.ID, "MSU").Count(&assignments)
+	assert.Equal(t, int64(1), assignments)
+}
+
+func TestRevokeRoleKeepsOtherScopes(t *testing.T) {
+	professor := models.User{
+		Username:     "professor_scoped_rbac",
+		Email:        "professor_scoped_rbac@example.com",
+		PasswordHash: "hash",
+	}
+	db.Where("username = ?", professor.Username).FirstOrCreate(&professor)
+	db.Where("user_id = ?", professor.ID).Delete(&models.UserRole{})
+
+	var role models.Role
+	assert.NoError(t, db.Where("name = ?", "professor").First(&role).Error)
+	db.Create(&models.UserRole{UserID: professor.ID, RoleID: role.ID})
+	db.Create(&models.UserRole{UserID: professor.ID, RoleID: role.ID, University: "MSU"})
+
+	url := "/api/admin/users/" + strconv.Itoa(int(professor.ID)) + "/roles/" + strconv.Itoa(int(role.ID))
+	req := httptest.NewRequest("DELETE", url+"?university=MSU", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	var remaining []models.UserRole
+	db.Where("user_id = ?", professor.ID).Find(&remaining)
+	if assert.Len(t, remaining, 1) {
+		assert.Equal(t, "", remaining[0].University)
+	}
+
+	// The university assignment is already gone
+	req = httptest.NewRequest("DELETE", url+"?university=MSU", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestAdminMiddlewareChecksCurrentRole(t *testing.T) {
+	demoted := models.User{
+		Username:     "demoted_admin_rbac",
+		Email:        "demoted_admin_rbac@example.com",
+		PasswordHash: "hash",
+		Role:         "admin",
+	}
+	db.Where("username = ?", demoted.Username).FirstOrCreate(&demoted)
+	db.Model(&demoted).Update("role", "admin")
+	demoted.Role = "admin"
+	token, err := utils.GenerateJWTToken(&demoted, cfg)
+	assert.NoError(t, err)
+
+	probe := fiber.New()
+	probe.Get("/admin", middleware.AdminMiddleware(db, cfg), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	send := func() int {
+		req := httptest.NewRequest("GET", "/admin", nil)
+		req.Header.Set("Authorization", token)
+		resp, err := probe.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, fiber.StatusOK, send())
+
+	// The token still says admin, the database no longer does
+	db.Model(&demoted).Update("role", "user")
+	assert.Equal(t, fiber.StatusForbidden, send())
+}
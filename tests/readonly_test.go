@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"context"
+	"project/backend/cache"
+	"project/backend/models"
+	"project/backend/readonly"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	defer readonly.Set(context.Background(), db, false, "", testUser.ID)
+
+	status, _ := sendJSON(t, "PUT", "/api/admin/platform/read-only", map[string]interface{}{"enabled": true, "message": "Restoring a backup"})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Writes are refused with a code the frontend can react to, reads keep working
+	status, result := sendJSON(t, "POST", "/api/admin/topics", map[string]interface{}{"name": "Frozen topic"})
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	assert.Equal(t, string(utils.CodeReadOnly), result["code"])
+	assert.Equal(t, "Restoring a backup", result["message"])
+	status, _ = sendJSON(t, "GET", "/api/topics", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	cache.Status.Purge()
+	status, result = sendJSON(t, "GET", "/api/public/status", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	readOnly := result["data"].(map[string]interface{})["read_only"].(map[string]interface{})
+	assert.Equal(t, true, readOnly["enabled"])
+	assert.Equal(t, readonly.SourceAdmin, readOnly["source"])
+
+	// Another instance lifting the mode is picked up on refresh
+	db.Model(&models.PlatformSetting{}).Where("key = ?", models.SettingReadOnly).Update("value", `{"enabled":false}`)
+	assert.NoError(t, readonly.Refresh(context.Background(), db))
+	assert.False(t, readonly.Current().Enabled)
+	status, _ = sendJSON(t, "POST", "/api/admin/topics", map[string]interface{}{"name": "Thawed topic"})
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// The switch itself stays writable so the mode can be turned off
+	sendJSON(t, "PUT", "/api/admin/platform/read-only", map[string]interface{}{"enabled": true})
+	status, _ = sendJSON(t, "PUT", "/api/admin/platform/read-only", map[string]interface{}{"enabled": false})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.False(t, readonly.Current().Enabled)
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseReviews(t *testing.T) {
+	course := models.Course{
+		Title:          "Reviewed Course",
+		Status:         models.CoursePublished,
+		AuthorID:       testUser.ID,
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public"},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+
+	var tokens []string
+	for i := range 3 {
+		student := models.User{
+			Username:     fmt.Sprintf("review_student_%d", i),
+			Email:        fmt.Sprintf("review_student_%d@example.com", i),
+			PasswordHash: "hash",
+		}
+		assert.NoError(t, db.Create(&student).Error)
+		token, err := utils.GenerateJWTToken(&student, cfg)
+		assert.NoError(t, err)
+		tokens = append(tokens, token)
+		if i < 2 {
+			assert.NoError(t, db.Create(&models.UserCourseProgress{UserID: student.ID, CourseID: course.ID}).Error)
+		}
+	}
+
+	reviewPath := fmt.Sprintf("/api/courses/%d/review", course.ID)
+	rating := func() map[string]interface{} {
+		status, result := sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d", course.ID), jwtToken, nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		return result["course"].(map[string]interface{})["rating"].(map[string]interface{})
+	}
+
+	status, _ := sendJSONAs(t, "PUT", reviewPath, tokens[0], map[string]interface{}{"rating": 6})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = sendJSONAs(t, "PUT", reviewPath, tokens[2], map[string]interface{}{"rating": 5})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, _ = sendJSONAs(t, "PUT", reviewPath, tokens[0], map[string]interface{}{"rating": 3, "text": "Fine"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = sendJSONAs(t, "PUT", reviewPath, tokens[1], map[string]interface{}{"rating": 5, "text": "Superb"})
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// Editing keeps a single review per student
+	status, result := sendJSONAs(t, "PUT", reviewPath, tokens[0], map[string]interface{}{"rating": 4, "text": "Better on a second read"})
+	assert.Equal(t, fiber.StatusOK, status)
+	saved := result["data"].(map[string]interface{})
+	assert.Equal(t, "Better on a second read", saved["text"])
+	assert.Equal(t, float64(4), saved["rating"])
+	assert.Equal(t, "review_student_0", saved["user_name"])
+	status, result = sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d", course.ID), tokens[0], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, saved, result["course"].(map[string]interface{})["my_review"])
+
+	summary := rating()
+	assert.Equal(t, float64(2), summary["count"])
+	assert.Equal(t, 4.5, summary["average"])
+	histogram := summary["histogram"].(map[string]interface{})
+	assert.Equal(t, float64(1), histogram["5"])
+	assert.Equal(t, float64(1), histogram["4"])
+	assert.Equal(t, float64(0), histogram["3"])
+
+	status, result = sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d/reviews?rating=5", course.ID), tokens[2], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(1), result["total"])
+
+	// Older clients rate through comments: an enrolled student's rating updates the review, others' is dropped
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/comments/course/%d", course.ID), tokens[2], map[string]interface{}{"text": "Hello", "rating": 5})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/comments/course/%d", course.ID), tokens[0], map[string]interface{}{"text": "Still good", "rating": 4})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(2), rating()["count"])
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/comments/course/%d", course.ID), tokens[0], map[string]interface{}{"text": "Hello", "rating": 6})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, _ = sendJSONAs(t, "DELETE", reviewPath, tokens[1], nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	summary = rating()
+	assert.Equal(t, float64(1), summary["count"])
+	assert.Equal(t, float64(4), summary["average"])
+}
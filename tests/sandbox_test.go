@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/sandbox"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestDeveloperSandbox(t *testing.T) {
+	night := time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC)
+	assert.Equal(t, night, sandbox.NextReset(night.Add(-time.Hour), 3))
+	assert.Equal(t, night.AddDate(0, 0, 1), sandbox.NextReset(night, 3))
+	assert.True(t, sandbox.Due(sandbox.State{}, night, 3))
+	lastReset := night.Add(-24 * time.Hour)
+	assert.True(t, sandbox.Due(sandbox.State{LastResetAt: &lastReset}, night.Add(time.Minute), 3))
+	assert.False(t, sandbox.Due(sandbox.State{LastResetAt: &night}, night.Add(20*time.Hour), 3))
+
+	// Sandbox keys are told apart from live ones, and the live API points them to the sandbox
+	sandboxKey, _, _, err := utils.GenerateAPIKey(true)
+	assert.NoError(t, err)
+	assert.True(t, utils.IsSandboxAPIKey(sandboxKey))
+	liveKey, _, _, _ := utils.GenerateAPIKey(false)
+	assert.False(t, utils.IsSandboxAPIKey(liveKey))
+	assert.True(t, utils.IsLiveAPIKey(liveKey))
+	assert.False(t, utils.IsLiveAPIKey(sandboxKey))
+
+	req := httptest.NewRequest("GET", "/api/user/profile", nil)
+	req.Header.Set("X-API-Key", sandboxKey)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Equal(t, string(utils.CodeAPIKeyEnvironment), result["code"])
+
+	// A key that isn't a key at all is just invalid
+	req = httptest.NewRequest("GET", "/api/user/profile", nil)
+	req.Header.Set("X-API-Key", "not-a-key")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Equal(t, string(utils.CodeAPIKeyInvalid), result["code"])
+
+	// The reset wipes the database, so it never runs outside of sandbox mode
+	_, err = sandbox.Reset(context.Background(), db, false, "demo", time.Now())
+	assert.ErrorIs(t, err, sandbox.ErrNotSandbox)
+
+	// Everything runs in a transaction that is rolled back, the other tests keep their data
+	errRollback := errors.New("rollback")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		partner := models.User{Username: "sandbox_partner", Email: "partner@example.com", PasswordHash: "x"}
+		tx.Create(&partner)
+		_, prefix, hash, _ := utils.GenerateAPIKey(true)
+		tx.Create(&models.ApiKey{UserID: partner.ID, Name: "CI", Prefix: prefix, KeyHash: hash})
+		tx.Create(&models.Course{Title: "Partner scratch course", AuthorID: partner.ID})
+
+		// SANDBOX_MODE alone isn't enough, the database itself has to be marked
+		_, err := sandbox.Reset(context.Background(), tx, true, "demo", time.Now())
+		assert.ErrorIs(t, err, sandbox.ErrUnmarkedDatabase)
+		var scratch int64
+		tx.Model(&models.Course{}).Where("title = ?", "Partner scratch course").Count(&scratch)
+		assert.Equal(t, int64(1), scratch)
+
+		assert.NoError(t, sandbox.Mark(context.Background(), tx))
+		report, err := sandbox.Reset(context.Background(), tx, true, "demo", time.Now())
+		if !assert.NoError(t, err) {
+			return errRollback
+		}
+		assert.Equal(t, 2, report.Courses)
+		assert.Contains(t, report.Tables, "courses")
+		assert.NotContains(t, report.Tables, "api_keys")
+		marked, _ := sandbox.Marked(tx)
+		assert.True(t, marked)
+
+		// Accounts and keys survive, what was built with them doesn't
+		var keys int64
+		tx.Model(&models.ApiKey{}).Where("key_hash = ?", hash).Count(&keys)
+		assert.Equal(t, int64(1), keys)
+		tx.Model(&models.Course{}).Where("title = ?", "Partner scratch course").Count(&scratch)
+		assert.Zero(t, scratch)
+
+		var instructor models.User
+		tx.Where("username = ?", sandbox.DemoInstructor).First(&instructor)
+		ok, _ := utils.Passwords.Verify("demo", instructor.PasswordHash)
+		assert.True(t, ok)
+		var published int64
+		tx.Model(&models.Course{}).Where("author_id = ? AND status = ?", instructor.ID, models.CoursePublished).Count(&published)
+		assert.Equal(t, int64(2), published)
+
+		state, err := sandbox.Load(context.Background(), tx)
+		assert.NoError(t, err)
+		assert.NotNil(t, state.LastResetAt)
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"log"
+	"project/backend/jobs"
+	"project/backend/models"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRunsJobOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Three instances wake up together, only one of them runs the job for this interval
+	var runs atomic.Int32
+	for range 3 {
+		scheduler := jobs.NewScheduler(db, log.New(io.Discard, "", 0))
+		scheduler.Every("scheduler-test", time.Hour, func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+		scheduler.Start(ctx)
+	}
+	time.Sleep(500 * time.Millisecond)
+	assert.EqualValues(t, 1, runs.Load())
+
+	var job models.ScheduledJob
+	assert.NoError(t, db.First(&job, "name = ?", "scheduler-test").Error)
+	assert.NotNil(t, job.LastRunAt)
+
+	// An instance started later in the same interval doesn't run it either
+	scheduler := jobs.NewScheduler(db, log.New(io.Discard, "", 0))
+	scheduler.Every("scheduler-test", time.Hour, func(context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	scheduler.Start(ctx)
+	time.Sleep(200 * time.Millisecond)
+	assert.EqualValues(t, 1, runs.Load())
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"project/backend/utils"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaCompatibility(t *testing.T) {
+	// The expected version follows the newest migration file
+	entries, err := os.ReadDir("../backend/migrations")
+	assert.NoError(t, err)
+	latest := 0
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		if version, err := strconv.Atoi(prefix); err == nil && version > latest {
+			latest = version
+		}
+	}
+	assert.Equal(t, latest, utils.SchemaVersion)
+
+	cases := []struct {
+		state utils.SchemaState
+		mode  string
+		want  error
+	}{
+		{utils.SchemaState{Known: true, Version: 10}, utils.SchemaCheckCompat, nil},
+		{utils.SchemaState{Known: true, Version: 11}, utils.SchemaCheckCompat, nil},
+		{utils.SchemaState{Known: true, Version: 11}, utils.SchemaCheckStrict, utils.ErrSchemaAhead},
+		{utils.SchemaState{Known: true, Version: 9}, utils.SchemaCheckCompat, utils.ErrSchemaBehind},
+		{utils.SchemaState{Known: true, Version: 10, Dirty: true}, utils.SchemaCheckCompat, utils.ErrSchemaDirty},
+		{utils.SchemaState{}, utils.SchemaCheckCompat, utils.ErrSchemaUnknown},
+		{utils.SchemaState{Known: true, Version: 9}, utils.SchemaCheckOff, nil},
+	}
+	for _, tc := range cases {
+		err := utils.CheckSchemaCompatibility(tc.state, 10, tc.mode)
+		if tc.want == nil {
+			assert.NoError(t, err, "%+v %s", tc.state, tc.mode)
+		} else {
+			assert.True(t, errors.Is(err, tc.want), "%+v %s: %v", tc.state, tc.mode, err)
+		}
+	}
+}
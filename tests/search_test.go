@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchEnrolledContent(t *testing.T) {
+	enrolled := models.Course{Title: "Stoicism Basics", AuthorID: testUser.ID}
+	other := models.Course{Title: "Stoicism Advanced", AuthorID: testUser.ID}
+	db.Create(&enrolled)
+	db.Create(&other)
+	db.Create(&models.Lesson{CourseID: enrolled.ID, Title: "Virtue", Content: "Marcus Aurelius on stoicism"})
+	db.Create(&models.UserCourseProgress{UserID: testUser.ID, CourseID: enrolled.ID})
+
+	req := httptest.NewRequest("GET", "/api/user/search?q=stoicism", nil)
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Data struct {
+			Courses []struct {
+				ID uint `json:"id"`
+			} `json:"courses"`
+			Lessons []struct {
+				CourseID uint `json:"course_id"`
+			} `json:"lessons"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	// Courses the user isn't enrolled in never show up
+	assert.Len(t, result.Data.Courses, 1)
+	assert.Equal(t, enrolled.ID, result.Data.Courses[0].ID)
+	assert.Len(t, result.Data.Lessons, 1)
+	assert.Equal(t, enrolled.ID, result.Data.Lessons[0].CourseID)
+
+	// Wildcards in the query are plain characters, "%%" doesn't match everything
+	status, wildcard := sendJSON(t, "GET", "/api/user/search?q=%25%25", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Empty(t, wildcard["data"].(map[string]interface{})["courses"])
+	assert.Empty(t, wildcard["data"].(map[string]interface{})["lessons"])
+
+	shortReq := httptest.NewRequest("GET", "/api/user/search?q=s", nil)
+	shortReq.Header.Set("Authorization", jwtToken)
+	shortResp, err := app.Test(shortReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, shortResp.StatusCode)
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendSettings(t *testing.T, method string, payload interface{}) (int, map[string]interface{}) {
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(method, "/api/user/settings", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result.Data
+}
+
+func TestUserSettings(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/user/settings", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var defaults struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&defaults)
+	assert.Equal(t, "UTC", defaults.Data["timezone"])
+	assert.Equal(t, true, defaults.Data["email_comments"])
+
+	status, _ := sendSettings(t, "PUT", map[string]interface{}{"timezone": "Mars/Olympus_Mons", "theme": "neon"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, data := sendSettings(t, "PATCH", map[string]interface{}{
+		"locale":         "ru",
+		"timezone":       "Europe/Moscow",
+		"email_comments": false,
+	})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "ru", data["locale"])
+	assert.Equal(t, false, data["email_comments"])
+	assert.Equal(t, true, data["email_notifications"])
+
+	// Comments on the user's course no longer produce an email to them
+	course := models.Course{Title: "Stoicism", AuthorID: testUser.ID}
+	db.Create(&course)
+
+	commenter := models.User{Username: "settings_commenter", Email: "settings_commenter@example.com", PasswordHash: "hash"}
+	db.Where("username = ?", commenter.Username).FirstOrCreate(&commenter)
+	token, err := utils.GenerateJWTToken(&commenter, cfg)
+	assert.NoError(t, err)
+
+	countEmails := func() int64 {
+		var count int64
+		db.Model(&models.OutboxMessage{}).
+			Where("kind = ? AND payload LIKE ?", outbox.KindEmail, "%New comment on Stoicism%").Count(&count)
+		return count
+	}
+
+	comment, _ := json.Marshal(map[string]interface{}{"text": "Memento mori"})
+	commentReq := httptest.NewRequest("POST", fmt.Sprintf("/api/comments/course/%d", course.ID), bytes.NewBuffer(comment))
+	commentReq.Header.Set("Content-Type", "application/json")
+	commentReq.Header.Set("Authorization", token)
+	commentResp, err := app.Test(commentReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, commentResp.StatusCode)
+	assert.Equal(t, int64(0), countEmails())
+
+	status, _ = sendSettings(t, "PATCH", map[string]interface{}{"email_comments": true})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	commentReq = httptest.NewRequest("POST", fmt.Sprintf("/api/comments/course/%d", course.ID), bytes.NewBuffer(comment))
+	commentReq.Header.Set("Content-Type", "application/json")
+	commentReq.Header.Set("Authorization", token)
+	_, err = app.Test(commentReq)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), countEmails())
+
+	// Later tests expect UTC date buckets
+	sendSettings(t, "PATCH", map[string]interface{}{"locale": "en", "timezone": "UTC"})
+}
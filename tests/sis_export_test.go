@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"project/backend/models"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSISGradeExport(t *testing.T) {
+	course := models.Course{Title: "SIS Course", AuthorID: testUser.ID}
+	assert.NoError(t, db.Create(&course).Error)
+	student := models.User{Username: "sis_student", Email: "sis_student@example.com", PasswordHash: "hash", Group: "PHIL-1"}
+	assert.NoError(t, db.Create(&student).Error)
+	db.Create(&models.UserCourseProgress{UserID: student.ID, CourseID: course.ID, LessonsCompleted: 2, CompletionRate: 40})
+
+	send := func(method, path string, headers map[string]string, payload interface{}) (int, []byte) {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+	author := map[string]string{"Authorization": jwtToken}
+	readCSV := func(body []byte) [][]string {
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		assert.NoError(t, err)
+		return records
+	}
+	gradebookPath := fmt.Sprintf("/api/courses/%d/gradebook", course.ID)
+
+	status, body := send("GET", gradebookPath+"?format=moodle", author, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	records := readCSV(body)
+	assert.Equal(t, []string{"Email address", "Username", "ID number", "Course total", "Letter grade"}, records[0])
+	assert.Equal(t, "sis_student@example.com", records[1][0])
+
+	status, _ = send("GET", gradebookPath+"?format=blackboard", author, nil)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = send("GET", gradebookPath+"?format=custom", author, nil)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	mappingPath := fmt.Sprintf("/api/courses/%d/sis-mapping", course.ID)
+	status, _ = send("PUT", mappingPath, author, map[string]interface{}{"columns": []map[string]string{{"header": "Secret", "field": "password_hash"}}})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = send("PUT", mappingPath, author, map[string]interface{}{"columns": []map[string]string{
+		{"header": "Login", "field": "email"},
+		{"header": "Cohort", "field": "group"},
+		{"header": "Progress", "field": "completion_rate"},
+		{"header": "Comment", "field": ""},
+	}})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, body = send("GET", gradebookPath+"?format=custom", author, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, [][]string{{"Login", "Cohort", "Progress", "Comment"}, {"sis_student@example.com", "PHIL-1", "40.00", ""}}, readCSV(body))
+
+	// Nightly pulls only go through integration keys
+	pullPath := fmt.Sprintf("/api/integrations/courses/%d/grades", course.ID)
+	status, _ = send("GET", pullPath, author, nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, body = send("POST", "/api/user/api-keys", author, map[string]string{"name": "SIS sync"})
+	assert.Equal(t, fiber.StatusCreated, status)
+	var created map[string]interface{}
+	json.Unmarshal(body, &created)
+	integration := map[string]string{"X-API-Key": created["data"].(map[string]interface{})["key"].(string)}
+
+	status, body = send("GET", pullPath, integration, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	records = readCSV(body)
+	assert.Equal(t, "SIS Login ID", records[0][3])
+	assert.Len(t, records, 2)
+
+	// Nothing changed since the last run
+	since := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	status, body = send("GET", pullPath+"?format=moodle&since="+since, integration, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, readCSV(body), 1)
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseTeachingAssistant(t *testing.T) {
+	assistant := models.User{Username: "course_ta", Email: "course_ta@example.com", PasswordHash: "hash"}
+	db.Where("username = ?", assistant.Username).FirstOrCreate(&assistant)
+	token, err := utils.GenerateJWTToken(&assistant, cfg)
+	assert.NoError(t, err)
+
+	course := models.Course{Title: "Staffed Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "private"})
+	comment := models.CourseComment{CourseID: course.ID, UserID: testUser.ID, UserName: "testuser", Text: "When is the deadline?"}
+	db.Create(&comment)
+
+	send := func(method, path, auth string, payload interface{}) int {
+		status, _ := sendJSONAs(t, method, path, auth, payload)
+		return status
+	}
+	staffPath := fmt.Sprintf("/api/courses/%d/staff", course.ID)
+	replyPath := fmt.Sprintf("/api/comments/course/%d/%d/replies", course.ID, comment.ID)
+	settingsPath := fmt.Sprintf("/api/admin/courses/%d/settings", course.ID)
+
+	// Before the assignment the user is an outsider
+	assert.Equal(t, fiber.StatusForbidden, send("POST", replyPath, token, map[string]string{"text": "Friday"}))
+
+	assert.Equal(t, fiber.StatusUnprocessableEntity, send("POST", staffPath, jwtToken, map[string]interface{}{"user_id": assistant.ID, "role": "owner"}))
+	assert.Equal(t, fiber.StatusCreated, send("POST", staffPath, jwtToken, map[string]interface{}{"user_id": assistant.ID}))
+
+	// Assistants answer comments, view analytics and export the gradebook...
+	assert.Equal(t, fiber.StatusCreated, send("POST", replyPath, token, map[string]string{"text": "Friday"}))
+	assert.Equal(t, fiber.StatusOK, send("GET", fmt.Sprintf("/api/analytics/course/%d", course.ID), token, nil))
+	assert.Equal(t, fiber.StatusOK, send("GET", fmt.Sprintf("/api/courses/%d/gradebook", course.ID), token, nil))
+
+	// ...but can't change the course or its staff
+	assert.Equal(t, fiber.StatusForbidden, send("PUT", settingsPath, token, map[string]string{"access_level": "public"}))
+	assert.Equal(t, fiber.StatusForbidden, send("GET", staffPath, token, nil))
+
+	var replies int64
+	db.Model(&models.CourseCommentReply{}).Where("comment_id = ?", comment.ID).Count(&replies)
+	assert.Equal(t, int64(1), replies)
+
+	assert.Equal(t, fiber.StatusNoContent, send("DELETE", fmt.Sprintf("%s/%d", staffPath, assistant.ID), jwtToken, nil))
+	assert.Equal(t, fiber.StatusForbidden, send("POST", replyPath, token, map[string]string{"text": "Saturday"}))
+}
+
+func TestCourseCollaborators(t *testing.T) {
+	editor := models.User{Username: "course_editor", Email: "course_editor@example.com", PasswordHash: "hash"}
+	viewer := models.User{Username: "course_viewer", Email: "course_viewer@example.com", PasswordHash: "hash"}
+	db.Where("username = ?", editor.Username).FirstOrCreate(&editor)
+	db.Where("username = ?", viewer.Username).FirstOrCreate(&viewer)
+	editorToken, _ := utils.GenerateJWTToken(&editor, cfg)
+	viewerToken, _ := utils.GenerateJWTToken(&viewer, cfg)
+
+	course := models.Course{Title: "Co-authored Course", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "private"})
+
+	send := func(method, path, auth string, payload interface{}) int {
+		status, _ := sendJSONAs(t, method, path, auth, payload)
+		return status
+	}
+	staffPath := fmt.Sprintf("/api/courses/%d/staff", course.ID)
+	versionsPath := fmt.Sprintf("/api/courses/%d/versions", course.ID)
+
+	// Co-admins are no longer a comma-separated setting
+	assert.Equal(t, fiber.StatusUnprocessableEntity, send("PUT", fmt.Sprintf("/api/admin/courses/%d/settings", course.ID), jwtToken, map[string]string{"admins": fmt.Sprint(editor.ID)}))
+
+	// Editors manage the course together with its author, including who else works on it
+	assert.Equal(t, fiber.StatusForbidden, send("GET", staffPath, editorToken, nil))
+	assert.Equal(t, fiber.StatusCreated, send("POST", staffPath, jwtToken, map[string]interface{}{"user_id": editor.ID, "role": "editor"}))
+	assert.Equal(t, fiber.StatusOK, send("GET", staffPath, editorToken, nil))
+	assert.Equal(t, fiber.StatusCreated, send("POST", staffPath, editorToken, map[string]interface{}{"user_id": viewer.ID, "role": "viewer"}))
+
+	var member models.CourseStaff
+	db.Where("course_id = ? AND user_id = ?", course.ID, viewer.ID).First(&member)
+	assert.Equal(t, models.CourseRoleViewer, member.Role)
+	assert.Equal(t, editor.ID, member.AddedBy)
+
+	// Viewers see the course and its analytics but neither grade nor manage it
+	assert.Equal(t, fiber.StatusOK, send("GET", versionsPath, viewerToken, nil))
+	assert.Equal(t, fiber.StatusOK, send("GET", fmt.Sprintf("/api/analytics/course/%d", course.ID), viewerToken, nil))
+	assert.Equal(t, fiber.StatusForbidden, send("GET", fmt.Sprintf("/api/courses/%d/gradebook", course.ID), viewerToken, nil))
+	assert.Equal(t, fiber.StatusForbidden, send("GET", staffPath, viewerToken, nil))
+
+	// Changing the role replaces the previous one
+	assert.Equal(t, fiber.StatusCreated, send("POST", staffPath, jwtToken, map[string]interface{}{"user_id": viewer.ID, "role": "ta"}))
+	assert.Equal(t, fiber.StatusOK, send("GET", fmt.Sprintf("/api/courses/%d/gradebook", course.ID), viewerToken, nil))
+
+	assert.Equal(t, fiber.StatusNoContent, send("DELETE", fmt.Sprintf("%s/%d", staffPath, editor.ID), jwtToken, nil))
+	assert.Equal(t, fiber.StatusForbidden, send("GET", staffPath, editorToken, nil))
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/cache"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/storage"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicStatus(t *testing.T) {
+	previous := storage.Default
+	storage.Default = storage.NewDisk(t.TempDir(), "/uploads")
+	defer func() { storage.Default = previous }()
+
+	type component struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	}
+	type statusPage struct {
+		Status     string      `json:"status"`
+		Components []component `json:"components"`
+		Incidents  []struct {
+			ID         uint       `json:"id"`
+			ResolvedAt *time.Time `json:"resolved_at"`
+		} `json:"incidents"`
+	}
+	getStatus := func() statusPage {
+		cache.Status.Purge()
+		// No Authorization header: the status page is public
+		req := httptest.NewRequest("GET", "/api/public/status", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var result struct {
+			Data statusPage `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result.Data
+	}
+	componentStatus := func(page statusPage, name string) string {
+		for _, c := range page.Components {
+			if c.Name == name {
+				return c.Status
+			}
+		}
+		return ""
+	}
+	page := getStatus()
+	assert.Len(t, page.Components, 4)
+	assert.Equal(t, "operational", componentStatus(page, models.ComponentDatabase))
+	assert.Equal(t, "operational", componentStatus(page, models.ComponentStorage))
+
+	// Only admins publish incident notes
+	student := models.User{Username: "status_student", Email: "status.student@example.com", PasswordHash: "x", Active: true}
+	db.Create(&student)
+	studentToken, _ := utils.GenerateJWTToken(&student, cfg)
+	status, _ := sendJSONAs(t, "POST", "/api/admin/status/incidents", studentToken, map[string]interface{}{"title": "Nope"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, _ = sendJSONAs(t, "POST", "/api/admin/status/incidents", jwtToken, map[string]interface{}{
+		"title": "Uploads failing", "severity": "critical", "components": []string{"storage"},
+	})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, result := sendJSONAs(t, "POST", "/api/admin/status/incidents", jwtToken, map[string]interface{}{
+		"title": "Uploads failing", "message": "We're looking into it", "severity": models.IncidentMajor,
+		"components": []string{models.ComponentStorage},
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	incidentID := uint(result["data"].(map[string]interface{})["id"].(float64))
+
+	page = getStatus()
+	assert.Equal(t, "outage", page.Status)
+	assert.Equal(t, "outage", componentStatus(page, models.ComponentStorage))
+	if assert.NotEmpty(t, page.Incidents) {
+		assert.Equal(t, incidentID, page.Incidents[0].ID)
+	}
+
+	// A resolved incident stays on the page for a while but no longer affects the components
+	status, _ = sendJSONAs(t, "PATCH", fmt.Sprintf("/api/admin/status/incidents/%d", incidentID), jwtToken, map[string]interface{}{"resolved": true})
+	assert.Equal(t, fiber.StatusOK, status)
+	page = getStatus()
+	assert.Equal(t, "operational", componentStatus(page, models.ComponentStorage))
+	if assert.NotEmpty(t, page.Incidents) {
+		assert.NotNil(t, page.Incidents[0].ResolvedAt)
+	}
+
+	// An email stuck in the queue marks the queue as delayed
+	stuck := models.OutboxMessage{Kind: outbox.KindEmail, Payload: "{}", Status: outbox.StatusPending, NextAttemptAt: time.Now().Add(time.Hour)}
+	db.Create(&stuck)
+	db.Model(&stuck).UpdateColumn("created_at", time.Now().Add(-30*time.Minute))
+	defer db.Unscoped().Delete(&stuck)
+	assert.Equal(t, "degraded", componentStatus(getStatus(), models.ComponentEmailQueue))
+}
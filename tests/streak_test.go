@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimezoneStreaks(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+
+	// Twenty minutes across local midnight is the next day
+	lastActive := time.Date(2026, 3, 1, 23, 50, 0, 0, moscow)
+	progress := models.UserProgress{LastActive: lastActive, StreakDays: 4}
+	assert.Equal(t, 5, utils.NextStreak(progress, lastActive.Add(20*time.Minute), moscow))
+	// Logging in again the same day doesn't grow the streak
+	assert.Equal(t, 4, utils.NextStreak(progress, lastActive.Add(-10*time.Hour), moscow))
+	// A whole missed day breaks it, even though less than 48 hours have passed
+	assert.Equal(t, 1, utils.NextStreak(progress, lastActive.Add(24*time.Hour+20*time.Minute), moscow))
+	// The same moment is still "yesterday" in UTC
+	assert.Equal(t, 0, utils.CalendarDaysBetween(lastActive, lastActive.Add(20*time.Minute), time.UTC))
+
+	now := time.Now()
+	zones := map[string]string{"streak_east": "Pacific/Kiritimati", "streak_west": "Pacific/Pago_Pago"}
+	users := map[string]models.User{}
+	for name, zone := range zones {
+		user := models.User{Username: name, Email: name + "@example.com", PasswordHash: "hash"}
+		assert.NoError(t, db.Create(&user).Error)
+		settings := utils.DefaultUserSettings(user.ID)
+		settings.Timezone = zone
+		assert.NoError(t, db.Create(&settings).Error)
+		users[name] = user
+	}
+
+	// East was active late the day before yesterday, west early yesterday (both local)
+	east, _ := time.LoadLocation(zones["streak_east"])
+	west, _ := time.LoadLocation(zones["streak_west"])
+	db.Create(&models.UserProgress{
+		UserID:     users["streak_east"].ID,
+		LastActive: utils.LocalDayStart(now, east).AddDate(0, 0, -2).Add(23*time.Hour + 59*time.Minute),
+		StreakDays: 7,
+	})
+	db.Create(&models.UserProgress{
+		UserID:     users["streak_west"].ID,
+		LastActive: utils.LocalDayStart(now, west).AddDate(0, 0, -1).Add(time.Minute),
+		StreakDays: 7,
+	})
+
+	// Activity reports the broken streak before the background job runs
+	eastUser := users["streak_east"]
+	token, err := utils.GenerateJWTToken(&eastUser, cfg)
+	assert.NoError(t, err)
+	req := httptest.NewRequest("GET", "/api/user/activity", nil)
+	req.Header.Set("Authorization", token)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var activity struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&activity)
+	assert.Equal(t, float64(0), activity.Data["streak_days"])
+	assert.Equal(t, "Pacific/Kiritimati", activity.Data["timezone"])
+
+	assert.NoError(t, jobs.ResetStaleStreaks(db)(context.Background()))
+
+	var stored models.UserProgress
+	db.Where("user_id = ?", users["streak_east"].ID).First(&stored)
+	assert.Equal(t, 0, stored.StreakDays)
+	db.Where("user_id = ?", users["streak_west"].ID).First(&stored)
+	assert.Equal(t, 7, stored.StreakDays)
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/utils"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStudyCircles(t *testing.T) {
+	course := models.Course{Title: "Philosophy of Mind", AuthorID: testUser.ID}
+	assert.NoError(t, db.Create(&course).Error)
+	for i, title := range []string{"Dualism", "Functionalism", "Qualia", "Zombies"} {
+		db.Create(&models.Lesson{CourseID: course.ID, Title: title, SequenceOrder: i + 1})
+	}
+
+	tokens, users := map[string]string{}, map[string]models.User{}
+	for _, name := range []string{"circle_owner", "circle_sharer", "circle_private", "circle_outsider"} {
+		student := models.User{Username: name, Email: name + "@example.com", PasswordHash: "hash"}
+		assert.NoError(t, db.Create(&student).Error)
+		token, err := utils.GenerateJWTToken(&student, cfg)
+		assert.NoError(t, err)
+		tokens[name], users[name] = token, student
+		if name != "circle_outsider" {
+			db.Create(&models.UserCourseProgress{UserID: student.ID, CourseID: course.ID, LessonsCompleted: len(name) % 4, CompletionRate: float64(len(name)%4) * 25})
+		}
+	}
+
+	// Only enrolled students can start a circle
+	status, _ := sendJSONAs(t, "POST", fmt.Sprintf("/api/courses/%d/circles", course.ID), tokens["circle_outsider"], map[string]interface{}{"name": "Outsiders"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, result := sendJSONAs(t, "POST", fmt.Sprintf("/api/courses/%d/circles", course.ID), tokens["circle_owner"], map[string]interface{}{
+		"name": "Hard Problem", "max_members": 3, "share_progress": true,
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	circle := result["data"].(map[string]interface{})
+	circlePath := fmt.Sprintf("/api/circles/%d", int(circle["id"].(float64)))
+	code := circle["join_code"].(string)
+	assert.Len(t, code, 8)
+	assert.Equal(t, models.CircleOwner, circle["role"])
+
+	// Joining by code, which is not case sensitive; one circle per course
+	status, _ = sendJSONAs(t, "POST", "/api/circles/join", tokens["circle_sharer"], map[string]interface{}{"code": "nope"})
+	assert.Equal(t, fiber.StatusNotFound, status)
+	status, result = sendJSONAs(t, "POST", "/api/circles/join", tokens["circle_sharer"], map[string]interface{}{"code": code, "share_progress": true})
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, float64(2), result["data"].(map[string]interface{})["member_count"])
+	status, _ = sendJSONAs(t, "POST", "/api/circles/join", tokens["circle_sharer"], map[string]interface{}{"code": code})
+	assert.Equal(t, fiber.StatusConflict, status)
+	status, _ = sendJSONAs(t, "POST", "/api/circles/join", tokens["circle_private"], map[string]interface{}{"code": " " + strings.ToLower(code)})
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// The database keeps one circle per student and course, whichever way the second membership comes in
+	status, _ = sendJSONAs(t, "POST", fmt.Sprintf("/api/courses/%d/circles", course.ID), tokens["circle_sharer"], map[string]interface{}{"name": "Second Circle"})
+	assert.Equal(t, fiber.StatusConflict, status)
+	var circles int64
+	db.Model(&models.StudyCircle{}).Where("course_id = ?", course.ID).Count(&circles)
+	assert.Equal(t, int64(1), circles)
+	other := models.StudyCircle{CourseID: course.ID, Name: "Elsewhere", JoinCode: "ELSE2345"}
+	assert.NoError(t, db.Create(&other).Error)
+	assert.Error(t, db.Create(&models.StudyCircleMember{CircleID: other.ID, UserID: users["circle_sharer"].ID, CourseID: course.ID}).Error)
+	db.Unscoped().Delete(&other)
+
+	// Circles are visible to their members only
+	status, _ = sendJSONAs(t, "GET", circlePath, tokens["circle_outsider"], nil)
+	assert.Equal(t, fiber.StatusNotFound, status)
+	status, result = sendJSONAs(t, "GET", circlePath, tokens["circle_private"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, result["data"].(map[string]interface{})["members"], 3)
+
+	// The board shows numbers only for members who share them, and everyone's own numbers to themselves
+	board := func(name string) map[string]map[string]interface{} {
+		status, result := sendJSONAs(t, "GET", circlePath+"/board", tokens[name], nil)
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, float64(4), result["meta"].(map[string]interface{})["lessons_total"])
+		rows := map[string]map[string]interface{}{}
+		for _, row := range result["data"].([]interface{}) {
+			rows[row.(map[string]interface{})["username"].(string)] = row.(map[string]interface{})
+		}
+		return rows
+	}
+	rows := board("circle_sharer")
+	assert.Contains(t, rows["circle_owner"], "completion_rate")
+	assert.Contains(t, rows["circle_sharer"], "completion_rate")
+	assert.NotContains(t, rows["circle_private"], "completion_rate")
+	assert.Contains(t, board("circle_private")["circle_private"], "completion_rate")
+
+	// Sharing can be switched off at any time
+	status, _ = sendJSONAs(t, "PATCH", circlePath+"/membership", tokens["circle_sharer"], map[string]interface{}{"share_progress": false})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NotContains(t, board("circle_owner")["circle_sharer"], "completion_rate")
+
+	// Only the owner manages the circle
+	status, _ = sendJSONAs(t, "PATCH", circlePath, tokens["circle_sharer"], map[string]interface{}{"name": "Taken over"})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = sendJSONAs(t, "PATCH", circlePath, tokens["circle_owner"], map[string]interface{}{"max_members": 2})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, result = sendJSONAs(t, "POST", circlePath+"/code", tokens["circle_owner"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NotEqual(t, code, result["data"].(map[string]interface{})["join_code"])
+	status, _ = sendJSONAs(t, "DELETE", fmt.Sprintf("%s/members/%d", circlePath, users["circle_owner"].ID), tokens["circle_sharer"], nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = sendJSONAs(t, "DELETE", fmt.Sprintf("%s/members/%d", circlePath, users["circle_private"].ID), tokens["circle_owner"], nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+
+	// When the owner leaves, the longest-standing member takes over
+	status, _ = sendJSONAs(t, "DELETE", fmt.Sprintf("%s/members/%d", circlePath, users["circle_owner"].ID), tokens["circle_owner"], nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	status, result = sendJSONAs(t, "GET", "/api/user/circles", tokens["circle_sharer"], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	if mine := result["data"].([]interface{}); assert.Len(t, mine, 1) {
+		assert.Equal(t, models.CircleOwner, mine[0].(map[string]interface{})["role"])
+	}
+}
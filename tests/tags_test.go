@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogTags(t *testing.T) {
+	send := func(method, path string, payload interface{}) (int, []byte) {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+	createTag := func(name string) uint {
+		status, created := postJSON(t, "/api/admin/tags", map[string]string{"name": name})
+		assert.Equal(t, fiber.StatusCreated, status)
+		return uint(created["data"].(map[string]interface{})["id"].(float64))
+	}
+
+	stoicism := createTag("Stoicism Tagged")
+	examPrep := createTag("Exam Prep Tagged")
+	status, _ := postJSON(t, "/api/admin/tags", map[string]string{"name": "Stoicism tagged"})
+	assert.Equal(t, fiber.StatusConflict, status)
+	// Slugs end up in catalog URLs: a short lowercase word list only
+	for _, slug := range []string{"Has Spaces", "double--dash", "-edge", "a/b", strings.Repeat("x", 65)} {
+		status, _ = postJSON(t, "/api/admin/tags", map[string]string{"name": "Bad Slug", "slug": slug})
+		assert.Equal(t, fiber.StatusUnprocessableEntity, status, slug)
+	}
+	status, _ = send("PATCH", fmt.Sprintf("/api/admin/tags/%d", stoicism), map[string]string{"slug": "Stoicism!"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	topic := createTopic(t, "Hellenistic Tagged", nil)
+	seneca := models.Course{Title: "Seneca's Letters", AuthorID: testUser.ID, Status: models.CoursePublished, TopicID: &topic}
+	epictetus := models.Course{Title: "Epictetus' Handbook", AuthorID: testUser.ID, Status: models.CoursePublished}
+	for _, course := range []*models.Course{&seneca, &epictetus} {
+		db.Create(course)
+		db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: models.AccessPublic})
+	}
+
+	status, _ = send("PUT", fmt.Sprintf("/api/admin/courses/%d/tags", seneca.ID), map[string]interface{}{"tag_ids": []uint{stoicism, examPrep, 999999}})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _ = send("PUT", fmt.Sprintf("/api/admin/courses/%d/tags", seneca.ID), map[string]interface{}{"tag_ids": []uint{stoicism, examPrep, stoicism}})
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = send("PUT", fmt.Sprintf("/api/admin/courses/%d/tags", epictetus.ID), map[string]interface{}{"tag_ids": []uint{stoicism}})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Every listed tag has to match
+	status, body := send("GET", "/api/courses/available?tags=stoicism-tagged,exam-prep-tagged", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	var available []map[string]interface{}
+	json.Unmarshal(body, &available)
+	assert.Len(t, available, 1)
+	assert.Equal(t, float64(seneca.ID), available[0]["id"])
+	assert.Len(t, available[0]["tags"], 2)
+
+	// Facets count the courses found under the current filters
+	status, body = send("GET", "/api/overview/courses?tags=stoicism-tagged&facets=true", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	var search struct {
+		Data struct {
+			Courses []map[string]interface{} `json:"courses"`
+			Facets  struct {
+				Tags []struct {
+					Slug  string `json:"slug"`
+					Count int64  `json:"count"`
+				} `json:"tags"`
+				Topics []struct {
+					ID    uint  `json:"id"`
+					Count int64 `json:"count"`
+				} `json:"topics"`
+				Total int `json:"total"`
+			} `json:"facets"`
+		} `json:"data"`
+	}
+	json.Unmarshal(body, &search)
+	assert.Len(t, search.Data.Courses, 2)
+	assert.Equal(t, 2, search.Data.Facets.Total)
+	counts := map[string]int64{}
+	for _, tag := range search.Data.Facets.Tags {
+		counts[tag.Slug] = tag.Count
+	}
+	assert.Equal(t, map[string]int64{"stoicism-tagged": 2, "exam-prep-tagged": 1}, counts)
+	assert.Len(t, search.Data.Facets.Topics, 1)
+	assert.Equal(t, topic, search.Data.Facets.Topics[0].ID)
+
+	// Tests take tags too
+	test := models.Test{Title: "Stoic Ethics Quiz", AuthorID: testUser.ID}
+	db.Create(&test)
+	status, _ = send("PUT", fmt.Sprintf("/api/admin/tests/%d/tags", test.ID), map[string]interface{}{"tag_ids": []uint{examPrep}})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Deleting a tag takes it off everything
+	status, _ = send("DELETE", fmt.Sprintf("/api/admin/tags/%d", examPrep), nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	status, body = send("GET", fmt.Sprintf("/api/courses/%d", seneca.ID), nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	var details struct {
+		Course struct {
+			Tags []map[string]interface{} `json:"tags"`
+		} `json:"course"`
+	}
+	json.Unmarshal(body, &details)
+	assert.Len(t, details.Course.Tags, 1)
+	var left int64
+	db.Model(&models.TestTag{}).Where("test_id = ?", test.ID).Count(&left)
+	assert.Zero(t, left)
+}
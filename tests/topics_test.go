@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTopic(t *testing.T, name string, parentID *uint) uint {
+	body, _ := json.Marshal(map[string]interface{}{"name": name, "parent_id": parentID})
+	req := httptest.NewRequest("POST", "/api/admin/topics", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", jwtToken)
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+	var result struct {
+		Data models.Topic `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result.Data.ID
+}
+
+func TestTopicTaxonomy(t *testing.T) {
+	philosophy := createTopic(t, "Philosophy", nil)
+	ethics := createTopic(t, "Ethics", &philosophy)
+	metaethics := createTopic(t, "Metaethics", &ethics)
+
+	course := models.Course{Title: "Moral Realism", AuthorID: testUser.ID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "public"})
+
+	body, _ := json.Marshal(map[string]interface{}{"topic_id": metaethics})
+	patchReq := httptest.NewRequest("PATCH", fmt.Sprintf("/api/admin/courses/%d/description", course.ID), bytes.NewBuffer(body))
+	patchReq.Header.Set("Content-Type", "application/json")
+	patchReq.Header.Set("Authorization", jwtToken)
+	patchResp, err := app.Test(patchReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, patchResp.StatusCode)
+
+	// Course payload carries the breadcrumb trail from the root
+	detailsReq := httptest.NewRequest("GET", fmt.Sprintf("/api/courses/%d", course.ID), nil)
+	detailsReq.Header.Set("Authorization", jwtToken)
+	detailsResp, err := app.Test(detailsReq)
+	assert.NoError(t, err)
+
+	var details struct {
+		Course struct {
+			Topic       string `json:"topic"`
+			Breadcrumbs []struct {
+				Name string `json:"name"`
+			} `json:"breadcrumbs"`
+		} `json:"course"`
+	}
+	json.NewDecoder(detailsResp.Body).Decode(&details)
+	assert.Equal(t, "Metaethics", details.Course.Topic)
+	if assert.Len(t, details.Course.Breadcrumbs, 3) {
+		assert.Equal(t, "Philosophy", details.Course.Breadcrumbs[0].Name)
+		assert.Equal(t, "Metaethics", details.Course.Breadcrumbs[2].Name)
+	}
+
+	// Browsing the root topic includes courses from the whole subtree
+	topicReq := httptest.NewRequest("GET", fmt.Sprintf("/api/topics/%d", philosophy), nil)
+	topicReq.Header.Set("Authorization", jwtToken)
+	topicResp, err := app.Test(topicReq)
+	assert.NoError(t, err)
+
+	var topic struct {
+		Data struct {
+			Courses []map[string]interface{} `json:"courses"`
+		} `json:"data"`
+	}
+	json.NewDecoder(topicResp.Body).Decode(&topic)
+	assert.Len(t, topic.Data.Courses, 1)
+
+	// A topic can't be moved under its own descendant
+	moveBody, _ := json.Marshal(map[string]interface{}{"parent_id": metaethics})
+	moveReq := httptest.NewRequest("PATCH", fmt.Sprintf("/api/admin/topics/%d", ethics), bytes.NewBuffer(moveBody))
+	moveReq.Header.Set("Content-Type", "application/json")
+	moveReq.Header.Set("Authorization", jwtToken)
+	moveResp, err := app.Test(moveReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, moveResp.StatusCode)
+
+	// Moving Ethics to the root rewrites the path of the whole subtree
+	moveBody, _ = json.Marshal(map[string]interface{}{"parent_id": nil})
+	moveReq = httptest.NewRequest("PATCH", fmt.Sprintf("/api/admin/topics/%d", ethics), bytes.NewBuffer(moveBody))
+	moveReq.Header.Set("Content-Type", "application/json")
+	moveReq.Header.Set("Authorization", jwtToken)
+	moveResp, err = app.Test(moveReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, moveResp.StatusCode)
+
+	var moved models.Topic
+	db.First(&moved, metaethics)
+	assert.Equal(t, fmt.Sprintf("/%d/%d/", ethics, metaethics), moved.Path)
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/llm"
+	"project/backend/models"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuestionTranslations(t *testing.T) {
+	previous := llm.Default
+	defer func() { llm.Default = previous }()
+
+	servedQuestion := func(testID uint) map[string]interface{} {
+		_, details := sendJSON(t, "GET", fmt.Sprintf("/api/tests/%d?locale=ru", testID), nil)
+		return details["test"].(map[string]interface{})["questions"].([]interface{})[0].(map[string]interface{})
+	}
+
+	test := models.Test{Title: "Translated Quiz", AuthorID: testUser.ID}
+	db.Create(&test)
+	question := models.TestQuestion{TestID: test.ID, Title: "Q1", Question: "Who wrote the Republic?",
+		Options: `["Plato","Aristotle"]`, CorrectAnswer: 0}
+	db.Create(&question)
+	translationsPath := fmt.Sprintf("/api/admin/tests/%d/translations", test.ID)
+
+	llm.Default = llm.Disabled{}
+	status, _ := sendJSON(t, "POST", translationsPath, map[string]interface{}{"locale": "ru"})
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+
+	fake := &fakeLLM{reply: `{"questions":[{"title":"В1","description":"","question":"Кто написал «Государство»?","options":["Платон","Аристотель"]}]}`}
+	llm.Default = fake
+	status, _ = sendJSON(t, "POST", translationsPath, map[string]interface{}{"locale": "de"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, result := sendJSON(t, "POST", translationsPath, map[string]interface{}{"locale": "ru"})
+	assert.Equal(t, fiber.StatusOK, status)
+	drafts := result["data"].(map[string]interface{})["translated"].([]interface{})
+	assert.Len(t, drafts, 1)
+	draft := drafts[0].(map[string]interface{})
+	assert.Equal(t, models.TranslationNeedsReview, draft["status"])
+	assert.Contains(t, fake.prompt, "Russian")
+	translationPath := fmt.Sprintf("%s/%d", translationsPath, uint(draft["id"].(float64)))
+
+	// Drafts aren't served until approved
+	assert.Equal(t, "Who wrote the Republic?", servedQuestion(test.ID)["question"])
+
+	// Running it again leaves the pending draft alone
+	status, result = sendJSON(t, "POST", translationsPath, map[string]interface{}{"locale": "ru"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, float64(1), result["data"].(map[string]interface{})["skipped"])
+
+	status, _ = sendJSON(t, "PATCH", translationPath, map[string]interface{}{"options": []string{"Платон"}})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, result = sendJSON(t, "PATCH", translationPath, map[string]interface{}{"question": "Кто автор «Государства»?"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, models.TranslationAuthor, result["data"].(map[string]interface{})["source"])
+
+	status, result = sendJSON(t, "POST", translationPath+"/approve", nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, models.TranslationApproved, result["data"].(map[string]interface{})["status"])
+
+	served := servedQuestion(test.ID)
+	assert.Equal(t, "Кто автор «Государства»?", served["question"])
+	assert.Equal(t, []interface{}{"Платон", "Аристотель"}, served["options"])
+
+	// Attempts remember the variant; analytics keep one test and split it by language
+	_, details := sendJSON(t, "GET", fmt.Sprintf("/api/tests/%d?locale=ru", test.ID), nil)
+	assert.Equal(t, "ru", details["test"].(map[string]interface{})["locale"])
+	assert.Equal(t, []interface{}{"ru"}, details["test"].(map[string]interface{})["locales"])
+	answers := []map[string]interface{}{{"question_id": question.ID, "answer": 0}}
+	status, _ = sendJSON(t, "POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), map[string]interface{}{"answers": answers, "locale": "ru"})
+	assert.Equal(t, fiber.StatusOK, status)
+	var attempt models.UserTestProgress
+	db.Where("test_id = ? AND user_id = ?", test.ID, testUser.ID).First(&attempt)
+	assert.Equal(t, "ru", attempt.Locale)
+
+	reader := models.User{Username: "original_reader", Email: "original_reader@example.com", PasswordHash: "hash"}
+	db.Create(&reader)
+	readerToken, _ := utils.GenerateJWTToken(&reader, cfg)
+	body, _ := json.Marshal(map[string]interface{}{"answers": answers})
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/tests/%d/progress", test.ID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", readerToken)
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	_, analytics := sendJSON(t, "GET", fmt.Sprintf("/api/analytics/test/%d", test.ID), nil)
+	data := analytics["data"].(map[string]interface{})
+	assert.Equal(t, float64(2), data["metrics"].(map[string]interface{})["TotalAttempts"])
+	byLocale := map[string]float64{}
+	for _, item := range data["locale_stats"].([]interface{}) {
+		stat := item.(map[string]interface{})
+		byLocale[stat["locale"].(string)] = stat["attempts"].(float64)
+	}
+	assert.Equal(t, map[string]float64{"ru": 1, "original": 1}, byLocale)
+
+	// Editing the original takes the translation out until it is reviewed again
+	status, _ = sendJSON(t, "PATCH", fmt.Sprintf("/api/admin/tests/%d/questions/%d", test.ID, question.ID), map[string]interface{}{"question": "Who wrote The Republic?"})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, "Who wrote The Republic?", servedQuestion(test.ID)["question"])
+	_, result = sendJSON(t, "GET", translationsPath+"?locale=ru", nil)
+	listed := result["data"].(map[string]interface{})["translations"].([]interface{})
+	assert.Equal(t, true, listed[0].(map[string]interface{})["stale"])
+
+	// A reply that loses options is rejected
+	fake.reply = `{"questions":[{"question":"Кто написал «Государство»?","options":["Платон"]}]}`
+	status, _ = sendJSON(t, "POST", translationsPath, map[string]interface{}{"locale": "ru"})
+	assert.Equal(t, fiber.StatusBadGateway, status)
+
+	status, _ = sendJSON(t, "DELETE", translationPath, nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	var remaining int64
+	db.Unscoped().Model(&models.TestQuestionTranslation{}).Where("test_id = ?", test.ID).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+
+	// A whole test is translated with one call per batch, not one per question
+	batched := models.Test{Title: "Batched Quiz", AuthorID: testUser.ID}
+	db.Create(&batched)
+	reply := make([]map[string]interface{}, 3)
+	for i := range reply {
+		db.Create(&models.TestQuestion{TestID: batched.ID, Title: fmt.Sprintf("Q%d", i+1), Question: "Who wrote the Republic?",
+			Options: `["Plato","Aristotle"]`, SequenceOrder: i + 1})
+		reply[i] = map[string]interface{}{"question": fmt.Sprintf("Вопрос %d", i+1), "options": []string{"Платон", "Аристотель"}}
+	}
+	encoded, _ := json.Marshal(map[string]interface{}{"questions": reply})
+	fake.reply, fake.calls = string(encoded), 0
+	status, result = sendJSON(t, "POST", fmt.Sprintf("/api/admin/tests/%d/translations", batched.ID), map[string]interface{}{"locale": "ru"})
+	assert.Equal(t, fiber.StatusOK, status)
+	drafts = result["data"].(map[string]interface{})["translated"].([]interface{})
+	if assert.Len(t, drafts, 3) {
+		assert.Equal(t, "Вопрос 3", drafts[2].(map[string]interface{})["question"])
+	}
+	assert.Equal(t, 1, fake.calls)
+}
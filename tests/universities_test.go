@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"project/backend/models"
+	"project/backend/outbox"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniversityAffiliation(t *testing.T) {
+	status, created := postJSON(t, "/api/admin/universities", map[string]interface{}{
+		"name":          "Saint Petersburg State University",
+		"short_name":    "SPbU",
+		"email_domains": []string{"@spbu.ru"},
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+	university := created["data"].(map[string]interface{})
+	universityID := uint(university["id"].(float64))
+	assert.Equal(t, "saint-petersburg-state-university", university["slug"])
+
+	// Free text outside the directory is rejected
+	status, _ = postJSON(t, "/api/admin/courses", map[string]interface{}{"title": "Logic", "university": "Nowhere U"})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	// An email outside the university domains can't be used
+	status, _ = postJSON(t, "/api/user/affiliation", map[string]interface{}{
+		"university_id": universityID,
+		"email":         "student@gmail.com",
+	})
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+
+	status, _ = postJSON(t, "/api/user/affiliation", map[string]interface{}{
+		"university_id": universityID,
+		"email":         "st012345@student.spbu.ru",
+	})
+	assert.Equal(t, fiber.StatusAccepted, status)
+
+	// The code is only delivered by email
+	var message models.OutboxMessage
+	db.Where("kind = ? AND payload LIKE ?", outbox.KindEmail, "%st012345@student.spbu.ru%").Last(&message)
+	var email outbox.EmailMessage
+	json.Unmarshal([]byte(message.Payload), &email)
+	token := email.Body[strings.LastIndex(email.Body, " ")+1:]
+
+	status, _ = postJSON(t, "/api/user/affiliation/verify", map[string]string{"token": "wrong"})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, _ = postJSON(t, "/api/user/affiliation/verify", map[string]string{"token": token})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var user models.User
+	db.First(&user, testUser.ID)
+	assert.Equal(t, "Saint Petersburg State University", user.University)
+	assert.NotNil(t, user.UniversityVerifiedAt)
+
+	// Public content of the university shows up on its landing page
+	course := models.Course{Title: "Russian Philosophy", AuthorID: testUser.ID, UniversityID: &universityID}
+	db.Create(&course)
+	db.Create(&models.CourseAccessSettings{CourseID: course.ID, AccessLevel: "public"})
+
+	req := httptest.NewRequest("GET", "/api/universities/saint-petersburg-state-university", nil)
+	req.Header.Set("Authorization", jwtToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var page struct {
+		Data struct {
+			VerifiedMembers int `json:"verified_members"`
+			Courses         []struct {
+				Title string `json:"title"`
+			} `json:"courses"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&page)
+	assert.Equal(t, 1, page.Data.VerifiedMembers)
+	assert.Len(t, page.Data.Courses, 1)
+	assert.Equal(t, "Russian Philosophy", page.Data.Courses[0].Title)
+
+	// Profiles carry the verified badge
+	profileReq := httptest.NewRequest("GET", fmt.Sprintf("/api/users/%d/profile", testUser.ID), nil)
+	profileReq.Header.Set("Authorization", jwtToken)
+	profileResp, err := app.Test(profileReq)
+	assert.NoError(t, err)
+
+	var profile struct {
+		Data struct {
+			UniversityVerified bool `json:"university_verified"`
+		} `json:"data"`
+	}
+	json.NewDecoder(profileResp.Body).Decode(&profile)
+	assert.True(t, profile.Data.UniversityVerified)
+
+	// Renaming the university carries the scoped roles over to the new name
+	var role models.Role
+	db.Where("name = ?", "professor").First(&role)
+	scoped := models.UserRole{UserID: testUser.ID, RoleID: role.ID, University: "Saint Petersburg State University"}
+	db.Create(&scoped)
+	body, _ := json.Marshal(map[string]string{"name": "St Petersburg University"})
+	renameReq := httptest.NewRequest("PATCH", fmt.Sprintf("/api/admin/universities/%d", universityID), bytes.NewBuffer(body))
+	renameReq.Header.Set("Content-Type", "application/json")
+	renameReq.Header.Set("Authorization", jwtToken)
+	renameResp, err := app.Test(renameReq)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, renameResp.StatusCode)
+	db.First(&scoped, scoped.ID)
+	assert.Equal(t, "St Petersburg University", scoped.University)
+}
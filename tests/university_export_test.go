@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/storage"
+	"project/backend/utils"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportUniversity(t *testing.T) {
+	university := models.University{Name: "Offboarding University", Slug: "offboarding-university"}
+	db.Create(&university)
+	db.Create(&models.Course{Title: "Leaving Course", AuthorID: testUser.ID, UniversityID: &university.ID, University: university.Name})
+	db.Create(&models.Test{Title: "Leaving Test", AuthorID: testUser.ID, UniversityID: &university.ID, University: university.Name})
+	member := models.User{Username: "offboarded", Email: "offboarded@example.com", PasswordHash: "x", UniversityID: &university.ID, University: university.Name}
+	db.Create(&member)
+
+	get := func(path, token string) (int, []byte) {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+
+	previous := storage.Archive
+	storage.Archive = storage.NewDisk(t.TempDir(), "")
+	defer func() { storage.Archive = previous }()
+
+	// A member of the university can't export it
+	memberToken, _ := utils.GenerateJWTToken(&member, cfg)
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/admin/universities/%d/export", university.ID), nil)
+	req.Header.Set("Authorization", memberToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+
+	status, created := postJSON(t, fmt.Sprintf("/api/admin/universities/%d/export", university.ID), nil)
+	assert.Equal(t, fiber.StatusAccepted, status)
+	statusURL := created["data"].(map[string]interface{})["status_url"].(string)
+
+	// One archive at a time until the worker has built it
+	status, _ = postJSON(t, fmt.Sprintf("/api/admin/universities/%d/export", university.ID), nil)
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.NoError(t, jobs.RunExports(db)(context.Background()))
+
+	_, body := get(statusURL, jwtToken)
+	var job struct {
+		Data struct {
+			Status      string `json:"status"`
+			DownloadURL string `json:"download_url"`
+		} `json:"data"`
+	}
+	json.Unmarshal(body, &job)
+	assert.Equal(t, models.ExportStatusDone, job.Data.Status)
+	downloadURL := job.Data.DownloadURL
+	if !assert.NotEmpty(t, downloadURL) {
+		return
+	}
+
+	// The signed link works without a token, a tampered one doesn't
+	status, archive := get(downloadURL, "")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = get(strings.Replace(downloadURL, "signature=", "signature=0", 1), "")
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	assert.Contains(t, files, "courses.json")
+	assert.Contains(t, files, "tests.json")
+	assert.Contains(t, files, "course_analytics.json")
+
+	membersFile, err := files["members.json"].Open()
+	if assert.NoError(t, err) {
+		var members []map[string]interface{}
+		json.NewDecoder(membersFile).Decode(&members)
+		if assert.Len(t, members, 1) {
+			assert.Equal(t, "offboarded", members[0]["username"])
+			assert.NotContains(t, members[0], "email")
+		}
+	}
+}
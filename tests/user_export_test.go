@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"project/backend/jobs"
+	"project/backend/models"
+	"project/backend/storage"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportUserData(t *testing.T) {
+	request := func() (int, []byte) {
+		req := httptest.NewRequest("GET", "/api/user/export", nil)
+		req.Header.Set("Authorization", jwtToken)
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+
+	previous := storage.Archive
+	storage.Archive = storage.NewDisk(t.TempDir(), "")
+	defer func() { storage.Archive = previous }()
+
+	// The first request only schedules the archive, a worker on any instance builds it
+	status, _ := request()
+	assert.Equal(t, fiber.StatusAccepted, status)
+	status, _ = request()
+	assert.Equal(t, fiber.StatusAccepted, status)
+	assert.NoError(t, jobs.RunExports(db)(context.Background()))
+
+	status, archive := request()
+	if !assert.Equal(t, fiber.StatusOK, status) {
+		return
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	assert.NoError(t, err)
+
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	assert.Contains(t, files, "login_history.json")
+	assert.Contains(t, files, "test_results.json")
+
+	profileFile, err := files["profile.json"].Open()
+	if assert.NoError(t, err) {
+		var profile map[string]interface{}
+		json.NewDecoder(profileFile).Decode(&profile)
+		assert.Equal(t, "testuser", profile["username"])
+		assert.NotContains(t, profile, "password_hash")
+		assert.NotContains(t, profile, "PasswordHash")
+	}
+}
+
+func TestExportJobReaper(t *testing.T) {
+	previous := storage.Archive
+	storage.Archive = storage.NewDisk(t.TempDir(), "")
+	defer func() { storage.Archive = previous }()
+
+	// A job whose instance died mid-run is failed instead of blocking new exports forever
+	started := time.Now().Add(-time.Hour)
+	abandoned := models.ExportJob{UserID: testUser.ID, Kind: models.ExportKindUserData, EntityID: testUser.ID,
+		Status: models.ExportStatusRunning, StartedAt: &started}
+	assert.NoError(t, db.Create(&abandoned).Error)
+
+	// A finished file past its retention is deleted
+	key := "exports/reaper-test.zip"
+	_, err := storage.Archive.Put(context.Background(), key, []byte("zip"), "application/zip")
+	assert.NoError(t, err)
+	expired := time.Now().Add(-time.Minute)
+	done := models.ExportJob{UserID: testUser.ID, Kind: models.ExportKindUserData, EntityID: testUser.ID,
+		Status: models.ExportStatusDone, FileKey: key, FinishedAt: &started, ExpiresAt: &expired}
+	assert.NoError(t, db.Create(&done).Error)
+
+	assert.NoError(t, jobs.ReapExports(db)(context.Background()))
+
+	db.First(&abandoned, abandoned.ID)
+	assert.Equal(t, models.ExportStatusFailed, abandoned.Status)
+	db.First(&done, done.ID)
+	assert.Equal(t, models.ExportStatusExpired, done.Status)
+	assert.Empty(t, done.FileKey)
+	_, err = storage.Archive.Get(context.Background(), key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
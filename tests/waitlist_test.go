@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"fmt"
+	"project/backend/models"
+	"project/backend/outbox"
+	"project/backend/utils"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseWaitlist(t *testing.T) {
+	course := models.Course{
+		Title:          "Seminar With Seats",
+		Status:         models.CoursePublished,
+		AuthorID:       testUser.ID,
+		Lessons:        []models.Lesson{{Title: "Intro", SequenceOrder: 1}},
+		AccessSettings: models.CourseAccessSettings{AccessLevel: "public", MaxEnrollments: 1},
+	}
+	assert.NoError(t, db.Create(&course).Error)
+
+	var tokens []string
+	var students []models.User
+	for i := range 3 {
+		student := models.User{
+			Username:     fmt.Sprintf("waitlist_student_%d", i),
+			Email:        fmt.Sprintf("waitlist_student_%d@example.com", i),
+			PasswordHash: "hash",
+		}
+		assert.NoError(t, db.Create(&student).Error)
+		token, err := utils.GenerateJWTToken(&student, cfg)
+		assert.NoError(t, err)
+		students = append(students, student)
+		tokens = append(tokens, token)
+	}
+
+	enrollPath := fmt.Sprintf("/api/courses/%d/enroll", course.ID)
+	waitlistPath := fmt.Sprintf("/api/courses/%d/waitlist", course.ID)
+	enrolled := func(userID uint) bool {
+		var count int64
+		db.Model(&models.UserCourseProgress{}).Where("user_id = ? AND course_id = ?", userID, course.ID).Count(&count)
+		return count > 0
+	}
+
+	// The only seat goes to the first student, the rest queue up in order
+	status, _ := sendJSONAs(t, "POST", enrollPath, tokens[0], nil)
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, result := sendJSONAs(t, "POST", enrollPath, tokens[1], nil)
+	assert.Equal(t, fiber.StatusAccepted, status)
+	assert.Equal(t, true, result["data"].(map[string]interface{})["waitlisted"])
+	assert.Equal(t, float64(1), result["data"].(map[string]interface{})["position"])
+	status, result = sendJSONAs(t, "POST", enrollPath, tokens[2], nil)
+	assert.Equal(t, fiber.StatusAccepted, status)
+	assert.Equal(t, float64(2), result["data"].(map[string]interface{})["position"])
+
+	// Opening a lesson doesn't get around the queue
+	status, result = sendJSONAs(t, "POST", fmt.Sprintf("/api/courses/%d/progress", course.ID), tokens[2], map[string]interface{}{"lesson_id": course.Lessons[0].ID})
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, true, result["course_full"])
+	assert.False(t, enrolled(students[2].ID))
+
+	status, _ = sendJSONAs(t, "GET", waitlistPath, tokens[1], nil)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, result = sendJSONAs(t, "GET", waitlistPath, jwtToken, nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Len(t, result["data"].(map[string]interface{})["waiting"], 2)
+
+	// A second seat promotes the head of the queue and tells them about it
+	status, _ = sendJSONAs(t, "PUT", fmt.Sprintf("/api/admin/courses/%d/settings", course.ID), jwtToken, map[string]interface{}{"max_enrollments": 2})
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.True(t, enrolled(students[1].ID))
+	assert.False(t, enrolled(students[2].ID))
+	var notified int64
+	db.Model(&models.OutboxMessage{}).
+		Where("kind = ? AND payload LIKE ? AND payload LIKE ?", outbox.KindEmail, "%"+students[1].Email+"%", "%enrolled from the waitlist%").
+		Count(&notified)
+	assert.Equal(t, int64(1), notified)
+
+	status, result = sendJSONAs(t, "GET", fmt.Sprintf("/api/courses/%d", course.ID), tokens[2], nil)
+	assert.Equal(t, fiber.StatusOK, status)
+	capacity := result["course"].(map[string]interface{})["capacity"].(map[string]interface{})
+	assert.Equal(t, float64(0), capacity["seats_left"])
+	assert.Equal(t, float64(1), capacity["waitlist_position"])
+
+	// Leaving the queue is final until the student joins again
+	status, _ = sendJSONAs(t, "DELETE", waitlistPath, tokens[2], nil)
+	assert.Equal(t, fiber.StatusNoContent, status)
+	var waiting int64
+	db.Model(&models.CourseWaitlist{}).Where("course_id = ?", course.ID).Count(&waiting)
+	assert.Equal(t, int64(0), waiting)
+}